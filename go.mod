@@ -5,12 +5,14 @@ go 1.24.4
 require (
 	github.com/anttikivi/semver v1.0.0
 	github.com/chzyer/readline v1.5.1
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/go-viper/mapstructure/v2 v2.3.0
 	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/reginald-project/reginald-sdk-go v0.0.0-20250703170709-bd0d87e15659
 	github.com/spf13/pflag v1.0.6
 	golang.org/x/sync v0.15.0
+	golang.org/x/sys v0.33.0
 	golang.org/x/term v0.32.0
 )
 
-require golang.org/x/sys v0.33.0 // indirect
+require gopkg.in/yaml.v3 v3.0.1
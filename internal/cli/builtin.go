@@ -0,0 +1,85 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// A builtinCommand is a command that is run by the CLI itself instead of by
+// the plugin that declares it. The built-in commands are declared in
+// the manifest of the core plugin like the other commands so that they are
+// resolved, validated, and shown in the help in the same way. The plugins are
+// not initialized for the built-in commands, and the missing config file and
+// plugin directories are not reported before running them.
+type builtinCommand struct {
+	run      func(ctx context.Context, info *runInfo) error // function that runs the command
+	json     bool                                           // whether the command can print its output as JSON
+	diagnose bool                                           // whether the command is run even if the initialization fails
+}
+
+// builtinCommands contains the built-in commands by their full names. The names
+// of the subcommands are separated by dots like in the "runCommand" calls to
+// the core plugin.
+//
+//nolint:gochecknoglobals // used like constant
+var builtinCommands = map[string]builtinCommand{
+	"doctor": {
+		run:      runDoctor,
+		json:     true,
+		diagnose: true,
+	},
+	"version": {
+		run:      runVersion,
+		json:     true,
+		diagnose: false,
+	},
+}
+
+// lookupBuiltin returns the built-in command for the given command. It returns
+// nil if the command is not a built-in command.
+func lookupBuiltin(cmd *plugin.Command) *builtinCommand {
+	if cmd == nil || cmd.Plugin.External() {
+		return nil
+	}
+
+	b, ok := builtinCommands[strings.Join(cmd.Names(), ".")]
+	if !ok {
+		return nil
+	}
+
+	return &b
+}
+
+// jsonBuiltins returns the quoted names of the built-in commands that can print
+// their output as JSON in sorted order.
+func jsonBuiltins() []string {
+	var names []string
+
+	for name, b := range builtinCommands {
+		if b.json {
+			names = append(names, strconv.Quote(strings.ReplaceAll(name, ".", " ")))
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
@@ -19,17 +19,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"runtime"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
 	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/events"
 	"github.com/reginald-project/reginald/internal/flags"
 	"github.com/reginald-project/reginald/internal/plugin"
-	"github.com/reginald-project/reginald/internal/plugin/builtin"
-	"github.com/reginald-project/reginald/internal/plugin/runtimes"
 	"github.com/reginald-project/reginald/internal/terminal"
 	"github.com/reginald-project/reginald/internal/version"
 )
@@ -46,99 +47,127 @@ type runInfo struct {
 	cmd     *plugin.Command // the command that was run
 	cfg     *config.Config  // config for the run
 	store   *plugin.Store   // loaded plugins
+	diags   *diag.Collector // collector for the diagnostics of the run
 	flagSet *flags.FlagSet  // flag set for the run
 	args    []string        // positional arguments
+	argv    []string        // command-line arguments with the aliases expanded
+	explain string          // config key to explain, if the explain flag was set
 	help    bool            // whether the help flag was set
+	helpAll bool            // whether all of the options should be shown in the help
+	json    bool            // whether the output should be printed as JSON
+	builtin *builtinCommand // the built-in command that was run, if any
 	version bool            // whether the version flag was set
 }
 
-// Execute runs the CLI application and returns any errors from the run.
+// Execute runs the CLI application and returns any errors from the run. If
+// the command returns [plugin.ErrReload], the program is initialized again so
+// that the config is parsed again, and the command is rerun.
 func Execute(ctx context.Context) error {
-	info, err := initialize(ctx)
-	if err != nil {
-		var exitErr *ExitError
-		if errors.As(err, &exitErr) {
-			return &ExitError{
-				Code: exitErr.Code,
-				err:  err,
-			}
+	for {
+		err := execute(ctx)
+		if !errors.Is(err, plugin.ErrReload) {
+			return err
 		}
 
-		return &ExitError{
-			Code: 1,
-			err:  err,
-		}
-	}
-
-	if info.help {
-		return runHelp(info.cmd, info.store)
-	}
-
-	if info.version {
-		runVersion(info.cmd)
-
-		return nil
+		slog.InfoContext(ctx, "reloading the config")
 	}
+}
 
-	if err = runtimes.Resolve(ctx, info.store, info.cfg); err != nil {
-		return &ExitError{
-			Code: 1,
-			err:  err,
-		}
-	}
+// execute initializes the program and runs the command once.
+func execute(ctx context.Context) error {
+	start := time.Now()
 
-	if err = info.store.Init(ctx, builtin.Service, info.cfg.Tasks); err != nil {
-		return &ExitError{
-			Code: 1,
-			err:  err,
-		}
+	info, err := initialize(ctx)
+	if err != nil {
+		return exitError(err)
 	}
 
-	shutdownDone := false
+	h := chain(dispatch(info), withExitCode, withTimings(start), withLogging, withRecover)
 
-	shutdown := func() {
-		if shutdownDone {
-			return
-		}
+	return h(ctx, info)
+}
 
-		if err = info.store.ShutdownAll(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "Error when shutting down plugins: %v\n", err)
-		}
+// openEvents connects to the events socket if it is set in cfg and makes it
+// the default event stream. The prompts of the run are reported to the stream,
+// and their answers are read from it. The returned function closes the stream.
+func openEvents(ctx context.Context, cfg *config.Config) (func(), error) {
+	if cfg.EventsSocket == "" {
+		return func() {}, nil
+	}
 
-		shutdownDone = true
+	stream, err := events.Dial(string(cfg.EventsSocket))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
 	}
-	defer shutdown()
 
-	if err = run(ctx, info); err != nil {
-		return &ExitError{
-			Code: 1,
-			err:  err,
+	slog.InfoContext(ctx, "writing events to socket", "path", cfg.EventsSocket)
+
+	events.SetDefault(stream)
+	terminal.Default().SetAnswers(stream)
+	terminal.Default().SetPromptHook(func(prompt string, options []string, secret bool) {
+		events.Emit(events.Event{ //nolint:exhaustruct // only the fields of the event type are set
+			Type:    events.TypePrompt,
+			Prompt:  prompt,
+			Options: options,
+			Secret:  secret,
+		})
+	})
+
+	return func() {
+		events.SetDefault(nil)
+		terminal.Default().SetAnswers(nil)
+		terminal.Default().SetPromptHook(nil)
+
+		if err := stream.Close(); err != nil {
+			slog.WarnContext(ctx, "failed to close the events socket", "err", err)
 		}
-	}
+	}, nil
+}
 
-	shutdown()
+// A versionInfo is the data of the "version" command in the JSON output.
+type versionInfo struct {
+	Plugin  *pluginVersion `json:"plugin,omitempty"`
+	Version string         `json:"version"`
+	OS      string         `json:"os"`
+	Arch    string         `json:"arch"`
+}
 
-	return nil
+// A pluginVersion is the version of the plugin in the JSON output of
+// the "version" command.
+type pluginVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 // printVersion prints the program's version or, if the user specified
 // the "--version" flag for a command from a plugin, the version of the plugin.
-func printVersion(cmd *plugin.Command) {
-	terminal.Printf("%s version %s (%s/%s)\n", Name, version.Version(), runtime.GOOS, runtime.GOARCH)
+func printVersion(out output, cmd *plugin.Command) error {
+	data := versionInfo{
+		Version: version.Version().String(),
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Plugin:  nil,
+	}
 
 	if cmd != nil && cmd.Plugin.External() {
 		manifest := cmd.Plugin.Manifest()
-		terminal.Printf("Plugin %q version %s\n", manifest.Name, manifest.Version)
-		terminal.Println()
-		terminal.Printf(
-			"%s is licensed under the Apache License, Version 2.0: <https://www.apache.org/licenses/LICENSE-2.0>\n",
-			ProgramName,
-		)
-	} else {
-		terminal.Println("Licensed under the Apache License, Version 2.0: <https://www.apache.org/licenses/LICENSE-2.0>") //nolint:lll
+		data.Plugin = &pluginVersion{Name: manifest.Name, Version: manifest.Version}
 	}
 
-	terminal.Flush()
+	return out.print(data, nil, func() {
+		terminal.Printf("%s version %s (%s/%s)\n", Name, data.Version, data.OS, data.Arch)
+
+		if data.Plugin != nil {
+			terminal.Printf("Plugin %q version %s\n", data.Plugin.Name, data.Plugin.Version)
+			terminal.Println()
+			terminal.Printf(
+				"%s is licensed under the Apache License, Version 2.0: <https://www.apache.org/licenses/LICENSE-2.0>\n",
+				ProgramName,
+			)
+		} else {
+			terminal.Println("Licensed under the Apache License, Version 2.0: <https://www.apache.org/licenses/LICENSE-2.0>") //nolint:lll
+		}
+	})
 }
 
 // rootCommand returns the root command of the given command.
@@ -156,6 +185,38 @@ func rootCommand(cmd *plugin.Command) *plugin.Command {
 	return root
 }
 
+// commandChain returns the commands from the root command to cmd.
+func commandChain(cmd *plugin.Command) []*plugin.Command {
+	var chain []*plugin.Command
+
+	for c := cmd; c != nil; c = c.Parent {
+		chain = append(chain, c)
+	}
+
+	slices.Reverse(chain)
+
+	return chain
+}
+
+// inheritConfig returns the config values of a command with the inherited
+// values of the persistent config entries of its parents added. The values of
+// the command itself and the values of the nearest parents take precedence.
+func inheritConfig(cfgs, inherited api.KeyValues) api.KeyValues {
+	if len(inherited) == 0 {
+		return cfgs
+	}
+
+	result := slices.Clip(cfgs)
+
+	for _, kv := range slices.Backward(inherited) {
+		if _, ok := result.Get(kv.Key); !ok {
+			result = append(result, kv)
+		}
+	}
+
+	return result
+}
+
 // run runs the requested command.
 func run(ctx context.Context, info *runInfo) error {
 	var (
@@ -174,16 +235,16 @@ func run(ctx context.Context, info *runInfo) error {
 		}
 	}
 
-	names := info.cmd.Names()
-
-	for len(names) > 0 {
-		s := names[0]
+	// inherited contains the values of the persistent config entries of
+	// the parents of the command, starting from the root command.
+	var inherited api.KeyValues
 
+	for _, c := range commandChain(info.cmd) {
 		var ok bool
 
-		cfg, ok = cfgs.Get(s)
+		cfg, ok = cfgs.Get(c.Name)
 		if !ok {
-			return fmt.Errorf("%w: %s", errCmdConfig, s)
+			return fmt.Errorf("%w: %s", errCmdConfig, c.Name)
 		}
 
 		cfgs, err = cfg.Configs()
@@ -191,26 +252,76 @@ func run(ctx context.Context, info *runInfo) error {
 			return fmt.Errorf("failed to get configs from KeyVal %q: %w", cfg.Key, err)
 		}
 
-		names = names[1:]
+		for _, entry := range c.PersistentConfig {
+			if kv, ok := cfgs.Get(entry.Key); ok {
+				inherited = append(inherited, kv)
+			}
+		}
 	}
 
-	if err = info.cmd.Run(ctx, info.store, cfgs, pluginCfg, info.cfg.Tasks); err != nil {
-		return fmt.Errorf("running command %q failed: %w", strings.Join(info.cmd.Names(), " "), err)
+	cfgs = inheritConfig(cfgs, inherited)
+
+	name := strings.Join(info.cmd.Names(), " ")
+
+	result, err := info.cmd.Run(ctx, info.store, info.args, cfgs, pluginCfg, info.cfg.CommandContext())
+	if err != nil {
+		return fmt.Errorf("running command %q failed: %w", name, err)
+	}
+
+	return printCommandResult(info, name, result)
+}
+
+// printCommandResult prints the result of the plugin command with the given
+// name. With "--json", the data of the result is printed in the common JSON
+// envelope. Otherwise, the message of a successful command is printed. If
+// the command failed, the returned error has the exit code of the command.
+func printCommandResult(info *runInfo, name string, result *plugin.RunCommandResult) error {
+	err := result.Err()
+
+	if info.json {
+		var data any
+		if len(result.Data) > 0 {
+			data = result.Data
+		}
+
+		err = newOutput(info, name).print(data, err, func() {})
+	} else if err == nil && result.Message != "" {
+		terminal.Println(result.Message)
+		terminal.Flush()
+	}
+
+	if err != nil {
+		return &ExitError{
+			Code: result.ExitCode,
+			err:  fmt.Errorf("running command %q failed: %w", name, err),
+		}
 	}
 
 	return nil
 }
 
+// page directs the standard output to a pager if paging is enabled. The returned
+// function waits for the user to quit the pager and restores the output.
+func page(ctx context.Context) func() {
+	pager := terminal.Page(ctx)
+
+	return func() {
+		if err := pager.Close(); err != nil {
+			slog.WarnContext(ctx, "failed to close pager", "err", err)
+		}
+	}
+}
+
 // runVersion runs the version command or flag by resolving the place of
-// the command or the flag in the arguments list. It prints the version of
-// the command that was given before the flag.
-func runVersion(cmd *plugin.Command) {
-	root := rootCommand(cmd)
+// the command or the flag in the command-line arguments. It prints the version
+// of the command that was given before the flag.
+func runVersion(_ context.Context, info *runInfo) error {
+	root := rootCommand(info.cmd)
 
 	var found *plugin.Command
 
 Loop:
-	for _, arg := range os.Args[1:] {
+	for _, arg := range info.argv[1:] {
 		if arg == "--version" {
 			break
 		}
@@ -232,5 +343,5 @@ Loop:
 		}
 	}
 
-	printVersion(found)
+	return printVersion(newOutput(info, "version"), found)
 }
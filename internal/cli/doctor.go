@@ -0,0 +1,565 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/plugin/builtin"
+	"github.com/reginald-project/reginald/internal/plugin/runtimes"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"golang.org/x/term"
+)
+
+// Severities of the check results of the "doctor" command.
+const (
+	checkOK checkSeverity = iota
+	checkWarning
+	checkError
+)
+
+// errDoctor is returned when the "doctor" command finds errors.
+var errDoctor = errors.New("doctor found problems")
+
+// A checkResult is the result of a single check run by the "doctor" command.
+type checkResult struct {
+	msg      string        // description of the checked item and its state
+	hint     string        // suggestion for fixing the problem
+	severity checkSeverity // severity of the result
+}
+
+// A checkSection is a titled group of related check results.
+type checkSection struct {
+	title   string
+	results []checkResult
+}
+
+// A doctorReport contains the results of the checks run by the "doctor"
+// command.
+type doctorReport struct {
+	Sections []checkSection `json:"sections"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+}
+
+// checkSeverity is the severity of a check result.
+type checkSeverity int
+
+// String returns the label of s that is printed before the check results.
+func (s checkSeverity) String() string {
+	switch s {
+	case checkOK:
+		return "ok"
+	case checkWarning:
+		return "warning"
+	case checkError:
+		return "error"
+	default:
+		return fmt.Sprintf("checkSeverity(%d)", int(s))
+	}
+}
+
+// MarshalJSON encodes r as a JSON object. It implements [json.Marshaler].
+func (r checkResult) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+		Hint     string `json:"hint,omitempty"`
+	}{
+		Severity: r.severity.String(),
+		Message:  r.msg,
+		Hint:     r.hint,
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal check result: %w", err)
+	}
+
+	return data, nil
+}
+
+// MarshalJSON encodes s as a JSON object. It implements [json.Marshaler].
+func (s checkSection) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Title   string        `json:"title"`
+		Results []checkResult `json:"results"`
+	}{
+		Title:   s.title,
+		Results: s.results,
+	}
+
+	if v.Results == nil {
+		v.Results = []checkResult{}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal check section: %w", err)
+	}
+
+	return data, nil
+}
+
+// runDoctor runs the "doctor" command. It checks the config file, the plugins,
+// the terminal, and the logging, and prints a report of the results with
+// suggestions for fixing the found problems. It returns an error if any of
+// the checks failed.
+func runDoctor(ctx context.Context, info *runInfo) error {
+	cfg, cfgResults := checkConfigFile(ctx, info)
+
+	// The terminal checks report the settings that the runs actually use.
+	applyCI(cfg)
+
+	// The store is created again from the checked config so that the report
+	// does not depend on what the initialization of this run managed to load.
+	// The manifest cache is not used so that the manifests are validated again.
+	store, storeErr := plugin.NewStore(ctx, builtin.Manifests(), cfg.Directory, cfg.PluginPaths, "")
+
+	var pathErrs plugin.PathErrors
+	if errors.As(storeErr, &pathErrs) {
+		storeErr = nil
+	}
+
+	if storeErr == nil && cfg.HasFile() {
+		cfgResults = append(cfgResults, checkConfigValues(ctx, cfg, info, store)...)
+	}
+
+	sections := []checkSection{
+		{title: "Config", results: cfgResults},
+		{title: "Plugins", results: checkPlugins(ctx, cfg, storeErr)},
+		{title: "Terminal", results: checkTerminal(cfg)},
+		{title: "Logging", results: checkLogging(cfg)},
+	}
+
+	report := doctorReport{Sections: sections, Errors: 0, Warnings: 0}
+
+	for _, s := range sections {
+		for _, r := range s.results {
+			switch r.severity {
+			case checkOK:
+			case checkWarning:
+				report.Warnings++
+			case checkError:
+				report.Errors++
+			default:
+				panic(fmt.Sprintf("invalid check severity: %d", r.severity))
+			}
+		}
+	}
+
+	var err error
+	if report.Errors > 0 {
+		err = fmt.Errorf("%w: %d error(s) and %d warning(s)", errDoctor, report.Errors, report.Warnings)
+	}
+
+	return newOutput(info, "doctor").print(report, err, func() { printDoctorReport(report) })
+}
+
+// printDoctorReport prints the results of the checks as text.
+func printDoctorReport(report doctorReport) {
+	for _, s := range report.Sections {
+		terminal.Println(s.title)
+
+		for _, r := range s.results {
+			terminal.Printf("  %-8s%s\n", r.severity, r.msg)
+
+			if r.hint != "" {
+				terminal.Printf("  %-8s%s\n", "", "hint: "+r.hint)
+			}
+		}
+
+		terminal.Println()
+	}
+
+	switch {
+	case report.Errors > 0:
+		// The error is reported when the command returns.
+	case report.Warnings > 0:
+		terminal.Printf("Found %d warning(s)\n", report.Warnings)
+	default:
+		terminal.Println("No problems found")
+	}
+}
+
+// checkConfigFile checks that the config file is found and that it can be
+// parsed. It returns the parsed config or, if the config file cannot be
+// parsed, the config of the current run that contains the defaults.
+func checkConfigFile(ctx context.Context, info *runInfo) (*config.Config, []checkResult) {
+	cfg, err := config.Parse(ctx, info.flagSet)
+
+	var fileErr *config.FileError
+
+	switch {
+	case errors.As(err, &fileErr):
+		return cfg, []checkResult{{
+			msg:      "No config file found",
+			hint:     fmt.Sprintf("create %s.toml in the dotfiles directory %s or set the file with --config", Name, cfg.Directory),
+			severity: checkWarning,
+		}}
+	case err != nil:
+		return info.cfg, []checkResult{{
+			msg:      fmt.Sprintf("Config file could not be parsed: %v", err),
+			hint:     "fix the error in the config file or set another file with --config",
+			severity: checkError,
+		}}
+	default:
+		return cfg, []checkResult{{
+			msg:      fmt.Sprintf("Config file found at %s", cfg.File()),
+			hint:     "",
+			severity: checkOK,
+		}}
+	}
+}
+
+// checkConfigValues checks that the plugin and the task values in the config
+// are valid for the plugins in store.
+func checkConfigValues(ctx context.Context, cfg *config.Config, info *runInfo, store *plugin.Store) []checkResult {
+	var warnings []string
+
+	report := func(class diag.Class, msg string) {
+		if class == diag.Deprecated && !slices.Contains(warnings, msg) {
+			warnings = append(warnings, msg)
+		}
+	}
+
+	opts := config.ApplyOptions{
+		Dir:     cfg.Directory,
+		FlagSet: info.flagSet,
+		Store:   store,
+		Report:  report,
+	}
+	if err := config.ApplyPlugins(ctx, cfg, opts); err != nil {
+		return []checkResult{{
+			msg:      fmt.Sprintf("Plugin config is invalid: %v", err),
+			hint:     "fix the value in the config file; see the help of the commands for the valid options",
+			severity: checkError,
+		}}
+	}
+
+	taskOpts := config.TaskApplyOptions{
+		Dir:      cfg.Directory,
+		Store:    store,
+		Defaults: cfg.Defaults,
+		Report:   report,
+	}
+
+	tasks, err := config.ApplyTasks(ctx, cfg.RawTasks, taskOpts)
+	if err != nil {
+		return []checkResult{{
+			msg:      fmt.Sprintf("Task config is invalid: %v", err),
+			hint:     "fix the task in the config file",
+			severity: checkError,
+		}}
+	}
+
+	results := []checkResult{{
+		msg:      fmt.Sprintf("Config is valid with %d task(s)", len(tasks)),
+		hint:     "",
+		severity: checkOK,
+	}}
+
+	for _, msg := range warnings {
+		results = append(results, checkResult{
+			msg:      msg,
+			hint:     "remove the deprecated value from the config or use its replacement",
+			severity: checkWarning,
+		})
+	}
+
+	return results
+}
+
+// checkLogging checks that the log output of the config can be written to.
+func checkLogging(cfg *config.Config) []checkResult {
+	output := cfg.Logging.Output
+
+	switch {
+	case !cfg.Logging.Enabled:
+		return []checkResult{{msg: "Logging is disabled", hint: "", severity: checkOK}}
+	case strings.EqualFold(output, "stderr"), strings.EqualFold(output, "stdout"):
+		return []checkResult{{msg: "Logs are written to " + strings.ToLower(output), hint: "", severity: checkOK}}
+	}
+
+	if err := checkWritable(fspath.Path(output)); err != nil {
+		return []checkResult{{
+			msg:      fmt.Sprintf("Log file %s is not writable: %v", output, err),
+			hint:     "fix the permissions of the file or set another file with \"logging.output\" in the config",
+			severity: checkError,
+		}}
+	}
+
+	return []checkResult{{msg: "Logs are written to " + output, hint: "", severity: checkOK}}
+}
+
+// checkManifest checks that the plugin with the given manifest can be run.
+// The executable of a plugin without a runtime must be executable, and
+// the runtime of a plugin must be installed.
+func checkManifest(ctx context.Context, m *api.Manifest) checkResult {
+	if m.Runtime == nil || m.Runtime.Name == "" {
+		ok, err := fsutil.IsExecutable(m.Executable)
+
+		switch {
+		case err != nil:
+			return checkResult{
+				msg:      fmt.Sprintf("Executable of plugin %q cannot be checked: %v", m.Name, err),
+				hint:     "",
+				severity: checkError,
+			}
+		case !ok:
+			return checkResult{
+				msg:      fmt.Sprintf("Executable of plugin %q is not executable: %s", m.Name, m.Executable),
+				hint:     fmt.Sprintf("make the file executable, for example, with \"chmod +x %s\"", m.Executable),
+				severity: checkError,
+			}
+		default:
+			return checkResult{msg: fmt.Sprintf("Plugin %q %s", m.Name, m.Version), hint: "", severity: checkOK}
+		}
+	}
+
+	status, err := runtimes.Check(ctx, m.Runtime)
+
+	switch {
+	case errors.Is(err, runtimes.ErrRuntimeNotFound):
+		return checkResult{
+			msg:      fmt.Sprintf("Runtime %q for plugin %q is not installed", status.Name, m.Name),
+			hint:     fmt.Sprintf("install %s or add a task that provides it to the config", status.Name),
+			severity: checkError,
+		}
+	case errors.Is(err, runtimes.ErrRuntimeVersion):
+		return checkResult{
+			msg:      fmt.Sprintf("Runtime for plugin %q is too old: %v", m.Name, err),
+			hint:     fmt.Sprintf("upgrade %s to version %s or newer", status.Name, status.Required),
+			severity: checkError,
+		}
+	case err != nil:
+		return checkResult{
+			msg:      fmt.Sprintf("Runtime for plugin %q cannot be checked: %v", m.Name, err),
+			hint:     "",
+			severity: checkError,
+		}
+	}
+
+	v := "unknown version"
+	if status.Version != nil {
+		v = status.Version.String()
+	}
+
+	return checkResult{
+		msg:      fmt.Sprintf("Plugin %q %s uses %s %s at %s", m.Name, m.Version, status.Name, v, status.Executable),
+		hint:     "",
+		severity: checkOK,
+	}
+}
+
+// checkPlugins checks the plugin search paths of the config and the manifests
+// of the plugins found in them. The given store error is the error from
+// loading the plugins together, like a conflict between two plugins.
+func checkPlugins(ctx context.Context, cfg *config.Config, storeErr error) []checkResult {
+	var results []checkResult
+
+	if len(cfg.PluginPaths) == 0 {
+		results = append(results, checkResult{msg: "No plugin search paths set", hint: "", severity: checkOK})
+	}
+
+	manifestErr := false
+
+	for _, p := range cfg.PluginPaths {
+		path, err := plugin.ResolveSearchPath(cfg.Directory, p)
+		if err != nil {
+			results = append(results, checkResult{msg: err.Error(), hint: "", severity: checkError})
+
+			continue
+		}
+
+		entries, err := os.ReadDir(string(path))
+		if errors.Is(err, os.ErrNotExist) {
+			results = append(results, checkResult{
+				msg:      fmt.Sprintf("Plugin search path %s does not exist", path),
+				hint:     "create the directory or remove it from \"plugin-paths\" in the config",
+				severity: checkWarning,
+			})
+
+			continue
+		} else if err != nil {
+			results = append(results, checkResult{
+				msg:      fmt.Sprintf("Plugin search path %s cannot be read: %v", path, err),
+				hint:     "make sure that the search path is a directory that you can read",
+				severity: checkError,
+			})
+
+			continue
+		}
+
+		results = append(results, checkResult{msg: "Plugin search path " + string(path), hint: "", severity: checkOK})
+
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+
+			m, err := plugin.ReadManifest(path.Join(e.Name(), "manifest.json"))
+			if err != nil {
+				manifestErr = true
+
+				results = append(results, checkResult{
+					msg:      fmt.Sprintf("Invalid plugin in %s: %v", path.Join(e.Name()), err),
+					hint:     "fix the manifest of the plugin or remove the plugin directory",
+					severity: checkError,
+				})
+
+				continue
+			}
+
+			results = append(results, checkManifest(ctx, m))
+		}
+	}
+
+	// The invalid manifests also cause loading the plugins to fail so
+	// the error would only be reported twice.
+	if storeErr != nil && !manifestErr {
+		results = append(results, checkResult{
+			msg:      fmt.Sprintf("Plugins cannot be loaded: %v", storeErr),
+			hint:     "remove or rename the conflicting plugins",
+			severity: checkError,
+		})
+	}
+
+	return results
+}
+
+// checkTerminal reports the capabilities of the terminal that Reginald is run
+// in.
+func checkTerminal(cfg *config.Config) []checkResult {
+	var results []checkResult
+
+	stdin := term.IsTerminal(int(os.Stdin.Fd()))
+	stdout := term.IsTerminal(int(os.Stdout.Fd()))
+
+	switch {
+	case stdin:
+		results = append(results, checkResult{msg: "Standard input is a terminal", hint: "", severity: checkOK})
+	case cfg.Interactive:
+		results = append(results, checkResult{
+			msg:      "Standard input is not a terminal but interactive mode is enabled",
+			hint:     "the prompts of interactive mode are not shown unless Reginald is run in a terminal",
+			severity: checkWarning,
+		})
+	default:
+		results = append(results, checkResult{msg: "Standard input is not a terminal", hint: "", severity: checkOK})
+	}
+
+	if stdout {
+		results = append(results, checkResult{
+			msg:      fmt.Sprintf("Standard output is a terminal with %d columns", terminal.Width()),
+			hint:     "",
+			severity: checkOK,
+		})
+	} else {
+		results = append(results, checkResult{msg: "Standard output is not a terminal", hint: "", severity: checkOK})
+	}
+
+	if cfg.CI {
+		results = append(results, checkResult{
+			msg:      "CI mode is enabled, so the default answers are used for the prompts",
+			hint:     "",
+			severity: checkOK,
+		})
+	}
+
+	if os.Getenv("TERM") == "dumb" {
+		results = append(results, checkResult{
+			msg:      "TERM is set to \"dumb\"",
+			hint:     "set TERM to match your terminal so that the output is shown correctly",
+			severity: checkWarning,
+		})
+	}
+
+	colors := "disabled"
+	if terminal.ColorsEnabled(cfg.Color) {
+		colors = "enabled"
+	}
+
+	results = append(results, checkResult{
+		msg:      fmt.Sprintf("Colors are %s (color mode %q)", colors, cfg.Color),
+		hint:     "",
+		severity: checkOK,
+	})
+
+	return results
+}
+
+// checkWritable checks that the file at path can be written to. If the file
+// does not exist, it checks that it can be created to the closest existing
+// parent directory. The file is not modified.
+func checkWritable(path fspath.Path) error {
+	f, err := os.OpenFile(string(path), os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		if err = f.Close(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w", err)
+	}
+
+	dir := path.Dir()
+
+	for {
+		var ok bool
+
+		if ok, err = dir.IsDir(); err != nil {
+			return fmt.Errorf("failed to check %q: %w", dir, err)
+		}
+
+		if ok || dir.Dir() == dir {
+			break
+		}
+
+		dir = dir.Dir()
+	}
+
+	f, err = os.CreateTemp(string(dir), "."+Name+"-doctor-*")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	name := f.Name()
+
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = os.Remove(name); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -14,12 +14,7 @@
 
 package cli
 
-import (
-	"errors"
-	"strings"
-
-	"github.com/reginald-project/reginald/internal/plugin"
-)
+import "errors"
 
 // errCmdConfig is returned when the config for the command that is run is not
 // found.
@@ -43,16 +38,6 @@ type ExitError struct {
 // The name might be confusing, but let it go.
 type SuccessError struct{}
 
-// strictError is an error that is returned by the CLI when the program is
-// executed in strict mode and the config file or the plugins directory is not
-// found.
-
-// strictError is used to store errors that are returned during the bootstrap
-// process that cause the program to exit only if the strict mode is enabled.
-type strictError struct {
-	errs []error
-}
-
 // Error returns the value of the error as a string. This function implements
 // the error interface for Success.
 func (*SuccessError) Error() string {
@@ -68,30 +53,3 @@ func (e *ExitError) Error() string {
 func (e *ExitError) Unwrap() error {
 	return e.err
 }
-
-// Error returns the value of e as a string.
-func (e *strictError) Error() string {
-	if len(e.errs) == 1 {
-		return e.errs[0].Error()
-	}
-
-	s := ""
-
-	for _, err := range e.errs {
-		var pathErrs plugin.PathErrors
-		if errors.As(err, &pathErrs) && len(pathErrs) > 1 {
-			s += "\n  - plugin search paths not found: " + strings.Join(pathErrs.Paths(), "; ")
-
-			continue
-		}
-
-		s += "\n  - " + err.Error()
-	}
-
-	return s
-}
-
-// Unwrap returns the wrapped errors from e.
-func (e *strictError) Unwrap() []error {
-	return e.errs
-}
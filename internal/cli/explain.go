@@ -0,0 +1,89 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// runExplain runs the "--explain" flag by printing how the value for the given
+// config key or task was resolved.
+func runExplain(info *runInfo) error {
+	opts := config.ApplyOptions{
+		Dir:     info.cfg.Directory,
+		FlagSet: info.flagSet,
+		Store:   info.store,
+	}
+
+	out := newOutput(info, "explain")
+
+	explanations, err := config.Explain(info.cfg, info.explain, opts)
+	if err != nil {
+		return out.print(nil, fmt.Errorf("failed to explain %q: %w", info.explain, err), func() {})
+	}
+
+	return out.print(explanations, nil, func() { printExplanations(explanations) })
+}
+
+// printExplanations prints the explanations as text. For each explanation, it
+// prints the resolved value and a table of the values that were considered for
+// it, the used one highlighted.
+func printExplanations(explanations []config.Explanation) {
+
+	cols := []terminal.TableColumn{
+		{Header: "SOURCE", AlignRight: false},
+		{Header: "ORIGIN", AlignRight: false},
+		{Header: "VALUE", AlignRight: false},
+	}
+
+	for i, e := range explanations {
+		if i > 0 {
+			terminal.Println()
+		}
+
+		winner := e.Candidates[e.Winner]
+		from := string(winner.Source)
+
+		if winner.Origin != "" {
+			from += " " + winner.Origin
+		}
+
+		terminal.Printf("%s = %s (from %s)\n", e.Key, e.Value, from)
+
+		rows := make([]terminal.TableRow, 0, len(e.Candidates))
+
+		for j, c := range e.Candidates {
+			style := terminal.RowPlain
+			if j == e.Winner {
+				style = terminal.RowSuccess
+			}
+
+			origin := c.Origin
+			if origin == "" {
+				origin = "-"
+			}
+
+			rows = append(rows, terminal.TableRow{
+				Cells: []string{string(c.Source), origin, c.Value},
+				Style: style,
+			})
+		}
+
+		terminal.PrintTable(cols, rows)
+	}
+}
@@ -17,7 +17,6 @@ package cli
 import (
 	"bytes"
 	"fmt"
-	"os"
 	"slices"
 	"sort"
 	"strings"
@@ -39,13 +38,45 @@ const (
 )
 
 // defaultUsage returns the default usage message for the program.
-func defaultUsage() string { //nolint:gocognit // no need to split this up
-	flagSet := newFlagSet()
+func defaultUsage() string {
+	parts := []string{Name}
+	parts = append(parts, flagUsages(newFlagSet(), flags.GroupGlobal)...)
+	parts = append(parts, "<command>", "[<args>]")
+
+	return strings.Join(parts, " ")
+}
+
+// commandUsage returns the usage message for the given command. If the command
+// does not define its usage, the usage is created from the flags of
+// the command in flagSet.
+func commandUsage(cmd *plugin.Command, flagSet *flags.FlagSet) string {
+	if cmd.Usage != "" {
+		return cmd.Usage
+	}
+
+	parts := []string{cmd.Name}
+	parts = append(parts, flagUsages(flagSet, commandGroup(cmd))...)
+
+	if len(cmd.Commands) > 0 {
+		parts = append(parts, "<command>")
+	}
+
+	if cmd.Args != nil {
+		parts = append(parts, "[<args>]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// flagUsages returns the usage parts for the flags in the given group in
+// flagSet. The mutually exclusive flags are combined into a single part, and
+// the parts are sorted.
+func flagUsages(flagSet *flags.FlagSet, group flags.Group) []string { //nolint:gocognit // no need to split this up
 	mutexGroups := flagSet.MutuallyExclusive()
 	grouped := make(map[string]bool, 0)
 
-	for _, group := range mutexGroups {
-		for _, name := range group {
+	for _, mutex := range mutexGroups {
+		for _, name := range mutex {
 			grouped[name] = true
 		}
 	}
@@ -53,7 +84,7 @@ func defaultUsage() string { //nolint:gocognit // no need to split this up
 	var singles []string
 
 	flagSet.VisitAll(func(f *pflag.Flag) {
-		if grouped[f.Name] {
+		if grouped[f.Name] || flagSet.Group(f.Name) != group {
 			return
 		}
 
@@ -94,10 +125,14 @@ func defaultUsage() string { //nolint:gocognit // no need to split this up
 
 	mutexParts := make([]string, 0, len(mutexGroups))
 
-	for _, group := range mutexGroups {
+	for _, mutex := range mutexGroups {
 		var elems []string
 
-		for _, name := range group {
+		if !slices.ContainsFunc(mutex, func(name string) bool { return flagSet.Group(name) == group }) {
+			continue
+		}
+
+		for _, name := range mutex {
 			f := flagSet.Lookup(name)
 			if f == nil {
 				panic(
@@ -120,8 +155,6 @@ func defaultUsage() string { //nolint:gocognit // no need to split this up
 			}
 
 			elems = append(elems, s)
-
-			continue
 		}
 
 		sort.Strings(elems)
@@ -137,11 +170,7 @@ func defaultUsage() string { //nolint:gocognit // no need to split this up
 
 	sort.Strings(usages)
 
-	parts := []string{Name}
-	parts = append(parts, usages...)
-	parts = append(parts, "<command>", "[<args>]")
-
-	return strings.Join(parts, " ")
+	return usages
 }
 
 // formatCommands wraps the given commands and their descriptions to the given
@@ -254,23 +283,27 @@ func formatUsage(s string, width int, parents ...string) string {
 	return strings.Join(lines, "\n")
 }
 
-// printHelp prints the help message for the given command.
-func printHelp(cmd *plugin.Command, flagSet *flags.FlagSet, store *plugin.Store) {
+// printHelp prints the help message for the given command. The options are
+// printed in groups: first the options of the command and its parents, then
+// the global options, and, if all is true, the advanced options. The hidden
+// options are printed only if all is true.
+func printHelp(cmd *plugin.Command, flagSet *flags.FlagSet, store *plugin.Store, all bool) {
 	var sb strings.Builder
 
 	width := min(max(terminal.Width(), minWidth), maxWidth)
 	desc := description
 	help := rootHelp
-	cmds := store.Commands
+	cmds := slices.Collect(store.CommandsFor(nil))
 
 	var (
 		usage   string // don't calculate unless needed
 		parents []string
+		chain   []*plugin.Command
 	)
 
 	if cmd != nil {
 		desc = cmd.Description
-		usage = cmd.Usage
+		usage = commandUsage(cmd, flagSet)
 		help = cmd.Help
 
 		for parent := cmd.Parent; parent != nil; parent = parent.Parent {
@@ -280,8 +313,14 @@ func printHelp(cmd *plugin.Command, flagSet *flags.FlagSet, store *plugin.Store)
 		parents = append(parents, Name)
 		slices.Reverse(parents)
 
+		for c := cmd; c != nil; c = c.Parent {
+			chain = append(chain, c)
+		}
+
+		slices.Reverse(chain)
+
 		if len(cmd.Commands) > 0 {
-			cmds = cmd.Commands
+			cmds = slices.Collect(store.CommandsFor(cmd))
 		}
 	} else {
 		usage = defaultUsage()
@@ -294,24 +333,49 @@ func printHelp(cmd *plugin.Command, flagSet *flags.FlagSet, store *plugin.Store)
 	sb.WriteString(text.Wrap(help, width))
 	sb.WriteString("\nCommands:\n")
 	sb.WriteString(formatCommands(cmds, 2, width)) //nolint:mnd
-	sb.WriteString("\nOptions:\n")
-	sb.WriteString(flagSet.FlagUsagesWrapped(width))
+
+	for _, c := range chain {
+		group := commandGroup(c)
+		if !flagSet.HasGroupFlags(group, all) {
+			continue
+		}
+
+		sb.WriteString("\nOptions for " + Name + " " + strings.Join(c.Names(), " ") + ":\n")
+		sb.WriteString(flagSet.FlagUsagesGroup(group, width, all))
+	}
+
+	if len(chain) > 0 {
+		sb.WriteString("\nGlobal options:\n")
+	} else {
+		sb.WriteString("\nOptions:\n")
+	}
+
+	sb.WriteString(flagSet.FlagUsagesGroup(flags.GroupGlobal, width, all))
+
+	if all {
+		sb.WriteString("\nAdvanced options:\n")
+		sb.WriteString(flagSet.FlagUsagesGroup(flags.GroupAdvanced, width, all))
+	} else {
+		sb.WriteString("\n")
+		sb.WriteString(text.Wrap("Use --help --all to show the advanced and hidden options.", width))
+	}
 
 	terminal.Print(sb.String())
 	terminal.Flush()
 }
 
 // runHelp runs the help command or flag by resolving the place of the command
-// or the flag in the arguments list. It prints the help message of the command
-// that was given before the flag.
-func runHelp(cmd *plugin.Command, store *plugin.Store) error {
+// or the flag in the arguments list argv. It prints the help message of
+// the command that was given before the flag. If all is true, the help message
+// includes the advanced and the hidden options.
+func runHelp(cmd *plugin.Command, store *plugin.Store, argv []string, all bool) error {
 	root := rootCommand(cmd)
 	flagSet := newFlagSet()
 
 	var found *plugin.Command
 
 Loop:
-	for _, arg := range os.Args[1:] {
+	for _, arg := range argv[1:] {
 		if arg == "-h" || arg == "--help" {
 			break
 		}
@@ -345,7 +409,7 @@ Loop:
 		}
 	}
 
-	printHelp(found, flagSet, store)
+	printHelp(found, flagSet, store, all)
 
 	return nil
 }
@@ -19,16 +19,25 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/errhint"
 	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/httpclient"
 	"github.com/reginald-project/reginald/internal/logger"
 	"github.com/reginald-project/reginald/internal/plugin"
 	"github.com/reginald-project/reginald/internal/plugin/builtin"
+	"github.com/reginald-project/reginald/internal/registry"
 	"github.com/reginald-project/reginald/internal/system"
 	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/timing"
 	"github.com/reginald-project/reginald/internal/version"
 	"github.com/spf13/pflag"
 )
@@ -36,10 +45,19 @@ import (
 // errInvalidArgs is the error returned when the arguments are invalid.
 var errInvalidArgs = errors.New("invalid arguments")
 
-// addFlags adds the flags from the given command to the flag set.
+// addFlags adds the flags from the given command to the flag set. The flags are
+// put in the group of the command. The flags of the persistent config entries
+// are also added so that they can be given after the subcommands as
+// the commands are added to the flag set as they are found on the command
+// line.
 func addFlags(flagSet *flags.FlagSet, cmd *plugin.Command) error {
-	for i := range cmd.Config {
-		if err := flagSet.AddPluginFlag(&cmd.Config[i], cmd.Plugin.Manifest().Domain); err != nil {
+	group := commandGroup(cmd)
+	entries := cmd.Entries()
+
+	for i := range entries {
+		entry := &entries[i]
+
+		if err := flagSet.AddPluginFlag(entry, cmd.Options[entry.Key], cmd.Plugin.Manifest().Domain, group); err != nil {
 			return fmt.Errorf("%w", err)
 		}
 	}
@@ -47,6 +65,11 @@ func addFlags(flagSet *flags.FlagSet, cmd *plugin.Command) error {
 	return nil
 }
 
+// commandGroup returns the flag group for the flags of the given command.
+func commandGroup(cmd *plugin.Command) flags.Group {
+	return flags.CommandGroup(strings.Join(cmd.Names(), " "))
+}
+
 // collectFlags removes all of the known flags from the arguments list and
 // appends them to flags. It returns the non-flag arguments as the first return
 // value and the appended flags as the second return value. It does not check
@@ -175,50 +198,67 @@ func hasShortNoOptDefVal(name string, fs *flags.FlagSet) bool {
 // streams, loading the plugin information, and parsing the command-line
 // arguments.
 func initialize(ctx context.Context) (*runInfo, error) {
-	strictErr := &strictError{
-		errs: nil,
-	}
+	// initErr is an error that prevents the run. It is returned only after
+	// the command is resolved so that the "doctor" command can still be run to
+	// report the problem. Until then, the defaults are used in place of
+	// the failed parts.
+	var initErr error
 
+	stop := timing.Start("parse config")
 	cfg, err := initConfig(ctx)
+
+	stop()
+
 	if err != nil {
 		var fileErr *config.FileError
 		if !errors.As(err, &fileErr) {
+			initErr = err
+			cfg = config.DefaultConfig()
+		}
+	}
+
+	if err = initOut(ctx, cfg); err != nil {
+		if initErr == nil {
+			initErr = err
+		}
+
+		cfg.Logging.Enabled = false
+
+		if err = initOut(ctx, cfg); err != nil {
 			return nil, &ExitError{
 				Code: 1,
 				err:  err,
 			}
 		}
-
-		strictErr.errs = append(strictErr.errs, fileErr)
 	}
 
-	if err = initOut(ctx, cfg); err != nil {
-		return nil, &ExitError{
-			Code: 1,
-			err:  err,
+	slog.InfoContext(ctx, "executing Reginald", "version", version.Version(), "os", system.This())
+
+	if err = httpclient.Init(cfg.Network); err != nil {
+		if initErr == nil {
+			initErr = fmt.Errorf("failed to initialize the network config: %w", err)
 		}
 	}
 
-	slog.InfoContext(ctx, "executing Reginald", "version", version.Version(), "os", system.This())
-
 	var pathErrs plugin.PathErrors
 
+	stop = timing.Start("discover plugins")
 	store, err := initPlugins(ctx, cfg)
+
+	stop()
+
 	if err != nil {
 		if !errors.As(err, &pathErrs) {
-			return nil, &ExitError{
-				Code: 1,
-				err:  err,
+			if initErr == nil {
+				initErr = err
 			}
-		}
 
-		strictErr.errs = append(strictErr.errs, err)
-	}
-
-	if len(strictErr.errs) > 0 && cfg.Strict {
-		return nil, &ExitError{
-			Code: 1,
-			err:  strictErr,
+			if store, err = plugin.NewStore(ctx, builtin.Manifests(), cfg.Directory, nil, ""); err != nil {
+				return nil, &ExitError{
+					Code: 1,
+					err:  err,
+				}
+			}
 		}
 	}
 
@@ -226,62 +266,120 @@ func initialize(ctx context.Context) (*runInfo, error) {
 		cmd:     nil,
 		cfg:     cfg,
 		store:   store,
+		diags:   nil,
 		flagSet: nil,
 		args:    nil,
+		argv:    nil,
+		explain: "",
 		help:    false,
+		helpAll: false,
+		json:    false,
+		builtin: nil,
 		version: false,
 	}
 
 	if err = parseArgs(ctx, info); err != nil {
+		if initErr != nil {
+			err = initErr
+		}
+
 		return nil, &ExitError{
 			Code: 1,
 			err:  err,
 		}
 	}
 
-	// Best to skip printing if "--help" or "--version" was used.
-	if info.help || info.version {
+	if info.builtin == nil || !info.builtin.diagnose {
+		if initErr != nil {
+			return nil, &ExitError{
+				Code: 1,
+				err:  initErr,
+			}
+		}
+	}
+
+	// Best to skip printing if "--help", "--version", or "--explain" was used.
+	// The built-in commands, like "doctor", check the config and the plugins
+	// themselves if they need to.
+	if info.help || info.version || info.explain != "" || info.builtin != nil {
 		return info, nil
 	}
 
+	// In interactive mode, the user is asked whether to continue instead of
+	// only warning about the missing files.
+	ask := cfg.Interactive && info.diags.Severity(diag.MissingConfig) == diag.SeverityWarn
+
 	switch {
 	case cfg.HasFile():
 		// no-op
-	case !cfg.Interactive:
-		terminal.Warnln("No config file was found")
+	case !ask:
+		info.diags.Report(ctx, diag.MissingConfig, "No config file was found")
 	case !terminal.Confirm(ctx, "No config file was found. Continue?", true):
 		return nil, &SuccessError{}
 	}
 
+	ask = cfg.Interactive && info.diags.Severity(diag.MissingPluginDir) == diag.SeverityWarn
+
 	switch {
 	case pathErrs == nil:
 		// no-op
-	case !cfg.Interactive:
-		terminal.Warnln("Plugin directory not found")
+	case !ask:
+		info.diags.Report(ctx, diag.MissingPluginDir, "Plugin directory not found: "+strings.Join(pathErrs.Paths(), ", "))
 	case !terminal.Confirm(ctx, "Plugin directory not found. Continue?", true):
 		return nil, &SuccessError{}
 	}
 
+	if err = checkDiagnostics(info); err != nil {
+		return nil, err
+	}
+
+	stop = timing.Start("verify plugins")
+	err = verifyLock(ctx, info)
+
+	stop()
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := func(class diag.Class, msg string) {
+		info.diags.Report(ctx, class, msg)
+	}
 	opts := config.ApplyOptions{
 		Dir:     info.cfg.Directory,
 		FlagSet: info.flagSet,
 		Store:   info.store,
+		Report:  report,
 	}
-	if err = config.ApplyPlugins(ctx, info.cfg, opts); err != nil {
+	stop = timing.Start("parse plugin config")
+	err = config.ApplyPlugins(ctx, info.cfg, opts)
+
+	stop()
+
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	info.cfg.RawPlugins = nil
 
+	if err = info.store.SetConfigs(info.cfg.Plugins); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
 	taskOpts := config.TaskApplyOptions{
 		Dir:      info.cfg.Directory,
 		Store:    info.store,
 		Defaults: info.cfg.Defaults,
+		Report:   report,
 	}
 
 	var taskCfgs []plugin.TaskConfig
 
+	stop = timing.Start("parse task config")
 	taskCfgs, err = config.ApplyTasks(ctx, info.cfg.RawTasks, taskOpts)
+
+	stop()
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
@@ -289,6 +387,10 @@ func initialize(ctx context.Context) (*runInfo, error) {
 	info.cfg.Tasks = taskCfgs
 	info.cfg.RawTasks = nil
 
+	if err = checkDiagnostics(info); err != nil {
+		return nil, err
+	}
+
 	slog.DebugContext(ctx, "config parsed", "file", info.cfg.File(), "cfg", info.cfg, "args", info.args)
 
 	return info, nil
@@ -303,7 +405,8 @@ func initConfig(ctx context.Context) (*config.Config, error) {
 	// Ignore errors for now as we want to get all of the flags from plugins
 	// first.
 	err := flagSet.Parse(os.Args[1:])
-	if err != nil && !strings.Contains(err.Error(), "unknown flag") {
+	if err != nil && !strings.Contains(err.Error(), "unknown flag") &&
+		!strings.Contains(err.Error(), "unknown shorthand flag") {
 		// We aren't aware of all of the flags yet, so if the error is for
 		// an unknown flag, ignore it. Unfortunately pflag doesn't offer any
 		// actual error type that could be checked for.
@@ -326,9 +429,58 @@ func initConfig(ctx context.Context) (*config.Config, error) {
 	return cfg, nil
 }
 
+// applyCI enables CI mode in cfg if it is set or a CI environment is detected.
+// In CI mode, the interactive mode is disabled, the default answers are used
+// for the prompts unless yes is assumed, and the colors are disabled unless
+// the user has set the color mode. The environment is not detected if
+// the events socket is used as then the run is driven through the socket.
+func applyCI(cfg *config.Config) {
+	if !cfg.CI && (cfg.EventsSocket != "" || !terminal.DetectCI()) {
+		return
+	}
+
+	cfg.CI = true
+	cfg.Interactive = false
+
+	if !cfg.AssumeYes {
+		cfg.AssumeDefaults = true
+	}
+
+	if cfg.Color == terminal.ColorAuto {
+		cfg.Color = terminal.ColorNever
+	}
+}
+
 // initOut initializes the output streams and the logging for the program.
 func initOut(ctx context.Context, cfg *config.Config) error {
-	terminal.Default().Init(cfg.Quiet, cfg.Verbose, cfg.Interactive, cfg.Color)
+	applyCI(cfg)
+
+	colors := cfg.Color
+
+	// The porcelain output replaces the regular user interface so that it is
+	// never mixed with the machine-readable lines.
+	if cfg.Porcelain {
+		colors = terminal.ColorNever
+	}
+
+	terminal.Default().Init(
+		cfg.Quiet || cfg.Porcelain,
+		cfg.Verbose,
+		cfg.Interactive && !cfg.Porcelain,
+		colors,
+		cfg.Theme,
+	)
+
+	switch {
+	case cfg.AssumeYes:
+		terminal.Default().SetAssume(terminal.AssumeYes)
+	case cfg.AssumeDefaults:
+		terminal.Default().SetAssume(terminal.AssumeDefaults)
+	}
+
+	terminal.Default().SetCI(cfg.CI)
+	terminal.Default().SetProgress(!cfg.CI)
+	terminal.Default().SetPager(cfg.Pager && !cfg.CI && !cfg.Porcelain)
 
 	if err := logger.Init(cfg.Logging, cfg.Debug); err != nil {
 		return fmt.Errorf("failed to initialize logging: %w", err)
@@ -344,7 +496,14 @@ func initOut(ctx context.Context, cfg *config.Config) error {
 func initPlugins(ctx context.Context, cfg *config.Config) (*plugin.Store, error) {
 	var pathErrs plugin.PathErrors
 
-	store, err := plugin.NewStore(ctx, builtin.Manifests(), cfg.Directory, cfg.PluginPaths)
+	cacheFile, err := config.PluginCacheFile()
+	if err != nil {
+		slog.DebugContext(ctx, "not caching plugin manifests", "err", err)
+
+		cacheFile = ""
+	}
+
+	store, err := plugin.NewStore(ctx, builtin.Manifests(), cfg.Directory, cfg.PluginPaths, cacheFile)
 	if err != nil {
 		if !errors.As(err, &pathErrs) {
 			return nil, fmt.Errorf("failed to search for plugins: %w", err)
@@ -362,6 +521,66 @@ func initPlugins(ctx context.Context, cfg *config.Config) (*plugin.Store, error)
 	return store, nil
 }
 
+// checkDiagnostics returns the diagnostics of the run that have the error
+// severity as an error that stops the run.
+func checkDiagnostics(info *runInfo) error {
+	err := info.diags.Err()
+	if err == nil {
+		return nil
+	}
+
+	return &ExitError{
+		Code: 1,
+		err:  errhint.Wrap(err, "lower the severities of the problems in the \"strict\" table of the config to allow them"),
+	}
+}
+
+// verifyLock checks that the installed plugins match the lock file. The check
+// is skipped for the "plugin" commands as they are used to fix the installed
+// plugins and the lock file. The external plugins that are not in the lock
+// file are reported as unverified.
+func verifyLock(ctx context.Context, info *runInfo) error {
+	if info.cmd != nil && info.cmd.Names()[0] == "plugin" {
+		return nil
+	}
+
+	lock, err := registry.ReadLock(info.cfg.LockFile())
+	if err != nil {
+		return fmt.Errorf("failed to read the lock file: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(lock.Plugins)) {
+		locked := lock.Plugins[name]
+
+		dir, ok := info.store.Dir(name)
+		if !ok {
+			return errhint.Wrap(
+				fmt.Errorf("%w: %s@%s is not installed", registry.ErrLockMismatch, name, locked.Version),
+				"run \"reginald plugin install\" to install the locked plugins",
+			)
+		}
+
+		if err = lock.Verify(name, dir); err != nil {
+			return errhint.Wrapf(
+				err,
+				"run \"reginald plugin update %s@%s\" to install the locked version",
+				name,
+				locked.Version,
+			)
+		}
+	}
+
+	for p := range info.store.VisitPlugins() {
+		name := p.Manifest().Name
+		if _, ok := lock.Plugins[name]; p.External() && !ok {
+			msg := fmt.Sprintf("Plugin %q is not in the lock file so its files are not verified", name)
+			info.diags.Report(ctx, diag.UnverifiedPlugin, msg)
+		}
+	}
+
+	return nil
+}
+
 // newFlagSet creates a [flags.FlagSet] that contains the command-line flags for
 // the root command of the program. The function panics on errors.
 func newFlagSet() *flags.FlagSet {
@@ -370,12 +589,20 @@ func newFlagSet() *flags.FlagSet {
 
 	flagSet.Bool("version", false, "print the version information and exit", "")
 	flagSet.BoolP("help", "h", false, "show the help message and exit", "")
+	flagSet.Bool("all", false, "show the advanced and hidden options in the help message", "")
+	flagSet.Bool("json", false, "print the output of the commands and \"--explain\" as JSON", "")
+	flagSet.String(
+		"explain",
+		"",
+		"show where the value for the config `<key>` or the options of the task \"tasks.<id>\" come from and exit",
+		"",
+	)
 
 	flagSet.StringP(
 		"config",
 		"c",
 		"",
-		"use `<path>` as the configuration file instead of resolving it from the standard locations",
+		"use `<path>` as the configuration file instead of resolving it from the standard locations; the path may also be an HTTPS URL or a Git URL starting with \"git+\"", //nolint:lll
 		"",
 	)
 	flagSet.PathP(
@@ -385,6 +612,23 @@ func newFlagSet() *flags.FlagSet {
 		"use `<path>` as the \"dotfiles\" directory so that Reginald looks for the config file and the files for linking from there", //nolint:lll
 		"",
 	)
+
+	detectName := config.FlagName("DetectDirectory")
+	noDetectName := config.InvertedFlagName("DetectDirectory")
+
+	flagSet.Bool(detectName, defaults.DetectDirectory, "detect the \"dotfiles\" directory from the parent directories", "")
+	flagSet.Bool(
+		noDetectName,
+		!defaults.DetectDirectory,
+		"do not look for the \"dotfiles\" directory from the parent directories of the working directory",
+		"",
+	)
+	flagSet.MarkMutuallyExclusive(detectName, noDetectName)
+
+	if err := flagSet.MarkHidden(detectName); err != nil {
+		panic(fmt.Sprintf("failed to mark --%s hidden: %v", detectName, err))
+	}
+
 	flagSet.PathSliceP(
 		config.FlagName("PluginPaths"),
 		"p",
@@ -393,6 +637,27 @@ func newFlagSet() *flags.FlagSet {
 		"",
 	)
 
+	flagSet.StringSlice(
+		config.FlagName("PluginIndexes"),
+		defaults.PluginIndexes,
+		"search and install plugins from the plugin index at `<url>`",
+		"",
+	)
+
+	flagSet.Path(
+		config.FlagName("BackupDir"),
+		defaults.BackupDir,
+		"store the backups of the files that the tasks replace in `<path>`",
+		"",
+	)
+
+	flagSet.Path(
+		config.FlagName("KeyFile"),
+		defaults.KeyFile,
+		"read the key for the encrypted config values from `<path>`",
+		"",
+	)
+
 	verboseName := config.FlagName("Verbose")
 	quietName := config.FlagName("Quiet")
 
@@ -401,9 +666,103 @@ func newFlagSet() *flags.FlagSet {
 	flagSet.MarkMutuallyExclusive(quietName, verboseName)
 
 	flagSet.BoolP(config.FlagName("Interactive"), "i", defaults.Interactive, "run in interactive mode", "")
-	flagSet.Bool(config.FlagName("Strict"), defaults.Strict, "enable strict mode", "")
+	flagSet.Bool(config.FlagName("Strict.Enabled"), defaults.Strict.Enabled, "enable strict mode", "")
 	flagSet.MarkMutuallyExclusive("interactive", "strict")
 
+	assumeYesName := config.FlagName("AssumeYes")
+	assumeDefaultsName := config.FlagName("AssumeDefaults")
+
+	flagSet.BoolP(
+		assumeYesName,
+		"y",
+		defaults.AssumeYes,
+		"answer yes to all confirmations and use the defaults for the other prompts",
+		"",
+	)
+	flagSet.Bool(
+		assumeDefaultsName,
+		defaults.AssumeDefaults,
+		"use the default answers for all prompts instead of asking",
+		"",
+	)
+	flagSet.MarkMutuallyExclusive(assumeYesName, assumeDefaultsName)
+
+	porcelainName := config.FlagName("Porcelain")
+
+	flagSet.Bool(
+		porcelainName,
+		defaults.Porcelain,
+		"print one stable, tab-separated line per task event instead of the regular output",
+		"",
+	)
+	flagSet.MarkMutuallyExclusive(porcelainName, "interactive")
+
+	ciName := config.FlagName("CI")
+
+	flagSet.Bool(
+		ciName,
+		defaults.CI,
+		"run without prompts, colors, or progress messages as in CI environments, which are also detected automatically",
+		"",
+	)
+	flagSet.MarkMutuallyExclusive(ciName, "interactive")
+
+	flagSet.BoolP(
+		config.FlagName("DryRun"),
+		"n",
+		defaults.DryRun,
+		"show the changes that the tasks would make without applying them",
+		"",
+	)
+	flagSet.String(
+		config.FlagName("OnFailure"),
+		string(defaults.OnFailure),
+		"set the `<policy>` for the failed tasks: \"stop\", \"continue\", or \"skip-dependents\"",
+		"",
+	)
+
+	lockName := config.FlagName("Lock")
+	noLockName := config.InvertedFlagName("Lock")
+
+	flagSet.Bool(lockName, defaults.Lock, "lock the run so that other runs cannot change the system at the same time", "")
+	flagSet.Bool(noLockName, !defaults.Lock, "do not acquire the lock file for the run", "")
+	flagSet.MarkMutuallyExclusive(lockName, noLockName)
+
+	if err := flagSet.MarkHidden(lockName); err != nil {
+		panic(fmt.Sprintf("failed to mark --%s hidden: %v", lockName, err))
+	}
+
+	flagSet.Bool(
+		config.FlagName("Wait"),
+		defaults.Wait,
+		"wait for the lock file to be released if another run holds it instead of failing",
+		"",
+	)
+	flagSet.Bool(config.FlagName("Timings"), defaults.Timings, "print how long each phase of the run took", "")
+
+	pagerName := config.FlagName("Pager")
+	noPagerName := config.InvertedFlagName("Pager")
+
+	flagSet.Bool(pagerName, defaults.Pager, "show the long output in a pager when the output is a terminal", "")
+	flagSet.Bool(noPagerName, !defaults.Pager, "do not show the long output in a pager", "")
+	flagSet.MarkMutuallyExclusive(pagerName, noPagerName)
+
+	if err := flagSet.MarkHidden(pagerName); err != nil {
+		panic(fmt.Sprintf("failed to mark --%s hidden: %v", pagerName, err))
+	}
+	flagSet.Path(
+		config.FlagName("EventsSocket"),
+		defaults.EventsSocket,
+		"write the events of the run as JSON lines to the Unix socket at `<path>` and read the answers to the prompts from it",
+		"",
+	)
+	flagSet.Bool(
+		config.FlagName("Logging.TraceRPC"),
+		defaults.Logging.TraceRPC,
+		"write the messages sent to and received from each plugin to a trace file next to the log file",
+		"",
+	)
+
 	colorMode := defaults.Color
 
 	flagSet.Var(&colorMode, config.FlagName("Color"), "set the `<mode>` for color output", "")
@@ -428,6 +787,23 @@ func newFlagSet() *flags.FlagSet {
 		panic(fmt.Sprintf("failed to mark --%s hidden: %v", debugFlag, err))
 	}
 
+	flagSet.SetGroup(
+		flags.GroupAdvanced,
+		detectName,
+		noDetectName,
+		config.FlagName("PluginPaths"),
+		config.FlagName("PluginIndexes"),
+		config.FlagName("BackupDir"),
+		config.FlagName("KeyFile"),
+		lockName,
+		noLockName,
+		config.FlagName("Wait"),
+		config.FlagName("Timings"),
+		config.FlagName("EventsSocket"),
+		config.FlagName("Logging.TraceRPC"),
+		debugFlag,
+	)
+
 	return flagSet
 }
 
@@ -466,15 +842,26 @@ func parseArgs(ctx context.Context, info *runInfo) error {
 
 	slog.Log(ctx, slog.Level(logger.LevelTrace), "flags parsed", "args", info.args)
 
-	info.flagSet = flagSet
+	// The config was parsed using the original command-line arguments so
+	// the flags from the command line of an alias must be applied again.
+	if !slices.Equal(info.argv, os.Args) {
+		opts := config.ApplyOptions{
+			Dir:     info.cfg.Directory,
+			FlagSet: flagSet,
+			Store:   nil,
+		}
+		if err := config.Apply(ctx, info.cfg, opts); err != nil {
+			return fmt.Errorf("failed to apply the flags from the alias: %w", err)
+		}
 
-	if err := validateArgs(info); err != nil {
-		return err
+		if err := initOut(ctx, info.cfg); err != nil {
+			return err
+		}
 	}
 
-	if err := config.Validate(info.cfg, info.store); err != nil {
-		return fmt.Errorf("%w", err)
-	}
+	info.flagSet = flagSet
+	info.diags = diag.NewCollector(info.cfg.Strict)
+	diag.Set(info.diags)
 
 	var err error
 
@@ -482,16 +869,147 @@ func parseArgs(ctx context.Context, info *runInfo) error {
 		return fmt.Errorf("failed to get value for --help: %w", err)
 	}
 
+	if info.helpAll, err = flagSet.GetBool("all"); err != nil {
+		return fmt.Errorf("failed to get value for --all: %w", err)
+	}
+
 	if info.version, err = flagSet.GetBool("version"); err != nil {
 		return fmt.Errorf("failed to get value for --version: %w", err)
 	}
 
-	if info.cmd == nil && !info.version {
+	if info.explain, err = flagSet.GetString("explain"); err != nil {
+		return fmt.Errorf("failed to get value for --explain: %w", err)
+	}
+
+	if info.json, err = flagSet.GetBool("json"); err != nil {
+		return fmt.Errorf("failed to get value for --json: %w", err)
+	}
+
+	// The help and the version of a command and the explanations can be
+	// printed without giving the arguments the command requires.
+	if !info.help && !info.version && info.explain == "" {
+		if err = validateArgs(info); err != nil {
+			return err
+		}
+	}
+
+	// The config is not validated for the explanations as they are used for
+	// finding out why the config is invalid.
+	if info.explain == "" {
+		report := func(class diag.Class, msg string) {
+			info.diags.Report(ctx, class, msg)
+		}
+
+		if err = config.Validate(info.cfg, info.store, report); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	shutdownTimeout := time.Duration(info.cfg.ShutdownTimeout) * time.Second
+
+	for p := range info.store.VisitPlugins() {
+		if err = info.store.SetShutdownTimeout(p.Manifest().Name, shutdownTimeout); err != nil {
+			return fmt.Errorf("failed to set shutdown timeout for plugin %q: %w", p.Manifest().Name, err)
+		}
+	}
+
+	for name, opts := range info.cfg.PluginOptions {
+		if opts.ShutdownTimeout != nil {
+			timeout := time.Duration(*opts.ShutdownTimeout) * time.Second
+			if err = info.store.SetShutdownTimeout(name, timeout); err != nil {
+				return fmt.Errorf("failed to set shutdown timeout for plugin %q: %w", name, err)
+			}
+		}
+
+		if opts.LogLevel != nil {
+			if err = info.store.SetLogLevel(name, opts.LogLevel.Level()); err != nil {
+				return fmt.Errorf("failed to set log level for plugin %q: %w", name, err)
+			}
+		}
+
+		if !opts.Sandbox.IsZero() {
+			if err = setSandbox(info, name, opts.Sandbox); err != nil {
+				return err
+			}
+		}
+
+		if !opts.Output.IsZero() {
+			if err = info.store.SetOutputLimits(name, opts.Output); err != nil {
+				return fmt.Errorf("failed to set output limits for plugin %q: %w", name, err)
+			}
+		}
+	}
+
+	info.store.SetMaxProtocolErrors(info.cfg.MaxProtocolErrors)
+	info.store.SetMaxMessageSize(info.cfg.MaxMessageSize)
+
+	dataDir, err := config.PluginDataDir()
+	if err != nil {
+		return fmt.Errorf("failed to resolve the plugin data directory: %w", err)
+	}
+
+	info.store.SetDataDir(dataDir)
+
+	cacheDir, err := config.DownloadCacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to resolve the download cache directory: %w", err)
+	}
+
+	info.store.SetDownloader(registry.NewDownloader(dlcache.New(cacheDir), info.cfg.LockFile()))
+
+	if info.cfg.Logging.TraceRPC {
+		dir, err := logger.TraceDir(info.cfg.Logging)
+		if err != nil {
+			return fmt.Errorf("failed to resolve the RPC trace directory: %w", err)
+		}
+
+		slog.InfoContext(ctx, "tracing plugin messages", "dir", dir)
+		info.store.TraceRPC(dir)
+	}
+
+	if info.cmd == nil && !info.version && info.explain == "" {
 		info.help = true
 	}
 
-	if info.cmd != nil && info.cmd.Name == "version" {
-		info.version = true
+	info.builtin = lookupBuiltin(info.cmd)
+
+	jsonCmd := (info.cmd != nil && info.cmd.Plugin.External()) || (info.builtin != nil && info.builtin.json)
+
+	if info.json && !info.version && info.explain == "" && !jsonCmd {
+		hint := fmt.Sprintf(
+			"use --json with %s, \"--version\", \"--explain\", or the commands of external plugins",
+			strings.Join(jsonBuiltins(), ", "),
+		)
+
+		if info.help || info.cmd == nil {
+			return errhint.Wrap(fmt.Errorf("%w for the help message", errNoJSON), hint)
+		}
+
+		return errhint.Wrap(fmt.Errorf("%w for command %q", errNoJSON, strings.Join(info.cmd.Names(), " ")), hint)
+	}
+
+	return nil
+}
+
+// setSandbox sets the sandbox for the plugin with the given name or domain. A
+// relative working directory of the sandbox is resolved against the directory
+// of the config.
+func setSandbox(info *runInfo, name string, sandbox plugin.Sandbox) error {
+	if sandbox.Dir != "" {
+		dir, err := sandbox.Dir.Expand()
+		if err != nil {
+			return fmt.Errorf("failed to expand sandbox directory for plugin %q: %w", name, err)
+		}
+
+		if !dir.IsAbs() {
+			dir = info.cfg.Directory.Join(string(dir))
+		}
+
+		sandbox.Dir = dir.Clean()
+	}
+
+	if err := info.store.SetSandbox(name, sandbox); err != nil {
+		return fmt.Errorf("failed to set sandbox for plugin %q: %w", name, err)
 	}
 
 	return nil
@@ -512,6 +1030,10 @@ func parseCommands(flagSet *flags.FlagSet, info *runInfo) error {
 		return nil
 	}
 
+	if err := expandAlias(flagSet, info); err != nil {
+		return err
+	}
+
 	flagsFound := []string{}
 	info.args = info.args[1:]
 
@@ -541,12 +1063,64 @@ func parseCommands(flagSet *flags.FlagSet, info *runInfo) error {
 	return nil
 }
 
+// expandAlias replaces the command given on the command line with the command
+// line of the alias if the command is not a known command but an alias
+// defined in the config. The expanded arguments are stored in info so that
+// the help and the version can be resolved from them.
+func expandAlias(flagSet *flags.FlagSet, info *runInfo) error {
+	info.argv = info.args
+
+	// The first non-flag argument is the command. It is found by collecting
+	// the flags from longer and longer prefixes of the arguments so that
+	// the values of the flags are not mistaken for the command.
+	i := -1
+
+	for j := 2; j <= len(info.args); j++ {
+		if rest, _ := collectFlags(flagSet, info.args[1:j], nil); len(rest) > 0 {
+			i = j - 1
+
+			break
+		}
+	}
+
+	if i == -1 || info.store.Command(nil, info.args[i]) != nil {
+		return nil
+	}
+
+	// The aliases are validated before the expansion as an alias that has
+	// the same name as a command would otherwise be expanded when it is used
+	// in the command line of another alias.
+	if err := info.cfg.Aliases.Validate(info.store); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	args, ok, err := info.cfg.Aliases.Expand(info.args, i)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	slog.Debug("expanded command alias", "alias", info.args[i], "args", args)
+
+	info.args = args
+	info.argv = args
+
+	return nil
+}
+
 // validateArgs validates the command-line arguments according to
 // the specifications given by the plugins.
 func validateArgs(info *runInfo) error {
 	if info.cmd == nil {
 		if len(info.args) > 0 {
-			return fmt.Errorf("%w: unknown command: %q", errInvalidArgs, info.args[0])
+			return errhint.Wrapf(
+				fmt.Errorf("%w: unknown command: %q", errInvalidArgs, info.args[0]),
+				"run \"%s --help\" to see the available commands",
+				Name,
+			)
 		}
 
 		return nil
@@ -0,0 +1,263 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/plugin/builtin"
+	"github.com/reginald-project/reginald/internal/plugin/runtimes"
+	"github.com/reginald-project/reginald/internal/timing"
+)
+
+// errPanic is returned when running a command panics.
+var errPanic = errors.New("command panicked")
+
+// categoryExitCodes contains the exit codes for the categories of the errors
+// that the plugins report. The codes follow the conventions of sysexits.h.
+//
+//nolint:gochecknoglobals // used like constant
+var categoryExitCodes = map[string]int{
+	plugin.CategoryUser:        64, // EX_USAGE
+	plugin.CategoryEnvironment: 69, // EX_UNAVAILABLE
+	plugin.CategoryInternal:    70, // EX_SOFTWARE
+	plugin.CategoryConfig:      78, // EX_CONFIG
+}
+
+// A handler runs the command of the program run.
+type handler func(ctx context.Context, info *runInfo) error
+
+// A middleware wraps a handler to add behavior around running the command,
+// like logging or setting up the plugins.
+type middleware func(next handler) handler
+
+// chain wraps h with the given middlewares. The first middleware is
+// the outermost one, so it is the first to run and the last to return.
+func chain(h handler, mws ...middleware) handler {
+	for _, mw := range slices.Backward(mws) {
+		h = mw(h)
+	}
+
+	return h
+}
+
+// dispatch returns the handler for the run described by info. The "--help",
+// "--version", and "--explain" flags take precedence over the command.
+func dispatch(info *runInfo) handler {
+	switch {
+	case info.help:
+		return chain(
+			func(_ context.Context, info *runInfo) error {
+				return runHelp(info.cmd, info.store, info.argv, info.helpAll)
+			},
+			withPager,
+		)
+	case info.version:
+		return runVersion
+	case info.explain != "":
+		return chain(
+			func(_ context.Context, info *runInfo) error {
+				return runExplain(info)
+			},
+			withPager,
+		)
+	case info.builtin != nil:
+		return info.builtin.run
+	default:
+		return chain(run, withPlugins, withEvents, withDiagnostics, withKeepalive)
+	}
+}
+
+// exitError returns err as an [ExitError]. The exit code of an ExitError in
+// the chain of err is kept. Otherwise, the exit code is chosen by the category
+// of the error response from a plugin in the chain, or it is 1.
+func exitError(err error) *ExitError {
+	code := 1
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.Code
+	} else if c, ok := categoryExitCodes[plugin.ErrorCategory(err)]; ok {
+		code = c
+	}
+
+	return &ExitError{
+		Code: code,
+		err:  err,
+	}
+}
+
+// withExitCode converts the errors from running the command to [ExitError]s so
+// that the program exits with the correct code.
+func withExitCode(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		if err := next(ctx, info); err != nil {
+			return exitError(err)
+		}
+
+		return nil
+	}
+}
+
+// withTimings records the duration of running the command and reports
+// the timings of the program run that was started at start.
+func withTimings(start time.Time) middleware {
+	return func(next handler) handler {
+		return func(ctx context.Context, info *runInfo) error {
+			defer reportTimings(ctx, info.cfg, start)
+
+			stop := timing.Start("run command")
+			defer stop()
+
+			return next(ctx, info)
+		}
+	}
+}
+
+// withLogging logs the start and the end of running the command.
+func withLogging(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		var name string
+		if info.cmd != nil {
+			name = strings.Join(info.cmd.Names(), " ")
+		}
+
+		slog.InfoContext(ctx, "running command", "cmd", name, "help", info.help, "explain", info.explain)
+
+		start := time.Now()
+
+		if err := next(ctx, info); err != nil {
+			slog.ErrorContext(ctx, "command failed", "cmd", name, "duration", time.Since(start), "err", err)
+
+			return err
+		}
+
+		slog.InfoContext(ctx, "command finished", "cmd", name, "duration", time.Since(start))
+
+		return nil
+	}
+}
+
+// withRecover converts a panic in running the command to an error so that
+// the deferred cleanup of the run, like shutting down the plugins, is done
+// before the program exits. The stack trace of the panic is logged.
+func withRecover(next handler) handler {
+	return func(ctx context.Context, info *runInfo) (err error) {
+		defer func() {
+			//revive:disable-next-line:defer This is a deferred function.
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			slog.ErrorContext(ctx, "command panicked", "panic", r, "stack", string(debug.Stack()))
+
+			err = errhint.Wrap(
+				fmt.Errorf("%w: %v", errPanic, r),
+				"this is most likely a bug, please report it at https://github.com/reginald-project/reginald/issues",
+			)
+		}()
+
+		return next(ctx, info)
+	}
+}
+
+// withPager directs the output of the command to a pager. The output is not
+// paged if it is printed as JSON.
+func withPager(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		if !info.json {
+			defer page(ctx)()
+		}
+
+		return next(ctx, info)
+	}
+}
+
+// withPlugins resolves the runtimes of the plugins and initializes them before
+// running the command.
+func withPlugins(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		if err := runtimes.Resolve(ctx, info.store, info.cfg); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := info.store.Init(ctx, builtin.Service(info.cfg), info.cfg.Tasks); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return next(ctx, info)
+	}
+}
+
+// withEvents connects to the events socket for the duration of running
+// the command if it is set in the config.
+func withEvents(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		closeEvents, err := openEvents(ctx, info.cfg)
+		if err != nil {
+			return err
+		}
+		defer closeEvents()
+
+		return next(ctx, info)
+	}
+}
+
+// withDiagnostics checks the diagnostics of the run after the command has
+// succeeded and prints the summary of the warnings. The summary is printed
+// after the inner middlewares have returned so that it is the last output of
+// the run.
+func withDiagnostics(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		defer info.diags.PrintSummary()
+
+		if err := next(ctx, info); err != nil {
+			return err
+		}
+
+		return checkDiagnostics(info)
+	}
+}
+
+// withKeepalive keeps the plugins alive while the command runs and shuts them
+// down after it.
+func withKeepalive(next handler) handler {
+	return func(ctx context.Context, info *runInfo) error {
+		keepaliveCtx, stopKeepalive := context.WithCancel(ctx)
+
+		info.store.Keepalive(keepaliveCtx, time.Duration(info.cfg.PingInterval)*time.Second, info.cfg.RestartPlugins)
+
+		defer func() {
+			stopKeepalive()
+
+			if err := info.store.ShutdownAll(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Error when shutting down plugins: %v\n", err)
+			}
+		}()
+
+		return next(ctx, info)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+func TestExitError(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("test error")
+
+	pluginErr := func(category string) error {
+		return &plugin.Error{ //nolint:exhaustruct // only the category is needed
+			Err:      &api.Error{Code: api.CodeCommandError, Message: "task error", Data: nil},
+			Category: category,
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Plain", errTest, 1},
+		{"ExitError", &ExitError{err: errTest, Code: 3}, 3},
+		{"WrappedExitError", fmt.Errorf("run failed: %w", &ExitError{err: errTest, Code: 4}), 4},
+		{"ConfigCategory", pluginErr(plugin.CategoryConfig), 78},
+		{"UserCategory", fmt.Errorf("task failed: %w", pluginErr(plugin.CategoryUser)), 64},
+		{"EnvironmentCategory", pluginErr(plugin.CategoryEnvironment), 69},
+		{"InternalCategory", pluginErr(plugin.CategoryInternal), 70},
+		{"UnknownCategory", pluginErr(""), 1},
+		{"ExitErrorOverCategory", &ExitError{err: pluginErr(plugin.CategoryConfig), Code: 2}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := exitError(tt.err)
+			if got.Code != tt.want {
+				t.Errorf("exitError(%v).Code = %d, want %d", tt.err, got.Code, tt.want)
+			}
+
+			if !errors.Is(got, tt.err) {
+				t.Errorf("exitError(%v) does not wrap the error", tt.err)
+			}
+		})
+	}
+}
+
+func TestWithRecover(t *testing.T) {
+	t.Parallel()
+
+	h := chain(
+		func(context.Context, *runInfo) error {
+			panic("boom")
+		},
+		withExitCode,
+		withRecover,
+	)
+
+	err := h(t.Context(), nil)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("handler error = %v (%T), want *ExitError", err, err)
+	}
+
+	if exitErr.Code != 1 {
+		t.Errorf("ExitError.Code = %d, want 1", exitErr.Code)
+	}
+
+	if !errors.Is(err, errPanic) {
+		t.Errorf("handler error = %v, want %v", err, errPanic)
+	}
+
+	if errhint.Hint(err) == "" {
+		t.Error("handler error has no hint")
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+
+	mw := func(name string) middleware {
+		return func(next handler) handler {
+			return func(ctx context.Context, info *runInfo) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, info)
+				calls = append(calls, name+" after")
+
+				return err
+			}
+		}
+	}
+
+	h := chain(
+		func(context.Context, *runInfo) error {
+			calls = append(calls, "handler")
+
+			return nil
+		},
+		mw("first"),
+		mw("second"),
+	)
+
+	if err := h(t.Context(), nil); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+
+	want := []string{"first before", "second before", "handler", "second after", "first after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// jsonVersion is the version of the JSON output. It is incremented when
+// the envelope or the data of a command changes in an incompatible way.
+const jsonVersion = 1
+
+// errNoJSON is returned when "--json" is used with a command that does not have
+// JSON output.
+var errNoJSON = errors.New("JSON output is not supported")
+
+// A jsonEnvelope is the document that the informational commands print to
+// standard output with "--json". The data of the command is wrapped in it so
+// that all of the commands have the same top-level structure.
+type jsonEnvelope struct {
+	Data     any               `json:"data"`
+	Command  string            `json:"command"`
+	Error    string            `json:"error,omitempty"`
+	Hint     string            `json:"hint,omitempty"`
+	Warnings []diag.Diagnostic `json:"warnings,omitempty"`
+	Version  int               `json:"version"`
+	OK       bool              `json:"ok"`
+}
+
+// An output prints the result of an informational command either as text for
+// the user or, with "--json", as a JSON document in the common envelope. The
+// commands only provide the data and the function for printing the text so
+// that they do not need to handle the formats themselves.
+type output struct {
+	diags   *diag.Collector // diagnostics of the run for the envelope
+	command string          // name of the command in the envelope
+	json    bool            // whether the result is printed as JSON
+}
+
+// newOutput returns the output for the given informational command of the run.
+func newOutput(info *runInfo, command string) output {
+	return output{
+		diags:   info.diags,
+		command: command,
+		json:    info.json,
+	}
+}
+
+// print prints the result of the command. In JSON mode, data and err are
+// written in the envelope. Otherwise, text is called to print the result. It
+// returns err so that the commands can return the result of print.
+func (o output) print(data any, err error, text func()) error {
+	if !o.json {
+		text()
+		terminal.Flush()
+
+		return err
+	}
+
+	env := jsonEnvelope{
+		Version:  jsonVersion,
+		Command:  o.command,
+		OK:       err == nil,
+		Data:     data,
+		Error:    "",
+		Hint:     "",
+		Warnings: o.diags.Warnings(),
+	}
+
+	if err != nil {
+		env.Error = err.Error()
+		env.Hint = errhint.Hint(err)
+	}
+
+	// The JSON document is written even in quiet mode as it is the only output
+	// of the command.
+	enc := json.NewEncoder(terminal.NewWriter(terminal.Default(), terminal.Stdout))
+	enc.SetIndent("", "  ")
+
+	if encErr := enc.Encode(env); encErr != nil {
+		return fmt.Errorf("failed to encode the output of %q: %w", o.command, encErr)
+	}
+
+	terminal.Flush()
+
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/timing"
+)
+
+// reportTimings logs the timed phases of the run and, if the user asked for
+// them with "--timings", prints a summary of them. The phases in the summary
+// are sorted by their duration so that the slowest ones are listed first.
+// The summary is written to standard error so that it does not mix with
+// the output of the command.
+func reportTimings(ctx context.Context, cfg *config.Config, start time.Time) {
+	total := time.Since(start)
+	phases := timing.Phases()
+
+	slog.DebugContext(ctx, "run timings", "total", total, "phases", phases)
+
+	if cfg == nil || !cfg.Timings {
+		return
+	}
+
+	slices.SortStableFunc(phases, func(a, b timing.Phase) int { return cmp.Compare(b.Duration, a.Duration) })
+
+	width := len("total")
+
+	for _, p := range phases {
+		width = max(width, len(p.Name))
+	}
+
+	terminal.PrintErrf("\nTimings:\n")
+
+	for _, p := range phases {
+		terminal.PrintErrf("  %-*s  %10s\n", width, p.Name, p.Duration.Round(time.Microsecond))
+	}
+
+	terminal.PrintErrf("  %-*s  %10s\n", width, "total", total.Round(time.Microsecond))
+	terminal.Flush()
+}
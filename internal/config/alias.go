@@ -0,0 +1,168 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// Errors returned when the command line of an alias cannot be split.
+var (
+	errEmptyAlias        = errors.New("empty command line")
+	errUnterminatedQuote = errors.New("unterminated quote")
+)
+
+// Aliases maps the names of the command aliases to the command lines that
+// they are expanded to, for example, "up" to "attend --dry-run".
+type Aliases map[string]string
+
+// Expand expands the alias in args[i] and returns the arguments with the alias
+// replaced by the words of its command line. The alias may refer to other
+// aliases as its first word. If args[i] is not an alias, args is returned as
+// is. The second return value reports whether an alias was expanded.
+func (a Aliases) Expand(args []string, i int) ([]string, bool, error) {
+	if i < 0 || i >= len(args) {
+		return args, false, nil
+	}
+
+	var seen []string
+
+	words := []string{args[i]}
+
+	for {
+		value, ok := a[words[0]]
+		if !ok {
+			break
+		}
+
+		if slices.Contains(seen, words[0]) {
+			return nil, false, fmt.Errorf(
+				"%w: alias loop: %s -> %s",
+				ErrInvalidConfig,
+				strings.Join(seen, " -> "),
+				words[0],
+			)
+		}
+
+		seen = append(seen, words[0])
+
+		expanded, err := splitAlias(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: alias %q: %w", ErrInvalidConfig, seen[len(seen)-1], err)
+		}
+
+		words = append(expanded, words[1:]...)
+	}
+
+	if len(seen) == 0 {
+		return args, false, nil
+	}
+
+	result := make([]string, 0, len(args)+len(words)-1)
+	result = append(result, args[:i]...)
+	result = append(result, words...)
+	result = append(result, args[i+1:]...)
+
+	return result, true, nil
+}
+
+// Validate checks that the names and the command lines of the aliases are
+// valid and that the aliases do not shadow the commands in store.
+func (a Aliases) Validate(store *plugin.Store) error {
+	var commands []string
+	for cmd := range store.CommandsFor(nil) {
+		commands = append(commands, cmd.Name)
+		commands = append(commands, cmd.Aliases...)
+	}
+
+	for name, value := range a {
+		if name == "" || strings.IndexFunc(name, unicode.IsSpace) != -1 || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("%w: invalid alias name %q", ErrInvalidConfig, name)
+		}
+
+		if slices.Contains(commands, name) {
+			return fmt.Errorf("%w: alias %q has the same name as a command", ErrInvalidConfig, name)
+		}
+
+		if _, err := splitAlias(value); err != nil {
+			return fmt.Errorf("%w: alias %q: %w", ErrInvalidConfig, name, err)
+		}
+	}
+
+	return nil
+}
+
+// splitAlias splits the command line of an alias into words. The words are
+// separated by whitespace, and the quotes can be used to include whitespace in
+// a word like in a shell. Within double quotes, a backslash escapes a double
+// quote or a backslash.
+func splitAlias(s string) ([]string, error) {
+	var (
+		words   []string
+		sb      strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+
+				inWord = false
+			}
+		default:
+			sb.WriteRune(r)
+
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("%w in %q", errUnterminatedQuote, s)
+	}
+
+	if inWord {
+		words = append(words, sb.String())
+	}
+
+	if len(words) == 0 {
+		return nil, errEmptyAlias
+	}
+
+	return words, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+func TestAliasesExpand(t *testing.T) {
+	t.Parallel()
+
+	aliases := config.Aliases{
+		"up":     "attend --tags packages",
+		"dry":    "up -n",
+		"quoted": `greet "hello world" 'it''s' "say \"hi\""`,
+		"hi":     "example greet",
+		"loop":   "loop2 x",
+		"loop2":  "loop",
+		"empty":  "  ",
+		"open":   `attend "unterminated`,
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		i       int
+		want    []string
+		wantOK  bool
+		wantErr bool
+	}{
+		{"Not alias", []string{"reginald", "attend"}, 1, []string{"reginald", "attend"}, false, false},
+		{"Simple", []string{"reginald", "up"}, 1, []string{"reginald", "attend", "--tags", "packages"}, true, false},
+		{
+			"Flags around",
+			[]string{"reginald", "-v", "up", "--dry-run"},
+			2,
+			[]string{"reginald", "-v", "attend", "--tags", "packages", "--dry-run"},
+			true,
+			false,
+		},
+		{"Chained", []string{"reginald", "dry"}, 1, []string{"reginald", "attend", "--tags", "packages", "-n"}, true, false},
+		{
+			"Quotes",
+			[]string{"reginald", "quoted"},
+			1,
+			[]string{"reginald", "greet", "hello world", "its", `say "hi"`},
+			true,
+			false,
+		},
+		{"Plugin command", []string{"reginald", "hi", "you"}, 1, []string{"reginald", "example", "greet", "you"}, true, false},
+		{"Out of range", []string{"reginald"}, 1, []string{"reginald"}, false, false},
+		{"Loop", []string{"reginald", "loop"}, 1, nil, false, true},
+		{"Empty", []string{"reginald", "empty"}, 1, nil, false, true},
+		{"Unterminated", []string{"reginald", "open"}, 1, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok, err := aliases.Expand(tt.args, tt.i)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand(%q, %d) error = %v, wantErr %v", tt.args, tt.i, err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, config.ErrInvalidConfig) {
+				t.Errorf("Expand(%q, %d) error = %v, want wrapping ErrInvalidConfig", tt.args, tt.i, err)
+			}
+
+			if ok != tt.wantOK {
+				t.Errorf("Expand(%q, %d) ok = %v, want %v", tt.args, tt.i, ok, tt.wantOK)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expand(%q, %d) = %q, want %q", tt.args, tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAliasesValidate(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example commands",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands: []*api.Command{
+				{
+					Name:        "greet",
+					Usage:       "greet",
+					Description: "greets",
+					Help:        "",
+					Manual:      "",
+					Args:        nil,
+					Aliases:     []string{"hello"},
+					Config:      nil,
+					Commands:    nil,
+				},
+			},
+			Tasks: nil,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		aliases config.Aliases
+		wantErr bool
+	}{
+		{"Nil", nil, false},
+		{"Valid", config.Aliases{"hi": "greet --loud"}, false},
+		{"Shadows command", config.Aliases{"greet": "hello"}, true},
+		{"Shadows command alias", config.Aliases{"hello": "greet"}, true},
+		{"Invalid name", config.Aliases{"two words": "attend"}, true},
+		{"Flag name", config.Aliases{"--up": "attend"}, true},
+		{"Empty value", config.Aliases{"up": ""}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t, manifests, fspath.Path(t.TempDir()))
+
+			err := tt.aliases.Validate(store)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/typeconv"
+)
+
+// errUnsupportedField is returned when a config field has a type that has no
+// binder.
+var errUnsupportedField = errors.New("unsupported config field type")
+
+// A binder sets the value of a config field from the environment variables and
+// the command-line flags. The value is the settable field in the config struct.
+//
+// The names of the environment variable and the command-line flag of the field
+// are resolved from the struct tags of the field: the "env" tag overrides
+// the name of the environment variable after the global prefix (see
+// [envName]), the "flag" tag overrides the name of the flag (see [FlagName]),
+// and the "mapstructure" tag is the key of the field in the config file.
+type binder func(value reflect.Value, opts ApplyOptions) error
+
+// typeBinders contains the binders of the config field types. Adding a field
+// of a type in this map or in [kindBinders] to the config needs no other
+// changes, and a new type is supported by adding its binder here.
+//
+//nolint:gochecknoglobals // used like constant
+var typeBinders = map[reflect.Type]binder{
+	reflect.TypeFor[terminal.ColorMode](): applyColorMode,
+	reflect.TypeFor[fspath.Path]():        bindValue(pathValue),
+	reflect.TypeFor[plugin.ByteSize]():    bindValue(byteSizeValue),
+	reflect.TypeFor[time.Duration]():      bindValue(durationValue),
+	reflect.TypeFor[float64]():            bindValue(floatValue),
+	reflect.TypeFor[[]bool]():             bindValue(boolSliceValue),
+	reflect.TypeFor[[]fspath.Path]():      bindValue(pathSliceValue),
+	reflect.TypeFor[[]int]():              bindValue(intSliceValue),
+	reflect.TypeFor[[]string]():           bindValue(stringSliceValue),
+	reflect.TypeFor[map[string]string]():  bindValue(stringMapValue),
+}
+
+// kindBinders contains the binders of the config fields by the kinds of their
+// types. They are used for the types that are not in [typeBinders], for
+// example the named types that implement [encoding.TextUnmarshaler].
+//
+//nolint:gochecknoglobals // used like constant
+var kindBinders = map[reflect.Kind]binder{
+	reflect.Bool:   applyBool,
+	reflect.Int:    applyInt,
+	reflect.Int8:   applyInt,
+	reflect.Int16:  applyInt,
+	reflect.Int32:  applyInt,
+	reflect.Int64:  applyInt,
+	reflect.String: applyString,
+}
+
+// bindField sets the value of the given config field from the environment
+// variables and the command-line flags using the binder of its type.
+func bindField(value reflect.Value, opts ApplyOptions) error {
+	bind, ok := typeBinders[value.Type()]
+	if !ok {
+		bind, ok = kindBinders[value.Kind()]
+	}
+
+	if !ok {
+		return fmt.Errorf("%w for %s: %s", errUnsupportedField, configKey(opts.idents), value.Type())
+	}
+
+	return bind(value, opts)
+}
+
+// bindValue returns a binder for the config fields of type T that resolves
+// the value of the field with the given function. The same functions are used
+// for resolving the config values of the plugins.
+func bindValue[T any](resolve func(x T, opts ApplyOptions, entry *api.ConfigEntry) (T, error)) binder {
+	return func(value reflect.Value, opts ApplyOptions) error {
+		x, ok := value.Interface().(T)
+		if !ok {
+			return fmt.Errorf("%w: %s to %T", typeconv.ErrConv, value.Type(), x)
+		}
+
+		x, err := resolve(x, opts, nil)
+		if err != nil {
+			return err
+		}
+
+		value.Set(reflect.ValueOf(x))
+
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/spf13/pflag"
+)
+
+func TestBinders_Config(t *testing.T) {
+	t.Parallel()
+
+	var check func(typ reflect.Type, path string)
+
+	check = func(typ reflect.Type, path string) {
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if !f.IsExported() || (path == "" && slices.Contains(dynamicFields, f.Name)) {
+				continue
+			}
+
+			if f.Type.Kind() == reflect.Struct && !reflect.PointerTo(f.Type).Implements(textUnmarshalerType) {
+				check(f.Type, path+f.Name+".")
+
+				continue
+			}
+
+			_, ok := typeBinders[f.Type]
+			if !ok {
+				_, ok = kindBinders[f.Type.Kind()]
+			}
+
+			if !ok {
+				t.Errorf("no binder for %s%s (%s)", path, f.Name, f.Type)
+			}
+		}
+	}
+
+	check(reflect.TypeFor[Config](), "")
+}
+
+func TestBindField(t *testing.T) { //nolint:paralleltest // uses t.Setenv
+	t.Setenv("REGINALD_PING_INTERVAL", "90")
+
+	opts := ApplyOptions{
+		FlagSet: flags.NewFlagSet("test", pflag.ContinueOnError),
+		idents:  []string{filename, "PingInterval"},
+	}
+
+	var n int
+
+	if err := bindField(reflect.ValueOf(&n).Elem(), opts); err != nil {
+		t.Fatalf("bindField() error = %v", err)
+	}
+
+	if n != 90 {
+		t.Errorf("bindField() = %d, want 90", n)
+	}
+
+	var c chan int
+
+	err := bindField(reflect.ValueOf(&c).Elem(), opts)
+	if !errors.Is(err, errUnsupportedField) {
+		t.Errorf("bindField() error = %v, want %v", err, errUnsupportedField)
+	}
+}
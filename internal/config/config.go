@@ -18,15 +18,23 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 
+	"github.com/pelletier/go-toml/v2/unstable"
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
 	"github.com/reginald-project/reginald/internal/flags"
 	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/httpclient"
 	"github.com/reginald-project/reginald/internal/logger"
 	"github.com/reginald-project/reginald/internal/plugin"
 	"github.com/reginald-project/reginald/internal/terminal"
@@ -34,8 +42,10 @@ import (
 
 // Path constants.
 const (
-	filename            = "reginald" // directories and default config files
-	secondaryConfigName = "config"   // alternative config file name for some paths
+	filename            = "reginald"      // directories and default config files
+	secondaryConfigName = "config"        // alternative config file name for some paths
+	directoryMarker     = ".reginald"     // marks the dotfiles directory without a config file
+	lockFileName        = "reginald.lock" // lock file of the installed plugins
 )
 
 // configExtensions contains the possible file extensions for the config file.
@@ -53,6 +63,18 @@ type Config struct {
 	// configFile is path to the config file that was found and parsed.
 	configFile fspath.Path
 
+	// fileData is the content of the config file. It is used for showing
+	// the snippets of the file in the errors.
+	fileData []byte
+
+	// positions contains the positions of the keys in the config file by
+	// their dotted key paths.
+	positions map[string]unstable.Position
+
+	// origins contains the config fragments that the values that are set in
+	// the fragments come from by the dotted key paths of the values.
+	origins map[string]fragmentOrigin
+
 	// Directory is the "dotfiles" directory option. If it is set, Reginald
 	// looks for all of the relative filenames from this directory. Most
 	// absolute paths are still resolved relative to actual current working
@@ -62,6 +84,30 @@ type Config struct {
 	// PluginPaths is the directory where Reginald looks for the plugins.
 	PluginPaths []fspath.Path `mapstructure:"plugin-paths"`
 
+	// PluginIndexes contains the URLs of the plugin indexes that the plugins
+	// are searched and installed from. The indexes are searched in order, and
+	// the URLs can also be file URLs or local paths.
+	PluginIndexes []string `mapstructure:"plugin-indexes"`
+
+	// BackupDir is the directory where Reginald stores the backups of
+	// the files that the tasks replace or remove. The files replaced during
+	// a run are stored in a snapshot directory of the run within it.
+	BackupDir fspath.Path `mapstructure:"backup-dir"`
+
+	// EventsSocket is the Unix socket that the structured events of the run
+	// are written to as newline-delimited JSON. The answers to the prompts
+	// are read from the socket. If it is empty, no events are written.
+	EventsSocket fspath.Path `mapstructure:"events-socket"`
+
+	// KeyFile is the file that contains the key for decrypting the encrypted
+	// block in the config file.
+	KeyFile fspath.Path `mapstructure:"key-file"`
+
+	// Aliases contains the command aliases that the user has defined. When
+	// the command given on the command line is an alias, it is replaced with
+	// the command line of the alias before resolving the command.
+	Aliases Aliases `mapstructure:"aliases"`
+
 	// Defaults contains the default options set for tasks.
 	Defaults plugin.TaskDefaults `mapstructure:"defaults"`
 
@@ -70,6 +116,13 @@ type Config struct {
 	// later.
 	RawPlugins map[string]any `mapstructure:",remain"` //nolint:tagliatelle // linter doesn't know about "remain"
 
+	// PluginOptions contains the options that Reginald uses for the individual
+	// plugins and the config values that are passed to the plugins when they
+	// are given in the "plugins" table. The keys of the map are the plugin
+	// names or domains, but the config values can only be given using
+	// the domains.
+	PluginOptions map[string]PluginOptions `mapstructure:"plugins"`
+
 	// RawTasks contains the raw config values for the tasks as given in
 	// the config file.
 	RawTasks []map[string]any `mapstructure:"tasks"`
@@ -83,9 +136,16 @@ type Config struct {
 	// Logging contains the config values for logging.
 	Logging logger.Config `flag:"log" mapstructure:"logging"`
 
+	// Network contains the config values for the network operations, like
+	// downloading the plugins.
+	Network httpclient.Config `mapstructure:"network"`
+
 	// Color tells whether colors should be enabled in the user output.
 	Color terminal.ColorMode `mapstructure:"color"`
 
+	// Theme contains the colors used in the user output.
+	Theme terminal.Theme `mapstructure:"theme"`
+
 	// Debug tells the program to print debug output.
 	Debug bool `mapstructure:"debug"`
 
@@ -98,10 +158,115 @@ type Config struct {
 	// Interactive tells the program to run in interactive mode.
 	Interactive bool `mapstructure:"interactive"`
 
-	// Strict tells the program to enable strict mode. If the strict mode is
-	// enabled, the program will exit if the config file or the plugins
-	// directory is not found.
-	Strict bool `mapstructure:"strict"`
+	// Porcelain tells the program to suppress the regular user interface and
+	// print stable, machine-readable lines for the task events instead.
+	Porcelain bool `mapstructure:"porcelain"`
+
+	// CI tells the program to run in CI mode that is meant for the runs
+	// without a user at a terminal, like in CI services and container builds.
+	// In CI mode, the prompts are not shown and their default answers are
+	// used, and the colors and the progress messages are disabled. CI mode is
+	// also enabled automatically if a CI environment is detected.
+	CI bool `flag:"ci" mapstructure:"ci"`
+
+	// DryRun tells the program to only report the changes that the tasks would
+	// make instead of applying them.
+	DryRun bool `mapstructure:"dry-run"`
+
+	// OnFailure is the default policy for handling the failed tasks. It is used
+	// for the tasks that do not set the "on-failure" option.
+	OnFailure plugin.FailurePolicy `mapstructure:"on-failure"`
+
+	// Strict contains the strict mode and the severities of the classes of
+	// diagnostics. If the strict mode is enabled, the program will exit if,
+	// for example, the config file or the plugins directory is not found.
+	// For backwards compatibility, the strict mode can also be enabled with
+	// "strict = true" in the config file.
+	Strict diag.Config `mapstructure:"strict"`
+
+	// Lock tells the program to acquire the lock file in the state directory
+	// before running a command that changes the system so that two runs cannot
+	// change the same files at the same time.
+	Lock bool `flag:"lock,no-lock" mapstructure:"lock"`
+
+	// Wait tells the program to wait for the lock file to be released if
+	// another run holds it instead of exiting with an error.
+	Wait bool `mapstructure:"wait"`
+
+	// AssumeYes tells the program to answer yes to all of the confirmations and
+	// use the default values for the other prompts instead of asking the user.
+	AssumeYes bool `mapstructure:"assume-yes"`
+
+	// AssumeDefaults tells the program to use the default values for all of
+	// the prompts instead of asking the user.
+	AssumeDefaults bool `mapstructure:"assume-defaults"`
+
+	// Timings tells the program to print a summary of how long each phase of
+	// the run took after the run.
+	Timings bool `mapstructure:"timings"`
+
+	// Pager tells the program to show the long output, like the help messages,
+	// in a pager when the standard output is a terminal. The pager is read
+	// from the "PAGER" environment variable and it defaults to less.
+	Pager bool `flag:"pager,no-pager" mapstructure:"pager"`
+
+	// PingInterval is the interval in seconds at which the started plugins
+	// that have been idle are pinged to detect hung plugin processes. Zero
+	// disables the pings.
+	PingInterval int `mapstructure:"ping-interval"`
+
+	// ShutdownTimeout is the time in seconds that each plugin has for shutting
+	// down gracefully at the end of the run. The plugins that have not exited
+	// by then are terminated. Zero uses the default timeout. It can be
+	// overridden for a plugin in its plugin options.
+	ShutdownTimeout int `mapstructure:"shutdown-timeout"`
+
+	// MaxProtocolErrors is the number of protocol errors after which a plugin
+	// is killed and not used again during the run. Zero disables the limit,
+	// but the plugins are still stopped for the errors after which their
+	// messages cannot be read.
+	MaxProtocolErrors int `mapstructure:"max-protocol-errors"`
+
+	// MaxMessageSize is the maximum length of the content of a message that
+	// a plugin sends. A plugin that sends a longer message is killed and not
+	// used again during the run. Zero uses the default size.
+	MaxMessageSize plugin.ByteSize `mapstructure:"max-message-size"`
+
+	// RestartPlugins tells the program to restart the plugins that have not
+	// responded to a ping before they are used again.
+	RestartPlugins bool `mapstructure:"restart-plugins"`
+
+	// DetectDirectory tells the program to detect the dotfiles directory by
+	// walking up from the working directory until it finds a directory with
+	// a config file or a ".reginald" marker. It is used only if the directory
+	// or the config file is not set explicitly. As the detection is done
+	// before the config file is read, it can be disabled only with
+	// the environment variable or the command-line option.
+	DetectDirectory bool `flag:"detect-directory,no-detect-directory" mapstructure:"detect-directory"`
+}
+
+// PluginOptions contains the options that Reginald uses for a plugin.
+type PluginOptions struct {
+	// LogLevel is the minimum level of the log messages from the plugin that
+	// are logged. If it is not set, the level from the logging config is used.
+	LogLevel *logger.Level `mapstructure:"log-level"`
+
+	// Sandbox contains the restrictions for the process of the plugin. It can
+	// only be set for external plugins.
+	Sandbox plugin.Sandbox `mapstructure:"sandbox"`
+
+	// Output contains the limits of the standard error output of the plugin.
+	// It can only be set for external plugins.
+	Output plugin.OutputLimits `mapstructure:"output"`
+
+	// ShutdownTimeout is the time in seconds that the plugin has for shutting
+	// down gracefully. If it is not set, the global shutdown timeout is used.
+	ShutdownTimeout *int `mapstructure:"shutdown-timeout"`
+
+	// Values contains the rest of the values in the table of the plugin. They
+	// are the config values of the plugin, and they are used in the same way
+	// as the values in the top-level table of the plugin domain.
+	Values map[string]any `mapstructure:",remain"` //nolint:tagliatelle // linter doesn't know about "remain"
 }
 
 // DefaultConfig returns the default values for configuration. The function
@@ -117,22 +282,72 @@ func DefaultConfig() *Config {
 		panic(fmt.Sprintf("failed to get default plugin directory: %v", err))
 	}
 
+	backupDir, err := DefaultBackupDir()
+	if err != nil {
+		panic(fmt.Sprintf("failed to get default backup directory: %v", err))
+	}
+
+	keyFile, err := DefaultKeyFile()
+	if err != nil {
+		panic(fmt.Sprintf("failed to get default key file: %v", err))
+	}
+
 	return &Config{
-		configFile:  "",
-		Color:       terminal.ColorAuto,
-		Debug:       false,
-		Defaults:    plugin.TaskDefaults{},
-		Directory:   fspath.Path(wd),
-		Interactive: false,
-		Logging:     logger.DefaultConfig(),
-		PluginPaths: pluginPaths,
-		Plugins:     nil,
-		Quiet:       false,
-		RawPlugins:  nil,
-		RawTasks:    nil,
-		Tasks:       nil,
-		Verbose:     false,
-		Strict:      false,
+		configFile:        "",
+		fileData:          nil,
+		positions:         nil,
+		origins:           nil,
+		Aliases:           nil,
+		AssumeDefaults:    false,
+		AssumeYes:         false,
+		BackupDir:         backupDir,
+		CI:                false,
+		Color:             terminal.ColorAuto,
+		Debug:             false,
+		Defaults:          plugin.TaskDefaults{},
+		DetectDirectory:   true,
+		Directory:         fspath.Path(wd),
+		DryRun:            false,
+		EventsSocket:      "",
+		Interactive:       false,
+		KeyFile:           keyFile,
+		Lock:              true,
+		Logging:           logger.DefaultConfig(),
+		MaxMessageSize:    plugin.DefaultMaxMessageSize,
+		MaxProtocolErrors: plugin.DefaultMaxProtocolErrors,
+		Network:           httpclient.DefaultConfig(),
+		OnFailure:         plugin.FailureStop,
+		Pager:             true,
+		PluginIndexes:     nil,
+		PluginOptions:     nil,
+		PingInterval:      30, //nolint:mnd // default ping interval in seconds
+		PluginPaths:       pluginPaths,
+		Plugins:           nil,
+		Porcelain:         false,
+		Quiet:             false,
+		RawPlugins:        nil,
+		RawTasks:          nil,
+		RestartPlugins:    false,
+		ShutdownTimeout:   5, //nolint:mnd // default shutdown timeout in seconds
+		Tasks:             nil,
+		Theme:             terminal.DefaultTheme(),
+		Timings:           false,
+		Verbose:           false,
+		Strict:            diag.Config{}, //nolint:exhaustruct // use the defaults of the classes
+		Wait:              false,
+	}
+}
+
+// CommandContext returns the global options of the run that are passed to
+// the plugin commands.
+func (c *Config) CommandContext() *plugin.CommandContext {
+	return &plugin.CommandContext{
+		Directory:   string(c.Directory),
+		Color:       terminal.ColorsEnabled(c.Color),
+		DryRun:      c.DryRun,
+		Verbose:     c.Verbose,
+		Quiet:       c.Quiet,
+		Interactive: c.Interactive,
 	}
 }
 
@@ -146,6 +361,70 @@ func (c *Config) HasFile() bool {
 	return c.configFile != ""
 }
 
+// LockFile returns the path to the lock file of the plugins installed from
+// the plugin indexes. The lock file is next to the config file, or in
+// the "dotfiles" directory if no config file was found.
+func (c *Config) LockFile() fspath.Path {
+	if c.configFile != "" {
+		return c.configFile.Dir().Join(lockFileName)
+	}
+
+	return c.Directory.Join(lockFileName)
+}
+
+// DefaultBackupDir returns the default directory for the backups. It is
+// the "backups" directory within the data directory of Reginald.
+func DefaultBackupDir() (fspath.Path, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return dir.Join("backups"), nil
+}
+
+// DefaultKeyFile returns the default path to the key file for the encrypted
+// config values. It is the "key" file within the data directory of Reginald so
+// that it is not stored in the dotfiles directory with the encrypted values.
+func DefaultKeyFile() (fspath.Path, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return dir.Join("key"), nil
+}
+
+// PluginDataDir returns the directory for the persistent data of the external
+// plugins. It is the "plugin-data" directory within the data directory of
+// Reginald, and each plugin has a directory named after it within it. It is not
+// within the "plugins" directory as that is a plugin search path.
+func PluginDataDir() (fspath.Path, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return dir.Join("plugin-data"), nil
+}
+
+// StateDir returns the directory for the state files of Reginald, like the lock
+// file of the runs. It is the "reginald" directory within "XDG_STATE_HOME" if
+// the variable is set.
+func StateDir() (fspath.Path, error) {
+	env := os.Getenv("XDG_STATE_HOME")
+	if env == "" {
+		return defaultOSStateDir()
+	}
+
+	path, err := fspath.NewAbs(env, filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert state directory to absolute path: %w", err)
+	}
+
+	return path, nil
+}
+
 // DefaultPluginPaths returns the default plugins directory to use.
 func DefaultPluginPaths() ([]fspath.Path, error) {
 	paths, err := defaultOSPluginPaths()
@@ -366,11 +645,89 @@ func resolveDefaultFiles(dir fspath.Path) (fspath.Path, error) {
 	return "", &FileError{""}
 }
 
+// FindDirectory looks for the dotfiles directory by walking up from start. It
+// returns the first directory that contains either a config file with one of
+// the default names or a ".reginald" marker file or directory. If no such
+// directory is found, FindDirectory returns an empty path. The start directory
+// must be absolute.
+func FindDirectory(start fspath.Path) (fspath.Path, error) {
+	dir := start.Clean()
+
+	for {
+		names := make([]string, 0, len(configExtensions)+1)
+		for _, e := range configExtensions {
+			names = append(names, filename+e)
+		}
+
+		names = append(names, directoryMarker)
+
+		for _, name := range names {
+			if _, err := os.Stat(string(dir.Join(name))); err == nil {
+				return dir, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("failed to check %q: %w", dir.Join(name), err)
+			}
+		}
+
+		parent := dir.Dir()
+		if parent == dir {
+			return "", nil
+		}
+
+		dir = parent
+	}
+}
+
+// detectDirectory returns the detected dotfiles directory for the given
+// working directory. It returns an empty path if the detection is disabled,
+// the dotfiles directory or the config file is set explicitly, or no directory
+// is found.
+func detectDirectory(ctx context.Context, wd fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
+	enabled := true
+
+	if env := os.Getenv(strings.ToUpper(filename + "_DETECT_DIRECTORY")); env != "" {
+		var err error
+
+		if enabled, err = strconv.ParseBool(env); err != nil {
+			return "", fmt.Errorf("%w: invalid value for %s_DETECT_DIRECTORY: %q", ErrInvalidConfig, strings.ToUpper(filename), env)
+		}
+	}
+
+	if flagName := FlagName("DetectDirectory"); flagSet.Changed(flagName) {
+		enabled = true
+	}
+
+	if flagName := InvertedFlagName("DetectDirectory"); flagSet.Changed(flagName) {
+		enabled = false
+	}
+
+	if !enabled ||
+		os.Getenv(strings.ToUpper(filename+"_DIRECTORY")) != "" ||
+		os.Getenv(strings.ToUpper(filename+"_CONFIG_FILE")) != "" ||
+		flagSet.Changed(FlagName("Directory")) ||
+		flagSet.Changed("config") {
+		return "", nil
+	}
+
+	dir, err := FindDirectory(wd)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the dotfiles directory: %w", err)
+	}
+
+	if dir != "" && dir != wd {
+		slog.DebugContext(ctx, "detected dotfiles directory", "dir", dir, "wd", wd)
+	}
+
+	return dir, nil
+}
+
 // resolveFile looks up the possible paths for the configuration file and
 // returns the first one that contains a file with a valid name. The returned
 // path is absolute. If no configuration file is found, the function returns an
-// empty string and an error.
-func resolveFile(dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
+// empty string and an error. If the config file is given as a URL, the config
+// is fetched to the cache directory and the function also returns the path to
+// the fetched repository if the URL points to a Git repository.
+func resolveFile(ctx context.Context, dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, fspath.Path, error) {
 	var (
 		err       error
 		fileValue string
@@ -383,24 +740,37 @@ func resolveFile(dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
 	if flagSet.Changed("config") {
 		fileValue, err = flagSet.GetString("config")
 		if err != nil {
-			return "", fmt.Errorf("failed to get the value for command-line option --%s: %w", "config", err)
+			return "", "", fmt.Errorf("failed to get the value for command-line option --%s: %w", "config", err)
 		}
 	}
 
+	if isRemote(fileValue) {
+		if err = initNetwork(ctx, dir, flagSet); err != nil {
+			return "", "", err
+		}
+
+		remote, err := fetchRemote(ctx, fileValue)
+		if err != nil {
+			return "", "", err
+		}
+
+		return remote.file, remote.dir, nil
+	}
+
 	file := fspath.Path(fileValue)
 
 	file, err = file.Expand()
 	if err != nil {
-		return "", fmt.Errorf("failed to expand config path: %w", err)
+		return "", "", fmt.Errorf("failed to expand config path: %w", err)
 	}
 
 	if file.IsAbs() {
 		var ok bool
 
 		if ok, err = file.IsFile(); err != nil {
-			return "", fmt.Errorf("failed to check if %q is a file: %w", file, err)
+			return "", "", fmt.Errorf("failed to check if %q is a file: %w", file, err)
 		} else if ok {
-			return file.Clean(), nil
+			return file.Clean(), "", nil
 		}
 	}
 
@@ -414,7 +784,7 @@ func resolveFile(dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
 	if flagSet.Changed(flagName) {
 		wd, err = flagSet.GetPath(flagName)
 		if err != nil {
-			return "", fmt.Errorf(
+			return "", "", fmt.Errorf(
 				"failed to get the value for command-line option --%s: %w",
 				flagName,
 				err,
@@ -424,13 +794,13 @@ func resolveFile(dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
 
 	wd, err = wd.Expand()
 	if err != nil {
-		return "", fmt.Errorf("failed to expand working directory: %w", err)
+		return "", "", fmt.Errorf("failed to expand working directory: %w", err)
 	}
 
 	if !wd.IsAbs() {
 		wd, err = wd.Abs()
 		if err != nil {
-			return "", fmt.Errorf("failed to make working directory path absolute: %w", err)
+			return "", "", fmt.Errorf("failed to make working directory path absolute: %w", err)
 		}
 	}
 
@@ -439,23 +809,23 @@ func resolveFile(dir fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
 	var ok bool
 
 	if ok, err = file.IsFile(); err != nil {
-		return "", fmt.Errorf("failed to check if %q is a file: %w", file, err)
+		return "", "", fmt.Errorf("failed to check if %q is a file: %w", file, err)
 	} else if ok {
-		return file, nil
+		return file, "", nil
 	}
 
 	// If the config file flag is set but it didn't resolve, fail so that the
 	// program doesn't use a config file from some other location by surprise.
 	if fileValue != "" {
-		return "", &FileError{file: file}
+		return "", "", &FileError{file: file}
 	}
 
 	file, err = resolveDefaultFiles(wd)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return file, nil
+	return file, "", nil
 }
 
 // xdgConfigPaths returns the possible config file combinations to check
@@ -475,6 +845,23 @@ func xdgConfigPaths() ([]fspath.Path, error) {
 	return []fspath.Path{path.Join("reginald"), path.Join("config"), path}, nil
 }
 
+// dataDir returns the directory for the data that Reginald manages, like
+// the backups. It respects the XDG base directory specification on all
+// platforms and uses the platform-specific default otherwise.
+func dataDir() (fspath.Path, error) {
+	env := os.Getenv("XDG_DATA_HOME")
+	if env == "" {
+		return defaultOSDataDir()
+	}
+
+	path, err := fspath.NewAbs(env, filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert data directory to absolute path: %w", err)
+	}
+
+	return path, nil
+}
+
 // xdgPluginPath returns the plugin directory resolved from "XDG_DATA_HOME"
 // variable if it is set. Otherwise, it returns an empty string.
 func xdgPluginPath() (fspath.Path, error) {
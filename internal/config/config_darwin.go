@@ -57,6 +57,34 @@ func defaultOSConfigs() ([]fspath.Path, error) {
 	return paths, nil
 }
 
+func defaultOSDataDir() (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	path, err := fspath.NewAbs(home, ".local", "share", filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert data directory to absolute path: %w", err)
+	}
+
+	return path, nil
+}
+
+func defaultOSStateDir() (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	path, err := fspath.NewAbs(home, ".local", "state", filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert state directory to absolute path: %w", err)
+	}
+
+	return path, nil
+}
+
 func defaultOSPluginPaths() ([]fspath.Path, error) {
 	path, err := xdgPluginPath()
 	if err != nil {
@@ -0,0 +1,539 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/spf13/pflag"
+)
+
+func TestFindDirectory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files []string
+		start string
+		want  string
+	}{
+		{
+			"Config in start",
+			[]string{"dotfiles/reginald.toml"},
+			"dotfiles",
+			"dotfiles",
+		},
+		{
+			"Config in parent",
+			[]string{"dotfiles/reginald.toml", "dotfiles/zsh/"},
+			"dotfiles/zsh",
+			"dotfiles",
+		},
+		{
+			"Marker file",
+			[]string{"dotfiles/.reginald", "dotfiles/a/b/"},
+			"dotfiles/a/b",
+			"dotfiles",
+		},
+		{
+			"Marker directory",
+			[]string{"dotfiles/.reginald/", "dotfiles/a/"},
+			"dotfiles/a",
+			"dotfiles",
+		},
+		{
+			"Nearest wins",
+			[]string{"reginald.toml", "dotfiles/reginald.toml", "dotfiles/a/"},
+			"dotfiles/a",
+			"dotfiles",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+
+			for _, f := range tt.files {
+				path := filepath.Join(root, filepath.FromSlash(f))
+
+				if f[len(f)-1] == '/' {
+					if err := os.MkdirAll(path, 0o750); err != nil {
+						t.Fatal(err)
+					}
+
+					continue
+				}
+
+				if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.WriteFile(path, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := config.FindDirectory(fspath.Path(filepath.Join(root, filepath.FromSlash(tt.start))))
+			if err != nil {
+				t.Fatalf("FindDirectory() error = %v", err)
+			}
+
+			want := fspath.Path(filepath.Join(root, filepath.FromSlash(tt.want)))
+			if got != want {
+				t.Errorf("FindDirectory() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeys(t *testing.T) {
+	t.Parallel()
+
+	cfg := map[string]any{
+		"logLevel": "debug",
+		"plugin-name": map[string]any{
+			"envVars": map[string]any{
+				"JAVA_HOME": "/opt/java",
+				"my_var":    "foo",
+				"GoPath":    "/go",
+			},
+		},
+	}
+
+	want := map[string]any{
+		"log-level": "debug",
+		"plugin-name": map[string]any{
+			"env-vars": map[string]any{
+				"JAVA_HOME": "/opt/java",
+				"my_var":    "foo",
+				"GoPath":    "/go",
+			},
+		},
+	}
+
+	config.NormalizeKeys(cfg)
+
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("NormalizeKeys() = %v, want %v", cfg, want)
+	}
+}
+
+func TestApplyPlugins_PluginsTable(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "executable": "plugin",
+  "config": [
+    { "key": "greeting", "type": "string", "value": "hello" }
+  ],
+  "commands": [
+    { "name": "greet", "usage": "greet", "description": "greet" }
+  ]
+}`
+
+	tests := []struct {
+		name         string
+		file         string
+		want         string
+		wantErr      bool
+		wantValidErr bool
+	}{
+		{"Default", "", "hello", false, false},
+		{"Domain table", "[example]\ngreeting = \"hi\"", "hi", false, false},
+		{"Plugins table", "[plugins.example]\ngreeting = \"hey\"", "hey", false, false},
+		{
+			"Plugins table with options",
+			"[plugins.example]\ngreeting = \"hey\"\nlog-level = \"debug\"",
+			"hey",
+			false,
+			false,
+		},
+		{
+			"Plugins table with snake_case options",
+			"[plugins.example]\ngreeting = \"hey\"\nlog_level = \"debug\"",
+			"hey",
+			false,
+			false,
+		},
+		{"Options by name", "[plugins.reginald-example]\nlog-level = \"debug\"", "hello", false, false},
+		{
+			"Plugins table with sandbox",
+			"[plugins.example]\ngreeting = \"hey\"\n\n[plugins.example.sandbox]\nenv = [\"PATH\"]",
+			"hey",
+			false,
+			false,
+		},
+		{"Both tables", "[example]\ngreeting = \"hi\"\n\n[plugins.example]\ngreeting = \"hey\"", "", true, false},
+		{"Values by name", "[plugins.reginald-example]\ngreeting = \"hey\"", "hello", false, true},
+		{"Unknown plugin", "[plugins.unknown]\ngreeting = \"hey\"", "hello", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+			store := newExternalStore(t, manifest, cfg.Directory)
+
+			opts := config.ApplyOptions{
+				Dir:     cfg.Directory,
+				FlagSet: flags.NewFlagSet("test", pflag.ContinueOnError),
+				Store:   store,
+			}
+
+			err := config.ApplyPlugins(t.Context(), cfg, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPlugins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			values, ok := cfg.Plugins.Get("example")
+			if !ok {
+				t.Fatal("no config values for \"example\"")
+			}
+
+			kvs, ok := values.Val.(api.KeyValues)
+			if !ok {
+				t.Fatalf("config values = %T, want api.KeyValues", values.Val)
+			}
+
+			if kv, ok := kvs.Get("greeting"); !ok || kv.Val != tt.want {
+				t.Errorf("greeting = %v, want %v", kv.Val, tt.want)
+			}
+
+			if err = config.Validate(cfg, store, nil); (err != nil) != tt.wantValidErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantValidErr)
+			}
+		})
+	}
+}
+
+func TestApplyPlugins_Order(t *testing.T) {
+	t.Parallel()
+
+	cmd := func(name string, subcommands ...*api.Command) *api.Command {
+		return &api.Command{ //nolint:exhaustruct // only the names are needed
+			Name:     name,
+			Usage:    name,
+			Commands: subcommands,
+		}
+	}
+
+	manifests := []*api.Manifest{
+		{ //nolint:exhaustruct // only the commands are needed
+			Name:     "reginald-one",
+			Version:  "0.1.0",
+			Domain:   "one",
+			Commands: []*api.Command{cmd("zeta", cmd("b"), cmd("a")), cmd("alpha")},
+		},
+		{ //nolint:exhaustruct // only the commands are needed
+			Name:     "reginald-two",
+			Version:  "0.1.0",
+			Domain:   "two",
+			Commands: []*api.Command{cmd("mid")},
+		},
+	}
+
+	dir := fspath.Path(t.TempDir())
+	cfg := parseFile(t, "")
+	cfg.Directory = dir
+
+	opts := config.ApplyOptions{
+		Dir:     dir,
+		FlagSet: flags.NewFlagSet("test", pflag.ContinueOnError),
+		Store:   newStore(t, manifests, dir),
+	}
+
+	if err := config.ApplyPlugins(t.Context(), cfg, opts); err != nil {
+		t.Fatalf("ApplyPlugins() error = %v", err)
+	}
+
+	keys := func(kvs api.KeyValues) []string {
+		var a []string
+		for _, kv := range kvs {
+			a = append(a, kv.Key)
+		}
+
+		return a
+	}
+
+	if got, want := keys(cfg.Plugins), []string{"alpha", "mid", "zeta"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plugin config keys = %v, want %v", got, want)
+	}
+
+	zeta, ok := cfg.Plugins.Get("zeta")
+	if !ok {
+		t.Fatal("no config values for \"zeta\"")
+	}
+
+	kvs, err := zeta.Configs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := keys(kvs), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subcommand config keys = %v, want %v", got, want)
+	}
+}
+
+func TestApplyPlugins_PersistentConfig(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "executable": "plugin",
+  "commands": [
+    {
+      "name": "greet",
+      "usage": "greet",
+      "description": "greet",
+      "persistentConfig": [
+        { "key": "name", "type": "string", "value": "world", "pattern": "^[a-z]+$" }
+      ],
+      "commands": [
+        { "name": "loudly", "usage": "loudly", "description": "greet loudly" }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{"Default", "", "world", false},
+		{"File", "[example.greet]\nname = \"there\"", "there", false},
+		{"Constraint", "[example.greet]\nname = \"THERE\"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+			store := newExternalStore(t, manifest, cfg.Directory)
+
+			greet := store.Command(store.Command(nil, "example"), "greet")
+			if greet == nil || len(greet.PersistentConfig) != 1 {
+				t.Fatalf("greet command = %+v, want one persistent config entry", greet)
+			}
+
+			opts := config.ApplyOptions{
+				Dir:     cfg.Directory,
+				FlagSet: flags.NewFlagSet("test", pflag.ContinueOnError),
+				Store:   store,
+			}
+
+			err := config.ApplyPlugins(t.Context(), cfg, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPlugins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			values, ok := cfg.Plugins.Get("example")
+			if !ok {
+				t.Fatal("no config values for \"example\"")
+			}
+
+			kvs, err := values.Configs()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			greetValues, ok := kvs.Get("greet")
+			if !ok {
+				t.Fatal("no config values for \"greet\"")
+			}
+
+			if kvs, err = greetValues.Configs(); err != nil {
+				t.Fatal(err)
+			}
+
+			if kv, ok := kvs.Get("name"); !ok || kv.Val != tt.want {
+				t.Errorf("name = %v, want %v", kv.Val, tt.want)
+			}
+		})
+	}
+}
+
+func TestPluginOptions_Sandbox(t *testing.T) {
+	t.Parallel()
+
+	cfg := parseFile(t, `[plugins.example.sandbox]
+env = ["PATH", "LC_*"]
+dir = "work"
+cpu = 30
+memory = "512M"
+network = false`)
+
+	network := false
+	want := plugin.Sandbox{
+		Env:     []string{"PATH", "LC_*"},
+		Dir:     "work",
+		CPU:     30,
+		Memory:  512 << 20,
+		Network: &network,
+	}
+
+	if got := cfg.PluginOptions["example"].Sandbox; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sandbox = %+v, want %+v", got, want)
+	}
+
+	if len(cfg.PluginOptions["example"].Values) != 0 {
+		t.Errorf("Values = %v, want none", cfg.PluginOptions["example"].Values)
+	}
+}
+
+func TestPluginOptions_Output(t *testing.T) {
+	t.Parallel()
+
+	cfg := parseFile(t, `[plugins.example.output]
+max-line-length = "1K"
+lines-per-second = 20`)
+
+	want := plugin.OutputLimits{MaxLineLength: 1 << 10, LinesPerSecond: 20}
+
+	if got := cfg.PluginOptions["example"].Output; got != want {
+		t.Errorf("Output = %+v, want %+v", got, want)
+	}
+
+	if len(cfg.PluginOptions["example"].Values) != 0 {
+		t.Errorf("Values = %v, want none", cfg.PluginOptions["example"].Values)
+	}
+}
+
+func TestConfig_MaxMessageSize(t *testing.T) {
+	t.Parallel()
+
+	cfg := parseFile(t, "max-message-size = \"32M\"\n")
+
+	if cfg.MaxMessageSize != 32<<20 {
+		t.Errorf("MaxMessageSize = %d, want %d", cfg.MaxMessageSize, 32<<20)
+	}
+}
+
+func TestPluginOptions_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg := parseFile(t, "shutdown-timeout = 10\n\n[plugins.example]\nshutdown-timeout = 30\n")
+
+	if cfg.ShutdownTimeout != 10 {
+		t.Errorf("ShutdownTimeout = %d, want 10", cfg.ShutdownTimeout)
+	}
+
+	got := cfg.PluginOptions["example"].ShutdownTimeout
+	if got == nil || *got != 30 {
+		t.Errorf("plugin ShutdownTimeout = %v, want 30", got)
+	}
+
+	if len(cfg.PluginOptions["example"].Values) != 0 {
+		t.Errorf("Values = %v, want none", cfg.PluginOptions["example"].Values)
+	}
+}
+
+func TestParse_Strict(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want diag.Config
+	}{
+		{
+			name: "bool",
+			data: "strict = true\n",
+			want: diag.Config{Enabled: true},
+		},
+		{
+			name: "table",
+			data: "[strict]\nenabled = true\nmissing-config = \"ignore\"\nplatform-skip = \"error\"\n",
+			want: diag.Config{
+				MissingConfig: diag.SeverityIgnore,
+				PlatformSkip:  diag.SeverityError,
+				Enabled:       true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "reginald.toml")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := parseWithConfig(t, path)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if cfg.Strict != tt.want {
+				t.Errorf("Strict = %+v, want %+v", cfg.Strict, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_CommandContext(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.DefaultConfig()
+	cfg.Directory = "/dotfiles"
+	cfg.Color = terminal.ColorAlways
+	cfg.DryRun = true
+	cfg.Verbose = true
+
+	want := &plugin.CommandContext{
+		Directory:   "/dotfiles",
+		Color:       true,
+		DryRun:      true,
+		Verbose:     true,
+		Quiet:       false,
+		Interactive: cfg.Interactive,
+	}
+
+	if got := cfg.CommandContext(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandContext() = %+v, want %+v", got, want)
+	}
+}
@@ -16,17 +16,47 @@ package config
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/reginald-project/reginald/internal/fspath"
 )
 
 func defaultOSConfigs() ([]fspath.Path, error) {
-	appData, err := fspath.NewAbs("%APPDATA%", filename)
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create absolute AppData path: %w", err)
+		return nil, fmt.Errorf("failed to get the user home directory: %w", err)
 	}
 
-	return []fspath.Path{appData.Join(filename), appData.Join(secondaryConfigName)}, nil
+	var appData fspath.Path
+
+	appData, err = knownFolder("APPDATA", home, "AppData", "Roaming")
+	if err != nil {
+		return nil, err
+	}
+
+	cfgPath := appData.Join(filename)
+
+	return []fspath.Path{
+		cfgPath.Join(filename),
+		cfgPath.Join(secondaryConfigName),
+		cfgPath,
+		fspath.New(home, filename),
+		fspath.New(home, "."+filename),
+	}, nil
+}
+
+func defaultOSDataDir() (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	localAppData, err := knownFolder("LOCALAPPDATA", home, "AppData", "Local")
+	if err != nil {
+		return "", err
+	}
+
+	return localAppData.Join(filename), nil
 }
 
 func defaultOSPluginPaths() ([]fspath.Path, error) {
@@ -39,10 +69,51 @@ func defaultOSPluginPaths() ([]fspath.Path, error) {
 		return []fspath.Path{path}, nil
 	}
 
-	path, err = fspath.NewAbs("%LOCALAPPDATA%", filename, "plugins")
+	var home string
+
+	home, err = os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	var localAppData fspath.Path
+
+	localAppData, err = knownFolder("LOCALAPPDATA", home, "AppData", "Local")
+	if err != nil {
+		return nil, err
+	}
+
+	return []fspath.Path{localAppData.Join(filename, "plugins")}, nil
+}
+
+func defaultOSStateDir() (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	localAppData, err := knownFolder("LOCALAPPDATA", home, "AppData", "Local")
+	if err != nil {
+		return "", err
+	}
+
+	return localAppData.Join(filename), nil
+}
+
+// knownFolder returns the absolute path of the Windows known folder that is
+// stored in the environment variable env, for example "APPDATA". If
+// the variable is not set, the path is created by joining the given default
+// path elements which should point to the default location of the folder.
+func knownFolder(env string, def ...string) (fspath.Path, error) {
+	dir := os.Getenv(env)
+	if dir == "" {
+		dir = string(fspath.New(def...))
+	}
+
+	path, err := fspath.NewAbs(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert plugins directory to absolute path: %w", err)
+		return "", fmt.Errorf("failed to create absolute %%%s%% path: %w", env, err)
 	}
 
-	return []fspath.Path{path}, nil
+	return path, nil
 }
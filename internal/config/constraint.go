@@ -0,0 +1,162 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// checkConstraints checks that the resolved value kv satisfies the validation
+// constraints that the plugin has declared for the entry in opts. key is
+// the config key of the value that is used in the error messages. The types
+// that the constraints cannot be used with are checked when the manifest is
+// loaded, so the other types are not checked here.
+func checkConstraints(kv api.KeyVal, key string, opts plugin.EntryOptions) error {
+	switch kv.Type { //nolint:exhaustive // the constraints are not supported for the other types
+	case api.IntValue:
+		x, err := kv.Int()
+		if err != nil {
+			return fmt.Errorf("failed to convert type for %q: %w", key, err)
+		}
+
+		return checkNumber(float64(x), key, opts)
+	case api.IntListValue:
+		a, err := kv.IntSlice()
+		if err != nil {
+			return fmt.Errorf("failed to convert type for %q: %w", key, err)
+		}
+
+		for _, x := range a {
+			if err = checkNumber(float64(x), key, opts); err != nil {
+				return err
+			}
+		}
+	case plugin.FloatValue:
+		x, ok := kv.Val.(float64)
+		if !ok {
+			return fmt.Errorf("failed to convert type for %q: %v (%T) is not a float64", key, kv.Val, kv.Val)
+		}
+
+		return checkNumber(x, key, opts)
+	case api.StringListValue:
+		a, err := kv.StringSlice()
+		if err != nil {
+			return fmt.Errorf("failed to convert type for %q: %w", key, err)
+		}
+
+		for _, x := range a {
+			if err = checkString(x, key, opts); err != nil {
+				return err
+			}
+		}
+	case api.StringValue:
+		x, err := kv.String()
+		if err != nil {
+			return fmt.Errorf("failed to convert type for %q: %w", key, err)
+		}
+
+		return checkString(x, key, opts)
+	}
+
+	return nil
+}
+
+// checkNumber checks that the numeric value x satisfies the constraints in
+// opts.
+func checkNumber(x float64, key string, opts plugin.EntryOptions) error {
+	if opts.Min != nil && x < *opts.Min {
+		return fmt.Errorf("%w: invalid value %v for %q, must be at least %v", ErrInvalidConfig, x, key, *opts.Min)
+	}
+
+	if opts.Max != nil && x > *opts.Max {
+		return fmt.Errorf("%w: invalid value %v for %q, must be at most %v", ErrInvalidConfig, x, key, *opts.Max)
+	}
+
+	if len(opts.OneOf) == 0 || slices.Contains(opts.OneOf, any(x)) {
+		return nil
+	}
+
+	allowed := make([]string, len(opts.OneOf))
+	for i, v := range opts.OneOf {
+		allowed[i] = fmt.Sprint(v)
+	}
+
+	return fmt.Errorf(
+		"%w: invalid value %v for %q, must be one of %s",
+		ErrInvalidConfig,
+		x,
+		key,
+		strings.Join(allowed, ", "),
+	)
+}
+
+// checkRequired checks that the value of the entry with the given key is set
+// by the user if opts requires it. set reports whether the value is given in
+// the config file, in an environment variable, or with a command-line flag.
+func checkRequired(set bool, key string, opts plugin.EntryOptions) error {
+	if !opts.Required || set {
+		return nil
+	}
+
+	return fmt.Errorf("%w: missing required value for %q", ErrInvalidConfig, key)
+}
+
+// checkString checks that the string value x satisfies the constraints in
+// opts. An empty string means that the value is not set, and it is not
+// checked.
+func checkString(x, key string, opts plugin.EntryOptions) error {
+	if x == "" {
+		return nil
+	}
+
+	if opts.Pattern != "" {
+		// The pattern is checked when the manifest is loaded.
+		re, err := regexp.Compile(opts.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: invalid pattern for %q: %w", ErrInvalidConfig, key, err)
+		}
+
+		if !re.MatchString(x) {
+			return fmt.Errorf(
+				"%w: invalid value %q for %q, must match %s",
+				ErrInvalidConfig,
+				x,
+				key,
+				strconv.Quote(opts.Pattern),
+			)
+		}
+	}
+
+	if len(opts.OneOf) == 0 {
+		return nil
+	}
+
+	allowed := make([]string, 0, len(opts.OneOf))
+
+	for _, v := range opts.OneOf {
+		if s, ok := v.(string); ok {
+			allowed = append(allowed, s)
+		}
+	}
+
+	return checkEnum(x, key, allowed)
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// deprecationWarning returns the warning that is given when the user sets
+// the deprecated value name. replacement is the name of the value that
+// replaces it, or an empty string if there is none.
+func deprecationWarning(name, replacement string, opts plugin.EntryOptions) string {
+	msg := name + " is deprecated"
+
+	if replacement != "" {
+		msg += ", use " + replacement + " instead"
+	}
+
+	if opts.Deprecated != "" {
+		msg += ": " + opts.Deprecated
+	}
+
+	return msg
+}
+
+// pluginValueName returns the name of the plugin config value that the user
+// has set from the given source. The name is used in the messages to the user.
+func pluginValueName(source Source, entry *api.ConfigEntry, idents []string) string {
+	switch source { //nolint:exhaustive // the other sources use the config key
+	case SourceFlag:
+		if flagName := pluginFlagName(idents, entry); flagName != "" {
+			return "--" + flagName
+		}
+	case SourceEnv:
+		if !entry.FlagOnly {
+			return pluginEnvName(idents, entry)
+		}
+	}
+
+	return strconv.Quote(configKey(idents))
+}
+
+// replaceDeprecated gives the values of the deprecated entries in kvs to their
+// replacements. A value is replaced only if the user has set the deprecated
+// value but not the replacement. set tells which values the user has set by
+// the keys of the entries. The replaced values are checked against
+// the validation constraints of the replacements.
+func replaceDeprecated(kvs api.KeyValues, set map[string]bool, options map[string]plugin.EntryOptions) error {
+	for key, opts := range options {
+		if opts.ReplacedBy == "" || !set[key] || set[opts.ReplacedBy] {
+			continue
+		}
+
+		i := slices.IndexFunc(kvs, func(kv api.KeyVal) bool { return kv.Key == key })
+		j := slices.IndexFunc(kvs, func(kv api.KeyVal) bool { return kv.Key == opts.ReplacedBy })
+
+		if i < 0 || j < 0 {
+			continue
+		}
+
+		kvs[j].Value = kvs[i].Value
+
+		if err := checkConstraints(kvs[j], kvs[j].Key, options[kvs[j].Key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// warnDeprecatedPlugin gives the warning for the deprecated plugin config entry
+// that the user has set from the given source. entries are the config entries
+// that the entry is defined with.
+func warnDeprecatedPlugin(
+	source Source,
+	entries []api.ConfigEntry,
+	entry *api.ConfigEntry,
+	entryOpts plugin.EntryOptions,
+	opts ApplyOptions,
+) {
+	if opts.Report == nil {
+		return
+	}
+
+	replacement := ""
+
+	if i := slices.IndexFunc(entries, func(e api.ConfigEntry) bool { return e.Key == entryOpts.ReplacedBy }); i >= 0 {
+		idents := append(slices.Clone(opts.idents[:len(opts.idents)-1]), entryOpts.ReplacedBy)
+		replacement = pluginValueName(source, &entries[i], idents)
+	}
+
+	name := pluginValueName(source, entry, opts.idents)
+	if source == SourceFile {
+		name = "Config key " + name
+	}
+
+	opts.Report(diag.Deprecated, deprecationWarning(name, replacement, entryOpts))
+}
+
+// warnDeprecatedTask gives the warning for the deprecated task config entry
+// with the given key that the user has set for a task of type ttName.
+func warnDeprecatedTask(key, ttName string, entryOpts plugin.EntryOptions, opts TaskApplyOptions) {
+	if opts.Report == nil {
+		return
+	}
+
+	replacement := ""
+	if entryOpts.ReplacedBy != "" {
+		replacement = strconv.Quote(entryOpts.ReplacedBy)
+	}
+
+	opts.Report(diag.Deprecated, deprecationWarning(fmt.Sprintf("Option %q of task type %q", key, ttName), replacement, entryOpts))
+}
@@ -0,0 +1,199 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/secret"
+)
+
+// EncryptedKey is the key of the encrypted block in the config file.
+const EncryptedKey = "encrypted"
+
+// Errors returned when handling the encrypted block.
+var (
+	errNoEncrypted = errors.New("no encrypted block")
+	errNoKeyFile   = errors.New("key file not found")
+)
+
+// encryptedLine matches the line that sets the encrypted block in the config
+// file.
+var encryptedLine = regexp.MustCompile(`^\s*` + EncryptedKey + `\s*=`) //nolint:gochecknoglobals // used like a constant
+
+// EncryptedBlock returns the encrypted block from the config file at path. It
+// returns an error if the file has no encrypted block.
+func EncryptedBlock(path fspath.Path) (string, error) {
+	data, err := os.ReadFile(string(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file at %q: %w", path, err)
+	}
+
+	rawCfg := make(map[string]any)
+
+	if err = toml.Unmarshal(data, &rawCfg); err != nil {
+		return "", fmt.Errorf("failed to decode the config file at %q: %w", path, err)
+	}
+
+	raw, ok := rawCfg[EncryptedKey]
+	if !ok {
+		return "", fmt.Errorf("%w in %q", errNoEncrypted, path)
+	}
+
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not a string: %[3]v (%[3]T)", ErrInvalidConfig, EncryptedKey, raw)
+	}
+
+	return value, nil
+}
+
+// ResolveKeyFile resolves the path to the key file for the encrypted block in
+// the config file. The path is read, in the order of precedence, from
+// the command-line flag, the environment variable, and the raw config values
+// from the config file. If none of them is set, the given default is used.
+func ResolveKeyFile(rawCfg map[string]any, def fspath.Path, flagSet *flags.FlagSet) (fspath.Path, error) {
+	var err error
+
+	path := def
+
+	if s, ok := rawCfg["key-file"].(string); ok && s != "" {
+		path = fspath.Path(s)
+	}
+
+	if env := os.Getenv(strings.ToUpper(filename + "_KEY_FILE")); env != "" {
+		path = fspath.Path(env)
+	}
+
+	flagName := FlagName("KeyFile")
+	if flagSet != nil && flagSet.Changed(flagName) {
+		path, err = flagSet.GetPath(flagName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the value for command-line option --%s: %w", flagName, err)
+		}
+	}
+
+	path, err = path.Abs()
+	if err != nil {
+		return "", fmt.Errorf("failed to make key file path absolute: %w", err)
+	}
+
+	return path, nil
+}
+
+// decryptBlock decrypts the encrypted block in the raw config values using
+// the key from the given key file. The decrypted block is a TOML document and
+// its values are merged into rawCfg so that the values in the encrypted block
+// take precedence. The encrypted block is removed from rawCfg. If there is no
+// encrypted block, decryptBlock does nothing.
+func decryptBlock(rawCfg map[string]any, keyFile fspath.Path) error {
+	raw, ok := rawCfg[EncryptedKey]
+	if !ok {
+		return nil
+	}
+
+	delete(rawCfg, EncryptedKey)
+
+	value, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("%w: %q is not a string: %[3]v (%[3]T)", ErrInvalidConfig, EncryptedKey, raw)
+	}
+
+	ok, err := keyFile.IsFile()
+	if err != nil {
+		return fmt.Errorf("failed to check key file %q: %w", keyFile, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: config has an encrypted block but there is no key file at %q", errNoKeyFile, keyFile)
+	}
+
+	key, err := secret.ReadKey(string(keyFile))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	data, err := secret.Decrypt(key, value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the encrypted block: %w", err)
+	}
+
+	decrypted := make(map[string]any)
+
+	if err = toml.Unmarshal(data, &decrypted); err != nil {
+		return fmt.Errorf("failed to decode the encrypted block: %w", err)
+	}
+
+	mergeMaps(rawCfg, decrypted)
+
+	return nil
+}
+
+// SetEncryptedBlock returns the contents of a TOML config file with
+// the encrypted block set to value. If the file already has an encrypted
+// block, its line is replaced. Otherwise, the block is added to the beginning
+// of the file so that it is not inside of a table.
+func SetEncryptedBlock(data []byte, value string) []byte {
+	line := EncryptedKey + " = " + strconv.Quote(value)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "[") {
+			break
+		}
+
+		if encryptedLine.MatchString(l) {
+			lines[i] = line
+
+			if strings.HasSuffix(l, "\n") {
+				lines[i] += "\n"
+			}
+
+			return []byte(strings.Join(lines, ""))
+		}
+	}
+
+	return []byte(line + "\n\n" + string(data))
+}
+
+// mergeMaps merges the values from src into dst recursively. The values from
+// src take precedence, but nested maps are merged instead of being replaced.
+func mergeMaps(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]any)
+		if !ok {
+			dst[k] = v
+
+			continue
+		}
+
+		dstMap, ok := dst[k].(map[string]any)
+		if !ok {
+			dst[k] = srcMap
+
+			continue
+		}
+
+		mergeMaps(dstMap, srcMap)
+	}
+}
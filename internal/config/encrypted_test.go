@@ -0,0 +1,62 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+)
+
+func TestSetEncryptedBlock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			"Empty file",
+			"",
+			"encrypted = \"new\"\n\n",
+		},
+		{
+			"No block",
+			"[[tasks]]\ntype = \"link/create\"\n",
+			"encrypted = \"new\"\n\n[[tasks]]\ntype = \"link/create\"\n",
+		},
+		{
+			"Existing block",
+			"# comment\nencrypted = \"old\"\n\n[[tasks]]\n",
+			"# comment\nencrypted = \"new\"\n\n[[tasks]]\n",
+		},
+		{
+			"Key in table",
+			"[plugin]\nencrypted = \"plugin value\"\n",
+			"encrypted = \"new\"\n\n[plugin]\nencrypted = \"plugin value\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := string(config.SetEncryptedBlock([]byte(tt.data), "new")); got != tt.want {
+				t.Errorf("SetEncryptedBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
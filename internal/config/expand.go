@@ -0,0 +1,168 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandVars replaces the references to environment variables in s with their
+// values. The references may be written as "$VAR" or "${VAR}", and
+// "${VAR:-default}" is replaced by default if the variable is unset or empty.
+// The default may contain references, too. References to undefined variables
+// are replaced by an empty string. A "$$" is replaced by a single "$" so that
+// a value can opt out of the expansion by escaping the dollar sign.
+func ExpandVars(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+
+			i++
+		case next == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated variable reference in %q", ErrInvalidConfig, s)
+			}
+
+			value, err := expandBraced(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(value)
+
+			i = end
+		case isNameStart(next):
+			j := i + 2
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+
+			sb.WriteString(os.Getenv(s[i+1 : j]))
+
+			i = j - 1
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// expandBraced returns the value for the content of a "${...}" reference.
+func expandBraced(ref string) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+
+	if name == "" || !isNameStart(name[0]) || strings.IndexFunc(name, func(r rune) bool {
+		return r > 0x7f || !isNameChar(byte(r))
+	}) >= 0 {
+		return "", fmt.Errorf("%w: invalid variable name in \"${%s}\"", ErrInvalidConfig, ref)
+	}
+
+	if value := os.Getenv(name); value != "" || !hasDefault {
+		return value, nil
+	}
+
+	return ExpandVars(def)
+}
+
+// expandRaw expands the environment variable references in all of the string
+// values in the given raw config value recursively. It returns the expanded
+// value.
+func expandRaw(raw any) (any, error) {
+	switch v := raw.(type) {
+	case string:
+		return ExpandVars(v)
+	case map[string]any:
+		for k, x := range v {
+			expanded, err := expandRaw(x)
+			if err != nil {
+				return nil, fmt.Errorf("%w (in %q)", err, k)
+			}
+
+			v[k] = expanded
+		}
+
+		return v, nil
+	case []any:
+		for i, x := range v {
+			expanded, err := expandRaw(x)
+			if err != nil {
+				return nil, err
+			}
+
+			v[i] = expanded
+		}
+
+		return v, nil
+	case []map[string]any:
+		for _, m := range v {
+			if _, err := expandRaw(m); err != nil {
+				return nil, err
+			}
+		}
+
+		return v, nil
+	default:
+		return raw, nil
+	}
+}
+
+// closingBrace returns the index of the brace that closes the reference that
+// starts at i, taking the nested references in the default value into
+// account. It returns -1 if the reference is not closed.
+func closingBrace(s string, i int) int {
+	depth := 0
+
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+
+			depth--
+		}
+	}
+
+	return -1
+}
+
+// isNameStart reports whether c may start an environment variable name.
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// isNameChar reports whether c may be a part of an environment variable name.
+func isNameChar(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
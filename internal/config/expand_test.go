@@ -0,0 +1,64 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+)
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("REGINALD_TEST_HOME", "/home/test")
+	t.Setenv("REGINALD_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{"No references", "plain/path", "plain/path", false},
+		{"Bare reference", "$REGINALD_TEST_HOME/.config", "/home/test/.config", false},
+		{"Braced reference", "${REGINALD_TEST_HOME}rc", "/home/testrc", false},
+		{"Undefined", "${REGINALD_TEST_UNDEFINED}/x", "/x", false},
+		{"Default unset", "${REGINALD_TEST_UNDEFINED:-/etc}/x", "/etc/x", false},
+		{"Default empty", "${REGINALD_TEST_EMPTY:-fallback}", "fallback", false},
+		{"Default not used", "${REGINALD_TEST_HOME:-/etc}", "/home/test", false},
+		{"Nested default", "${REGINALD_TEST_UNDEFINED:-${REGINALD_TEST_HOME}/x}", "/home/test/x", false},
+		{"Escaped", "$${REGINALD_TEST_HOME} costs $$5", "${REGINALD_TEST_HOME} costs $5", false},
+		{"Lone dollar", "a $ b$", "a $ b$", false},
+		{"Unterminated", "${REGINALD_TEST_HOME", "", true},
+		{"Invalid name", "${1ABC}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.ExpandVars(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandVars(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, config.ErrInvalidConfig) {
+				t.Errorf("ExpandVars(%q) error = %v, want wrapping ErrInvalidConfig", tt.s, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ExpandVars(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,615 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// Sources of the values that are considered when a config value is resolved.
+const (
+	SourceDefault  Source = "default"  // built-in default value
+	SourceDefaults Source = "defaults" // task defaults in the config file
+	SourceFile     Source = "file"     // value in the config file
+	SourceEnv      Source = "env"      // environment variable
+	SourceFlag     Source = "flag"     // command-line flag
+)
+
+// tasksKey is the config key for the tasks.
+const tasksKey = "tasks"
+
+// Source is the source of a value that is considered when a config value is
+// resolved.
+type Source string
+
+// A Candidate is a value that was considered when a config value was resolved.
+type Candidate struct {
+	Source Source `json:"source"`           // kind of the source of the value
+	Origin string `json:"origin,omitempty"` // position in the config file, environment variable, or flag
+	Value  string `json:"value"`            // value formatted for printing
+}
+
+// An Explanation describes how a config value was resolved.
+type Explanation struct {
+	// Key is the config key that is explained.
+	Key string `json:"key"`
+
+	// Value is the resolved value formatted for printing.
+	Value string `json:"value"`
+
+	// Candidates are the values that were considered for the key in the order
+	// of increasing precedence.
+	Candidates []Candidate `json:"candidates"`
+
+	// Winner is the index of the candidate whose value was used.
+	Winner int `json:"winner"`
+}
+
+// explainFile is the config file as it is read for the explanations.
+type explainFile struct {
+	path      fspath.Path
+	raw       map[string]any
+	positions map[string]unstable.Position
+	origins   map[string]fragmentOrigin
+}
+
+// Explain returns explanations of how the value for the given config key was
+// resolved. An explanation lists the values that were considered for the key,
+// the default value, the value in the config file and its line, the environment
+// variable, and the command-line flag, and tells which of them was used.
+//
+// The key may be a key of a config value of Reginald, like "logging.level",
+// a key of a config value of a plugin, like "link.create-dirs" or
+// "plugins.link.create-dirs", or a task given as "tasks.<id>" or
+// "tasks.<id>.<key>". For a task, the function returns one explanation per task
+// option.
+//
+// The cfg must be the config that was parsed using the flag set in opts.
+// The values in the config file are shown as they are written in it. The plugin
+// config values and the tasks can be explained only if opts contains the plugin
+// store.
+func Explain(cfg *Config, key string, opts ApplyOptions) ([]Explanation, error) {
+	opts = initIdents(opts)
+
+	file, err := readExplainFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if rest, ok := strings.CutPrefix(key, tasksKey+"."); ok && opts.Store != nil {
+		return explainTask(file, rest, opts)
+	}
+
+	path := strings.Split(key, ".")
+	for i, part := range path {
+		path[i] = CanonicalKey(part)
+	}
+
+	if names, ok := fieldNames(path); ok {
+		return []Explanation{explainField(cfg, file, path, names, opts)}, nil
+	}
+
+	// The config values of the plugins may also be given with their keys in
+	// the "plugins" table.
+	if len(path) > 2 && path[0] == pluginsKey { //nolint:mnd // the table, the domain, and the key
+		path = path[1:]
+	}
+
+	if opts.Store != nil {
+		if entry := findPluginEntry(opts.Store, path); entry != nil {
+			return []Explanation{explainPluginEntry(file, path, entry, opts)}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unknown config key %q", ErrInvalidConfig, key)
+}
+
+// add adds a candidate to e and marks it as the one that was used as it has
+// higher precedence than the previously added candidates.
+func (e *Explanation) add(source Source, origin string, value any) {
+	e.Candidates = append(e.Candidates, Candidate{
+		Source: source,
+		Origin: origin,
+		Value:  formatValue(value),
+	})
+	e.Winner = len(e.Candidates) - 1
+}
+
+// lookup returns the raw value for the given key path in the config file and
+// the position of the key in the file. It reports whether the key was found.
+func (f *explainFile) lookup(path []string) (any, string, bool) {
+	var v any = f.raw
+
+	for _, part := range path {
+		switch x := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = lookupKey(x, part); !ok {
+				return nil, "", false
+			}
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, "", false
+			}
+
+			v = x[i]
+		case []map[string]any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, "", false
+			}
+
+			v = x[i]
+		default:
+			return nil, "", false
+		}
+	}
+
+	normalized := make([]string, len(path))
+
+	for i, part := range path {
+		normalized[i] = CanonicalKey(part)
+	}
+
+	key := strings.Join(normalized, ".")
+	file, positions := f.path, f.positions
+
+	if frag, fragmentKey, ok := fragmentOf(f.origins, key); ok {
+		file, positions, key = frag.path, frag.positions, fragmentKey
+	}
+
+	origin := string(file)
+
+	if pos, ok := lookupPosition(positions, key); ok {
+		origin = fmt.Sprintf("%s:%d:%d", file, pos.Line, pos.Column)
+	}
+
+	return v, origin, true
+}
+
+// explainField explains how the config value of Reginald with the given key
+// path and the names of the Config fields for it was resolved.
+func explainField(cfg *Config, file *explainFile, path, names []string, opts ApplyOptions) Explanation {
+	e := Explanation{
+		Key:        strings.Join(path, "."),
+		Value:      formatValue(fieldValue(cfg, names)),
+		Candidates: nil,
+		Winner:     0,
+	}
+
+	e.add(SourceDefault, "", fieldValue(DefaultConfig(), names))
+
+	if raw, origin, ok := file.lookup(path); ok {
+		e.add(SourceFile, origin, resolveOSValue(raw))
+	}
+
+	idents := slices.Concat(opts.idents, names)
+
+	if name := envName(idents); os.Getenv(name) != "" {
+		e.add(SourceEnv, name, os.Getenv(name))
+	}
+
+	if opts.FlagSet == nil {
+		return e
+	}
+
+	field := strings.Join(names, ".")
+
+	if f := opts.FlagSet.Lookup(FlagName(field)); f != nil && f.Changed {
+		e.add(SourceFlag, "--"+f.Name, f.Value.String())
+	}
+
+	if HasInvertedFlagName(field) {
+		if f := opts.FlagSet.Lookup(InvertedFlagName(field)); f != nil && f.Changed {
+			x, err := strconv.ParseBool(f.Value.String())
+			if err == nil {
+				e.add(SourceFlag, "--"+f.Name, !x)
+			}
+		}
+	}
+
+	return e
+}
+
+// explainPluginEntry explains how the config value of a plugin with the given
+// key path and config entry was resolved.
+func explainPluginEntry(file *explainFile, path []string, entry *api.ConfigEntry, opts ApplyOptions) Explanation {
+	e := Explanation{
+		Key:        strings.Join(path, "."),
+		Value:      "",
+		Candidates: nil,
+		Winner:     0,
+	}
+
+	e.add(SourceDefault, "", entry.Val)
+
+	idents := slices.Concat(opts.idents, path)
+
+	if !entry.FlagOnly {
+		if raw, origin, ok := file.lookup(path); ok {
+			e.add(SourceFile, origin, resolveOSValue(raw))
+		} else if raw, origin, ok = file.lookup(append([]string{pluginsKey}, path...)); ok {
+			e.add(SourceFile, origin, resolveOSValue(raw))
+		}
+
+		if name := pluginEnvName(idents, entry); os.Getenv(name) != "" {
+			e.add(SourceEnv, name, os.Getenv(name))
+		}
+	}
+
+	if name := pluginFlagName(idents, entry); name != "" && opts.FlagSet != nil {
+		if f := opts.FlagSet.Lookup(name); f != nil && f.Changed {
+			e.add(SourceFlag, "--"+f.Name, f.Value.String())
+		}
+
+		if inverted := opts.FlagSet.InvertedFlag(name); inverted != "" {
+			if f := opts.FlagSet.Lookup(inverted); f != nil && f.Changed {
+				x, err := strconv.ParseBool(f.Value.String())
+				if err == nil {
+					e.add(SourceFlag, "--"+f.Name, !x)
+				}
+			}
+		}
+	}
+
+	e.Value = e.Candidates[e.Winner].Value
+
+	return e
+}
+
+// explainTask explains how the options of the task given as "<id>" or
+// "<id>.<key>" were resolved.
+func explainTask(file *explainFile, s string, opts ApplyOptions) ([]Explanation, error) {
+	rawTasks, _, _ := file.lookup([]string{tasksKey})
+	entries := typedTasks(rawTasks)
+	counts := make(map[string]int)
+
+	for i, entry := range entries {
+		taskType, _ := entry["type"].(string)
+
+		id, ok := entry["id"].(string)
+		if !ok {
+			id = taskType + "-" + strconv.Itoa(counts[taskType])
+		}
+
+		counts[taskType]++
+
+		task := opts.Store.Task(taskType)
+		single := strings.HasPrefix(s, id+".")
+
+		var keys []string
+
+		switch {
+		case s == id:
+			keys = taskKeys(task, entry, file, taskType)
+		case single:
+			keys = []string{CanonicalKey(strings.TrimPrefix(s, id+"."))}
+		default:
+			continue
+		}
+
+		result := make([]Explanation, 0, len(keys))
+
+		for _, key := range keys {
+			e := Explanation{
+				Key:        tasksKey + "." + id + "." + key,
+				Value:      "",
+				Candidates: nil,
+				Winner:     0,
+			}
+
+			if task != nil {
+				if v, ok := taskDefault(task.Config, key); ok {
+					e.add(SourceDefault, "", v)
+				}
+			}
+
+			if raw, origin, ok := file.lookup([]string{"defaults", taskType, key}); ok {
+				e.add(SourceDefaults, origin, resolveOSValue(raw))
+			}
+
+			if raw, origin, ok := file.lookup([]string{tasksKey, strconv.Itoa(i), key}); ok {
+				e.add(SourceFile, origin, resolveOSValue(raw))
+			}
+
+			if len(e.Candidates) == 0 {
+				if single {
+					return nil, fmt.Errorf("%w: unknown option %q for task %q", ErrInvalidConfig, key, id)
+				}
+
+				continue
+			}
+
+			e.Value = e.Candidates[e.Winner].Value
+			result = append(result, e)
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown task %q", ErrInvalidConfig, s)
+}
+
+// fieldByTag returns the field in the struct type typ that has the given name
+// in its "mapstructure" tag.
+func fieldByTag(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+
+		tag, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if tag != "" && tag != "-" && tag == name {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// fieldNames returns the names of the Config fields for the given config key
+// path. It reports whether the key path is a config value of Reginald that can
+// be explained.
+func fieldNames(path []string) ([]string, bool) {
+	typ := reflect.TypeFor[Config]()
+	names := make([]string, 0, len(path))
+
+	for _, part := range path {
+		if typ.Kind() != reflect.Struct {
+			return nil, false
+		}
+
+		f, ok := fieldByTag(typ, part)
+		if !ok {
+			return nil, false
+		}
+
+		// The directory is resolved before the other values but it has
+		// the same sources as the static fields.
+		if len(names) == 0 && f.Name != "Directory" && slices.Contains(dynamicFields, f.Name) {
+			return nil, false
+		}
+
+		names = append(names, f.Name)
+		typ = f.Type
+	}
+
+	if typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map {
+		return nil, false
+	}
+
+	return names, true
+}
+
+// fieldValue returns the value of the field in cfg with the given names of
+// the fields that lead to it.
+func fieldValue(cfg *Config, names []string) any {
+	v := reflect.ValueOf(cfg).Elem()
+
+	for _, name := range names {
+		v = v.FieldByName(name)
+	}
+
+	return v.Interface()
+}
+
+// findPluginEntry returns the config entry of a plugin or a plugin command for
+// the given key path. It returns nil if there is no such entry.
+func findPluginEntry(store *plugin.Store, path []string) *api.ConfigEntry {
+	if len(path) < 2 { //nolint:mnd // the domain and the key
+		return nil
+	}
+
+	for cmd := range store.CommandsFor(nil) {
+		entries := cmd.Entries()
+
+		if configDomain(cmd) != path[0] {
+			continue
+		}
+
+		cmds := cmd.Commands
+
+		for _, name := range path[1 : len(path)-1] {
+			i := slices.IndexFunc(cmds, func(c *plugin.Command) bool { return c.Name == name })
+			if i == -1 {
+				return nil
+			}
+
+			entries = cmds[i].Entries()
+			cmds = cmds[i].Commands
+		}
+
+		for i := range entries {
+			if entries[i].Key == path[len(path)-1] {
+				return &entries[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatValue formats a config value for printing.
+func formatValue(v any) string {
+	s := fmt.Sprint(v)
+	if s == "" {
+		return `""`
+	}
+
+	return s
+}
+
+// readExplainFile decodes the config file that cfg was parsed from and merges
+// the config fragments to it for the explanations. If cfg was not parsed from
+// a file, it returns an empty file.
+func readExplainFile(cfg *Config) (*explainFile, error) {
+	file := &explainFile{
+		path:      cfg.configFile,
+		raw:       nil,
+		positions: cfg.positions,
+		origins:   make(map[string]fragmentOrigin),
+	}
+
+	if cfg.fileData == nil {
+		return file, nil
+	}
+
+	if err := toml.Unmarshal(cfg.fileData, &file.raw); err != nil {
+		return nil, fmt.Errorf("failed to decode the config file at %q: %w", file.path, err)
+	}
+
+	NormalizeKeys(file.raw)
+
+	if _, err := expandRaw(file.raw); err != nil {
+		return nil, fmt.Errorf("failed to expand the environment variables in the config file at %q: %w", file.path, err)
+	}
+
+	fragments, err := readFragments(file.path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fragments {
+		mergeFragment(file.raw, f, file.origins)
+	}
+
+	return file, nil
+}
+
+// resolveOSValue returns the value for the current platform if raw is given as
+// a map that contains different values for different OSes. Otherwise, it
+// returns raw as is.
+func resolveOSValue(raw any) any {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return raw
+	}
+
+	v, err := fromOSDecodeHookFunc()(reflect.TypeOf(m), reflect.TypeFor[string](), m)
+	if err != nil {
+		return raw
+	}
+
+	return v
+}
+
+// taskDefault returns the default value of the task option with the given key
+// from the config types of a task type. It reports whether the option has
+// a default value.
+func taskDefault(configs []api.ConfigType, key string) (any, bool) {
+	for _, c := range configs {
+		switch typed := c.(type) {
+		case api.ConfigValue:
+			if typed.Key == key {
+				return typed.Val, true
+			}
+		case api.UnionValue:
+			if v, ok := taskDefault(typed.Alternatives, key); ok {
+				return v, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// taskKeys returns the keys of the options of a task that are explained for
+// the task. The options of the task type are listed first, followed by
+// the other keys from the task defaults and the task entry.
+func taskKeys(task *plugin.Task, entry map[string]any, file *explainFile, taskType string) []string {
+	var keys []string
+
+	add := func(k string) {
+		k = CanonicalKey(k)
+		if !slices.Contains(keys, k) && !slices.Contains(reservedTaskKeys, k) {
+			keys = append(keys, k)
+		}
+	}
+
+	if task != nil {
+		for _, k := range taskConfigKeys(task.Config) {
+			add(k)
+		}
+	}
+
+	var extra []string
+
+	defaults, _, _ := file.lookup([]string{"defaults", taskType})
+	if m, ok := defaults.(map[string]any); ok {
+		for k := range m {
+			extra = append(extra, k)
+		}
+	}
+
+	for k := range entry {
+		extra = append(extra, k)
+	}
+
+	slices.Sort(extra)
+
+	for _, k := range extra {
+		add(k)
+	}
+
+	return keys
+}
+
+// taskConfigKeys returns the keys of the given config types of a task type.
+func taskConfigKeys(configs []api.ConfigType) []string {
+	var keys []string
+
+	for _, c := range configs {
+		switch typed := c.(type) {
+		case api.ConfigValue:
+			keys = append(keys, typed.Key)
+		case api.MappedValue:
+			keys = append(keys, typed.Key)
+		case api.UnionValue:
+			keys = append(keys, taskConfigKeys(typed.Alternatives)...)
+		}
+	}
+
+	return keys
+}
+
+// typedTasks returns the raw task entries from the config file as maps. The
+// entries that are not maps are returned as nil so that the indices of
+// the entries match the file.
+func typedTasks(raw any) []map[string]any {
+	switch x := raw.(type) {
+	case []map[string]any:
+		return x
+	case []any:
+		result := make([]map[string]any, len(x))
+
+		for i, v := range x {
+			result[i], _ = v.(map[string]any)
+		}
+
+		return result
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/spf13/pflag"
+)
+
+func TestExplain(t *testing.T) {
+	const data = `verbose = true
+
+[logging]
+level = "debug"
+`
+
+	path := filepath.Join(t.TempDir(), "reginald.toml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		args        []string
+		env         map[string]string
+		want        string
+		wantSources []config.Source
+		wantOrigin  string
+		wantErr     bool
+	}{
+		{"Default", "quiet", nil, nil, "false", []config.Source{config.SourceDefault}, "", false},
+		{
+			"File",
+			"verbose",
+			nil,
+			nil,
+			"true",
+			[]config.Source{config.SourceDefault, config.SourceFile},
+			path + ":1:1",
+			false,
+		},
+		{
+			"Table",
+			"logging.level",
+			nil,
+			nil,
+			"DEBUG",
+			[]config.Source{config.SourceDefault, config.SourceFile},
+			path + ":4:1",
+			false,
+		},
+		{
+			"Env",
+			"logging.level",
+			nil,
+			map[string]string{"REGINALD_LOGGING_LEVEL": "warn"},
+			"WARN",
+			[]config.Source{config.SourceDefault, config.SourceFile, config.SourceEnv},
+			"REGINALD_LOGGING_LEVEL",
+			false,
+		},
+		{
+			"Flag",
+			"verbose",
+			[]string{"--verbose=false"},
+			map[string]string{"REGINALD_VERBOSE": "true"},
+			"false",
+			[]config.Source{config.SourceDefault, config.SourceFile, config.SourceEnv, config.SourceFlag},
+			"--verbose",
+			false,
+		},
+		{
+			"Camel case",
+			"logging.traceRpc",
+			nil,
+			nil,
+			"false",
+			[]config.Source{config.SourceDefault},
+			"",
+			false,
+		},
+		{
+			"Snake case",
+			"logging.trace_rpc",
+			nil,
+			nil,
+			"false",
+			[]config.Source{config.SourceDefault},
+			"",
+			false,
+		},
+		{
+			"Env initialism",
+			"logging.trace-rpc",
+			nil,
+			map[string]string{"REGINALD_LOGGING_TRACE_RPC": "true"},
+			"true",
+			[]config.Source{config.SourceDefault, config.SourceEnv},
+			"REGINALD_LOGGING_TRACE_RPC",
+			false,
+		},
+		{
+			"Env CI",
+			"ci",
+			nil,
+			map[string]string{"REGINALD_CI": "true"},
+			"true",
+			[]config.Source{config.SourceDefault, config.SourceEnv},
+			"REGINALD_CI",
+			false,
+		},
+		{
+			"Env string list",
+			"plugin-indexes",
+			nil,
+			map[string]string{"REGINALD_PLUGIN_INDEXES": `https://example.com/index.json,'b,c'`},
+			"[https://example.com/index.json b,c]",
+			[]config.Source{config.SourceDefault, config.SourceEnv},
+			"REGINALD_PLUGIN_INDEXES",
+			false,
+		},
+		{"Empty path", "events-socket", nil, nil, `""`, []config.Source{config.SourceDefault}, "", false},
+		{"Unknown", "unknown", nil, nil, "", nil, "", true},
+		{"Table key", "logging", nil, nil, "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			flagSet := flags.NewFlagSet("test", pflag.ContinueOnError)
+			flagSet.String("config", "", "", "")
+			flagSet.Bool("verbose", false, "", "")
+
+			if err := flagSet.Parse(append([]string{"--config", path}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := config.Parse(t.Context(), flagSet)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			opts := config.ApplyOptions{
+				Dir:     cfg.Directory,
+				FlagSet: flagSet,
+				Store:   nil,
+			}
+
+			got, err := config.Explain(cfg, tt.key, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Explain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("Explain() returned %d explanations, want 1", len(got))
+			}
+
+			e := got[0]
+			if e.Value != tt.want {
+				t.Errorf("Explain() value = %q, want %q", e.Value, tt.want)
+			}
+
+			sources := make([]config.Source, len(e.Candidates))
+			for i, c := range e.Candidates {
+				sources[i] = c.Source
+			}
+
+			if !slices.Equal(sources, tt.wantSources) {
+				t.Errorf("Explain() sources = %v, want %v", sources, tt.wantSources)
+			}
+
+			if e.Winner != len(e.Candidates)-1 {
+				t.Errorf("Explain() winner = %d, want %d", e.Winner, len(e.Candidates)-1)
+			}
+
+			if origin := e.Candidates[e.Winner].Origin; origin != tt.wantOrigin {
+				t.Errorf("Explain() origin = %q, want %q", origin, tt.wantOrigin)
+			}
+		})
+	}
+}
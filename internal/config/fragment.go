@@ -0,0 +1,210 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// fragmentDir is the name of the drop-in directory next to the config file
+// that contains the config fragments.
+const fragmentDir = filename + ".d"
+
+// errEncryptedFragment is returned when a config fragment contains
+// an encrypted block.
+var errEncryptedFragment = errors.New("encrypted block in a config fragment")
+
+// A fragment is a config fragment from the drop-in directory. The fragments
+// are merged to the config file in the lexical order of their names before
+// the config is decoded.
+type fragment struct {
+	// path is the path to the fragment file.
+	path fspath.Path
+
+	// raw is the decoded content of the fragment with the keys normalized and
+	// the environment variables expanded.
+	raw map[string]any
+
+	// data is the content of the fragment file. It is used for showing
+	// the snippets of the file in the errors.
+	data []byte
+
+	// positions contains the positions of the keys in the fragment file by
+	// their dotted key paths.
+	positions map[string]unstable.Position
+}
+
+// A fragmentOrigin tells which fragment a value in the merged config comes
+// from.
+type fragmentOrigin struct {
+	fragment *fragment
+
+	// key is the dotted key path of the value in the fragment. It differs from
+	// the key path in the merged config for the tasks as the tasks of
+	// the fragments are appended to the tasks of the config file.
+	key string
+}
+
+// fragmentOf returns the fragment that the value with the given dotted key
+// path comes from according to origins and the key path of the value in
+// the fragment. It reports whether the value comes from a fragment.
+func fragmentOf(origins map[string]fragmentOrigin, key string) (*fragment, string, bool) {
+	parts := strings.Split(key, ".")
+
+	for i := len(parts); i > 0; i-- {
+		prefix := strings.Join(parts[:i], ".")
+
+		if o, ok := origins[prefix]; ok {
+			return o.fragment, o.key + key[len(prefix):], true
+		}
+	}
+
+	return nil, "", false
+}
+
+// mergeFragment merges the fragment f to raw. The tables are merged key by key,
+// the tasks are appended to the tasks in raw, and the other values replace
+// the values in raw. The origins of the merged values are recorded to origins
+// by their dotted key paths.
+func mergeFragment(raw map[string]any, f *fragment, origins map[string]fragmentOrigin) {
+	for k, v := range f.raw {
+		if k != tasksKey {
+			mergeValue(raw, k, v, []string{k}, f, origins)
+
+			continue
+		}
+
+		tasks, _ := raw[k].([]any)
+		offset := len(tasks)
+
+		newTasks, ok := v.([]any)
+		if !ok {
+			mergeValue(raw, k, v, []string{k}, f, origins)
+
+			continue
+		}
+
+		for i := range newTasks {
+			origins[tasksKey+"."+strconv.Itoa(offset+i)] = fragmentOrigin{
+				fragment: f,
+				key:      tasksKey + "." + strconv.Itoa(i),
+			}
+		}
+
+		raw[k] = append(tasks, newTasks...)
+	}
+}
+
+// mergeValue merges the value v of the key k to the table m. path is the key
+// path of the value.
+func mergeValue(m map[string]any, k string, v any, path []string, f *fragment, origins map[string]fragmentOrigin) {
+	dst, dstOK := m[k].(map[string]any)
+	src, srcOK := v.(map[string]any)
+
+	if !dstOK || !srcOK {
+		key := strings.Join(path, ".")
+		m[k] = v
+		origins[key] = fragmentOrigin{fragment: f, key: key}
+
+		return
+	}
+
+	for sk, sv := range src {
+		mergeValue(dst, sk, sv, append(slices.Clone(path), sk), f, origins)
+	}
+}
+
+// readFragments reads the config fragments from the drop-in directory next to
+// the given config file. The fragments are returned in the lexical order of
+// their names. If the directory does not exist, readFragments returns no
+// fragments.
+func readFragments(configFile fspath.Path) ([]*fragment, error) {
+	dir := fspath.Join(configFile.Clean().Dir(), fragmentDir)
+
+	files, err := filepath.Glob(filepath.Join(string(dir), "*.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the config fragments in %q: %w", dir, err)
+	}
+
+	// The names are already sorted by filepath.Glob but the order is
+	// guaranteed here as the fragments are merged in it.
+	slices.Sort(files)
+
+	fragments := make([]*fragment, 0, len(files))
+
+	for _, file := range files {
+		f, err := readFragment(fspath.Path(file))
+		if err != nil {
+			return nil, err
+		}
+
+		fragments = append(fragments, f)
+	}
+
+	return fragments, nil
+}
+
+// readFragment reads and decodes the config fragment at path.
+func readFragment(path fspath.Path) (*fragment, error) {
+	data, err := os.ReadFile(string(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config fragment at %q: %w", path, err)
+	}
+
+	raw := make(map[string]any)
+
+	if err = toml.Unmarshal(data, &raw); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column := decodeErr.Position()
+
+			return nil, fmt.Errorf("failed to decode the config fragment: %w", newPositionError(path, data, line, column, err))
+		}
+
+		return nil, fmt.Errorf("failed to decode the config fragment at %q: %w", path, err)
+	}
+
+	positions, err := scanPositions(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the config fragment at %q: %w", path, err)
+	}
+
+	NormalizeKeys(raw)
+
+	if _, ok := raw[EncryptedKey]; ok {
+		return nil, fmt.Errorf("%w: %s", errEncryptedFragment, path)
+	}
+
+	if _, err = expandRaw(raw); err != nil {
+		return nil, fmt.Errorf("failed to expand the environment variables in the config fragment at %q: %w", path, err)
+	}
+
+	return &fragment{
+		path:      path,
+		raw:       raw,
+		data:      data,
+		positions: positions,
+	}, nil
+}
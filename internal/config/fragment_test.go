@@ -0,0 +1,137 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/logger"
+	"github.com/spf13/pflag"
+)
+
+func TestParse_Fragments(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := writeFragmentFiles(t, dir, map[string]string{
+		"reginald.toml":              "verbose = false\nquiet = true\n\n[logging]\nlevel = \"info\"\n\n[[tasks]]\ntype = \"link\"\n",
+		"reginald.d/20-machine.toml": "verbose = true\n\n[logging]\nlevel = \"debug\"\n",
+		"reginald.d/10-base.toml":    "quiet = false\n\n[logging]\nlevel = \"warn\"\n\n[[tasks]]\ntype = \"shell\"\n",
+		"reginald.d/ignored.txt":     "verbose = 3\n",
+	})
+
+	cfg, err := parseWithConfig(t, path)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if !cfg.Verbose {
+		t.Error("Verbose = false, want true")
+	}
+
+	if cfg.Quiet {
+		t.Error("Quiet = true, want false")
+	}
+
+	if cfg.Logging.Level != logger.LevelDebug {
+		t.Errorf("Logging.Level = %v, want debug", cfg.Logging.Level)
+	}
+
+	if len(cfg.RawTasks) != 2 {
+		t.Fatalf("len(RawTasks) = %d, want 2", len(cfg.RawTasks))
+	}
+
+	if cfg.RawTasks[0]["type"] != "link" || cfg.RawTasks[1]["type"] != "shell" {
+		t.Errorf("RawTasks = %v, want the tasks of the config file first", cfg.RawTasks)
+	}
+}
+
+func TestParse_FragmentPositionError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		fragment string
+		wantLine int
+	}{
+		{"Syntax", "verbose = true\nquiet = \n", 2},
+		{"Type", "\nverbose = 3\n", 2},
+		{"Nested", "[logging]\nlevel = \"loud\"\n", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := writeFragmentFiles(t, dir, map[string]string{
+				"reginald.toml":         "[[tasks]]\ntype = \"link\"\n",
+				"reginald.d/extra.toml": tt.fragment,
+			})
+
+			_, err := parseWithConfig(t, path)
+
+			var posErr *config.PositionError
+			if !errors.As(err, &posErr) {
+				t.Fatalf("Parse() error = %v, want PositionError", err)
+			}
+
+			if line, _ := posErr.Position(); line != tt.wantLine {
+				t.Errorf("line = %d, want %d", line, tt.wantLine)
+			}
+
+			if want := filepath.Join(dir, "reginald.d", "extra.toml"); !strings.HasPrefix(posErr.Error(), want) {
+				t.Errorf("Error() = %q, want prefix %q", posErr.Error(), want)
+			}
+		})
+	}
+}
+
+func parseWithConfig(t *testing.T, path string) (*config.Config, error) {
+	t.Helper()
+
+	flagSet := flags.NewFlagSet("test", pflag.ContinueOnError)
+	flagSet.String("config", "", "", "")
+
+	if err := flagSet.Parse([]string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Parse(t.Context(), flagSet)
+}
+
+func writeFragmentFiles(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return filepath.Join(dir, "reginald.toml")
+}
@@ -0,0 +1,221 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// CanonicalKey returns the given config key in the canonical format
+// ("kebab-case"). The keys can be written in the config file in "kebab-case",
+// "snake_case", or "camelCase" so that the files in other formats can use
+// the keys that are idiomatic for those formats. The initialisms are kept as
+// single words, for example "proxyURL" becomes "proxy-url". Keys that start
+// with an uppercase letter or contain other characters than letters, digits,
+// hyphens, and underscores are returned as they are.
+func CanonicalKey(k string) string {
+	runes := []rune(k)
+	if len(runes) == 0 || !unicode.IsLower(runes[0]) {
+		return k
+	}
+
+	for _, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return k
+		}
+	}
+
+	var sb strings.Builder
+
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			sb.WriteRune('-')
+		case unicode.IsUpper(r):
+			prev := runes[i-1]
+			lowerBefore := prev != '-' && prev != '_' && !unicode.IsUpper(prev)
+			lowerAfter := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if lowerBefore || (lowerAfter && unicode.IsUpper(prev)) {
+				sb.WriteRune('-')
+			}
+
+			sb.WriteRune(unicode.ToLower(r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// NormalizeKeys changes the keys in the given raw config map into
+// the canonical format (see [CanonicalKey]). The keys of the tables that
+// Reginald knows are matched against the fields of [Config] so that the keys of
+// the free-form maps, such as the names of environment variables, are left as
+// they are. The keys of the plugin tables in "camelCase" are also changed, and
+// the rest of their keys and the keys of the tasks are matched against
+// the config entries of the plugins when the configs are applied.
+func NormalizeKeys(cfg map[string]any) {
+	if cfg == nil {
+		return
+	}
+
+	normalizeTable(cfg, reflect.TypeFor[Config]())
+}
+
+// canonicalizeKeys changes the keys in raw that have the same canonical format
+// as one of the given known keys to the known key. If the known key is already
+// in raw, the other key is left as it is so that it is reported as unknown.
+func canonicalizeKeys(raw map[string]any, known []string) {
+	for _, k := range slices.Sorted(maps.Keys(raw)) {
+		if slices.Contains(known, k) {
+			continue
+		}
+
+		canonical := CanonicalKey(k)
+
+		i := slices.IndexFunc(known, func(s string) bool { return CanonicalKey(s) == canonical })
+		if i == -1 {
+			continue
+		}
+
+		if _, ok := raw[known[i]]; ok {
+			continue
+		}
+
+		raw[known[i]] = raw[k]
+		delete(raw, k)
+	}
+}
+
+// lookupKey returns the value of the given key from raw. If the key is not in
+// raw as it is, the value of a key that has the same canonical format is
+// returned. It reports whether the key was found.
+func lookupKey(raw map[string]any, key string) (any, bool) {
+	if v, ok := raw[key]; ok {
+		return v, true
+	}
+
+	canonical := CanonicalKey(key)
+
+	for _, k := range slices.Sorted(maps.Keys(raw)) {
+		if CanonicalKey(k) == canonical {
+			return raw[k], true
+		}
+	}
+
+	return nil, false
+}
+
+// normalizeKey returns the given key in the canonical format if it is in
+// "camelCase". It is used for the keys of the plugin tables before the config
+// entries of the plugins are known so that the keys of the free-form maps in
+// "snake_case" are left as they are.
+func normalizeKey(k string) string {
+	if strings.ContainsAny(k, "-_") {
+		return k
+	}
+
+	return CanonicalKey(k)
+}
+
+// normalizePluginKey changes the key k in raw into the canonical format if it
+// is in "camelCase" and normalizes the keys of its value in the same way if it
+// is a table.
+func normalizePluginKey(raw map[string]any, k string) {
+	v := raw[k]
+
+	if key := normalizeKey(k); key != k {
+		if _, ok := raw[key]; !ok {
+			delete(raw, k)
+
+			raw[key] = v
+		}
+	}
+
+	if m, ok := v.(map[string]any); ok {
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			normalizePluginKey(m, key)
+		}
+	}
+}
+
+// normalizeTable changes the keys in raw that match the fields of the given
+// struct type into the canonical format and normalizes the tables of
+// the struct fields recursively. The other keys are normalized as the keys of
+// the plugin tables.
+func normalizeTable(raw map[string]any, typ reflect.Type) {
+	fields := tableFields(typ)
+	canonicalizeKeys(raw, slices.Collect(maps.Keys(fields)))
+
+	for _, k := range slices.Sorted(maps.Keys(raw)) {
+		ft, ok := fields[k]
+		if !ok {
+			normalizePluginKey(raw, k)
+
+			continue
+		}
+
+		m, ok := raw[k].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case isTable(ft):
+			normalizeTable(m, ft)
+		case ft.Kind() == reflect.Map && isTable(ft.Elem()):
+			for _, elem := range m {
+				if em, ok := elem.(map[string]any); ok {
+					normalizeTable(em, ft.Elem())
+				}
+			}
+		}
+	}
+}
+
+// isTable reports whether the given type is a struct that is decoded from
+// a table in the config file.
+func isTable(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && !reflect.PointerTo(typ).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
+// tableFields returns the types of the fields of the given struct type by
+// the config keys of the fields.
+func tableFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields[name] = f.Type
+	}
+
+	return fields
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		{"directory", "directory"},
+		{"plugin-paths", "plugin-paths"},
+		{"plugin_paths", "plugin-paths"},
+		{"pluginPaths", "plugin-paths"},
+		{"dryRun2", "dry-run2"},
+		{"proxyURL", "proxy-url"},
+		{"caFile", "ca-file"},
+		{"tlsCAFile", "tls-ca-file"},
+		{"trace_rpc", "trace-rpc"},
+		{"on_Failure", "on-failure"},
+		{"JAVA_HOME", "JAVA_HOME"},
+		{"GoPath", "GoPath"},
+		{"~/.config", "~/.config"},
+		{"my.key", "my.key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			if got := config.CanonicalKey(tt.key); got != tt.want {
+				t.Errorf("CanonicalKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeys_Formats(t *testing.T) {
+	t.Parallel()
+
+	cfg := map[string]any{
+		"plugin_paths": []any{"/plugins"},
+		"dryRun":       true,
+		"logging": map[string]any{
+			"trace_rpc": true,
+		},
+		"strict": map[string]any{
+			"missingConfig":  "ignore",
+			"unknown_key":    "warn",
+			"platform-skip":  "error",
+			"missing-config": "warn",
+		},
+		"aliases": map[string]any{
+			"my_alias": "apply",
+			"upAll":    "apply --all",
+		},
+		"plugins": map[string]any{
+			"example": map[string]any{
+				"log_level": "debug",
+				"my_option": 1,
+			},
+		},
+	}
+
+	want := map[string]any{
+		"plugin-paths": []any{"/plugins"},
+		"dry-run":      true,
+		"logging": map[string]any{
+			"trace-rpc": true,
+		},
+		"strict": map[string]any{
+			"missingConfig":  "ignore",
+			"unknown-key":    "warn",
+			"platform-skip":  "error",
+			"missing-config": "warn",
+		},
+		"aliases": map[string]any{
+			"my_alias": "apply",
+			"upAll":    "apply --all",
+		},
+		"plugins": map[string]any{
+			"example": map[string]any{
+				"log-level": "debug",
+				"my_option": 1,
+			},
+		},
+	}
+
+	config.NormalizeKeys(cfg)
+
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("NormalizeKeys() = %v, want %v", cfg, want)
+	}
+}
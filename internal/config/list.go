@@ -0,0 +1,124 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// listSeparatorEnv is the environment variable that sets the separator of
+// the elements in the list values of the environment variables.
+const listSeparatorEnv = "REGINALD_LIST_SEPARATOR"
+
+// Errors returned when a list value cannot be split.
+var (
+	errListSeparator = errors.New("invalid list separator")
+	errQuotedElement = errors.New("unexpected character after quoted element")
+)
+
+// listSeparator returns the separator of the elements in the list values of
+// the environment variables. It is a comma unless another character is set
+// with [listSeparatorEnv].
+func listSeparator() (rune, error) {
+	s := os.Getenv(listSeparatorEnv)
+	if s == "" {
+		return ',', nil
+	}
+
+	runes := []rune(s)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\'' || runes[0] == '\\' {
+		return 0, fmt.Errorf("%w in %s: %q (must be a single character other than a quote or a backslash)",
+			errListSeparator, listSeparatorEnv, s)
+	}
+
+	return runes[0], nil
+}
+
+// splitList splits the list value s of an environment variable into its
+// elements. The elements are separated by the list separator (see
+// [listSeparator]). A separator or a quote can be included in an element by
+// escaping it with a backslash, and the other backslashes are kept as they are
+// so that the paths on Windows need no escaping. An element can also be
+// enclosed in double or single quotes, and the separators within the quotes
+// are part of the element. Within double quotes, a backslash escapes a double
+// quote or another backslash, and within single quotes, the characters are
+// taken as they are.
+func splitList(s string) ([]string, error) {
+	sep, err := listSeparator()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		elems   []string
+		sb      strings.Builder
+		quote   rune
+		quoted  bool
+		escaped bool
+	)
+
+	start := true
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != '"' && (quote != 0 || (r != sep && r != '\'')) && (quote == 0 || r != '\\') {
+				sb.WriteRune('\\')
+			}
+
+			sb.WriteRune(r)
+
+			escaped = false
+		case quote != 0 && r == quote:
+			quote = 0
+			quoted = true
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			sb.WriteRune(r)
+		case r == sep:
+			elems = append(elems, sb.String())
+			sb.Reset()
+
+			quoted = false
+			start = true
+
+			continue
+		case quoted:
+			return nil, fmt.Errorf("%w in %q: %q", errQuotedElement, s, r)
+		case r == '\\':
+			escaped = true
+		case start && (r == '"' || r == '\''):
+			quote = r
+		default:
+			sb.WriteRune(r)
+		}
+
+		start = false
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("%w in %q", errUnterminatedQuote, s)
+	}
+
+	if escaped {
+		sb.WriteRune('\\')
+	}
+
+	return append(elems, sb.String()), nil
+}
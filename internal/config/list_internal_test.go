@@ -0,0 +1,67 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+//nolint:paralleltest // sets the environment variable for the separator
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		sep     string
+		want    []string
+		wantErr error
+	}{
+		{"Single", "a", "", []string{"a"}, nil},
+		{"Plain", "a,b,c", "", []string{"a", "b", "c"}, nil},
+		{"Empty elements", "a,,b,", "", []string{"a", "", "b", ""}, nil},
+		{"Spaces", " a , b", "", []string{" a ", " b"}, nil},
+		{"Escaped separator", `a\,b,c`, "", []string{"a,b", "c"}, nil},
+		{"Escaped quote", `\"a",b`, "", []string{`"a"`, "b"}, nil},
+		{"Other backslashes", `C:\Users\me,\\server\share\`, "", []string{`C:\Users\me`, `\\server\share\`}, nil},
+		{"Double quotes", `"a,b",c`, "", []string{"a,b", "c"}, nil},
+		{"Escapes in double quotes", `"a\"b\\c\d"`, "", []string{`a"b\c\d`}, nil},
+		{"Single quotes", `'a,b\',c`, "", []string{`a,b\`, "c"}, nil},
+		{"Empty quotes", `"",''`, "", []string{"", ""}, nil},
+		{"Quote within element", `it's,b`, "", []string{"it's", "b"}, nil},
+		{"Separator", "a;b,c", ";", []string{"a", "b,c"}, nil},
+		{"Escaped custom separator", `a\;b;c\,d`, ";", []string{"a;b", `c\,d`}, nil},
+		{"Multibyte separator", "a→b", "→", []string{"a", "b"}, nil},
+		{"Unterminated quote", `"a,b`, "", nil, errUnterminatedQuote},
+		{"Text after quote", `"a"b,c`, "", nil, errQuotedElement},
+		{"Long separator", "a;b", ";;", nil, errListSeparator},
+		{"Quote separator", "a'b", "'", nil, errListSeparator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(listSeparatorEnv, tt.sep)
+
+			got, err := splitList(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("splitList(%q) error = %v, want %v", tt.s, err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitList(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
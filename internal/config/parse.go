@@ -15,21 +15,25 @@
 package config
 
 import (
+	"cmp"
 	"context"
 	"encoding"
 	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
+	"maps"
 	"os"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
-	"unicode"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
 	"github.com/reginald-project/reginald/internal/flags"
 	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/logger"
@@ -59,7 +63,20 @@ var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(
 // loaded.
 //
 //nolint:gochecknoglobals // used like constant
-var dynamicFields = []string{"Defaults", "Directory", "RawPlugins", "RawTasks", "Plugins", "Tasks"}
+var dynamicFields = []string{
+	"Aliases",
+	"Defaults",
+	"Directory",
+	"PluginOptions",
+	"RawPlugins",
+	"RawTasks",
+	"Plugins",
+	"Tasks",
+}
+
+// pluginsKey is the config key for the table that contains the options and
+// the config values of the plugins by their names or domains.
+const pluginsKey = "plugins"
 
 // ApplyOptions is the type for the options for the Apply function.
 type ApplyOptions struct {
@@ -70,6 +87,11 @@ type ApplyOptions struct {
 	// the built-in config values
 	Store *plugin.Store
 
+	// Report is called with the diagnostics about the config values, such as
+	// the use of deprecated values. The same diagnostic may be reported more
+	// than once. It may be nil.
+	Report func(class diag.Class, msg string)
+
 	// idents is the list of the config identifiers that form the "path" to
 	// the config value that is currently being parsed. It must always start
 	// with the global prefix for the environment variables.
@@ -91,47 +113,34 @@ func ApplyPlugins(ctx context.Context, cfg *Config, opts ApplyOptions) error {
 		panic("nil plugin store")
 	}
 
-	plugins := opts.Store.Plugins
-	if len(plugins) == 0 {
+	if opts.Store.Len() == 0 {
 		return nil
 	}
 
-	rawPlugins := cfg.RawPlugins
-	cfgs := make(api.KeyValues, 0, len(opts.Store.Commands))
-
-	// At this point, all of the plugins have been converted to commands.
-	for _, cmd := range opts.Store.Commands {
-		manifest := cmd.Plugin.Manifest()
-		name := manifest.Name
-		domain := manifest.Domain
+	var cfgs api.KeyValues
 
-		if !cmd.Plugin.External() {
-			domain = cmd.Name
-		}
+	// At this point, all of the plugins have been converted to commands. They
+	// are applied in the order of their config keys as the order of the plugins
+	// in the store depends on the order they were loaded in.
+	for _, cmd := range sortedCommands(opts.Store.CommandsFor(nil), configDomain) {
+		name := cmd.Plugin.Manifest().Name
+		domain := configDomain(cmd)
+		entries := cmd.Entries()
 
-		a, ok := rawPlugins[domain]
-		if !ok {
-			a = make(map[string]any)
-		}
-
-		rawMap, ok := a.(map[string]any)
-		if !ok {
-			return fmt.Errorf(
-				"%w: config for plugin %q with config key %q is not a map",
-				ErrInvalidConfig,
-				name,
-				domain,
-			)
+		rawMap, err := pluginTable(cfg, name, domain)
+		if err != nil {
+			return err
 		}
 
 		newOpts := ApplyOptions{
 			Dir:     opts.Dir,
 			FlagSet: opts.FlagSet,
 			Store:   opts.Store,
+			Report:  opts.Report,
 			idents:  append(opts.idents, domain),
 		}
 
-		values, err := applyPluginMap(ctx, rawMap, manifest.Config, cmd.Commands, newOpts)
+		values, err := applyPluginMap(ctx, rawMap, entries, cmd.Options, cmd.Commands, newOpts)
 		if err != nil {
 			return err
 		}
@@ -151,39 +160,6 @@ func ApplyPlugins(ctx context.Context, cfg *Config, opts ApplyOptions) error {
 	return nil
 }
 
-// NormalizeKeys checks the config value keys in the given raw config map and
-// changes them into the wanted format ("kebab-case") in case the config
-// contains something in "camelCase". This way the config file is able to
-// support JSON and YAML while allowing those files to have the keys more
-// idiomatic for those formats.
-func NormalizeKeys(cfg map[string]any) {
-	if cfg == nil {
-		return
-	}
-
-	for k, v := range cfg {
-		key := ""
-
-		for i, r := range k {
-			if i > 0 && unicode.IsUpper(r) {
-				key += "-"
-			}
-
-			key += strings.ToLower(string(r))
-		}
-
-		if k != key {
-			delete(cfg, k)
-
-			cfg[key] = v
-		}
-
-		if m, ok := v.(map[string]any); ok {
-			NormalizeKeys(m)
-		}
-	}
-}
-
 // Parse parses the configuration according to the configuration given with
 // flagSet. The flag set should contain all of the flags for the program as the
 // function uses the flags to override values from the configuration file. The
@@ -192,14 +168,32 @@ func NormalizeKeys(cfg map[string]any) {
 //
 // The function also resolves the configuration file according to the standard
 // paths for the file or according the flags. The relevant flags are
-// `--directory` and `--config`.
+// `--directory` and `--config`. If neither of them is set, the dotfiles
+// directory is detected from the working directory and its parents unless
+// the detection is disabled with `--no-detect-directory`.
+//
+// The "*.toml" files in the "reginald.d" directory next to the config file are
+// merged to the config file in the lexical order of their names before
+// the config is decoded. The tables in the fragments are merged key by key,
+// the tasks in them are appended to the tasks in the config file, and
+// the other values replace the earlier values.
 func Parse(ctx context.Context, flagSet *flags.FlagSet) (*Config, error) {
 	cfg := DefaultConfig()
+
+	detected, err := detectDirectory(ctx, cfg.Directory, flagSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if detected != "" {
+		cfg.Directory = detected
+	}
+
 	dir := cfg.Directory
 
 	var fileErr *FileError
 
-	if err := parseFile(dir, flagSet, cfg); err != nil {
+	if err := parseFile(ctx, dir, flagSet, cfg); err != nil {
 		if !errors.As(err, &fileErr) {
 			return nil, err
 		}
@@ -207,9 +201,10 @@ func Parse(ctx context.Context, flagSet *flags.FlagSet) (*Config, error) {
 
 	opts := ApplyOptions{
 		idents:  nil,
-		Dir:     dir, // this is the working dir by default so no extra work is needed
+		Dir:     dir, // the detected or the working dir by default so no extra work is needed
 		FlagSet: flagSet,
 		Store:   nil,
+		Report:  nil,
 	}
 	if err := Apply(ctx, cfg, opts); err != nil {
 		return nil, err
@@ -223,41 +218,61 @@ func Parse(ctx context.Context, flagSet *flags.FlagSet) (*Config, error) {
 }
 
 // Validate checks if all of the config values that were left after unmarshaling
-// the config are valid plugin or plugin command names.
+// the config are valid plugin or plugin command names. The unknown keys are
+// errors unless their severity is lowered in the strict mode config, and then
+// they are passed to report that may be nil.
 //
 // TODO: This should have a better implementation.
-func Validate(cfg *Config, store *plugin.Store) error {
+func Validate(cfg *Config, store *plugin.Store, report func(class diag.Class, msg string)) error {
 	if cfg.Quiet && cfg.Verbose {
 		return fmt.Errorf("%w: cannot be both quiet and verbose", ErrInvalidConfig)
 	}
 
-	if cfg.Interactive && cfg.Strict {
+	if cfg.Interactive && cfg.Strict.Enabled {
 		return fmt.Errorf("%w: cannot be both interactive and strict", ErrInvalidConfig)
 	}
 
-	for k := range cfg.RawPlugins {
-		ok := false
-	PluginLoop:
-		for _, p := range store.Plugins {
-			manifest := p.Manifest()
+	if cfg.AssumeYes && cfg.AssumeDefaults {
+		return fmt.Errorf("%w: cannot both assume yes and assume defaults", ErrInvalidConfig)
+	}
 
-			if manifest.Domain == k && manifest.Config != nil {
-				ok = true
+	if cfg.Interactive && cfg.Porcelain {
+		return fmt.Errorf("%w: cannot be both interactive and porcelain", ErrInvalidConfig)
+	}
 
-				break PluginLoop
-			}
+	if cfg.PingInterval < 0 {
+		return fmt.Errorf("%w: ping interval cannot be negative: %d", ErrInvalidConfig, cfg.PingInterval)
+	}
 
-			for _, c := range manifest.Commands {
-				if c.Name == k && c.Config != nil {
-					ok = true
+	if cfg.ShutdownTimeout < 0 {
+		return fmt.Errorf("%w: shutdown timeout cannot be negative: %d", ErrInvalidConfig, cfg.ShutdownTimeout)
+	}
 
-					break PluginLoop
-				}
-			}
+	if cfg.MaxProtocolErrors < 0 {
+		return fmt.Errorf("%w: maximum protocol errors cannot be negative: %d", ErrInvalidConfig, cfg.MaxProtocolErrors)
+	}
+
+	if err := cfg.Aliases.Validate(store); err != nil {
+		return err
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(cfg.PluginOptions)) {
+		if err := validatePluginOptions(store, k, cfg.PluginOptions[k]); err != nil {
+			return cfg.errorAt(pluginsKey+"."+k, err)
 		}
+	}
 
-		if !ok {
-			return fmt.Errorf("%w: invalid config key %q", ErrInvalidConfig, k)
+	for _, k := range slices.Sorted(maps.Keys(cfg.RawPlugins)) {
+		if hasPluginConfig(store, k) {
+			continue
+		}
+
+		if cfg.Strict.Severity(diag.UnknownKey) == diag.SeverityError {
+			return cfg.errorAt(k, fmt.Errorf("%w: invalid config key %q", ErrInvalidConfig, k))
+		}
+
+		if report != nil {
+			report(diag.UnknownKey, fmt.Sprintf("Unknown config key %q is ignored", k))
 		}
 	}
 
@@ -280,14 +295,11 @@ func applyBool(value reflect.Value, opts ApplyOptions) error {
 // applyColorMode sets a color mode value from the environment variables and
 // command-line flags to the config struct.
 func applyColorMode(value reflect.Value, opts ApplyOptions) error {
-	if !canUnmarshal(value) {
-		panic(fmt.Sprintf("failed to cast value to encoding.TextUnmarshaler: %[1]v (%[1]T)", value))
+	x, err := colorModeOf(value)
+	if err != nil {
+		return err
 	}
 
-	var err error
-
-	// TODO: Unsafe conversion.
-	x := terminal.ColorMode(value.Int())
 	env := envValue(opts.idents)
 
 	if env != "" {
@@ -298,8 +310,9 @@ func applyColorMode(value reflect.Value, opts ApplyOptions) error {
 			return err
 		}
 
-		// TODO: Unsafe conversion.
-		x = terminal.ColorMode(v.Int())
+		if x, err = colorModeOf(v); err != nil {
+			return fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, envName(opts.idents), err)
+		}
 	}
 
 	key := configKey(opts.idents)
@@ -318,8 +331,9 @@ func applyColorMode(value reflect.Value, opts ApplyOptions) error {
 			return err
 		}
 
-		// TODO: Unsafe conversion.
-		x = terminal.ColorMode(v.Int())
+		if x, err = colorModeOf(v); err != nil {
+			return fmt.Errorf("invalid value for --%s: %w", flagName, err)
+		}
 	}
 
 	value.SetInt(int64(x))
@@ -327,6 +341,17 @@ func applyColorMode(value reflect.Value, opts ApplyOptions) error {
 	return nil
 }
 
+// colorModeOf returns the color mode held by the given value. It returns an
+// error if the value is out of the range of [terminal.ColorMode].
+func colorModeOf(value reflect.Value) (terminal.ColorMode, error) {
+	n, err := typeconv.Int64ToInt(value.Int())
+	if err != nil {
+		return 0, fmt.Errorf("invalid color mode: %w", err)
+	}
+
+	return terminal.ColorMode(n), nil
+}
+
 // applyInt sets an integer value from the environment variables and
 // command-line flags to the config struct.
 func applyInt(value reflect.Value, opts ApplyOptions) error {
@@ -358,42 +383,11 @@ func applyInt(value reflect.Value, opts ApplyOptions) error {
 		x = int64(i)
 	}
 
-	value.SetInt(x)
-
-	return nil
-}
-
-// applyPath sets a filesystem path value from the environment variables and
-// command-line flags to the config struct.
-func applyPath(value reflect.Value, opts ApplyOptions) error {
-	x, err := pathValue(fspath.Path(value.String()), opts, nil)
-	if err != nil {
-		return err
-	}
-
-	value.Set(reflect.ValueOf(x))
-
-	return nil
-}
-
-// applyPathSlice sets a slice of filesystem paths from the environment
-// variables and command-line flags to the config struct.
-func applyPathSlice(value reflect.Value, opts ApplyOptions) error {
-	i := value.Interface()
-
-	x, ok := i.([]fspath.Path)
-	if !ok {
-		panic(fmt.Sprintf("failed to convert value to slice of paths: %[1]v (%[1]T)", i))
+	if value.OverflowInt(x) {
+		return fmt.Errorf("%w: %w: %d for %s", typeconv.ErrConv, typeconv.ErrRange, x, configKey(opts.idents))
 	}
 
-	var err error
-
-	x, err = pathSliceValue(x, opts, nil)
-	if err != nil {
-		return err
-	}
-
-	value.Set(reflect.ValueOf(x))
+	value.SetInt(x)
 
 	return nil
 }
@@ -409,7 +403,7 @@ func applyPluginCommands(
 ) (api.KeyValues, error) {
 	result := make(api.KeyValues, 0, len(cmds))
 
-	for _, cmd := range cmds {
+	for _, cmd := range sortedCommands(slices.Values(cmds), func(c *plugin.Command) string { return c.Name }) {
 		name := cmd.Name
 
 		a, ok := rawMap[name]
@@ -430,10 +424,11 @@ func applyPluginCommands(
 			Dir:     opts.Dir,
 			FlagSet: opts.FlagSet,
 			Store:   opts.Store,
+			Report:  opts.Report,
 			idents:  append(opts.idents, name),
 		}
 
-		values, err := applyPluginMap(ctx, raw, cmd.Config, cmd.Commands, newOpts)
+		values, err := applyPluginMap(ctx, raw, cmd.Entries(), cmd.Options, cmd.Commands, newOpts)
 		if err != nil {
 			return nil, err
 		}
@@ -450,17 +445,30 @@ func applyPluginCommands(
 }
 
 // applyPluginMap applies the config values from the environment variables and
-// the command-line flags to the given plugin configs map.
+// the command-line flags to the given plugin configs map. The entry options of
+// entries are given in options by the keys of the entries.
 func applyPluginMap(
 	ctx context.Context,
 	rawMap map[string]any,
 	entries []api.ConfigEntry,
+	options map[string]plugin.EntryOptions,
 	cmds []*plugin.Command,
 	opts ApplyOptions,
 ) (api.KeyValues, error) {
 	result := make(api.KeyValues, 0, len(entries)+len(cmds))
 
 	parent := opts.idents[len(opts.idents)-1]
+	keys := make([]string, 0, len(entries)+len(cmds))
+
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+
+	for _, cmd := range cmds {
+		keys = append(keys, cmd.Name)
+	}
+
+	canonicalizeKeys(rawMap, keys)
 
 	values, err := applyPluginCommands(ctx, rawMap, cmds, opts)
 	if err != nil {
@@ -469,6 +477,10 @@ func applyPluginMap(
 
 	result = append(result, values...)
 
+	// set tells which values the user has set by the keys of the entries for
+	// mapping the deprecated values to their replacements.
+	set := make(map[string]bool, len(entries))
+
 	for _, entry := range entries {
 		slog.Log(ctx, slog.Level(logger.LevelTrace), "parsing plugin value", "plugin", parent, "entry", entry)
 
@@ -488,7 +500,7 @@ func applyPluginMap(
 
 				// TODO: Is this conversion now done twice even for the default
 				// value?
-				raw, err = entry.Int()
+				raw, err = typeconv.ToInt(entry.Val)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert value for %q in %q to int: %w", entry.Key, parent, err)
 				}
@@ -501,20 +513,41 @@ func applyPluginMap(
 			Dir:     opts.Dir,
 			FlagSet: opts.FlagSet,
 			Store:   opts.Store,
+			Report:  opts.Report,
 			idents:  append(opts.idents, entry.Key),
 		}
 
-		kv, err := resolvePluginValue(raw, &entry, newOpts)
+		kv, err := resolvePluginValue(raw, &entry, options[entry.Key], newOpts)
 		if err != nil {
 			return nil, err
 		}
 
+		source := pluginValueSource(ok, &entry, newOpts)
+
+		if err = checkRequired(source != "", entry.Key, options[entry.Key]); err != nil {
+			return nil, err
+		}
+
+		if err = checkConstraints(kv, entry.Key, options[entry.Key]); err != nil {
+			return nil, err
+		}
+
+		if source != "" && options[entry.Key].IsDeprecated() {
+			warnDeprecatedPlugin(source, entries, &entry, options[entry.Key], newOpts)
+		}
+
+		set[entry.Key] = source != ""
+
 		slog.Log(ctx, slog.Level(logger.LevelTrace), "plugin value parsed", "plugin", parent, "kv", kv)
 
 		result = append(result, kv)
 	}
 
-	for k, v := range rawMap {
+	if err = replaceDeprecated(result, set, options); err != nil {
+		return nil, err
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(rawMap)) {
 		ok := slices.ContainsFunc(entries, func(e api.ConfigEntry) bool { return e.Key == k })
 		if ok {
 			continue
@@ -525,7 +558,7 @@ func applyPluginMap(
 			return nil, fmt.Errorf("%w: unknown key %q in %q", ErrInvalidConfig, k, strings.Join(opts.idents[1:], "."))
 		}
 
-		_, ok = v.(map[string]any)
+		_, ok = rawMap[k].(map[string]any)
 		if !ok {
 			return nil, fmt.Errorf("%w: unknown key %q in %q", ErrInvalidConfig, k, strings.Join(opts.idents[1:], "."))
 		}
@@ -542,6 +575,20 @@ func applyString(value reflect.Value, opts ApplyOptions) error {
 		return err
 	}
 
+	// The types that implement [encoding.TextUnmarshaler] validate the values
+	// from the environment variables and flags the same way as the values from
+	// the config file.
+	if x != value.String() && canUnmarshal(value) {
+		v, err := unmarshal(value, x)
+		if err != nil {
+			return err
+		}
+
+		value.Set(v)
+
+		return nil
+	}
+
 	value.SetString(x)
 
 	return nil
@@ -582,36 +629,13 @@ func applyStruct(ctx context.Context, cfg reflect.Value, opts ApplyOptions) erro
 			Dir:     opts.Dir,
 			FlagSet: opts.FlagSet,
 			Store:   opts.Store,
+			Report:  opts.Report,
 		}
 
-		switch val.Kind() { //nolint:exhaustive // TODO: implemented as needed
-		case reflect.Bool:
-			err = applyBool(val, newOpts)
-		case reflect.Int:
-			if val.Type().Name() == "ColorMode" {
-				err = applyColorMode(val, newOpts)
-			} else {
-				err = applyInt(val, newOpts)
-			}
-		case reflect.Slice:
-			e := val.Type().Elem()
-			if e.Kind() != reflect.String || e.Name() != "Path" {
-				panic(
-					fmt.Sprintf("unsupported config field type for %s: %s", field.Name, val.Kind()),
-				)
-			}
-
-			err = applyPathSlice(val, newOpts)
-		case reflect.String:
-			if val.Type().Name() == "Path" {
-				err = applyPath(val, newOpts)
-			} else {
-				err = applyString(val, newOpts)
-			}
-		case reflect.Struct:
+		if val.Kind() == reflect.Struct && !canUnmarshal(val) {
 			err = applyStruct(ctx, val, newOpts)
-		default:
-			panic(fmt.Sprintf("unsupported config field type for %s: %s", field.Name, val.Kind()))
+		} else {
+			err = bindField(val, newOpts)
 		}
 
 		if err != nil {
@@ -631,10 +655,14 @@ func boolSliceValue(x []bool, opts ApplyOptions, entry *api.ConfigEntry) ([]bool
 
 	env := pluginEnvValue(opts.idents, entry)
 
-	// TODO: There might be a more robust way to parse the paths, but this is
-	// fine for now.
 	if env != "" && (entry == nil || !entry.FlagOnly) {
-		parts := strings.Split(env, ",")
+		var parts []string
+
+		parts, err = splitList(env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
+		}
+
 		x = make([]bool, len(parts))
 
 		for i, part := range parts {
@@ -680,11 +708,15 @@ func boolValue(x bool, opts ApplyOptions, entry *api.ConfigEntry) (bool, error)
 		}
 	}
 
-	key := configKey(opts.idents)
+	inverted := ""
 
-	// TODO: Add plugin support for inverted flags and remove the plugin  check.
-	if entry == nil && HasInvertedFlagName(key) {
-		inverted := InvertedFlagName(key)
+	if entry != nil {
+		inverted = opts.FlagSet.InvertedFlag(flagName)
+	} else if key := configKey(opts.idents); HasInvertedFlagName(key) {
+		inverted = InvertedFlagName(key)
+	}
+
+	if inverted != "" {
 		if opts.FlagSet.Changed(inverted) {
 			x, err = opts.FlagSet.GetBool(inverted)
 			if err != nil {
@@ -704,31 +736,177 @@ func canUnmarshal(value reflect.Value) bool {
 	return reflect.PointerTo(value.Type()).Implements(textUnmarshalerType)
 }
 
+// checkEnum checks that x is one of the allowed values of the enum entry with
+// the given key.
+func checkEnum(x, key string, allowed []string) error {
+	if slices.Contains(allowed, x) {
+		return nil
+	}
+
+	quoted := make([]string, len(allowed))
+	for i, s := range allowed {
+		quoted[i] = strconv.Quote(s)
+	}
+
+	return fmt.Errorf(
+		"%w: invalid value %q for %q, must be one of %s",
+		ErrInvalidConfig,
+		x,
+		key,
+		strings.Join(quoted, ", "),
+	)
+}
+
+// byteSizeValue resolves a size in bytes from the environment variables and
+// the command-line flags to be used in the config. Like in the config file,
+// the size may be given with a binary unit suffix.
+func byteSizeValue(x plugin.ByteSize, opts ApplyOptions, entry *api.ConfigEntry) (plugin.ByteSize, error) {
+	env := pluginEnvValue(opts.idents, entry)
+
+	if env != "" && (entry == nil || !entry.FlagOnly) {
+		if err := x.UnmarshalText([]byte(env)); err != nil {
+			return 0, fmt.Errorf("failed to parse %q as a size: %w", env, err)
+		}
+	}
+
+	flagName := pluginFlagName(opts.idents, entry)
+
+	if opts.FlagSet.Changed(flagName) {
+		v, err := opts.FlagSet.GetString(flagName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get value for --%s: %w", flagName, err)
+		}
+
+		if err = x.UnmarshalText([]byte(v)); err != nil {
+			return 0, fmt.Errorf("failed to parse %q as a size: %w", v, err)
+		}
+	}
+
+	return x, nil
+}
+
 // configKey returns the key for the given config identifiers.
 func configKey(idents []string) string {
 	return strings.Join(idents[1:], ".")
 }
 
-// envValue returns the value of the environment variable for the given config
-// identifiers.
-func envValue(idents []string) string {
+// durationValue resolves a duration value from the environment variables and
+// the command-line flags to be used in the config. Like in the config file,
+// the environment variable may also give the duration as a number of seconds.
+func durationValue(x time.Duration, opts ApplyOptions, entry *api.ConfigEntry) (time.Duration, error) {
+	var err error
+
+	env := pluginEnvValue(opts.idents, entry)
+
+	if env != "" && (entry == nil || !entry.FlagOnly) {
+		var raw any = env
+
+		if n, err := strconv.Atoi(env); err == nil {
+			raw = n
+		}
+
+		x, err = typeconv.ToDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q as a duration: %w", env, err)
+		}
+	}
+
+	flagName := pluginFlagName(opts.idents, entry)
+
+	if opts.FlagSet.Changed(flagName) {
+		x, err = opts.FlagSet.GetDuration(flagName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get value for --%s: %w", flagName, err)
+		}
+	}
+
+	return x, nil
+}
+
+// envName returns the name of the environment variable for the given config
+// identifiers. The words in the identifiers are separated by underscores, and
+// the initialisms like "CI" are kept as single words. If a field of [Config]
+// in the identifiers has an "env" tag, the tag replaces the name thus far after
+// the global prefix.
+func envName(idents []string) string {
 	key := ""
+	typ := reflect.TypeFor[Config]()
 
 	for i, ident := range idents {
+		if i > 0 && typ != nil {
+			f, ok := typ.FieldByName(ident)
+
+			typ = nil
+
+			if ok && f.Type.Kind() == reflect.Struct {
+				typ = f.Type
+			}
+
+			if tag := f.Tag.Get("env"); ok && tag != "" {
+				key = idents[0] + "_" + tag
+
+				continue
+			}
+		}
+
 		if i > 0 {
 			key += "_"
 		}
 
-		for j, c := range ident {
-			if j > 0 && 'A' <= c && c <= 'Z' {
-				key += "_"
+		runes := []rune(ident)
+
+		for j, c := range runes {
+			if j > 0 && isUpper(c) {
+				lowerBefore := !isUpper(runes[j-1])
+				lowerAfter := j+1 < len(runes) && !isUpper(runes[j+1])
+
+				if lowerBefore || lowerAfter {
+					key += "_"
+				}
 			}
 
 			key += string(c)
 		}
 	}
 
-	return os.Getenv(strings.ToUpper(key))
+	return strings.ToUpper(key)
+}
+
+// isUpper reports whether c is an uppercase ASCII letter.
+func isUpper(c rune) bool {
+	return 'A' <= c && c <= 'Z'
+}
+
+// envValue returns the value of the environment variable for the given config
+// identifiers.
+func envValue(idents []string) string {
+	return os.Getenv(envName(idents))
+}
+
+// floatValue resolves a floating-point value from the environment variables and
+// the command-line flags to be used in the config.
+func floatValue(x float64, opts ApplyOptions, entry *api.ConfigEntry) (float64, error) {
+	var err error
+
+	env := pluginEnvValue(opts.idents, entry)
+
+	if env != "" && (entry == nil || !entry.FlagOnly) {
+		x, err = strconv.ParseFloat(env, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q as a float: %w", env, err)
+		}
+	}
+
+	flagName := pluginFlagName(opts.idents, entry)
+
+	if opts.FlagSet.Changed(flagName) {
+		x, err = opts.FlagSet.GetFloat64(flagName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get value for --%s: %w", flagName, err)
+		}
+	}
+
+	return x, nil
 }
 
 // fromOSDecodeHookFunc returns a decode hook for [mapstructure] that decodes
@@ -748,10 +926,8 @@ func fromOSDecodeHookFunc() mapstructure.DecodeHookFuncType {
 			return nil, fmt.Errorf("%w: cannot convert OS map to a map: %+v", typeconv.ErrConv, data)
 		}
 
-		for k, v := range m {
-			if system.OS(k).Current() {
-				return v, nil
-			}
+		if v, ok := currentOSValue(m); ok {
+			return v, nil
 		}
 
 		var def any
@@ -768,6 +944,70 @@ func fromOSDecodeHookFunc() mapstructure.DecodeHookFuncType {
 	}
 }
 
+// strictBoolDecodeHookFunc returns a decode hook for [mapstructure] that decodes
+// the boolean "strict" value of the earlier versions into the strict mode
+// config.
+func strictBoolDecodeHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.Bool || t != reflect.TypeFor[diag.Config]() {
+			return data, nil
+		}
+
+		enabled, ok := data.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot convert %+v to a bool", typeconv.ErrConv, data)
+		}
+
+		return map[string]any{"enabled": enabled}, nil
+	}
+}
+
+// configDomain returns the key of the config table of the given top-level
+// command. The built-in commands are configured as if they were plugins of
+// their own, and the commands of the external plugins are configured in
+// the tables of the plugins' domains.
+func configDomain(cmd *plugin.Command) string {
+	if !cmd.Plugin.External() {
+		return cmd.Name
+	}
+
+	return cmd.Plugin.Manifest().Domain
+}
+
+// sortedCommands collects the given commands into a slice sorted by the given
+// key.
+func sortedCommands(cmds iter.Seq[*plugin.Command], key func(cmd *plugin.Command) string) []*plugin.Command {
+	return slices.SortedStableFunc(cmds, func(a, b *plugin.Command) int {
+		return cmp.Compare(key(a), key(b))
+	})
+}
+
+// hasPluginConfig reports whether k is the domain of a plugin or the name of
+// a built-in command that has config values.
+func hasPluginConfig(store *plugin.Store, k string) bool {
+	for p := range store.VisitPlugins() {
+		manifest := p.Manifest()
+
+		if manifest.Domain == k && manifest.Config != nil {
+			return true
+		}
+
+		for _, c := range manifest.Commands {
+			if c.Name == k && c.Config != nil {
+				return true
+			}
+		}
+	}
+
+	for cmd := range store.CommandsFor(nil) {
+		if cmd.Name == k && cmd.PersistentConfig != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 // initIdents sets the correct initial identifiers to the ApplyOptions and
 // checks that the initial identifiers are valid. It panics on errors.
 func initIdents(opts ApplyOptions) ApplyOptions {
@@ -794,22 +1034,26 @@ func intSliceValue(x []int, opts ApplyOptions, entry *api.ConfigEntry) ([]int, e
 
 	env := pluginEnvValue(opts.idents, entry)
 
-	// TODO: There might be a more robust way to parse the paths, but this is
-	// fine for now.
 	if env != "" && (entry == nil || !entry.FlagOnly) {
-		parts := strings.Split(env, ",")
+		var parts []string
+
+		parts, err = splitList(env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
+		}
+
 		x = make([]int, len(parts))
 
 		for i, part := range parts {
-			var n int64
-
-			n, err = strconv.ParseInt(part, 10, 0)
+			x[i], err = typeconv.ParseInt(part)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse %q as an int: %w", parts, err)
+				return nil, fmt.Errorf(
+					"%w: invalid value for %s: %w",
+					ErrInvalidConfig,
+					pluginEnvName(opts.idents, entry),
+					err,
+				)
 			}
-
-			// TODO: Unsafe?
-			x[i] = int(n)
 		}
 	}
 
@@ -833,15 +1077,10 @@ func intValue(x int, opts ApplyOptions, entry *api.ConfigEntry) (int, error) {
 	env := pluginEnvValue(opts.idents, entry)
 
 	if env != "" && (entry == nil || !entry.FlagOnly) {
-		var i int64
-
-		i, err = strconv.ParseInt(env, 10, 0)
+		x, err = typeconv.ParseInt(env)
 		if err != nil {
-			return 0, fmt.Errorf("failed to parse %q as an integer: %w", env, err)
+			return 0, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
 		}
-
-		// TODO: Unsafe?
-		x = int(i)
 	}
 
 	flagName := pluginFlagName(opts.idents, entry)
@@ -858,8 +1097,8 @@ func intValue(x int, opts ApplyOptions, entry *api.ConfigEntry) (int, error) {
 
 // parseFile finds and parses the config file and sets the values to cfg. It
 // modifies the pointed cfg in place.
-func parseFile(dir fspath.Path, flagSet *flags.FlagSet, cfg *Config) error {
-	configFile, err := resolveFile(dir, flagSet)
+func parseFile(ctx context.Context, dir fspath.Path, flagSet *flags.FlagSet, cfg *Config) error {
+	configFile, repoDir, err := resolveFile(ctx, dir, flagSet)
 	if err != nil {
 		return err
 	}
@@ -878,14 +1117,69 @@ func parseFile(dir fspath.Path, flagSet *flags.FlagSet, cfg *Config) error {
 	rawCfg := make(map[string]any)
 
 	if err = toml.Unmarshal(data, &rawCfg); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column := decodeErr.Position()
+
+			return fmt.Errorf("failed to decode the config file: %w", newPositionError(configFile, data, line, column, err))
+		}
+
+		return fmt.Errorf("failed to decode the config file at %q: %w", configFile, err)
+	}
+
+	cfg.fileData = data
+
+	if cfg.positions, err = scanPositions(data); err != nil {
 		return fmt.Errorf("failed to decode the config file at %q: %w", configFile, err)
 	}
 
 	NormalizeKeys(rawCfg)
 
+	// The variables are expanded before decrypting the encrypted values so
+	// that the secrets are used as is.
+	if _, err = expandRaw(rawCfg); err != nil {
+		return fmt.Errorf("failed to expand the environment variables in the config file at %q: %w", configFile, err)
+	}
+
+	fragments, err := readFragments(configFile)
+	if err != nil {
+		return err
+	}
+
+	cfg.origins = make(map[string]fragmentOrigin)
+
+	for _, f := range fragments {
+		mergeFragment(rawCfg, f, cfg.origins)
+	}
+
+	// The fetched repository is used as the dotfiles directory unless
+	// the config file sets it.
+	if _, ok := rawCfg["directory"]; !ok && repoDir != "" {
+		rawCfg["directory"] = string(repoDir)
+	}
+
+	if _, ok := rawCfg[EncryptedKey]; ok {
+		var keyFile fspath.Path
+
+		keyFile, err = ResolveKeyFile(rawCfg, cfg.KeyFile, flagSet)
+		if err != nil {
+			return err
+		}
+
+		if err = decryptBlock(rawCfg, keyFile); err != nil {
+			return fmt.Errorf("failed to decrypt the config file at %q: %w", configFile, err)
+		}
+
+		NormalizeKeys(rawCfg)
+	}
+
 	decoderConfig := &mapstructure.DecoderConfig{ //nolint:exhaustruct // use default values
-		DecodeHook: mapstructure.ComposeDecodeHookFunc(fromOSDecodeHookFunc(), mapstructure.TextUnmarshallerHookFunc()),
-		Result:     cfg,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			fromOSDecodeHookFunc(),
+			strictBoolDecodeHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+		Result: cfg,
 	}
 
 	d, err := mapstructure.NewDecoder(decoderConfig)
@@ -894,7 +1188,7 @@ func parseFile(dir fspath.Path, flagSet *flags.FlagSet, cfg *Config) error {
 	}
 
 	if err := d.Decode(rawCfg); err != nil {
-		return fmt.Errorf("failed to decode the config file: %w", err)
+		return fmt.Errorf("failed to decode the config file: %w", cfg.decodeErrorAt(err))
 	}
 
 	return nil
@@ -921,6 +1215,29 @@ func parseInt(s string, value reflect.Value) (int64, error) {
 	return x, nil
 }
 
+// parseStringMap parses a map of strings from s that is a list of "KEY=VALUE"
+// pairs. The list is split in the same way as the other list values (see
+// [splitList]).
+func parseStringMap(s string) (map[string]string, error) {
+	pairs, err := splitList(s)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("%w: %q is not in the format KEY=VALUE", typeconv.ErrConv, pair)
+		}
+
+		m[strings.TrimSpace(k)] = v
+	}
+
+	return m, nil
+}
+
 // pathSliceValue resolves a slice of filesystem paths from the environment
 // variables and the command-line flags to be used in the config.
 func pathSliceValue(x []fspath.Path, opts ApplyOptions, entry *api.ConfigEntry) ([]fspath.Path, error) {
@@ -928,10 +1245,14 @@ func pathSliceValue(x []fspath.Path, opts ApplyOptions, entry *api.ConfigEntry)
 
 	env := pluginEnvValue(opts.idents, entry)
 
-	// TODO: There might be a more robust way to parse the paths, but this is
-	// fine for now.
 	if env != "" && (entry == nil || !entry.FlagOnly) {
-		parts := strings.Split(env, ",")
+		var parts []string
+
+		parts, err = splitList(env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
+		}
+
 		x = make([]fspath.Path, len(parts))
 
 		for i, part := range parts {
@@ -982,7 +1303,9 @@ func pathValue(x fspath.Path, opts ApplyOptions, entry *api.ConfigEntry) (fspath
 		}
 	}
 
-	if !x.IsAbs() {
+	// An empty path means that the option is not set, so it is not resolved
+	// against the directory.
+	if x != "" && !x.IsAbs() {
 		path, err := fspath.NewAbs(string(opts.Dir), string(x))
 		if err != nil {
 			return "", fmt.Errorf("failed to create absolute path from %q: %w", x, err)
@@ -994,15 +1317,22 @@ func pathValue(x fspath.Path, opts ApplyOptions, entry *api.ConfigEntry) (fspath
 	return x, nil
 }
 
-// pluginEnvValue returns the value of the environment variable for the given
+// pluginEnvName returns the name of the environment variable for the given
 // config identifiers, applying the environment variable name override from
 // the plugin's config entry it is set.
-func pluginEnvValue(idents []string, entry *api.ConfigEntry) string {
+func pluginEnvName(idents []string, entry *api.ConfigEntry) string {
 	if entry == nil || entry.EnvOverride == "" {
-		return envValue(idents)
+		return envName(idents)
 	}
 
-	return os.Getenv(strings.ToUpper(filename + "_" + entry.EnvOverride))
+	return strings.ToUpper(filename + "_" + entry.EnvOverride)
+}
+
+// pluginEnvValue returns the value of the environment variable for the given
+// config identifiers, applying the environment variable name override from
+// the plugin's config entry it is set.
+func pluginEnvValue(idents []string, entry *api.ConfigEntry) string {
+	return os.Getenv(pluginEnvName(idents, entry))
 }
 
 // pluginFlagName returns the name of the command-line flag for the given config
@@ -1025,6 +1355,70 @@ func pluginFlagName(idents []string, entry *api.ConfigEntry) string {
 	return FlagName(key)
 }
 
+// pluginTable returns the raw config values of the plugin with the given name
+// and domain from cfg. The values can be given either in the top-level table of
+// the domain or in the table of the domain within the "plugins" table, but not
+// in both.
+func pluginTable(cfg *Config, name, domain string) (map[string]any, error) {
+	a, inTop := cfg.RawPlugins[domain]
+
+	if opts, ok := cfg.PluginOptions[domain]; ok && len(opts.Values) > 0 {
+		if inTop {
+			return nil, cfg.errorAt(domain, fmt.Errorf(
+				"%w: config for plugin %q is given both in %q and in %q",
+				ErrInvalidConfig,
+				name,
+				domain,
+				pluginsKey+"."+domain,
+			))
+		}
+
+		return opts.Values, nil
+	}
+
+	if !inTop {
+		return make(map[string]any), nil
+	}
+
+	rawMap, ok := a.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: config for plugin %q with config key %q is not a map",
+			ErrInvalidConfig,
+			name,
+			domain,
+		)
+	}
+
+	return rawMap, nil
+}
+
+// pluginValueSource returns the source that sets the value of the plugin
+// config entry with the highest precedence. inFile tells whether the value is
+// set in the config file. If the value is not set by the user, an empty Source
+// is returned.
+func pluginValueSource(inFile bool, entry *api.ConfigEntry, opts ApplyOptions) Source {
+	if flagName := pluginFlagName(opts.idents, entry); flagName != "" {
+		if opts.FlagSet.Changed(flagName) {
+			return SourceFlag
+		}
+
+		if inverted := opts.FlagSet.InvertedFlag(flagName); inverted != "" && opts.FlagSet.Changed(inverted) {
+			return SourceFlag
+		}
+	}
+
+	if !entry.FlagOnly && pluginEnvValue(opts.idents, entry) != "" {
+		return SourceEnv
+	}
+
+	if inFile {
+		return SourceFile
+	}
+
+	return ""
+}
+
 // resolvePluginOSValue resolves the raw config value for a plugin config entry
 // from a map that contains different values for different OSes. It return
 // errNoOSMap if the plugin value is not given as an OS map.
@@ -1041,10 +1435,8 @@ func resolvePluginOSValue(raw any, entry *api.ConfigEntry) (any, error) {
 		return raw, errNoOSMap
 	}
 
-	for k, v := range m {
-		if system.OS(k).Current() {
-			return v, nil
-		}
+	if v, ok := currentOSValue(m); ok {
+		return v, nil
 	}
 
 	var def any
@@ -1060,19 +1452,41 @@ func resolvePluginOSValue(raw any, entry *api.ConfigEntry) (any, error) {
 	return nil, fmt.Errorf("%w: %q has no config value for current platform", ErrInvalidConfig, entry.Key)
 }
 
-// resolvePluginValue resolves the value of the given ConfigEntry and returns
-// the parsed KeyVal.
+// currentOSValue returns the value for the current OS from the given OS map.
+// More than one key may match the current OS, for example "linux" and "unix",
+// so the keys are checked in sorted order to always pick the same value.
+func currentOSValue(m map[string]any) (any, bool) {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if system.OS(k).Current() {
+			return m[k], true
+		}
+	}
+
+	return nil, false
+}
+
+// resolvePluginValue resolves the value of the given ConfigEntry with the given
+// entry options and returns the parsed KeyVal.
 //
 //nolint:cyclop,gocyclo,funlen,gocognit,maintidx // need to check all of the types
-func resolvePluginValue(raw any, entry *api.ConfigEntry, opts ApplyOptions) (api.KeyVal, error) {
+func resolvePluginValue(
+	raw any,
+	entry *api.ConfigEntry,
+	entryOpts plugin.EntryOptions,
+	opts ApplyOptions,
+) (api.KeyVal, error) {
 	var err error
 
-	if raw, err = resolvePluginOSValue(raw, entry); err != nil && !errors.Is(err, errNoOSMap) {
-		return api.KeyVal{}, fmt.Errorf(
-			"cannot parse config for %q: %w",
-			strings.Join(opts.idents[1:len(opts.idents)-1], "."),
-			err,
-		)
+	// The free-form maps cannot be given separately for each platform as
+	// they cannot be told apart from the platform maps.
+	if !plugin.IsMapType(entry.Type) {
+		if raw, err = resolvePluginOSValue(raw, entry); err != nil && !errors.Is(err, errNoOSMap) {
+			return api.KeyVal{}, fmt.Errorf(
+				"cannot parse config for %q: %w",
+				strings.Join(opts.idents[1:len(opts.idents)-1], "."),
+				err,
+			)
+		}
 	}
 
 	switch entry.Type {
@@ -1118,6 +1532,55 @@ func resolvePluginValue(raw any, entry *api.ConfigEntry, opts ApplyOptions) (api
 			entry.Key,
 			entry.Type,
 		)
+	case plugin.DurationValue:
+		x, err := typeconv.ToDuration(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
+		x, err = durationValue(x, opts, entry)
+		if err != nil {
+			return api.KeyVal{}, err
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x.String(), Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.EnumValue:
+		x, ok := raw.(string)
+		if !ok {
+			return api.KeyVal{}, fmt.Errorf("%w: %[2]v in %q to string", typeconv.ErrConv, raw, entry.Key)
+		}
+
+		x, err := stringValue(x, opts, entry)
+		if err != nil {
+			return api.KeyVal{}, err
+		}
+
+		if err = checkEnum(x, entry.Key, entryOpts.Enum); err != nil {
+			return api.KeyVal{}, err
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.FloatValue:
+		x, err := typeconv.ToFloat(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
+		x, err = floatValue(x, opts, entry)
+		if err != nil {
+			return api.KeyVal{}, err
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
 	case api.IntListValue:
 		a, ok := raw.([]any)
 		if !ok {
@@ -1149,6 +1612,33 @@ func resolvePluginValue(raw any, entry *api.ConfigEntry, opts ApplyOptions) (api
 			return api.KeyVal{}, err
 		}
 
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.MapValue:
+		if raw == nil {
+			raw = map[string]any{}
+		}
+
+		x, ok := raw.(map[string]any)
+		if !ok {
+			return api.KeyVal{}, fmt.Errorf("%w: %[2]v in %q to map[string]any", typeconv.ErrConv, raw, entry.Key)
+		}
+
+		m, err := stringMapValue(nil, opts, entry)
+		if err != nil {
+			return api.KeyVal{}, err
+		}
+
+		if m != nil {
+			x = make(map[string]any, len(m))
+
+			for k, v := range m {
+				x[k] = v
+			}
+		}
+
 		return api.KeyVal{
 			Value: api.Value{Val: x, Type: entry.Type},
 			Key:   entry.Key,
@@ -1206,6 +1696,25 @@ func resolvePluginValue(raw any, entry *api.ConfigEntry, opts ApplyOptions) (api
 			return api.KeyVal{}, err
 		}
 
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.StringMapValue:
+		if raw == nil {
+			raw = map[string]any{}
+		}
+
+		x, err := typeconv.ToStringMap(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
+		x, err = stringMapValue(x, opts, entry)
+		if err != nil {
+			return api.KeyVal{}, err
+		}
+
 		return api.KeyVal{
 			Value: api.Value{Val: x, Type: entry.Type},
 			Key:   entry.Key,
@@ -1264,9 +1773,10 @@ func setDir(cfg reflect.Value, opts ApplyOptions) (ApplyOptions, error) {
 		Dir:     opts.Dir,
 		FlagSet: opts.FlagSet,
 		Store:   opts.Store,
+		Report:  opts.Report,
 	}
 
-	if err := applyPath(val, newOpts); err != nil {
+	if err := bindField(val, newOpts); err != nil {
 		return ApplyOptions{}, err
 	}
 
@@ -1275,12 +1785,49 @@ func setDir(cfg reflect.Value, opts ApplyOptions) (ApplyOptions, error) {
 	return opts, nil
 }
 
-// string resolves a slice of strings from the environment variables and
-// the command-line flags to be used in the config.
+// stringMapValue resolves a map of strings from the environment variables and
+// the command-line flags to be used in the config. The environment variable is
+// given as a list of "KEY=VALUE" pairs separated by commas. If neither of them
+// is set, x is returned as is.
+func stringMapValue(x map[string]string, opts ApplyOptions, entry *api.ConfigEntry) (map[string]string, error) {
+	env := pluginEnvValue(opts.idents, entry)
+
+	if env != "" && (entry == nil || !entry.FlagOnly) {
+		var err error
+
+		x, err = parseStringMap(env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
+		}
+	}
+
+	flagName := pluginFlagName(opts.idents, entry)
+
+	if opts.FlagSet.Changed(flagName) {
+		var err error
+
+		x, err = opts.FlagSet.GetStringToString(flagName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get value for --%s: %w", flagName, err)
+		}
+	}
+
+	return x, nil
+}
+
+// stringSliceValue resolves a slice of strings from the environment
+// variables and the command-line flags to be used in the config.
 func stringSliceValue(x []string, opts ApplyOptions, entry *api.ConfigEntry) ([]string, error) {
 	var err error
 
-	// TODO: Right now, we do not read the environment variable for this type.
+	env := pluginEnvValue(opts.idents, entry)
+
+	if env != "" && (entry == nil || !entry.FlagOnly) {
+		x, err = splitList(env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidConfig, pluginEnvName(opts.idents, entry), err)
+		}
+	}
 
 	flagName := pluginFlagName(opts.idents, entry)
 
@@ -1334,3 +1881,36 @@ func unmarshal(value reflect.Value, s string) (reflect.Value, error) {
 
 	return ptr.Elem(), nil
 }
+
+// validatePluginOptions checks the table with the key k in the "plugins" table.
+// The key must be the name or the domain of a plugin, and the config values of
+// the plugin can only be given in the table of its domain.
+func validatePluginOptions(store *plugin.Store, k string, opts PluginOptions) error {
+	if opts.ShutdownTimeout != nil && *opts.ShutdownTimeout < 0 {
+		return fmt.Errorf("%w: shutdown timeout cannot be negative: %d", ErrInvalidConfig, *opts.ShutdownTimeout)
+	}
+
+	if hasPluginConfig(store, k) {
+		return nil
+	}
+
+	for p := range store.VisitPlugins() {
+		manifest := p.Manifest()
+
+		switch {
+		case len(opts.Values) == 0 && (manifest.Name == k || manifest.Domain == k):
+			return nil
+		case manifest.Name == k:
+			return fmt.Errorf(
+				"%w: config values for plugin %q must be given in %q",
+				ErrInvalidConfig,
+				k,
+				pluginsKey+"."+manifest.Domain,
+			)
+		case manifest.Domain == k:
+			return fmt.Errorf("%w: plugin %q has no config values", ErrInvalidConfig, manifest.Name)
+		}
+	}
+
+	return fmt.Errorf("%w: options for unknown plugin %q", ErrInvalidConfig, k)
+}
@@ -0,0 +1,263 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// snippetLines is the number of lines of the config file that are shown in
+// the snippets of the errors, including the line of the error.
+const snippetLines = 2
+
+// indexPattern matches the array indices in the field names of the errors
+// from [mapstructure], for example "[0]" in "tasks[0].type".
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`) //nolint:gochecknoglobals // used like a constant
+
+// A PositionError is an error in the config file at a known position. Its
+// message contains the path to the file, the line and the column of the error,
+// and a snippet of the file that marks the position.
+type PositionError struct {
+	err     error
+	file    fspath.Path
+	snippet string
+	line    int
+	column  int
+}
+
+// Error returns the value of e as a string.
+func (e *PositionError) Error() string {
+	msg := fmt.Sprintf("%s:%d:%d: %v", e.file, e.line, e.column, e.err)
+	if e.snippet != "" {
+		msg += "\n" + e.snippet
+	}
+
+	return msg
+}
+
+// Position returns the line and the column of the error. They are 1-indexed.
+func (e *PositionError) Position() (int, int) {
+	return e.line, e.column
+}
+
+// Unwrap returns the error wrapped by e.
+func (e *PositionError) Unwrap() error {
+	return e.err
+}
+
+// decodeErrorAt returns the error from decoding the config file with
+// [mapstructure] as a [PositionError] that points to the first key that caused
+// an error if the position of the key is known. Otherwise, it returns err as
+// is.
+func (c *Config) decodeErrorAt(err error) error {
+	var decodeErr *mapstructure.DecodeError
+	if !errors.As(err, &decodeErr) {
+		return err
+	}
+
+	key := indexPattern.ReplaceAllString(decodeErr.Name(), ".$1")
+	if _, _, _, ok := c.position(key); !ok {
+		return err
+	}
+
+	return c.errorAt(key, decodeErr)
+}
+
+// errorAt returns err as a [PositionError] that points to the given key in
+// the config file or in the config fragment that the value comes from if
+// the position of the key is known. Otherwise, it returns err as is. The key
+// is given as a dotted key path.
+func (c *Config) errorAt(key string, err error) error {
+	file, data, pos, ok := c.position(key)
+	if !ok {
+		return err
+	}
+
+	return newPositionError(file, data, pos.Line, pos.Column, err)
+}
+
+// position returns the file that the value with the given dotted key path is
+// set in, the content of the file, and the position of the key in it. It
+// reports whether the position is known.
+func (c *Config) position(key string) (fspath.Path, []byte, unstable.Position, bool) {
+	if f, fragmentKey, ok := fragmentOf(c.origins, key); ok {
+		pos, ok := lookupPosition(f.positions, fragmentKey)
+
+		return f.path, f.data, pos, ok
+	}
+
+	pos, ok := lookupPosition(c.positions, key)
+
+	return c.configFile, c.fileData, pos, ok
+}
+
+// lookupPosition returns the position of the key with the given dotted key path
+// from positions. The key paths in positions are in the canonical format, so
+// the keys that are not canonicalized in the config, such as the keys of
+// the free-form maps, are looked up by their canonical format if they are not
+// found as they are.
+func lookupPosition(positions map[string]unstable.Position, key string) (unstable.Position, bool) {
+	if pos, ok := positions[key]; ok {
+		return pos, true
+	}
+
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = CanonicalKey(p)
+	}
+
+	pos, ok := positions[strings.Join(parts, ".")]
+
+	return pos, ok
+}
+
+// keyPath returns the normalized key path of the given key nodes appended to
+// prefix.
+func keyPath(prefix []string, it unstable.Iterator) []string {
+	path := slices.Clone(prefix)
+
+	for it.Next() {
+		path = append(path, CanonicalKey(string(it.Node().Data)))
+	}
+
+	return path
+}
+
+// keyPosition returns the position of the first key node of the given table
+// or key-value node.
+func keyPosition(p *unstable.Parser, node *unstable.Node) unstable.Position {
+	it := node.Key()
+	it.Next()
+
+	return p.Shape(it.Node().Raw).Start
+}
+
+// newPositionError returns a new PositionError for the error at the given
+// position in the data of the config file.
+func newPositionError(file fspath.Path, data []byte, line, column int, err error) *PositionError {
+	return &PositionError{
+		err:     err,
+		file:    file,
+		snippet: snippet(data, line, column),
+		line:    line,
+		column:  column,
+	}
+}
+
+// scanKeyValue records the positions of the keys in the given key-value node
+// and in the possible inline tables in its value.
+func scanKeyValue(p *unstable.Parser, positions map[string]unstable.Position, prefix []string, node *unstable.Node) {
+	path := keyPath(prefix, node.Key())
+	positions[strings.Join(path, ".")] = keyPosition(p, node)
+
+	scanValue(p, positions, path, node.Value())
+}
+
+// scanPositions returns the positions of the keys in the given TOML document.
+// The keys of the returned map are the normalized key paths joined with dots,
+// and the elements of arrays are given by their indices, for example
+// "tasks.0.type".
+func scanPositions(data []byte) (map[string]unstable.Position, error) {
+	positions := make(map[string]unstable.Position)
+	counts := make(map[string]int)
+	p := unstable.Parser{} //nolint:exhaustruct // zero value is ready to use
+
+	p.Reset(data)
+
+	var table []string
+
+	for p.NextExpression() {
+		node := p.Expression()
+
+		switch node.Kind { //nolint:exhaustive // only the top-level expressions are relevant
+		case unstable.Table:
+			table = keyPath(nil, node.Key())
+			positions[strings.Join(table, ".")] = keyPosition(&p, node)
+		case unstable.ArrayTable:
+			table = keyPath(nil, node.Key())
+			name := strings.Join(table, ".")
+			table = append(table, strconv.Itoa(counts[name]))
+			counts[name]++
+			positions[strings.Join(table, ".")] = keyPosition(&p, node)
+		case unstable.KeyValue:
+			scanKeyValue(&p, positions, table, node)
+		}
+	}
+
+	if err := p.Error(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return positions, nil
+}
+
+// scanValue records the positions of the keys in the inline tables within
+// the given value node.
+func scanValue(p *unstable.Parser, positions map[string]unstable.Position, path []string, node *unstable.Node) {
+	switch node.Kind { //nolint:exhaustive // only the tables can contain keys
+	case unstable.InlineTable:
+		it := node.Children()
+		for it.Next() {
+			scanKeyValue(p, positions, path, it.Node())
+		}
+	case unstable.Array:
+		it := node.Children()
+
+		for i := 0; it.Next(); i++ {
+			scanValue(p, positions, append(slices.Clone(path), strconv.Itoa(i)), it.Node())
+		}
+	}
+}
+
+// snippet returns the lines of data that lead to the given position with
+// a marker under the column. The lines are prefixed with their line numbers.
+func snippet(data []byte, line, column int) string {
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	width := len(strconv.Itoa(line))
+
+	var sb strings.Builder
+
+	for i := max(line-snippetLines, 0); i < line; i++ {
+		fmt.Fprintf(&sb, "%*d | %s\n", width, i+1, strings.TrimRight(lines[i], "\r"))
+	}
+
+	// The marker keeps the tabs of the line so that it is aligned with
+	// the column.
+	current := lines[line-1]
+	indent := []rune(current[:min(max(column-1, 0), len(current))])
+
+	for i, r := range indent {
+		if r != '\t' {
+			indent[i] = ' '
+		}
+	}
+
+	fmt.Fprintf(&sb, "%*s | %s^", width, "", string(indent))
+
+	return sb.String()
+}
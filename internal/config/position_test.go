@@ -0,0 +1,106 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/spf13/pflag"
+)
+
+func TestParse_PositionError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		data        string
+		wantLine    int
+		wantColumn  int
+		wantSnippet string
+	}{
+		{
+			"Syntax",
+			"verbose = true\n\n[logging]\nlevel = \n",
+			4,
+			9,
+			"3 | [logging]\n4 | level = \n  |         ^",
+		},
+		{
+			"Type",
+			"verbose = 3\n",
+			1,
+			1,
+			"1 | verbose = 3\n  | ^",
+		},
+		{
+			"Nested",
+			"[logging]\n\tlevel = \"loud\"\n",
+			2,
+			2,
+			"1 | [logging]\n2 | \tlevel = \"loud\"\n  | \t^",
+		},
+		{
+			"Camel case",
+			"[logging]\ntraceRpc = \"yes\"\n",
+			2,
+			1,
+			"1 | [logging]\n2 | traceRpc = \"yes\"\n  | ^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "reginald.toml")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			flagSet := flags.NewFlagSet("test", pflag.ContinueOnError)
+			flagSet.String("config", "", "", "")
+
+			if err := flagSet.Parse([]string{"--config", path}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := config.Parse(t.Context(), flagSet)
+
+			var posErr *config.PositionError
+			if !errors.As(err, &posErr) {
+				t.Fatalf("Parse() error = %v, want PositionError", err)
+			}
+
+			if line, column := posErr.Position(); line != tt.wantLine || column != tt.wantColumn {
+				t.Errorf("Position() = %d, %d, want %d, %d", line, column, tt.wantLine, tt.wantColumn)
+			}
+
+			msg := posErr.Error()
+			if !strings.HasPrefix(msg, path) {
+				t.Errorf("Error() = %q, want prefix %q", msg, path)
+			}
+
+			if !strings.HasSuffix(msg, "\n"+tt.wantSnippet) {
+				t.Errorf("Error() = %q, want snippet %q", msg, tt.wantSnippet)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/httpclient"
+)
+
+// gitPrefix is the prefix of the remote config URLs that point to a Git
+// repository.
+const gitPrefix = "git+"
+
+// insecurePrefix is the prefix of the remote config URLs that use plain HTTP.
+// They are recognized as remote configs only to reject them.
+const insecurePrefix = "http://"
+
+// remoteTimeout is the timeout for downloading a remote config file.
+const remoteTimeout = 30 * time.Second
+
+// Errors returned when fetching a remote config.
+var (
+	errInsecureRemote = errors.New("plain HTTP is not allowed")
+	errRemotePath     = errors.New("config path is outside of the repository")
+	errRemote         = errors.New("failed to fetch remote config")
+)
+
+// A remoteConfig is a config file that was fetched from a remote location to
+// the local cache.
+type remoteConfig struct {
+	// file is the path to the fetched config file in the cache.
+	file fspath.Path
+
+	// dir is the path to the local clone of the repository if the config was
+	// fetched from a Git repository. Otherwise, it is empty.
+	dir fspath.Path
+}
+
+// CacheDir returns the directory for the files that Reginald caches, like
+// the remote config files.
+func CacheDir() (fspath.Path, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user cache directory: %w", err)
+	}
+
+	path, err := fspath.NewAbs(dir, filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert cache directory to absolute path: %w", err)
+	}
+
+	return path, nil
+}
+
+// PluginCacheFile returns the path to the file where the decoded plugin
+// manifests are cached. It is within the cache directory of Reginald.
+func PluginCacheFile() (fspath.Path, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return dir.Join("plugins.json"), nil
+}
+
+// DownloadCacheDir returns the directory of the download cache that is shared
+// by the operations that download artifacts. It is within the cache directory
+// of Reginald.
+func DownloadCacheDir() (fspath.Path, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return dir.Join("downloads"), nil
+}
+
+// isRemote reports whether the config file value is a URL of a remote config
+// instead of a local path.
+func isRemote(s string) bool {
+	for _, p := range []string{"https://", insecurePrefix, gitPrefix} {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// initNetwork initializes the HTTP client for fetching the remote config.
+// The config file has not been read yet, so the network config is resolved
+// only from the defaults, the environment variables, and the command-line
+// flags. The client is initialized again after the config file is parsed.
+func initNetwork(ctx context.Context, dir fspath.Path, flagSet *flags.FlagSet) error {
+	cfg := httpclient.DefaultConfig()
+	opts := ApplyOptions{
+		idents:  []string{filename, "Network"},
+		Dir:     dir,
+		FlagSet: flagSet,
+		Store:   nil,
+		Report:  nil,
+	}
+
+	if err := applyStruct(ctx, reflect.ValueOf(&cfg).Elem(), opts); err != nil {
+		return err
+	}
+
+	if err := httpclient.Init(cfg); err != nil {
+		return fmt.Errorf("failed to initialize the network config: %w", err)
+	}
+
+	return nil
+}
+
+// fetchRemote fetches the config from the given URL to the cache directory.
+// The URL is either an HTTPS URL of the config file or a Git URL that
+// starts with "git+", for example "git+ssh://git@github.com/user/dotfiles.git".
+// The Git repositories are cloned as a whole, and the config file is resolved
+// from the root of the repository or from the path in the URL fragment, for
+// example "git+https://github.com/user/dotfiles.git#config/reginald.toml".
+//
+// The URLs that use plain HTTP are rejected as the config could be modified in
+// transit and the config can run commands on the system.
+//
+// If the config was fetched before and fetching it fails, the cached copy is
+// used.
+func fetchRemote(ctx context.Context, rawURL string) (remoteConfig, error) {
+	if strings.HasPrefix(strings.TrimPrefix(rawURL, gitPrefix), insecurePrefix) {
+		return remoteConfig{}, errhint.Wrap(
+			fmt.Errorf("%w: %s: %w", errRemote, rawURL, errInsecureRemote),
+			"use an \"https://\" URL for the remote config",
+		)
+	}
+
+	dir, err := remoteCacheDir(rawURL)
+	if err != nil {
+		return remoteConfig{}, err
+	}
+
+	if strings.HasPrefix(rawURL, gitPrefix) {
+		return fetchGit(ctx, strings.TrimPrefix(rawURL, gitPrefix), dir)
+	}
+
+	return fetchHTTP(ctx, rawURL, dir)
+}
+
+// fetchGit clones or updates the Git repository at rawURL to dir and resolves
+// the config file from it.
+func fetchGit(ctx context.Context, rawURL string, dir fspath.Path) (remoteConfig, error) {
+	repoURL, file, _ := strings.Cut(rawURL, "#")
+	clone := dir.Join("repo")
+
+	var path fspath.Path
+
+	// The path in the fragment is checked before cloning so that an invalid
+	// URL fails fast.
+	if file != "" {
+		var err error
+
+		if path, err = remotePath(clone, file); err != nil {
+			return remoteConfig{}, err
+		}
+	}
+
+	ok, err := clone.Join(".git").IsDir()
+	if err != nil {
+		return remoteConfig{}, fmt.Errorf("failed to check repository in %q: %w", clone, err)
+	}
+
+	var args []string
+
+	if ok {
+		args = []string{"-C", string(clone), "pull", "--ff-only"}
+	} else {
+		// The separator keeps a URL that starts with a dash from being
+		// parsed as an option.
+		args = []string{"clone", "--depth", "1", "--", repoURL, string(clone)}
+	}
+
+	slog.InfoContext(ctx, "fetching remote config repository", "url", repoURL, "dir", clone)
+
+	if err = runGit(ctx, args...); err != nil {
+		if !ok {
+			return remoteConfig{}, fmt.Errorf("%w: %w", errRemote, err)
+		}
+
+		slog.WarnContext(ctx, "failed to update remote config repository, using cached copy", "url", repoURL, "err", err)
+	}
+
+	if path != "" {
+		if ok, err = path.IsFile(); err != nil {
+			return remoteConfig{}, fmt.Errorf("failed to check if %q is a file: %w", path, err)
+		} else if !ok {
+			return remoteConfig{}, &FileError{file: path}
+		}
+	} else {
+		for _, e := range configExtensions {
+			f := clone.Join(filename + e)
+
+			if ok, err = f.IsFile(); err != nil {
+				return remoteConfig{}, fmt.Errorf("failed to check if %q is a file: %w", f, err)
+			} else if ok {
+				path = f
+
+				break
+			}
+		}
+
+		if path == "" {
+			return remoteConfig{}, &FileError{file: clone.Join(filename + configExtensions[0])}
+		}
+	}
+
+	return remoteConfig{file: path, dir: clone}, nil
+}
+
+// fetchHTTP downloads the config file at rawURL to dir.
+func fetchHTTP(ctx context.Context, rawURL string, dir fspath.Path) (remoteConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return remoteConfig{}, fmt.Errorf("%w: invalid URL %q: %w", errRemote, rawURL, err)
+	}
+
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = filename + configExtensions[0]
+	}
+
+	file := dir.Join(name)
+
+	slog.InfoContext(ctx, "downloading remote config", "url", rawURL, "file", file)
+
+	downloadCtx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	data, err := httpclient.ReadAll(downloadCtx, rawURL)
+
+	cancel()
+
+	if err != nil {
+		ok, statErr := file.IsFile()
+		if statErr != nil || !ok {
+			return remoteConfig{}, fmt.Errorf("%w: %w", errRemote, err)
+		}
+
+		slog.WarnContext(ctx, "failed to download remote config, using cached copy", "url", rawURL, "err", err)
+
+		return remoteConfig{file: file, dir: ""}, nil
+	}
+
+	if _, err = fsutil.WriteFile(string(file), data, 0o600, ""); err != nil { //nolint:mnd // private file
+		return remoteConfig{}, fmt.Errorf("failed to write remote config to cache: %w", err)
+	}
+
+	return remoteConfig{file: file, dir: ""}, nil
+}
+
+// remotePath returns the path to the config file in the URL fragment within
+// the clone. The path must be relative and stay within the clone.
+func remotePath(clone fspath.Path, file string) (fspath.Path, error) {
+	rel := filepath.Clean(filepath.FromSlash(file))
+
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || strings.HasPrefix(file, "/") ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s: %w", errRemote, file, errRemotePath)
+	}
+
+	return clone.Join(rel), nil
+}
+
+// remoteCacheDir returns the cache directory for the remote config at
+// the given URL.
+func remoteCacheDir(rawURL string) (fspath.Path, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+
+	return dir.Join("remote", hex.EncodeToString(sum[:8])), nil //nolint:mnd // short enough to be unique
+}
+
+// runGit runs git with the given arguments.
+func runGit(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
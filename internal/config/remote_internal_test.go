@@ -0,0 +1,95 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/flags"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/httpclient"
+	"github.com/spf13/pflag"
+)
+
+func TestFetchRemote_Insecure(t *testing.T) {
+	t.Parallel()
+
+	for _, rawURL := range []string{
+		"http://example.com/reginald.toml",
+		"git+http://example.com/dotfiles.git",
+	} {
+		if !isRemote(rawURL) {
+			t.Errorf("isRemote(%q) = false, want true", rawURL)
+		}
+
+		if _, err := fetchRemote(t.Context(), rawURL); !errors.Is(err, errInsecureRemote) {
+			t.Errorf("fetchRemote(%q) error = %v, want %v", rawURL, err, errInsecureRemote)
+		}
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	t.Parallel()
+
+	clone := fspath.Path(filepath.Join(t.TempDir(), "repo"))
+
+	for _, tt := range []struct {
+		file    string
+		want    fspath.Path
+		wantErr error
+	}{
+		{"reginald.toml", clone.Join("reginald.toml"), nil},
+		{"config/reginald.toml", clone.Join("config", "reginald.toml"), nil},
+		{"config/../reginald.toml", clone.Join("reginald.toml"), nil},
+		{"../reginald.toml", "", errRemotePath},
+		{"../../etc/x", "", errRemotePath},
+		{"config/../../x", "", errRemotePath},
+		{"..", "", errRemotePath},
+		{"/etc/x", "", errRemotePath},
+	} {
+		got, err := remotePath(clone, tt.file)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("remotePath(%q) error = %v, want %v", tt.file, err, tt.wantErr)
+		}
+
+		if got != tt.want {
+			t.Errorf("remotePath(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestInitNetwork(t *testing.T) { //nolint:paralleltest // uses t.Setenv
+	flagSet := flags.NewFlagSet("test", pflag.ContinueOnError)
+
+	// The CA bundle from the environment is used for the remote config, so
+	// a missing bundle fails before anything is fetched.
+	t.Setenv("REGINALD_NETWORK_CA_FILE", filepath.Join(t.TempDir(), "missing.pem"))
+
+	if err := initNetwork(t.Context(), "", flagSet); err == nil {
+		t.Error("initNetwork() with a missing CA bundle succeeded")
+	}
+
+	t.Setenv("REGINALD_NETWORK_CA_FILE", "")
+
+	if err := initNetwork(t.Context(), "", flagSet); err != nil {
+		t.Errorf("initNetwork() error = %v", err)
+	}
+
+	if err := httpclient.Init(httpclient.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+}
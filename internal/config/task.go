@@ -19,9 +19,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/expr"
 	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/logger"
 	"github.com/reginald-project/reginald/internal/plugin"
@@ -33,14 +38,34 @@ import (
 // alternative does not match the variable in the config.
 var errNoUnionMatch = errors.New("union value does not match")
 
+// errInvalidTimeout is returned when the timeout of a task is invalid.
+var errInvalidTimeout = errors.New("invalid timeout")
+
+// reservedTaskKeys are the keys in the task entries that Reginald uses itself
+// and that are not passed to the tasks as config values.
+var reservedTaskKeys = []string{ //nolint:gochecknoglobals // used like a constant
+	"elevate",
+	"glob",
+	"id",
+	"on-failure",
+	"platforms",
+	"requires",
+	"timeout",
+	"type",
+	"when",
+}
+
 // TaskApplyOptions is the type for the options for the ApplyTasks function.
 type TaskApplyOptions struct {
 	// Store contains the discovered plugin. It should not be set when applying
 	// the built-in config values
 	Store           *plugin.Store
-	Defaults        plugin.TaskDefaults // default options for the task types
-	currentDefaults map[string]any      // default options for the currently-parsed task
-	Dir             fspath.Path         // base directory for the program operations
+	Defaults        plugin.TaskDefaults            // default options for the task types
+	Report          func(diag.Class, string)       // called with the diagnostics about the task configs, may be nil
+	currentDefaults map[string]any                 // default options for the currently-parsed task
+	currentOptions  map[string]plugin.EntryOptions // entry options of the currently-parsed task
+	glob            bool                           // whether to expand glob patterns in the path lists of the current task
+	Dir             fspath.Path                    // base directory for the program operations
 }
 
 // ApplyTasks applies the default values for tasks from the given defaults,
@@ -51,14 +76,15 @@ func ApplyTasks(ctx context.Context, rawCfg []map[string]any, opts TaskApplyOpti
 		panic("nil plugin store")
 	}
 
-	plugins := opts.Store.Plugins
-	if len(plugins) == 0 {
+	if opts.Store.Len() == 0 {
 		return nil, fmt.Errorf("cannot apply task config: %w", errNilPlugins)
 	}
 
 	result := make([]plugin.TaskConfig, 0)
 	counts := make(map[string]int)
 
+	var vars map[string]string
+
 	for _, rawEntry := range rawCfg {
 		slog.Log(ctx, slog.Level(logger.LevelTrace), "checking task map entry", "entry", rawEntry)
 
@@ -77,6 +103,9 @@ func ApplyTasks(ctx context.Context, rawCfg []map[string]any, opts TaskApplyOpti
 			return nil, fmt.Errorf("%w: unknown task type %q", ErrInvalidConfig, ttName)
 		}
 
+		configKeys := taskConfigKeys(task.Config)
+		canonicalizeKeys(rawEntry, append(slices.Clone(reservedTaskKeys), configKeys...))
+
 		c, err := newTaskConfig(task, rawEntry, counts)
 		if err != nil {
 			return nil, err
@@ -94,9 +123,32 @@ func ApplyTasks(ctx context.Context, rawCfg []map[string]any, opts TaskApplyOpti
 				c.Platforms,
 			)
 
+			if opts.Report != nil {
+				opts.Report(diag.PlatformSkip, fmt.Sprintf("Task %q is skipped as it is not enabled on %s", c.ID, system.This()))
+			}
+
 			continue
 		}
 
+		if rawWhen, ok := rawEntry["when"]; ok {
+			if vars == nil {
+				vars = system.CurrentFacts().Vars()
+			}
+
+			var enabled bool
+
+			enabled, err = evalTaskCondition(rawWhen, vars)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %q: %w", c.ID, err)
+			}
+
+			if !enabled {
+				slog.DebugContext(ctx, "task condition not met", "id", c.ID, "taskType", ttName, "when", rawWhen)
+
+				continue
+			}
+		}
+
 		var defaults map[string]any
 
 		defaults, ok = opts.Defaults[ttName]
@@ -104,7 +156,15 @@ func ApplyTasks(ctx context.Context, rawCfg []map[string]any, opts TaskApplyOpti
 			defaults = map[string]any{}
 		}
 
+		canonicalizeKeys(defaults, configKeys)
+
 		opts.currentDefaults = defaults
+		opts.currentOptions = task.Options
+
+		opts.glob, err = resolveTaskGlob(rawEntry["glob"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", c.ID, err)
+		}
 
 		c.Config, err = resolveTaskConfigs(task, c.ID, rawEntry, opts)
 		if err != nil {
@@ -127,6 +187,22 @@ func ApplyTasks(ctx context.Context, rawCfg []map[string]any, opts TaskApplyOpti
 	return result, nil
 }
 
+// evalTaskCondition evaluates the raw "when" condition of a task using
+// the given variables and reports whether the task is enabled.
+func evalTaskCondition(raw any, vars map[string]string) (bool, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return false, fmt.Errorf("%w: condition is not a string (%v)", ErrInvalidConfig, raw)
+	}
+
+	enabled, err := expr.Eval(s, vars)
+	if err != nil {
+		return false, fmt.Errorf("%w: invalid condition %q: %w", ErrInvalidConfig, s, err)
+	}
+
+	return enabled, nil
+}
+
 // newTaskConfig creates a new TaskConfig for a config entry.
 func newTaskConfig(task *plugin.Task, rawEntry map[string]any, counts map[string]int) (plugin.TaskConfig, error) {
 	var taskID string
@@ -177,27 +253,99 @@ func newTaskConfig(task *plugin.Task, rawEntry map[string]any, counts map[string
 		return plugin.TaskConfig{}, fmt.Errorf("failed to parse %q: %w", taskID, err)
 	}
 
+	var onFailure plugin.FailurePolicy
+
+	if rawOnFailure, ok := rawEntry["on-failure"]; ok {
+		s, ok := rawOnFailure.(string)
+		if !ok {
+			return plugin.TaskConfig{}, fmt.Errorf(
+				"%w: on-failure for task %q is not a string",
+				ErrInvalidConfig,
+				taskID,
+			)
+		}
+
+		if onFailure, err = plugin.ParseFailurePolicy(s); err != nil {
+			return plugin.TaskConfig{}, fmt.Errorf("%w: on-failure for task %q: %w", ErrInvalidConfig, taskID, err)
+		}
+	}
+
+	var timeout time.Duration
+
+	if rawTimeout, ok := rawEntry["timeout"]; ok {
+		if timeout, err = parseTaskTimeout(rawTimeout); err != nil {
+			return plugin.TaskConfig{}, fmt.Errorf("%w: timeout for task %q: %w", ErrInvalidConfig, taskID, err)
+		}
+	}
+
+	var elevate bool
+
+	if rawElevate, ok := rawEntry["elevate"]; ok {
+		if elevate, ok = rawElevate.(bool); !ok {
+			return plugin.TaskConfig{}, fmt.Errorf("%w: elevate for task %q is not a boolean", ErrInvalidConfig, taskID)
+		}
+	}
+
 	return plugin.TaskConfig{
 		Config:    nil,
+		Elevate:   elevate,
 		ID:        taskID,
+		OnFailure: onFailure,
 		Platforms: platforms,
 		Requires:  requires,
 		TaskType:  ttName,
+		Timeout:   timeout,
 	}, nil
 }
 
+// parseTaskTimeout parses the raw timeout of a task. The timeout is either
+// a duration string, such as "1m30s", or a number of seconds.
+func parseTaskTimeout(raw any) (time.Duration, error) {
+	timeout, err := typeconv.ToDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	if timeout < 0 {
+		return 0, fmt.Errorf("%w: negative timeout %s", errInvalidTimeout, timeout)
+	}
+
+	return timeout, nil
+}
+
 // parseTaskConfigValue parses the value of the given KeyValue from the task
-// options and the defaults. It returns the parsed value and any errors it
-// encounters.
+// options and the defaults and checks it against the validation constraints
+// of the entry. It returns the parsed value and any errors it encounters.
+func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts TaskApplyOptions) (api.KeyVal, error) {
+	entryOpts := opts.currentOptions[entry.Key]
+
+	if err := checkRequired(taskValueSet(entry.Key, rawMap, opts), entry.Key, entryOpts); err != nil {
+		return api.KeyVal{}, err
+	}
+
+	kv, err := convertTaskConfigValue(entry, rawMap, opts)
+	if err != nil {
+		return api.KeyVal{}, err
+	}
+
+	if err = checkConstraints(kv, entry.Key, entryOpts); err != nil {
+		return api.KeyVal{}, err
+	}
+
+	return kv, nil
+}
+
+// convertTaskConfigValue resolves the value of the given KeyValue from the task
+// options and the defaults and converts it to the type of the entry.
 //
 //nolint:cyclop,funlen,gocognit,gocyclo,maintidx // need for complexity when checking the config type
-func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts TaskApplyOptions) (api.KeyVal, error) {
+func convertTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts TaskApplyOptions) (api.KeyVal, error) {
 	var err error
 
 	raw := entry.Val
 
 	if entry.Type == api.IntValue {
-		if raw, err = entry.Int(); err != nil {
+		if raw, err = typeconv.ToInt(entry.Val); err != nil {
 			return api.KeyVal{}, fmt.Errorf("type conversion for %q failed: %w", entry.Key, err)
 		}
 	}
@@ -214,8 +362,11 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 		raw = fileValue
 	}
 
-	if raw, err = resolveTaskOSValue(raw, entry); err != nil && !errors.Is(err, errNoOSMap) {
-		return api.KeyVal{}, err
+	// The free-form maps cannot be given separately for each platform.
+	if !plugin.IsMapType(entry.Type) {
+		if raw, err = resolveTaskOSValue(raw, entry); err != nil && !errors.Is(err, errNoOSMap) {
+			return api.KeyVal{}, err
+		}
 	}
 
 	switch entry.Type {
@@ -253,6 +404,62 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 		}, nil
 	case api.ConfigSliceValue:
 		return api.KeyVal{}, fmt.Errorf("%w: %q has invalid type %q", plugin.ErrInvalidConfig, entry.Key, entry.Type)
+	case plugin.DurationValue:
+		if raw == nil {
+			raw = 0
+		}
+
+		var x time.Duration
+
+		x, err = typeconv.ToDuration(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x.String(), Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.EnumValue:
+		// An enum value without a default is left empty like a string.
+		if raw == nil {
+			return api.KeyVal{
+				Value: api.Value{Val: "", Type: entry.Type},
+				Key:   entry.Key,
+			}, nil
+		}
+
+		var x string
+
+		x, ok = raw.(string)
+		if !ok {
+			return api.KeyVal{}, fmt.Errorf("%w: %[2]v (%[2]T) in %q to string", typeconv.ErrConv, raw, entry.Key)
+		}
+
+		if err = checkEnum(x, entry.Key, opts.currentOptions[entry.Key].Enum); err != nil {
+			return api.KeyVal{}, err
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.FloatValue:
+		if raw == nil {
+			raw = 0.0
+		}
+
+		var x float64
+
+		x, err = typeconv.ToFloat(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
 	case api.IntListValue:
 		if raw == nil {
 			raw = []int{}
@@ -281,6 +488,22 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
 		}
 
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.MapValue:
+		if raw == nil {
+			raw = map[string]any{}
+		}
+
+		var x map[string]any
+
+		x, ok = raw.(map[string]any)
+		if !ok {
+			return api.KeyVal{}, fmt.Errorf("%w: %[2]v (%[2]T) in %q to map[string]any", typeconv.ErrConv, raw, entry.Key)
+		}
+
 		return api.KeyVal{
 			Value: api.Value{Val: x, Type: entry.Type},
 			Key:   entry.Key,
@@ -297,10 +520,12 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
 		}
 
-		x := make([]fspath.Path, len(paths))
+		x := make([]fspath.Path, 0, len(paths))
 
-		for i, path := range paths {
-			path, err = path.Expand()
+		// The environment variables have already been expanded when
+		// the config file was read.
+		for _, path := range paths {
+			path, err = path.ExpandUser()
 			if err != nil {
 				return api.KeyVal{}, fmt.Errorf("failed to expand %q: %w", path, err)
 			}
@@ -309,7 +534,20 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 				path = fspath.Join(opts.Dir, path)
 			}
 
-			x[i] = path
+			if !opts.glob {
+				x = append(x, path)
+
+				continue
+			}
+
+			var matches []fspath.Path
+
+			matches, err = path.Glob()
+			if err != nil {
+				return api.KeyVal{}, fmt.Errorf("invalid pattern %q in %q: %w", path, entry.Key, err)
+			}
+
+			x = append(x, matches...)
 		}
 
 		return api.KeyVal{
@@ -330,7 +568,16 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 
 		x := fspath.Path(s)
 
-		x, err = x.Expand()
+		// An empty path means that the value is not set, and it must not be
+		// resolved to the base directory.
+		if x == "" {
+			return api.KeyVal{
+				Value: api.Value{Val: x, Type: entry.Type},
+				Key:   entry.Key,
+			}, nil
+		}
+
+		x, err = x.ExpandUser()
 		if err != nil {
 			return api.KeyVal{}, fmt.Errorf("failed to expand %q: %w", x, err)
 		}
@@ -355,6 +602,22 @@ func parseTaskConfigValue(entry api.ConfigValue, rawMap map[string]any, opts Tas
 			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
 		}
 
+		return api.KeyVal{
+			Value: api.Value{Val: x, Type: entry.Type},
+			Key:   entry.Key,
+		}, nil
+	case plugin.StringMapValue:
+		if raw == nil {
+			raw = map[string]any{}
+		}
+
+		var x map[string]string
+
+		x, err = typeconv.ToStringMap(raw)
+		if err != nil {
+			return api.KeyVal{}, fmt.Errorf("failed to convert type for %q: %w", entry.Key, err)
+		}
+
 		return api.KeyVal{
 			Value: api.Value{Val: x, Type: entry.Type},
 			Key:   entry.Key,
@@ -391,9 +654,11 @@ func parseTaskMappedValue(top any, entry api.MappedValue, opts TaskApplyOptions)
 
 	kvs := make(api.KeyValues, 0, len(topMap))
 
-	// The key is the dymanic value and the value should be a map.
-	for topMapKey, topMapValue := range topMap {
-		origKey := topMapKey //nolint:copyloopvar // we modify the value later and need to preserve the original
+	// The key is the dymanic value and the value should be a map. The keys are
+	// sorted so that the order of the values is always the same.
+	for _, topMapKey := range slices.Sorted(maps.Keys(topMap)) {
+		origKey := topMapKey
+		topMapValue := topMap[topMapKey]
 
 		rawValueMap, ok := topMapValue.(map[string]any)
 		if !ok {
@@ -433,6 +698,13 @@ func parseTaskMappedValue(top any, entry api.MappedValue, opts TaskApplyOptions)
 		}
 
 		values := make(api.KeyValues, 0, len(entry.Values))
+		keys := make([]string, 0, len(entry.Values))
+
+		for _, configValue := range entry.Values {
+			keys = append(keys, configValue.Key)
+		}
+
+		canonicalizeKeys(rawValueMap, keys)
 
 		for _, configValue := range entry.Values {
 			kv, err := parseTaskConfigValue(configValue, rawValueMap, opts)
@@ -518,6 +790,10 @@ func resolveTaskConfigs(
 	cfgs := make(api.KeyValues, 0, len(task.Config))
 	ttName := task.TaskType
 
+	// set tells which values the user has set by the keys of the entries for
+	// mapping the deprecated values to their replacements.
+	set := make(map[string]bool)
+
 	// TODO: The defaults are now wrong, the functions try to check
 	// the top-level map instead of the values for the current task type.
 
@@ -535,6 +811,12 @@ func resolveTaskConfigs(
 				)
 			}
 
+			set[cfgTyped.Key] = taskValueSet(cfgTyped.Key, rawEntry, opts)
+
+			if entryOpts := opts.currentOptions[cfgTyped.Key]; set[cfgTyped.Key] && entryOpts.IsDeprecated() {
+				warnDeprecatedTask(cfgTyped.Key, ttName, entryOpts, opts)
+			}
+
 			cfgs = append(cfgs, kv)
 		case api.MappedValue:
 			topValue := rawEntry[cfgTyped.Key]
@@ -576,6 +858,10 @@ func resolveTaskConfigs(
 		}
 	}
 
+	if err := replaceDeprecated(cfgs, set, opts.currentOptions); err != nil {
+		return nil, fmt.Errorf("failed to parse config for %q (%s): %w", taskID, ttName, err)
+	}
+
 	return cfgs, nil
 }
 
@@ -611,10 +897,8 @@ func resolveTaskRequirements(raw any, second bool) ([]string, error) {
 		return nil, fmt.Errorf("%w: requires is not a list of strings", ErrInvalidConfig)
 	}
 
-	for k, v := range m {
-		if system.OS(k).Current() {
-			return resolveTaskRequirements(v, true)
-		}
+	if v, ok := currentOSValue(m); ok {
+		return resolveTaskRequirements(v, true)
 	}
 
 	var def any
@@ -632,6 +916,22 @@ func resolveTaskRequirements(raw any, second bool) ([]string, error) {
 	return nil, nil
 }
 
+// resolveTaskGlob resolves the value of the "glob" option of a task. The option
+// tells whether the path lists in the task config are glob patterns that are
+// expanded to the matching paths. It is disabled by default.
+func resolveTaskGlob(raw any) (bool, error) {
+	if raw == nil {
+		return false, nil
+	}
+
+	glob, ok := raw.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: \"glob\" is not a boolean: %[2]v (%[2]T)", ErrInvalidConfig, raw)
+	}
+
+	return glob, nil
+}
+
 // resolveTaskOSValue resolves the raw config value for a task config entry from
 // a map that contains different values for different OSes. It return errNoOSMap
 // if the plugin value is not given as an OS map.
@@ -648,10 +948,8 @@ func resolveTaskOSValue(raw any, entry api.ConfigValue) (any, error) {
 		return raw, errNoOSMap
 	}
 
-	for k, v := range m {
-		if system.OS(k).Current() {
-			return v, nil
-		}
+	if v, ok := currentOSValue(m); ok {
+		return v, nil
 	}
 
 	var def any
@@ -744,11 +1042,25 @@ func validateTasks(tasks []plugin.TaskConfig) error {
 	return nil
 }
 
+// taskValueSet reports whether the user has set the value of the task config
+// entry with the given key in the task or in the task defaults.
+func taskValueSet(key string, rawMap map[string]any, opts TaskApplyOptions) bool {
+	if _, ok := rawMap[key]; ok {
+		return true
+	}
+
+	_, ok := opts.currentDefaults[key]
+
+	return ok
+}
+
 // validateTaskConfigValues validates the config values parsed from the file and
 // check that the file contains no unknown values.
 func validateTaskConfigValues(rawTask map[string]any, cfg api.KeyValues, dir fspath.Path) error {
-	for key, value := range rawTask {
-		if key == "id" || key == "requires" || key == "type" {
+	for _, key := range slices.Sorted(maps.Keys(rawTask)) {
+		value := rawTask[key]
+
+		if slices.Contains(reservedTaskKeys, key) {
 			continue
 		}
 
@@ -759,7 +1071,10 @@ func validateTaskConfigValues(rawTask map[string]any, cfg api.KeyValues, dir fsp
 
 		var u map[string]any
 
-		if u, ok = value.(map[string]any); !ok {
+		// Only the tables of configs are checked further. The other values
+		// that are given as tables are free-form maps or values that are
+		// given separately for each OS.
+		if u, ok = value.(map[string]any); !ok || kv.Type != api.ConfigSliceValue {
 			continue
 		}
 
@@ -789,7 +1104,7 @@ func validateTaskMappedValue(kv api.KeyVal, raw map[string]any, dir fspath.Path)
 
 		a, ok := raw[c.Key]
 		if !ok {
-			for k, v := range raw {
+			for _, k := range slices.Sorted(maps.Keys(raw)) {
 				path := fspath.Path(k)
 
 				path, err = path.Expand()
@@ -805,7 +1120,7 @@ func validateTaskMappedValue(kv api.KeyVal, raw map[string]any, dir fspath.Path)
 					continue
 				}
 
-				a = v
+				a = raw[k]
 
 				break
 			}
@@ -15,13 +15,20 @@
 package config_test
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/reginald-project/reginald-sdk-go/api"
 	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
 	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/plugin"
 )
@@ -439,6 +446,865 @@ int = 321`
 	}
 }
 
+func TestApplyTasks_Glob(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example config",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands:    nil,
+			Tasks: []api.Task{
+				{
+					TaskType:    "foo",
+					Description: "does foo",
+					Provides:    "",
+					RawConfig:   nil,
+					Config: []api.ConfigType{
+						api.ConfigValue{
+							KeyVal: api.KeyVal{
+								Value: api.Value{
+									Val:  []string{},
+									Type: api.PathListValue,
+								},
+								Key: "files",
+							},
+							Description: "files for foo",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	dir := fspath.Path(t.TempDir())
+
+	for _, name := range []string{"a.toml", "b.toml", "c.txt"} {
+		if err := os.WriteFile(string(dir.Join(name)), []byte(name), 0o600); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		file    string
+		want    []fspath.Path
+		wantErr bool
+	}{
+		{
+			file: `[[tasks]]
+type = "example/foo"
+files = ["*.toml"]`,
+			want:    []fspath.Path{dir.Join("*.toml")},
+			wantErr: false,
+		},
+		{
+			file: `[[tasks]]
+type = "example/foo"
+glob = true
+files = ["*.toml", "c.txt", "*.md"]`,
+			want:    []fspath.Path{dir.Join("a.toml"), dir.Join("b.toml"), dir.Join("c.txt")},
+			wantErr: false,
+		},
+		{
+			file: `[[tasks]]
+type = "example/foo"
+glob = "yes"
+files = ["*.toml"]`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, tt.file)
+			cfg.Directory = dir
+
+			opts := config.TaskApplyOptions{
+				Store:    newStore(t, manifests, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil && !tt.wantErr {
+				t.Fatalf("ApplyTasks() failed: %v", err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			kv, ok := tasks[0].Config.Get("files")
+			if !ok {
+				t.Fatalf("missing %q in config", "files")
+			}
+
+			if got, _ := kv.Val.([]fspath.Path); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, kv.Val)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_When(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example config",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands:    nil,
+			Tasks: []api.Task{
+				{
+					TaskType:    "foo",
+					Description: "does foo",
+					Provides:    "",
+					RawConfig:   nil,
+					Config:      nil,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		when    string
+		want    int
+		wantErr bool
+	}{
+		{`"os == '` + runtime.GOOS + `'"`, 1, false},
+		{`"os != '` + runtime.GOOS + `'"`, 0, false},
+		{`"arch == '` + runtime.GOARCH + `' && (os == 'plan9' || true)"`, 1, false},
+		{`"hostname =~ '.*' && !(arch == 'none')"`, 1, false},
+		{`"os = 'linux'"`, 0, true},
+		{`"kernel == 'linux'"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\nwhen = "+tt.when)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newStore(t, manifests, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil && !tt.wantErr {
+				t.Fatalf("ApplyTasks() failed: %v", err)
+			}
+
+			if len(tasks) != tt.want {
+				t.Errorf("expected %d tasks, got %d", tt.want, len(tasks))
+			}
+		})
+	}
+}
+
+func TestApplyTasks_OnFailure(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example config",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands:    nil,
+			Tasks: []api.Task{
+				{
+					TaskType:    "foo",
+					Description: "does foo",
+					Provides:    "",
+					RawConfig:   nil,
+					Config:      nil,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    plugin.FailurePolicy
+		wantErr bool
+	}{
+		{"unset", "[[tasks]]\ntype = \"example/foo\"", "", false},
+		{"stop", "[[tasks]]\ntype = \"example/foo\"\non-failure = \"stop\"", plugin.FailureStop, false},
+		{"continue", "[[tasks]]\ntype = \"example/foo\"\non-failure = \"continue\"", plugin.FailureContinue, false},
+		{
+			"skip-dependents",
+			"[[tasks]]\ntype = \"example/foo\"\non-failure = \"skip-dependents\"",
+			plugin.FailureSkipDependents,
+			false,
+		},
+		{"invalid", "[[tasks]]\ntype = \"example/foo\"\non-failure = \"ignore\"", "", true},
+		{"not string", "[[tasks]]\ntype = \"example/foo\"\non-failure = true", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newStore(t, manifests, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			if tasks[0].OnFailure != tt.want {
+				t.Errorf("OnFailure = %q, want %q", tasks[0].OnFailure, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_Elevate(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example config",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands:    nil,
+			Tasks: []api.Task{
+				{
+					TaskType:    "foo",
+					Description: "does foo",
+					Provides:    "",
+					RawConfig:   nil,
+					Config:      nil,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		elevate string
+		want    bool
+		wantErr bool
+	}{
+		{`true`, true, false},
+		{`false`, false, false},
+		{`"yes"`, false, true},
+		{`1`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.elevate, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\nelevate = "+tt.elevate)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newStore(t, manifests, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			if tasks[0].Elevate != tt.want {
+				t.Errorf("Elevate = %v, want %v", tasks[0].Elevate, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_Timeout(t *testing.T) {
+	t.Parallel()
+
+	manifests := []*api.Manifest{
+		{
+			Name:        "reginald-example",
+			Version:     "0.1.0",
+			Domain:      "example",
+			Description: "example config",
+			Help:        "",
+			Executable:  "",
+			Config:      nil,
+			Commands:    nil,
+			Tasks: []api.Task{
+				{
+					TaskType:    "foo",
+					Description: "does foo",
+					Provides:    "",
+					RawConfig:   nil,
+					Config:      nil,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`"1m30s"`, 90 * time.Second, false},
+		{`"250ms"`, 250 * time.Millisecond, false},
+		{`45`, 45 * time.Second, false},
+		{`0`, 0, false},
+		{`"-1s"`, 0, true},
+		{`-5`, 0, true},
+		{`"soon"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timeout, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\ntimeout = "+tt.timeout)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newStore(t, manifests, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			if tasks[0].Timeout != tt.want {
+				t.Errorf("Timeout = %v, want %v", tasks[0].Timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_ValueTypes(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        { "key": "delay", "type": "duration", "value": "1s" },
+        { "key": "ratio", "type": "float", "value": 0.5 },
+        { "key": "mode", "type": "enum", "value": "fast", "enum": ["fast", "safe"] }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name      string
+		file      string
+		wantDelay string
+		wantRatio float64
+		wantMode  string
+		wantErr   bool
+	}{
+		{"Defaults", "", "1s", 0.5, "fast", false},
+		{"Duration string", `delay = "1m30s"`, "1m30s", 0.5, "fast", false},
+		{"Duration seconds", `delay = 45`, "45s", 0.5, "fast", false},
+		{"Float", `ratio = 2.25`, "1s", 2.25, "fast", false},
+		{"Float integer", `ratio = 3`, "1s", 3, "fast", false},
+		{"Enum", `mode = "safe"`, "1s", 0.5, "safe", false},
+		{"OS value", `ratio = { default = 1.5 }`, "1s", 1.5, "fast", false},
+		{"Invalid duration", `delay = "soon"`, "", 0, "", true},
+		{"Invalid float", `ratio = "half"`, "", 0, "", true},
+		{"Invalid enum", `mode = "slow"`, "", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\n"+tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newExternalStore(t, manifest, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			values := tasks[0].Config
+
+			if kv, ok := values.Get("delay"); !ok || kv.Val != tt.wantDelay {
+				t.Errorf("delay = %v, want %v", kv.Val, tt.wantDelay)
+			}
+
+			if kv, ok := values.Get("ratio"); !ok || kv.Val != tt.wantRatio {
+				t.Errorf("ratio = %v, want %v", kv.Val, tt.wantRatio)
+			}
+
+			if kv, ok := values.Get("mode"); !ok || kv.Val != tt.wantMode {
+				t.Errorf("mode = %v, want %v", kv.Val, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_MapValues(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        { "key": "env", "type": "stringMap", "value": { "EDITOR": "vi" } },
+        { "key": "settings", "type": "map" }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name         string
+		file         string
+		wantEnv      map[string]string
+		wantSettings map[string]any
+		wantErr      bool
+	}{
+		{
+			"Defaults",
+			"",
+			map[string]string{"EDITOR": "vi"},
+			map[string]any{},
+			false,
+		},
+		{
+			"Tables",
+			"[tasks.env]\nJAVA_HOME = \"/opt/java\"\n[tasks.settings]\ncount = 3\nfastMode = true\nlinux = \"yes\"\n",
+			map[string]string{"JAVA_HOME": "/opt/java"},
+			map[string]any{"count": int64(3), "fastMode": true, "linux": "yes"},
+			false,
+		},
+		{
+			"Inline tables",
+			"env = { GOPATH = \"/go\", GOFLAGS = \"-v\" }\nsettings = { list = [1, 2] }\n",
+			map[string]string{"GOPATH": "/go", "GOFLAGS": "-v"},
+			map[string]any{"list": []any{int64(1), int64(2)}},
+			false,
+		},
+		{"Invalid string map", "env = { COUNT = 1 }\n", nil, nil, true},
+		{"Not a map", "settings = \"foo\"\n", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\n"+tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newExternalStore(t, manifest, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			values := tasks[0].Config
+
+			if kv, ok := values.Get("env"); !ok || !reflect.DeepEqual(kv.Val, tt.wantEnv) {
+				t.Errorf("env = %v, want %v", kv.Val, tt.wantEnv)
+			}
+
+			if kv, ok := values.Get("settings"); !ok || !reflect.DeepEqual(kv.Val, tt.wantSettings) {
+				t.Errorf("settings = %v, want %v", kv.Val, tt.wantSettings)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_MappedValueOrder(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        {
+          "key": "links",
+          "keyType": "string",
+          "values": [{ "key": "mode", "type": "string", "value": "link" }]
+        }
+      ]
+    }
+  ]
+}`
+
+	const file = "[[tasks]]\ntype = \"example/foo\"\n" +
+		"links = { zeta = { mode = \"copy\" }, alpha = {}, mid = {}, beta = {} }\n"
+
+	want := []string{"alpha", "beta", "mid", "zeta"}
+
+	// The order of iterating a map is random so the check is repeated to
+	// catch an unstable order.
+	for range 10 {
+		cfg := parseFile(t, file)
+		cfg.Directory = fspath.Path(t.TempDir())
+
+		opts := config.TaskApplyOptions{
+			Store:    newExternalStore(t, manifest, cfg.Directory),
+			Defaults: cfg.Defaults,
+			Dir:      cfg.Directory,
+		}
+
+		tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+		if err != nil {
+			t.Fatalf("ApplyTasks() failed: %v", err)
+		}
+
+		links, ok := tasks[0].Config.Get("links")
+		if !ok {
+			t.Fatal("no value for \"links\"")
+		}
+
+		kvs, err := links.Configs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]string, 0, len(kvs))
+		for _, kv := range kvs {
+			got = append(got, kv.Key)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("keys of links = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestApplyTasks_OSMapOrder(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("the test needs two keys that match the current OS")
+	}
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [{ "key": "name", "type": "string", "value": "" }]
+    }
+  ]
+}`
+
+	const file = "[[tasks]]\ntype = \"example/foo\"\nname = { unix = \"unix\", linux = \"linux\" }\n"
+
+	// Both of the keys match the current OS so the first key in sorted order
+	// must always be used.
+	for range 10 {
+		cfg := parseFile(t, file)
+		cfg.Directory = fspath.Path(t.TempDir())
+
+		opts := config.TaskApplyOptions{
+			Store:    newExternalStore(t, manifest, cfg.Directory),
+			Defaults: cfg.Defaults,
+			Dir:      cfg.Directory,
+		}
+
+		tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+		if err != nil {
+			t.Fatalf("ApplyTasks() failed: %v", err)
+		}
+
+		if kv, ok := tasks[0].Config.Get("name"); !ok || kv.Val != "linux" {
+			t.Fatalf("name = %v, want %q", kv.Val, "linux")
+		}
+	}
+}
+
+func TestApplyTasks_Constraints(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        { "key": "jobs", "type": "int", "value": 1, "min": 1, "max": 8 },
+        { "key": "ratio", "type": "float", "value": 0.5, "oneOf": [0.5, 1] },
+        { "key": "name", "type": "string", "value": "", "pattern": "^[a-z]+$" },
+        { "key": "tags", "type": "stringList", "value": [], "oneOf": ["a", "b"] },
+        { "key": "url", "type": "string", "value": "", "required": true }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"Valid", "url = \"x\"\njobs = 8\nratio = 1\nname = \"foo\"\ntags = [\"a\", \"b\"]", false},
+		{"Defaults", "url = \"x\"", false},
+		{"Missing required", "jobs = 2", true},
+		{"Below min", "url = \"x\"\njobs = 0", true},
+		{"Above max", "url = \"x\"\njobs = 9", true},
+		{"Not one of", "url = \"x\"\nratio = 0.75", true},
+		{"No match", "url = \"x\"\nname = \"Foo\"", true},
+		{"List element not one of", "url = \"x\"\ntags = [\"a\", \"c\"]", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\n"+tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newExternalStore(t, manifest, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			_, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil && !tt.wantErr {
+				t.Fatalf("ApplyTasks() failed: %v", err)
+			}
+
+			if err != nil && !errors.Is(err, config.ErrInvalidConfig) {
+				t.Errorf("ApplyTasks() error = %v, want %v", err, config.ErrInvalidConfig)
+			}
+		})
+	}
+}
+
+func TestApplyTasks_Deprecated(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        { "key": "dest", "type": "string", "value": "" },
+        { "key": "target", "type": "string", "value": "", "replacedBy": "dest" },
+        { "key": "force", "type": "bool", "value": false, "deprecated": "it has no effect" }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name         string
+		file         string
+		wantDest     string
+		wantWarnings []string
+	}{
+		{"Not set", "", "", nil},
+		{
+			"Replaced",
+			`target = "foo"`,
+			"foo",
+			[]string{`Option "target" of task type "example/foo" is deprecated, use "dest" instead`},
+		},
+		{
+			"Replacement set",
+			"target = \"foo\"\ndest = \"bar\"",
+			"bar",
+			[]string{`Option "target" of task type "example/foo" is deprecated, use "dest" instead`},
+		},
+		{
+			"Message",
+			`force = true`,
+			"",
+			[]string{`Option "force" of task type "example/foo" is deprecated: it has no effect`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\n"+tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			var warnings []string
+
+			opts := config.TaskApplyOptions{
+				Store:    newExternalStore(t, manifest, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+				Report:   func(_ diag.Class, msg string) { warnings = append(warnings, msg) },
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err != nil {
+				t.Fatalf("ApplyTasks() failed: %v", err)
+			}
+
+			if kv, ok := tasks[0].Config.Get("dest"); !ok || kv.Val != tt.wantDest {
+				t.Errorf("dest = %v, want %v", kv.Val, tt.wantDest)
+			}
+
+			if !slices.Equal(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
 func parseFile(t *testing.T, file string) *config.Config {
 	t.Helper()
 
@@ -472,10 +1338,117 @@ func parseFile(t *testing.T, file string) *config.Config {
 func newStore(t *testing.T, manifests []*api.Manifest, dir fspath.Path) *plugin.Store {
 	t.Helper()
 
-	store, err := plugin.NewStore(t.Context(), manifests, dir, nil)
+	store, err := plugin.NewStore(t.Context(), manifests, dir, nil, "")
+	if err != nil {
+		t.Fatalf("failed to create plugin Store: %v", err)
+	}
+
+	return store
+}
+
+// newExternalStore returns a new plugin store with one external plugin that
+// has the given manifest. The plugin is created in dir.
+func newExternalStore(t *testing.T, manifest string, dir fspath.Path) *plugin.Store {
+	t.Helper()
+
+	pluginDir := filepath.Join(string(dir), "plugins", "example")
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(pluginDir, "manifest.json"), []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin"), nil, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []fspath.Path{dir.Join("plugins")}
+
+	store, err := plugin.NewStore(t.Context(), nil, dir, paths, "")
 	if err != nil {
 		t.Fatalf("failed to create plugin Store: %v", err)
 	}
 
 	return store
 }
+
+func TestApplyTasks_KeyFormats(t *testing.T) {
+	t.Parallel()
+
+	const manifest = `{
+  "name": "reginald-example",
+  "version": "0.1.0",
+  "domain": "example",
+  "description": "example config",
+  "executable": "plugin",
+  "tasks": [
+    {
+      "taskType": "foo",
+      "description": "does foo",
+      "config": [
+        { "key": "max-count", "type": "int", "value": 1 },
+        { "key": "env", "type": "stringMap", "value": {} }
+      ]
+    }
+  ]
+}`
+
+	tests := []struct {
+		name    string
+		file    string
+		want    int
+		wantErr bool
+	}{
+		{"kebab-case", "max-count = 2\non-failure = \"continue\"\n", 2, false},
+		{"snake_case", "max_count = 3\non_failure = \"continue\"\n", 3, false},
+		{"camelCase", "maxCount = 4\nonFailure = \"continue\"\n", 4, false},
+		{"Both", "max-count = 2\nmax_count = 3\non-failure = \"continue\"\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := parseFile(t, "[[tasks]]\ntype = \"example/foo\"\nenv = { my_var = \"x\" }\n"+tt.file)
+			cfg.Directory = fspath.Path(t.TempDir())
+
+			opts := config.TaskApplyOptions{
+				Store:    newExternalStore(t, manifest, cfg.Directory),
+				Defaults: cfg.Defaults,
+				Dir:      cfg.Directory,
+			}
+
+			tasks, err := config.ApplyTasks(t.Context(), cfg.RawTasks, opts)
+			if err == nil && tt.wantErr {
+				t.Fatal("ApplyTasks() succeeded unexpectedly")
+			}
+
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("ApplyTasks() failed: %v", err)
+				}
+
+				return
+			}
+
+			if len(tasks) != 1 {
+				t.Fatalf("expected 1 task, got %d", len(tasks))
+			}
+
+			if tasks[0].OnFailure != plugin.FailureContinue {
+				t.Errorf("OnFailure = %q, want %q", tasks[0].OnFailure, plugin.FailureContinue)
+			}
+
+			if kv, ok := tasks[0].Config.Get("max-count"); !ok || kv.Val != tt.want {
+				t.Errorf("max-count = %v, want %v", kv.Val, tt.want)
+			}
+
+			want := map[string]string{"my_var": "x"}
+			if kv, ok := tasks[0].Config.Get("env"); !ok || !reflect.DeepEqual(kv.Val, want) {
+				t.Errorf("env = %v, want %v", kv.Val, want)
+			}
+		})
+	}
+}
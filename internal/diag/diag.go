@@ -0,0 +1,407 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diag implements the diagnostics of Reginald. The problems that do not
+// stop the run by themselves are reported to a collector by their classes, and
+// the severity of each class decides whether the problem is ignored, printed as
+// a warning, or fails the run. The severities are set in the "strict" table of
+// the config. The modules report the problems to the default collector of
+// the run with [Report] instead of printing the warnings themselves so that
+// the same problem is reported only once and the warnings can be summarized at
+// the end of the run.
+package diag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// errSeverity is returned when a severity cannot be parsed.
+var errSeverity = errors.New("invalid severity")
+
+// collector is the default Collector of the run.
+var collector *Collector //nolint:gochecknoglobals // global Collector instance
+
+// A Class is a class of diagnostics that share the severity.
+type Class string
+
+// The classes of the diagnostics.
+const (
+	DependencySkip     Class = "dependency-skip"     // task is skipped as a task it depends on was not run
+	Deprecated         Class = "deprecated"          // deprecated config values are used
+	LinkFallback       Class = "link-fallback"       // link is created as a hard link or a copy
+	MissingConfig      Class = "missing-config"      // config file is not found
+	MissingPluginDir   Class = "missing-plugin-dir"  // plugin search path is not found
+	PlatformSkip       Class = "platform-skip"       // task is skipped as it is not enabled on the platform
+	PluginKilled       Class = "plugin-killed"       // plugin does not shut down in time and is killed
+	PluginQuarantined  Class = "plugin-quarantined"  // plugin is killed for its protocol errors
+	PluginUnresponsive Class = "plugin-unresponsive" // plugin does not respond to the pings
+	UnknownKey         Class = "unknown-key"         // config file contains an unknown key
+	UnknownStatus      Class = "unknown-status"      // status of a task cannot be checked in a dry run
+	Untranslated       Class = "untranslated"        // imported or exported config cannot be fully translated
+	UnverifiedPlugin   Class = "unverified-plugin"   // plugin is not in the lock file and has no checksum
+	WatchError         Class = "watch-error"         // watching the files for changes failed
+)
+
+// Severity is the severity of a class of diagnostics.
+type Severity int
+
+// The severities of the diagnostics. The default severity means that
+// the default of the class is used, and it depends on whether the strict mode
+// is enabled.
+const (
+	SeverityDefault Severity = iota // use the default of the class
+	SeverityIgnore                  // only log the diagnostic
+	SeverityWarn                    // print the diagnostic as a warning
+	SeverityError                   // fail the run
+)
+
+// severityNames are the names of the severities in the config.
+//
+//nolint:gochecknoglobals // used like constant
+var severityNames = map[Severity]string{
+	SeverityDefault: "",
+	SeverityIgnore:  "ignore",
+	SeverityWarn:    "warn",
+	SeverityError:   "error",
+}
+
+// classDefaults contains the default severities of the classes as
+// the severities without and with the strict mode.
+//
+//nolint:gochecknoglobals // used like constant
+var classDefaults = map[Class][2]Severity{
+	DependencySkip:     {SeverityWarn, SeverityWarn},
+	Deprecated:         {SeverityWarn, SeverityError},
+	LinkFallback:       {SeverityWarn, SeverityError},
+	MissingConfig:      {SeverityWarn, SeverityError},
+	MissingPluginDir:   {SeverityWarn, SeverityError},
+	PlatformSkip:       {SeverityIgnore, SeverityWarn},
+	PluginKilled:       {SeverityWarn, SeverityWarn},
+	PluginQuarantined:  {SeverityWarn, SeverityWarn},
+	PluginUnresponsive: {SeverityWarn, SeverityWarn},
+	UnknownKey:         {SeverityError, SeverityError},
+	UnknownStatus:      {SeverityWarn, SeverityWarn},
+	Untranslated:       {SeverityWarn, SeverityWarn},
+	UnverifiedPlugin:   {SeverityIgnore, SeverityError},
+	WatchError:         {SeverityWarn, SeverityWarn},
+}
+
+// Config is the configuration of the strict mode and the severities of
+// the classes of diagnostics. The severities that are not set use the defaults
+// of the classes, and the strict mode changes the defaults to be stricter.
+type Config struct {
+	// DependencySkip is the severity for skipping a task as a task it depends
+	// on failed or was declined.
+	DependencySkip Severity `mapstructure:"dependency-skip"`
+
+	// Deprecated is the severity for using deprecated config values.
+	Deprecated Severity `mapstructure:"deprecated"`
+
+	// LinkFallback is the severity for creating a hard link or a copy of
+	// the file when a symbolic link cannot be created.
+	LinkFallback Severity `mapstructure:"link-fallback"`
+
+	// MissingConfig is the severity for not finding the config file.
+	MissingConfig Severity `mapstructure:"missing-config"`
+
+	// MissingPluginDir is the severity for not finding a plugin search path.
+	MissingPluginDir Severity `mapstructure:"missing-plugin-dir"`
+
+	// PlatformSkip is the severity for skipping a task as it is not enabled on
+	// the current platform.
+	PlatformSkip Severity `mapstructure:"platform-skip"`
+
+	// PluginKilled is the severity for a plugin that does not shut down in
+	// time so that its process is killed.
+	PluginKilled Severity `mapstructure:"plugin-killed"`
+
+	// PluginQuarantined is the severity for a plugin that is killed and not
+	// used again during the run as it has violated the protocol too many
+	// times.
+	PluginQuarantined Severity `mapstructure:"plugin-quarantined"`
+
+	// PluginUnresponsive is the severity for a plugin that does not respond to
+	// the pings.
+	PluginUnresponsive Severity `mapstructure:"plugin-unresponsive"`
+
+	// UnknownKey is the severity for an unknown key in the config file.
+	UnknownKey Severity `mapstructure:"unknown-key"`
+
+	// UnknownStatus is the severity for a task whose status cannot be checked
+	// in a dry run as its plugin does not support it.
+	UnknownStatus Severity `mapstructure:"unknown-status"`
+
+	// Untranslated is the severity for the parts of an imported or exported
+	// config that cannot be translated.
+	Untranslated Severity `mapstructure:"untranslated"`
+
+	// UnverifiedPlugin is the severity for an external plugin that is not in
+	// the lock file so that its files have no checksum to verify.
+	UnverifiedPlugin Severity `mapstructure:"unverified-plugin"`
+
+	// WatchError is the severity for the errors from watching the files for
+	// changes.
+	WatchError Severity `mapstructure:"watch-error"`
+
+	// Enabled tells the program to enable the strict mode. In strict mode,
+	// the classes that have no severity set fail the run instead of only
+	// printing a warning.
+	Enabled bool `flag:"strict" mapstructure:"enabled"`
+}
+
+// A Diagnostic is a single reported problem.
+type Diagnostic struct {
+	Class   Class  `json:"class"`   // class of the diagnostic
+	Message string `json:"message"` // description of the problem
+}
+
+// A Collector collects the diagnostics of a run. The diagnostics with
+// the warning severity are printed when they are reported and summarized at
+// the end of the run, and the ones with the error severity are returned
+// together by [Collector.Err] so that all of them are reported before the run
+// fails. A Collector is safe for concurrent use.
+type Collector struct {
+	reported map[Diagnostic]bool // the diagnostics that have been reported
+	errs     []Diagnostic        // the diagnostics with the error severity
+	warns    []Diagnostic        // the diagnostics with the warning severity
+	cfg      Config              // severities of the classes
+	mu       sync.Mutex
+}
+
+// An Error is the error for the diagnostics that have the error severity.
+type Error struct {
+	Diagnostics []Diagnostic
+}
+
+// NewCollector returns a new Collector that uses the severities from cfg.
+func NewCollector(cfg Config) *Collector {
+	return &Collector{ //nolint:exhaustruct // zero mutex is ready for use
+		reported: make(map[Diagnostic]bool),
+		errs:     nil,
+		warns:    nil,
+		cfg:      cfg,
+	}
+}
+
+// Severity returns the severity that is used for the given class.
+func (c Config) Severity(class Class) Severity {
+	var s Severity
+
+	switch class {
+	case DependencySkip:
+		s = c.DependencySkip
+	case Deprecated:
+		s = c.Deprecated
+	case LinkFallback:
+		s = c.LinkFallback
+	case MissingConfig:
+		s = c.MissingConfig
+	case MissingPluginDir:
+		s = c.MissingPluginDir
+	case PlatformSkip:
+		s = c.PlatformSkip
+	case PluginKilled:
+		s = c.PluginKilled
+	case PluginQuarantined:
+		s = c.PluginQuarantined
+	case PluginUnresponsive:
+		s = c.PluginUnresponsive
+	case UnknownKey:
+		s = c.UnknownKey
+	case UnknownStatus:
+		s = c.UnknownStatus
+	case Untranslated:
+		s = c.Untranslated
+	case UnverifiedPlugin:
+		s = c.UnverifiedPlugin
+	case WatchError:
+		s = c.WatchError
+	default:
+		panic("invalid diagnostic class: " + string(class))
+	}
+
+	if s != SeverityDefault {
+		return s
+	}
+
+	if c.Enabled {
+		return classDefaults[class][1]
+	}
+
+	return classDefaults[class][0]
+}
+
+// Severity returns the severity that c uses for the given class.
+func (c *Collector) Severity(class Class) Severity {
+	return c.cfg.Severity(class)
+}
+
+// Report reports a diagnostic of the given class. The same diagnostic is
+// handled only once. Depending on the severity of the class, the diagnostic is
+// only logged, printed as a warning, or recorded to be returned by
+// [Collector.Err].
+func (c *Collector) Report(ctx context.Context, class Class, msg string) {
+	d := Diagnostic{Class: class, Message: msg}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reported[d] {
+		return
+	}
+
+	c.reported[d] = true
+
+	severity := c.cfg.Severity(class)
+
+	slog.InfoContext(ctx, "diagnostic reported", "class", class, "severity", severity, "msg", msg)
+
+	switch severity { //nolint:exhaustive // default severity is never returned
+	case SeverityWarn:
+		c.warns = append(c.warns, d)
+
+		terminal.Warnln(msg)
+	case SeverityError:
+		c.errs = append(c.errs, d)
+	}
+}
+
+// Err returns the diagnostics that have the error severity as an [*Error]. It
+// returns nil if there are none.
+func (c *Collector) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.errs) == 0 {
+		return nil
+	}
+
+	return &Error{Diagnostics: append([]Diagnostic(nil), c.errs...)}
+}
+
+// Warnings returns the diagnostics that have been reported with the warning
+// severity in the order they were reported.
+func (c *Collector) Warnings() []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Diagnostic(nil), c.warns...)
+}
+
+// PrintSummary prints the warnings reported to c grouped by their classes. As
+// the warnings are already printed when they are reported, the summary is only
+// printed if there is more than one warning so that the warnings printed during
+// a long run are not missed.
+func (c *Collector) PrintSummary() {
+	warns := c.Warnings()
+	if len(warns) < 2 { //nolint:mnd // a single warning is not summarized
+		return
+	}
+
+	var classes []Class
+
+	groups := make(map[Class][]string)
+
+	for _, d := range warns {
+		if _, ok := groups[d.Class]; !ok {
+			classes = append(classes, d.Class)
+		}
+
+		groups[d.Class] = append(groups[d.Class], d.Message)
+	}
+
+	terminal.Warnln(fmt.Sprintf("Finished with %d warnings:", len(warns)))
+
+	for _, class := range classes {
+		terminal.Warnln(fmt.Sprintf("  %s (%d):", class, len(groups[class])))
+
+		for _, msg := range groups[class] {
+			terminal.Warnln("    " + msg)
+		}
+	}
+}
+
+// Default returns the default Collector of the run.
+func Default() *Collector {
+	return collector
+}
+
+// Set sets the default Collector of the run.
+func Set(c *Collector) {
+	collector = c
+}
+
+// Report reports a diagnostic of the given class to the default Collector.
+func Report(ctx context.Context, class Class, msg string) {
+	if collector == nil {
+		panic("tried to call nil Collector")
+	}
+
+	collector.Report(ctx, class, msg)
+}
+
+// Error returns the value of e as a string.
+func (e *Error) Error() string {
+	if len(e.Diagnostics) == 1 {
+		d := e.Diagnostics[0]
+
+		return fmt.Sprintf("%s (%s)", d.Message, d.Class)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("multiple problems found: ")
+
+	for i, d := range e.Diagnostics {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+
+		fmt.Fprintf(&sb, "%s (%s)", d.Message, d.Class)
+	}
+
+	return sb.String()
+}
+
+// String returns the name of s.
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// MarshalText encodes s as text.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the severity from text.
+func (s *Severity) UnmarshalText(data []byte) error {
+	name := strings.ToLower(string(data))
+
+	for severity, n := range severityNames {
+		if n == name {
+			*s = severity
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q (must be \"ignore\", \"warn\", or \"error\")", errSeverity, string(data))
+}
@@ -0,0 +1,144 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diag_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/diag"
+)
+
+func TestSeverity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		class diag.Class
+		cfg   diag.Config
+		want  diag.Severity
+	}{
+		{
+			name:  "default",
+			class: diag.MissingConfig,
+			cfg:   diag.Config{},
+			want:  diag.SeverityWarn,
+		},
+		{
+			name:  "strict",
+			class: diag.MissingConfig,
+			cfg:   diag.Config{Enabled: true},
+			want:  diag.SeverityError,
+		},
+		{
+			name:  "ignoredByDefault",
+			class: diag.PlatformSkip,
+			cfg:   diag.Config{},
+			want:  diag.SeverityIgnore,
+		},
+		{
+			name:  "set",
+			class: diag.MissingConfig,
+			cfg:   diag.Config{MissingConfig: diag.SeverityIgnore},
+			want:  diag.SeverityIgnore,
+		},
+		{
+			name:  "setInStrict",
+			class: diag.Deprecated,
+			cfg:   diag.Config{Deprecated: diag.SeverityWarn, Enabled: true},
+			want:  diag.SeverityWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.cfg.Severity(tt.class); got != tt.want {
+				t.Errorf("Config.Severity(%q) = %v, want %v", tt.class, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    diag.Severity
+		wantErr bool
+	}{
+		{in: "ignore", want: diag.SeverityIgnore},
+		{in: "warn", want: diag.SeverityWarn},
+		{in: "ERROR", want: diag.SeverityError},
+		{in: "", want: diag.SeverityDefault},
+		{in: "fatal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			var got diag.Severity
+
+			err := got.UnmarshalText([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Severity.UnmarshalText(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Severity.UnmarshalText(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	c := diag.NewCollector(diag.Config{UnknownKey: diag.SeverityError, PlatformSkip: diag.SeverityIgnore})
+
+	c.Report(t.Context(), diag.PlatformSkip, "skipped")
+
+	if err := c.Err(); err != nil {
+		t.Fatalf("Err() after ignored diagnostic = %v, want nil", err)
+	}
+
+	c.Report(t.Context(), diag.UnknownKey, "unknown a")
+	c.Report(t.Context(), diag.UnknownKey, "unknown a")
+
+	err := c.Err()
+
+	var diagErr *diag.Error
+	if !errors.As(err, &diagErr) {
+		t.Fatalf("Err() = %v, want *diag.Error", err)
+	}
+
+	if len(diagErr.Diagnostics) != 1 {
+		t.Errorf("Err() diagnostics = %v, want 1", diagErr.Diagnostics)
+	}
+
+	if want := "unknown a (unknown-key)"; err.Error() != want {
+		t.Errorf("Err() = %q, want %q", err.Error(), want)
+	}
+
+	c.Report(t.Context(), diag.UnknownKey, "unknown b")
+
+	want := "multiple problems found: unknown a (unknown-key); unknown b (unknown-key)"
+	if got := c.Err().Error(); got != want {
+		t.Errorf("Err() = %q, want %q", got, want)
+	}
+}
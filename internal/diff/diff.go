@@ -0,0 +1,370 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff implements a line-based diff engine for showing the changes
+// that the tasks would make to the files. The diffs are computed using
+// the Myers' algorithm and they can be formatted in the unified format.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Operations for the edits.
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// DefaultContext is the default number of context lines included in unified
+// diff hunks.
+const DefaultContext = 3
+
+// noNewline is the marker that is added after a line that does not end with
+// a newline character.
+const noNewline = "\\ No newline at end of file"
+
+// Op is the type of an edit operation.
+type Op int
+
+// An Edit is a single line-level edit in a diff.
+type Edit struct {
+	Line string // line content including the trailing newline, if any
+	Op   Op     // the operation for the line
+}
+
+// A Hunk is a group of edits with the surrounding context lines.
+type Hunk struct {
+	Edits    []Edit
+	OldStart int // 1-based start line in the old content
+	OldLines int // number of lines from the old content in the hunk
+	NewStart int // 1-based start line in the new content
+	NewLines int // number of lines from the new content in the hunk
+}
+
+// Hunks computes the edits between a and b and groups them into hunks that
+// have at most n lines of context around the changes. It returns nil if a and
+// b are equal.
+func Hunks(a, b string, n int) []Hunk {
+	if a == b {
+		return nil
+	}
+
+	if n < 0 {
+		n = 0
+	}
+
+	edits := Lines(a, b)
+
+	var (
+		hunks   []Hunk
+		current *Hunk
+	)
+
+	oldLine, newLine := 1, 1
+	lastChange := -1
+
+	for i, e := range edits {
+		if e.Op != Equal {
+			if current != nil && i-lastChange-1 > 2*n {
+				hunks = append(hunks, closeHunk(*current, edits, lastChange, n))
+				current = nil
+			}
+
+			if current == nil {
+				start := max(0, i-n)
+				current = &Hunk{
+					Edits:    nil,
+					OldStart: oldLine - (i - start),
+					OldLines: 0,
+					NewStart: newLine - (i - start),
+					NewLines: 0,
+				}
+
+				current.addContext(edits[start:i])
+			} else {
+				current.addContext(edits[lastChange+1 : i])
+			}
+
+			current.Edits = append(current.Edits, e)
+
+			if e.Op == Delete {
+				current.OldLines++
+			} else {
+				current.NewLines++
+			}
+
+			lastChange = i
+		}
+
+		switch e.Op {
+		case Equal:
+			oldLine++
+			newLine++
+		case Delete:
+			oldLine++
+		case Insert:
+			newLine++
+		default:
+			panic(fmt.Sprintf("invalid diff operation: %d", e.Op))
+		}
+	}
+
+	if current != nil {
+		hunks = append(hunks, closeHunk(*current, edits, lastChange, n))
+	}
+
+	return hunks
+}
+
+// Lines computes the line-level edits that transform a into b.
+func Lines(a, b string) []Edit {
+	return compute(split(a), split(b))
+}
+
+// Unified returns the unified diff between a and b using oldName and newName as
+// the file names in the header. It returns an empty string if the contents are
+// equal. The number of context lines is given as n.
+func Unified(oldName, newName, a, b string, n int) string {
+	hunks := Hunks(a, b, n)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("--- " + oldName + "\n")
+	sb.WriteString("+++ " + newName + "\n")
+
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+
+		for _, e := range h.Edits {
+			switch e.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			default:
+				panic(fmt.Sprintf("invalid diff operation: %d", e.Op))
+			}
+
+			sb.WriteString(e.Line)
+
+			if !strings.HasSuffix(e.Line, "\n") {
+				sb.WriteString("\n" + noNewline + "\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// String returns the string representation of o.
+func (o Op) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return fmt.Sprintf("Op(%d)", int(o))
+	}
+}
+
+// addContext adds the given equal edits to h as context lines.
+func (h *Hunk) addContext(edits []Edit) {
+	for _, e := range edits {
+		h.Edits = append(h.Edits, e)
+		h.OldLines++
+		h.NewLines++
+	}
+}
+
+// closeHunk adds at most n lines of trailing context after the last change to
+// h and returns it.
+func closeHunk(h Hunk, edits []Edit, lastChange, n int) Hunk {
+	end := min(len(edits), lastChange+1+n)
+	h.addContext(edits[lastChange+1 : end])
+
+	return h
+}
+
+// compute computes the shortest edit script from a to b. The common prefix and
+// suffix are handled directly and the rest is computed using the Myers'
+// algorithm.
+func compute(a, b []string) []Edit {
+	prefix := 0
+
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Line: line, Op: Equal})
+	}
+
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Line: line, Op: Equal})
+	}
+
+	return edits
+}
+
+// myers computes the shortest edit script from a to b using the Myers'
+// algorithm.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+
+	if maxD == 0 {
+		return nil
+	}
+
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+
+	// The trace contains snapshots of v before each round d. Only the diagonals
+	// from -d to d are stored as the backtracking only needs them.
+	trace := make([][]int, 0, maxD+1)
+
+Search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break Search
+			}
+		}
+	}
+
+	return backtrack(a, b, trace)
+}
+
+// backtrack walks the trace of the Myers' algorithm backwards and returns
+// the edits in order.
+func backtrack(a, b []string, trace [][]int) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		vd := trace[d]
+		k := x - y
+
+		var prevK int
+
+		if k == -d || (k != d && vd[k-1+d] < vd[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		var prevX int
+
+		if d > 0 {
+			prevX = vd[prevK+d]
+		}
+
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+
+			edits = append(edits, Edit{Line: a[x], Op: Equal})
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			y--
+
+			edits = append(edits, Edit{Line: b[y], Op: Insert})
+		} else {
+			x--
+
+			edits = append(edits, Edit{Line: a[x], Op: Delete})
+		}
+	}
+
+	slices.Reverse(edits)
+
+	return edits
+}
+
+// hunkRange formats a line range for a unified diff hunk header.
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		// An empty range is reported as the line before the change.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// split splits s into lines. The lines keep their trailing newline characters
+// so that a missing newline at the end of the content is detected as a change.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/diff"
+)
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []diff.Op
+	}{
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: nil,
+		},
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: []diff.Op{diff.Equal, diff.Equal},
+		},
+		{
+			name: "insert",
+			a:    "a\nc\n",
+			b:    "a\nb\nc\n",
+			want: []diff.Op{diff.Equal, diff.Insert, diff.Equal},
+		},
+		{
+			name: "delete",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			want: []diff.Op{diff.Equal, diff.Delete, diff.Equal},
+		},
+		{
+			name: "replace",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: []diff.Op{diff.Equal, diff.Delete, diff.Insert, diff.Equal},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb\n",
+			want: []diff.Op{diff.Insert, diff.Insert},
+		},
+		{
+			name: "to empty",
+			a:    "a\nb\n",
+			b:    "",
+			want: []diff.Op{diff.Delete, diff.Delete},
+		},
+		{
+			name: "missing newline",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: []diff.Op{diff.Equal, diff.Delete, diff.Insert},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			edits := diff.Lines(tt.a, tt.b)
+			if len(edits) != len(tt.want) {
+				t.Fatalf("Lines(%q, %q) returned %d edits, want %d: %v", tt.a, tt.b, len(edits), len(tt.want), edits)
+			}
+
+			var a, b strings.Builder
+
+			for i, e := range edits {
+				if e.Op != tt.want[i] {
+					t.Errorf("Lines(%q, %q)[%d].Op = %v, want %v", tt.a, tt.b, i, e.Op, tt.want[i])
+				}
+
+				if e.Op != diff.Insert {
+					a.WriteString(e.Line)
+				}
+
+				if e.Op != diff.Delete {
+					b.WriteString(e.Line)
+				}
+			}
+
+			if a.String() != tt.a {
+				t.Errorf("Lines(%q, %q) does not reproduce the old content: %q", tt.a, tt.b, a.String())
+			}
+
+			if b.String() != tt.b {
+				t.Errorf("Lines(%q, %q) does not reproduce the new content: %q", tt.a, tt.b, b.String())
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		n    int
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			n:    3,
+			want: "",
+		},
+		{
+			name: "single change",
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			n:    3,
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\n",
+			n:    3,
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "x\n2\n3\n4\n5\n6\n7\ny\n",
+			n:    1,
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+y\n",
+		},
+		{
+			name: "merged hunks",
+			a:    "1\n2\n3\n4\n",
+			b:    "x\n2\n3\ny\n",
+			n:    1,
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n-4\n+y\n",
+		},
+		{
+			name: "no newline",
+			a:    "a\n",
+			b:    "a",
+			n:    3,
+			want: "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := diff.Unified("old", "new", tt.a, tt.b, tt.n); got != tt.want {
+				t.Errorf("Unified() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
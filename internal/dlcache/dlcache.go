@@ -0,0 +1,278 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dlcache implements the download cache that is shared by
+// the operations of Reginald that download artifacts. The downloaded files are
+// stored by their URLs and checksums so that an artifact is downloaded only
+// once even if it is needed by multiple runs or by multiple operations during
+// the same run.
+package dlcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/httpclient"
+	"golang.org/x/sync/singleflight"
+)
+
+// dirPerm is the permissions for the directories in the cache.
+const dirPerm fs.FileMode = 0o755
+
+// Errors returned by the cache.
+var (
+	ErrChecksum   = errors.New("checksum mismatch")
+	errNoChecksum = errors.New("no checksum for the download")
+)
+
+// A Cache is a download cache in a directory. The files in the cache are
+// verified against their checksums every time they are used, so a corrupted
+// file is downloaded again. A Cache is safe for concurrent use, and concurrent
+// requests for the same file share one download.
+type Cache struct {
+	group singleflight.Group // in-flight downloads by their keys
+	dir   fspath.Path        // directory of the cache
+}
+
+// Usage is the disk usage of the cache.
+type Usage struct {
+	Bytes int64 // total size of the files
+	Files int   // number of the files
+}
+
+// New returns a new cache that stores the downloads in dir. The directory is
+// created when the first file is downloaded.
+func New(dir fspath.Path) *Cache {
+	return &Cache{dir: dir} //nolint:exhaustruct // zero group is ready for use
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() fspath.Path {
+	return c.dir
+}
+
+// Fetch returns the path to the cached file that is downloaded from rawURL and
+// has the hexadecimal SHA-256 checksum sum. If the file is not in the cache or
+// it does not match the checksum, it is downloaded and verified first.
+// The returned file must not be modified.
+func (c *Cache) Fetch(ctx context.Context, rawURL, sum string) (fspath.Path, error) {
+	if sum == "" {
+		return "", fmt.Errorf("%w: %s", errNoChecksum, rawURL)
+	}
+
+	sum = strings.ToLower(sum)
+	key := cacheKey(rawURL, sum)
+
+	path, err, _ := c.group.Do(key, func() (any, error) {
+		path := c.dir.Join(key[:2], key)
+
+		if ok, err := verify(path, sum); err != nil {
+			return nil, err
+		} else if ok {
+			slog.DebugContext(ctx, "using cached download", "url", rawURL, "path", path)
+
+			return path, nil
+		}
+
+		path, _, err := c.download(ctx, rawURL, sum)
+
+		return path, err
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return path.(fspath.Path), nil //nolint:forcetypeassert // the function returns only paths
+}
+
+// Download downloads the file from rawURL to the cache without a known
+// checksum and returns the path to the cached file and its hexadecimal SHA-256
+// checksum. As the file cannot be looked up without the checksum, it is always
+// downloaded. The checksum should be recorded so that the cached file can be
+// used with [Cache.Fetch] later. The returned file must not be modified.
+func (c *Cache) Download(ctx context.Context, rawURL string) (fspath.Path, string, error) {
+	type result struct {
+		path fspath.Path
+		sum  string
+	}
+
+	res, err, _ := c.group.Do("url "+rawURL, func() (any, error) {
+		path, sum, err := c.download(ctx, rawURL, "")
+
+		return result{path: path, sum: sum}, err
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("%w", err)
+	}
+
+	r := res.(result) //nolint:forcetypeassert // the function returns only results
+
+	return r.path, r.sum, nil
+}
+
+// Size returns the disk usage of the cache.
+func (c *Cache) Size() (Usage, error) {
+	var u Usage
+
+	err := filepath.WalkDir(string(c.dir), func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && name == string(c.dir) {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %q: %w", name, err)
+		}
+
+		u.Bytes += info.Size()
+		u.Files++
+
+		return nil
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to read the download cache %q: %w", c.dir, err)
+	}
+
+	return u, nil
+}
+
+// Clean removes all of the files from the cache and returns the disk usage of
+// the removed files.
+func (c *Cache) Clean() (Usage, error) {
+	u, err := c.Size()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	if err = os.RemoveAll(string(c.dir)); err != nil {
+		return Usage{}, fmt.Errorf("failed to remove the download cache %q: %w", c.dir, err)
+	}
+
+	return u, nil
+}
+
+// cacheKey returns the cache key for the file that is downloaded from rawURL and has
+// the checksum sum.
+func cacheKey(rawURL, sum string) string {
+	h := sha256.Sum256([]byte(sum + " " + rawURL))
+
+	return hex.EncodeToString(h[:])
+}
+
+// download downloads the file from rawURL to the cache and returns the path to
+// the cached file and its checksum. If sum is not empty, the checksum of
+// the file must match it. The file is written to a temporary file first and
+// renamed into place so that a partial download is never in the cache.
+func (c *Cache) download(ctx context.Context, rawURL, sum string) (fspath.Path, string, error) {
+	if err := os.MkdirAll(string(c.dir), dirPerm); err != nil {
+		return "", "", fmt.Errorf("failed to create the download cache directory %q: %w", c.dir, err)
+	}
+
+	body, err := httpclient.Get(ctx, rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("%w", err)
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp(string(c.dir), ".download-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+
+	tmp := f.Name()
+	defer func() {
+		if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.WarnContext(ctx, "failed to remove temporary file", "path", tmp, "err", err)
+		}
+	}()
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), body)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	if sum != "" && got != sum {
+		return "", "", fmt.Errorf("%w for %s: expected %s, got %s", ErrChecksum, rawURL, sum, got)
+	}
+
+	key := cacheKey(rawURL, got)
+	path := c.dir.Join(key[:2], key)
+
+	if err = os.MkdirAll(string(path.Dir()), dirPerm); err != nil {
+		return "", "", fmt.Errorf("failed to create the download cache directory %q: %w", path.Dir(), err)
+	}
+
+	if err = os.Rename(tmp, string(path)); err != nil {
+		return "", "", fmt.Errorf("failed to move the download to %q: %w", path, err)
+	}
+
+	slog.DebugContext(ctx, "cached download", "url", rawURL, "path", path)
+
+	return path, got, nil
+}
+
+// verify reports whether the file at path exists and has the checksum sum.
+// A file that does not match is removed.
+func verify(path fspath.Path, sum string) (bool, error) {
+	f, err := os.Open(string(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to open %q: %w", path, err)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) == sum {
+		return true, nil
+	}
+
+	if err = os.Remove(string(path)); err != nil {
+		return false, fmt.Errorf("failed to remove the corrupted download %q: %w", path, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlcache_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+const content = "artifact"
+
+// server returns a test server that serves content and a counter for
+// the requests.
+func server(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var n atomic.Int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n.Add(1)
+
+		_, _ = io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &n
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	srv, n := server(t)
+	cache := dlcache.New(fspath.Path(filepath.Join(t.TempDir(), "downloads")))
+	sum := checksum(content)
+
+	path, err := cache.Fetch(t.Context(), srv.URL+"/a.tar.gz", sum)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	data, err := os.ReadFile(string(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != content {
+		t.Errorf("cached file = %q, want %q", data, content)
+	}
+
+	if _, err = cache.Fetch(t.Context(), srv.URL+"/a.tar.gz", sum); err != nil {
+		t.Fatalf("Fetch() again error = %v", err)
+	}
+
+	if got := n.Load(); got != 1 {
+		t.Errorf("requests after cached Fetch() = %d, want 1", got)
+	}
+
+	if err = os.WriteFile(string(path), []byte("corrupted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = cache.Fetch(t.Context(), srv.URL+"/a.tar.gz", sum); err != nil {
+		t.Fatalf("Fetch() of corrupted file error = %v", err)
+	}
+
+	if got := n.Load(); got != 2 {
+		t.Errorf("requests after corrupted Fetch() = %d, want 2", got)
+	}
+
+	if _, err = cache.Fetch(t.Context(), srv.URL+"/b.tar.gz", "00"); err == nil {
+		t.Error("Fetch() with checksum mismatch error = nil, want error")
+	}
+
+	if _, err = cache.Fetch(t.Context(), srv.URL+"/b.tar.gz", ""); err == nil {
+		t.Error("Fetch() without checksum error = nil, want error")
+	}
+
+	u, err := cache.Size()
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+
+	if want := (dlcache.Usage{Bytes: int64(len(content)), Files: 1}); u != want {
+		t.Errorf("Size() = %+v, want %+v", u, want)
+	}
+}
+
+func TestFetchConcurrent(t *testing.T) {
+	t.Parallel()
+
+	srv, n := server(t)
+	cache := dlcache.New(fspath.Path(filepath.Join(t.TempDir(), "downloads")))
+	sum := checksum(content)
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := cache.Fetch(t.Context(), srv.URL, sum); err != nil {
+				t.Errorf("Fetch() error = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	before := n.Load()
+
+	if _, err := cache.Fetch(t.Context(), srv.URL, sum); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if got := n.Load(); got != before {
+		t.Errorf("requests after cached Fetch() = %d, want %d", got, before)
+	}
+
+	u, err := cache.Size()
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+
+	if u.Files != 1 {
+		t.Errorf("files in cache = %d, want 1", u.Files)
+	}
+}
+
+func TestClean(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := server(t)
+	dir := fspath.Path(filepath.Join(t.TempDir(), "downloads"))
+	cache := dlcache.New(dir)
+
+	u, err := cache.Clean()
+	if err != nil {
+		t.Fatalf("Clean() of missing cache error = %v", err)
+	}
+
+	if u != (dlcache.Usage{}) {
+		t.Errorf("Clean() of missing cache = %+v, want zero", u)
+	}
+
+	if _, err = cache.Fetch(t.Context(), srv.URL, checksum(content)); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	u, err = cache.Clean()
+	if err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+
+	if want := (dlcache.Usage{Bytes: int64(len(content)), Files: 1}); u != want {
+		t.Errorf("Clean() = %+v, want %+v", u, want)
+	}
+
+	if _, err = os.Stat(string(dir)); err == nil {
+		t.Error("cache directory not removed")
+	}
+}
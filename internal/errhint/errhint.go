@@ -0,0 +1,73 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errhint defines an error wrapper that attaches a hint to an error.
+// The hint tells the user how to fix the problem, and it is printed on its own
+// line after the error message instead of being a part of the message.
+package errhint
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An Error is an error with a hint for the user. Its message is the message of
+// the wrapped error.
+type Error struct {
+	err error
+
+	// Hint is the suggestion for fixing the problem.
+	Hint string
+}
+
+// Wrap returns an error that wraps err and has the given hint. It returns nil
+// if err is nil.
+func Wrap(err error, hint string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{err: err, Hint: hint}
+}
+
+// Wrapf returns an error that wraps err and has the hint formatted according to
+// the format specifier. It returns nil if err is nil.
+func Wrapf(err error, format string, a ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{err: err, Hint: fmt.Sprintf(format, a...)}
+}
+
+// Hint returns the hint of the first error in the tree of err that has a hint.
+// It returns an empty string if no error has one.
+func Hint(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Hint
+	}
+
+	return ""
+}
+
+// Error returns the message of the wrapped error.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
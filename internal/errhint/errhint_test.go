@@ -0,0 +1,51 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errhint_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/errhint"
+)
+
+var errTest = errors.New("test error")
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	if err := errhint.Wrap(nil, "hint"); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+
+	err := fmt.Errorf("outer: %w", errhint.Wrapf(errTest, "use --%s", "wait"))
+
+	if got := err.Error(); got != "outer: test error" {
+		t.Errorf("Error() = %q, want %q", got, "outer: test error")
+	}
+
+	if !errors.Is(err, errTest) {
+		t.Errorf("errors.Is(%v, errTest) = false, want true", err)
+	}
+
+	if got := errhint.Hint(err); got != "use --wait" {
+		t.Errorf("Hint() = %q, want %q", got, "use --wait")
+	}
+
+	if got := errhint.Hint(errTest); got != "" {
+		t.Errorf("Hint(errTest) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events implements the stream of structured events that Reginald
+// writes to a Unix socket during a run so that external user interfaces can
+// observe the run. The events are written as newline-delimited JSON objects.
+// The stream can also be read from, and the lines that are read are used as
+// the answers to the prompts of the run.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Types of the events.
+const (
+	// TypeRun is the type of the events for the start and the end of a run.
+	TypeRun Type = "run"
+
+	// TypeTask is the type of the events for the life cycle of the tasks.
+	TypeTask Type = "task"
+
+	// TypeProgress is the type of the progress messages from the plugins.
+	TypeProgress Type = "progress"
+
+	// TypePrompt is the type of the events that are emitted when the run
+	// waits for an answer to a prompt.
+	TypePrompt Type = "prompt"
+)
+
+// mu guards the default stream.
+var mu sync.Mutex //nolint:gochecknoglobals // used by multiple goroutines
+
+// defaultStream is the stream that the package-level functions write to. It
+// is nil if no events socket is used.
+var defaultStream *Stream //nolint:gochecknoglobals // global event stream
+
+// Type is the type of an [Event].
+type Type string
+
+// An Event is a structured event in a run. Only the fields that are relevant to
+// the type of the event are set.
+type Event struct {
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+
+	// Type is the type of the event.
+	Type Type `json:"type"`
+
+	// Task is the ID of the task instance that the event is for.
+	Task string `json:"task,omitempty"`
+
+	// TaskType is the type of the task instance that the event is for.
+	TaskType string `json:"taskType,omitempty"`
+
+	// Status is the status of the run or the task, for example "started" or
+	// "failed".
+	Status string `json:"status,omitempty"`
+
+	// Error is the error message if the run or the task failed.
+	Error string `json:"error,omitempty"`
+
+	// Plugin is the name of the plugin that sent a progress message.
+	Plugin string `json:"plugin,omitempty"`
+
+	// Message is the progress message.
+	Message string `json:"message,omitempty"`
+
+	// Prompt is the text of the prompt that waits for an answer.
+	Prompt string `json:"prompt,omitempty"`
+
+	// Options are the options of a selection prompt.
+	Options []string `json:"options,omitempty"`
+
+	// Duration is the duration of the task in milliseconds.
+	Duration int64 `json:"durationMs,omitempty"`
+
+	// Stage is the index of the execution stage of the task.
+	Stage int `json:"stage,omitempty"`
+
+	// Changes is the number of changes that the task made.
+	Changes int `json:"changes,omitempty"`
+
+	// Elevated tells whether the task runs with elevated privileges.
+	Elevated bool `json:"elevated,omitempty"`
+
+	// Current is the current step of the progress.
+	Current int `json:"current,omitempty"`
+
+	// Total is the total number of steps of the progress.
+	Total int `json:"total,omitempty"`
+
+	// Secret tells whether the prompt asks for secret input.
+	Secret bool `json:"secret,omitempty"`
+
+	// DryRun tells whether the run is a dry run.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// A Stream writes the events to a connection.
+type Stream struct {
+	conn net.Conn
+	enc  *json.Encoder
+	mu   sync.Mutex
+	err  error // the first write error, after which nothing is written
+}
+
+// Dial connects to the Unix socket at path and returns a stream that writes
+// the events to it.
+func Dial(path string) (*Stream, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the events socket %q: %w", path, err)
+	}
+
+	return NewStream(conn), nil
+}
+
+// NewStream returns a stream that writes the events to conn.
+func NewStream(conn net.Conn) *Stream {
+	return &Stream{conn: conn, enc: json.NewEncoder(conn), mu: sync.Mutex{}, err: nil}
+}
+
+// Emit writes e to s. If Time is not set in e, the current time is used. After
+// a write fails, the events are discarded as the other end is gone.
+func (s *Stream) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+
+	if err := s.enc.Encode(e); err != nil {
+		slog.Warn("failed to write to the events socket, no more events are written", "err", err)
+
+		s.err = err
+	}
+}
+
+// Read reads from the connection of s. It implements [io.Reader] so that
+// the answers to the prompts can be read from the stream.
+func (s *Stream) Read(p []byte) (int, error) {
+	n, err := s.conn.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("failed to read from the events socket: %w", err)
+	}
+
+	return n, err //nolint:wrapcheck // io.EOF must not be wrapped
+}
+
+// Close closes the connection of s.
+func (s *Stream) Close() error {
+	if err := s.conn.Close(); err != nil {
+		return fmt.Errorf("failed to close the events socket: %w", err)
+	}
+
+	return nil
+}
+
+// SetDefault sets s as the stream that the package-level functions write to.
+// Passing nil disables the events.
+func SetDefault(s *Stream) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultStream = s
+}
+
+// Enabled reports whether the events are written to a stream.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return defaultStream != nil
+}
+
+// Emit writes e to the default stream if it is set.
+func Emit(e Event) {
+	mu.Lock()
+	s := defaultStream
+	mu.Unlock()
+
+	if s != nil {
+		s.Emit(e)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/events"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.sock")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	stream, err := events.Dial(path)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		stream.Emit(events.Event{Type: events.TypeTask, Task: "a", Status: "started"}) //nolint:exhaustruct // test
+		stream.Emit(events.Event{Type: events.TypePrompt, Prompt: "Name: "})           //nolint:exhaustruct // test
+	}()
+
+	scanner := bufio.NewScanner(conn)
+
+	for _, want := range []events.Event{
+		{Type: events.TypeTask, Task: "a", Status: "started"}, //nolint:exhaustruct // test
+		{Type: events.TypePrompt, Prompt: "Name: "},           //nolint:exhaustruct // test
+	} {
+		if !scanner.Scan() {
+			t.Fatalf("no event received: %v", scanner.Err())
+		}
+
+		var got events.Event
+		if err = json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+
+		if got.Time.IsZero() {
+			t.Errorf("event %q has no time", scanner.Text())
+		}
+
+		if got.Type != want.Type || got.Task != want.Task || got.Status != want.Status || got.Prompt != want.Prompt {
+			t.Errorf("event = %q, want %+v", scanner.Text(), want)
+		}
+	}
+
+	if _, err = conn.Write([]byte("answer\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil || line != "answer\n" {
+		t.Errorf("read %q, %v, want \"answer\\n\", nil", line, err)
+	}
+
+	if err = stream.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
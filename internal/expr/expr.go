@@ -0,0 +1,330 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expr implements the condition expressions that are used to enable
+// tasks only on some systems.
+//
+// An expression compares variables to string literals and combines
+// the comparisons with logical operators, for example:
+//
+//	os == 'linux' && hostname =~ 'work-.*' && arch == 'arm64'
+//
+// The supported comparison operators are "==" and "!=" for equality and "=~"
+// and "!~" for regular expressions. The regular expressions must match
+// the whole value. The comparisons can be combined with "&&", "||", and "!",
+// and grouped with parentheses. The literals "true" and "false" are also
+// supported. The strings can be quoted with either single or double quotes.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Token kinds.
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenEq
+	tokenNotEq
+	tokenMatch
+	tokenNotMatch
+)
+
+// Errors returned by the evaluation.
+var (
+	ErrSyntax    = errors.New("invalid expression")
+	ErrUnknownID = errors.New("unknown variable")
+)
+
+// A token is a lexical token in an expression.
+type token struct {
+	val  string    // value of the identifier or the string
+	kind tokenKind // kind of the token
+	pos  int       // byte offset of the token in the expression
+}
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+// A parser evaluates an expression while parsing it.
+type parser struct {
+	vars   map[string]string // variables available in the expression
+	tokens []token           // tokens of the expression
+	pos    int               // index of the current token
+}
+
+// Eval evaluates the expression s using the given variables and returns
+// the result. It returns an error if the expression is invalid or it refers to
+// a variable that is not in vars.
+func Eval(s string, vars map[string]string) (bool, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return false, err
+	}
+
+	p := &parser{vars: vars, tokens: tokens, pos: 0}
+
+	result, err := p.or()
+	if err != nil {
+		return false, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return false, fmt.Errorf("%w: unexpected %q at %d", ErrSyntax, t.val, t.pos)
+	}
+
+	return result, nil
+}
+
+// lex splits the expression into tokens.
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{val: "(", kind: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{val: ")", kind: tokenRParen, pos: i})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrSyntax, i)
+			}
+
+			tokens = append(tokens, token{val: s[i+1 : i+1+end], kind: tokenString, pos: i})
+			i += end + 2 //nolint:mnd // skip both of the quotes
+		case isIdentStart(c):
+			start := i
+
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+
+			tokens = append(tokens, token{val: s[start:i], kind: tokenIdent, pos: start})
+		default:
+			t, ok := lexOperator(s, i)
+			if !ok {
+				return nil, fmt.Errorf("%w: unexpected character %q at %d", ErrSyntax, c, i)
+			}
+
+			tokens = append(tokens, t)
+			i += len(t.val)
+		}
+	}
+
+	return append(tokens, token{val: "end of expression", kind: tokenEOF, pos: len(s)}), nil
+}
+
+// lexOperator returns the operator token at index i in s. It reports whether
+// there is an operator at i.
+func lexOperator(s string, i int) (token, bool) {
+	operators := []struct {
+		val  string
+		kind tokenKind
+	}{
+		{"&&", tokenAnd},
+		{"||", tokenOr},
+		{"==", tokenEq},
+		{"!=", tokenNotEq},
+		{"=~", tokenMatch},
+		{"!~", tokenNotMatch},
+		{"!", tokenNot},
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(s[i:], op.val) {
+			return token{val: op.val, kind: op.kind, pos: i}, true
+		}
+	}
+
+	return token{}, false //nolint:exhaustruct // zero value is not used
+}
+
+// and parses and evaluates a conjunction. All of the operands are parsed even
+// if the result is already known so that the syntax errors are always
+// reported.
+func (p *parser) and() (bool, error) {
+	result, err := p.unary()
+	if err != nil {
+		return false, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.pos++
+
+		next, err := p.unary()
+		if err != nil {
+			return false, err
+		}
+
+		result = result && next
+	}
+
+	return result, nil
+}
+
+// comparison parses and evaluates a comparison between a variable or a string
+// and a variable or a string.
+func (p *parser) comparison() (bool, error) {
+	left, err := p.operand()
+	if err != nil {
+		return false, err
+	}
+
+	op := p.next()
+
+	right, err := p.operand()
+	if err != nil {
+		return false, err
+	}
+
+	switch op.kind { //nolint:exhaustive // other tokens are not comparison operators
+	case tokenEq:
+		return left == right, nil
+	case tokenNotEq:
+		return left != right, nil
+	case tokenMatch, tokenNotMatch:
+		re, err := regexp.Compile("^(?:" + right + ")$")
+		if err != nil {
+			return false, fmt.Errorf("%w: invalid regular expression %q: %w", ErrSyntax, right, err)
+		}
+
+		return re.MatchString(left) == (op.kind == tokenMatch), nil
+	default:
+		return false, fmt.Errorf("%w: expected a comparison operator at %d, got %q", ErrSyntax, op.pos, op.val)
+	}
+}
+
+// next returns the current token and advances to the next one.
+func (p *parser) next() token {
+	t := p.peek()
+
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// operand parses an operand of a comparison and returns its value.
+func (p *parser) operand() (string, error) {
+	t := p.next()
+
+	switch t.kind { //nolint:exhaustive // other tokens are not operands
+	case tokenString:
+		return t.val, nil
+	case tokenIdent:
+		v, ok := p.vars[t.val]
+		if !ok {
+			return "", fmt.Errorf("%w: %q at %d", ErrUnknownID, t.val, t.pos)
+		}
+
+		return v, nil
+	default:
+		return "", fmt.Errorf("%w: expected a variable or a string at %d, got %q", ErrSyntax, t.pos, t.val)
+	}
+}
+
+// or parses and evaluates a disjunction.
+func (p *parser) or() (bool, error) {
+	result, err := p.and()
+	if err != nil {
+		return false, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.pos++
+
+		next, err := p.and()
+		if err != nil {
+			return false, err
+		}
+
+		result = result || next
+	}
+
+	return result, nil
+}
+
+// peek returns the current token without advancing.
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// unary parses and evaluates a negation, a group, a boolean literal, or
+// a comparison.
+func (p *parser) unary() (bool, error) {
+	t := p.peek()
+
+	switch {
+	case t.kind == tokenNot:
+		p.pos++
+
+		result, err := p.unary()
+		if err != nil {
+			return false, err
+		}
+
+		return !result, nil
+	case t.kind == tokenLParen:
+		p.pos++
+
+		result, err := p.or()
+		if err != nil {
+			return false, err
+		}
+
+		if c := p.next(); c.kind != tokenRParen {
+			return false, fmt.Errorf("%w: expected \")\" at %d, got %q", ErrSyntax, c.pos, c.val)
+		}
+
+		return result, nil
+	case t.kind == tokenIdent && (t.val == "true" || t.val == "false"):
+		if k := p.tokens[p.pos+1].kind; k != tokenEq && k != tokenNotEq && k != tokenMatch && k != tokenNotMatch {
+			p.pos++
+
+			return t.val == "true", nil
+		}
+
+		return p.comparison()
+	default:
+		return p.comparison()
+	}
+}
+
+// isIdentChar reports whether c can be a part of an identifier.
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.'
+}
+
+// isIdentStart reports whether an identifier can start with c.
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
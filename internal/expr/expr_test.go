@@ -0,0 +1,88 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/expr"
+)
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{
+		"arch":     "arm64",
+		"hostname": "work-laptop",
+		"os":       "linux",
+	}
+
+	tests := []struct {
+		s       string
+		want    bool
+		wantErr error
+	}{
+		{"os == 'linux'", true, nil},
+		{`os == "darwin"`, false, nil},
+		{"os != 'darwin'", true, nil},
+		{"'linux' == os", true, nil},
+		{"hostname =~ 'work-.*'", true, nil},
+		{"hostname =~ 'work'", false, nil},
+		{"hostname !~ 'home-.*'", true, nil},
+		{"os == 'linux' && hostname =~ 'work-.*' && arch == 'arm64'", true, nil},
+		{"os == 'linux' && arch == 'amd64'", false, nil},
+		{"os == 'darwin' || arch == 'arm64'", true, nil},
+		{"os == 'darwin' || os == 'linux' && arch == 'amd64'", false, nil},
+		{"(os == 'darwin' || os == 'linux') && arch == 'arm64'", true, nil},
+		{"!(os == 'linux')", false, nil},
+		{"!os == 'darwin'", true, nil},
+		{"true", true, nil},
+		{"false || true", true, nil},
+		{"", false, expr.ErrSyntax},
+		{"os", false, expr.ErrSyntax},
+		{"os == ", false, expr.ErrSyntax},
+		{"os == 'linux", false, expr.ErrSyntax},
+		{"(os == 'linux'", false, expr.ErrSyntax},
+		{"os == 'linux')", false, expr.ErrSyntax},
+		{"os = 'linux'", false, expr.ErrSyntax},
+		{"hostname =~ '('", false, expr.ErrSyntax},
+		{"kernel == 'linux'", false, expr.ErrUnknownID},
+		{"os == 'darwin' && kernel == 'linux'", false, expr.ErrUnknownID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := expr.Eval(tt.s, vars)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Eval(%q) error = %v, want %v", tt.s, err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Eval(%q) returned unexpected error: %v", tt.s, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
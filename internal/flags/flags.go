@@ -25,6 +25,8 @@ import (
 
 	"github.com/reginald-project/reginald-sdk-go/api"
 	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/typeconv"
 	"github.com/spf13/pflag"
 )
 
@@ -35,6 +37,29 @@ var (
 	errMutuallyExclusive = errors.New("two mutually exclusive flags set at the same time")
 )
 
+// Groups of the flags that the flags are organized in in the help output.
+const (
+	// GroupGlobal is the group of the flags that apply to the whole program.
+	// The flags that are not explicitly put in a group belong to it.
+	GroupGlobal Group = ""
+
+	// GroupAdvanced is the group of the flags that are rarely needed. They are
+	// shown in the help output only when all of the flags are requested.
+	GroupAdvanced Group = "advanced"
+)
+
+// A Group is the group of a flag. The groups are used to organize the flags in
+// the help output.
+type Group string
+
+// CommandGroup returns the group of the flags of the command with the given
+// name. The name should contain the names of the parent commands of
+// the command separated by spaces so that the groups of the commands with
+// the same name do not collide.
+func CommandGroup(name string) Group {
+	return Group("command:" + name)
+}
+
 // A FlagSet is a wrapper of [pflag.FlagSet] that includes the [Flag] objects
 // that corresponds to the flags in the wrapped flag set. It keeps the sets in
 // sync.
@@ -43,6 +68,14 @@ type FlagSet struct {
 
 	flags map[string]*Flag
 
+	// groups contains the groups of the flags by the names of the flags.
+	// The flags that are not in the map belong to [GroupGlobal].
+	groups map[string]Group
+
+	// inverted contains the names of the flags that invert boolean flags by
+	// the names of the flags they invert.
+	inverted map[string]string
+
 	// mutuallyExclusive is the list of flag names that are marked as
 	// mutually exclusive. Each element of the slice is a slice that contains
 	// the full names of the mutually exclusive flags in that group.
@@ -98,15 +131,40 @@ func (f *FlagSet) AddFlagSet(newSet *FlagSet) {
 		}
 	}
 
+	for k, v := range newSet.groups {
+		if f.groups == nil {
+			f.groups = make(map[string]Group)
+		}
+
+		if _, ok := f.groups[k]; !ok {
+			f.groups[k] = v
+		}
+	}
+
+	for k, v := range newSet.inverted {
+		if f.inverted == nil {
+			f.inverted = make(map[string]string)
+		}
+
+		if _, ok := f.inverted[k]; !ok {
+			f.inverted[k] = v
+		}
+	}
+
 	f.mutuallyExclusive = append(f.mutuallyExclusive, newSet.mutuallyExclusive...)
 }
 
 // AddPluginFlag adds a flag to the flag set according to the given ConfigEntry
 // specification from a plugin. If the flag in the config entry does not define
-// a name, the name will be generated from prefix and the key of cfg.
+// a name, the name will be generated from prefix and the key of cfg. The flag
+// is put in the given group. If the flag is negatable according to opts, it
+// must be a boolean flag, and an inverted flag "--no-<name>" that sets
+// the value to false is added with it. The one of the two flags that sets
+// the default value is hidden. The flags of deprecated entries are hidden
+// but they still work.
 //
-//nolint:cyclop,funlen // need to check all of the types
-func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
+//nolint:cyclop,funlen,gocognit,gocyclo,maintidx // need to check all of the types
+func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, opts plugin.EntryOptions, prefix string, group Group) error {
 	if cfg == nil {
 		panic("nil config entry in AddPluginFlag")
 	}
@@ -131,7 +189,10 @@ func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
 		description = cfg.Description
 	}
 
-	// TODO: Add inverted flags.
+	if opts.Negatable && cfg.Type != api.BoolValue {
+		return fmt.Errorf("%w: flag %q is negatable but not a boolean: %v", errInvalidFlagType, name, cfg.Type)
+	}
+
 	switch cfg.Type {
 	case api.BoolListValue:
 		defVal, err := cfg.BoolSlice()
@@ -147,8 +208,37 @@ func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
 		}
 
 		f.BoolP(name, flag.Shorthand, defVal, description, "")
+
+		if opts.Negatable {
+			if err = f.addInvertedFlag(name, defVal); err != nil {
+				return err
+			}
+		}
 	case api.ConfigSliceValue:
 		return fmt.Errorf("%w: flag %q: %v (%T)", errInvalidFlagType, name, cfg.Type, cfg.Value)
+	case plugin.DurationValue:
+		defVal, err := typeconv.ToDuration(cfg.Val)
+		if err != nil {
+			return fmt.Errorf("invalid default value for flag --%s: %w", name, err)
+		}
+
+		f.DurationP(name, flag.Shorthand, defVal, description)
+	case plugin.EnumValue:
+		defVal, ok := cfg.Val.(string)
+		if !ok {
+			return fmt.Errorf("invalid default value for flag --%s: %w: %v (%T)", name, typeconv.ErrConv, cfg.Val, cfg.Val)
+		}
+
+		usage := strings.TrimSpace(description + " (one of: " + strings.Join(opts.Enum, ", ") + ")")
+
+		f.StringP(name, flag.Shorthand, defVal, usage, "")
+	case plugin.FloatValue:
+		defVal, err := typeconv.ToFloat(cfg.Val)
+		if err != nil {
+			return fmt.Errorf("invalid default value for flag --%s: %w", name, err)
+		}
+
+		f.Float64P(name, flag.Shorthand, defVal, description)
 	case api.IntListValue:
 		defVal, err := cfg.IntSlice()
 		if err != nil {
@@ -163,6 +253,19 @@ func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
 		}
 
 		f.IntP(name, flag.Shorthand, defVal, description, "")
+	case plugin.MapValue:
+		m, ok := cfg.Val.(map[string]any)
+		if !ok && cfg.Val != nil {
+			return fmt.Errorf("invalid default value for flag --%s: %w: %v (%T)", name, typeconv.ErrConv, cfg.Val, cfg.Val)
+		}
+
+		// The values given on the command line are always strings.
+		defVal := make(map[string]string, len(m))
+		for k, v := range m {
+			defVal[k] = fmt.Sprint(v)
+		}
+
+		f.StringToStringP(name, flag.Shorthand, defVal, mapUsage(description))
 	case api.PathListValue:
 		slice, err := cfg.StringSlice()
 		if err != nil {
@@ -190,7 +293,20 @@ func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
 			return fmt.Errorf("invalid default value for flag --%s: %w", name, err)
 		}
 
-		f.StringSliceP(name, flag.Shorthand, defVal, description)
+		f.StringSliceP(name, flag.Shorthand, defVal, description, "")
+	case plugin.StringMapValue:
+		defVal := map[string]string{}
+
+		if cfg.Val != nil {
+			var err error
+
+			defVal, err = typeconv.ToStringMap(cfg.Val)
+			if err != nil {
+				return fmt.Errorf("invalid default value for flag --%s: %w", name, err)
+			}
+		}
+
+		f.StringToStringP(name, flag.Shorthand, defVal, mapUsage(description))
 	case api.StringValue:
 		defVal, err := cfg.String()
 		if err != nil {
@@ -202,6 +318,25 @@ func (f *FlagSet) AddPluginFlag(cfg *api.ConfigEntry, prefix string) error {
 		return fmt.Errorf("%w: flag %q: %v (%T)", errInvalidFlagType, name, cfg.Type, cfg.Value)
 	}
 
+	f.SetGroup(group, name)
+
+	inverted := f.InvertedFlag(name)
+	if inverted != "" {
+		f.SetGroup(group, inverted)
+	}
+
+	if opts.IsDeprecated() {
+		for _, n := range []string{name, inverted} {
+			if n == "" {
+				continue
+			}
+
+			if err := f.MarkHidden(n); err != nil {
+				return fmt.Errorf("failed to mark --%s hidden: %w", n, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -237,6 +372,55 @@ func (f *FlagSet) CheckMutuallyExclusive() error {
 	return nil
 }
 
+// FlagUsagesGroup returns a string containing the usage information for
+// the flags in the given group, wrapped to cols columns (0 for no wrapping).
+// The hidden flags are included only if all is true.
+func (f *FlagSet) FlagUsagesGroup(group Group, cols int, all bool) string {
+	groupSet := pflag.NewFlagSet(f.Name(), pflag.ContinueOnError)
+	groupSet.SortFlags = f.SortFlags
+
+	f.VisitAll(func(flag *pflag.Flag) {
+		if f.Group(flag.Name) != group {
+			return
+		}
+
+		if all && flag.Hidden {
+			c := *flag
+			c.Hidden = false
+			flag = &c
+		}
+
+		groupSet.AddFlag(flag)
+	})
+
+	return groupSet.FlagUsagesWrapped(cols)
+}
+
+// Group returns the group of the flag with the given name.
+func (f *FlagSet) Group(name string) Group {
+	return f.groups[name]
+}
+
+// HasGroupFlags reports whether the given group contains any flags that are
+// shown in the help output. The hidden flags are counted only if all is true.
+func (f *FlagSet) HasGroupFlags(group Group, all bool) bool {
+	found := false
+
+	f.VisitAll(func(flag *pflag.Flag) {
+		if f.Group(flag.Name) == group && (all || !flag.Hidden) {
+			found = true
+		}
+	})
+
+	return found
+}
+
+// InvertedFlag returns the name of the flag that inverts the boolean flag with
+// the given name. It returns an empty string if the flag has no inverted flag.
+func (f *FlagSet) InvertedFlag(name string) string {
+	return f.inverted[name]
+}
+
 // MarkMutuallyExclusive marks two or more flags as mutually exclusive so that
 // the program returns an error if the user tries to set them at the same time.
 // This function panics on errors.
@@ -263,6 +447,24 @@ func (f *FlagSet) MutuallyExclusive() [][]string {
 	return f.mutuallyExclusive
 }
 
+// SetGroup puts the flags with the given names in the group. This function
+// panics if any of the flags is not in the flag set.
+func (f *FlagSet) SetGroup(group Group, names ...string) {
+	for _, s := range names {
+		if f := f.Lookup(s); f == nil {
+			panic(fmt.Sprintf("failed to find flag %q while setting its group", s))
+		}
+	}
+
+	if f.groups == nil {
+		f.groups = make(map[string]Group)
+	}
+
+	for _, s := range names {
+		f.groups[s] = group
+	}
+}
+
 // WrapperLookup returns the Flag structure of the named flag, returning nil if
 // none exists.
 func (f *FlagSet) WrapperLookup(name string) *Flag {
@@ -363,7 +565,7 @@ func (f *FlagSet) PathSliceP(name, shorthand string, value []fspath.Path, usage,
 		s = append(s, string(p))
 	}
 
-	p := f.StringSliceP(name, shorthand, s, usage)
+	p := f.FlagSet.StringSliceP(name, shorthand, s, usage)
 
 	flag := f.Lookup(name)
 	if flag == nil {
@@ -403,6 +605,32 @@ func (f *FlagSet) StringP(name, shorthand, value, usage, doc string) *string {
 	return p
 }
 
+// StringSlice defines a string slice flag with specified name, default value,
+// and usage string. The flag is given as a string that has comma-separated
+// values, and the flag can be specified multiple times. The return value is
+// the address of a string slice variable that stores the value of the flag.
+func (f *FlagSet) StringSlice(name string, value []string, usage, doc string) *[]string {
+	return f.StringSliceP(name, "", value, usage, doc)
+}
+
+// StringSliceP is like StringSlice, but accepts a shorthand letter that can be
+// used after a single dash.
+func (f *FlagSet) StringSliceP(name, shorthand string, value []string, usage, doc string) *[]string {
+	p := f.FlagSet.StringSliceP(name, shorthand, value, usage)
+
+	flag := f.Lookup(name)
+	if flag == nil {
+		panic(fmt.Sprintf("received nil flag %q from wrapped flag set", name))
+	}
+
+	f.AddFlag(&Flag{
+		Flag: flag,
+		Doc:  doc,
+	})
+
+	return p
+}
+
 // Var defines a flag with the specified name and usage string. The type and
 // value of the flag are represented by the first argument, of type
 // [pflag.Value], which typically holds a user-defined implementation of
@@ -452,3 +680,41 @@ func (f *FlagSet) GetPathSlice(name string) ([]fspath.Path, error) {
 
 	return result, nil
 }
+
+// addInvertedFlag adds a flag "--no-<name>" that inverts the boolean flag with
+// the given name and default value. The flags are marked as mutually exclusive,
+// and the one that sets the default value is hidden.
+func (f *FlagSet) addInvertedFlag(name string, defVal bool) error {
+	inverted := "no-" + name
+
+	if f := f.Lookup(inverted); f != nil {
+		return fmt.Errorf("%w: %s", errDuplicateFlag, f.Name)
+	}
+
+	f.Bool(inverted, !defVal, "set --"+name+" to false", "")
+	f.MarkMutuallyExclusive(name, inverted)
+
+	if f.inverted == nil {
+		f.inverted = make(map[string]string)
+	}
+
+	f.inverted[name] = inverted
+
+	hidden := inverted
+	if defVal {
+		hidden = name
+	}
+
+	if err := f.MarkHidden(hidden); err != nil {
+		return fmt.Errorf("failed to mark --%s hidden: %w", hidden, err)
+	}
+
+	return nil
+}
+
+// mapUsage returns the usage message for a flag of a map value with the format
+// of the value added to the description. The format is also used as
+// the placeholder for the value in the usage messages.
+func mapUsage(description string) string {
+	return strings.TrimSpace(description + " (given as `KEY=VALUE` pairs)")
+}
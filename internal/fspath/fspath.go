@@ -54,7 +54,8 @@ func NewAbs[E ~string](elem ...E) (Path, error) {
 
 // Abs returns an absolute representation of path. Relative paths will be joined
 // with the current working directory. Abs calls Clean on the result. Abs also
-// resolves user home directories and environment variables.
+// resolves user home directories and environment variables. On Windows,
+// the drive letter of the result is converted to upper case.
 func (p Path) Abs() (Path, error) {
 	p = p.ExpandEnv()
 
@@ -72,7 +73,7 @@ func (p Path) Abs() (Path, error) {
 		return "", fmt.Errorf("%w", err)
 	}
 
-	p = Path(absPath)
+	p = normalizeVolume(Path(absPath))
 
 	return p, nil
 }
@@ -197,6 +198,14 @@ func (p Path) String() string {
 	return string(p)
 }
 
+// VolumeName returns the leading volume name of p. Given "C:\foo\bar" it
+// returns "C:" on Windows. Given "\\host\share\foo" it returns
+// "\\host\share". On other platforms it returns "". It wraps
+// [filepath.VolumeName].
+func (p Path) VolumeName() Path {
+	return Path(filepath.VolumeName(string(p)))
+}
+
 // ExpandEnv calls [Path.ExpandEnv].
 func ExpandEnv(p Path) Path {
 	return p.ExpandEnv()
@@ -18,9 +18,19 @@ package fspath
 
 import "os"
 
+// metaChars contains the characters that have a special meaning in the glob
+// patterns.
+const metaChars = "*?[\\"
+
 // expandOSEnv replaces ${var} or $var in the string according to the values of
 // the current environment variables. References to undefined variables are
 // replaced by empty string.
 func expandOSEnv(path Path) Path {
 	return Path(os.ExpandEnv(string(path)))
 }
+
+// normalizeVolume returns path unchanged as there are no volume names outside
+// of Windows.
+func normalizeVolume(path Path) Path {
+	return path
+}
@@ -21,6 +21,11 @@ import (
 	"strings"
 )
 
+// metaChars contains the characters that have a special meaning in the glob
+// patterns. Backslash is the path separator on Windows and it cannot be used
+// for escaping.
+const metaChars = "*?["
+
 // expandOSEnv replaces %var%, ${var}, or $var in the string according to the
 // values of the current environment variables. References to undefined
 // variables are replaced by the empty string.
@@ -34,33 +39,44 @@ func expandOSEnv(path Path) Path {
 	return path
 }
 
+// expandWinEnv replaces %var% in the string according to the values of
+// the current environment variables. An escaped "%%" is replaced by a single
+// percent sign and a percent sign that does not start a variable reference is
+// kept as is.
 func expandWinEnv(s Path) Path {
 	var buf []byte
 	// %% is all ASCII, so bytes are fine for this operation.
 	i := 0
 	for j := 0; j < len(s); j++ {
-		if s[j] == '%' && j+1 < len(s) {
-			if buf == nil {
-				buf = make([]byte, 0, 2*len(s))
-			}
-
-			buf = append(buf, s[i:j]...)
-
-			var k int
-			for k = j + 1; k < len(s) && isAlphaNum(s[k]); k++ {
-			}
-
-			if k == j+1 {
-				buf = append(buf, '%')
-			} else if s[k] == '%' {
-				buf = append(buf, os.Getenv(string(s[j+1:k]))...)
-			} else {
-				buf = append(buf, s[j:k+1]...)
-			}
-
-			j = k + 1
-			i = j
+		if s[j] != '%' {
+			continue
+		}
+
+		k := j + 1
+		for k < len(s) && isAlphaNum(s[k]) {
+			k++
+		}
+
+		if k >= len(s) || s[k] != '%' {
+			j = k - 1
+
+			continue
+		}
+
+		if buf == nil {
+			buf = make([]byte, 0, 2*len(s))
 		}
+
+		buf = append(buf, s[i:j]...)
+
+		if k == j+1 {
+			buf = append(buf, '%')
+		} else {
+			buf = append(buf, os.Getenv(string(s[j+1:k]))...)
+		}
+
+		j = k
+		i = k + 1
 	}
 
 	if buf == nil {
@@ -75,3 +91,14 @@ func expandWinEnv(s Path) Path {
 func isAlphaNum(c byte) bool {
 	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
 }
+
+// normalizeVolume converts the drive letter at the start of path to upper case
+// so that the same paths compare equal regardless of how the drive letter was
+// written.
+func normalizeVolume(path Path) Path {
+	if len(path) < 2 || path[1] != ':' || path[0] < 'a' || path[0] > 'z' {
+		return path
+	}
+
+	return Path(path[0]-'a'+'A') + path[1:]
+}
@@ -98,6 +98,30 @@ func TestAbs(t *testing.T) {
 			home(),
 			false,
 		},
+		{
+			"~/test/file",
+			nil,
+			home() + "\\test\\file",
+			false,
+		},
+		{
+			"c:\\test\\file",
+			nil,
+			"C:\\test\\file",
+			false,
+		},
+		{
+			"C:/test/file",
+			nil,
+			"C:\\test\\file",
+			false,
+		},
+		{
+			"%ENVVAR%\\file",
+			map[string]string{"ENVVAR": "D:\\path"},
+			"D:\\path\\file",
+			false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -169,6 +193,26 @@ func TestExpandEnv(t *testing.T) {
 			map[string]string{},
 			"some/path/var/here",
 		},
+		{
+			"some/path/%WITHVAR",
+			map[string]string{"WITHVAR": "var"},
+			"some/path/%WITHVAR",
+		},
+		{
+			"some/path/%",
+			map[string]string{},
+			"some/path/%",
+		},
+		{
+			"some%/path",
+			map[string]string{},
+			"some%/path",
+		},
+		{
+			"%FIRST%%SECOND%\\here",
+			map[string]string{"FIRST": "a", "SECOND": "b"},
+			"ab\\here",
+		},
 	}
 
 	for _, tt := range tests {
@@ -237,6 +281,31 @@ func TestExpandUser(t *testing.T) {
 	}
 }
 
+func TestVolumeName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path fspath.Path
+		want fspath.Path
+	}{
+		{"C:\\test\\file", "C:"},
+		{"c:/test/file", "c:"},
+		{"\\\\host\\share\\file", "\\\\host\\share"},
+		{"\\test\\file", ""},
+		{"test\\file", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.path), func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.path.VolumeName(); got != tt.want {
+				t.Errorf("VolumeName(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
 func cwd() fspath.Path {
 	path, _ := os.Getwd()
 
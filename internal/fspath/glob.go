@@ -0,0 +1,179 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fspath
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// doubleStar is the pattern element that matches zero or more path elements.
+const doubleStar = "**"
+
+// Glob returns the paths of all files matching the pattern p or nil if there
+// is no matching file. The syntax of the patterns is the same as in
+// [filepath.Match] with the addition of "**" that matches zero or more path
+// elements, including the directories, when it is a complete path element. For
+// example, "dir/**/*.toml" matches all of the TOML files in "dir" and its
+// subdirectories. The results are in lexical order.
+//
+// Glob ignores file system errors such as I/O errors reading directories.
+// The only possible returned error is [filepath.ErrBadPattern], when pattern is
+// malformed.
+func (p Path) Glob() ([]Path, error) {
+	pattern := p.Clean()
+
+	if !strings.Contains(string(pattern), doubleStar) {
+		matches, err := filepath.Glob(string(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return toPaths(matches), nil
+	}
+
+	parts, err := splitPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Path
+
+	root := globRoot(pattern)
+
+	_ = filepath.WalkDir(string(root), func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped like in [filepath.Glob].
+			return nil //nolint:nilerr // errors are ignored by design
+		}
+
+		if matchParts(parts, strings.Split(path, string(filepath.Separator))) {
+			matches = append(matches, Path(path))
+		}
+
+		return nil
+	})
+
+	return matches, nil
+}
+
+// Match reports whether name matches the shell pattern p. The pattern syntax
+// is the same as in [Path.Glob]. The only possible returned error is
+// [filepath.ErrBadPattern], when pattern is malformed.
+func (p Path) Match(name Path) (bool, error) {
+	pattern := p.Clean()
+
+	if !strings.Contains(string(pattern), doubleStar) {
+		ok, err := filepath.Match(string(pattern), string(name.Clean()))
+		if err != nil {
+			return false, fmt.Errorf("%w", err)
+		}
+
+		return ok, nil
+	}
+
+	parts, err := splitPattern(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return matchParts(parts, strings.Split(string(name.Clean()), string(filepath.Separator))), nil
+}
+
+// Glob calls [Path.Glob].
+func Glob(pattern Path) ([]Path, error) {
+	return pattern.Glob()
+}
+
+// Match calls [Path.Match].
+func Match(pattern, name Path) (bool, error) {
+	return pattern.Match(name)
+}
+
+// globRoot returns the longest leading directory of the cleaned pattern that
+// contains no special characters. It is the directory that needs to be walked
+// to find the matches for the pattern.
+func globRoot(pattern Path) Path {
+	i := strings.IndexAny(string(pattern), metaChars)
+	if i == -1 {
+		return pattern
+	}
+
+	return Path(filepath.Dir(string(pattern[:i])))
+}
+
+// matchParts reports whether the path elements in name match the pattern
+// elements in pattern. The pattern elements must be validated before calling
+// matchParts.
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == doubleStar {
+			for i := range len(name) + 1 {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := filepath.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// splitPattern splits the cleaned pattern into its path elements and checks
+// that the elements are valid patterns.
+func splitPattern(pattern Path) ([]string, error) {
+	parts := strings.Split(string(pattern), string(filepath.Separator))
+
+	for _, part := range parts {
+		if part == doubleStar {
+			continue
+		}
+
+		if _, err := filepath.Match(part, ""); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	return parts, nil
+}
+
+// toPaths converts the given strings to paths.
+func toPaths(s []string) []Path {
+	if s == nil {
+		return nil
+	}
+
+	paths := make([]Path, len(s))
+	for i, p := range s {
+		paths[i] = Path(p)
+	}
+
+	return paths
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fspath_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+
+	dir := fspath.Path(t.TempDir())
+
+	for _, name := range []string{"x.toml", "a/y.toml", "a/b/z.toml", "a/b/c.txt", ".hidden/w.toml"} {
+		path := dir.Join(filepath.FromSlash(name))
+
+		if err := os.MkdirAll(string(path.Dir()), 0o700); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(string(path), []byte(name), 0o600); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{"*.toml", []string{"x.toml"}, false},
+		{"*/*.toml", []string{".hidden/w.toml", "a/y.toml"}, false},
+		{"**/*.toml", []string{".hidden/w.toml", "a/b/z.toml", "a/y.toml", "x.toml"}, false},
+		{"a/**/*.toml", []string{"a/b/z.toml", "a/y.toml"}, false},
+		{"a/**", []string{"a", "a/b", "a/b/c.txt", "a/b/z.toml", "a/y.toml"}, false},
+		{"**/b/*", []string{"a/b/c.txt", "a/b/z.toml"}, false},
+		{"**/*.md", nil, false},
+		{"**/[", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := dir.Join(filepath.FromSlash(tt.pattern)).Glob()
+			if err == nil && tt.wantErr {
+				t.Fatal("Glob() succeeded unexpectedly")
+			}
+
+			if err != nil && !tt.wantErr {
+				t.Fatalf("Glob() failed: %v", err)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, filepath.ErrBadPattern) {
+					t.Errorf("Glob() error = %v, want %v", err, filepath.ErrBadPattern)
+				}
+
+				return
+			}
+
+			var want []fspath.Path
+
+			for _, s := range tt.want {
+				want = append(want, dir.Join(filepath.FromSlash(s)))
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("Glob(%q) = %v, want %v", tt.pattern, got, want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.toml", "x.toml", true},
+		{"*.toml", "a/x.toml", false},
+		{"**/*.toml", "x.toml", true},
+		{"**/*.toml", "a/b/x.toml", true},
+		{"a/**/x.toml", "a/x.toml", true},
+		{"a/**/x.toml", "a/b/c/x.toml", true},
+		{"a/**/x.toml", "b/c/x.toml", false},
+		{"a/**", "a", true},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/z/c", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pattern := fspath.Path(filepath.FromSlash(tt.pattern))
+
+			got, err := pattern.Match(fspath.Path(filepath.FromSlash(tt.name)))
+			if err != nil {
+				t.Fatalf("Match() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
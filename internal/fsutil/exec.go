@@ -0,0 +1,37 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"fmt"
+	"os"
+)
+
+// IsExecutable reports whether the file at the given path is a regular file
+// that the current platform can execute. On Unix systems, the file must have
+// an execute permission bit set. On Windows, the file must have an extension
+// that is listed in the "PATHEXT" environment variable.
+func IsExecutable(name string) (bool, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	return isExecutable(name, info), nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPathExt is the list of the executable extensions that is used if
+// "PATHEXT" is not set.
+const defaultPathExt = ".com;.exe;.bat;.cmd"
+
+func isExecutable(name string, _ os.FileInfo) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return false
+	}
+
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = defaultPathExt
+	}
+
+	for e := range strings.SplitSeq(strings.ToLower(pathExt), ";") {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
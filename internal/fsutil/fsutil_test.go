@@ -15,9 +15,14 @@
 package fsutil_test
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/reginald-project/reginald/internal/fsutil"
 )
@@ -136,6 +141,481 @@ func TestID(t *testing.T) {
 	}
 }
 
+func TestLink(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		setup func(t *testing.T) string
+		name  string
+		dir   bool
+	}{
+		{
+			name: "File",
+			dir:  false,
+			setup: func(t *testing.T) string {
+				t.Helper()
+
+				return createTempFile(t, "file1")
+			},
+		},
+		{
+			name: "Directory",
+			dir:  true,
+			setup: func(t *testing.T) string {
+				t.Helper()
+
+				return createTempDir(t, "dir1")
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			oldname := tt.setup(t)
+			newname := filepath.Join(t.TempDir(), "link")
+
+			mode, err := fsutil.Link(oldname, newname)
+			if err != nil {
+				t.Fatalf("Link(%q, %q) failed: %v", oldname, newname, err)
+			}
+
+			info, err := os.Stat(newname)
+			if err != nil {
+				t.Fatalf("Failed to stat link created as %v: %v", mode, err)
+			}
+
+			if info.IsDir() != tt.dir {
+				t.Errorf("Link created as %v: IsDir() = %v, want %v", mode, info.IsDir(), tt.dir)
+			}
+
+			if tt.dir {
+				return
+			}
+
+			data, err := os.ReadFile(newname)
+			if err != nil {
+				t.Fatalf("Failed to read link created as %v: %v", mode, err)
+			}
+
+			if string(data) != filepath.Base(oldname) {
+				t.Errorf("Link created as %v has content %q, want %q", mode, data, filepath.Base(oldname))
+			}
+		})
+	}
+}
+
+func TestIsLink(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		setup func(t *testing.T) string
+		name  string
+	}{
+		{
+			name: "File",
+			setup: func(t *testing.T) string {
+				t.Helper()
+
+				return createTempFile(t, "file1")
+			},
+		},
+		{
+			name: "Directory",
+			setup: func(t *testing.T) string {
+				t.Helper()
+
+				return createTempDir(t, "dir1")
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			oldname := tt.setup(t)
+			newname := filepath.Join(t.TempDir(), "link")
+
+			if ok, err := fsutil.IsLink(oldname, newname); err != nil || ok {
+				t.Errorf("IsLink() before Link = %v, %v, want false, nil", ok, err)
+			}
+
+			mode, err := fsutil.Link(oldname, newname)
+			if err != nil {
+				t.Fatalf("Link(%q, %q) failed: %v", oldname, newname, err)
+			}
+
+			if ok, err := fsutil.IsLink(oldname, newname); err != nil || !ok {
+				t.Errorf("IsLink() for link created as %v = %v, %v, want true, nil", mode, ok, err)
+			}
+
+			other := tt.setup(t)
+			if ok, err := fsutil.IsLink(other, newname); err != nil || ok {
+				t.Errorf("IsLink() for other target = %v, %v, want false, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		setup    func(t *testing.T) string
+		name     string
+		data     string
+		wantPerm os.FileMode
+		backup   bool
+	}{
+		{
+			name:     "New file",
+			data:     "new",
+			wantPerm: 0o600,
+			backup:   false,
+			setup: func(t *testing.T) string {
+				t.Helper()
+
+				return filepath.Join(t.TempDir(), "dir", "file")
+			},
+		},
+		{
+			name:     "Existing file",
+			data:     "new",
+			wantPerm: 0o640,
+			backup:   true,
+			setup: func(t *testing.T) string {
+				t.Helper()
+				path := createTempFile(t, "old")
+
+				if err := os.Chmod(path, 0o640); err != nil {
+					t.Fatalf("Failed to set permissions: %v", err)
+				}
+
+				return path
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := tt.setup(t)
+			backupDir := t.TempDir()
+
+			old, _ := os.ReadFile(path)
+
+			backup, err := fsutil.WriteFile(path, []byte(tt.data), 0o600, backupDir)
+			if err != nil {
+				t.Fatalf("WriteFile() failed: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+
+			if string(data) != tt.data {
+				t.Errorf("File has content %q, want %q", data, tt.data)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat file: %v", err)
+			}
+
+			if runtime.GOOS != "windows" && info.Mode().Perm() != tt.wantPerm {
+				t.Errorf("File has permissions %v, want %v", info.Mode().Perm(), tt.wantPerm)
+			}
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatalf("Failed to read directory: %v", err)
+			}
+
+			if len(entries) != 1 {
+				t.Errorf("Directory has %d entries, want 1", len(entries))
+			}
+
+			if !tt.backup {
+				if backup != "" {
+					t.Errorf("WriteFile() returned backup %q, want none", backup)
+				}
+
+				return
+			}
+
+			if !strings.HasPrefix(backup, backupDir) {
+				t.Errorf("Backup %q is not in %q", backup, backupDir)
+			}
+
+			data, err = os.ReadFile(backup)
+			if err != nil {
+				t.Fatalf("Failed to read backup: %v", err)
+			}
+
+			if string(data) != string(old) {
+				t.Errorf("Backup has content %q, want %q", data, old)
+			}
+		})
+	}
+}
+
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	dir := createTempDir(t, "dir")
+	backupDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("file"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	backup, err := fsutil.Backup(dir, backupDir)
+	if err != nil {
+		t.Fatalf("Backup() failed: %v", err)
+	}
+
+	if filepath.Base(backup) != filepath.Base(dir) {
+		t.Errorf("Backup() = %q, want base name %q", backup, filepath.Base(dir))
+	}
+
+	data, err := os.ReadFile(filepath.Join(backup, "file"))
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+
+	if string(data) != "file" {
+		t.Errorf("Backup has content %q, want %q", data, "file")
+	}
+
+	backup, err = fsutil.Backup(filepath.Join(dir, "missing"), backupDir)
+	if err != nil {
+		t.Fatalf("Backup() failed for missing file: %v", err)
+	}
+
+	if backup != "" {
+		t.Errorf("Backup() = %q for missing file, want empty string", backup)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	path := createTempFile(t, "file")
+	snapshotDir := t.TempDir()
+	backupDir := t.TempDir()
+
+	saved, err := fsutil.Snapshot(path, snapshotDir)
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+
+	if err = os.WriteFile(path, []byte("changed"), 0o600); err != nil {
+		t.Fatalf("Failed to change file: %v", err)
+	}
+
+	// The second snapshot of the same file keeps the original.
+	if again, err := fsutil.Snapshot(path, snapshotDir); err != nil || again != saved {
+		t.Fatalf("Snapshot() = %q, %v, want %q, nil", again, err, saved)
+	}
+
+	if saved, err := fsutil.Snapshot(filepath.Join(filepath.Dir(path), "missing"), snapshotDir); err != nil || saved != "" {
+		t.Fatalf("Snapshot() = %q, %v for missing file, want empty string", saved, err)
+	}
+
+	entries, err := fsutil.ReadJournal(snapshotDir)
+	if err != nil {
+		t.Fatalf("ReadJournal() failed: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Path != path {
+		t.Fatalf("ReadJournal() = %+v, want one entry for %q", entries, path)
+	}
+
+	restored, err := fsutil.Restore(snapshotDir, backupDir)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+
+	if len(restored) != 1 || restored[0] != path {
+		t.Errorf("Restore() = %q, want [%q]", restored, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+
+	if string(data) != "file" {
+		t.Errorf("restored file has content %q, want %q", data, "file")
+	}
+
+	if backups, _ := os.ReadDir(backupDir); len(backups) != 1 {
+		t.Errorf("Restore() made %d backups of the replaced file, want 1", len(backups))
+	}
+
+	if _, err = fsutil.ReadJournal(t.TempDir()); !errors.Is(err, fsutil.ErrNoSnapshot) {
+		t.Errorf("ReadJournal() error = %v, want %v", err, fsutil.ErrNoSnapshot)
+	}
+}
+
+func TestSnapshotData(t *testing.T) {
+	t.Parallel()
+
+	// The file itself is not read, so it does not need to exist.
+	path := filepath.Join(t.TempDir(), "unreadable")
+	snapshotDir := t.TempDir()
+
+	saved, err := fsutil.SnapshotData(path, []byte("original"), snapshotDir)
+	if err != nil {
+		t.Fatalf("SnapshotData() failed: %v", err)
+	}
+
+	// The second snapshot of the same file keeps the original.
+	if _, err = fsutil.SnapshotData(path, []byte("changed"), snapshotDir); err != nil {
+		t.Fatalf("SnapshotData() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatalf("Failed to read the saved copy: %v", err)
+	}
+
+	if string(data) != "original" {
+		t.Errorf("Saved copy has content %q, want %q", data, "original")
+	}
+
+	entries, err := fsutil.ReadJournal(snapshotDir)
+	if err != nil {
+		t.Fatalf("ReadJournal() failed: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Path != path {
+		t.Errorf("ReadJournal() = %+v, want one entry for %q", entries, path)
+	}
+}
+
+func TestIsExecutable(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("executable permissions are not used on Windows")
+	}
+
+	testCases := []struct {
+		name string
+		perm os.FileMode
+		want bool
+	}{
+		{"Executable", 0o700, true},
+		{"GroupExecutable", 0o610, true},
+		{"NotExecutable", 0o600, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := createTempFile(t, "file")
+
+			if err := os.Chmod(path, tt.perm); err != nil {
+				t.Fatalf("Failed to change file mode: %v", err)
+			}
+
+			got, err := fsutil.IsExecutable(path)
+			if err != nil {
+				t.Fatalf("IsExecutable(%q) failed: %v", path, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("IsExecutable(%q) = %v, want %v", path, got, tt.want)
+			}
+		})
+	}
+
+	got, err := fsutil.IsExecutable(t.TempDir())
+	if err != nil {
+		t.Fatalf("IsExecutable() failed for a directory: %v", err)
+	}
+
+	if got {
+		t.Error("IsExecutable() = true for a directory, want false")
+	}
+}
+
+func TestOwner(t *testing.T) {
+	t.Parallel()
+
+	path := createTempFile(t, "file")
+
+	uid, gid, err := fsutil.Owner(path)
+
+	if runtime.GOOS == "windows" {
+		if !errors.Is(err, fsutil.ErrNoOwner) {
+			t.Errorf("Owner(%q) error = %v, want %v", path, err, fsutil.ErrNoOwner)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("Owner(%q) failed: %v", path, err)
+	}
+
+	if uid != os.Geteuid() || gid != os.Getegid() {
+		t.Errorf("Owner(%q) = %d, %d, want %d, %d", path, uid, gid, os.Geteuid(), os.Getegid())
+	}
+
+	if _, _, err = fsutil.Owner(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Owner() succeeded for a missing file")
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "test.lock")
+
+	lock, err := fsutil.TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock(%q) failed: %v", path, err)
+	}
+
+	_, err = fsutil.TryLock(path)
+
+	var lockErr *fsutil.LockError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("TryLock(%q) for a held lock = %v, want *LockError", path, err)
+	}
+
+	if lockErr.PID != os.Getpid() {
+		t.Errorf("LockError.PID = %d, want %d", lockErr.PID, os.Getpid())
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+
+	if _, err = fsutil.Lock(ctx, path); !errors.Is(err, fsutil.ErrLocked) {
+		t.Errorf("Lock(%q) for a held lock = %v, want ErrLocked", path, err)
+	}
+
+	if err = lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+
+	lock, err = fsutil.TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock(%q) after Unlock() failed: %v", path, err)
+	}
+
+	if err = lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+}
+
 func createID(t *testing.T, path string) fsutil.FileID {
 	t.Helper()
 
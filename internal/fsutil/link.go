@@ -0,0 +1,153 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Modes for the links created by [Link].
+const (
+	Symlink  LinkMode = iota // symbolic link
+	Junction                 // directory junction on Windows
+	Copy                     // copy of the file
+)
+
+// LinkMode tells how a link was created by [Link].
+type LinkMode int
+
+// Link creates newname as a link to oldname. It first tries to create
+// a symbolic link. If that fails on Windows, for example because creating
+// symbolic links requires either administrator privileges or Developer Mode,
+// Link falls back to creating a directory junction if oldname is a directory
+// and to copying the file if oldname is a regular file. Link returns the mode
+// that was used to create the link.
+func Link(oldname, newname string) (LinkMode, error) {
+	return link(oldname, newname)
+}
+
+// IsLink reports whether newname is already a link to oldname in any of
+// the modes that [Link] may use. A symbolic link or a junction is a link to
+// oldname if its target is oldname. On Windows where [Link] may fall back to
+// copying the file, a regular file is also a link to oldname if it is the same
+// file or has the same content as oldname.
+func IsLink(oldname, newname string) (bool, error) {
+	if target, err := os.Readlink(newname); err == nil {
+		target = normalizeTarget(target)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(newname), target)
+		}
+
+		return samePath(target, oldname), nil
+	}
+
+	if !copyFallback {
+		return false, nil
+	}
+
+	info, err := os.Lstat(newname)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to get info for %q: %w", newname, err)
+	}
+
+	srcInfo, err := os.Stat(oldname)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to get info for %q: %w", oldname, err)
+	}
+
+	if !info.Mode().IsRegular() || !srcInfo.Mode().IsRegular() || info.Size() != srcInfo.Size() {
+		return false, nil
+	}
+
+	if os.SameFile(info, srcInfo) {
+		return true, nil
+	}
+
+	return sameContent(oldname, newname)
+}
+
+// String returns the string representation of m.
+func (m LinkMode) String() string {
+	switch m {
+	case Symlink:
+		return "symlink"
+	case Junction:
+		return "junction"
+	case Copy:
+		return "copy"
+	default:
+		return fmt.Sprintf("LinkMode(%d)", int(m))
+	}
+}
+
+// copyFile copies the regular file src to dst, preserving the permission bits
+// of src. The file dst must not exist.
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to get info for %q: %w", src, err)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", dst, err)
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+
+		return fmt.Errorf("failed to copy %q to %q: %w", src, dst, err)
+	}
+
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("failed to close %q: %w", dst, err)
+	}
+
+	return nil
+}
+
+// sameContent reports whether the files a and b have the same content.
+func sameContent(a, b string) (bool, error) {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %q: %w", a, err)
+	}
+
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %q: %w", b, err)
+	}
+
+	return bytes.Equal(dataA, dataB), nil
+}
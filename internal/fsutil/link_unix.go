@@ -0,0 +1,42 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// copyFallback tells whether [Link] may copy the file instead of linking it.
+const copyFallback = false
+
+func link(oldname, newname string) (LinkMode, error) {
+	if err := os.Symlink(oldname, newname); err != nil {
+		return Symlink, fmt.Errorf("%w", err)
+	}
+
+	return Symlink, nil
+}
+
+func normalizeTarget(target string) string {
+	return target
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
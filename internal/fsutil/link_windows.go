@@ -0,0 +1,80 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// copyFallback tells whether [Link] may copy the file instead of linking it.
+const copyFallback = true
+
+// ntPathPrefix is the prefix of the NT namespace paths that the targets of
+// junctions may be read as.
+const ntPathPrefix = `\??\`
+
+func link(oldname, newname string) (LinkMode, error) {
+	symlinkErr := os.Symlink(oldname, newname)
+	if symlinkErr == nil {
+		return Symlink, nil
+	}
+
+	info, err := os.Stat(oldname)
+	if err != nil {
+		return Symlink, fmt.Errorf("%w", errors.Join(symlinkErr, err))
+	}
+
+	if info.IsDir() {
+		// Junctions can be created without extra privileges but there is no
+		// function for it in the standard library.
+		cmd := exec.Command("cmd", "/c", "mklink", "/J", newname, oldname) //nolint:gosec // arguments are paths
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return Junction, fmt.Errorf(
+				"failed to create junction %q (%s): %w",
+				newname,
+				out,
+				errors.Join(symlinkErr, err),
+			)
+		}
+
+		return Junction, nil
+	}
+
+	if err = copyFile(oldname, newname); err != nil {
+		return Copy, fmt.Errorf("%w", errors.Join(symlinkErr, err))
+	}
+
+	return Copy, nil
+}
+
+// normalizeTarget converts the target of a symbolic link or a junction to
+// a regular path. Junction targets may be read back as NT namespace paths
+// like "\??\C:\dir".
+func normalizeTarget(target string) string {
+	return strings.TrimPrefix(target, ntPathPrefix)
+}
+
+// samePath reports whether a and b are the same path. Paths on Windows are
+// case-insensitive.
+func samePath(a, b string) bool {
+	return strings.EqualFold(filepath.Clean(a), filepath.Clean(b))
+}
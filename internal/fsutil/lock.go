@@ -0,0 +1,162 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockPollInterval is the interval for trying to acquire a lock again when
+// waiting for it.
+const lockPollInterval = 100 * time.Millisecond
+
+// ErrLocked is returned when a lock file is held by another process.
+var ErrLocked = errors.New("lock file is held by another process")
+
+// A FileLock is an exclusive advisory lock on a lock file. The lock file
+// contains the process ID of the process that holds the lock.
+type FileLock struct {
+	f *os.File
+}
+
+// A LockError is returned when a lock file is held by another process. It
+// wraps [ErrLocked].
+type LockError struct {
+	// Path is the path to the lock file.
+	Path string
+
+	// PID is the process ID of the process that holds the lock. It is zero if
+	// the process ID could not be read from the lock file.
+	PID int
+}
+
+// Error returns the value of e as a string.
+func (e *LockError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%v: %s", ErrLocked, e.Path)
+	}
+
+	return fmt.Sprintf("%v: %s is held by process %d", ErrLocked, e.Path, e.PID)
+}
+
+// Unwrap returns the wrapped error.
+func (*LockError) Unwrap() error {
+	return ErrLocked
+}
+
+// Lock acquires the lock file at path. If another process holds the lock, Lock
+// waits until the lock is released or ctx is done.
+func Lock(ctx context.Context, path string) (*FileLock, error) {
+	for {
+		l, err := TryLock(path)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// TryLock acquires the lock file at path, creating the file and its parent
+// directories if needed. If another process holds the lock, TryLock returns
+// a [LockError] immediately.
+func TryLock(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), DefaultDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory for lock file %q: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, DefaultFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %q: %w", path, err)
+	}
+
+	ok, err := lockFile(f)
+	if err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("failed to lock %q: %w", path, err)
+	}
+
+	if !ok {
+		_ = f.Close()
+
+		return nil, &LockError{Path: path, PID: readPID(path)}
+	}
+
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	if err != nil {
+		_ = unlockFile(f)
+		_ = f.Close()
+
+		return nil, fmt.Errorf("failed to write process ID to lock file %q: %w", path, err)
+	}
+
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place so that another
+// process waiting for the lock does not end up locking a removed file.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+
+	err := l.f.Truncate(0)
+	if unlockErr := unlockFile(l.f); unlockErr != nil && err == nil {
+		err = unlockErr
+	}
+
+	if closeErr := l.f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	l.f = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+
+	return nil
+}
+
+// readPID reads the process ID from the lock file at path. It returns zero if
+// the process ID cannot be read.
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
@@ -0,0 +1,36 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoOwner is returned when the owners of the files are not supported on
+// the current platform.
+var ErrNoOwner = errors.New("file owners are not supported on this platform")
+
+// Owner returns the numeric user and group IDs of the owner of the file at
+// name. It does not follow symbolic links. On Windows, it returns [ErrNoOwner].
+func Owner(name string) (int, int, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return -1, -1, fmt.Errorf("failed to get info for %q: %w", name, err)
+	}
+
+	return owner(info)
+}
@@ -0,0 +1,32 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func owner(info os.FileInfo) (int, int, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, fmt.Errorf("%w: %q", errSysStat, info.Name())
+	}
+
+	return int(stat.Uid), int(stat.Gid), nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Names of the files and directories in a snapshot directory.
+const (
+	// JournalName is the name of the journal file of a snapshot.
+	JournalName = "journal.jsonl"
+
+	// snapshotFiles is the name of the directory within a snapshot directory
+	// that contains the saved files.
+	snapshotFiles = "files"
+)
+
+// snapshotIDFormat is the layout of the timestamps that are used as the IDs of
+// the snapshots.
+const snapshotIDFormat = "20060102T150405.000Z"
+
+// ErrNoSnapshot is returned when a snapshot directory has no journal.
+var ErrNoSnapshot = errors.New("no snapshot found")
+
+// A JournalEntry is a record in the journal of a snapshot. It tells which file
+// was saved to the snapshot before it was replaced or removed.
+type JournalEntry struct {
+	// Time is the time when the file was saved.
+	Time time.Time `json:"time"`
+
+	// Path is the absolute path to the original file.
+	Path string `json:"path"`
+
+	// Backup is the path to the saved copy of the file relative to
+	// the snapshot directory.
+	Backup string `json:"backup"`
+}
+
+// NewSnapshotID returns the ID for a new snapshot of a run that was started at
+// the given time. The ID is also the name of the snapshot directory.
+func NewSnapshotID(t time.Time) string {
+	return t.UTC().Format(snapshotIDFormat)
+}
+
+// ReadJournal reads the journal of the snapshot in dir. It returns
+// [ErrNoSnapshot] if dir does not contain a journal.
+func ReadJournal(dir string) ([]JournalEntry, error) {
+	f, err := os.Open(filepath.Join(dir, JournalName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSnapshot, dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open the snapshot journal in %q: %w", dir, err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e JournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid entry in the snapshot journal in %q: %w", dir, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot journal in %q: %w", dir, err)
+	}
+
+	return entries, nil
+}
+
+// Restore puts the files saved in the snapshot in dir back to their original
+// locations. The files that are at the locations are backed up to backupDir
+// using [Backup] before they are replaced unless backupDir is empty. The files
+// are restored in the reverse order of the journal. Restore returns the paths
+// of the restored files.
+func Restore(dir, backupDir string) ([]string, error) {
+	entries, err := ReadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]string, 0, len(entries))
+
+	for _, e := range slices.Backward(entries) {
+		if _, err = os.Lstat(e.Path); err == nil {
+			if backupDir != "" {
+				if _, err = Backup(e.Path, backupDir); err != nil {
+					return restored, err
+				}
+			}
+
+			if err = os.RemoveAll(e.Path); err != nil {
+				return restored, fmt.Errorf("failed to remove %q: %w", e.Path, err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return restored, fmt.Errorf("failed to get info for %q: %w", e.Path, err)
+		}
+
+		if err = os.MkdirAll(filepath.Dir(e.Path), DefaultDirPerm); err != nil {
+			return restored, fmt.Errorf("failed to create directory for %q: %w", e.Path, err)
+		}
+
+		if err = copyAll(filepath.Join(dir, e.Backup), e.Path); err != nil {
+			return restored, fmt.Errorf("failed to restore %q: %w", e.Path, err)
+		}
+
+		restored = append(restored, e.Path)
+	}
+
+	return restored, nil
+}
+
+// Snapshot saves a copy of the file, directory, or symbolic link at name to
+// the snapshot in dir and records it in the journal of the snapshot so that it
+// can be put back with [Restore]. If the file has already been saved to
+// the snapshot, the first copy is kept as it is the original file. Snapshot
+// returns the path to the copy. If there is no file at name, Snapshot does
+// nothing and returns an empty string.
+func Snapshot(name, dir string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %q: %w", name, err)
+	}
+
+	if _, err = os.Lstat(abs); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to get info for %q: %w", abs, err)
+	}
+
+	return saveSnapshot(abs, dir, func(dst string) error {
+		return copyAll(abs, dst)
+	})
+}
+
+// SnapshotData saves data as the copy of the regular file at name to
+// the snapshot in dir like [Snapshot]. It is used for the files that the user
+// cannot read but whose contents have been read otherwise, for example with
+// sudo. The copy can only be read by the user.
+func SnapshotData(name string, data []byte, dir string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %q: %w", name, err)
+	}
+
+	return saveSnapshot(abs, dir, func(dst string) error {
+		if err := os.WriteFile(dst, data, 0o600); err != nil { //nolint:mnd // private copy
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	})
+}
+
+// saveSnapshot saves the copy of the file at the absolute path abs to
+// the snapshot in dir using save and records it in the journal. The copy is
+// not saved again if the snapshot already has one. It returns the path to
+// the copy.
+func saveSnapshot(abs, dir string, save func(dst string) error) (string, error) {
+	rel := filepath.Join(snapshotFiles, backupPath(abs))
+	dst := filepath.Join(dir, rel)
+
+	if _, err := os.Lstat(dst); err == nil {
+		return dst, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), DefaultDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory for %q: %w", abs, err)
+	}
+
+	if err := save(dst); err != nil {
+		return "", fmt.Errorf("failed to save %q to the snapshot: %w", abs, err)
+	}
+
+	data, err := json.Marshal(JournalEntry{Time: time.Now().UTC(), Path: abs, Backup: rel})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the snapshot journal entry for %q: %w", abs, err)
+	}
+
+	// The entry is written with a single append so that the entries written by
+	// the tasks running concurrently are not mixed.
+	f, err := os.OpenFile(filepath.Join(dir, JournalName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, DefaultFilePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to open the snapshot journal in %q: %w", dir, err)
+	}
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+
+		return "", fmt.Errorf("failed to write the snapshot journal in %q: %w", dir, err)
+	}
+
+	if err = f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close the snapshot journal in %q: %w", dir, err)
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the layout of the timestamps in the names of the backup
+// directories.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Default permissions for the files and directories created by the functions
+// in this package.
+const (
+	DefaultDirPerm  os.FileMode = 0o755
+	DefaultFilePerm os.FileMode = 0o644
+)
+
+// Backup copies the file, directory, or symbolic link at name to a new
+// timestamped directory within dir and returns the path to the backup.
+// The backup is stored using the full absolute path of the original file
+// within the timestamped directory so that the file can be restored to its
+// original location. For example, "/home/user/.zshrc" is backed up to
+// "<dir>/20250102T150405.000000000Z/home/user/.zshrc". If there is no file at
+// name, Backup does nothing and returns an empty string.
+func Backup(name, dir string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %q: %w", name, err)
+	}
+
+	if _, err = os.Lstat(abs); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to get info for %q: %w", abs, err)
+	}
+
+	dst := filepath.Join(dir, time.Now().UTC().Format(backupTimeFormat), backupPath(abs))
+
+	if err = os.MkdirAll(filepath.Dir(dst), DefaultDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create backup directory for %q: %w", abs, err)
+	}
+
+	if err = copyAll(abs, dst); err != nil {
+		return "", fmt.Errorf("failed to back up %q: %w", abs, err)
+	}
+
+	return dst, nil
+}
+
+// WriteFile writes data to the named file atomically. The data is first
+// written to a temporary file in the same directory which is then renamed to
+// name so that an interrupted write never leaves a partially-written file
+// behind. If the file exists, its permission bits are preserved and, if
+// backupDir is not empty, it is backed up using [Backup] before it is
+// replaced. Otherwise, the file is created with permissions perm. The parent
+// directories of the file are created if they don't exist. WriteFile returns
+// the path to the backup or an empty string if no backup was made.
+func WriteFile(name string, data []byte, perm os.FileMode, backupDir string) (string, error) {
+	info, err := os.Stat(name)
+
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+	case errors.Is(err, fs.ErrNotExist):
+		// The file is created.
+	default:
+		return "", fmt.Errorf("failed to get info for %q: %w", name, err)
+	}
+
+	var backup string
+
+	if err == nil && backupDir != "" {
+		if backup, err = Backup(name, backupDir); err != nil {
+			return "", err
+		}
+	}
+
+	dir := filepath.Dir(name)
+
+	if err = os.MkdirAll(dir, DefaultDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create directory for %q: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for %q: %w", name, err)
+	}
+
+	tmpName := tmp.Name()
+
+	// The temporary file is removed if anything fails before the rename.
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+
+		return "", fmt.Errorf("failed to write %q: %w", tmpName, err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return "", fmt.Errorf("failed to sync %q: %w", tmpName, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close %q: %w", tmpName, err)
+	}
+
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return "", fmt.Errorf("failed to set permissions for %q: %w", tmpName, err)
+	}
+
+	if err = os.Rename(tmpName, name); err != nil {
+		return "", fmt.Errorf("failed to replace %q: %w", name, err)
+	}
+
+	return backup, nil
+}
+
+// backupPath returns the relative path that is used for storing the backup of
+// the file at the absolute path abs within a backup directory.
+func backupPath(abs string) string {
+	// The volume name is kept as a directory without the colon so that
+	// the backups of the files on different drives don't collide on Windows.
+	rel := strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	rel = strings.TrimLeft(rel, string(filepath.Separator))
+
+	if vol := strings.TrimSuffix(filepath.VolumeName(abs), ":"); vol != "" {
+		rel = filepath.Join(strings.TrimLeft(vol, `\`), rel)
+	}
+
+	return rel
+}
+
+// copyAll copies the file, directory, or symbolic link at src to dst. Symbolic
+// links are copied as links and directories are copied recursively.
+func copyAll(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read link %q: %w", path, err)
+			}
+
+			if err = os.Symlink(link, target); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return fmt.Errorf("failed to get info for %q: %w", path, err)
+			}
+
+			if err = os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		default:
+			return copyFile(path, target)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient provides the HTTP client that all of the network
+// operations of Reginald use. The client honors the "HTTP_PROXY",
+// "HTTPS_PROXY", and "NO_PROXY" environment variables and the custom CA bundle
+// and the timeouts from the "network" section of the config.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// keepAlive is the interval of the keep-alive probes of the connections.
+const keepAlive = 30 * time.Second
+
+// Errors returned by the client.
+var (
+	errCAFile = errors.New("invalid CA bundle")
+	errStatus = errors.New("unexpected HTTP status")
+)
+
+// client is the client that is used for the requests. It is set by [Init], and
+// a client with the default config is used before that.
+var client atomic.Pointer[http.Client] //nolint:gochecknoglobals // shared client
+
+// Config contains the configuration options for the network operations.
+type Config struct {
+	// CAFile is the file that contains the PEM-encoded certificates of
+	// the certificate authorities that are trusted in addition to the system
+	// certificates.
+	CAFile fspath.Path `mapstructure:"ca-file"`
+
+	// Timeout is the timeout in seconds for a whole request, including reading
+	// the response. Zero means no timeout.
+	Timeout int `mapstructure:"timeout"`
+
+	// ConnectTimeout is the timeout in seconds for establishing
+	// the connections, including the TLS handshakes.
+	ConnectTimeout int `mapstructure:"connect-timeout"`
+}
+
+// DefaultConfig returns the default network configuration.
+func DefaultConfig() Config {
+	return Config{
+		CAFile:         "",
+		Timeout:        300, //nolint:mnd // default timeout in seconds
+		ConnectTimeout: 30,  //nolint:mnd // default connect timeout in seconds
+	}
+}
+
+// Init creates the client from cfg and sets it as the client that is used for
+// the requests.
+func Init(cfg Config) error {
+	c, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	client.Store(c)
+
+	return nil
+}
+
+// New returns a new HTTP client for cfg. The proxies are resolved from
+// the environment variables.
+func New(cfg Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12} //nolint:exhaustruct // defaults for the rest
+
+	if cfg.CAFile != "" {
+		pool, err := certPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	connectTimeout := time.Duration(cfg.ConnectTimeout) * time.Second
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: keepAlive} //nolint:exhaustruct // defaults for the rest
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		panic(fmt.Sprintf("invalid default transport: %T", http.DefaultTransport))
+	}
+
+	transport = transport.Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{ //nolint:exhaustruct // defaults for the rest
+		Transport: transport,
+		Timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}, nil
+}
+
+// Client returns the client that is used for the requests.
+func Client() *http.Client {
+	if c := client.Load(); c != nil {
+		return c
+	}
+
+	c, err := New(DefaultConfig())
+	if err != nil {
+		panic(fmt.Sprintf("failed to create the default HTTP client: %v", err))
+	}
+
+	client.CompareAndSwap(nil, c)
+
+	return client.Load()
+}
+
+// Get sends a GET request to rawURL and returns the body of the response. It
+// returns an error if the status of the response is not 200 OK. The caller
+// must close the body.
+func Get(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %q failed: %w", rawURL, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+
+		return nil, fmt.Errorf("%w: request to %q returned %q", errStatus, rawURL, res.Status)
+	}
+
+	return res.Body, nil
+}
+
+// ReadAll sends a GET request to rawURL and returns the contents of
+// the response.
+func ReadAll(ctx context.Context, rawURL string) ([]byte, error) {
+	body, err := Get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %q: %w", rawURL, err)
+	}
+
+	return data, nil
+}
+
+// certPool returns the system certificate pool with the certificates from
+// the given PEM file added to it.
+func certPool(file fspath.Path) (*x509.CertPool, error) {
+	data, err := os.ReadFile(string(file))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %q: %w", errCAFile, file, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates found in %q", errCAFile, file)
+	}
+
+	return pool, nil
+}
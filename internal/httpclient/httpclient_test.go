@@ -0,0 +1,112 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/httpclient"
+)
+
+func TestReadAll(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	got, err := httpclient.ReadAll(t.Context(), srv.URL+"/ok")
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if string(got) != "hello" {
+		t.Errorf("ReadAll() = %q, want %q", got, "hello")
+	}
+
+	if _, err = httpclient.ReadAll(t.Context(), srv.URL+"/missing"); err == nil {
+		t.Error("ReadAll() of missing page error = nil, want error")
+	}
+}
+
+func TestNewCAFile(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := httpclient.DefaultConfig()
+
+	c, err := httpclient.New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if res, err := c.Get(srv.URL); err == nil {
+		res.Body.Close()
+		t.Error("Get() without the CA error = nil, want error")
+	}
+
+	cfg.CAFile = fspath.Path(caFile)
+
+	c, err = httpclient.New(cfg)
+	if err != nil {
+		t.Fatalf("New() with CA file error = %v", err)
+	}
+
+	res, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() with the CA error = %v", err)
+	}
+
+	res.Body.Close()
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err = os.WriteFile(invalid, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{invalid, filepath.Join(dir, "missing.pem")} {
+		cfg.CAFile = fspath.Path(file)
+
+		if _, err = httpclient.New(cfg); err == nil {
+			t.Errorf("New() with CA file %q error = nil, want error", file)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package logger
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/reginald-project/reginald/internal/fspath"
 )
@@ -24,14 +25,16 @@ import (
 const (
 	defaultPrefix      = "reginald"
 	defaultLogFileName = defaultPrefix + ".log"
+	traceDirName       = "rpc-trace"
 )
 
 // Config contains the configuration options for the logger.
 type Config struct {
-	Format  string `mapstructure:"format"`                    // format of the logs, "json" or "text"
-	Output  string `mapstructure:"output"`                    // destination of the logs
-	Level   Level  `mapstructure:"level"`                     // logging level
-	Enabled bool   `flag:"log,no-log" mapstructure:"enabled"` // whether logging is enabled
+	Format   string `mapstructure:"format"`                     // format of the logs, "json" or "text"
+	Output   string `mapstructure:"output"`                     // destination of the logs
+	Level    Level  `mapstructure:"level"`                      // logging level
+	Enabled  bool   `flag:"log,no-log" mapstructure:"enabled"`  // whether logging is enabled
+	TraceRPC bool   `flag:"trace-rpc" mapstructure:"trace-rpc"` // whether to trace the plugin messages
 }
 
 // DefaultConfig returns the default logging configuration.
@@ -42,10 +45,28 @@ func DefaultConfig() Config {
 	}
 
 	return Config{
-		Enabled: true,
-		Format:  "json",
-		Level:   LevelInfo,
-		Output:  string(logOutput),
+		Enabled:  true,
+		Format:   "json",
+		Level:    LevelInfo,
+		Output:   string(logOutput),
+		TraceRPC: false,
+	}
+}
+
+// TraceDir returns the directory for the trace files of the plugin messages.
+// It is a subdirectory of the directory of the log file, or of the directory of
+// the default log file if the logs are not written to a file.
+func TraceDir(cfg Config) (fspath.Path, error) {
+	switch strings.ToLower(cfg.Output) {
+	case "stderr", "stdout":
+		path, err := DefaultLogOutput()
+		if err != nil {
+			return "", err
+		}
+
+		return path.Dir().Join(traceDirName), nil
+	default:
+		return fspath.Path(cfg.Output).Dir().Join(traceDirName), nil
 	}
 }
 
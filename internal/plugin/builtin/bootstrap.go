@@ -0,0 +1,408 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// defaultBootstrapDir is the default destination directory for the dotfiles
+// repository that is cloned by the "bootstrap" command.
+const defaultBootstrapDir = "~/dotfiles"
+
+// defaultProfile is the name of the profile that uses the default config file
+// of the repository.
+const defaultProfile = "default"
+
+// repoLockFile is the name of the lock file of the plugins in the dotfiles
+// repository.
+const repoLockFile = "reginald.lock"
+
+// errBootstrap is returned when the "bootstrap" command fails.
+var errBootstrap = errors.New("bootstrap failed")
+
+// bootstrapCommand returns the manifest entry for the "bootstrap" command.
+func bootstrapCommand() *api.Command {
+	return &api.Command{
+		Name:        "bootstrap",
+		Usage:       "bootstrap [options] <repo-url>",
+		Description: "Clone a dotfiles repository and apply it.",
+		//nolint:lll
+		Help:    "Clones the dotfiles repository from the given URL to the destination directory, resolves the config file inside it, installs the plugins that are declared in the lock file `reginald.lock` of the repository, and runs `attend` with the repository as the dotfiles directory. The plugins are loaded from the plugin paths set in the config of the repository. If the destination directory already contains a clone of a Git repository, it is updated instead. If the repository contains config files for multiple profiles named `reginald.<profile>.toml`, the profile is selected with `--profile`. In interactive mode, the destination directory and the profile are asked from the user if they are not set.",
+		Manual:  "",
+		Aliases: nil,
+		Config: []api.ConfigEntry{
+			{
+				ConfigValue: api.ConfigValue{
+					KeyVal: api.KeyVal{
+						Value: api.Value{Val: "", Type: api.StringValue},
+						Key:   "dest",
+					},
+					Description: "Directory to clone the repository to.",
+				},
+				Flag: &api.Flag{
+					Name:        "dest",
+					Shorthand:   "",
+					Description: "clone the repository to `<path>` (default \"" + defaultBootstrapDir + "\")",
+					Manual:      "",
+				},
+				EnvOverride: "",
+				FlagOnly:    false,
+			},
+			{
+				ConfigValue: api.ConfigValue{
+					KeyVal: api.KeyVal{
+						Value: api.Value{Val: "", Type: api.StringValue},
+						Key:   "profile",
+					},
+					Description: "Profile to apply from the repository.",
+				},
+				Flag: &api.Flag{
+					Name:        "profile",
+					Shorthand:   "",
+					Description: "apply the config file of `<profile>` from the repository",
+					Manual:      "",
+				},
+				EnvOverride: "",
+				FlagOnly:    false,
+			},
+		},
+		Commands: nil,
+		Args: &api.Arguments{
+			Spec: []api.ArgSpec{
+				{
+					Name:        "repo-url",
+					Description: "URL of the dotfiles repository to clone.",
+				},
+			},
+			Min: 1,
+			Max: 1,
+		},
+	}
+}
+
+// runBootstrap runs the "bootstrap" command that clones the dotfiles
+// repository and applies the config in it.
+func runBootstrap(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(p.Args) != 1 {
+		return fmt.Errorf("%w: expected exactly one repository URL, got %d", errBootstrap, len(p.Args))
+	}
+
+	dest, err := bootstrapDest(ctx, p.Config)
+	if err != nil {
+		return err
+	}
+
+	if err = cloneRepo(ctx, p.Args[0], dest); err != nil {
+		return err
+	}
+
+	file, err := bootstrapProfile(ctx, p.Config, dest)
+	if err != nil {
+		return err
+	}
+
+	if err = installRepoPlugins(ctx, cfg, dest, file); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "applying bootstrapped repository", "dir", dest, "file", file)
+
+	return runRepo(ctx, cfg, dest, file, "attend")
+}
+
+// installRepoPlugins installs the plugins that are declared in the lock file of
+// the repository in dir and that are not installed yet. The plugins are
+// installed by running "plugin install" in a new Reginald process so that they
+// are resolved through the plugin indexes and installed to the plugin search
+// path of the repository's config.
+func installRepoPlugins(ctx context.Context, cfg *config.Config, dir, file fspath.Path) error {
+	ok, err := dir.Join(repoLockFile).IsFile()
+	if err != nil {
+		return fmt.Errorf("failed to check %q: %w", dir.Join(repoLockFile), err)
+	}
+
+	if !ok {
+		slog.DebugContext(ctx, "no plugins declared in the repository", "dir", dir)
+
+		return nil
+	}
+
+	terminal.Progressf("Installing the plugins of the repository\n")
+
+	return runRepo(ctx, cfg, dir, file, "plugin", "install")
+}
+
+// runRepo runs the given command in a new Reginald process with the given
+// directory as the dotfiles directory so that the config, the plugins, and
+// the tasks are resolved from the cloned repository. If file is empty,
+// the config file is resolved from the directory.
+func runRepo(ctx context.Context, cfg *config.Config, dir, file fspath.Path, command ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get the path to the executable: %w", err)
+	}
+
+	args := []string{"--" + config.FlagName("Directory"), string(dir)}
+
+	if file != "" {
+		args = append(args, "--config", string(file))
+	}
+
+	args = append(
+		args,
+		"--"+config.FlagName("BackupDir"),
+		string(cfg.BackupDir),
+		"--"+config.FlagName("KeyFile"),
+		string(cfg.KeyFile),
+	)
+	args = append(args, runFlags(cfg)...)
+	args = append(args, command...)
+
+	// The output of this process must be written before the new process starts
+	// writing to the same terminal.
+	terminal.Flush()
+
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s failed: %w", errBootstrap, strings.Join(command, " "), err)
+	}
+
+	return nil
+}
+
+// runFlags returns the command-line flags that pass the options of
+// the current run that do not depend on the local file system to a new
+// Reginald process.
+func runFlags(cfg *config.Config) []string {
+	args := []string{"--" + config.FlagName("Color"), cfg.Color.String()}
+
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"AssumeDefaults", cfg.AssumeDefaults},
+		{"AssumeYes", cfg.AssumeYes},
+		{"CI", cfg.CI},
+		{"DryRun", cfg.DryRun},
+		{"Interactive", cfg.Interactive},
+		{"Quiet", cfg.Quiet},
+		{"Verbose", cfg.Verbose},
+		{"Wait", cfg.Wait},
+	} {
+		if f.set {
+			args = append(args, "--"+config.FlagName(f.name))
+		}
+	}
+
+	if !cfg.Lock {
+		args = append(args, "--"+config.InvertedFlagName("Lock"))
+	}
+
+	return args
+}
+
+// bootstrapDest returns the destination directory for the repository. If it is
+// not set in the config, it is asked from the user in interactive mode.
+// Otherwise, the default directory is used.
+func bootstrapDest(ctx context.Context, cfg api.KeyValues) (fspath.Path, error) {
+	dest, err := stringConfig(cfg, "dest")
+	if err != nil {
+		return "", err
+	}
+
+	if dest == "" && terminal.Interactive() {
+		dest, err = terminal.Ask(ctx, fmt.Sprintf("Clone the repository to [%s]: ", defaultBootstrapDir))
+		if err != nil {
+			return "", fmt.Errorf("failed to ask the destination directory: %w", err)
+		}
+
+		dest = strings.TrimSpace(dest)
+	}
+
+	if dest == "" {
+		dest = defaultBootstrapDir
+	}
+
+	path, err := fspath.NewAbs(dest)
+	if err != nil {
+		return "", fmt.Errorf("failed to make %q absolute: %w", dest, err)
+	}
+
+	return path, nil
+}
+
+// bootstrapProfile returns the config file in dir for the selected profile.
+// It returns an empty path if the default config file of the repository should
+// be used.
+func bootstrapProfile(ctx context.Context, cfg api.KeyValues, dir fspath.Path) (fspath.Path, error) {
+	profile, err := stringConfig(cfg, "profile")
+	if err != nil {
+		return "", err
+	}
+
+	profiles, err := repoProfiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if profile == "" && len(profiles) > 1 && terminal.Interactive() {
+		profile, err = askProfile(ctx, profiles)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if profile == "" || profile == defaultProfile {
+		return "", nil
+	}
+
+	if !slices.Contains(profiles, profile) {
+		return "", fmt.Errorf(
+			"%w: profile %q not found in %s, available profiles: %s",
+			errBootstrap,
+			profile,
+			dir,
+			strings.Join(profiles, ", "),
+		)
+	}
+
+	return dir.Join(profileFile(profile)), nil
+}
+
+// askProfile asks the user to select one of the given profiles.
+func askProfile(ctx context.Context, profiles []string) (string, error) {
+	i, err := terminal.Select(ctx, "The repository contains multiple profiles. Select profile:", profiles)
+	if err != nil {
+		return "", fmt.Errorf("failed to ask the profile: %w", err)
+	}
+
+	return profiles[i], nil
+}
+
+// cloneRepo clones the Git repository at url to dir. If dir already contains
+// a clone, it is updated instead. The output of Git is shown to the user so
+// that Git can also ask for the credentials if needed.
+func cloneRepo(ctx context.Context, url string, dir fspath.Path) error {
+	var args []string
+
+	ok, err := dir.Join(".git").IsDir()
+	if err != nil {
+		return fmt.Errorf("failed to check %q: %w", dir, err)
+	}
+
+	if ok {
+		terminal.Progressf("Updating the repository in %s\n", dir)
+
+		args = []string{"-C", string(dir), "pull", "--ff-only"}
+	} else {
+		var entries []os.DirEntry
+
+		entries, err = os.ReadDir(string(dir))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read %q: %w", dir, err)
+		}
+
+		if len(entries) > 0 {
+			return fmt.Errorf("%w: destination %s is not empty and not a Git repository", errBootstrap, dir)
+		}
+
+		terminal.Progressf("Cloning %s to %s\n", url, dir)
+
+		// The separator keeps a URL that starts with a dash from being
+		// parsed as an option.
+		args = []string{"clone", "--", url, string(dir)}
+	}
+
+	terminal.Flush()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: git %s failed: %w", errBootstrap, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
+
+// profileFile returns the name of the config file for the given profile.
+func profileFile(profile string) string {
+	return "reginald." + profile + ".toml"
+}
+
+// repoProfiles returns the names of the profiles that have a config file in
+// dir. The default config file is reported as [defaultProfile].
+func repoProfiles(dir fspath.Path) ([]string, error) {
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", dir, err)
+	}
+
+	var profiles []string
+
+	for _, e := range entries {
+		name := e.Name()
+
+		switch {
+		case e.IsDir():
+		case name == "reginald.toml", name == ".reginald.toml":
+			if !slices.Contains(profiles, defaultProfile) {
+				profiles = append(profiles, defaultProfile)
+			}
+		case strings.HasPrefix(name, "reginald.") && strings.HasSuffix(name, ".toml"):
+			profiles = append(profiles, strings.TrimSuffix(strings.TrimPrefix(name, "reginald."), ".toml"))
+		}
+	}
+
+	slices.Sort(profiles)
+
+	return profiles, nil
+}
+
+// stringConfig returns the string value of the given key in cfg. It returns an
+// empty string if the key is not set.
+func stringConfig(cfg api.KeyValues, key string) (string, error) {
+	kv, ok := cfg.Get(key)
+	if !ok {
+		return "", nil
+	}
+
+	s, err := kv.String()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", key, err)
+	}
+
+	return s, nil
+}
@@ -20,23 +20,49 @@
 package builtin
 
 import (
+	"errors"
+
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
 	"github.com/reginald-project/reginald/internal/plugin"
 )
 
+// errElevate is returned when an elevated task is run by a built-in plugin that
+// cannot run its tasks with elevated privileges.
+var errElevate = errors.New("task cannot be elevated")
+
 // Manifests returns the plugin manifests for the built-in plugins.
 func Manifests() []*api.Manifest {
-	return []*api.Manifest{coreManifest(), linkManifest()}
+	return []*api.Manifest{
+		coreManifest(),
+		copyManifest(),
+		defaultsManifest(),
+		linkManifest(),
+		profileManifest(),
+		servicesManifest(),
+	}
 }
 
-// Service returns the service function for the given built-in plugin name.
-func Service(pluginName string) plugin.Service {
-	switch pluginName {
-	case coreManifest().Name:
-		return coreService
-	case linkManifest().Name:
-		return linkService
-	default:
-		panic("invalid built-in plugin name: " + pluginName)
+// Service returns the function that resolves the service function for
+// the given built-in plugin name. The service functions use the given config of
+// the current run.
+func Service(cfg *config.Config) func(pluginName string) plugin.Service {
+	return func(pluginName string) plugin.Service {
+		switch pluginName {
+		case coreManifest().Name:
+			return coreService(cfg)
+		case copyManifest().Name:
+			return copyService(cfg)
+		case defaultsManifest().Name:
+			return defaultsService(cfg)
+		case linkManifest().Name:
+			return linkService(cfg)
+		case profileManifest().Name:
+			return profileService(cfg)
+		case servicesManifest().Name:
+			return servicesService(cfg)
+		default:
+			panic("invalid built-in plugin name: " + pluginName)
+		}
 	}
 }
@@ -0,0 +1,134 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// cacheCommand returns the manifest entry for the "cache" command that
+// contains the commands for managing the download cache.
+func cacheCommand() *api.Command {
+	return &api.Command{
+		Name:        "cache",
+		Usage:       "cache <command>",
+		Description: "Manage the download cache.",
+		//nolint:lll
+		Help:    "Contains the commands for managing the download cache. The artifacts that Reginald downloads, like the plugin archives, are stored in the cache by their URLs and checksums so that they are downloaded only once. The cached files are verified before they are used.",
+		Manual:  "",
+		Aliases: nil,
+		Config:  nil,
+		Commands: []*api.Command{
+			{
+				Name:        "clean",
+				Usage:       "cache clean",
+				Description: "Remove the downloaded files.",
+				Help:        "Removes all of the files from the download cache and prints the amount of disk space freed.",
+				Manual:      "",
+				Aliases:     nil,
+				Config:      nil,
+				Commands:    nil,
+				Args:        nil,
+			},
+			{
+				Name:        "size",
+				Usage:       "cache size",
+				Description: "Print the size of the download cache.",
+				Help:        "Prints the directory of the download cache, the number of files in it, and their total size.",
+				Manual:      "",
+				Aliases:     nil,
+				Config:      nil,
+				Commands:    nil,
+				Args:        nil,
+			},
+		},
+		Args: nil,
+	}
+}
+
+// runCacheClean runs the "cache clean" command that removes the files in
+// the download cache.
+func runCacheClean(ctx context.Context) error {
+	dir, err := config.DownloadCacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get the download cache directory: %w", err)
+	}
+
+	u, err := dlcache.New(dir).Clean()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	slog.InfoContext(ctx, "removed download cache", "dir", dir, "files", u.Files, "bytes", u.Bytes)
+	terminal.Printf("Removed %d files, freed %s\n", u.Files, formatSize(u.Bytes))
+
+	return nil
+}
+
+// runCacheSize runs the "cache size" command that prints the disk usage of
+// the download cache.
+func runCacheSize() error {
+	dir, err := config.DownloadCacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get the download cache directory: %w", err)
+	}
+
+	u, err := dlcache.New(dir).Size()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	terminal.Printf("%s: %d files, %s\n", dir, u.Files, formatSize(u.Bytes))
+
+	return nil
+}
+
+// downloadCache returns the shared download cache. If the cache directory
+// cannot be determined, it returns nil and the files are downloaded without
+// the cache.
+func downloadCache(ctx context.Context) *dlcache.Cache {
+	dir, err := config.DownloadCacheDir()
+	if err != nil {
+		slog.WarnContext(ctx, "download cache is disabled", "err", err)
+
+		return nil
+	}
+
+	return dlcache.New(dir)
+}
+
+// formatSize formats the size of n bytes using the binary units.
+func formatSize(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
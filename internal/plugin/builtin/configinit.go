@@ -0,0 +1,374 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// Constants for generating the config file.
+const (
+	initFileName = "reginald.toml" // name of the generated config file
+	linkTaskType = "link/create"   // task type that creates the links
+	linksKey     = "links"         // config key for the links in the link task
+)
+
+// initIgnorePrefixes contains the prefixes of the files in the dotfiles
+// directory that are never offered as links as they are usually the files of
+// the repository itself.
+//
+//nolint:gochecknoglobals // used like a constant
+var initIgnorePrefixes = []string{"CHANGELOG", "COPYING", "LICENSE", "Makefile", "README", "reginald"}
+
+// runConfigInit runs the "config init" command that interactively generates
+// a starter config file in the dotfiles directory.
+func runConfigInit(ctx context.Context, store *plugin.Store, cfg *config.Config) error {
+	if !terminal.Interactive() {
+		return fmt.Errorf("%w: config init can only be run in interactive mode, run it with --interactive", errConfigCmd)
+	}
+
+	dir, err := askInitDirectory(ctx, cfg.Directory)
+	if err != nil {
+		return err
+	}
+
+	file := dir.Join(initFileName)
+
+	ok, err := file.IsFile()
+	if err != nil {
+		return fmt.Errorf("failed to check config file %q: %w", file, err)
+	}
+
+	if ok {
+		overwrite, err := terminal.ConfirmE(ctx, fmt.Sprintf("Config file %s already exists. Replace it?", file), false)
+		if err != nil {
+			return fmt.Errorf("failed to ask for confirmation: %w", err)
+		}
+
+		if !overwrite {
+			terminal.Println("Config file not written")
+
+			return nil
+		}
+	}
+
+	links, err := askInitLinks(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := askInitTasks(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	if len(links) > 0 && !slices.ContainsFunc(tasks, func(t *plugin.Task) bool { return t.TaskType == linkTaskType }) {
+		if t := store.Task(linkTaskType); t != nil {
+			tasks = append([]*plugin.Task{t}, tasks...)
+		}
+	}
+
+	data, err := initConfig(dir, links, tasks)
+	if err != nil {
+		return err
+	}
+
+	backup, err := fsutil.WriteFile(string(file), data, fsutil.DefaultFilePerm, string(cfg.BackupDir))
+	if err != nil {
+		return fmt.Errorf("failed to write config file at %q: %w", file, err)
+	}
+
+	slog.InfoContext(ctx, "config file generated", "file", file, "backup", backup)
+	terminal.Printf("Config file written to %s\n", file)
+
+	return nil
+}
+
+// askInitDirectory asks the user for the dotfiles directory. The current
+// dotfiles directory is used if the user gives no input.
+func askInitDirectory(ctx context.Context, current fspath.Path) (fspath.Path, error) {
+	input, err := terminal.Ask(ctx, fmt.Sprintf("Dotfiles directory [%s]: ", current))
+	if err != nil {
+		return "", fmt.Errorf("failed to ask the dotfiles directory: %w", err)
+	}
+
+	dir := current
+
+	if input = strings.TrimSpace(input); input != "" {
+		dir = fspath.Path(input)
+	}
+
+	dir, err = dir.Expand()
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %q: %w", dir, err)
+	}
+
+	dir, err = dir.Abs()
+	if err != nil {
+		return "", fmt.Errorf("failed to make %q absolute: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+// askInitLinks offers to scan the dotfiles directory for the files to link and
+// asks the user which of the found files should be linked. It returns the link
+// paths as they should be written in the config file.
+func askInitLinks(ctx context.Context, dir fspath.Path) ([]string, error) {
+	ok, err := dir.IsDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check directory %q: %w", dir, err)
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	scan, err := terminal.ConfirmE(ctx, fmt.Sprintf("Scan %s for files to link?", dir), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask for confirmation: %w", err)
+	}
+
+	if !scan {
+		return nil, nil
+	}
+
+	candidates, err := linkCandidates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		terminal.Printf("No files to link found in %s\n", dir)
+
+		return nil, nil
+	}
+
+	options := make([]string, len(candidates))
+
+	for i, c := range candidates {
+		options[i] = fmt.Sprintf("%s -> %s", c, dir.Join(strings.TrimPrefix(c, "~/.")))
+	}
+
+	selected, err := terminal.MultiSelect(ctx, "Select the files to link:", options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask the files to link: %w", err)
+	}
+
+	links := make([]string, 0, len(selected))
+
+	for _, i := range selected {
+		links = append(links, candidates[i])
+	}
+
+	return links, nil
+}
+
+// askInitTasks asks the user which of the task types available in the store
+// should be included in the config file.
+func askInitTasks(ctx context.Context, store *plugin.Store) ([]*plugin.Task, error) {
+	types := slices.Collect(store.TaskTypes())
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	options := make([]string, len(types))
+
+	for i, t := range types {
+		options[i] = fmt.Sprintf("%s: %s", t.TaskType, t.Description)
+	}
+
+	selected, err := terminal.MultiSelect(ctx, "Select the task types to include:", options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask the task types: %w", err)
+	}
+
+	tasks := make([]*plugin.Task, 0, len(selected))
+
+	for _, i := range selected {
+		tasks = append(tasks, types[i])
+	}
+
+	return tasks, nil
+}
+
+// linkCandidates returns the files in the top level of the dotfiles directory
+// that look like dotfiles as link paths in the user's home directory. The link
+// task resolves "~/.name" to "name" in the dotfiles directory so the hidden
+// files and the files of the repository itself are skipped.
+func linkCandidates(dir fspath.Path) ([]string, error) {
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %q: %w", dir, err)
+	}
+
+	var candidates []string
+
+	for _, e := range entries {
+		name := e.Name()
+
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".md") {
+			continue
+		}
+
+		if slices.ContainsFunc(initIgnorePrefixes, func(p string) bool { return strings.HasPrefix(name, p) }) {
+			continue
+		}
+
+		candidates = append(candidates, "~/."+name)
+	}
+
+	return candidates, nil
+}
+
+// initConfig returns the contents of the generated config file. The links are
+// written to the link task and the config options of the tasks are written as
+// comments with their default values.
+func initConfig(dir fspath.Path, links []string, tasks []*plugin.Task) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("# Reginald config file generated by `reginald config init`.\n")
+	b.WriteString("#\n")
+	b.WriteString("# The commented options show the default values. Remove the \"#\" in front of\n")
+	b.WriteString("# an option to change it.\n\n")
+	b.WriteString("# The dotfiles directory. The relative paths in the config are resolved from it.\n")
+
+	if err := writeTOMLValue(&b, "directory", string(dir)); err != nil {
+		return nil, err
+	}
+
+	for _, t := range tasks {
+		b.WriteString("\n[[tasks]]\n")
+
+		if t.Description != "" {
+			fmt.Fprintf(&b, "# %s\n", t.Description)
+		}
+
+		if err := writeTOMLValue(&b, "type", t.TaskType); err != nil {
+			return nil, err
+		}
+
+		skip := ""
+
+		if t.TaskType == linkTaskType && len(links) > 0 {
+			if err := writeTOMLValue(&b, linksKey, links); err != nil {
+				return nil, err
+			}
+
+			skip = linksKey
+		}
+
+		for _, c := range t.Config {
+			if err := writeInitConfigType(&b, c, skip, "# "); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// writeInitConfigType writes the given task config option to b as a comment.
+// The option is skipped if its key is skip.
+func writeInitConfigType(b *strings.Builder, c api.ConfigType, skip, prefix string) error {
+	switch c := c.(type) {
+	case api.ConfigValue:
+		if c.Key == skip {
+			return nil
+		}
+
+		if c.Description != "" {
+			fmt.Fprintf(b, "#\n# %s\n", c.Description)
+		}
+
+		b.WriteString(prefix)
+
+		return writeTOMLValue(b, c.Key, c.Val)
+	case api.MappedValue:
+		if c.Key == skip {
+			return nil
+		}
+
+		if c.Description != "" {
+			fmt.Fprintf(b, "#\n# %s\n", c.Description)
+		}
+
+		fmt.Fprintf(b, "%s[tasks.%s.\"<%s>\"]\n", prefix, c.Key, c.KeyType)
+
+		for _, v := range c.Values {
+			b.WriteString(prefix)
+
+			if err := writeTOMLValue(b, v.Key, v.Val); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case api.UnionValue:
+		// Only one of the alternatives can be used so the first one is shown
+		// as the example.
+		for _, alt := range c.Alternatives {
+			if skip != "" && configTypeKey(alt) == skip {
+				return nil
+			}
+		}
+
+		if len(c.Alternatives) > 0 {
+			return writeInitConfigType(b, c.Alternatives[0], skip, prefix)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: invalid config type %T", errConfigCmd, c)
+	}
+}
+
+// configTypeKey returns the key of the given task config option or an empty
+// string if the option has no key.
+func configTypeKey(c api.ConfigType) string {
+	switch c := c.(type) {
+	case api.ConfigValue:
+		return c.Key
+	case api.MappedValue:
+		return c.Key
+	default:
+		return ""
+	}
+}
+
+// writeTOMLValue writes the given key and value to b as a TOML key-value pair.
+func writeTOMLValue(b *strings.Builder, key string, value any) error {
+	data, err := toml.Marshal(map[string]any{key: value})
+	if err != nil {
+		return fmt.Errorf("failed to encode %q as TOML: %w", key, err)
+	}
+
+	b.Write(data)
+
+	return nil
+}
@@ -0,0 +1,742 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/sudo"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+const (
+	copyName     = "reginald-copy" // name of the copy plugin
+	copyTaskType = "copy/files"    // task type that copies the files
+)
+
+// Errors returned by the copy task.
+var (
+	errCopy       = errors.New("cannot copy file")
+	errUnreadable = errors.New("cannot read destination")
+)
+
+// copyManifest returns the manifest for the copy plugin.
+func copyManifest() *api.Manifest {
+	//nolint:lll
+	mode := api.ConfigValue{
+		KeyVal: api.KeyVal{
+			Value: api.Value{Val: "", Type: api.StringValue},
+			Key:   "mode",
+		},
+		Description: "Permission bits of the copied files in octal, e.g. \"0600\". If omitted, the permission bits of the source files are used. The permission bits are not used on Windows.",
+	}
+
+	//nolint:lll
+	owner := api.ConfigValue{
+		KeyVal: api.KeyVal{
+			Value: api.Value{Val: "", Type: api.StringValue},
+			Key:   "owner",
+		},
+		Description: "Owner of the copied files as \"user\", \"user:group\", or \":group\". The users and the groups can be given by their names or IDs. Giving the files to another user requires running Reginald as root. If omitted, the owner is not changed.",
+	}
+
+	return &api.Manifest{
+		Name:        copyName,
+		Version:     version.Version().String(),
+		Domain:      "copy",
+		Description: "The \"reginald-copy\" plugin contains the tasks for copying files with Reginald.",
+		Help:        "",
+		Executable:  "",
+		Runtime:     nil,
+		Config:      nil,
+		Commands:    nil,
+		Tasks: []api.Task{
+			{
+				TaskType:    "files",
+				Description: "Copy files into place.",
+				Provides:    "",
+				RawConfig:   nil,
+				Config: []api.ConfigType{
+					mode,
+					owner,
+					api.UnionValue{
+						Alternatives: []api.ConfigType{
+							api.ConfigValue{
+								KeyVal: api.KeyVal{
+									Value: api.Value{
+										Val:  []string{},
+										Type: api.PathListValue,
+									},
+									Key: "files",
+								},
+								//nolint:lll
+								Description: "List of files to copy where the value is the destination of the copy. The source file is resolved from the destination in the same way as the source of a link. Directories are copied recursively.",
+							},
+							//nolint:lll
+							api.MappedValue{
+								Key:         "files",
+								KeyType:     api.PathValue,
+								Description: "List of files to copy where the key is the destination of the copy. If no `src` is given, the source file is resolved from the destination in the same way as the source of a link. Directories are copied recursively.",
+								Values: []api.ConfigValue{
+									mode,
+									owner,
+									{
+										KeyVal: api.KeyVal{
+											Value: api.Value{
+												Val:  "",
+												Type: api.PathValue,
+											},
+											Key: "src",
+										},
+										Description: "The file or directory to copy. If omitted, it will be resolved from the path given as the key for this table entry.",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// A copySpec is a single file or directory that the "files" task copies.
+type copySpec struct {
+	path    fspath.Path // destination of the copy
+	src     fspath.Path // file or directory to copy
+	mode    os.FileMode // permission bits of the copied files if setMode is true
+	uid     int         // user ID of the owner, or -1 if not changed
+	gid     int         // group ID of the owner, or -1 if not changed
+	setMode bool        // whether mode is used instead of the mode of the source
+	elevate bool        // whether the files are written with sudo
+}
+
+// copyService returns the service function for the "reginald-copy" plugin.
+func copyService(cfg *config.Config) plugin.Service {
+	return func(ctx context.Context, _ *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunTask:
+			p, ok := params.(plugin.RunTaskParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunTaskParams", plugin.ErrInvalidCast)
+			}
+
+			specs, err := parseCopies(p.Config, cfg.Directory)
+			if err != nil {
+				return nil, err
+			}
+
+			var changes []plugin.FileChange
+
+			for _, s := range specs {
+				s.elevate = p.Elevate
+
+				c, err := copyFiles(ctx, s, p.DryRun, p.BackupDir)
+				if err != nil {
+					return nil, err
+				}
+
+				changes = append(changes, c...)
+			}
+
+			return plugin.RunTaskResult{Changes: changes}, nil
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", copyName, method))
+		}
+	}
+}
+
+// copyFiles copies the source of s to its destination. If the source is
+// a directory, the files in it are copied recursively. It returns the changes
+// made to the destination files.
+func copyFiles(ctx context.Context, s copySpec, dryRun bool, backupDir string) ([]plugin.FileChange, error) {
+	info, err := os.Stat(string(s.src))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: source %q does not exist", errCopy, s.src)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get info for %q: %w", s.src, err)
+	}
+
+	if !info.IsDir() {
+		c, err := copyFile(ctx, s, string(s.src), string(s.path), info, dryRun, backupDir)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, nil
+	}
+
+	var changes []plugin.FileChange
+
+	err = filepath.WalkDir(string(s.src), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(string(s.src), path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %q: %w", path, err)
+		}
+
+		// The symbolic links in the directory are followed.
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to get info for %q: %w", path, err)
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		c, err := copyFile(ctx, s, path, filepath.Join(string(s.path), rel), info, dryRun, backupDir)
+		if err != nil {
+			return err
+		}
+
+		changes = append(changes, c...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errCopy, err)
+	}
+
+	return changes, nil
+}
+
+// copyFile copies the file at src to dst if their contents differ, and sets
+// the permission bits and the owner of dst. An existing file that is replaced
+// is saved to the snapshot of the run in backupDir. The change to the contents
+// and the change to the permission bits and the owner are returned
+// separately.
+func copyFile(
+	ctx context.Context,
+	s copySpec,
+	src, dst string,
+	info os.FileInfo,
+	dryRun bool,
+	backupDir string,
+) ([]plugin.FileChange, error) {
+	var changes []plugin.FileChange
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", src, err)
+	}
+
+	perm := info.Mode().Perm()
+	if s.setMode {
+		perm = s.mode
+	}
+
+	old, oldInfo, err := readDestination(ctx, dst, s.elevate)
+
+	// The sudo credentials are not acquired for a dry run, so the destination
+	// may be unreadable. It is then reported as changed as its contents are
+	// unknown.
+	unknown := dryRun && errors.Is(err, errUnreadable)
+	if unknown {
+		slog.WarnContext(ctx, "cannot read destination without sudo, reporting it as changed", "path", dst, "err", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	write := unknown || oldInfo == nil || !oldInfo.Mode().IsRegular() || !bytes.Equal(old, data)
+
+	switch {
+	case unknown:
+		changes = append(changes, plugin.FileChange{
+			Path: dst,
+			Old:  "unknown, cannot read without sudo\n",
+			New:  diffContent(data),
+		})
+	case write:
+		changes = append(changes, plugin.FileChange{Path: dst, Old: diffContent(old), New: diffContent(data)})
+	}
+
+	oldAttrs := ""
+	if oldInfo != nil && oldInfo.Mode().IsRegular() {
+		if oldAttrs, err = fileAttrs(dst, oldInfo.Mode().Perm(), s); err != nil {
+			return nil, err
+		}
+	}
+
+	newAttrs, err := fileAttrs(dst, perm, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The permission bits and the owner are only shown separately for
+	// the existing files.
+	if oldAttrs != "" && oldAttrs != newAttrs {
+		changes = append(changes, plugin.FileChange{Path: dst, Old: oldAttrs, New: newAttrs})
+	}
+
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	if s.elevate {
+		if err = writeElevated(ctx, s, dst, data, old, perm, oldInfo, backupDir, write); err != nil {
+			return nil, err
+		}
+
+		slog.InfoContext(ctx, "file copied as root", "src", src, "path", dst, "mode", perm, "written", write)
+
+		return changes, nil
+	}
+
+	if write {
+		if err = writeCopy(ctx, dst, data, perm, oldInfo, backupDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// The permission bits of an existing file are kept when it is written so
+	// they are always set here.
+	if runtime.GOOS != "windows" {
+		if err = os.Chmod(dst, perm); err != nil {
+			return nil, fmt.Errorf("failed to change the mode of %q: %w", dst, err)
+		}
+	}
+
+	if s.uid != -1 || s.gid != -1 {
+		if err = os.Chown(dst, s.uid, s.gid); err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				return nil, fmt.Errorf(
+					"%w: changing the owner of %q requires running as root or elevate = true: %w",
+					errCopy,
+					dst,
+					err,
+				)
+			}
+
+			return nil, fmt.Errorf("failed to change the owner of %q: %w", dst, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "file copied", "src", src, "path", dst, "mode", perm, "written", write)
+
+	return changes, nil
+}
+
+// readDestination reads the contents and the info of the destination file at
+// path. The returned info is nil if the file does not exist. The contents are
+// only read for regular files. If elevate is true, a file that the user cannot
+// read is read with sudo. Sudo does not ask for the password, so reading fails
+// with errUnreadable if the credentials have not been acquired. The info is
+// still returned then.
+func readDestination(ctx context.Context, path string, elevate bool) ([]byte, os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get info for %q: %w", path, err)
+	}
+
+	if info.IsDir() {
+		return nil, nil, fmt.Errorf("%w: destination %q is a directory", errCopy, path)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, info, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrPermission) && elevate {
+		if data, err = sudo.Command(ctx, "cat", path).Output(); err != nil {
+			return nil, info, fmt.Errorf("%w %q: %w", errUnreadable, path, err)
+		}
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	return data, info, nil
+}
+
+// writeCopy writes data to the destination file at path. The existing file is
+// saved to the snapshot of the run in backupDir first. Other existing files
+// than regular files, like links, are removed so that the copy replaces them
+// instead of the files they point to.
+func writeCopy(ctx context.Context, path string, data []byte, perm os.FileMode, info os.FileInfo, backupDir string) error {
+	if info != nil && backupDir != "" {
+		backup, err := fsutil.Snapshot(path, backupDir)
+		if err != nil {
+			return fmt.Errorf("failed to back up %q: %w", path, err)
+		}
+
+		slog.InfoContext(ctx, "existing file saved to the run snapshot", "path", path, "backup", backup)
+	}
+
+	if info != nil && !info.Mode().IsRegular() {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %q: %w", path, err)
+		}
+	}
+
+	if _, err := fsutil.WriteFile(path, data, perm, ""); err != nil {
+		return fmt.Errorf("%w: failed to write %q: %w", errCopy, path, err)
+	}
+
+	return nil
+}
+
+// writeElevated writes data to the destination file at path with sudo if write
+// is true and sets the permission bits and the owner of the file. The data is
+// first written to a temporary file that is then installed to path so that
+// the contents, the permission bits, and the owner are set with a single
+// command. The existing file is saved to the snapshot of the run in backupDir
+// first. As the user may not be able to read the file, a regular file is saved
+// from its contents old that have been read with sudo.
+func writeElevated(
+	ctx context.Context,
+	s copySpec,
+	path string,
+	data, old []byte,
+	perm os.FileMode,
+	info os.FileInfo,
+	backupDir string,
+	write bool,
+) error {
+	mode := strconv.FormatUint(uint64(perm), 8)
+
+	owner := ""
+	if s.uid != -1 {
+		owner = strconv.Itoa(s.uid)
+	}
+
+	if s.gid != -1 {
+		owner += ":" + strconv.Itoa(s.gid)
+	}
+
+	if !write {
+		if err := runElevated(ctx, "chmod", mode, path); err != nil {
+			return err
+		}
+
+		if owner != "" {
+			return runElevated(ctx, "chown", owner, path)
+		}
+
+		return nil
+	}
+
+	if info != nil && backupDir != "" {
+		var (
+			backup string
+			err    error
+		)
+
+		if info.Mode().IsRegular() {
+			backup, err = fsutil.SnapshotData(path, old, backupDir)
+		} else {
+			backup, err = fsutil.Snapshot(path, backupDir)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to back up %q: %w", path, err)
+		}
+
+		slog.InfoContext(ctx, "existing file saved to the run snapshot", "path", path, "backup", backup)
+	}
+
+	tmp, err := os.CreateTemp("", "reginald-copy-*")
+	if err != nil {
+		return fmt.Errorf("%w: failed to create temporary file: %w", errCopy, err)
+	}
+
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil {
+			slog.WarnContext(ctx, "failed to remove temporary file", "path", tmp.Name(), "err", err)
+		}
+	}()
+
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: failed to write temporary file: %w", errCopy, err)
+	}
+
+	if err = runElevated(ctx, "mkdir", "-p", filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	// The links and other files that are not regular files are removed so
+	// that they are replaced instead of the files they point to.
+	if info != nil && !info.Mode().IsRegular() {
+		if err = runElevated(ctx, "rm", "-f", path); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"-m", mode}
+
+	if s.uid != -1 {
+		args = append(args, "-o", strconv.Itoa(s.uid))
+	}
+
+	if s.gid != -1 {
+		args = append(args, "-g", strconv.Itoa(s.gid))
+	}
+
+	return runElevated(ctx, "install", append(args, tmp.Name(), path)...)
+}
+
+// runElevated runs the given program with sudo. The output of the program is
+// included in the returned error if it fails.
+func runElevated(ctx context.Context, name string, args ...string) error {
+	slog.DebugContext(ctx, "running elevated command", "cmd", name, "args", args)
+
+	out, err := sudo.Command(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("%w: %s failed: %w", errCopy, name, err)
+		}
+
+		return fmt.Errorf("%w: %s failed: %s: %w", errCopy, name, msg, err)
+	}
+
+	return nil
+}
+
+// fileAttrs returns the permission bits and the owner of the file at path as
+// lines of "key = value" for showing the changes to the user. The owner is
+// only included if s changes it. If path does not exist, the owner is shown as
+// the one that s sets.
+func fileAttrs(path string, perm os.FileMode, s copySpec) (string, error) {
+	var b strings.Builder
+
+	if runtime.GOOS != "windows" {
+		fmt.Fprintf(&b, "mode = %04o\n", perm)
+	}
+
+	if s.uid == -1 && s.gid == -1 {
+		return b.String(), nil
+	}
+
+	uid, gid, err := fsutil.Owner(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		uid, gid = s.uid, s.gid
+	} else if err != nil {
+		return "", fmt.Errorf("%w: %w", errCopy, err)
+	}
+
+	if s.uid != -1 {
+		fmt.Fprintf(&b, "uid = %d\n", uid)
+	}
+
+	if s.gid != -1 {
+		fmt.Fprintf(&b, "gid = %d\n", gid)
+	}
+
+	return b.String(), nil
+}
+
+// diffContent returns the contents of a file for showing the changes to
+// the user. The binary files are replaced with a note.
+func diffContent(data []byte) string {
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) != -1 {
+		return fmt.Sprintf("binary file, %d bytes\n", len(data))
+	}
+
+	return string(data)
+}
+
+// parseCopies parses the files to copy from the config of the task.
+func parseCopies(cfg api.KeyValues, dir fspath.Path) ([]copySpec, error) {
+	base := copySpec{path: "", src: "", mode: 0, uid: -1, gid: -1, setMode: false, elevate: false}
+
+	if err := parseCopyAttrs(cfg, &base); err != nil {
+		return nil, err
+	}
+
+	kv, ok := cfg.Get("files")
+	if !ok {
+		return nil, nil
+	}
+
+	if kv.Type == api.PathListValue {
+		paths, ok := kv.Val.([]fspath.Path)
+		if !ok {
+			return nil, fmt.Errorf("%w: \"files\" has invalid type %T", plugin.ErrInvalidCast, kv.Val)
+		}
+
+		specs := make([]copySpec, 0, len(paths))
+
+		for _, path := range paths {
+			src, err := linkSource(path, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			s := base
+			s.path = path
+			s.src = src
+			specs = append(specs, s)
+		}
+
+		return specs, nil
+	}
+
+	entries, err := kv.Configs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read \"files\": %w", err)
+	}
+
+	specs := make([]copySpec, 0, len(entries))
+
+	for _, entry := range entries {
+		s := base
+		s.path = fspath.Path(entry.Key)
+
+		values, err := entry.Configs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %q: %w", entry.Key, err)
+		}
+
+		if err = parseCopyAttrs(values, &s); err != nil {
+			return nil, fmt.Errorf("file %q: %w", entry.Key, err)
+		}
+
+		if kv, ok := values.Get("src"); ok {
+			if s.src, ok = kv.Val.(fspath.Path); !ok {
+				return nil, fmt.Errorf("%w: \"src\" for %q has invalid type %T", plugin.ErrInvalidCast, entry.Key, kv.Val)
+			}
+		}
+
+		if s.src == "" {
+			if s.src, err = linkSource(s.path, dir); err != nil {
+				return nil, err
+			}
+		}
+
+		specs = append(specs, s)
+	}
+
+	return specs, nil
+}
+
+// parseCopyAttrs parses the mode and the owner from cfg to s. The values that
+// are not set in cfg are left as they are in s.
+func parseCopyAttrs(cfg api.KeyValues, s *copySpec) error {
+	if kv, ok := cfg.Get("mode"); ok {
+		v, err := kv.String()
+		if err != nil {
+			return fmt.Errorf("failed to read \"mode\": %w", err)
+		}
+
+		if v != "" {
+			n, err := strconv.ParseUint(v, 8, 32)
+			if err != nil || n > uint64(os.ModePerm) {
+				return fmt.Errorf("%w: invalid mode %q", errCopy, v)
+			}
+
+			s.mode = os.FileMode(n)
+			s.setMode = true
+		}
+	}
+
+	if kv, ok := cfg.Get("owner"); ok {
+		v, err := kv.String()
+		if err != nil {
+			return fmt.Errorf("failed to read \"owner\": %w", err)
+		}
+
+		if v != "" {
+			if s.uid, s.gid, err = lookupOwner(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupOwner resolves the user and the group IDs from an owner given as
+// "user", "user:group", or ":group". The ID is -1 for the part that is not
+// given.
+func lookupOwner(owner string) (int, int, error) {
+	name, group, _ := strings.Cut(owner, ":")
+	uid, gid := -1, -1
+
+	if name != "" {
+		id := name
+
+		if _, err := strconv.Atoi(name); err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return -1, -1, fmt.Errorf("%w: unknown user %q: %w", errCopy, name, err)
+			}
+
+			id = u.Uid
+		}
+
+		var err error
+		if uid, err = strconv.Atoi(id); err != nil {
+			return -1, -1, fmt.Errorf("%w: user %q has a non-numeric ID %q", errCopy, name, id)
+		}
+	}
+
+	if group != "" {
+		id := group
+
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("%w: unknown group %q: %w", errCopy, group, err)
+			}
+
+			id = g.Gid
+		}
+
+		var err error
+		if gid, err = strconv.Atoi(id); err != nil {
+			return -1, -1, fmt.Errorf("%w: group %q has a non-numeric ID %q", errCopy, group, id)
+		}
+	}
+
+	return uid, gid, nil
+}
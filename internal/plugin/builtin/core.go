@@ -16,15 +16,36 @@ package builtin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/pelletier/go-toml/v2"
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/events"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
 	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/secret"
+	"github.com/reginald-project/reginald/internal/terminal"
 	"github.com/reginald-project/reginald/internal/version"
 )
 
 const coreName = "reginald-core"
 
+// lockFileName is the name of the lock file in the state directory that is
+// acquired by the commands that change the system.
+const lockFileName = "reginald.lock"
+
+// errConfigCmd is returned when the "config" commands fail.
+var errConfigCmd = errors.New("config command failed")
+
 // coreManifest returns the manifest for the core plugin.
 func coreManifest() *api.Manifest {
 	return &api.Manifest{
@@ -40,12 +61,109 @@ func coreManifest() *api.Manifest {
 		Commands: []*api.Command{
 			{
 				Name:        "attend",
-				Usage:       "attend",
+				Usage:       "attend [options]",
 				Description: "Execute the tasks.",
 				//nolint:lll
-				Help:     "Executes the tasks defined in the Reginald config file. The order of the tasks is not guaranteed; `attend` may run the tasks in parallel and in any order. However, tasks depending on other tasks are executed after the tasks they depend on. Task dependencies are declared in the `requires` field using the task IDs.",
-				Manual:   "TODO",
-				Aliases:  []string{"apply", "tend"},
+				Help:    "Executes the tasks defined in the Reginald config file. The order of the tasks is not guaranteed; `attend` may run the tasks in parallel and in any order. However, tasks depending on other tasks are executed after the tasks they depend on. Task dependencies are declared in the `requires` field using the task IDs. With `--target`, the tasks are executed on a remote machine over SSH instead: the dotfiles directory and the config file are copied to `~/.local/share/reginald/remote/dotfiles` on the machine, and the output of the run is streamed back. The Reginald installed on the remote machine is used if it is found, and otherwise this executable is copied to the machine if the operating system and the architecture match. The remote run uses the plugins and the key file of the remote machine.",
+				Manual:  "TODO",
+				Aliases: []string{"apply", "tend"},
+				Config: []api.ConfigEntry{
+					{
+						ConfigValue: api.ConfigValue{
+							KeyVal: api.KeyVal{
+								Value: api.Value{Val: "", Type: api.StringValue},
+								Key:   "target",
+							},
+							Description: "Remote machine to execute the tasks on.",
+						},
+						Flag: &api.Flag{
+							Name:        "target",
+							Shorthand:   "",
+							Description: "execute the tasks on the remote machine `<user@host>` over SSH",
+							Manual:      "",
+						},
+						EnvOverride: "",
+						FlagOnly:    true,
+					},
+				},
+				Commands: nil,
+				Args:     nil,
+			},
+			bootstrapCommand(),
+			cacheCommand(),
+			devCommand(),
+			{
+				Name:        "doctor",
+				Usage:       "doctor",
+				Description: "Check the environment for problems.",
+				//nolint:lll
+				Help:     "Checks that the config file is found and valid, that the plugins in the plugin search paths have valid manifests and can be run, that the runtimes required by the plugins are installed, what the terminal supports, and that the log file can be written to. For each problem, a suggestion for fixing it is printed. The command exits with a non-zero exit code if it finds errors.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args:     nil,
+			},
+			{
+				Name:        "config",
+				Usage:       "config <command>",
+				Description: "Manage the config file.",
+				Help:        "Contains the commands for managing the config file.",
+				Manual:      "",
+				Aliases:     nil,
+				Config:      nil,
+				Commands: []*api.Command{
+					{
+						Name:        "decrypt",
+						Usage:       "config decrypt",
+						Description: "Print the decrypted block of the config file.",
+						//nolint:lll
+						Help:     "Decrypts the `encrypted` block in the config file using the key file and prints the decrypted TOML to the standard output. The output can be edited and encrypted again with `config encrypt`.",
+						Manual:   "",
+						Aliases:  nil,
+						Config:   nil,
+						Commands: nil,
+						Args:     nil,
+					},
+					{
+						Name:        "encrypt",
+						Usage:       "config encrypt",
+						Description: "Encrypt the standard input into the config file.",
+						//nolint:lll
+						Help:     "Reads TOML from the standard input, encrypts it using the key file, and stores it as the `encrypted` block in the config file. The values in the block are merged into the config when it is parsed. If the key file does not exist, a new key is generated. The old config file is backed up before it is replaced.",
+						Manual:   "",
+						Aliases:  nil,
+						Config:   nil,
+						Commands: nil,
+						Args:     nil,
+					},
+					{
+						Name:        "init",
+						Usage:       "config init",
+						Description: "Generate a starter config file.",
+						//nolint:lll
+						Help:     "Generates a commented `reginald.toml` interactively. The command asks for the dotfiles directory, offers to scan it for files to link, and lets you pick the task types from the available plugins to include in the config file. If the config file already exists, it is backed up before it is replaced. The command must be run in interactive mode.",
+						Manual:   "",
+						Aliases:  nil,
+						Config:   nil,
+						Commands: nil,
+						Args:     nil,
+					},
+				},
+				Args: nil,
+			},
+			exportCommand(),
+			importCommand(),
+			pluginCommand(),
+			restoreCommand(),
+			{
+				Name:        "validate",
+				Usage:       "validate",
+				Description: "Check the task configs without running the tasks.",
+				//nolint:lll
+				Help:     "Sends the config of each task defined in the Reginald config file to the plugin that provides the task type so that the plugin can check it. The problems found in all of the tasks are reported together, and the command exits with a non-zero exit code if any of the tasks are invalid. Only the plugins that support validation check their tasks. The same validation is done by `attend` before any of the tasks are executed.",
+				Manual:   "",
+				Aliases:  nil,
 				Config:   nil,
 				Commands: nil,
 				Args:     nil,
@@ -62,22 +180,353 @@ func coreManifest() *api.Manifest {
 				Commands:    nil,
 				Args:        nil,
 			},
+			watchCommand(),
 		},
 		Tasks: nil,
 	}
 }
 
-// coreService is the service function for the "reginald-core" plugin.
-func coreService(_ context.Context, _ *plugin.Store, method string, params any) error {
-	switch method {
-	case api.MethodRunCommand:
-		_, ok := params.(api.RunCommandParams)
-		if !ok {
-			return fmt.Errorf("%w: params are not RunCommandParams", plugin.ErrInvalidCast)
+// coreService returns the service function for the "reginald-core" plugin.
+func coreService(cfg *config.Config) plugin.Service {
+	return func(ctx context.Context, store *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunCommand:
+			p, ok := params.(plugin.RunCommandParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunCommandParams", plugin.ErrInvalidCast)
+			}
+
+			switch p.Cmd {
+			case "attend":
+				return nil, runAttend(ctx, store, cfg, p)
+			case "bootstrap":
+				return nil, runBootstrap(ctx, cfg, p)
+			case "cache.clean":
+				return nil, runCacheClean(ctx)
+			case "cache.size":
+				return nil, runCacheSize()
+			case "dev":
+				return nil, runDev(ctx, cfg, p)
+			case "config.decrypt":
+				return nil, runConfigDecrypt(cfg)
+			case "config.encrypt":
+				return nil, runConfigEncrypt(ctx, cfg)
+			case "config.init":
+				return nil, runConfigInit(ctx, store, cfg)
+			case "export":
+				return nil, runExport(ctx, store, cfg, p)
+			case "import.dotbot":
+				return nil, runImportDotbot(ctx, cfg, p)
+			case "import.stow":
+				return nil, runImportStow(ctx, cfg, p)
+			case "plugin.clean":
+				return nil, runPluginClean(ctx, store, p)
+			case "plugin.install":
+				return nil, runPluginInstall(ctx, store, cfg, p)
+			case "plugin.new":
+				return nil, runPluginNew(ctx, p)
+			case "plugin.refresh":
+				return nil, runPluginRefresh(ctx, cfg)
+			case "plugin.search":
+				return nil, runPluginSearch(ctx, cfg, p)
+			case "plugin.test":
+				return nil, runPluginTest(ctx, p)
+			case "plugin.update":
+				return nil, runPluginUpdate(ctx, store, cfg, p)
+			case "restore":
+				return nil, runRestore(ctx, cfg, p)
+			case "validate":
+				return nil, runValidate(ctx, store)
+			case "watch":
+				return nil, runWatch(ctx, store, cfg, p)
+			default:
+				return nil, nil
+			}
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", coreName, method))
 		}
+	}
+}
+
+// runAttend runs the "attend" command that executes the tasks. If a target is
+// given, the tasks are executed on the remote machine instead.
+func runAttend(ctx context.Context, store *plugin.Store, cfg *config.Config, p plugin.RunCommandParams) error {
+	target, err := stringConfig(p.Config, "target")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
 
-		return nil
-	default:
-		panic(fmt.Sprintf("invalid method call to %q: %s", coreName, method))
+	if target != "" {
+		return runRemote(ctx, cfg, target)
 	}
+
+	if err = runTasks(ctx, store, cfg, nil); err != nil {
+		if errors.Is(err, plugin.ErrQuit) {
+			slog.InfoContext(ctx, "user quit the run")
+			terminal.Println("Quitting")
+
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// runTasks runs the tasks with the options from cfg. If only is not empty, only
+// the tasks with the given IDs are run.
+func runTasks(ctx context.Context, store *plugin.Store, cfg *config.Config, only []string) error {
+	// The dry runs do not change anything so they can be run alongside
+	// a regular run.
+	if !cfg.DryRun {
+		unlock, err := lockRun(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// The files that the tasks replace or remove are saved to a snapshot of
+	// the run so that they can be put back with "restore".
+	runID := fsutil.NewSnapshotID(time.Now())
+	opts := plugin.RunOptions{
+		DryRun:    cfg.DryRun,
+		Confirm:   cfg.Interactive,
+		BackupDir: cfg.BackupDir.Join(runID),
+		OnEvent:   nil,
+		Only:      only,
+		OnFailure: cfg.OnFailure,
+	}
+
+	// The summary is printed even if the run fails so that the user can see
+	// which of the tasks were run before the failure.
+	var summary runSummary
+
+	if cfg.Porcelain {
+		opts.OnEvent = printPorcelain
+	} else {
+		opts.OnEvent = summary.add
+		defer printSnapshot(opts.BackupDir, runID)
+		defer summary.print()
+	}
+
+	if events.Enabled() {
+		onEvent := opts.OnEvent
+		opts.OnEvent = func(e plugin.TaskEvent) {
+			onEvent(e)
+			emitTaskEvent(e)
+		}
+	}
+
+	events.Emit(events.Event{ //nolint:exhaustruct // only the fields of the event type are set
+		Type:   events.TypeRun,
+		Status: plugin.TaskStarted.String(),
+		DryRun: cfg.DryRun,
+	})
+
+	err := store.RunTasks(ctx, opts)
+
+	end := events.Event{ //nolint:exhaustruct // only the fields of the event type are set
+		Type:   events.TypeRun,
+		Status: plugin.TaskSucceeded.String(),
+		DryRun: cfg.DryRun,
+	}
+
+	if err != nil {
+		end.Status = plugin.TaskFailed.String()
+		end.Error = err.Error()
+	}
+
+	events.Emit(end)
+
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// emitTaskEvent writes the given task event to the event stream.
+func emitTaskEvent(e plugin.TaskEvent) {
+	event := events.Event{ //nolint:exhaustruct // only the fields of the event type are set
+		Type:     events.TypeTask,
+		Task:     e.ID,
+		TaskType: e.TaskType,
+		Status:   e.Status.String(),
+		Duration: e.Duration.Milliseconds(),
+		Stage:    e.Stage,
+		Changes:  e.Changes,
+		Elevated: e.Elevated,
+	}
+
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+
+	events.Emit(event)
+}
+
+// runValidate runs the "validate" command that validates the tasks without
+// running them.
+func runValidate(ctx context.Context, store *plugin.Store) error {
+	if err := store.ValidateTasks(ctx, plugin.RunOptions{}); err != nil { //nolint:exhaustruct // no options needed
+		return fmt.Errorf("%w", err)
+	}
+
+	terminal.Println("No problems found in the task configs")
+
+	return nil
+}
+
+// runConfigDecrypt runs the "config decrypt" command that prints
+// the decrypted block of the config file.
+func runConfigDecrypt(cfg *config.Config) error {
+	if !cfg.HasFile() {
+		return fmt.Errorf("%w: no config file found", errConfigCmd)
+	}
+
+	value, err := config.EncryptedBlock(cfg.File())
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	key, err := secret.ReadKey(string(cfg.KeyFile))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	data, err := secret.Decrypt(key, value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the encrypted block: %w", err)
+	}
+
+	terminal.Print(string(data))
+
+	return nil
+}
+
+// runConfigEncrypt runs the "config encrypt" command that reads TOML from
+// the standard input and stores it encrypted in the config file.
+func runConfigEncrypt(ctx context.Context, cfg *config.Config) error {
+	if !cfg.HasFile() {
+		return fmt.Errorf("%w: no config file found", errConfigCmd)
+	}
+
+	plaintext, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read the standard input: %w", err)
+	}
+
+	if err = toml.Unmarshal(plaintext, &map[string]any{}); err != nil {
+		return fmt.Errorf("%w: input is not valid TOML: %w", errConfigCmd, err)
+	}
+
+	unlock, err := lockRun(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key, err := readOrGenerateKey(ctx, cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	value, err := secret.Encrypt(key, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt the input: %w", err)
+	}
+
+	file := string(cfg.File())
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config file at %q: %w", file, err)
+	}
+
+	backup, err := fsutil.WriteFile(file, config.SetEncryptedBlock(data, value), fsutil.DefaultFilePerm, string(cfg.BackupDir))
+	if err != nil {
+		return fmt.Errorf("failed to write config file at %q: %w", file, err)
+	}
+
+	slog.InfoContext(ctx, "encrypted block written", "file", file, "backup", backup)
+	terminal.Printf("Encrypted block written to %s\n", file)
+
+	return nil
+}
+
+// readOrGenerateKey reads the key from the key file at path or, if the file
+// does not exist, generates a new key to it.
+func readOrGenerateKey(ctx context.Context, path fspath.Path) ([]byte, error) {
+	ok, err := path.IsFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check key file %q: %w", path, err)
+	}
+
+	if ok {
+		key, err := secret.ReadKey(string(path))
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return key, nil
+	}
+
+	key, err := secret.GenerateKey(string(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	slog.InfoContext(ctx, "generated new key file", "path", path)
+	terminal.Printf("Generated a new key to %s, keep it safe and outside of your dotfiles directory\n", path)
+
+	return key, nil
+}
+
+// lockRun acquires the lock file for a command that changes the system. It
+// returns a function that releases the lock. If locking is disabled, nothing is
+// locked. If another run holds the lock, lockRun fails unless it should wait
+// for the lock.
+func lockRun(ctx context.Context, cfg *config.Config) (func(), error) {
+	if !cfg.Lock {
+		slog.DebugContext(ctx, "locking disabled, skipping lock file")
+
+		return func() {}, nil
+	}
+
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the lock file path: %w", err)
+	}
+
+	path := string(dir.Join(lockFileName))
+
+	lock, err := fsutil.TryLock(path)
+	if errors.Is(err, fsutil.ErrLocked) && cfg.Wait {
+		terminal.Progressf("Waiting for another run to finish (%v)\n", err)
+
+		lock, err = fsutil.Lock(ctx, path)
+	}
+
+	if errors.Is(err, fsutil.ErrLocked) {
+		return nil, errhint.Wrap(err, "wait for the other run to finish or use --wait")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to acquire the lock file: %w", err)
+	}
+
+	slog.DebugContext(ctx, "lock file acquired", "path", path)
+
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			slog.WarnContext(ctx, "failed to release the lock file", "path", path, "err", err)
+		}
+	}, nil
+}
+
+// printPorcelain prints the given task event as a porcelain line. The line
+// contains the task ID, the status, and the duration of the task in
+// milliseconds separated by tabs.
+func printPorcelain(e plugin.TaskEvent) {
+	terminal.PrintPorcelain(e.ID, e.Status.String(), strconv.FormatInt(e.Duration.Milliseconds(), 10))
 }
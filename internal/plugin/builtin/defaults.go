@@ -0,0 +1,612 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+const (
+	defaultsName     = "reginald-defaults" // name of the defaults plugin
+	defaultsTaskType = "defaults/write"    // task type that writes the defaults
+	defaultsProgram  = "defaults"          // the macOS defaults(1) program
+)
+
+// errDefaults is returned when the preferences cannot be read or written.
+var errDefaults = errors.New("cannot write defaults")
+
+// defaultsManifest returns the manifest for the defaults plugin.
+func defaultsManifest() *api.Manifest {
+	return &api.Manifest{
+		Name:    defaultsName,
+		Version: version.Version().String(),
+		Domain:  "defaults",
+		//nolint:lll
+		Description: "The \"reginald-defaults\" plugin contains the tasks for setting the macOS user preferences with defaults(1).",
+		Help:        "",
+		Executable:  "",
+		Runtime:     nil,
+		Config:      nil,
+		Commands:    nil,
+		Tasks: []api.Task{
+			{
+				TaskType:    "write",
+				Description: "Write macOS preferences with defaults(1).",
+				Provides:    "",
+				RawConfig:   nil,
+				Config: []api.ConfigType{
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{
+								Val:  map[string]any{},
+								Type: plugin.MapValue,
+							},
+							Key: "domains",
+						},
+						//nolint:lll
+						Description: "Preferences to write by the domain, e.g. \"com.apple.dock\" or \"NSGlobalDomain\". Each domain is a table of the preference keys and their values. Booleans, integers, floats, and strings are written with the matching type, arrays as arrays, and tables as dictionaries. The current values are read back first, and only the preferences that differ are written. The task is only supported on macOS.",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{
+								Val:  []string{},
+								Type: api.StringListValue,
+							},
+							Key: "restart",
+						},
+						//nolint:lll
+						Description: "Names of the applications to restart with killall(1) after the task has changed any of the preferences, e.g. \"Dock\" or \"Finder\", so that the applications pick up the new values.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// A plistDate is a date value in a property list. The dates can only be read
+// back from the preferences, and they are kept as the text of the property
+// list so that they are not equal to any value from the config.
+type plistDate string
+
+// A defaultsSpec is a single preference that the "write" task writes.
+type defaultsSpec struct {
+	value  any    // value of the preference as a property list value
+	domain string // domain of the preference
+	key    string // key of the preference in the domain
+}
+
+// defaultsService returns the service function for the "reginald-defaults"
+// plugin.
+func defaultsService(_ *config.Config) plugin.Service {
+	return func(ctx context.Context, _ *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunTask:
+			p, ok := params.(plugin.RunTaskParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunTaskParams", plugin.ErrInvalidCast)
+			}
+
+			if p.Elevate {
+				return nil, fmt.Errorf("%w: %s", errElevate, defaultsTaskType)
+			}
+
+			if runtime.GOOS != "darwin" {
+				return nil, fmt.Errorf(
+					"%w: task type %q is only supported on macOS, limit the task with \"platforms\"",
+					errDefaults,
+					defaultsTaskType,
+				)
+			}
+
+			specs, restart, err := parseDefaults(p.Config)
+			if err != nil {
+				return nil, err
+			}
+
+			return writeDefaults(ctx, specs, restart, p.DryRun)
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", defaultsName, method))
+		}
+	}
+}
+
+// writeDefaults writes the preferences that differ from the current values and
+// restarts the given applications if anything was changed. The differing
+// preferences are returned as the changes of the task with the current and
+// the new value as the contents so that the drift can be shown as a diff in
+// dry-run mode.
+func writeDefaults(ctx context.Context, specs []defaultsSpec, restart []string, dryRun bool) (any, error) {
+	var changes []plugin.FileChange
+
+	current := make(map[string]map[string]any)
+
+	for _, s := range specs {
+		values, ok := current[s.domain]
+		if !ok {
+			var err error
+
+			if values, err = readDefaults(ctx, s.domain); err != nil {
+				return nil, err
+			}
+
+			current[s.domain] = values
+		}
+
+		old, ok := values[s.key]
+		if ok && plistEqual(old, s.value) {
+			slog.DebugContext(ctx, "preference already set", "domain", s.domain, "key", s.key)
+
+			continue
+		}
+
+		change := plugin.FileChange{Path: s.domain + " " + s.key, Old: "", New: plistString(s.value) + "\n"}
+		if ok {
+			change.Old = plistString(old) + "\n"
+		}
+
+		changes = append(changes, change)
+
+		if dryRun {
+			continue
+		}
+
+		if err := runDefaults(ctx, defaultsWriteArgs(s)...); err != nil {
+			return nil, fmt.Errorf("%w: failed to write %q in %q: %w", errDefaults, s.key, s.domain, err)
+		}
+
+		slog.InfoContext(ctx, "preference written", "domain", s.domain, "key", s.key, "value", s.value)
+	}
+
+	if len(changes) == 0 {
+		return plugin.RunTaskResult{Changes: nil}, nil
+	}
+
+	for _, app := range restart {
+		if dryRun {
+			terminal.Printf("Would restart %s\n", app)
+
+			continue
+		}
+
+		// The application is not necessarily running, so the failures are
+		// not errors.
+		if out, err := exec.CommandContext(ctx, "killall", app).CombinedOutput(); err != nil {
+			slog.DebugContext(ctx, "failed to restart application", "app", app, "err", err, "output", string(out))
+		} else {
+			slog.InfoContext(ctx, "application restarted", "app", app)
+		}
+	}
+
+	return plugin.RunTaskResult{Changes: changes}, nil
+}
+
+// readDefaults returns the current preferences in the given domain. It returns
+// an empty map if the domain does not exist yet.
+func readDefaults(ctx context.Context, domain string) (map[string]any, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, defaultsProgram, "export", domain, "-")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: failed to read %q: %w: %s", errDefaults, domain, err, msg)
+		}
+
+		return nil, fmt.Errorf("%w: failed to read %q: %w", errDefaults, domain, err)
+	}
+
+	return parseExport(domain, out)
+}
+
+// parseExport parses the preferences in the given domain from the output of
+// "defaults export". It returns an empty map if the output is empty.
+func parseExport(domain string, out []byte) (map[string]any, error) {
+	v, err := decodePlist(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %q: %w", errDefaults, domain, err)
+	}
+
+	if v == nil {
+		return map[string]any{}, nil
+	}
+
+	values, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: preferences of %q are not a dictionary", errDefaults, domain)
+	}
+
+	return values, nil
+}
+
+// runDefaults runs defaults(1) with the given arguments.
+func runDefaults(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, defaultsProgram, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// defaultsWriteArgs returns the arguments for defaults(1) that write
+// the preference s. The scalar values are written with the type flags, and
+// the arrays and the dictionaries as property lists.
+func defaultsWriteArgs(s defaultsSpec) []string {
+	args := []string{"write", s.domain, s.key}
+
+	switch v := s.value.(type) {
+	case bool:
+		return append(args, "-bool", strconv.FormatBool(v))
+	case int64:
+		return append(args, "-int", strconv.FormatInt(v, 10))
+	case float64:
+		return append(args, "-float", strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		return append(args, "-string", v)
+	default:
+		var b strings.Builder
+
+		plistXML(&b, v)
+
+		return append(args, b.String())
+	}
+}
+
+// parseDefaults parses the preferences to write and the applications to
+// restart from the config of the task. The preferences are sorted by
+// the domain and the key.
+func parseDefaults(cfg api.KeyValues) ([]defaultsSpec, []string, error) {
+	var (
+		specs   []defaultsSpec
+		restart []string
+	)
+
+	if kv, ok := cfg.Get("restart"); ok {
+		var err error
+
+		if restart, err = kv.StringSlice(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read \"restart\": %w", err)
+		}
+	}
+
+	kv, ok := cfg.Get("domains")
+	if !ok {
+		return nil, restart, nil
+	}
+
+	domains, ok := kv.Val.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: \"domains\" has invalid type %T", plugin.ErrInvalidCast, kv.Val)
+	}
+
+	for _, domain := range slices.Sorted(maps.Keys(domains)) {
+		values, ok := domains[domain].(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: domain %q is not a table", errDefaults, domain)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			v, err := plistValue(values[key])
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %q in %q: %w", errDefaults, key, domain, err)
+			}
+
+			specs = append(specs, defaultsSpec{value: v, domain: domain, key: key})
+		}
+	}
+
+	return specs, restart, nil
+}
+
+// plistValue converts a value from the config file to a property list value.
+// The integers are converted to int64 so that they can be compared to
+// the values that are read back.
+func plistValue(raw any) (any, error) {
+	switch v := raw.(type) {
+	case bool, string, int64, float64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case []any:
+		values := make([]any, len(v))
+
+		for i, x := range v {
+			var err error
+
+			if values[i], err = plistValue(x); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	case map[string]any:
+		values := make(map[string]any, len(v))
+
+		for k, x := range v {
+			var err error
+
+			if values[k], err = plistValue(x); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported value type %T", plugin.ErrInvalidCast, raw)
+	}
+}
+
+// plistEqual reports whether the property list values a and b are equal. Both
+// the types and the values must match.
+func plistEqual(a, b any) bool {
+	switch x := a.(type) {
+	case []any:
+		y, ok := b.([]any)
+
+		return ok && slices.EqualFunc(x, y, plistEqual)
+	case map[string]any:
+		y, ok := b.(map[string]any)
+
+		return ok && maps.EqualFunc(x, y, plistEqual)
+	case []byte:
+		y, ok := b.([]byte)
+
+		return ok && bytes.Equal(x, y)
+	default:
+		return a == b
+	}
+}
+
+// plistString formats the property list value v for showing the changes to
+// the user.
+func plistString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		s := strconv.FormatFloat(x, 'g', -1, 64)
+
+		// The floats are shown with the decimal point so that they can be
+		// told apart from the integers.
+		if !math.IsInf(x, 0) && !math.IsNaN(x) && !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+
+		return s
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(x))
+	case plistDate:
+		return string(x)
+	case []any:
+		values := make([]string, len(x))
+
+		for i, y := range x {
+			values[i] = plistString(y)
+		}
+
+		return "[" + strings.Join(values, ", ") + "]"
+	case map[string]any:
+		values := make([]string, 0, len(x))
+
+		for _, k := range slices.Sorted(maps.Keys(x)) {
+			values = append(values, strconv.Quote(k)+" = "+plistString(x[k]))
+		}
+
+		return "{" + strings.Join(values, ", ") + "}"
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// plistXML writes the property list value v to b in the XML format.
+func plistXML(b *strings.Builder, v any) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			b.WriteString("<true/>")
+		} else {
+			b.WriteString("<false/>")
+		}
+	case int64:
+		fmt.Fprintf(b, "<integer>%d</integer>", x)
+	case float64:
+		fmt.Fprintf(b, "<real>%s</real>", strconv.FormatFloat(x, 'g', -1, 64))
+	case string:
+		b.WriteString("<string>")
+		_ = xml.EscapeText(b, []byte(x)) // writing to a strings.Builder does not fail
+		b.WriteString("</string>")
+	case []byte:
+		fmt.Fprintf(b, "<data>%s</data>", base64.StdEncoding.EncodeToString(x))
+	case plistDate:
+		fmt.Fprintf(b, "<date>%s</date>", x)
+	case []any:
+		b.WriteString("<array>")
+
+		for _, y := range x {
+			plistXML(b, y)
+		}
+
+		b.WriteString("</array>")
+	case map[string]any:
+		b.WriteString("<dict>")
+
+		for _, k := range slices.Sorted(maps.Keys(x)) {
+			b.WriteString("<key>")
+			_ = xml.EscapeText(b, []byte(k)) // writing to a strings.Builder does not fail
+			b.WriteString("</key>")
+			plistXML(b, x[k])
+		}
+
+		b.WriteString("</dict>")
+	default:
+		panic(fmt.Sprintf("invalid property list value: %[1]v (%[1]T)", v))
+	}
+}
+
+// decodePlist decodes the first value of the XML property list read from r.
+// It returns nil if the property list is empty.
+func decodePlist(r io.Reader) (any, error) {
+	d := xml.NewDecoder(r)
+
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return decodePlistValue(d, start)
+		}
+	}
+}
+
+// decodePlistValue decodes the property list value that starts with
+// the element start.
+func decodePlistValue(d *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+
+		return start.Name.Local == "true", nil
+	case "array":
+		values := []any{}
+
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, fmt.Errorf("invalid property list: %w", err)
+			}
+
+			switch t := tok.(type) {
+			case xml.StartElement:
+				v, err := decodePlistValue(d, t)
+				if err != nil {
+					return nil, err
+				}
+
+				values = append(values, v)
+			case xml.EndElement:
+				return values, nil
+			}
+		}
+	case "dict":
+		return decodePlistDict(d)
+	}
+
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return nil, fmt.Errorf("invalid property list: %w", err)
+	}
+
+	switch start.Name.Local {
+	case "integer":
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer in property list: %w", err)
+		}
+
+		return n, nil
+	case "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid real in property list: %w", err)
+		}
+
+		return f, nil
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid data in property list: %w", err)
+		}
+
+		return data, nil
+	case "string":
+		return s, nil
+	case "date":
+		return plistDate(strings.TrimSpace(s)), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown property list element %q", errDefaults, start.Name.Local)
+	}
+}
+
+// decodePlistDict decodes the entries of a property list dictionary after its
+// start element.
+func decodePlistDict(d *xml.Decoder) (map[string]any, error) {
+	values := make(map[string]any)
+	key := ""
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "key" {
+				if err = d.DecodeElement(&key, &t); err != nil {
+					return nil, fmt.Errorf("invalid property list: %w", err)
+				}
+
+				continue
+			}
+
+			v, err := decodePlistValue(d, t)
+			if err != nil {
+				return nil, err
+			}
+
+			values[key] = v
+		case xml.EndElement:
+			return values, nil
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+func TestPlistXML_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"True", true},
+		{"False", false},
+		{"Integer", int64(-42)},
+		{"Real", 0.5},
+		{"WholeReal", float64(3)},
+		{"String", `<a & "b">`},
+		{"EmptyString", ""},
+		{"Data", []byte{0, 1, 2, 0xff}},
+		{"Date", plistDate("2025-01-02T03:04:05Z")},
+		{"EmptyArray", []any{}},
+		{"EmptyDict", map[string]any{}},
+		{"Array", []any{int64(1), "two", 3.5, false}},
+		{
+			"Nested",
+			map[string]any{
+				"list":  []any{map[string]any{"a": int64(1)}, []any{"b"}},
+				"dict":  map[string]any{"<key>": true, "data": []byte("x")},
+				"float": 1.25,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+
+			b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+			b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" `)
+			b.WriteString(`"http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+			b.WriteString(`<plist version="1.0">`)
+			plistXML(&b, tt.value)
+			b.WriteString("</plist>\n")
+
+			got, err := decodePlist(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("decodePlist(%q) error = %v", b.String(), err)
+			}
+
+			if !plistEqual(got, tt.value) {
+				t.Errorf("decodePlist(%q) = %#v, want %#v", b.String(), got, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecodePlist(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name    string
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{"Empty", "", nil, false},
+		{"EmptyPlist", `<plist version="1.0"></plist>`, nil, false},
+		{"Whitespace", "<plist><integer> 7\n</integer></plist>", int64(7), false},
+		{"WrappedData", "<plist><data>AAEC\n/w==</data></plist>", []byte{0, 1, 2, 0xff}, false},
+		{
+			"Dict",
+			"<plist><dict>\n\t<key>a</key>\n\t<real>1</real>\n\t<key>b</key>\n\t<array/>\n</dict></plist>",
+			map[string]any{"a": float64(1), "b": []any{}},
+			false,
+		},
+		{"InvalidInteger", "<plist><integer>1.5</integer></plist>", nil, true},
+		{"InvalidData", "<plist><data>!</data></plist>", nil, true},
+		{"UnknownElement", "<plist><uid>1</uid></plist>", nil, true},
+		{"Unterminated", "<plist><dict><key>a</key>", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decodePlist(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodePlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !plistEqual(got, tt.want) {
+				t.Errorf("decodePlist() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name    string
+		out     string
+		want    map[string]any
+		wantErr bool
+	}{
+		{"Empty", "", map[string]any{}, false},
+		{"EmptyDict", `<?xml version="1.0"?><plist version="1.0"><dict/></plist>`, map[string]any{}, false},
+		{
+			"Values",
+			`<plist version="1.0"><dict><key>autohide</key><true/><key>tilesize</key><integer>36</integer></dict></plist>`,
+			map[string]any{"autohide": true, "tilesize": int64(36)},
+			false,
+		},
+		{"NotDict", `<plist version="1.0"><array/></plist>`, nil, true},
+		{"Invalid", `<plist version="1.0"><dict>`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseExport("com.apple.dock", []byte(tt.out))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, errDefaults) {
+					t.Errorf("parseExport() error = %v, want %v", err, errDefaults)
+				}
+
+				return
+			}
+
+			// The empty preferences must be an empty map and not nil so that
+			// the new keys can be compared against them.
+			if got == nil || !plistEqual(got, tt.want) {
+				t.Errorf("parseExport() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlistEqual(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name string
+		a    any
+		b    any
+		want bool
+	}{
+		{"SameInteger", int64(1), int64(1), true},
+		{"IntegerAndReal", int64(1), float64(1), false},
+		{"RealAndInteger", float64(1), int64(1), false},
+		{"DifferentStrings", "a", "b", false},
+		{"DateAndString", plistDate("2025-01-01T00:00:00Z"), "2025-01-01T00:00:00Z", false},
+		{"SameData", []byte("a"), []byte("a"), true},
+		{"DataAndString", []byte("a"), "a", false},
+		{"NilAndEmptyDict", nil, map[string]any{}, false},
+		{"SameArray", []any{int64(1), "a"}, []any{int64(1), "a"}, true},
+		{"ArrayOrder", []any{int64(1), "a"}, []any{"a", int64(1)}, false},
+		{"ArrayLength", []any{int64(1)}, []any{int64(1), int64(1)}, false},
+		{"ArrayAndDict", []any{}, map[string]any{}, false},
+		{
+			"NestedDict",
+			map[string]any{"a": map[string]any{"b": []any{int64(1), map[string]any{"c": true}}}},
+			map[string]any{"a": map[string]any{"b": []any{int64(1), map[string]any{"c": true}}}},
+			true,
+		},
+		{
+			"NestedDictValue",
+			map[string]any{"a": map[string]any{"b": []any{int64(1), map[string]any{"c": true}}}},
+			map[string]any{"a": map[string]any{"b": []any{int64(1), map[string]any{"c": false}}}},
+			false,
+		},
+		{
+			"NestedIntegerAndReal",
+			map[string]any{"a": []any{int64(2)}},
+			map[string]any{"a": []any{float64(2)}},
+			false,
+		},
+		{"ExtraKey", map[string]any{"a": true}, map[string]any{"a": true, "b": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := plistEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("plistEqual(%#v, %#v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlistValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name    string
+		raw     any
+		want    any
+		wantErr bool
+	}{
+		{"Int", 5, int64(5), false},
+		{"Int64", int64(5), int64(5), false},
+		{"Float", 2.5, 2.5, false},
+		{"String", "a", "a", false},
+		{"Bool", true, true, false},
+		{
+			"Nested",
+			map[string]any{"a": []any{1, map[string]any{"b": 2}}},
+			map[string]any{"a": []any{int64(1), map[string]any{"b": int64(2)}}},
+			false,
+		},
+		{"Unsupported", uint8(1), nil, true},
+		{"NestedUnsupported", []any{"a", struct{}{}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := plistValue(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("plistValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, plugin.ErrInvalidCast) {
+					t.Errorf("plistValue() error = %v, want %v", err, plugin.ErrInvalidCast)
+				}
+
+				return
+			}
+
+			if !plistEqual(got, tt.want) {
+				t.Errorf("plistValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultsWriteArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+		want  []string
+	}{
+		{"Bool", true, []string{"-bool", "true"}},
+		{"Integer", int64(36), []string{"-int", "36"}},
+		{"Real", 0.25, []string{"-float", "0.25"}},
+		{"WholeReal", float64(2), []string{"-float", "2"}},
+		{"String", "-dash", []string{"-string", "-dash"}},
+		{"Array", []any{"a", int64(1)}, []string{"<array><string>a</string><integer>1</integer></array>"}},
+		{
+			"Dict",
+			map[string]any{"b": false, "a": "<x>"},
+			[]string{"<dict><key>a</key><string>&lt;x&gt;</string><key>b</key><false/></dict>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := defaultsWriteArgs(defaultsSpec{value: tt.value, domain: "com.apple.dock", key: "key"})
+			want := append([]string{"write", "com.apple.dock", "key"}, tt.want...)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("defaultsWriteArgs() = %q, want %q", got, want)
+			}
+		})
+	}
+}
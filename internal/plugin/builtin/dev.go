@@ -0,0 +1,268 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/plugin/runtimes"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// devPollInterval is the interval for checking the plugin files for changes in
+// the "dev" command.
+const devPollInterval = 500 * time.Millisecond
+
+// errDev is returned when the "dev" command fails.
+var errDev = errors.New("dev failed")
+
+// A devPlugin is a plugin loaded from a development directory by the "dev"
+// command.
+type devPlugin struct {
+	store *plugin.Store // store with the built-in plugins and the plugin
+	name  string        // name of the plugin
+	files []fspath.Path // files that are watched for changes
+}
+
+// devCommand returns the manifest entry for the "dev" command.
+func devCommand() *api.Command {
+	return &api.Command{
+		Name:        "dev",
+		Usage:       "dev <plugin-dir> [<command> [<arg>...]]",
+		Description: "Run a plugin from a development directory.",
+		//nolint:lll
+		Help:     "Loads the plugin from the given directory instead of the plugin search paths, starts it, and performs the handshake with it. If a command of the plugin is given, it is run after the handshake with the given arguments and without config values. The manifest and the executable of the plugin are watched for changes, and the plugin is restarted when they change. The command runs until it is interrupted.",
+		Manual:   "",
+		Aliases:  nil,
+		Config:   nil,
+		Commands: nil,
+		Args: &api.Arguments{
+			Spec: []api.ArgSpec{
+				{
+					Name:        "plugin-dir",
+					Description: "Directory of the plugin.",
+				},
+				{
+					Name:        "command",
+					Description: "Command of the plugin to run after each start.",
+				},
+			},
+			Min: 1,
+			Max: -1,
+		},
+	}
+}
+
+// runDev runs the "dev" command that loads a plugin from a development
+// directory and restarts it whenever its files change.
+func runDev(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(p.Args) == 0 {
+		return fmt.Errorf("%w: no plugin directory given", errDev)
+	}
+
+	dir := fspath.Path(p.Args[0])
+	if !dir.IsAbs() {
+		dir = cfg.Directory.Join(p.Args[0])
+	}
+
+	dir, err := dir.Abs()
+	if err != nil {
+		return fmt.Errorf("failed to resolve plugin directory %q: %w", p.Args[0], err)
+	}
+
+	// The manifest is watched even if it cannot be loaded so that the plugin
+	// is loaded once it is fixed.
+	files := []fspath.Path{dir.Join("manifest.json")}
+
+	for {
+		dev, err := loadDev(ctx, cfg, dir, p.Args[1:])
+		if err != nil {
+			slog.WarnContext(ctx, "failed to load plugin in dev mode", "dir", dir, "err", err)
+			terminal.Errorf("Error: %v\n", err)
+		}
+
+		if dev != nil {
+			files = dev.files
+		}
+
+		terminal.Progressf("Watching %s for changes\n", dir)
+
+		changed := waitForChange(ctx, files)
+
+		if dev != nil {
+			if err := dev.store.ShutdownAll(ctx); err != nil {
+				slog.WarnContext(ctx, "failed to shut down plugin in dev mode", "plugin", dev.name, "err", err)
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+
+		terminal.Progressf("Change detected, restarting the plugin\n")
+	}
+}
+
+// loadDev loads the plugin from dir, starts it, and runs the given command of
+// the plugin if one is given. It returns the loaded plugin even if running
+// the command fails so that the plugin can be shut down.
+func loadDev(ctx context.Context, cfg *config.Config, dir fspath.Path, args []string) (*devPlugin, error) {
+	store, err := plugin.NewDevStore(ctx, Manifests(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin from %s: %w", dir, err)
+	}
+
+	if dataDir, err := config.PluginDataDir(); err == nil {
+		store.SetDataDir(dataDir)
+	} else {
+		slog.WarnContext(ctx, "failed to resolve the plugin data directory", "err", err)
+	}
+
+	// The plugin that is being developed is the only external plugin in
+	// the store.
+	var m *api.Manifest
+
+	for p := range store.VisitPlugins() {
+		if p.External() {
+			m = p.Manifest()
+		}
+	}
+
+	dev := &devPlugin{
+		store: store,
+		name:  m.Name,
+		files: []fspath.Path{dir.Join("manifest.json"), fspath.Path(m.Executable)},
+	}
+
+	if err = runtimes.Resolve(ctx, store, cfg); err != nil {
+		return dev, fmt.Errorf("failed to resolve the runtime for plugin %q: %w", m.Name, err)
+	}
+
+	if err = store.Init(ctx, Service(cfg), nil); err != nil {
+		return dev, fmt.Errorf("%w", err)
+	}
+
+	if err = store.Require(ctx, m.Name); err != nil {
+		return dev, fmt.Errorf("failed to start plugin %q: %w", m.Name, err)
+	}
+
+	terminal.Printf(
+		"Started plugin %s %s with %d commands and %d tasks\n",
+		m.Name,
+		m.Version,
+		len(m.Commands),
+		len(m.Tasks),
+	)
+
+	if len(args) == 0 {
+		return dev, nil
+	}
+
+	cmd := store.Command(nil, m.Domain)
+	if cmd == nil {
+		return dev, fmt.Errorf("%w: plugin %q has no commands", errDev, m.Name)
+	}
+
+	for len(args) > 0 {
+		next := store.Command(cmd, args[0])
+		if next == nil {
+			break
+		}
+
+		cmd = next
+		args = args[1:]
+	}
+
+	if cmd.Parent == nil {
+		return dev, fmt.Errorf("%w: plugin %q has no command %q", errDev, m.Name, args[0])
+	}
+
+	result, err := cmd.Run(ctx, store, args, api.KeyValues{}, api.KeyValues{}, cfg.CommandContext())
+	if err != nil {
+		return dev, fmt.Errorf("running command %q failed: %w", cmd.Name, err)
+	}
+
+	if err = result.Err(); err != nil {
+		return dev, fmt.Errorf("running command %q failed: %w", cmd.Name, err)
+	}
+
+	return dev, nil
+}
+
+// waitForChange polls the given files until one of them changes. It reports
+// whether a change was detected; it returns false if ctx is canceled first.
+func waitForChange(ctx context.Context, files []fspath.Path) bool {
+	initial := fingerprintFiles(files)
+	ticker := time.NewTicker(devPollInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		if fingerprintFiles(files) == initial {
+			continue
+		}
+
+		// Wait for the writes to settle so that the plugin is not restarted
+		// while it is still being built.
+		for {
+			current := fingerprintFiles(files)
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-ticker.C:
+			}
+
+			if fingerprintFiles(files) == current {
+				return true
+			}
+		}
+	}
+}
+
+// fingerprintFiles returns a string that changes when the modification time or
+// the size of one of the given files changes. The missing files are included
+// so that removing and recreating a file is detected.
+func fingerprintFiles(files []fspath.Path) string {
+	var s string
+
+	for _, f := range files {
+		info, err := os.Stat(string(f))
+		if err != nil {
+			s += f.String() + ":missing;"
+
+			continue
+		}
+
+		s += fmt.Sprintf("%s:%d:%d;", f, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return s
+}
@@ -0,0 +1,380 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// formatShell is the export format for POSIX shell scripts.
+const formatShell = "shell"
+
+// exportLinkFunc is the shell function that the exported scripts use for
+// creating the links. It works like the link task: an existing link to
+// the same file is left as it is, and other existing files are only replaced if
+// the third argument is 1.
+const exportLinkFunc = `reginald_link() {
+	if [ "$(readlink "$2" 2>/dev/null)" = "$1" ]; then
+		return 0
+	fi
+
+	if [ ! -e "$1" ] && [ ! -L "$1" ]; then
+		echo "link source $1 does not exist" >&2
+		return 1
+	fi
+
+	if [ -e "$2" ] || [ -L "$2" ]; then
+		if [ "$3" != 1 ]; then
+			echo "file $2 already exists" >&2
+			return 1
+		fi
+
+		rm -rf "$2"
+	fi
+
+	mkdir -p "$(dirname "$2")"
+	ln -s "$1" "$2"
+	echo "Linked $2 to $1"
+}
+`
+
+// errExport is returned when the "export" command fails.
+var errExport = errors.New("export failed")
+
+// A shellExporter writes the shell commands that do the same as the given task
+// to b. The exporters are defined only for the built-in task types as
+// the tasks of the external plugins are opaque to Reginald.
+type shellExporter func(b *strings.Builder, cfg plugin.TaskConfig, dir fspath.Path) error
+
+// shellExporters returns the shell exporters by the task types.
+func shellExporters() map[string]shellExporter {
+	return map[string]shellExporter{
+		copyTaskType:     exportCopies,
+		defaultsTaskType: exportDefaults,
+		linkTaskType:     exportLinks,
+		profileTaskType:  exportProfile,
+	}
+}
+
+// exportCommand returns the manifest entry for the "export" command.
+func exportCommand() *api.Command {
+	return &api.Command{
+		Name:        "export",
+		Usage:       "export [options]",
+		Description: "Print the task plan as a script.",
+		//nolint:lll
+		Help:    "Renders the tasks defined in the Reginald config file in the order that `attend` runs them on this machine and prints the result to the standard output. With `--format shell`, the result is a standalone POSIX shell script that can be inspected or run on machines that do not have Reginald. The export is best-effort: only the built-in task types are translated, and the tasks that are run by the other plugins are listed as comments. The script does not back up the files that it replaces.",
+		Manual:  "",
+		Aliases: nil,
+		Config: []api.ConfigEntry{
+			{
+				ConfigValue: api.ConfigValue{
+					KeyVal: api.KeyVal{
+						Value: api.Value{Val: formatShell, Type: api.StringValue},
+						Key:   "format",
+					},
+					Description: "Format of the exported plan.",
+				},
+				Flag: &api.Flag{
+					Name:        "format",
+					Shorthand:   "",
+					Description: "export the plan in `<format>`; only \"" + formatShell + "\" is supported",
+					Manual:      "",
+				},
+				EnvOverride: "",
+				FlagOnly:    true,
+			},
+		},
+		Commands: nil,
+		Args:     nil,
+	}
+}
+
+// runExport runs the "export" command that prints the task plan in the given
+// format.
+func runExport(ctx context.Context, store *plugin.Store, cfg *config.Config, p plugin.RunCommandParams) error {
+	format, err := stringConfig(p.Config, "format")
+	if err != nil {
+		return err
+	}
+
+	if format != formatShell {
+		return fmt.Errorf("%w: unsupported format %q", errExport, format)
+	}
+
+	script, skipped, err := exportShell(store, cfg)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "task plan exported", "format", format, "skipped", skipped)
+	terminal.Print(script)
+
+	if len(skipped) > 0 {
+		diag.Report(ctx, diag.Untranslated, fmt.Sprintf(
+			"%d task(s) could not be exported and were left as comments: %s",
+			len(skipped),
+			strings.Join(skipped, ", "),
+		))
+	}
+
+	return nil
+}
+
+// exportShell renders the task plan of the store as a POSIX shell script. It
+// returns the script and the IDs of the tasks that could not be exported.
+func exportShell(store *plugin.Store, cfg *config.Config) (string, []string, error) {
+	var (
+		b       strings.Builder
+		skipped []string
+	)
+
+	b.WriteString("#!/bin/sh\n#\n")
+
+	if cfg.HasFile() {
+		fmt.Fprintf(&b, "# Generated by `reginald export --format shell` from %s.\n", cfg.File())
+	} else {
+		b.WriteString("# Generated by `reginald export --format shell`.\n")
+	}
+
+	b.WriteString("#\n")
+	b.WriteString("# The script runs the tasks in the same order as `reginald attend` on\n")
+	b.WriteString("# the machine that it was generated on, and it stops at the first failure.\n")
+	b.WriteString("# The export is best-effort: the tasks of the plugins are not translated, and\n")
+	b.WriteString("# the replaced files are not backed up.\n\n")
+	b.WriteString("set -eu\n\n")
+	b.WriteString(exportLinkFunc)
+
+	exporters := shellExporters()
+
+	for _, stage := range store.Plan() {
+		for _, t := range stage {
+			fmt.Fprintf(&b, "\n# Task %q (%s)\n", t.ID, t.TaskType)
+
+			export, ok := exporters[t.TaskType]
+			if !ok {
+				name := t.TaskType
+
+				if task := store.Task(t.TaskType); task != nil && task.Plugin != nil {
+					name = task.Plugin.Manifest().Name
+				}
+
+				fmt.Fprintf(&b, "# The task is run by the plugin %q and cannot be exported.\n", name)
+
+				skipped = append(skipped, t.ID)
+
+				continue
+			}
+
+			if err := export(&b, t, cfg.Directory); err != nil {
+				return "", nil, fmt.Errorf("%w: task %q: %w", errExport, t.ID, err)
+			}
+		}
+	}
+
+	return b.String(), skipped, nil
+}
+
+// exportLinks writes the commands for creating the links of a link task to b.
+func exportLinks(b *strings.Builder, cfg plugin.TaskConfig, dir fspath.Path) error {
+	links, err := parseLinks(cfg.Config, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range links {
+		force := "0"
+		if l.force {
+			force = "1"
+		}
+
+		fmt.Fprintf(b, "reginald_link %s %s %s\n", shellPath(l.src), shellPath(l.path), force)
+	}
+
+	return nil
+}
+
+// exportCopies writes the commands for copying the files of a copy task to b.
+// Unlike the task, the script copies the files even if they are up to date.
+func exportCopies(b *strings.Builder, cfg plugin.TaskConfig, dir fspath.Path) error {
+	specs, err := parseCopies(cfg.Config, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range specs {
+		src, dst := shellPath(s.src), shellPath(s.path)
+
+		info, err := os.Stat(string(s.src))
+		if err != nil {
+			return fmt.Errorf("%w: failed to get info for %q: %w", errExport, s.src, err)
+		}
+
+		if info.IsDir() {
+			fmt.Fprintf(b, "mkdir -p %s\ncp -R %s/. %s\n", dst, src, dst)
+		} else {
+			fmt.Fprintf(b, "mkdir -p \"$(dirname %s)\"\ncp %s %s\n", dst, src, dst)
+		}
+
+		if s.setMode {
+			if info.IsDir() {
+				fmt.Fprintf(b, "find %s -type f -exec chmod %04o {} +\n", dst, s.mode)
+			} else {
+				fmt.Fprintf(b, "chmod %04o %s\n", s.mode, dst)
+			}
+		}
+
+		if s.uid != -1 || s.gid != -1 {
+			owner := ""
+			if s.uid != -1 {
+				owner = strconv.Itoa(s.uid)
+			}
+
+			if s.gid != -1 {
+				owner += ":" + strconv.Itoa(s.gid)
+			}
+
+			fmt.Fprintf(b, "chown -R %s %s\n", owner, dst)
+		}
+	}
+
+	return nil
+}
+
+// exportDefaults writes the commands for writing the preferences of a defaults
+// task to b. Unlike the task, the script writes all of the preferences without
+// comparing them to the current values first.
+func exportDefaults(b *strings.Builder, cfg plugin.TaskConfig, _ fspath.Path) error {
+	specs, restart, err := parseDefaults(cfg.Config)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range specs {
+		fmt.Fprintf(b, "%s %s\n", defaultsProgram, shellJoin(defaultsWriteArgs(s)))
+	}
+
+	if len(specs) == 0 {
+		return nil
+	}
+
+	for _, app := range restart {
+		fmt.Fprintf(b, "killall %s 2>/dev/null || true\n", shellQuote(app))
+	}
+
+	return nil
+}
+
+// exportProfile writes the commands for writing the managed section of a shell
+// profile task to b. Unlike the task, the script only appends the section to
+// the files that do not have it and does not update an existing section.
+func exportProfile(b *strings.Builder, cfg plugin.TaskConfig, _ fspath.Path) error {
+	s, err := parseProfile(cfg.Config)
+	if err != nil {
+		return err
+	}
+
+	begin, end := sectionMarkers(s.name)
+
+	for _, file := range s.files {
+		path := shellPath(file)
+		block := ""
+
+		if !s.remove {
+			block = profileBlock(s, strings.HasSuffix(string(file), ".fish"))
+		}
+
+		// The file is rewritten with cat instead of "sed -i" so that
+		// the profiles that are links to the dotfiles stay links.
+		if block == "" {
+			fmt.Fprintf(b, "if [ -f %s ]; then\n", path)
+			b.WriteString("\ttmp=\"$(mktemp)\"\n")
+			script := "/^" + sedPattern(begin) + "$/,/^" + sedPattern(end) + "$/d"
+			fmt.Fprintf(b, "\tsed %s %s >\"$tmp\"\n", shellQuote(script), path)
+			fmt.Fprintf(b, "\tcat \"$tmp\" >%s\n\trm -f \"$tmp\"\nfi\n", path)
+
+			continue
+		}
+
+		fmt.Fprintf(b, "if ! grep -qxF %s %s 2>/dev/null; then\n", shellQuote(begin), path)
+		fmt.Fprintf(b, "\tmkdir -p \"$(dirname %s)\"\n", path)
+		delim := heredocDelimiter(block)
+		fmt.Fprintf(b, "\tcat >>%s <<'%s'\n\n%s%s\nfi\n", path, delim, block, delim)
+	}
+
+	return nil
+}
+
+// shellPath quotes the path p for a POSIX shell. The paths within the user's
+// home directory are given relative to "$HOME" so that the script works for
+// other users too.
+func shellPath(p fspath.Path) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return shellQuote(string(p))
+	}
+
+	rel, err := filepath.Rel(home, string(p))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return shellQuote(string(p))
+	}
+
+	return `"$HOME"/` + shellQuote(filepath.ToSlash(rel))
+}
+
+// sedPattern escapes s so that it matches itself literally in a basic regular
+// expression in a sed address delimited by slashes.
+func sedPattern(s string) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(`\/.*[^$`, r) {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// heredocDelimiter returns the delimiter for a here-document with the given
+// content. The delimiter is chosen so that no line of the content is equal to
+// it, as such a line would end the here-document early.
+func heredocDelimiter(content string) string {
+	lines := strings.Split(content, "\n")
+	delim := "REGINALD"
+
+	for i := 1; slices.Contains(lines, delim); i++ {
+		delim = "REGINALD_" + strconv.Itoa(i)
+	}
+
+	return delim
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"Plain", "plain", `'plain'`},
+		{"Empty", "", `''`},
+		{"SingleQuote", "it's", `'it'\''s'`},
+		{"Dollar", "$HOME and ${PATH}", `'$HOME and ${PATH}'`},
+		{"Backtick", "`id`", "'`id`'"},
+		{"Newline", "a\nb", "'a\nb'"},
+		{"Mixed", "'$(id)'\n`x`", `''\''$(id)'\''` + "\n`x`'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := shellQuote(tt.s)
+			if got != tt.want {
+				t.Errorf("shellQuote(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+
+			if out := runShell(t, "printf %s "+got); out != tt.s {
+				t.Errorf("shell printed %q for %s, want %q", out, got, tt.s)
+			}
+		})
+	}
+}
+
+func TestShellPath(t *testing.T) {
+	t.Parallel()
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+
+	rel := "it's `a` $b\nc"
+	path := fspath.Path(filepath.Join(home, rel))
+
+	got := shellPath(path)
+	if want := `"$HOME"/` + shellQuote(rel); got != want {
+		t.Errorf("shellPath(%q) = %q, want %q", path, got, want)
+	}
+
+	if out := runShell(t, "printf %s "+got); out != string(path) {
+		t.Errorf("shell printed %q for %s, want %q", out, got, path)
+	}
+}
+
+func TestExportLinks(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the exported scripts use POSIX paths")
+	}
+
+	path := "/srv/it's `a` $b\nc"
+	cfg := plugin.TaskConfig{ //nolint:exhaustruct // only the config is needed
+		TaskType: linkTaskType,
+		ID:       "link",
+		Config: api.KeyValues{
+			{
+				Value: api.Value{Val: []fspath.Path{fspath.Path(path)}, Type: api.PathListValue},
+				Key:   "links",
+			},
+		},
+	}
+
+	var b strings.Builder
+	if err := exportLinks(&b, cfg, "/dots"); err != nil {
+		t.Fatalf("exportLinks() error = %v", err)
+	}
+
+	want := "reginald_link '/dots/it'\\''s `a` $b\nc' '/srv/it'\\''s `a` $b\nc' 0\n"
+	if b.String() != want {
+		t.Errorf("exportLinks() =\n%q\nwant\n%q", b.String(), want)
+	}
+
+	// The arguments must reach the function unchanged.
+	out := runShell(t, "reginald_link() { printf '%s|' \"$@\"; }\n"+b.String())
+	if want := "/dots/it's `a` $b\nc|" + path + "|0|"; out != want {
+		t.Errorf("reginald_link got %q, want %q", out, want)
+	}
+}
+
+func TestExportProfile(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the exported scripts use POSIX paths")
+	}
+
+	file := filepath.Join(t.TempDir(), "profile")
+	name := `a/b.*[c]^$\d`
+	other := "# BEGIN reginald axb..c\nother\n# END reginald axb..c\n"
+
+	if err := os.WriteFile(file, []byte(other), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newConfig := func(remove bool) plugin.TaskConfig {
+		return plugin.TaskConfig{ //nolint:exhaustruct // only the config is needed
+			TaskType: profileTaskType,
+			ID:       "profile",
+			Config: api.KeyValues{
+				{Value: api.Value{Val: []fspath.Path{fspath.Path(file)}, Type: api.PathListValue}, Key: "files"},
+				{Value: api.Value{Val: []string{"REGINALD", "echo done"}, Type: api.StringListValue}, Key: "lines"},
+				{Value: api.Value{Val: name, Type: api.StringValue}, Key: "name"},
+				{Value: api.Value{Val: remove, Type: api.BoolValue}, Key: "remove"},
+			},
+		}
+	}
+
+	var b strings.Builder
+	if err := exportProfile(&b, newConfig(false), ""); err != nil {
+		t.Fatalf("exportProfile() error = %v", err)
+	}
+
+	runShell(t, b.String())
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The line equal to the default delimiter must not end the section early.
+	if want := "\nREGINALD\necho done\n# END reginald " + name + "\n"; !strings.HasSuffix(string(data), want) {
+		t.Errorf("profile after adding =\n%s\nwant suffix\n%s", data, want)
+	}
+
+	b.Reset()
+
+	if err = exportProfile(&b, newConfig(true), ""); err != nil {
+		t.Fatalf("exportProfile() error = %v", err)
+	}
+
+	runShell(t, b.String())
+
+	if data, err = os.ReadFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name must match literally so that the similar section is kept.
+	// Only the empty line before the section is left behind.
+	if want := other + "\n"; string(data) != want {
+		t.Errorf("profile after removing =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestHeredocDelimiter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"", "REGINALD"},
+		{"echo REGINALD\n", "REGINALD"},
+		{"REGINALD\n", "REGINALD_1"},
+		{"a\nREGINALD\nREGINALD_1\n", "REGINALD_2"},
+	}
+
+	for _, tt := range tests {
+		if got := heredocDelimiter(tt.content); got != tt.want {
+			t.Errorf("heredocDelimiter(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+// runShell runs script with the POSIX shell and returns its output. The test
+// is skipped if there is no shell.
+func runShell(t *testing.T, script string) string {
+	t.Helper()
+
+	sh, err := exec.LookPath("sh")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("no POSIX shell")
+	}
+
+	out, err := exec.CommandContext(t.Context(), sh, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("failed to run %q: %v", script, err)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,835 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"gopkg.in/yaml.v3"
+)
+
+// stowLocalIgnore is the name of the file in a Stow package that replaces
+// the default ignore list of Stow for the package.
+const stowLocalIgnore = ".stow-local-ignore"
+
+// errImport is returned when the "import" commands fail.
+var errImport = errors.New("import failed")
+
+// stowDefaultIgnore contains the patterns that Stow ignores by default. As in
+// Stow, the patterns that contain a slash are matched against the path within
+// the package and the others against the names of the files.
+//
+//nolint:gochecknoglobals // used like a constant
+var stowDefaultIgnore = []string{
+	`RCS`,
+	`.+,v`,
+	`CVS`,
+	`\.\#.+`,
+	`\.cvsignore`,
+	`\.svn`,
+	`_darcs`,
+	`\.hg`,
+	`\.git`,
+	`\.gitignore`,
+	`\.gitmodules`,
+	`.+~`,
+	`\#.*\#`,
+	`/README.*`,
+	`/LICENSE.*`,
+	`/COPYING`,
+}
+
+// An importedConfig is the config translated from the config of another
+// dotfiles tool.
+type importedConfig struct {
+	// tool is the name of the import command of the tool.
+	tool string
+
+	// from describes the config that was imported.
+	from string
+
+	// dir is the dotfiles directory that the sources of the links are
+	// relative to.
+	dir fspath.Path
+
+	// links are the links to create.
+	links []importedLink
+
+	// commands are the shell commands that could not be translated to tasks.
+	commands []importedCommand
+
+	// warnings are the parts of the config that could not be translated.
+	// They are reported when the imported config is written.
+	warnings []string
+}
+
+// An importedLink is a link translated from the config of another tool.
+type importedLink struct {
+	path  string // path of the link, using "~" for the home directory
+	src   string // source of the link relative to the dotfiles directory
+	force bool   // whether to replace an existing file
+}
+
+// An importedCommand is a shell command from the config of another tool.
+type importedCommand struct {
+	command     string
+	description string
+}
+
+// linkTable is the table of a link in the "links" config of the link task.
+type linkTable struct {
+	Src   string `toml:"src,omitempty"`
+	Force bool   `toml:"force,omitempty"`
+}
+
+// importCommand returns the manifest entry for the "import" command.
+func importCommand() *api.Command {
+	output := api.ConfigEntry{
+		ConfigValue: api.ConfigValue{
+			KeyVal: api.KeyVal{
+				Value: api.Value{Val: "", Type: api.StringValue},
+				Key:   "output",
+			},
+			Description: "File to write the config to.",
+		},
+		Flag: &api.Flag{
+			Name:        "output",
+			Shorthand:   "o",
+			Description: "write the config to `<path>` instead of the standard output",
+			Manual:      "",
+		},
+		EnvOverride: "",
+		FlagOnly:    true,
+	}
+
+	return &api.Command{
+		Name:        "import",
+		Usage:       "import <command>",
+		Description: "Translate the config of another dotfiles tool.",
+		Help:        "Contains the commands for translating the configs of other dotfiles tools to Reginald config files.",
+		Manual:      "",
+		Aliases:     nil,
+		Config:      nil,
+		Commands: []*api.Command{
+			{
+				Name:        "dotbot",
+				Usage:       "import dotbot [options] <config-file>",
+				Description: "Translate a dotbot config file.",
+				//nolint:lll
+				Help:     "Translates the `link` directives of the given dotbot config file to a link task of a Reginald config file. The dotfiles directory of the config is the directory of the dotbot config file. Reginald has no task type for running shell commands, so the commands of the `shell` directives are only written to the config as comments and reported. The other directives and the link options that Reginald does not support are reported and skipped. The config is printed to the standard output unless `--output` is given.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   []api.ConfigEntry{output},
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "config-file",
+							Description: "Path to the dotbot config file.",
+						},
+					},
+					Min: 1,
+					Max: 1,
+				},
+			},
+			{
+				Name:        "stow",
+				Usage:       "import stow [options] <dir> [<package>...]",
+				Description: "Translate GNU Stow packages.",
+				//nolint:lll
+				Help:    "Translates the GNU Stow packages in the given Stow directory to a link task of a Reginald config file that creates the same links as `stow` would create. If no packages are given, all of the packages in the directory are translated. As in Stow, the links are created to the parent directory of the Stow directory unless `--target` is given, the files in the ignore lists are skipped, and the directories are linked as a whole unless the target directory already exists or multiple packages have files in them. The config is printed to the standard output unless `--output` is given.",
+				Manual:  "",
+				Aliases: nil,
+				Config: []api.ConfigEntry{
+					output,
+					{
+						ConfigValue: api.ConfigValue{
+							KeyVal: api.KeyVal{
+								Value: api.Value{Val: "", Type: api.StringValue},
+								Key:   "target",
+							},
+							Description: "Target directory of the links.",
+						},
+						Flag: &api.Flag{
+							Name:        "target",
+							Shorthand:   "t",
+							Description: "create the links to `<dir>` instead of the parent of the Stow directory",
+							Manual:      "",
+						},
+						EnvOverride: "",
+						FlagOnly:    true,
+					},
+					{
+						ConfigValue: api.ConfigValue{
+							KeyVal: api.KeyVal{
+								Value: api.Value{Val: false, Type: api.BoolValue},
+								Key:   "dotfiles",
+							},
+							Description: "Whether the \"dot-\" prefix is replaced with a dot.",
+						},
+						Flag: &api.Flag{
+							Name:        "dotfiles",
+							Shorthand:   "",
+							Description: "replace the \"dot-\" prefix of the files with a dot as with \"stow --dotfiles\"",
+							Manual:      "",
+						},
+						EnvOverride: "",
+						FlagOnly:    true,
+					},
+				},
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "dir",
+							Description: "Path to the Stow directory.",
+						},
+						{
+							Name:        "package",
+							Description: "Name of the package to translate.",
+						},
+					},
+					Min: 1,
+					Max: -1,
+				},
+			},
+		},
+		Args: nil,
+	}
+}
+
+// runImportDotbot runs the "import dotbot" command that translates a dotbot
+// config file.
+func runImportDotbot(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(p.Args) != 1 {
+		return fmt.Errorf("%w: expected exactly one dotbot config file, got %d", errImport, len(p.Args))
+	}
+
+	file, err := fspath.NewAbs(p.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve the dotbot config file: %w", err)
+	}
+
+	imported, err := importDotbot(file)
+	if err != nil {
+		return err
+	}
+
+	if len(imported.commands) > 0 {
+		diag.Report(ctx, diag.Untranslated, fmt.Sprintf(
+			"The %d shell command(s) in the dotbot config were written as comments as they cannot be translated",
+			len(imported.commands),
+		))
+	}
+
+	return writeImported(ctx, cfg, p, imported)
+}
+
+// runImportStow runs the "import stow" command that translates Stow packages.
+func runImportStow(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(p.Args) == 0 {
+		return fmt.Errorf("%w: expected the Stow directory", errImport)
+	}
+
+	dir, err := fspath.NewAbs(p.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve the Stow directory: %w", err)
+	}
+
+	target := dir.Dir()
+
+	s, err := stringConfig(p.Config, "target")
+	if err != nil {
+		return err
+	}
+
+	if s != "" {
+		if target, err = fspath.NewAbs(s); err != nil {
+			return fmt.Errorf("failed to resolve the target directory: %w", err)
+		}
+	}
+
+	dotfiles := false
+
+	if kv, ok := p.Config.Get("dotfiles"); ok {
+		if dotfiles, err = kv.Bool(); err != nil {
+			return fmt.Errorf("failed to read \"dotfiles\": %w", err)
+		}
+	}
+
+	imported, err := importStow(dir, target, p.Args[1:], dotfiles)
+	if err != nil {
+		return err
+	}
+
+	return writeImported(ctx, cfg, p, imported)
+}
+
+// writeImported writes the imported config to the file given with "output" or,
+// if it is not given, to the standard output. An existing file is backed up
+// before it is replaced.
+func writeImported(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams, imported *importedConfig) error {
+	for _, msg := range imported.warnings {
+		diag.Report(ctx, diag.Untranslated, msg)
+	}
+
+	data, err := imported.marshal()
+	if err != nil {
+		return err
+	}
+
+	output, err := stringConfig(p.Config, "output")
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		terminal.Print(string(data))
+
+		return nil
+	}
+
+	file, err := fspath.NewAbs(output)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the output file: %w", err)
+	}
+
+	backup, err := fsutil.WriteFile(string(file), data, fsutil.DefaultFilePerm, string(cfg.BackupDir))
+	if err != nil {
+		return fmt.Errorf("failed to write config file at %q: %w", file, err)
+	}
+
+	slog.InfoContext(ctx, "imported config written", "file", file, "from", imported.from, "backup", backup)
+	terminal.Printf("Config file written to %s\n", file)
+
+	return nil
+}
+
+// importDotbot translates the dotbot config file at file.
+func importDotbot(file fspath.Path) (*importedConfig, error) {
+	data, err := os.ReadFile(string(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the dotbot config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: invalid dotbot config file %s: %w", errImport, file, err)
+	}
+
+	imported := &importedConfig{
+		tool:     "dotbot",
+		from:     "the dotbot config file " + string(file),
+		dir:      file.Dir(),
+		links:    nil,
+		commands: nil,
+		warnings: nil,
+	}
+
+	// An empty file has no content node.
+	if len(doc.Content) == 0 {
+		return imported, nil
+	}
+
+	directives := doc.Content[0]
+	if directives.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%w: dotbot config file %s must contain a list of directives", errImport, file)
+	}
+
+	var defaults dotbotLink
+
+	for _, d := range directives.Content {
+		if d.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%w: invalid directive on line %d of %s", errImport, d.Line, file)
+		}
+
+		for i := 0; i+1 < len(d.Content); i += 2 {
+			name, value := d.Content[i].Value, d.Content[i+1]
+
+			switch name {
+			case "defaults":
+				var opts struct {
+					Link dotbotLink `yaml:"link"`
+				}
+
+				if err = value.Decode(&opts); err != nil {
+					return nil, fmt.Errorf("%w: invalid defaults on line %d of %s: %w", errImport, value.Line, file, err)
+				}
+
+				defaults = opts.Link
+			case "link":
+				if err = imported.addDotbotLinks(value, defaults); err != nil {
+					return nil, fmt.Errorf("%w: invalid link directive on line %d of %s: %w", errImport, value.Line, file, err)
+				}
+			case "shell":
+				if err = imported.addDotbotCommands(value); err != nil {
+					return nil, fmt.Errorf("%w: invalid shell directive on line %d of %s: %w", errImport, value.Line, file, err)
+				}
+
+				imported.warnings = append(
+					imported.warnings,
+					fmt.Sprintf("The dotbot shell directive on line %d cannot be translated and was written as comments", value.Line),
+				)
+			default:
+				imported.warnings = append(
+					imported.warnings,
+					fmt.Sprintf("The dotbot directive %q on line %d is not supported and was skipped", name, value.Line),
+				)
+			}
+		}
+	}
+
+	return imported, nil
+}
+
+// dotbotLink contains the options of a dotbot link.
+type dotbotLink struct {
+	Path          string `yaml:"path"`
+	Force         bool   `yaml:"force"`
+	Relink        bool   `yaml:"relink"`
+	Create        bool   `yaml:"create"`
+	Glob          bool   `yaml:"glob"`
+	If            string `yaml:"if"`
+	IgnoreMissing bool   `yaml:"ignore-missing"`
+	Relative      bool   `yaml:"relative"`
+	Canonicalize  *bool  `yaml:"canonicalize"`
+	Exclude       []any  `yaml:"exclude"`
+	Prefix        string `yaml:"prefix"`
+	Backup        *bool  `yaml:"backup"`
+}
+
+// addDotbotLinks adds the links from the value of a dotbot "link" directive to
+// c. The options from the "defaults" directive are used for the links that do
+// not set them.
+func (c *importedConfig) addDotbotLinks(value *yaml.Node, defaults dotbotLink) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: links must be a mapping", errImport)
+	}
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		dst := strings.TrimSuffix(value.Content[i].Value, "/")
+		link := defaults
+		link.Path = ""
+
+		switch v := value.Content[i+1]; v.Kind {
+		case yaml.ScalarNode:
+			if v.Tag != "!!null" {
+				link.Path = v.Value
+			}
+		case yaml.MappingNode:
+			if err := v.Decode(&link); err != nil {
+				return fmt.Errorf("failed to decode link %q: %w", dst, err)
+			}
+		default:
+			return fmt.Errorf("%w: invalid value for link %q", errImport, dst)
+		}
+
+		// The sources of the glob links are resolved only when the links are
+		// created so they cannot be translated to single links.
+		if link.Glob {
+			c.warnings = append(c.warnings, fmt.Sprintf("The dotbot glob link %s is not supported and was skipped", dst))
+
+			continue
+		}
+
+		for _, opt := range []struct {
+			name string
+			set  bool
+		}{
+			{"if", link.If != ""},
+			{"relative", link.Relative},
+			{"exclude", len(link.Exclude) > 0},
+			{"prefix", link.Prefix != ""},
+		} {
+			if opt.set {
+				c.warnings = append(
+					c.warnings,
+					fmt.Sprintf("The dotbot link option %q of %s is not supported and was ignored", opt.name, dst),
+				)
+			}
+		}
+
+		// The link task always creates the parent directories and replaces
+		// the links that point to the same file, so "create" and "relink" need
+		// no translation.
+		if link.Path == "" {
+			// Dotbot uses the base name of the link without the leading dot as
+			// the source when the source is not given.
+			link.Path = strings.TrimPrefix(filepath.Base(dst), ".")
+		}
+
+		c.links = append(c.links, importedLink{path: dst, src: link.Path, force: link.Force})
+	}
+
+	return nil
+}
+
+// addDotbotCommands adds the commands from the value of a dotbot "shell"
+// directive to c.
+func (c *importedConfig) addDotbotCommands(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%w: commands must be a list", errImport)
+	}
+
+	for _, v := range value.Content {
+		var cmd importedCommand
+
+		switch v.Kind {
+		case yaml.ScalarNode:
+			cmd.command = v.Value
+		case yaml.SequenceNode:
+			var parts []string
+			if err := v.Decode(&parts); err != nil || len(parts) == 0 {
+				return fmt.Errorf("%w: invalid command on line %d", errImport, v.Line)
+			}
+
+			cmd.command = parts[0]
+
+			if len(parts) > 1 {
+				cmd.description = parts[1]
+			}
+		case yaml.MappingNode:
+			var opts struct {
+				Command     string `yaml:"command"`
+				Description string `yaml:"description"`
+			}
+
+			if err := v.Decode(&opts); err != nil {
+				return fmt.Errorf("failed to decode command on line %d: %w", v.Line, err)
+			}
+
+			cmd.command = opts.Command
+			cmd.description = opts.Description
+		default:
+			return fmt.Errorf("%w: invalid command on line %d", errImport, v.Line)
+		}
+
+		c.commands = append(c.commands, cmd)
+	}
+
+	return nil
+}
+
+// importStow translates the Stow packages in dir to links in target. If
+// packages is empty, all of the packages in dir are translated. If dotfiles is
+// true, the "dot-" prefixes in the file names are replaced with a dot.
+func importStow(dir, target fspath.Path, packages []string, dotfiles bool) (*importedConfig, error) {
+	if len(packages) == 0 {
+		entries, err := os.ReadDir(string(dir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Stow directory %q: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				packages = append(packages, e.Name())
+			}
+		}
+	}
+
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("%w: no packages found in %s", errImport, dir)
+	}
+
+	pkgs := make([]stowPackage, 0, len(packages))
+
+	for _, name := range packages {
+		pkg, err := newStowPackage(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	imported := &importedConfig{
+		tool:     "stow",
+		from:     "the Stow packages in " + string(dir),
+		dir:      dir,
+		links:    nil,
+		commands: nil,
+		warnings: nil,
+	}
+
+	if err := imported.addStowLinks(pkgs, target, "", dotfiles); err != nil {
+		return nil, err
+	}
+
+	return imported, nil
+}
+
+// A stowPackage is a package in a Stow directory.
+type stowPackage struct {
+	name    string
+	dir     fspath.Path
+	ignores []*regexp.Regexp // patterns matched against the file names
+	paths   []*regexp.Regexp // patterns matched against the paths in the package
+}
+
+// newStowPackage returns the package with the given name in dir. The ignore
+// list of the package is read from the local ignore file of the package if it
+// has one.
+func newStowPackage(dir fspath.Path, name string) (stowPackage, error) {
+	pkg := stowPackage{name: name, dir: dir.Join(name), ignores: nil, paths: nil}
+
+	ok, err := pkg.dir.IsDir()
+	if err != nil {
+		return stowPackage{}, fmt.Errorf("failed to check package %q: %w", name, err)
+	}
+
+	if !ok {
+		return stowPackage{}, fmt.Errorf("%w: package %q not found in %s", errImport, name, dir)
+	}
+
+	patterns := stowDefaultIgnore
+
+	f, err := os.Open(string(pkg.dir.Join(stowLocalIgnore)))
+	if err == nil {
+		patterns = nil
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				patterns = append(patterns, line)
+			}
+		}
+
+		err = scanner.Err()
+		_ = f.Close()
+
+		if err != nil {
+			return stowPackage{}, fmt.Errorf("failed to read the ignore list of package %q: %w", name, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return stowPackage{}, fmt.Errorf("failed to open the ignore list of package %q: %w", name, err)
+	}
+
+	// The ignore file itself is never linked.
+	patterns = append(patterns, regexp.QuoteMeta(stowLocalIgnore))
+
+	for _, s := range patterns {
+		re, err := regexp.Compile("^(?:" + s + ")$")
+		if err != nil {
+			return stowPackage{}, fmt.Errorf("%w: invalid ignore pattern %q in package %q: %w", errImport, s, name, err)
+		}
+
+		if strings.Contains(s, "/") {
+			pkg.paths = append(pkg.paths, re)
+		} else {
+			pkg.ignores = append(pkg.ignores, re)
+		}
+	}
+
+	return pkg, nil
+}
+
+// ignored reports whether the file at the given slash-separated path within
+// the package is in the ignore list of the package.
+func (p stowPackage) ignored(rel string) bool {
+	name := rel[strings.LastIndex(rel, "/")+1:]
+
+	return slices.ContainsFunc(p.ignores, func(re *regexp.Regexp) bool { return re.MatchString(name) }) ||
+		slices.ContainsFunc(p.paths, func(re *regexp.Regexp) bool { return re.MatchString("/" + rel) })
+}
+
+// addStowLinks adds the links for the files in the directory rel of
+// the packages to c. As in Stow, a directory is linked as a whole if only one
+// of the packages has it and the directory does not exist in target.
+// Otherwise, the links are created for the files in the directory.
+func (c *importedConfig) addStowLinks(pkgs []stowPackage, target fspath.Path, rel string, dotfiles bool) error {
+	var names []string
+
+	owners := make(map[string][]stowPackage)
+
+	for _, pkg := range pkgs {
+		entries, err := os.ReadDir(string(pkg.dir.Join(rel)))
+		if err != nil {
+			return fmt.Errorf("failed to read directory %q: %w", pkg.dir.Join(rel), err)
+		}
+
+		for _, e := range entries {
+			if pkg.ignored(path.Join(rel, e.Name())) {
+				continue
+			}
+
+			if _, ok := owners[e.Name()]; !ok {
+				names = append(names, e.Name())
+			}
+
+			owners[e.Name()] = append(owners[e.Name()], pkg)
+		}
+	}
+
+	for _, name := range names {
+		src := path.Join(rel, name)
+		dst := src
+
+		if dotfiles {
+			dst = path.Join(stowDotfilesName(rel), stowDotfilesName(name))
+		}
+
+		dirs := 0
+
+		for _, pkg := range owners[name] {
+			if ok, err := pkg.dir.Join(src).IsDir(); err != nil {
+				return fmt.Errorf("failed to check %q: %w", pkg.dir.Join(src), err)
+			} else if ok {
+				dirs++
+			}
+		}
+
+		// A real directory in the target is not replaced with a link, and
+		// a directory that multiple packages have cannot be a link to only
+		// one of them.
+		targetDir := false
+
+		if info, err := os.Lstat(string(target.Join(dst))); err == nil && info.IsDir() {
+			targetDir = true
+		}
+
+		switch {
+		case dirs == len(owners[name]) && (targetDir || dirs > 1):
+			if err := c.addStowLinks(owners[name], target, src, dotfiles); err != nil {
+				return err
+			}
+		case len(owners[name]) > 1:
+			c.warnings = append(c.warnings, fmt.Sprintf(
+				"The packages %s both have %s, only the one from %s is linked",
+				owners[name][0].name,
+				src,
+				owners[name][0].name,
+			))
+
+			fallthrough
+		default:
+			c.links = append(c.links, importedLink{
+				path:  homePath(target.Join(dst)),
+				src:   path.Join(owners[name][0].name, src),
+				force: false,
+			})
+		}
+	}
+
+	return nil
+}
+
+// stowDotfilesName replaces the "dot-" prefix of each element of the given
+// slash-separated path with a dot.
+func stowDotfilesName(rel string) string {
+	parts := strings.Split(rel, "/")
+
+	for i, s := range parts {
+		if rest, ok := strings.CutPrefix(s, "dot-"); ok {
+			parts[i] = "." + rest
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// homePath returns p with the user's home directory replaced with "~" so that
+// the config works for other users too.
+func homePath(p fspath.Path) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return string(p)
+	}
+
+	rel, err := filepath.Rel(home, string(p))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return string(p)
+	}
+
+	if rel == "." {
+		return "~"
+	}
+
+	return "~/" + filepath.ToSlash(rel)
+}
+
+// marshal returns the contents of the config file for c.
+func (c *importedConfig) marshal() ([]byte, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Reginald config file imported from %s\n", c.from)
+	fmt.Fprintf(&b, "# by `reginald import %s`.\n\n", c.tool)
+	b.WriteString("# The dotfiles directory. The relative paths in the config are resolved from it.\n")
+
+	if err := writeTOMLValue(&b, "directory", string(c.dir)); err != nil {
+		return nil, err
+	}
+
+	if len(c.links) > 0 {
+		b.WriteString("\n[[tasks]]\n")
+
+		if err := writeTOMLValue(&b, "type", linkTaskType); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&b, "\n[tasks.%s]\n", linksKey)
+
+		for _, l := range c.links {
+			var line strings.Builder
+
+			enc := toml.NewEncoder(&line)
+			enc.SetTablesInline(true)
+
+			if err := enc.Encode(map[string]linkTable{l.path: {Src: l.src, Force: l.force}}); err != nil {
+				return nil, fmt.Errorf("failed to encode link %q as TOML: %w", l.path, err)
+			}
+
+			b.WriteString(line.String())
+		}
+	}
+
+	if len(c.commands) > 0 {
+		b.WriteString("\n# Reginald has no task type for running shell commands, so the following\n")
+		b.WriteString("# commands were not translated and must be run in some other way.\n")
+
+		for _, cmd := range c.commands {
+			b.WriteString("#\n")
+
+			if cmd.description != "" {
+				fmt.Fprintf(&b, "# %s:\n", cmd.description)
+			}
+
+			for _, line := range strings.Split(strings.TrimSpace(cmd.command), "\n") {
+				fmt.Fprintf(&b, "#   %s\n", line)
+			}
+		}
+	}
+
+	return []byte(b.String()), nil
+}
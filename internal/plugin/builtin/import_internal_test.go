@@ -0,0 +1,495 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+func TestImportDotbot(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name     string
+		config   string
+		links    []importedLink
+		commands []importedCommand
+		warnings []string
+	}{
+		{
+			name:     "Empty",
+			config:   "",
+			links:    nil,
+			commands: nil,
+			warnings: nil,
+		},
+		{
+			name: "Defaults",
+			config: `- link:
+    ~/.bashrc: bashrc
+- defaults:
+    link:
+      force: true
+      create: true
+- link:
+    ~/.vimrc:
+    ~/.config/nvim/: nvim
+    ~/.zshrc:
+      path: zsh/zshrc
+      force: false
+`,
+			links: []importedLink{
+				{path: "~/.bashrc", src: "bashrc", force: false},
+				{path: "~/.vimrc", src: "vimrc", force: true},
+				{path: "~/.config/nvim", src: "nvim", force: true},
+				{path: "~/.zshrc", src: "zsh/zshrc", force: false},
+			},
+			commands: nil,
+			warnings: nil,
+		},
+		{
+			name: "ImplicitSource",
+			config: `- link:
+    ~/.tmux.conf:
+    ~/.gitconfig: null
+    ~/bin/tool:
+      force: true
+`,
+			links: []importedLink{
+				{path: "~/.tmux.conf", src: "tmux.conf", force: false},
+				{path: "~/.gitconfig", src: "gitconfig", force: false},
+				{path: "~/bin/tool", src: "tool", force: true},
+			},
+			commands: nil,
+			warnings: nil,
+		},
+		{
+			name: "Shell",
+			config: `- shell:
+    - git submodule update
+    - [make install, Installing]
+    - command: |
+        echo a
+        echo b
+      description: Echoing
+`,
+			links: nil,
+			commands: []importedCommand{
+				{command: "git submodule update", description: ""},
+				{command: "make install", description: "Installing"},
+				{command: "echo a\necho b\n", description: "Echoing"},
+			},
+			warnings: []string{
+				"The dotbot shell directive on line 2 cannot be translated and was written as comments",
+			},
+		},
+		{
+			name: "Unsupported",
+			config: `- clean: ["~"]
+- link:
+    ~/.config/*:
+      glob: true
+      path: config/*
+    ~/.profile:
+      relative: true
+      if: "[ -n x ]"
+`,
+			links: []importedLink{
+				{path: "~/.profile", src: "profile", force: false},
+			},
+			commands: nil,
+			warnings: []string{
+				`The dotbot directive "clean" on line 1 is not supported and was skipped`,
+				"The dotbot glob link ~/.config/* is not supported and was skipped",
+				`The dotbot link option "if" of ~/.profile is not supported and was ignored`,
+				`The dotbot link option "relative" of ~/.profile is not supported and was ignored`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "install.conf.yaml")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := importDotbot(fspath.Path(file))
+			if err != nil {
+				t.Fatalf("importDotbot() error = %v", err)
+			}
+
+			if got.dir != fspath.Path(filepath.Dir(file)) {
+				t.Errorf("importDotbot() dir = %q, want %q", got.dir, filepath.Dir(file))
+			}
+
+			if !reflect.DeepEqual(got.links, tt.links) {
+				t.Errorf("importDotbot() links = %+v, want %+v", got.links, tt.links)
+			}
+
+			if !reflect.DeepEqual(got.commands, tt.commands) {
+				t.Errorf("importDotbot() commands = %+v, want %+v", got.commands, tt.commands)
+			}
+
+			if !reflect.DeepEqual(got.warnings, tt.warnings) {
+				t.Errorf("importDotbot() warnings = %q, want %q", got.warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestImportDotbot_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"NotList", "link:\n  ~/.vimrc: vimrc\n"},
+		{"LinksNotMapping", "- link: [vimrc]\n"},
+		{"ShellNotList", "- shell: make\n"},
+		{"InvalidYAML", "- link: [\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "install.conf.yaml")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := importDotbot(fspath.Path(file)); err == nil {
+				t.Errorf("importDotbot() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestImportStow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name     string
+		files    []string // files in the Stow directory, directories end with a slash
+		ignore   string   // local ignore list of the "vim" package
+		existing []string // directories that exist in the target
+		packages []string
+		dotfiles bool
+		want     []importedLink // the paths are relative to the target
+		warnings int
+	}{
+		{
+			name:     "DefaultIgnore",
+			files:    []string{"vim/.vimrc", "vim/README.md", "vim/.git/config", "vim/notes~", "vim/docs/README.md"},
+			ignore:   "",
+			existing: nil,
+			packages: nil,
+			dotfiles: false,
+			want: []importedLink{
+				{path: ".vimrc", src: "vim/.vimrc", force: false},
+				{path: "docs", src: "vim/docs", force: false},
+			},
+			warnings: 0,
+		},
+		{
+			name:     "LocalIgnore",
+			files:    []string{"vim/.vimrc", "vim/README.md", "vim/secret"},
+			ignore:   "# comment\n\nsecret\n",
+			existing: nil,
+			packages: nil,
+			dotfiles: false,
+			want: []importedLink{
+				{path: ".vimrc", src: "vim/.vimrc", force: false},
+				{path: "README.md", src: "vim/README.md", force: false},
+			},
+			warnings: 0,
+		},
+		{
+			name:     "Dotfiles",
+			files:    []string{"nvim/dot-config/nvim/init.lua", "nvim/dot-vimrc", "nvim/plain"},
+			ignore:   "",
+			existing: nil,
+			packages: nil,
+			dotfiles: true,
+			want: []importedLink{
+				{path: ".config", src: "nvim/dot-config", force: false},
+				{path: ".vimrc", src: "nvim/dot-vimrc", force: false},
+				{path: "plain", src: "nvim/plain", force: false},
+			},
+			warnings: 0,
+		},
+		{
+			name:     "DotfilesExistingTarget",
+			files:    []string{"nvim/dot-config/nvim/init.lua"},
+			ignore:   "",
+			existing: []string{".config"},
+			packages: nil,
+			dotfiles: true,
+			want: []importedLink{
+				{path: ".config/nvim", src: "nvim/dot-config/nvim", force: false},
+			},
+			warnings: 0,
+		},
+		{
+			name:     "NoDotfiles",
+			files:    []string{"nvim/dot-vimrc"},
+			ignore:   "",
+			existing: nil,
+			packages: nil,
+			dotfiles: false,
+			want: []importedLink{
+				{path: "dot-vimrc", src: "nvim/dot-vimrc", force: false},
+			},
+			warnings: 0,
+		},
+		{
+			name:     "SharedDirectory",
+			files:    []string{"git/.config/git/config", "fish/.config/fish/config.fish", "fish/.profile", "git/.profile"},
+			ignore:   "",
+			existing: nil,
+			packages: []string{"fish", "git"},
+			dotfiles: false,
+			want: []importedLink{
+				{path: ".config/fish", src: "fish/.config/fish", force: false},
+				{path: ".config/git", src: "git/.config/git", force: false},
+				{path: ".profile", src: "fish/.profile", force: false},
+			},
+			warnings: 1,
+		},
+		{
+			name:     "SelectedPackages",
+			files:    []string{"git/.gitconfig", "vim/.vimrc"},
+			ignore:   "",
+			existing: nil,
+			packages: []string{"vim"},
+			dotfiles: false,
+			want: []importedLink{
+				{path: ".vimrc", src: "vim/.vimrc", force: false},
+			},
+			warnings: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			target := t.TempDir()
+
+			for _, f := range tt.files {
+				writeTestFile(t, dir, f)
+			}
+
+			if tt.ignore != "" {
+				if err := os.WriteFile(filepath.Join(dir, "vim", stowLocalIgnore), []byte(tt.ignore), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, d := range tt.existing {
+				if err := os.MkdirAll(filepath.Join(target, d), 0o700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := importStow(fspath.Path(dir), fspath.Path(target), tt.packages, tt.dotfiles)
+			if err != nil {
+				t.Fatalf("importStow() error = %v", err)
+			}
+
+			want := make([]importedLink, len(tt.want))
+			for i, l := range tt.want {
+				want[i] = importedLink{path: homePath(fspath.Path(target).Join(l.path)), src: l.src, force: l.force}
+			}
+
+			if !reflect.DeepEqual(got.links, want) {
+				t.Errorf("importStow() links = %+v, want %+v", got.links, want)
+			}
+
+			if len(got.warnings) != tt.warnings {
+				t.Errorf("importStow() warnings = %q, want %d warnings", got.warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestImportStow_MissingPackage(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "vim/.vimrc")
+
+	if _, err := importStow(fspath.Path(dir), fspath.Path(t.TempDir()), []string{"emacs"}, false); err == nil {
+		t.Error("importStow() error = nil, want an error")
+	}
+}
+
+func TestStowDotfilesName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"", ""},
+		{"dot-vimrc", ".vimrc"},
+		{"dot-config/dot-nvim/init.lua", ".config/.nvim/init.lua"},
+		{"plain/dot-", "plain/."},
+		{"adot-file", "adot-file"},
+	}
+
+	for _, tt := range tests {
+		if got := stowDotfilesName(tt.rel); got != tt.want {
+			t.Errorf("stowDotfilesName(%q) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestHomePath(t *testing.T) {
+	t.Parallel()
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	outside := filepath.Join(filepath.Dir(home), filepath.Base(home)+"-other", "file")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{home, "~"},
+		{filepath.Join(home, ".vimrc"), "~/.vimrc"},
+		{filepath.Join(home, ".config", "nvim"), "~/.config/nvim"},
+		{outside, outside},
+	}
+
+	for _, tt := range tests {
+		if got := homePath(fspath.Path(tt.path)); got != tt.want {
+			t.Errorf("homePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestImportedConfig_Marshal(t *testing.T) {
+	t.Parallel()
+
+	c := &importedConfig{
+		tool: "dotbot",
+		from: "the dotbot config file /dots/install.conf.yaml",
+		dir:  "/dots",
+		links: []importedLink{
+			{path: "~/.vimrc", src: "vimrc", force: false},
+			{path: "~/My Files/a.txt", src: `a "quoted" file`, force: true},
+		},
+		commands: []importedCommand{
+			{command: "git submodule update", description: ""},
+			{command: "echo a\necho b\n", description: "Echoing"},
+		},
+		warnings: nil,
+	}
+
+	data, err := c.marshal()
+	if err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+
+	want := `# Reginald config file imported from the dotbot config file /dots/install.conf.yaml
+# by ` + "`reginald import dotbot`" + `.
+
+# The dotfiles directory. The relative paths in the config are resolved from it.
+directory = '/dots'
+
+[[tasks]]
+type = 'link/create'
+
+[tasks.links]
+'~/.vimrc' = {src = 'vimrc'}
+'~/My Files/a.txt' = {src = 'a "quoted" file', force = true}
+
+# Reginald has no task type for running shell commands, so the following
+# commands were not translated and must be run in some other way.
+#
+#   git submodule update
+#
+# Echoing:
+#   echo a
+#   echo b
+`
+	if string(data) != want {
+		t.Errorf("marshal() =\n%s\nwant\n%s", data, want)
+	}
+
+	// The config must decode to the same links.
+	var decoded struct {
+		Directory string `toml:"directory"`
+		Tasks     []struct {
+			Type  string               `toml:"type"`
+			Links map[string]linkTable `toml:"links"`
+		} `toml:"tasks"`
+	}
+
+	if err = toml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode the marshaled config: %v", err)
+	}
+
+	if decoded.Directory != "/dots" || len(decoded.Tasks) != 1 || decoded.Tasks[0].Type != linkTaskType {
+		t.Fatalf("decoded config = %+v", decoded)
+	}
+
+	for _, l := range c.links {
+		if got := decoded.Tasks[0].Links[l.path]; got != (linkTable{Src: l.src, Force: l.force}) {
+			t.Errorf("decoded link %q = %+v, want %+v", l.path, got, linkTable{Src: l.src, Force: l.force})
+		}
+	}
+}
+
+// writeTestFile creates the file at the given slash-separated path in dir
+// with its parent directories. A path that ends with a slash is created as
+// a directory.
+func writeTestFile(t *testing.T, dir, name string) {
+	t.Helper()
+
+	p := filepath.Join(dir, filepath.FromSlash(name))
+
+	if strings.HasSuffix(name, "/") {
+		if err := os.MkdirAll(p, 0o700); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(p, []byte(name), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
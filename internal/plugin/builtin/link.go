@@ -16,16 +16,28 @@ package builtin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
 	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
 	"github.com/reginald-project/reginald/internal/version"
 )
 
 const linkName = "reginald-link"
 
+// errLink is returned when a link cannot be created.
+var errLink = errors.New("cannot create link")
+
 // linkManifest returns the manifest for the link plugin.
 func linkManifest() *api.Manifest {
 	//nolint:lll
@@ -98,14 +110,240 @@ func linkManifest() *api.Manifest {
 	}
 }
 
-// linkService is the service function for the "reginald-link" plugin.
-func linkService(ctx context.Context, _ *plugin.Store, method string, _ any) error {
-	switch method {
-	case api.MethodRunTask:
-		slog.InfoContext(ctx, "running task")
+// A linkSpec is a single link that the "create" task creates.
+type linkSpec struct {
+	path  fspath.Path // path of the link to create
+	src   fspath.Path // file that the link points to
+	force bool        // whether to remove an existing file at path
+}
+
+// linkService returns the service function for the "reginald-link" plugin.
+func linkService(cfg *config.Config) plugin.Service {
+	return func(ctx context.Context, _ *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunTask:
+			p, ok := params.(plugin.RunTaskParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunTaskParams", plugin.ErrInvalidCast)
+			}
+
+			if p.Elevate {
+				return nil, fmt.Errorf("%w: %s", errElevate, linkTaskType)
+			}
+
+			links, err := parseLinks(p.Config, cfg.Directory)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, l := range links {
+				if err = createLink(ctx, l, p.DryRun, p.BackupDir); err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, nil
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", linkName, method))
+		}
+	}
+}
+
+// createLink creates the given link. If the link already points to the correct
+// file, createLink does nothing. This includes the junctions and copies that
+// are created instead of symbolic links on Windows. In dry-run mode, it only prints the link that
+// would be created. An existing file that is replaced by the link is saved to
+// the snapshot of the run in backupDir unless it is empty.
+func createLink(ctx context.Context, l linkSpec, dryRun bool, backupDir string) error {
+	ok, err := fsutil.IsLink(string(l.src), string(l.path))
+	if err != nil {
+		return fmt.Errorf("failed to check link %q: %w", l.path, err)
+	}
+
+	if ok {
+		slog.DebugContext(ctx, "link already exists", "path", l.path, "src", l.src)
 
 		return nil
-	default:
-		panic(fmt.Sprintf("invalid method call to %q: %s", linkName, method))
 	}
+
+	ok, err = exists(l.src)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: link source %q does not exist", errLink, l.src)
+	}
+
+	ok, err = exists(l.path)
+	if err != nil {
+		return err
+	}
+
+	if ok && !l.force {
+		return fmt.Errorf("%w: file %q already exists", errLink, l.path)
+	}
+
+	if dryRun {
+		terminal.Printf("Would link %s to %s\n", l.path, l.src)
+
+		return nil
+	}
+
+	if ok && backupDir != "" {
+		var backup string
+
+		if backup, err = fsutil.Snapshot(string(l.path), backupDir); err != nil {
+			return fmt.Errorf("failed to back up %q: %w", l.path, err)
+		}
+
+		slog.InfoContext(ctx, "existing file saved to the run snapshot", "path", l.path, "backup", backup)
+	}
+
+	if ok {
+		slog.InfoContext(ctx, "removing existing file", "path", l.path)
+
+		if err = os.RemoveAll(string(l.path)); err != nil {
+			return fmt.Errorf("failed to remove %q: %w", l.path, err)
+		}
+	}
+
+	if err = os.MkdirAll(string(l.path.Dir()), fsutil.DefaultDirPerm); err != nil {
+		return fmt.Errorf("failed to create directory for %q: %w", l.path, err)
+	}
+
+	mode, err := fsutil.Link(string(l.src), string(l.path))
+	if err != nil {
+		return fmt.Errorf("failed to link %q to %q: %w", l.path, l.src, err)
+	}
+
+	slog.InfoContext(ctx, "link created", "path", l.path, "src", l.src, "mode", mode)
+
+	if mode != fsutil.Symlink {
+		msg := fmt.Sprintf("Could not create a symbolic link at %s, created a %s instead", l.path, mode)
+		diag.Report(ctx, diag.LinkFallback, msg)
+	}
+
+	return nil
+}
+
+// exists reports whether a file exists at the given path. It does not follow
+// symbolic links so broken links are reported as existing files.
+func exists(path fspath.Path) (bool, error) {
+	if _, err := os.Lstat(string(path)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to get info for %q: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// linkSource resolves the file that the link at path points to when no source
+// is given in the config. If path is within the user's home directory,
+// the source is the same relative path in the dotfiles directory dir without
+// the leading dot, e.g. "~/.config/nvim" points to "<dir>/config/nvim".
+// Otherwise, the source is the base name of path without the leading dot in
+// dir.
+func linkSource(path, dir fspath.Path) (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	rel, err := filepath.Rel(home, string(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = string(path.Base())
+	}
+
+	return dir.Join(strings.TrimPrefix(rel, ".")), nil
+}
+
+// parseLinks parses the links to create from the config of the task.
+func parseLinks(cfg api.KeyValues, dir fspath.Path) ([]linkSpec, error) {
+	force := false
+
+	if kv, ok := cfg.Get("force"); ok {
+		var err error
+
+		if force, err = kv.Bool(); err != nil {
+			return nil, fmt.Errorf("failed to read \"force\": %w", err)
+		}
+	}
+
+	kv, ok := cfg.Get("links")
+	if !ok {
+		return nil, nil
+	}
+
+	if kv.Type == api.PathListValue {
+		paths, ok := kv.Val.([]fspath.Path)
+		if !ok {
+			return nil, fmt.Errorf("%w: \"links\" has invalid type %T", plugin.ErrInvalidCast, kv.Val)
+		}
+
+		links := make([]linkSpec, 0, len(paths))
+
+		for _, path := range paths {
+			src, err := linkSource(path, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			links = append(links, linkSpec{path: path, src: src, force: force})
+		}
+
+		return links, nil
+	}
+
+	entries, err := kv.Configs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read \"links\": %w", err)
+	}
+
+	links := make([]linkSpec, 0, len(entries))
+
+	for _, entry := range entries {
+		l, err := parseLinkEntry(entry, dir, force)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, l)
+	}
+
+	return links, nil
+}
+
+// parseLinkEntry parses a single link from a table in the "links" config.
+// The force option of the task is used as the default for the link.
+func parseLinkEntry(entry api.KeyVal, dir fspath.Path, force bool) (linkSpec, error) {
+	l := linkSpec{path: fspath.Path(entry.Key), src: "", force: force}
+
+	values, err := entry.Configs()
+	if err != nil {
+		return linkSpec{}, fmt.Errorf("failed to read link %q: %w", entry.Key, err)
+	}
+
+	if kv, ok := values.Get("force"); ok {
+		if l.force, err = kv.Bool(); err != nil {
+			return linkSpec{}, fmt.Errorf("failed to read \"force\" for %q: %w", entry.Key, err)
+		}
+	}
+
+	if kv, ok := values.Get("src"); ok {
+		if l.src, ok = kv.Val.(fspath.Path); !ok {
+			return linkSpec{}, fmt.Errorf("%w: \"src\" for %q has invalid type %T", plugin.ErrInvalidCast, entry.Key, kv.Val)
+		}
+	}
+
+	if l.src == "" {
+		if l.src, err = linkSource(l.path, dir); err != nil {
+			return linkSpec{}, err
+		}
+	}
+
+	return l, nil
 }
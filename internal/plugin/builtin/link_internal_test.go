@@ -0,0 +1,69 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+)
+
+func TestCreateLink_Twice(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		dir  bool
+	}{
+		{name: "File", dir: false},
+		{name: "Directory", dir: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "src")
+
+			var err error
+			if tt.dir {
+				err = os.Mkdir(src, 0o700)
+			} else {
+				err = os.WriteFile(src, []byte("content"), 0o600)
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to create source: %v", err)
+			}
+
+			l := linkSpec{path: fspath.Path(filepath.Join(tmp, "link")), src: fspath.Path(src), force: false}
+
+			for i := range 2 {
+				if err = createLink(t.Context(), l, false, ""); err != nil {
+					t.Fatalf("createLink() run %d error = %v", i+1, err)
+				}
+
+				ok, err := fsutil.IsLink(src, string(l.path))
+				if err != nil || !ok {
+					t.Fatalf("IsLink() after run %d = %v, %v, want true, nil", i+1, ok, err)
+				}
+			}
+		})
+	}
+}
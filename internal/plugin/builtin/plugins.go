@@ -0,0 +1,722 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/registry"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// sdkModule is the module path of the Go SDK that the generated Go plugins use.
+const sdkModule = "github.com/reginald-project/reginald-sdk-go"
+
+// Permissions for the files of the generated plugins.
+const (
+	scaffoldDirPerm  fs.FileMode = 0o755
+	scaffoldFilePerm fs.FileMode = 0o644
+	scaffoldExecPerm fs.FileMode = 0o755
+)
+
+// Errors returned by the plugin commands.
+var (
+	errPluginInstall = errors.New("installing plugin failed")
+	errPluginNew     = errors.New("creating plugin failed")
+	errPluginTest    = errors.New("plugin does not follow the protocol")
+	errPluginUpdate  = errors.New("updating plugin failed")
+)
+
+// pluginNamePattern is the pattern that the names of the generated plugins
+// must match.
+var pluginNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`) //nolint:gochecknoglobals // used as a constant
+
+// scaffoldTemplates contains the templates for the generated plugins. Each
+// language has its own directory, and the file names without the ".tmpl"
+// suffix are used as the names of the generated files.
+//
+//go:embed templates
+var scaffoldTemplates embed.FS //nolint:gochecknoglobals // embedded files
+
+// scaffoldData is the data for executing the templates of a generated plugin.
+type scaffoldData struct {
+	Name       string // name of the plugin
+	Domain     string // domain of the plugin
+	Executable string // name of the executable of the plugin
+	Module     string // module path of a Go plugin
+	SDKVersion string // version of the Go SDK, empty if unknown
+}
+
+// pluginCommand returns the manifest entry for the "plugin" command that
+// contains the commands for managing the plugins.
+func pluginCommand() *api.Command {
+	return &api.Command{
+		Name:        "plugin",
+		Usage:       "plugin <command>",
+		Description: "Manage the plugins.",
+		Help:        "Contains the commands for managing the plugins.",
+		Manual:      "",
+		Aliases:     nil,
+		Config:      nil,
+		Commands: []*api.Command{
+			{
+				Name:        "clean",
+				Usage:       "plugin clean <name>...",
+				Description: "Remove the data of plugins.",
+				//nolint:lll
+				Help:     "Removes the data directories of the given external plugins and everything in them. Each plugin has a data directory for caching downloads and keeping state between the runs, and it is created again when the plugin is run the next time. The plugins can be given by their names or domains.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "name",
+							Description: "Name or domain of the plugin.",
+						},
+					},
+					Min: 1,
+					Max: -1,
+				},
+			},
+			{
+				Name:        "install",
+				Usage:       "plugin install [<name>[@<version>]...]",
+				Description: "Install plugins from the plugin indexes.",
+				//nolint:lll
+				Help:     "Installs the given plugins from the plugin indexes set in \"plugin-indexes\" to the first plugin search path and records them in the lock file \"reginald.lock\" next to the config file. The latest version that is available for the current platform is installed unless a version is given after the name. The plugins that are in the lock file are installed at the locked versions, and without arguments, all of the locked plugins that are not installed are installed. The checksums of the downloaded archives are verified before the plugins are installed.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "name",
+							Description: "Name of the plugin with an optional version, like \"name@1.2.3\".",
+						},
+					},
+					Min: 0,
+					Max: -1,
+				},
+			},
+			{
+				Name:        "new",
+				Usage:       "plugin new [options] <name>",
+				Description: "Create a new plugin.",
+				//nolint:lll
+				Help:    "Creates a skeleton of a new plugin to a directory with the given name in the current directory. The skeleton contains the manifest, a working implementation of the protocol with an example command and task, and tests. The plugin can be run with `dev` while developing it.",
+				Manual:  "",
+				Aliases: nil,
+				Config: []api.ConfigEntry{
+					{
+						ConfigValue: api.ConfigValue{
+							KeyVal: api.KeyVal{
+								Value: api.Value{Val: "go", Type: api.StringValue},
+								Key:   "lang",
+							},
+							Description: "Language of the plugin.",
+						},
+						Flag: &api.Flag{
+							Name:        "lang",
+							Shorthand:   "",
+							Description: "create the plugin in `<lang>`, either \"go\" or \"python\"",
+							Manual:      "",
+						},
+						EnvOverride: "",
+						FlagOnly:    true,
+					},
+				},
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "name",
+							Description: "Name of the plugin.",
+						},
+					},
+					Min: 1,
+					Max: 1,
+				},
+			},
+			{
+				Name:        "refresh",
+				Usage:       "plugin refresh",
+				Description: "Rescan the plugin search paths.",
+				//nolint:lll
+				Help:     "Removes the cache of the plugin manifests and reads the manifests from the plugin search paths again. The cache is invalidated automatically when a plugin directory or a manifest changes, but this command can be used to force the rescan, for example, if an executable outside of the plugin directory has been replaced.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args:     nil,
+			},
+			{
+				Name:        "search",
+				Usage:       "plugin search [<term>]",
+				Description: "Search for plugins in the plugin indexes.",
+				//nolint:lll
+				Help:     "Lists the plugins in the plugin indexes set in \"plugin-indexes\" whose name or description contains the given term. All of the plugins are listed if no term is given. The listed version is the latest version that is available for the current platform.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "term",
+							Description: "Term to search for.",
+						},
+					},
+					Min: 0,
+					Max: 1,
+				},
+			},
+			{
+				Name:        "test",
+				Usage:       "plugin test <path>",
+				Description: "Check that a plugin follows the protocol.",
+				//nolint:lll
+				Help:     "Runs the plugin in the given plugin directory through a scripted session and reports the protocol violations. The session consists of the handshake, calls to unknown methods, commands, and tasks and with invalid params, the shutdown, and the exit. The plugin is also checked to exit when the connection is closed. The command can be used to check plugins written in any language.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "path",
+							Description: "Path to the plugin directory.",
+						},
+					},
+					Min: 1,
+					Max: 1,
+				},
+			},
+			{
+				Name:        "update",
+				Usage:       "plugin update [<name>[@<version>]...]",
+				Description: "Update the installed plugins.",
+				//nolint:lll
+				Help:     "Updates the given plugins to the latest version in the plugin indexes, or to the given version, and records the new versions in the lock file \"reginald.lock\". Without arguments, all of the plugins in the lock file are updated. The lock file is changed only by this command and \"plugin install\", and every run checks that the installed plugins match it.",
+				Manual:   "",
+				Aliases:  nil,
+				Config:   nil,
+				Commands: nil,
+				Args: &api.Arguments{
+					Spec: []api.ArgSpec{
+						{
+							Name:        "name",
+							Description: "Name of the plugin with an optional version, like \"name@1.2.3\".",
+						},
+					},
+					Min: 0,
+					Max: -1,
+				},
+			},
+		},
+		Args: nil,
+	}
+}
+
+// runPluginClean runs the "plugin clean" command that removes the data
+// directories of the given plugins.
+func runPluginClean(ctx context.Context, store *plugin.Store, p plugin.RunCommandParams) error {
+	for _, name := range p.Args {
+		dir, err := store.DataDir(name)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		removed, err := store.CleanData(name)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if !removed {
+			terminal.Printf("No data for %s\n", name)
+
+			continue
+		}
+
+		slog.InfoContext(ctx, "removed plugin data", "plugin", name, "dir", dir)
+		terminal.Printf("Removed %s\n", dir)
+	}
+
+	return nil
+}
+
+// runPluginRefresh runs the "plugin refresh" command that removes the plugin
+// manifest cache and rebuilds it by reading the plugin search paths.
+func runPluginRefresh(ctx context.Context, cfg *config.Config) error {
+	cacheFile, err := config.PluginCacheFile()
+	if err != nil {
+		return fmt.Errorf("failed to get the plugin cache file: %w", err)
+	}
+
+	if err = os.Remove(string(cacheFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove the plugin cache file: %w", err)
+	}
+
+	slog.DebugContext(ctx, "removed plugin manifest cache", "path", cacheFile)
+
+	var pathErrs plugin.PathErrors
+
+	store, err := plugin.NewStore(ctx, Manifests(), cfg.Directory, cfg.PluginPaths, cacheFile)
+	if err != nil && !errors.As(err, &pathErrs) {
+		return fmt.Errorf("failed to search for plugins: %w", err)
+	}
+
+	n := 0
+
+	for p := range store.VisitPlugins() {
+		if p.External() {
+			n++
+		}
+	}
+
+	terminal.Printf("Found %d external plugins\n", n)
+
+	return nil
+}
+
+// runPluginTest runs the "plugin test" command that checks that the plugin in
+// the given directory follows the protocol.
+func runPluginTest(ctx context.Context, p plugin.RunCommandParams) error {
+	if len(p.Args) != 1 {
+		return fmt.Errorf("%w: expected exactly one plugin directory, got %d", errPluginTest, len(p.Args))
+	}
+
+	dir, err := fspath.NewAbs(p.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve the plugin directory: %w", err)
+	}
+
+	terminal.Printf("Checking plugin in %s\n", dir)
+
+	results, err := plugin.CheckConformance(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to run the plugin: %w", err)
+	}
+
+	failed := 0
+
+	for _, r := range results {
+		if r.Err == nil {
+			terminal.Printf("  ok    %s\n", r.Name)
+
+			continue
+		}
+
+		failed++
+
+		terminal.Printf("  FAIL  %s\n        %v\n", r.Name, r.Err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errPluginTest, failed, len(results))
+	}
+
+	terminal.Printf("All %d checks passed\n", len(results))
+
+	return nil
+}
+
+// runPluginInstall runs the "plugin install" command that installs the given
+// plugins from the plugin indexes and records them in the lock file. Without
+// arguments, it installs the locked plugins that are not installed.
+func runPluginInstall(ctx context.Context, store *plugin.Store, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(cfg.PluginPaths) == 0 {
+		return errhint.Wrap(
+			fmt.Errorf("%w: no plugin search paths", errPluginInstall),
+			"add a directory to \"plugin-paths\" in the config",
+		)
+	}
+
+	dir, err := plugin.ResolveSearchPath(cfg.Directory, cfg.PluginPaths[0])
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPluginInstall, err)
+	}
+
+	lockFile := cfg.LockFile()
+
+	lock, err := registry.ReadLock(lockFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPluginInstall, err)
+	}
+
+	refs := p.Args
+	if len(refs) == 0 {
+		for _, name := range slices.Sorted(maps.Keys(lock.Plugins)) {
+			if _, ok := store.Dir(name); !ok {
+				refs = append(refs, name)
+			}
+		}
+
+		if len(refs) == 0 {
+			terminal.Printf("All of the plugins in %s are installed\n", lockFile)
+
+			return nil
+		}
+	}
+
+	var indexes []*registry.Index
+
+	cache := downloadCache(ctx)
+
+	for _, ref := range refs {
+		name, version, err := registry.ParseRef(ref)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errPluginInstall, err)
+		}
+
+		if installed, ok := store.Dir(name); ok {
+			return errhint.Wrapf(
+				fmt.Errorf("%w: %w: %s", errPluginInstall, registry.ErrExists, installed),
+				"run \"reginald plugin update %s\" to change the installed version",
+				name,
+			)
+		}
+
+		locked, isLocked := lock.Plugins[name]
+		if isLocked && version != "" && !registry.SameVersion(version, locked.Version) {
+			return errhint.Wrapf(
+				fmt.Errorf("%w: %s is locked to version %s", errPluginInstall, name, locked.Version),
+				"run \"reginald plugin update %s@%s\" to change the locked version",
+				name,
+				version,
+			)
+		}
+
+		// The plugins that have been locked on this platform are installed from
+		// the locked artifacts so that the indexes are not needed.
+		r, pinned := lock.Resolved(name)
+		if !pinned {
+			if isLocked {
+				version = locked.Version
+			}
+
+			if indexes == nil {
+				if indexes, err = fetchIndexes(ctx, cfg); err != nil {
+					return err
+				}
+			}
+
+			if r, err = registry.Resolve(indexes, name, version); err != nil {
+				return fmt.Errorf("%w: %w", errPluginInstall, err)
+			}
+		}
+
+		pluginDir, err := registry.Install(ctx, cache, r, dir)
+		if errors.Is(err, registry.ErrExists) {
+			return errhint.Wrapf(
+				fmt.Errorf("%w: %w", errPluginInstall, err),
+				"remove the directory of the plugin to install %s again",
+				name,
+			)
+		} else if err != nil {
+			return fmt.Errorf("%w: %w", errPluginInstall, err)
+		}
+
+		if pinned {
+			err = lock.Verify(name, pluginDir)
+		} else {
+			err = lock.Set(r, pluginDir)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", errPluginInstall, err)
+		}
+
+		if err = lock.Write(lockFile); err != nil {
+			return fmt.Errorf("%w: %w", errPluginInstall, err)
+		}
+
+		terminal.Printf("Installed %s@%s to %s\n", r.Name, r.Version, pluginDir)
+	}
+
+	return nil
+}
+
+// runPluginSearch runs the "plugin search" command that lists the plugins in
+// the plugin indexes that match the given term.
+func runPluginSearch(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	indexes, err := fetchIndexes(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	term := ""
+	if len(p.Args) > 0 {
+		term = p.Args[0]
+	}
+
+	matches := registry.Search(indexes, term)
+	if len(matches) == 0 {
+		terminal.Printf("No plugins matching %q found\n", term)
+
+		return nil
+	}
+
+	cols := []terminal.TableColumn{
+		{Header: "NAME", AlignRight: false},
+		{Header: "VERSION", AlignRight: false},
+		{Header: "DESCRIPTION", AlignRight: false},
+	}
+	rows := make([]terminal.TableRow, 0, len(matches))
+
+	for _, m := range matches {
+		version := m.Latest
+		if version == "" {
+			version = "-"
+		}
+
+		rows = append(rows, terminal.TableRow{
+			Cells: []string{m.Name, version, m.Description},
+			Style: terminal.RowPlain,
+		})
+	}
+
+	terminal.PrintTable(cols, rows)
+
+	return nil
+}
+
+// runPluginUpdate runs the "plugin update" command that updates the given
+// plugins, or all of the locked plugins, from the plugin indexes and records
+// the new versions in the lock file.
+func runPluginUpdate(ctx context.Context, store *plugin.Store, cfg *config.Config, p plugin.RunCommandParams) error {
+	lockFile := cfg.LockFile()
+
+	lock, err := registry.ReadLock(lockFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPluginUpdate, err)
+	}
+
+	refs := p.Args
+	if len(refs) == 0 {
+		refs = slices.Sorted(maps.Keys(lock.Plugins))
+
+		if len(refs) == 0 {
+			terminal.Printf("No plugins in %s\n", lockFile)
+
+			return nil
+		}
+	}
+
+	indexes, err := fetchIndexes(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	cache := downloadCache(ctx)
+
+	for _, ref := range refs {
+		name, version, err := registry.ParseRef(ref)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errPluginUpdate, err)
+		}
+
+		dir, ok := store.Dir(name)
+		if !ok {
+			return errhint.Wrapf(
+				fmt.Errorf("%w: %s is not installed", errPluginUpdate, name),
+				"run \"reginald plugin install %s\" to install it",
+				ref,
+			)
+		}
+
+		r, err := registry.Resolve(indexes, name, version)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errPluginUpdate, err)
+		}
+
+		if locked, ok := lock.Plugins[name]; ok && locked.Version == r.Version && lock.Verify(name, dir) == nil {
+			terminal.Printf("%s@%s is up to date\n", name, r.Version)
+
+			continue
+		}
+
+		if err = registry.Update(ctx, cache, r, dir); err != nil {
+			return fmt.Errorf("%w: %w", errPluginUpdate, err)
+		}
+
+		if err = lock.Set(r, dir); err != nil {
+			return fmt.Errorf("%w: %w", errPluginUpdate, err)
+		}
+
+		if err = lock.Write(lockFile); err != nil {
+			return fmt.Errorf("%w: %w", errPluginUpdate, err)
+		}
+
+		terminal.Printf("Updated %s to %s\n", name, r.Version)
+	}
+
+	return nil
+}
+
+// fetchIndexes fetches the plugin indexes that are set in the config.
+func fetchIndexes(ctx context.Context, cfg *config.Config) ([]*registry.Index, error) {
+	indexes, err := registry.Fetch(ctx, cfg.PluginIndexes)
+	if errors.Is(err, registry.ErrNoIndexes) {
+		return nil, errhint.Wrap(err, "add the URLs of the indexes to \"plugin-indexes\" in the config")
+	} else if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return indexes, nil
+}
+
+// runPluginNew runs the "plugin new" command that creates the skeleton of
+// a new plugin.
+func runPluginNew(ctx context.Context, p plugin.RunCommandParams) error {
+	if len(p.Args) != 1 {
+		return fmt.Errorf("%w: expected exactly one plugin name, got %d", errPluginNew, len(p.Args))
+	}
+
+	name := p.Args[0]
+	if !pluginNamePattern.MatchString(name) {
+		return fmt.Errorf(
+			"%w: invalid name %q, use lowercase letters, digits, and hyphens starting with a letter",
+			errPluginNew,
+			name,
+		)
+	}
+
+	lang, err := stringConfig(p.Config, "lang")
+	if err != nil {
+		return err
+	}
+
+	lang = strings.ToLower(lang)
+
+	data := scaffoldData{
+		Name:       name,
+		Domain:     strings.TrimPrefix(name, "reginald-"),
+		Executable: name,
+		Module:     name,
+		SDKVersion: sdkVersion(),
+	}
+
+	switch lang {
+	case "go":
+	case "python":
+		data.Executable = "plugin.py"
+	default:
+		return fmt.Errorf("%w: unsupported language %q", errPluginNew, lang)
+	}
+
+	dir, err := fspath.NewAbs(name)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the plugin directory: %w", err)
+	}
+
+	if _, err = os.Stat(string(dir)); err == nil {
+		return fmt.Errorf("%w: %s already exists", errPluginNew, dir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to check the plugin directory: %w", err)
+	}
+
+	if err = writeScaffold(dir, lang, data); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "created plugin", "name", name, "lang", lang, "dir", dir)
+	terminal.Printf("Created %s plugin %s in %s\n", lang, name, dir)
+
+	if lang == "go" {
+		terminal.Printf("Build it with \"go mod tidy && go build\" in the plugin directory.\n")
+	}
+
+	terminal.Printf("Run it with \"reginald dev %s hello\".\n", name)
+
+	return nil
+}
+
+// writeScaffold writes the files from the templates for lang to dir.
+func writeScaffold(dir fspath.Path, lang string, data scaffoldData) error {
+	root := path.Join("templates", lang)
+
+	entries, err := scaffoldTemplates.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read the templates for %q: %w", lang, err)
+	}
+
+	if err = os.MkdirAll(string(dir), scaffoldDirPerm); err != nil {
+		return fmt.Errorf("failed to create the plugin directory: %w", err)
+	}
+
+	for _, e := range entries {
+		tmpl, err := template.ParseFS(scaffoldTemplates, path.Join(root, e.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to parse template %q: %w", e.Name(), err)
+		}
+
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to execute template %q: %w", e.Name(), err)
+		}
+
+		file := strings.TrimSuffix(e.Name(), ".tmpl")
+		perm := scaffoldFilePerm
+
+		if file == data.Executable {
+			perm = scaffoldExecPerm
+		}
+
+		if err = os.WriteFile(string(dir.Join(file)), buf.Bytes(), perm); err != nil {
+			return fmt.Errorf("failed to write %q: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// sdkVersion returns the version of the Go SDK that Reginald was built with or
+// an empty string if it cannot be determined.
+func sdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModule {
+			return dep.Version
+		}
+	}
+
+	return ""
+}
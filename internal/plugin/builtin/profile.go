@@ -0,0 +1,470 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+const (
+	profileName     = "reginald-shell" // name of the shell plugin
+	profileTaskType = "shell/profile"  // task type that manages the shell profiles
+	profileMarker   = "reginald"       // name in the markers of the managed sections
+)
+
+// errProfile is returned when a managed section cannot be written to a shell
+// profile.
+var errProfile = errors.New("cannot update shell profile")
+
+// envNamePattern matches the valid names of the environment variables.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// A profileSpec is the managed section that the "profile" task writes to
+// the shell profiles.
+type profileSpec struct {
+	env    map[string]string // environment variables to export
+	files  []fspath.Path     // shell profiles to write the section to
+	path   []fspath.Path     // directories to add to PATH
+	eval   []string          // commands whose output is evaluated
+	lines  []string          // other lines in the section
+	name   string            // name of the section, or empty for the default
+	remove bool              // whether the section is removed
+}
+
+// profileManifest returns the manifest for the shell plugin.
+func profileManifest() *api.Manifest {
+	return &api.Manifest{
+		Name:    profileName,
+		Version: version.Version().String(),
+		Domain:  "shell",
+		//nolint:lll
+		Description: "The \"reginald-shell\" plugin contains the tasks for managing the shell profiles with Reginald.",
+		Help:        "",
+		Executable:  "",
+		Runtime:     nil,
+		Config:      nil,
+		Commands:    nil,
+		Tasks: []api.Task{
+			{
+				TaskType:    "profile",
+				Description: "Manage a section of PATH entries and environment variables in shell profiles.",
+				Provides:    "",
+				RawConfig:   nil,
+				Config: []api.ConfigType{
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: []string{}, Type: api.PathListValue},
+							Key:   "files",
+						},
+						//nolint:lll
+						Description: "Shell profiles to write the managed section to. If omitted, the profile of the shell in SHELL is used: \"~/.zshrc\" for zsh, \"~/.bashrc\" for bash, \"~/.config/fish/config.fish\" for fish, and \"~/.profile\" for the other shells. The files ending in \".fish\" are written with the fish syntax.",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: "", Type: api.StringValue},
+							Key:   "name",
+						},
+						//nolint:lll
+						Description: "Name of the managed section. The section is delimited by the lines \"# BEGIN reginald <name>\" and \"# END reginald <name>\", so tasks with different names can manage separate sections in the same file. If omitted, the lines are \"# BEGIN reginald\" and \"# END reginald\".",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: []string{}, Type: api.PathListValue},
+							Key:   "path",
+						},
+						Description: "Directories to add to the beginning of PATH in the given order.",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: map[string]string{}, Type: plugin.StringMapValue},
+							Key:   "env",
+						},
+						//nolint:lll
+						Description: "Environment variables to export. The values are written in double quotes so that they may refer to other variables in the shell. As the variables in the config are expanded when the config is read, write \"$$\" to refer to a variable in the shell, e.g. \"$$HOME/go\".",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: []string{}, Type: api.StringListValue},
+							Key:   "eval",
+						},
+						//nolint:lll
+						Description: "Commands whose output is evaluated by the shell, e.g. \"brew shellenv\" for `eval \"$(brew shellenv)\"`.",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: []string{}, Type: api.StringListValue},
+							Key:   "lines",
+						},
+						Description: "Other lines to write to the managed section as they are.",
+					},
+					api.ConfigValue{
+						KeyVal: api.KeyVal{
+							Value: api.Value{Val: false, Type: api.BoolValue},
+							Key:   "remove",
+						},
+						//nolint:lll
+						Description: "Remove the managed section from the files instead of writing it. The section is also removed if it would be empty.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// profileService returns the service function for the "reginald-shell" plugin.
+func profileService(_ *config.Config) plugin.Service {
+	return func(ctx context.Context, _ *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunTask:
+			p, ok := params.(plugin.RunTaskParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunTaskParams", plugin.ErrInvalidCast)
+			}
+
+			if p.Elevate {
+				return nil, fmt.Errorf("%w: %s", errElevate, profileTaskType)
+			}
+
+			s, err := parseProfile(p.Config)
+			if err != nil {
+				return nil, err
+			}
+
+			var changes []plugin.FileChange
+
+			for _, file := range s.files {
+				c, err := writeProfile(ctx, s, file, p.DryRun, p.BackupDir)
+				if err != nil {
+					return nil, err
+				}
+
+				if c != nil {
+					changes = append(changes, *c)
+				}
+			}
+
+			return plugin.RunTaskResult{Changes: changes}, nil
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", profileName, method))
+		}
+	}
+}
+
+// writeProfile writes the managed section of s to the shell profile at path,
+// or removes it if s removes the section. It returns the change to the file,
+// or nil if the file is up to date. If the profile is a symbolic link, as it
+// often is in the dotfiles, the file that it points to is written instead so
+// that the link is kept.
+func writeProfile(
+	ctx context.Context,
+	s profileSpec,
+	path fspath.Path,
+	dryRun bool,
+	backupDir string,
+) (*plugin.FileChange, error) {
+	name := string(path)
+
+	if resolved, err := filepath.EvalSymlinks(name); err == nil {
+		name = resolved
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+
+	data, err := os.ReadFile(name)
+	exists := err == nil
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %q: %w", name, err)
+	}
+
+	block := ""
+	if !s.remove {
+		block = profileBlock(s, strings.HasSuffix(name, ".fish"))
+	}
+
+	old := string(data)
+
+	updated, err := updateSection(old, s.name, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %q: %w", name, err)
+	}
+
+	if updated == old {
+		slog.DebugContext(ctx, "shell profile is up to date", "path", name)
+
+		return nil, nil //nolint:nilnil // no change
+	}
+
+	change := &plugin.FileChange{Path: name, Old: old, New: updated}
+
+	if dryRun {
+		return change, nil
+	}
+
+	if exists && backupDir != "" {
+		backup, err := fsutil.Snapshot(name, backupDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %q: %w", name, err)
+		}
+
+		slog.InfoContext(ctx, "existing file saved to the run snapshot", "path", name, "backup", backup)
+	}
+
+	if _, err = fsutil.WriteFile(name, []byte(updated), 0o644, ""); err != nil { //nolint:mnd // default mode for profiles
+		return nil, fmt.Errorf("%w: failed to write %q: %w", errProfile, name, err)
+	}
+
+	slog.InfoContext(ctx, "shell profile updated", "path", name, "section", s.name, "removed", block == "")
+
+	return change, nil
+}
+
+// updateSection replaces the managed section with the given name in content
+// with block and returns the result. If content has no such section, block is
+// appended to it. If block is empty, the section is removed. If content uses
+// CRLF line endings, they are used for block too.
+func updateSection(content, name, block string) (string, error) {
+	begin, end := sectionMarkers(name)
+	lines := strings.SplitAfter(content, "\n")
+
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+		block = strings.ReplaceAll(block, "\n", newline)
+	}
+
+	start, stop := -1, -1
+
+	for i, line := range lines {
+		switch strings.TrimRight(line, "\r\n") {
+		case begin:
+			if start == -1 {
+				start = i
+			}
+		case end:
+			if start != -1 && stop == -1 {
+				stop = i
+			}
+		}
+	}
+
+	if start != -1 && stop == -1 {
+		return "", fmt.Errorf("%w: %q has no matching %q", errProfile, begin, end)
+	}
+
+	if start == -1 {
+		if block == "" {
+			return content, nil
+		}
+
+		var b strings.Builder
+
+		b.WriteString(content)
+
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			b.WriteString(newline)
+		}
+
+		// The section is separated from the previous content by an empty
+		// line.
+		if content != "" && !strings.HasSuffix(content, newline+newline) {
+			b.WriteString(newline)
+		}
+
+		b.WriteString(block)
+
+		return b.String(), nil
+	}
+
+	before, after := lines[:start], lines[stop+1:]
+
+	// When the section is removed, the empty line that separated it from
+	// the previous content is removed too if nothing follows it.
+	if block == "" && len(before) > 0 && strings.TrimSpace(before[len(before)-1]) == "" &&
+		strings.TrimSpace(strings.Join(after, "")) == "" {
+		before = before[:len(before)-1]
+	}
+
+	return strings.Join(before, "") + block + strings.Join(after, ""), nil
+}
+
+// profileBlock returns the managed section of s with the markers. The section
+// is empty if s has nothing to write. If fish is true, the section uses
+// the fish syntax instead of the POSIX shell syntax.
+func profileBlock(s profileSpec, fish bool) string {
+	var b strings.Builder
+
+	// The directories are prepended one by one, so they are written in
+	// the reverse order to keep the first one first in PATH.
+	for _, dir := range slices.Backward(s.path) {
+		if fish {
+			fmt.Fprintf(&b, "set -gx PATH %s $PATH\n", shellPath(dir))
+		} else {
+			fmt.Fprintf(&b, "export PATH=%s:\"$PATH\"\n", shellPath(dir))
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(s.env)) {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s.env[key])
+
+		if fish {
+			fmt.Fprintf(&b, "set -gx %s \"%s\"\n", key, value)
+		} else {
+			fmt.Fprintf(&b, "export %s=\"%s\"\n", key, value)
+		}
+	}
+
+	for _, cmd := range s.eval {
+		if fish {
+			fmt.Fprintf(&b, "%s | source\n", cmd)
+		} else {
+			fmt.Fprintf(&b, "eval \"$(%s)\"\n", cmd)
+		}
+	}
+
+	for _, line := range s.lines {
+		b.WriteString(line + "\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	begin, end := sectionMarkers(s.name)
+
+	return begin + "\n# Managed by Reginald, the changes to this section are overwritten.\n" + b.String() + end + "\n"
+}
+
+// sectionMarkers returns the lines that begin and end the managed section with
+// the given name.
+func sectionMarkers(name string) (string, string) {
+	if name == "" {
+		return "# BEGIN " + profileMarker, "# END " + profileMarker
+	}
+
+	return "# BEGIN " + profileMarker + " " + name, "# END " + profileMarker + " " + name
+}
+
+// defaultShellProfile returns the profile of the shell of the user.
+func defaultShellProfile() (fspath.Path, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "zsh":
+		return fspath.Join(fspath.Path(home), ".zshrc"), nil
+	case "bash":
+		return fspath.Join(fspath.Path(home), ".bashrc"), nil
+	case "fish":
+		return fspath.Join(fspath.Path(home), ".config", "fish", "config.fish"), nil
+	default:
+		return fspath.Join(fspath.Path(home), ".profile"), nil
+	}
+}
+
+// parseProfile parses the managed section from the config of the task.
+func parseProfile(cfg api.KeyValues) (profileSpec, error) {
+	s := profileSpec{env: nil, files: nil, path: nil, eval: nil, lines: nil, name: "", remove: false}
+
+	for _, key := range []string{"files", "path"} {
+		kv, ok := cfg.Get(key)
+		if !ok {
+			continue
+		}
+
+		paths, ok := kv.Val.([]fspath.Path)
+		if !ok {
+			return profileSpec{}, fmt.Errorf("%w: %q has invalid type %T", plugin.ErrInvalidCast, key, kv.Val)
+		}
+
+		if key == "files" {
+			s.files = paths
+		} else {
+			s.path = paths
+		}
+	}
+
+	if len(s.files) == 0 {
+		file, err := defaultShellProfile()
+		if err != nil {
+			return profileSpec{}, err
+		}
+
+		s.files = []fspath.Path{file}
+	}
+
+	var err error
+
+	if kv, ok := cfg.Get("eval"); ok {
+		if s.eval, err = kv.StringSlice(); err != nil {
+			return profileSpec{}, fmt.Errorf("failed to read \"eval\": %w", err)
+		}
+	}
+
+	if kv, ok := cfg.Get("lines"); ok {
+		if s.lines, err = kv.StringSlice(); err != nil {
+			return profileSpec{}, fmt.Errorf("failed to read \"lines\": %w", err)
+		}
+	}
+
+	if kv, ok := cfg.Get("name"); ok {
+		if s.name, err = kv.String(); err != nil {
+			return profileSpec{}, fmt.Errorf("failed to read \"name\": %w", err)
+		}
+
+		if strings.ContainsAny(s.name, "\r\n") {
+			return profileSpec{}, fmt.Errorf("%w: name %q contains a line break", errProfile, s.name)
+		}
+	}
+
+	if kv, ok := cfg.Get("remove"); ok {
+		if s.remove, err = kv.Bool(); err != nil {
+			return profileSpec{}, fmt.Errorf("failed to read \"remove\": %w", err)
+		}
+	}
+
+	if kv, ok := cfg.Get("env"); ok {
+		if s.env, ok = kv.Val.(map[string]string); !ok {
+			return profileSpec{}, fmt.Errorf("%w: \"env\" has invalid type %T", plugin.ErrInvalidCast, kv.Val)
+		}
+
+		for key := range s.env {
+			if !envNamePattern.MatchString(key) {
+				return profileSpec{}, fmt.Errorf("%w: invalid environment variable name %q", errProfile, key)
+			}
+		}
+	}
+
+	return s, nil
+}
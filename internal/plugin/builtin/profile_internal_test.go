@@ -0,0 +1,184 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+func TestUpdateSection(t *testing.T) {
+	t.Parallel()
+
+	const block = "# BEGIN reginald\nexport A=\"1\"\n# END reginald\n"
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		block   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Empty",
+			content: "",
+			block:   block,
+			want:    block,
+			wantErr: nil,
+		},
+		{
+			name:    "NoSection",
+			content: "alias ll='ls -l'\n",
+			block:   block,
+			want:    "alias ll='ls -l'\n\n" + block,
+			wantErr: nil,
+		},
+		{
+			name:    "NoTrailingNewline",
+			content: "alias ll='ls -l'",
+			block:   block,
+			want:    "alias ll='ls -l'\n\n" + block,
+			wantErr: nil,
+		},
+		{
+			name:    "Replace",
+			content: "before\n\n# BEGIN reginald\nexport OLD=\"0\"\n# END reginald\nafter\n",
+			block:   block,
+			want:    "before\n\n" + block + "after\n",
+			wantErr: nil,
+		},
+		{
+			name:    "Remove",
+			content: "before\n\n# BEGIN reginald\nexport OLD=\"0\"\n# END reginald\n",
+			block:   "",
+			want:    "before\n",
+			wantErr: nil,
+		},
+		{
+			name:    "RemoveMissing",
+			content: "before\n",
+			block:   "",
+			want:    "before\n",
+			wantErr: nil,
+		},
+		{
+			name:    "OtherSection",
+			content: "# BEGIN reginald other\nexport B=\"2\"\n# END reginald other\n",
+			block:   block,
+			want:    "# BEGIN reginald other\nexport B=\"2\"\n# END reginald other\n\n" + block,
+			wantErr: nil,
+		},
+		{
+			name:    "MissingEnd",
+			content: "before\n# BEGIN reginald\nexport OLD=\"0\"\nafter\n",
+			block:   block,
+			want:    "",
+			wantErr: errProfile,
+		},
+		{
+			name:    "CRLFReplace",
+			content: "before\r\n# BEGIN reginald\r\nexport OLD=\"0\"\r\n# END reginald\r\nafter\r\n",
+			block:   block,
+			want:    "before\r\n# BEGIN reginald\r\nexport A=\"1\"\r\n# END reginald\r\nafter\r\n",
+			wantErr: nil,
+		},
+		{
+			name:    "CRLFAppend",
+			content: "before\r\n",
+			block:   block,
+			want:    "before\r\n\r\n# BEGIN reginald\r\nexport A=\"1\"\r\n# END reginald\r\n",
+			wantErr: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := updateSection(tt.content, "", tt.block)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("updateSection() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("updateSection() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileBlock(t *testing.T) {
+	t.Parallel()
+
+	s := profileSpec{
+		env:    map[string]string{"EDITOR": "nvim", "QUOTED": `say "hi" \ bye`},
+		files:  nil,
+		path:   []fspath.Path{"/opt/a/bin", "/opt/b/bin"},
+		eval:   []string{"starship init"},
+		lines:  []string{"alias ll='ls -l'"},
+		name:   "tools",
+		remove: false,
+	}
+
+	for _, tt := range []struct {
+		name string
+		spec profileSpec
+		fish bool
+		want string
+	}{
+		{
+			name: "POSIX",
+			spec: s,
+			fish: false,
+			want: "# BEGIN reginald tools\n" +
+				"# Managed by Reginald, the changes to this section are overwritten.\n" +
+				"export PATH='/opt/b/bin':\"$PATH\"\n" +
+				"export PATH='/opt/a/bin':\"$PATH\"\n" +
+				"export EDITOR=\"nvim\"\n" +
+				"export QUOTED=\"say \\\"hi\\\" \\\\ bye\"\n" +
+				"eval \"$(starship init)\"\n" +
+				"alias ll='ls -l'\n" +
+				"# END reginald tools\n",
+		},
+		{
+			name: "Fish",
+			spec: s,
+			fish: true,
+			want: "# BEGIN reginald tools\n" +
+				"# Managed by Reginald, the changes to this section are overwritten.\n" +
+				"set -gx PATH '/opt/b/bin' $PATH\n" +
+				"set -gx PATH '/opt/a/bin' $PATH\n" +
+				"set -gx EDITOR \"nvim\"\n" +
+				"set -gx QUOTED \"say \\\"hi\\\" \\\\ bye\"\n" +
+				"starship init | source\n" +
+				"alias ll='ls -l'\n" +
+				"# END reginald tools\n",
+		},
+		{
+			name: "Empty",
+			spec: profileSpec{env: nil, files: nil, path: nil, eval: nil, lines: nil, name: "", remove: false},
+			fish: false,
+			want: "",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := profileBlock(tt.spec, tt.fish); got != tt.want {
+				t.Errorf("profileBlock() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"golang.org/x/term"
+)
+
+// remoteProgram is the name of the Reginald executable that is looked up from
+// the remote machine.
+const remoteProgram = "reginald"
+
+// remoteDotfiles is the directory on the remote machine that the dotfiles
+// directory is copied to, relative to the home directory of the remote user.
+// The directory is kept after the run as the links that the tasks create point
+// to the files in it.
+const remoteDotfiles = ".local/share/reginald/remote/dotfiles"
+
+// errRemote is returned when running the tasks on a remote machine fails.
+var errRemote = errors.New("remote run failed")
+
+// runRemote runs "attend" on the remote machine given as target over SSH. It
+// replaces the copy of the dotfiles directory on the machine with the current
+// dotfiles directory and the config file, and copies the current executable to
+// a temporary directory on the machine if Reginald is not installed there.
+// The output of the remote run is streamed to the terminal, and the temporary
+// directory is removed after the run.
+func runRemote(ctx context.Context, cfg *config.Config, target string) error {
+	if !cfg.HasFile() {
+		return fmt.Errorf("%w: no config file found", errRemote)
+	}
+
+	out, err := sshOutput(ctx, target, "mktemp", "-d")
+	if err != nil {
+		return fmt.Errorf("%w: failed to create a working directory on %s: %w", errRemote, target, err)
+	}
+
+	workDir := strings.TrimSpace(out)
+	if workDir == "" {
+		return fmt.Errorf("%w: failed to create a working directory on %s", errRemote, target)
+	}
+
+	slog.InfoContext(ctx, "created remote working directory", "target", target, "dir", workDir)
+
+	defer func() {
+		// The directory is removed even if the run was interrupted.
+		if err := sshRun(context.WithoutCancel(ctx), target, "rm", "-rf", workDir); err != nil {
+			slog.WarnContext(ctx, "failed to remove remote working directory", "target", target, "dir", workDir, "err", err)
+		}
+	}()
+
+	exe, err := remoteExecutable(ctx, target, workDir)
+	if err != nil {
+		return err
+	}
+
+	dir, err := remoteDir(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if err = scp(ctx, string(cfg.Directory), target, dir); err != nil {
+		return fmt.Errorf("%w: failed to copy the dotfiles directory to %s: %w", errRemote, target, err)
+	}
+
+	// The config file is usually within the dotfiles directory, and then it is
+	// used from the copied directory. Otherwise, it is copied separately.
+	var file string
+
+	rel, err := filepath.Rel(string(cfg.Directory), string(cfg.File()))
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		file = path.Join(dir, filepath.ToSlash(rel))
+	} else {
+		file = path.Join(dir, filepath.Base(string(cfg.File())))
+		if err = scp(ctx, string(cfg.File()), target, file); err != nil {
+			return fmt.Errorf("%w: failed to copy the config file to %s: %w", errRemote, target, err)
+		}
+	}
+
+	args := []string{exe, "--" + config.FlagName("Directory"), dir, "--config", file}
+	args = append(args, runFlags(cfg)...)
+	args = append(args, "attend")
+
+	slog.InfoContext(ctx, "running tasks on remote machine", "target", target, "args", args)
+
+	// The output of this process must be written before the remote process
+	// starts writing to the same terminal.
+	terminal.Flush()
+
+	sshArgs := []string{}
+
+	// A terminal is allocated on the remote machine so that the prompts and
+	// the colors work in the same way as in a local run.
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		sshArgs = append(sshArgs, "-t")
+	}
+
+	sshArgs = append(sshArgs, target, shellJoin(args))
+
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%w: running the tasks on %s failed: %w", errRemote, target, err)
+	}
+
+	return nil
+}
+
+// remoteDir removes the previous copy of the dotfiles directory on the remote
+// machine and returns the absolute path for the new copy.
+func remoteDir(ctx context.Context, target string) (string, error) {
+	// The parent directory is created so that the dotfiles directory can be
+	// copied as the new directory.
+	script := `rm -rf "$1" && mkdir -p "$(dirname "$1")" && cd "$(dirname "$1")" && pwd`
+
+	out, err := sshOutput(ctx, target, "sh", "-c", script, "sh", remoteDotfiles)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to prepare the dotfiles directory on %s: %w", errRemote, target, err)
+	}
+
+	parent := strings.TrimSpace(out)
+	if parent == "" {
+		return "", fmt.Errorf("%w: failed to prepare the dotfiles directory on %s", errRemote, target)
+	}
+
+	return path.Join(parent, path.Base(remoteDotfiles)), nil
+}
+
+// remoteExecutable returns the Reginald executable to run on the remote
+// machine. If Reginald is installed on the machine, it is used. Otherwise,
+// the current executable is copied to workDir if the remote machine has
+// the same operating system and architecture.
+func remoteExecutable(ctx context.Context, target, workDir string) (string, error) {
+	if _, err := sshOutput(ctx, target, "command", "-v", remoteProgram); err == nil {
+		slog.DebugContext(ctx, "using installed Reginald on remote machine", "target", target)
+
+		return remoteProgram, nil
+	}
+
+	out, err := sshOutput(ctx, target, "uname", "-sm")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to check the platform of %s: %w", errRemote, target, err)
+	}
+
+	goos, goarch := remotePlatform(out)
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return "", fmt.Errorf(
+			"%w: Reginald is not installed on %s and it cannot run this executable (%s/%s, need %s/%s)",
+			errRemote,
+			target,
+			goos,
+			goarch,
+			runtime.GOOS,
+			runtime.GOARCH,
+		)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the path to the executable: %w", err)
+	}
+
+	dst := path.Join(workDir, remoteProgram)
+	if err = scp(ctx, exe, target, dst); err != nil {
+		return "", fmt.Errorf("%w: failed to copy the executable to %s: %w", errRemote, target, err)
+	}
+
+	slog.DebugContext(ctx, "copied executable to remote machine", "target", target, "path", dst)
+
+	return dst, nil
+}
+
+// remotePlatform converts the output of "uname -sm" to the operating system and
+// the architecture names that Go uses.
+func remotePlatform(uname string) (string, string) {
+	fields := strings.Fields(uname)
+	if len(fields) != 2 { //nolint:mnd // system name and machine
+		return "unknown", "unknown"
+	}
+
+	goos := strings.ToLower(fields[0])
+	goarch := fields[1]
+
+	switch goarch {
+	case "x86_64", "amd64":
+		goarch = "amd64"
+	case "aarch64", "arm64":
+		goarch = "arm64"
+	case "i386", "i686":
+		goarch = "386"
+	}
+
+	return goos, goarch
+}
+
+// sshRun runs the command given as args on the remote machine.
+func sshRun(ctx context.Context, target string, args ...string) error {
+	_, err := sshOutput(ctx, target, args...)
+
+	return err
+}
+
+// sshOutput runs the command given as args on the remote machine and returns
+// its standard output.
+func sshOutput(ctx context.Context, target string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ssh", target, shellJoin(args))
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return string(out), nil
+}
+
+// scp copies the local file or directory at src to dst on the remote machine.
+func scp(ctx context.Context, src, target, dst string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "scp", "-q", "-r", "-p", src, target+":"+dst)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// shellJoin quotes the arguments for a POSIX shell and joins them into
+// a single command line. SSH passes the command to the shell of the remote
+// machine as a string so the arguments must be quoted.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s as a single argument for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// errRestore is returned when the "restore" command fails.
+var errRestore = errors.New("restore failed")
+
+// restoreCommand returns the manifest entry for the "restore" command.
+func restoreCommand() *api.Command {
+	return &api.Command{
+		Name:        "restore",
+		Usage:       "restore [<run-id>]",
+		Description: "Put back the files that a run replaced.",
+		//nolint:lll
+		Help:     "Restores the files that the tasks replaced or removed during the run with the given ID. The original files are saved to a snapshot of the run in the backup directory, and the ID of the run is printed after the run. The files that are at the original locations are backed up before they are replaced. Without a run ID, the command lists the runs that have snapshots.",
+		Manual:   "",
+		Aliases:  nil,
+		Config:   nil,
+		Commands: nil,
+		Args: &api.Arguments{
+			Spec: []api.ArgSpec{
+				{
+					Name:        "run-id",
+					Description: "ID of the run to restore the files of.",
+				},
+			},
+			Min: 0,
+			Max: 1,
+		},
+	}
+}
+
+// runRestore runs the "restore" command that puts back the files saved in
+// the snapshot of the given run, or lists the snapshots if no run is given.
+func runRestore(ctx context.Context, cfg *config.Config, p plugin.RunCommandParams) error {
+	if len(p.Args) == 0 {
+		return listSnapshots(cfg.BackupDir)
+	}
+
+	runID := p.Args[0]
+	dir := cfg.BackupDir.Join(runID)
+
+	entries, err := fsutil.ReadJournal(string(dir))
+	if errors.Is(err, fsutil.ErrNoSnapshot) {
+		return fmt.Errorf("%w: no saved files for run %q in %s", errRestore, runID, cfg.BackupDir)
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", errRestore, err)
+	}
+
+	if cfg.DryRun {
+		for _, e := range slices.Backward(entries) {
+			terminal.Printf("Would restore %s\n", e.Path)
+		}
+
+		return nil
+	}
+
+	unlock, err := lockRun(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	restored, err := fsutil.Restore(string(dir), string(cfg.BackupDir))
+
+	for _, path := range restored {
+		slog.InfoContext(ctx, "file restored", "path", path, "run", runID)
+		terminal.Printf("Restored %s\n", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", errRestore, err)
+	}
+
+	return nil
+}
+
+// listSnapshots prints the IDs of the runs that have snapshots in dir and
+// the number of files saved in them.
+func listSnapshots(dir fspath.Path) error {
+	dirEntries, err := os.ReadDir(string(dir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read the backup directory %q: %w", dir, err)
+	}
+
+	cols := []terminal.TableColumn{
+		{Header: "RUN", AlignRight: false},
+		{Header: "FILES", AlignRight: true},
+	}
+	rows := make([]terminal.TableRow, 0, len(dirEntries))
+
+	for _, d := range dirEntries {
+		if !d.IsDir() {
+			continue
+		}
+
+		// The directories without a journal are the backups that were not made
+		// during a run.
+		entries, err := fsutil.ReadJournal(string(dir.Join(d.Name())))
+		if errors.Is(err, fsutil.ErrNoSnapshot) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("%w: %w", errRestore, err)
+		}
+
+		rows = append(rows, terminal.TableRow{
+			Cells: []string{d.Name(), strconv.Itoa(len(entries))},
+			Style: terminal.RowPlain,
+		})
+	}
+
+	if len(rows) == 0 {
+		terminal.Println("No runs with saved files found")
+
+		return nil
+	}
+
+	terminal.PrintTable(cols, rows)
+
+	return nil
+}
+
+// printSnapshot tells the user how to restore the files that were saved to
+// the snapshot in dir during the run with the given ID. Nothing is printed if
+// no files were saved.
+func printSnapshot(dir fspath.Path, runID string) {
+	if _, err := os.Stat(string(dir.Join(fsutil.JournalName))); err != nil {
+		return
+	}
+
+	terminal.Printf("The replaced files were saved, restore them with \"reginald restore %s\"\n", runID)
+}
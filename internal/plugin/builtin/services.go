@@ -0,0 +1,596 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/fsutil"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/system"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+const (
+	servicesName     = "reginald-services" // name of the services plugin
+	servicesTaskType = "services/enable"   // task type that enables the services
+)
+
+// bootoutPollInterval is the interval for checking whether a launchd agent has
+// been unloaded after "launchctl bootout".
+const bootoutPollInterval = 100 * time.Millisecond
+
+// bootoutTimeout is the maximum time to wait for a launchd agent to be
+// unloaded after "launchctl bootout".
+const bootoutTimeout = 10 * time.Second
+
+// errService is returned when a service cannot be installed, enabled, or
+// started.
+var errService = errors.New("cannot enable service")
+
+// servicesManifest returns the manifest for the services plugin.
+func servicesManifest() *api.Manifest {
+	return &api.Manifest{
+		Name:    servicesName,
+		Version: version.Version().String(),
+		Domain:  "services",
+		//nolint:lll
+		Description: "The \"reginald-services\" plugin contains the tasks for managing the user services with systemd on Linux and launchd on macOS.",
+		Help:        "",
+		Executable:  "",
+		Runtime:     nil,
+		Config:      nil,
+		Commands:    nil,
+		Tasks: []api.Task{
+			{
+				TaskType:    "enable",
+				Description: "Install, enable, and start user services.",
+				Provides:    "",
+				RawConfig:   nil,
+				Config: []api.ConfigType{
+					//nolint:lll
+					api.MappedValue{
+						Key:         "services",
+						KeyType:     api.StringValue,
+						Description: "User services to enable where the key is the name of the service. The services are systemd user units on Linux and launchd agents on macOS. The values can be given separately for each operating system, e.g. `file = { linux = \"systemd/foo.service\", darwin = \"launchd/com.example.foo.plist\" }`.",
+						Values: []api.ConfigValue{
+							{
+								KeyVal: api.KeyVal{
+									Value: api.Value{Val: "", Type: api.PathValue},
+									Key:   "file",
+								},
+								Description: "The systemd unit file or the launchd property list to install to the user's service directory before the service is enabled. If omitted, the service must already be installed.",
+							},
+							{
+								KeyVal: api.KeyVal{
+									Value: api.Value{Val: "", Type: api.StringValue},
+									Key:   "name",
+								},
+								Description: "The name of the systemd unit or the label of the launchd agent. If omitted, it is resolved from the name of the installed file, or the key of the service is used.",
+							},
+							{
+								KeyVal: api.KeyVal{
+									Value: api.Value{Val: true, Type: api.BoolValue},
+									Key:   "start",
+								},
+								Description: "If enabled, the service is also started right away and restarted when its installed file changes. Otherwise, it is only enabled so that it starts on the next login.",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// A serviceSpec is a single service that the "enable" task enables.
+type serviceSpec struct {
+	file  fspath.Path // unit file or property list to install, empty if none
+	name  string      // name of the systemd unit or the label of the launchd agent
+	start bool        // whether to start the service
+}
+
+// A serviceState is the state of a service on the system.
+type serviceState struct {
+	enabled bool // whether the service is started on login
+	started bool // whether the service is running or loaded
+}
+
+// A serviceManager manages the user services with the service manager of
+// the operating system.
+type serviceManager interface {
+	// dir returns the directory where the files of the user services are
+	// installed.
+	dir() (string, error)
+
+	// name returns the name of the service that is installed from file.
+	name(file string) string
+
+	// file returns the name of the installed file of the named service.
+	file(name string) string
+
+	// state returns the current state of the service.
+	state(ctx context.Context, s serviceSpec) (serviceState, error)
+
+	// apply changes the state of the service from the old to the new state.
+	// If reload is true, the installed file of the service has changed.
+	apply(ctx context.Context, s serviceSpec, path string, old, state serviceState, reload bool) error
+}
+
+// servicesService returns the service function for the "reginald-services"
+// plugin.
+func servicesService(_ *config.Config) plugin.Service {
+	return func(ctx context.Context, _ *plugin.Store, method string, params any) (any, error) {
+		switch method {
+		case api.MethodRunTask:
+			p, ok := params.(plugin.RunTaskParams)
+			if !ok {
+				return nil, fmt.Errorf("%w: params are not RunTaskParams", plugin.ErrInvalidCast)
+			}
+
+			if p.Elevate {
+				return nil, fmt.Errorf("%w: %s", errElevate, servicesTaskType)
+			}
+
+			var m serviceManager
+
+			switch runtime.GOOS {
+			case system.Linux:
+				m = systemd{}
+			case "darwin":
+				m = launchd{}
+			default:
+				return nil, fmt.Errorf(
+					"%w: task type %q is only supported on Linux and macOS, limit the task with \"platforms\"",
+					errService,
+					servicesTaskType,
+				)
+			}
+
+			specs, err := parseServices(p.Config, m)
+			if err != nil {
+				return nil, err
+			}
+
+			var changes []plugin.FileChange
+
+			for _, s := range specs {
+				c, err := enableService(ctx, m, s, p.DryRun, p.BackupDir)
+				if err != nil {
+					return nil, err
+				}
+
+				changes = append(changes, c...)
+			}
+
+			return plugin.RunTaskResult{Changes: changes}, nil
+		default:
+			panic(fmt.Sprintf("invalid method call to %q: %s", servicesName, method))
+		}
+	}
+}
+
+// enableService installs the file of the service if it has changed and
+// enables and starts the service if it is not already enabled and started.
+// The changes are returned as file changes: the installed file with its
+// contents and the state of the service as lines of "key = value" so that
+// the drift can be shown as a diff in dry-run mode.
+func enableService(
+	ctx context.Context,
+	m serviceManager,
+	s serviceSpec,
+	dryRun bool,
+	backupDir string,
+) ([]plugin.FileChange, error) {
+	var changes []plugin.FileChange
+
+	dir, err := m.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, m.file(s.name))
+	if s.file != "" {
+		path = filepath.Join(dir, filepath.Base(string(s.file)))
+	}
+
+	reload := false
+
+	if s.file != "" {
+		var c *plugin.FileChange
+
+		if c, err = installServiceFile(ctx, s, path, dryRun, backupDir); err != nil {
+			return nil, err
+		}
+
+		if c != nil {
+			changes = append(changes, *c)
+			reload = true
+		}
+	}
+
+	old, err := m.state(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	state := serviceState{enabled: true, started: old.started || s.start}
+
+	if old != state {
+		changes = append(changes, plugin.FileChange{
+			Path: "service " + s.name,
+			Old:  old.String(),
+			New:  state.String(),
+		})
+	}
+
+	if dryRun || (old == state && !reload) {
+		return changes, nil
+	}
+
+	if err = m.apply(ctx, s, path, old, state, reload); err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", errService, s.name, err)
+	}
+
+	slog.InfoContext(ctx, "service enabled", "service", s.name, "started", state.started, "reloaded", reload)
+
+	return changes, nil
+}
+
+// installServiceFile copies the file of the service to path if its contents
+// differ from the installed file. The replaced file is saved to the snapshot of
+// the run in backupDir. It returns the change to the installed file or nil if
+// the file is up to date.
+func installServiceFile(
+	ctx context.Context,
+	s serviceSpec,
+	path string,
+	dryRun bool,
+	backupDir string,
+) (*plugin.FileChange, error) {
+	data, err := os.ReadFile(string(s.file))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the file of %q: %w", errService, s.name, err)
+	}
+
+	old, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: failed to read %q: %w", errService, path, err)
+	}
+
+	if err == nil && bytes.Equal(old, data) {
+		slog.DebugContext(ctx, "service file already installed", "service", s.name, "path", path)
+
+		return nil, nil
+	}
+
+	change := &plugin.FileChange{Path: path, Old: string(old), New: string(data)}
+
+	if dryRun {
+		return change, nil
+	}
+
+	if len(old) > 0 && backupDir != "" {
+		var backup string
+
+		if backup, err = fsutil.Snapshot(path, backupDir); err != nil {
+			return nil, fmt.Errorf("failed to back up %q: %w", path, err)
+		}
+
+		slog.InfoContext(ctx, "existing file saved to the run snapshot", "path", path, "backup", backup)
+	}
+
+	if _, err = fsutil.WriteFile(path, data, fsutil.DefaultFilePerm, ""); err != nil {
+		return nil, fmt.Errorf("%w: failed to install %q: %w", errService, path, err)
+	}
+
+	slog.InfoContext(ctx, "service file installed", "service", s.name, "path", path)
+
+	return change, nil
+}
+
+// parseServices parses the services to enable from the config of the task.
+// The services are sorted by their names.
+func parseServices(cfg api.KeyValues, m serviceManager) ([]serviceSpec, error) {
+	kv, ok := cfg.Get("services")
+	if !ok {
+		return nil, nil
+	}
+
+	entries, err := kv.Configs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read \"services\": %w", err)
+	}
+
+	specs := make([]serviceSpec, 0, len(entries))
+
+	for _, entry := range entries {
+		values, err := entry.Configs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service %q: %w", entry.Key, err)
+		}
+
+		s := serviceSpec{file: "", name: "", start: true}
+
+		if kv, ok := values.Get("file"); ok {
+			if s.file, ok = kv.Val.(fspath.Path); !ok {
+				return nil, fmt.Errorf("%w: \"file\" for %q has invalid type %T", plugin.ErrInvalidCast, entry.Key, kv.Val)
+			}
+		}
+
+		if kv, ok := values.Get("name"); ok {
+			if s.name, err = kv.String(); err != nil {
+				return nil, fmt.Errorf("failed to read \"name\" for %q: %w", entry.Key, err)
+			}
+		}
+
+		if kv, ok := values.Get("start"); ok {
+			if s.start, err = kv.Bool(); err != nil {
+				return nil, fmt.Errorf("failed to read \"start\" for %q: %w", entry.Key, err)
+			}
+		}
+
+		if s.name == "" && s.file != "" {
+			s.name = m.name(string(s.file))
+		}
+
+		if s.name == "" {
+			s.name = entry.Key
+		}
+
+		specs = append(specs, s)
+	}
+
+	slices.SortFunc(specs, func(a, b serviceSpec) int { return strings.Compare(a.name, b.name) })
+
+	return specs, nil
+}
+
+// String returns the state as lines of "key = value" for showing the changes
+// to the user.
+func (s serviceState) String() string {
+	return fmt.Sprintf("enabled = %t\nstarted = %t\n", s.enabled, s.started)
+}
+
+// systemd manages the user services as systemd user units.
+type systemd struct{}
+
+func (systemd) dir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+func (systemd) name(file string) string {
+	return filepath.Base(file)
+}
+
+func (systemd) file(name string) string {
+	return name
+}
+
+func (systemd) state(ctx context.Context, s serviceSpec) (serviceState, error) {
+	// The commands exit with a non-zero status if the unit is not enabled or
+	// active, so only the output is checked.
+	enabled, err := systemctlOutput(ctx, "is-enabled", s.name)
+	if err != nil {
+		return serviceState{}, err
+	}
+
+	active, err := systemctlOutput(ctx, "is-active", s.name)
+	if err != nil {
+		return serviceState{}, err
+	}
+
+	return serviceState{
+		// The static units cannot be enabled as they are started by other
+		// units.
+		enabled: enabled == "enabled" || enabled == "static",
+		started: active == "active",
+	}, nil
+}
+
+func (systemd) apply(ctx context.Context, s serviceSpec, _ string, old, state serviceState, reload bool) error {
+	if reload {
+		if err := systemctl(ctx, "daemon-reload"); err != nil {
+			return err
+		}
+	}
+
+	if !old.enabled {
+		if err := systemctl(ctx, "enable", s.name); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case state.started && !old.started:
+		return systemctl(ctx, "start", s.name)
+	case state.started && reload:
+		return systemctl(ctx, "restart", s.name)
+	default:
+		return nil
+	}
+}
+
+// systemctl runs "systemctl --user" with the given arguments.
+func systemctl(ctx context.Context, args ...string) error {
+	return runServiceCommand(ctx, "systemctl", append([]string{"--user"}, args...)...)
+}
+
+// systemctlOutput runs "systemctl --user" with the given arguments and returns
+// its trimmed standard output regardless of the exit status. It only fails if
+// systemctl cannot be run.
+func systemctlOutput(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "systemctl", append([]string{"--user"}, args...)...).Output()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("%w: failed to run systemctl: %w", errService, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// launchd manages the user services as launchd agents.
+type launchd struct{}
+
+func (launchd) dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user home directory: %w", err)
+	}
+
+	return filepath.Join(home, "Library", "LaunchAgents"), nil
+}
+
+func (launchd) name(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".plist")
+}
+
+func (launchd) file(name string) string {
+	return name + ".plist"
+}
+
+func (launchd) state(ctx context.Context, s serviceSpec) (serviceState, error) {
+	domain := launchdDomain()
+
+	out, err := exec.CommandContext(ctx, "launchctl", "print-disabled", domain).Output()
+	if err != nil {
+		return serviceState{}, fmt.Errorf("%w: failed to run launchctl: %w", errService, err)
+	}
+
+	state := serviceState{enabled: true, started: false}
+	prefix := strconv.Quote(s.name) + " =>"
+
+	// The output lists the overrides of the services as lines like
+	// `"com.example.foo" => disabled`. Older versions of macOS print "true"
+	// instead of "disabled".
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if v, ok := strings.CutPrefix(line, prefix); ok {
+			v = strings.TrimSpace(v)
+			state.enabled = v != "disabled" && v != "true"
+		}
+	}
+
+	// The agent is loaded if launchd knows about it.
+	state.started = exec.CommandContext(ctx, "launchctl", "print", domain+"/"+s.name).Run() == nil
+
+	return state, nil
+}
+
+func (launchd) apply(ctx context.Context, s serviceSpec, path string, old, state serviceState, reload bool) error {
+	domain := launchdDomain()
+	target := domain + "/" + s.name
+
+	if !old.enabled {
+		if err := runServiceCommand(ctx, "launchctl", "enable", target); err != nil {
+			return err
+		}
+	}
+
+	if !state.started {
+		return nil
+	}
+
+	// The agent must be unloaded before the changed property list can be
+	// loaded again.
+	if old.started && reload {
+		if err := runServiceCommand(ctx, "launchctl", "bootout", target); err != nil {
+			return err
+		}
+
+		if err := waitBootout(ctx, target); err != nil {
+			return err
+		}
+	} else if old.started {
+		return nil
+	}
+
+	return runServiceCommand(ctx, "launchctl", "bootstrap", domain, path)
+}
+
+// waitBootout waits until launchd no longer knows about the agent target.
+// The agent is unloaded asynchronously after "launchctl bootout" returns, and
+// bootstrapping it again before that fails.
+func waitBootout(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, bootoutTimeout)
+	defer cancel()
+
+	for {
+		if err := exec.CommandContext(ctx, "launchctl", "print", target).Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("failed to wait for %s to be unloaded: %w", target, ctx.Err())
+			}
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for %s to be unloaded: %w", target, ctx.Err())
+		case <-time.After(bootoutPollInterval):
+		}
+	}
+}
+
+// launchdDomain returns the launchd domain of the user services of the current
+// user.
+func launchdDomain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+// runServiceCommand runs the named program with the given arguments.
+func runServiceCommand(ctx context.Context, name string, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, msg)
+		}
+
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
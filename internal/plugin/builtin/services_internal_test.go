@@ -0,0 +1,378 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+var errFakeManager = errors.New("fake service manager failed")
+
+// fakeManager is a serviceManager that keeps the state of the services in
+// memory and records the calls to apply.
+type fakeManager struct {
+	states   map[string]serviceState
+	applied  []fakeApply
+	root     string
+	stateErr error
+	applyErr error
+}
+
+// fakeApply is a recorded call to fakeManager.apply.
+type fakeApply struct {
+	name   string
+	path   string
+	old    serviceState
+	state  serviceState
+	reload bool
+}
+
+func (m *fakeManager) dir() (string, error) {
+	return m.root, nil
+}
+
+func (*fakeManager) name(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".unit")
+}
+
+func (*fakeManager) file(name string) string {
+	return name + ".unit"
+}
+
+func (m *fakeManager) state(_ context.Context, s serviceSpec) (serviceState, error) {
+	return m.states[s.name], m.stateErr
+}
+
+func (m *fakeManager) apply(_ context.Context, s serviceSpec, path string, old, state serviceState, reload bool) error {
+	m.applied = append(m.applied, fakeApply{name: s.name, path: path, old: old, state: state, reload: reload})
+
+	if m.applyErr != nil {
+		return m.applyErr
+	}
+
+	m.states[s.name] = state
+
+	return nil
+}
+
+func TestEnableService(t *testing.T) {
+	t.Parallel()
+
+	stopped := serviceState{enabled: false, started: false}
+	enabled := serviceState{enabled: true, started: false}
+	running := serviceState{enabled: true, started: true}
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name      string
+		old       serviceState
+		start     bool
+		installed string // contents of the installed file, empty if none
+		file      string // contents of the file to install, empty if none
+		dryRun    bool
+		applyErr  error
+		changes   []string // paths of the changes, relative to the directory
+		applied   []fakeApply
+		wantErr   error
+	}{
+		{
+			name:      "Enable",
+			old:       stopped,
+			start:     true,
+			installed: "",
+			file:      "",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   []string{"service foo"},
+			applied:   []fakeApply{{name: "foo", path: "foo.unit", old: stopped, state: running, reload: false}},
+			wantErr:   nil,
+		},
+		{
+			name:      "EnableWithoutStart",
+			old:       stopped,
+			start:     false,
+			installed: "",
+			file:      "",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   []string{"service foo"},
+			applied:   []fakeApply{{name: "foo", path: "foo.unit", old: stopped, state: enabled, reload: false}},
+			wantErr:   nil,
+		},
+		{
+			name:      "KeepStarted",
+			old:       serviceState{enabled: false, started: true},
+			start:     false,
+			installed: "",
+			file:      "",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   []string{"service foo"},
+			applied: []fakeApply{
+				{name: "foo", path: "foo.unit", old: serviceState{enabled: false, started: true}, state: running, reload: false},
+			},
+			wantErr: nil,
+		},
+		{
+			name:      "UpToDate",
+			old:       running,
+			start:     true,
+			installed: "unit",
+			file:      "unit",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   nil,
+			applied:   nil,
+			wantErr:   nil,
+		},
+		{
+			name:      "Install",
+			old:       stopped,
+			start:     true,
+			installed: "",
+			file:      "unit",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   []string{"foo.unit", "service foo"},
+			applied:   []fakeApply{{name: "foo", path: "foo.unit", old: stopped, state: running, reload: true}},
+			wantErr:   nil,
+		},
+		{
+			name:      "Reload",
+			old:       running,
+			start:     true,
+			installed: "old unit",
+			file:      "new unit",
+			dryRun:    false,
+			applyErr:  nil,
+			changes:   []string{"foo.unit"},
+			applied:   []fakeApply{{name: "foo", path: "foo.unit", old: running, state: running, reload: true}},
+			wantErr:   nil,
+		},
+		{
+			name:      "DryRun",
+			old:       stopped,
+			start:     true,
+			installed: "old unit",
+			file:      "new unit",
+			dryRun:    true,
+			applyErr:  nil,
+			changes:   []string{"foo.unit", "service foo"},
+			applied:   nil,
+			wantErr:   nil,
+		},
+		{
+			name:      "ApplyError",
+			old:       stopped,
+			start:     true,
+			installed: "",
+			file:      "",
+			dryRun:    false,
+			applyErr:  errFakeManager,
+			changes:   nil,
+			applied:   []fakeApply{{name: "foo", path: "foo.unit", old: stopped, state: running, reload: false}},
+			wantErr:   errService,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			m := &fakeManager{
+				states:   map[string]serviceState{"foo": tt.old},
+				applied:  nil,
+				root:     filepath.Join(dir, "services"),
+				stateErr: nil,
+				applyErr: tt.applyErr,
+			}
+			s := serviceSpec{file: "", name: "foo", start: tt.start}
+
+			if tt.file != "" {
+				s.file = fspath.Path(filepath.Join(dir, "foo.unit"))
+
+				if err := os.WriteFile(string(s.file), []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.installed != "" {
+				if err := os.MkdirAll(m.root, 0o700); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.WriteFile(filepath.Join(m.root, "foo.unit"), []byte(tt.installed), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changes, err := enableService(t.Context(), m, s, tt.dryRun, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("enableService() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var paths []string
+			for _, c := range changes {
+				paths = append(paths, strings.TrimPrefix(c.Path, m.root+string(filepath.Separator)))
+			}
+
+			if !reflect.DeepEqual(paths, tt.changes) {
+				t.Errorf("enableService() changes = %q, want %q", paths, tt.changes)
+			}
+
+			for i := range tt.applied {
+				tt.applied[i].path = filepath.Join(m.root, tt.applied[i].path)
+			}
+
+			if !reflect.DeepEqual(m.applied, tt.applied) {
+				t.Errorf("apply calls = %+v, want %+v", m.applied, tt.applied)
+			}
+
+			// The file is only installed outside of the dry runs.
+			data, err := os.ReadFile(filepath.Join(m.root, "foo.unit"))
+
+			switch {
+			case tt.file == "" || tt.dryRun:
+				if tt.installed == "" && err == nil {
+					t.Errorf("service file was installed, want no file")
+				}
+			case err != nil || string(data) != tt.file:
+				t.Errorf("installed file = %q, %v, want %q", data, err, tt.file)
+			}
+		})
+	}
+}
+
+func TestEnableService_StateError(t *testing.T) {
+	t.Parallel()
+
+	m := &fakeManager{
+		states:   map[string]serviceState{},
+		applied:  nil,
+		root:     t.TempDir(),
+		stateErr: errFakeManager,
+		applyErr: nil,
+	}
+
+	_, err := enableService(t.Context(), m, serviceSpec{file: "", name: "foo", start: true}, false, "")
+	if !errors.Is(err, errFakeManager) {
+		t.Errorf("enableService() error = %v, want %v", err, errFakeManager)
+	}
+
+	if len(m.applied) > 0 {
+		t.Errorf("apply calls = %+v, want none", m.applied)
+	}
+}
+
+func TestParseServices(t *testing.T) {
+	t.Parallel()
+
+	service := func(key string, values ...api.KeyVal) api.KeyVal {
+		return api.KeyVal{Value: api.Value{Val: api.KeyValues(values), Type: api.ConfigSliceValue}, Key: key}
+	}
+	file := func(p string) api.KeyVal {
+		return api.KeyVal{Value: api.Value{Val: fspath.Path(p), Type: api.PathValue}, Key: "file"}
+	}
+	name := func(s string) api.KeyVal {
+		return api.KeyVal{Value: api.Value{Val: s, Type: api.StringValue}, Key: "name"}
+	}
+	start := func(b bool) api.KeyVal {
+		return api.KeyVal{Value: api.Value{Val: b, Type: api.BoolValue}, Key: "start"}
+	}
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name     string
+		services []api.KeyVal
+		want     []serviceSpec
+		wantErr  error
+	}{
+		{
+			name:     "Empty",
+			services: nil,
+			want:     []serviceSpec{},
+			wantErr:  nil,
+		},
+		{
+			name: "Defaults",
+			services: []api.KeyVal{
+				service("syncthing"),
+				service("docker", start(false)),
+			},
+			want: []serviceSpec{
+				{file: "", name: "docker", start: false},
+				{file: "", name: "syncthing", start: true},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "NameFromFile",
+			services: []api.KeyVal{
+				service("agent", file("/dots/com.example.agent.unit")),
+				service("other", file("/dots/other.unit"), name("custom")),
+			},
+			want: []serviceSpec{
+				{file: "/dots/com.example.agent.unit", name: "com.example.agent", start: true},
+				{file: "/dots/other.unit", name: "custom", start: true},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "InvalidFile",
+			services: []api.KeyVal{
+				service("agent", api.KeyVal{Value: api.Value{Val: 1, Type: api.IntValue}, Key: "file"}),
+			},
+			want:    nil,
+			wantErr: plugin.ErrInvalidCast,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := api.KeyValues{
+				{Value: api.Value{Val: api.KeyValues(tt.services), Type: api.ConfigSliceValue}, Key: "services"},
+			}
+
+			got, err := parseServices(cfg, &fakeManager{}) //nolint:exhaustruct // only the names are used
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseServices() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseServices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseServices_Missing(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseServices(api.KeyValues{}, &fakeManager{}) //nolint:exhaustruct // only the names are used
+	if err != nil || got != nil {
+		t.Errorf("parseServices() = %+v, %v, want nil, nil", got, err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// A runSummary collects the outcomes of the tasks during a run so that they can
+// be printed as a table after the run.
+type runSummary struct {
+	// events contains the latest event of each task in the order in which
+	// the tasks were first seen.
+	events []plugin.TaskEvent
+}
+
+// add records the given task event as the latest event of its task.
+func (s *runSummary) add(e plugin.TaskEvent) {
+	i := slices.IndexFunc(s.events, func(old plugin.TaskEvent) bool { return old.ID == e.ID })
+	if i == -1 {
+		s.events = append(s.events, e)
+
+		return
+	}
+
+	s.events[i] = e
+}
+
+// print prints the summary table of the tasks that finished during the run.
+// The tasks are sorted by their execution stages.
+func (s *runSummary) print() {
+	events := slices.DeleteFunc(slices.Clone(s.events), func(e plugin.TaskEvent) bool {
+		return e.Status == plugin.TaskStarted
+	})
+	if len(events) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(events, func(a, b plugin.TaskEvent) int { return cmp.Compare(a.Stage, b.Stage) })
+
+	cols := []terminal.TableColumn{
+		{Header: "TASK", AlignRight: false},
+		{Header: "TYPE", AlignRight: false},
+		{Header: "RESULT", AlignRight: false},
+		{Header: "DURATION", AlignRight: true},
+		{Header: "CHANGES", AlignRight: true},
+	}
+	rows := make([]terminal.TableRow, 0, len(events))
+
+	for _, e := range events {
+		duration := "-"
+
+		switch {
+		case e.Duration >= time.Millisecond:
+			duration = e.Duration.Round(time.Millisecond).String()
+		case e.Duration > 0:
+			duration = e.Duration.Round(time.Microsecond).String()
+		}
+
+		changes := "-"
+		if e.Status == plugin.TaskSucceeded {
+			changes = strconv.Itoa(e.Changes)
+		}
+
+		taskType := e.TaskType
+		if e.Elevated {
+			taskType += " (elevated)"
+		}
+
+		style := terminal.RowPlain
+
+		switch e.Status { //nolint:exhaustive // the started tasks are filtered out
+		case plugin.TaskSucceeded:
+			style = terminal.RowSuccess
+		case plugin.TaskSkipped, plugin.TaskUnknown:
+			style = terminal.RowWarning
+		case plugin.TaskFailed:
+			style = terminal.RowError
+		}
+
+		rows = append(rows, terminal.TableRow{
+			Cells: []string{e.ID, taskType, e.Status.String(), duration, changes},
+			Style: style,
+		})
+	}
+
+	terminal.Println()
+	terminal.PrintTable(cols, rows)
+}
@@ -0,0 +1,370 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/config"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/plugin"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// defaultWatchDebounce is the default time in milliseconds that the "watch"
+// command waits after a change before running the tasks.
+const defaultWatchDebounce = 500
+
+// errWatch is returned when the "watch" command fails.
+var errWatch = errors.New("watch failed")
+
+// watchIgnoreSuffixes contains the suffixes of the temporary files created by
+// the editors. The changes to them are ignored.
+//
+//nolint:gochecknoglobals // used like a constant
+var watchIgnoreSuffixes = []string{"~", ".swp", ".swx", ".tmp"}
+
+// A taskChange contains the changed files that affect a task instance.
+type taskChange struct {
+	cfg   *plugin.TaskConfig // config of the affected task
+	files []fspath.Path      // changed files that affect the task
+}
+
+// watchCommand returns the manifest entry for the "watch" command.
+func watchCommand() *api.Command {
+	return &api.Command{
+		Name:        "watch",
+		Usage:       "watch [options]",
+		Description: "Execute the tasks again when the files change.",
+		//nolint:lll
+		Help:    "Executes the tasks and watches the config file and the dotfiles directory for changes. When files in the dotfiles directory change, the tasks that use the changed files in their config are executed again. When the config file changes, the config is parsed again and all of the tasks are executed. The changes are collected until no new changes are detected for the debounce time. The command runs until it is interrupted.",
+		Manual:  "",
+		Aliases: nil,
+		Config: []api.ConfigEntry{
+			{
+				ConfigValue: api.ConfigValue{
+					KeyVal: api.KeyVal{
+						Value: api.Value{Val: defaultWatchDebounce, Type: api.IntValue},
+						Key:   "debounce",
+					},
+					Description: "Time in milliseconds to wait after a change before executing the tasks.",
+				},
+				Flag: &api.Flag{
+					Name:        "debounce",
+					Shorthand:   "",
+					Description: "wait for `<ms>` milliseconds without changes before executing the tasks",
+					Manual:      "",
+				},
+				EnvOverride: "",
+				FlagOnly:    false,
+			},
+		},
+		Commands: nil,
+		Args:     nil,
+	}
+}
+
+// runWatch runs the "watch" command that executes the tasks and executes them
+// again when the files they use change.
+func runWatch(ctx context.Context, store *plugin.Store, cfg *config.Config, p plugin.RunCommandParams) error {
+	debounce := defaultWatchDebounce
+
+	if kv, ok := p.Config.Get("debounce"); ok {
+		var err error
+
+		if debounce, err = kv.Int(); err != nil {
+			return fmt.Errorf("failed to read \"debounce\": %w", err)
+		}
+	}
+
+	if debounce <= 0 {
+		return fmt.Errorf("%w: debounce must be positive, got %d", errWatch, debounce)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close() //nolint:errcheck // no need to check
+
+	if err = watchDir(watcher, cfg.Directory); err != nil {
+		return err
+	}
+
+	// The directory of the config file is watched instead of the file itself
+	// as many editors replace the file when saving it.
+	if file := cfg.File(); cfg.HasFile() && !pathWithin(file, cfg.Directory) {
+		if err = watcher.Add(string(file.Dir())); err != nil {
+			return fmt.Errorf("failed to watch %q: %w", file.Dir(), err)
+		}
+	}
+
+	if err = rerunTasks(ctx, store, cfg, nil); err != nil {
+		return err
+	}
+
+	changes := make(map[fspath.Path]struct{})
+
+	var timer <-chan time.Time
+
+	terminal.Progressf("Watching %s for changes\n", cfg.Directory)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			path, relevant := watchEvent(ctx, watcher, cfg, event)
+			if !relevant {
+				continue
+			}
+
+			changes[path] = struct{}{}
+			timer = time.After(time.Duration(debounce) * time.Millisecond)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			slog.WarnContext(ctx, "file watcher error", "err", err)
+			diag.Report(ctx, diag.WatchError, fmt.Sprintf("Error while watching the files: %v", err))
+		case <-timer:
+			changed := make([]fspath.Path, 0, len(changes))
+
+			for path := range changes {
+				changed = append(changed, path)
+			}
+
+			slices.Sort(changed)
+			clear(changes)
+
+			timer = nil
+
+			if err = handleChanges(ctx, store, cfg, changed); err != nil {
+				return err
+			}
+
+			// The tasks may change the watched files themselves so the events
+			// from the run are discarded to avoid running the tasks in a loop.
+			drainEvents(watcher)
+			terminal.Progressf("Watching %s for changes\n", cfg.Directory)
+		}
+	}
+}
+
+// handleChanges runs the tasks that are affected by the changed files. If
+// the config file has changed, it returns [plugin.ErrReload] so that the config
+// is parsed again.
+func handleChanges(ctx context.Context, store *plugin.Store, cfg *config.Config, changed []fspath.Path) error {
+	slog.DebugContext(ctx, "files changed", "files", changed)
+
+	if cfg.HasFile() && slices.Contains(changed, cfg.File()) {
+		terminal.Printf("Config file %s changed, reloading the config\n", cfg.File())
+
+		return fmt.Errorf("%w", plugin.ErrReload)
+	}
+
+	affected := affectedTasks(store.TaskConfigs(), changed)
+	if len(affected) == 0 {
+		terminal.Printf("%s changed, no tasks affected\n", joinRel(changed, cfg.Directory))
+
+		return nil
+	}
+
+	terminal.Printf("Detected changes, executing %d tasks:\n", len(affected))
+
+	ids := make([]string, len(affected))
+
+	for i, c := range affected {
+		ids[i] = c.cfg.ID
+
+		terminal.Printf("  %s: %s\n", c.cfg.ID, joinRel(c.files, cfg.Directory))
+	}
+
+	return rerunTasks(ctx, store, cfg, ids)
+}
+
+// rerunTasks runs the tasks with the given IDs or all of the tasks if ids is
+// empty. The failures of the tasks are reported but they do not stop
+// the command so that the user can fix the files and try again.
+func rerunTasks(ctx context.Context, store *plugin.Store, cfg *config.Config, ids []string) error {
+	err := runTasks(ctx, store, cfg, ids)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, plugin.ErrQuit):
+		slog.InfoContext(ctx, "user quit the run")
+		terminal.Println("Quitting")
+
+		return nil
+	case ctx.Err() != nil:
+		return nil
+	default:
+		slog.WarnContext(ctx, "task run failed while watching", "err", err)
+		terminal.Errorf("Error: %v\n", err)
+
+		return nil
+	}
+}
+
+// affectedTasks returns the tasks that use the changed files in their config
+// in the order of the task configs. A task is affected if one of its paths is
+// a changed file or within a changed directory, or if a changed file is within
+// one of its paths.
+func affectedTasks(cfgs iter.Seq[plugin.TaskConfig], changed []fspath.Path) []taskChange {
+	var affected []taskChange
+
+	for tcfg := range cfgs {
+		var files []fspath.Path
+
+		paths := tcfg.Paths()
+
+		for _, c := range changed {
+			if slices.ContainsFunc(paths, func(p fspath.Path) bool { return pathWithin(c, p) || pathWithin(p, c) }) {
+				files = append(files, c)
+			}
+		}
+
+		if len(files) > 0 {
+			affected = append(affected, taskChange{cfg: &tcfg, files: files})
+		}
+	}
+
+	return affected
+}
+
+// drainEvents discards the events that are waiting in the channels of watcher.
+func drainEvents(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-watcher.Events:
+		case <-watcher.Errors:
+		default:
+			return
+		}
+	}
+}
+
+// joinRel returns the given paths relative to dir as a comma-separated list.
+func joinRel(paths []fspath.Path, dir fspath.Path) string {
+	names := make([]string, len(paths))
+
+	for i, p := range paths {
+		rel, err := filepath.Rel(string(dir), string(p))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = string(p)
+		}
+
+		names[i] = rel
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// pathWithin reports whether path is dir or within dir.
+func pathWithin(path, dir fspath.Path) bool {
+	rel, err := filepath.Rel(string(dir), string(path))
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// watchDir adds dir and its subdirectories to watcher. The Git directories are
+// skipped.
+func watchDir(watcher *fsnotify.Watcher, dir fspath.Path) error {
+	err := filepath.WalkDir(string(dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if err = watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %q: %w", path, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch directory %q: %w", dir, err)
+	}
+
+	return nil
+}
+
+// watchEvent checks the given file system event. It reports the changed path
+// and whether the event is relevant for the tasks. New directories in
+// the dotfiles directory are added to watcher.
+func watchEvent(ctx context.Context, watcher *fsnotify.Watcher, cfg *config.Config, event fsnotify.Event) (
+	fspath.Path,
+	bool,
+) {
+	path := fspath.Path(event.Name).Clean()
+
+	if event.Op == fsnotify.Chmod {
+		return path, false
+	}
+
+	if cfg.HasFile() && path == cfg.File() {
+		return path, true
+	}
+
+	if !pathWithin(path, cfg.Directory) {
+		return path, false
+	}
+
+	if slices.Contains(strings.Split(string(path), string(filepath.Separator)), ".git") {
+		return path, false
+	}
+
+	if slices.ContainsFunc(watchIgnoreSuffixes, func(s string) bool { return strings.HasSuffix(string(path), s) }) {
+		return path, false
+	}
+
+	if event.Has(fsnotify.Create) {
+		if ok, err := path.IsDir(); err == nil && ok {
+			if err = watchDir(watcher, path); err != nil {
+				slog.WarnContext(ctx, "failed to watch new directory", "path", path, "err", err)
+			}
+		}
+	}
+
+	return path, true
+}
@@ -0,0 +1,281 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+// ManifestFile is the name of the manifest file in a plugin directory.
+const ManifestFile = "manifest.json"
+
+// A manifestCache is the cache of the decoded plugin manifests that is used to
+// avoid reading and validating every manifest in the plugin search paths on
+// every run. The manifests are cached for each search path, and the cached
+// manifests for a search path are used only if the fingerprint of the search
+// path matches the fingerprint in the cache.
+type manifestCache struct {
+	// Paths contains the cached search paths. The keys of the map are
+	// the absolute search paths.
+	Paths map[string]cachedSearchPath `json:"paths"`
+
+	// file is the path to the cache file.
+	file fspath.Path
+
+	// Version is the version of the program that wrote the cache. The cache is
+	// discarded if it was written by another version as the manifests are
+	// validated by the program.
+	Version string `json:"version"`
+
+	// mu guards Paths and dirty as the search paths are read concurrently.
+	mu sync.Mutex
+
+	// dirty tells whether the cache has changed and must be written.
+	dirty bool
+}
+
+// cachedSearchPath contains the cached manifests of one search path.
+type cachedSearchPath struct {
+	// Fingerprint is the fingerprint of the search path at the time when
+	// the manifests were read.
+	Fingerprint string `json:"fingerprint"`
+
+	// Manifests are the decoded and validated manifests in the search path.
+	Manifests []*api.Manifest `json:"manifests"`
+
+	// Options contains the entry options of the manifests by the names of
+	// the plugins. See [stripEntryOptions].
+	Options map[string]*manifestOptions `json:"options,omitempty"`
+
+	// Dirs contains the plugin directories by the names of the plugins.
+	Dirs map[string]fspath.Path `json:"dirs"`
+}
+
+// loadManifestCache loads the manifest cache from file. If file is empty,
+// the function returns nil and the manifests are not cached. If the cache file
+// does not exist, is invalid, or was written by another version, the function
+// returns an empty cache that is written to file when the store is created.
+func loadManifestCache(ctx context.Context, file fspath.Path) *manifestCache {
+	if file == "" {
+		return nil
+	}
+
+	cache := &manifestCache{
+		Paths:   make(map[string]cachedSearchPath),
+		file:    file,
+		Version: version.Version().String(),
+		mu:      sync.Mutex{},
+		dirty:   false,
+	}
+
+	data, err := os.ReadFile(string(file))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.DebugContext(ctx, "failed to read plugin manifest cache", "path", file, "err", err)
+		}
+
+		return cache
+	}
+
+	var stored manifestCache
+	if err = json.Unmarshal(data, &stored); err != nil {
+		slog.DebugContext(ctx, "discarding invalid plugin manifest cache", "path", file, "err", err)
+
+		return cache
+	}
+
+	if stored.Version != cache.Version || stored.Paths == nil {
+		slog.DebugContext(ctx, "discarding plugin manifest cache from another version", "version", stored.Version)
+
+		return cache
+	}
+
+	cache.Paths = stored.Paths
+
+	return cache
+}
+
+// lookup returns the plugins for the cached manifests of the search path if
+// the cached fingerprint matches the given fingerprint.
+func (c *manifestCache) lookup(path fspath.Path, fingerprint string) ([]Plugin, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.Paths[string(path)]
+	if !ok || cached.Fingerprint != fingerprint {
+		return nil, false
+	}
+
+	plugins := make([]Plugin, 0, len(cached.Manifests))
+
+	for _, m := range cached.Manifests {
+		dir, ok := cached.Dirs[m.Name]
+		if !ok {
+			return nil, false
+		}
+
+		plugin := newExternalPlugin(m)
+		plugin.dir = dir
+		plugin.options = cached.Options[m.Name]
+		plugins = append(plugins, plugin)
+	}
+
+	return plugins, true
+}
+
+// store stores the manifests of the plugins that were read from the search
+// path to the cache.
+func (c *manifestCache) store(path fspath.Path, fingerprint string, plugins []Plugin) {
+	if c == nil {
+		return
+	}
+
+	manifests := make([]*api.Manifest, 0, len(plugins))
+	options := make(map[string]*manifestOptions)
+	dirs := make(map[string]fspath.Path, len(plugins))
+
+	for _, p := range plugins {
+		manifests = append(manifests, p.Manifest())
+
+		ext, ok := p.(*externalPlugin)
+		if !ok {
+			continue
+		}
+
+		dirs[p.Manifest().Name] = ext.dir
+
+		if ext.options != nil {
+			options[p.Manifest().Name] = ext.options
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Paths[string(path)] = cachedSearchPath{
+		Fingerprint: fingerprint,
+		Manifests:   manifests,
+		Options:     options,
+		Dirs:        dirs,
+	}
+	c.dirty = true
+}
+
+// write writes the cache to its file if it has changed. The cache file is
+// replaced atomically so that concurrent runs do not read partial caches.
+func (c *manifestCache) write() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode plugin manifest cache: %w", err)
+	}
+
+	if err = os.MkdirAll(string(c.file.Dir()), 0o755); err != nil { //nolint:gosec // cache is not secret
+		return fmt.Errorf("failed to create directory for %q: %w", c.file, err)
+	}
+
+	tmp, err := os.CreateTemp(string(c.file.Dir()), "."+string(c.file.Base())+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %q: %w", c.file, err)
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to write %q: %w", tmp.Name(), err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to close %q: %w", tmp.Name(), err)
+	}
+
+	if err = os.Rename(tmp.Name(), string(c.file)); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to replace %q: %w", c.file, err)
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+// searchPathFingerprint returns the fingerprint of the given search path. It is
+// computed from the names and modification times of the plugin directories and
+// the modification times and sizes of their manifest files so that it changes
+// when a plugin is added, removed, or its manifest changes.
+func searchPathFingerprint(path fspath.Path) (string, error) {
+	dir, err := os.ReadDir(string(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read directory %q: %w", path, err)
+	}
+
+	h := sha256.New()
+
+	for _, entry := range dir {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("failed to get file info for %q: %w", path.Join(entry.Name()), err)
+		}
+
+		// The writes to the hash never return errors.
+		_, _ = h.Write([]byte(entry.Name() + "\x00" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\x00"))
+
+		info, err = os.Stat(string(path.Join(entry.Name(), ManifestFile)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				_, _ = h.Write([]byte("-\x00"))
+
+				continue
+			}
+
+			return "", fmt.Errorf("failed to get file info for the manifest in %q: %w", path.Join(entry.Name()), err)
+		}
+
+		_, _ = h.Write([]byte(
+			strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\x00" + strconv.FormatInt(info.Size(), 10) + "\x00",
+		))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
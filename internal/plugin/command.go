@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
@@ -36,6 +37,15 @@ type Command struct {
 
 	// Commands is a list of subcommands that this command provides.
 	Commands []*Command
+
+	// PersistentConfig contains the config entries that this command shares
+	// with its subcommands. Their values are resolved in the config of this
+	// command, and their flags can also be given after the subcommands.
+	PersistentConfig []api.ConfigEntry
+
+	// Options contains the entry options of the config entries of this
+	// command by the keys of the entries.
+	Options map[string]EntryOptions
 }
 
 // logCmds is a helper type for logging a slice of commands.
@@ -76,8 +86,26 @@ func (c *Command) Names() []string {
 	return names
 }
 
-// Run runs the command by calling the correct plugin.
-func (c *Command) Run(ctx context.Context, store *Store, cfg, pluginCfg api.KeyValues, tasks []TaskConfig) error {
+// Entries returns the config entries of the command that are resolved in its
+// config: the entries of the command and its persistent entries.
+func (c *Command) Entries() []api.ConfigEntry {
+	if c == nil {
+		panic("calling Entries on nil command")
+	}
+
+	return slices.Concat(c.Config, c.PersistentConfig)
+}
+
+// Run runs the command by calling the correct plugin and returns the result of
+// the command. The global options of the run in cmdCtx are passed to
+// the plugin, and they may be nil.
+func (c *Command) Run(
+	ctx context.Context,
+	store *Store,
+	args []string,
+	cfg, pluginCfg api.KeyValues,
+	cmdCtx *CommandContext,
+) (*RunCommandResult, error) {
 	if c == nil {
 		panic("calling Run on nil command")
 	}
@@ -86,8 +114,8 @@ func (c *Command) Run(ctx context.Context, store *Store, cfg, pluginCfg api.KeyV
 		panic(fmt.Sprintf("command %q has nil plugin", c.Name))
 	}
 
-	if err := store.start(ctx, c.Plugin, tasks); err != nil {
-		return err
+	if err := store.Require(ctx, c.Plugin.Manifest().Name); err != nil {
+		return nil, err
 	}
 
 	names := c.Names()
@@ -98,7 +126,7 @@ func (c *Command) Run(ctx context.Context, store *Store, cfg, pluginCfg api.KeyV
 
 	name := strings.Join(names, ".")
 
-	return callRunCommand(ctx, c.Plugin, name, cfg, pluginCfg)
+	return callRunCommand(ctx, c.Plugin, name, args, cfg, pluginCfg, cmdCtx)
 }
 
 // LogValue implements [slog.LogValuer] for logCmds. It formats the slice of
@@ -125,10 +153,12 @@ func newCommand(plugin Plugin, manifest *api.Command) *Command {
 	}
 
 	cmd := &Command{
-		Command:  manifest,
-		Commands: nil,
-		Parent:   nil,
-		Plugin:   plugin,
+		Command:          manifest,
+		Commands:         nil,
+		PersistentConfig: nil,
+		Options:          nil,
+		Parent:           nil,
+		Plugin:           plugin,
 	}
 
 	var cmds []*Command
@@ -182,5 +212,24 @@ func newCommands(plugin Plugin) []*Command {
 		Args:        nil,
 	}
 
-	return []*Command{newCommand(plugin, cmdInfo)}
+	cmd := newCommand(plugin, cmdInfo)
+
+	if ext, ok := plugin.(*externalPlugin); ok && ext.options != nil {
+		setEntryOptions(cmd, "", ext.options)
+	}
+
+	return []*Command{cmd}
+}
+
+// setEntryOptions sets the entry options and the persistent config entries of
+// cmd and its subcommands from the given manifest options by the paths of
+// the commands. path is the names of the subcommands that lead to cmd from
+// the root command of the plugin joined with spaces.
+func setEntryOptions(cmd *Command, path string, opts *manifestOptions) {
+	cmd.Options = opts.Commands[path]
+	cmd.PersistentConfig = opts.PersistentConfig[path]
+
+	for _, c := range cmd.Commands {
+		setEntryOptions(c, strings.TrimSpace(path+" "+c.Name), opts)
+	}
 }
@@ -0,0 +1,396 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// conformanceTimeout is the time that a plugin has to respond to a request or
+// to exit during the conformance checks.
+const conformanceTimeout = 5 * time.Second
+
+// conformanceUnknown is the name of the method, the command, and the task type
+// that the conformance checks use for the names that the plugin must not know.
+const conformanceUnknown = "reginald-conformance-unknown"
+
+// Errors returned by the conformance checks.
+var (
+	errConformance = errors.New("protocol violation")
+	errTimeout     = errors.New("timed out")
+)
+
+// A ConformanceResult is the result of a single conformance check.
+type ConformanceResult struct {
+	// Err is the protocol violation that the check found. It is nil if
+	// the check passed.
+	Err error
+
+	// Name describes the checked behavior.
+	Name string
+}
+
+// A conformanceClient is a minimal protocol client used for the conformance
+// checks. Unlike the regular client, it reports all of the unexpected messages
+// as errors instead of handling them.
+type conformanceClient struct {
+	cmd    *exec.Cmd
+	group  *processGroup
+	stdin  io.WriteCloser
+	msgCh  chan *rpcMessage
+	errCh  chan error
+	doneCh chan error
+	lastID int64
+}
+
+// CheckConformance runs the plugin in the plugin directory dir through
+// a scripted session and checks that it follows the protocol. The session
+// consists of the handshake, calls to unknown methods and with invalid params,
+// the shutdown, and the exit. In addition, the plugin is checked to exit when
+// the client closes the connection. It returns an error if the plugin cannot be
+// loaded; the protocol violations are reported in the results.
+func CheckConformance(ctx context.Context, dir fspath.Path) ([]ConformanceResult, error) {
+	p, err := readExternalPlugin(dir.Join(ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	m := p.manifest
+
+	var results []ConformanceResult
+
+	check := func(name string, fn func() error) {
+		results = append(results, ConformanceResult{Err: fn(), Name: name})
+	}
+
+	c, err := startConformanceClient(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	defer c.kill()
+
+	check("handshake returns the protocol and the plugin name", func() error {
+		return c.checkHandshake(ctx, m.Name)
+	})
+	check("unknown method returns \"method not found\"", func() error {
+		return c.checkError(ctx, conformanceUnknown, nil, api.CodeMethodNotFound)
+	})
+	check("runCommand with invalid params returns an error", func() error {
+		return c.checkError(ctx, api.MethodRunCommand, json.RawMessage(`"invalid"`), 0)
+	})
+	check("runCommand with an unknown command returns an error", func() error {
+		return c.checkError(ctx, api.MethodRunCommand, api.RunCommandParams{
+			Cmd:          conformanceUnknown,
+			Config:       api.KeyValues{},
+			PluginConfig: api.KeyValues{},
+		}, 0)
+	})
+	check("runTask with invalid params returns an error", func() error {
+		return c.checkError(ctx, api.MethodRunTask, json.RawMessage(`"invalid"`), 0)
+	})
+	check("runTask with an unknown task type returns an error", func() error {
+		return c.checkError(ctx, api.MethodRunTask, api.RunTaskParams{
+			TaskType: conformanceUnknown,
+			Config:   api.KeyValues{},
+		}, 0)
+	})
+	check("shutdown returns a result", func() error {
+		_, err := c.call(ctx, api.MethodShutdown, nil)
+
+		return err
+	})
+	check("exit notification stops the plugin", func() error {
+		if err := c.notify(ctx, api.MethodExit); err != nil {
+			return err
+		}
+
+		return c.waitExit(true)
+	})
+
+	check("plugin exits when the client closes the connection", func() error {
+		c, err := startConformanceClient(ctx, m)
+		if err != nil {
+			return err
+		}
+		defer c.kill()
+
+		if err = c.checkHandshake(ctx, m.Name); err != nil {
+			return err
+		}
+
+		if err = c.stdin.Close(); err != nil {
+			return fmt.Errorf("failed to close the standard input: %w", err)
+		}
+
+		// The plugin was not shut down so it may exit with an error.
+		return c.waitExit(false)
+	})
+
+	return results, nil
+}
+
+// startConformanceClient starts the plugin process for the given manifest and
+// returns a client connected to it.
+func startConformanceClient(ctx context.Context, m *api.Manifest) (*conformanceClient, error) {
+	cmd := exec.CommandContext(ctx, m.Executable) // #nosec G204 -- the executable is checked when reading manifest
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe for %s: %w", m.Executable, err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe for %s: %w", m.Executable, err)
+	}
+
+	group, err := newProcessGroup(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up process group for %q: %w", m.Name, err)
+	}
+
+	cmd.Cancel = func() error {
+		return group.kill(cmd.Process)
+	}
+
+	if err = cmd.Start(); err != nil {
+		_ = group.close()
+
+		return nil, fmt.Errorf("execution of %q (%s) failed: %w", m.Name, m.Executable, err)
+	}
+
+	// If the process cannot be added to the group, only the process itself is
+	// killed.
+	_ = group.attach(cmd.Process)
+
+	c := &conformanceClient{
+		cmd:    cmd,
+		group:  group,
+		stdin:  stdin,
+		msgCh:  make(chan *rpcMessage),
+		errCh:  make(chan error, 1),
+		doneCh: make(chan error, 1),
+		lastID: 0,
+	}
+
+	go func() {
+		reader := bufio.NewReader(stdout)
+
+		for {
+			msgs, _, err := read(reader, int(DefaultMaxMessageSize))
+			if err != nil {
+				c.errCh <- err
+
+				return
+			}
+
+			for _, msg := range msgs {
+				c.msgCh <- msg
+			}
+		}
+	}()
+
+	go func() {
+		err := cmd.Wait()
+
+		_ = group.kill(cmd.Process)
+		_ = group.close()
+
+		c.doneCh <- err
+	}()
+
+	return c, nil
+}
+
+// call sends a request to the plugin and waits for the response. It returns
+// the response or an error if the plugin violates the protocol before
+// responding.
+func (c *conformanceClient) call(ctx context.Context, method string, params any) (*rpcMessage, error) {
+	c.lastID++
+
+	id, err := api.NewID(c.lastID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request ID: %w", err)
+	}
+
+	var raw json.RawMessage
+
+	if params != nil {
+		if raw, err = json.Marshal(params); err != nil {
+			return nil, fmt.Errorf("failed to marshal params: %w", err)
+		}
+	}
+
+	if err = c.exited(); err != nil {
+		return nil, err
+	}
+
+	req := api.Request{JSONRPC: api.JSONRPCVersion, ID: id, Method: method, Params: raw}
+	if err = write(ctx, c.stdin, req); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(conformanceTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg := <-c.msgCh:
+			if msg.Method != "" {
+				// The notifications and the requests from the plugin are
+				// allowed in between but they are not checked here.
+				continue
+			}
+
+			if msg.JSONRCP != api.JSONRPCVersion {
+				return nil, fmt.Errorf("%w: response has JSON-RPC version %q", errConformance, msg.JSONRCP)
+			}
+
+			if msg.ID == nil || idToKey(msg.ID) != idToKey(id) {
+				return nil, fmt.Errorf("%w: response to %q has wrong ID %s", errConformance, method, encodeID(msg.ID))
+			}
+
+			if msg.Error != nil && len(msg.Result) > 0 {
+				return nil, fmt.Errorf("%w: response to %q has both result and error", errConformance, method)
+			}
+
+			if msg.Error == nil && len(msg.Result) == 0 {
+				return nil, fmt.Errorf("%w: response to %q has neither result nor error", errConformance, method)
+			}
+
+			if msg.Error != nil {
+				return msg, fmt.Errorf("%w: %q returned error %d: %s", errConformance, method, msg.Error.Code, msg.Error.Message)
+			}
+
+			return msg, nil
+		case err := <-c.errCh:
+			return nil, fmt.Errorf("%w: failed to read response to %q: %w", errConformance, method, err)
+		case err := <-c.doneCh:
+			c.doneCh <- err
+
+			return nil, fmt.Errorf("%w: plugin exited before responding to %q: %v", errConformance, method, err)
+		case <-timer.C:
+			return nil, fmt.Errorf("%w: no response to %q: %w", errConformance, method, errTimeout)
+		}
+	}
+}
+
+// checkError calls the method and checks that the plugin responds with
+// an error. If code is not zero, the error must have that code.
+func (c *conformanceClient) checkError(ctx context.Context, method string, params any, code int) error {
+	msg, err := c.call(ctx, method, params)
+	if msg == nil {
+		return err
+	}
+
+	if msg.Error == nil {
+		return fmt.Errorf("%w: %q returned a result instead of an error", errConformance, method)
+	}
+
+	if code != 0 && msg.Error.Code != code {
+		return fmt.Errorf("%w: %q returned error code %d, want %d", errConformance, method, msg.Error.Code, code)
+	}
+
+	return nil
+}
+
+// checkHandshake performs the handshake and checks the result.
+func (c *conformanceClient) checkHandshake(ctx context.Context, name string) error {
+	params := newHandshakeParams()
+
+	msg, err := c.call(ctx, api.MethodHandshake, params)
+	if err != nil {
+		return err
+	}
+
+	var result api.HandshakeResult
+	if err = json.Unmarshal(msg.Result, &result); err != nil {
+		return fmt.Errorf("%w: invalid handshake result: %w", errConformance, err)
+	}
+
+	switch {
+	case result.Protocol != params.Protocol:
+		return fmt.Errorf("%w: handshake returned protocol %q, want %q", errConformance, result.Protocol, params.Protocol)
+	case result.ProtocolVersion < params.MinProtocolVersion || result.ProtocolVersion > params.MaxProtocolVersion:
+		return fmt.Errorf(
+			"%w: handshake returned protocol version %d, want %d to %d",
+			errConformance,
+			result.ProtocolVersion,
+			params.MinProtocolVersion,
+			params.MaxProtocolVersion,
+		)
+	case result.Name != name:
+		return fmt.Errorf("%w: handshake returned name %q, want %q from the manifest", errConformance, result.Name, name)
+	}
+
+	return nil
+}
+
+// exited returns an error if the plugin process has already exited.
+func (c *conformanceClient) exited() error {
+	select {
+	case err := <-c.doneCh:
+		c.doneCh <- err
+
+		return fmt.Errorf("%w: plugin has exited: %v", errConformance, err)
+	default:
+		return nil
+	}
+}
+
+// kill kills the plugin process and the processes it has started if it is
+// still running.
+func (c *conformanceClient) kill() {
+	if c.cmd.ProcessState == nil && c.cmd.Process != nil {
+		_ = c.group.kill(c.cmd.Process)
+	}
+}
+
+// notify sends a notification to the plugin.
+func (c *conformanceClient) notify(ctx context.Context, method string) error {
+	if err := c.exited(); err != nil {
+		return err
+	}
+
+	return write(ctx, c.stdin, api.Request{JSONRPC: api.JSONRPCVersion, ID: nil, Method: method, Params: nil})
+}
+
+// waitExit waits for the plugin process to exit. If success is true, it also
+// checks that the plugin exits successfully.
+func (c *conformanceClient) waitExit(success bool) error {
+	select {
+	case err := <-c.doneCh:
+		c.doneCh <- err
+
+		var exitErr *exec.ExitError
+		if err != nil && (success || !errors.As(err, &exitErr)) {
+			return fmt.Errorf("%w: plugin exited with an error: %w", errConformance, err)
+		}
+
+		return nil
+	case <-time.After(conformanceTimeout):
+		return fmt.Errorf("%w: plugin did not exit: %w", errConformance, errTimeout)
+	}
+}
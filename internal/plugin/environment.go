@@ -0,0 +1,151 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/reginald-project/reginald/internal/logger"
+	"github.com/reginald-project/reginald/internal/version"
+)
+
+// envPrefix is the prefix of the environment variables that the client sets
+// for the plugins. The variables with the prefix in the environment of
+// Reginald are the options of Reginald itself, so they are not passed to
+// the plugins.
+const envPrefix = "REGINALD_"
+
+// The environment variables that the client sets for the plugin processes.
+const (
+	envVersion         = envPrefix + "VERSION"
+	envProtocolVersion = envPrefix + "PROTOCOL_VERSION"
+	envLogLevel        = envPrefix + "LOG_LEVEL"
+	envDataDir         = envPrefix + "DATA_DIR"
+	envScratchDir      = envPrefix + "SCRATCH_DIR"
+)
+
+// dataDirPerm is the permissions for the data directories of the plugins.
+const dataDirPerm = 0o700
+
+// An environment is the environment that the client sets up for a plugin
+// process. The client passes it to the plugin both as environment variables
+// and in the handshake params.
+type environment struct {
+	// Version is the version of Reginald.
+	Version string `json:"version"`
+
+	// LogLevel is the minimum level of the log messages from the plugin that
+	// the client logs. The plugin can skip sending the messages below it.
+	LogLevel string `json:"logLevel"`
+
+	// PluginDir is the directory of the plugin that contains its manifest. It
+	// is the working directory of the plugin process unless the sandbox of
+	// the plugin sets another one.
+	PluginDir string `json:"pluginDir"`
+
+	// DataDir is the directory for the persistent data of the plugin. It is
+	// empty if the client has no data directory for the plugin.
+	DataDir string `json:"dataDir,omitempty"`
+
+	// ScratchDir is the temporary directory of the plugin process. It is
+	// removed when the process exits.
+	ScratchDir string `json:"scratchDir"`
+
+	// ProtocolVersion is the highest protocol version that the client
+	// supports. The version that is used is negotiated in the handshake.
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// newEnvironment creates the environment for starting the process of
+// the given plugin. It creates the data directory of the plugin if needed and
+// a new scratch directory that the caller must remove when the process exits.
+func newEnvironment(ctx context.Context, e *externalPlugin) (*environment, error) {
+	if e.dataDir != "" {
+		if err := os.MkdirAll(string(e.dataDir), dataDirPerm); err != nil {
+			return nil, fmt.Errorf("failed to create data directory for %q: %w", e.manifest.Name, err)
+		}
+	}
+
+	scratch, err := os.MkdirTemp("", "reginald-"+strings.TrimPrefix(e.manifest.Name, "reginald-")+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory for %q: %w", e.manifest.Name, err)
+	}
+
+	env := &environment{
+		Version:         version.Version().String(),
+		LogLevel:        logLevelName(ctx, e.logLevel),
+		PluginDir:       string(e.dir),
+		DataDir:         string(e.dataDir),
+		ScratchDir:      scratch,
+		ProtocolVersion: maxProtocolVersion,
+	}
+
+	return env, nil
+}
+
+// environ returns the environment variables for the plugin process. It
+// removes the variables of Reginald from base and adds the variables of env.
+func (env *environment) environ(base []string) []string {
+	vars := make([]string, 0, len(base)+5) //nolint:mnd // number of variables set below
+
+	for _, kv := range base {
+		if !strings.HasPrefix(kv, envPrefix) {
+			vars = append(vars, kv)
+		}
+	}
+
+	vars = append(
+		vars,
+		envVersion+"="+env.Version,
+		envProtocolVersion+"="+strconv.Itoa(env.ProtocolVersion),
+		envLogLevel+"="+env.LogLevel,
+		envScratchDir+"="+env.ScratchDir,
+	)
+
+	if env.DataDir != "" {
+		vars = append(vars, envDataDir+"="+env.DataDir)
+	}
+
+	return vars
+}
+
+// remove removes the scratch directory of env.
+func (env *environment) remove(ctx context.Context) {
+	if err := os.RemoveAll(env.ScratchDir); err != nil {
+		slog.WarnContext(ctx, "failed to remove plugin scratch directory", "dir", env.ScratchDir, "err", err)
+	}
+}
+
+// logLevelName returns the lowercase name of the minimum level of the log
+// messages that are logged from a plugin. If level is nil, the level of
+// the default logger is used.
+func logLevelName(ctx context.Context, level *slog.Level) string {
+	if level != nil {
+		return strings.ToLower(logger.Level(*level).String())
+	}
+
+	for _, l := range []logger.Level{logger.LevelTrace, logger.LevelDebug, logger.LevelInfo, logger.LevelWarn} {
+		if slog.Default().Enabled(ctx, l.Level()) {
+			return strings.ToLower(l.String())
+		}
+	}
+
+	return strings.ToLower(logger.LevelError.String())
+}
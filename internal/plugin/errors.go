@@ -15,25 +15,136 @@
 package plugin
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/errhint"
 	"github.com/reginald-project/reginald/internal/fspath"
 )
 
+// ErrCommandFailed is returned when a plugin command reports a non-zero exit
+// code in its result.
+var ErrCommandFailed = errors.New("command failed")
+
+// ErrQuit is returned when the user chooses to quit the run when confirming
+// the tasks in interactive mode.
+var ErrQuit = errors.New("run aborted by user")
+
+// ErrReload is returned by a command when the config file has changed and
+// the program should parse the config again and rerun the command.
+var ErrReload = errors.New("config reload requested")
+
+// ErrTaskTimeout is returned when a task is canceled as it did not finish
+// within its timeout.
+var ErrTaskTimeout = errors.New("task timed out")
+
 // Errors returned when a plugin is invalid.
 var (
 	ErrInvalidCast     = errors.New("cannot convert type")
 	ErrInvalidConfig   = errors.New("invalid plugin config")
 	errHandshake       = errors.New("plugin provided incompatible response")
+	errInitialize      = errors.New("failed to initialize plugin")
+	errInvalidBatch    = errors.New("invalid batch")
+	errInvalidMessage  = errors.New("invalid message")
 	errInvalidResponse = errors.New("invalid response")
 	errInvalidLength   = errors.New("number of bytes read does not match")
 	errInvalidManifest = errors.New("invalid plugin manifest")
+	errNoDataDir       = errors.New("plugin has no data directory")
+	errNoDownloader    = errors.New("downloads are not available")
 	errNoProvider      = errors.New("no provider for runtime")
 	errNoResponse      = errors.New("no response")
+	errNotInteractive  = errors.New("cannot prompt the user in non-interactive mode")
+	errNotResponding   = errors.New("plugin is not responding")
+	errQuarantined     = errors.New("quarantined")
+	errNoURL           = errors.New("no URL")
+	errUnknownPlugin   = errors.New("unknown plugin")
 	errUnknownMethod   = errors.New("unknown method")
 	errZeroLength      = errors.New("Content-Length is zero")
 )
 
+// Errors returned when a response from a plugin cannot be delivered.
+var (
+	errDuplicateResponse = errors.New("duplicate response")
+	errLateResponse      = errors.New("response to closed request")
+	errPartialResult     = errors.New("invalid partial result")
+	errResponseID        = errors.New("response ID is not an integer")
+	errUnknownID         = errors.New("response with unknown ID")
+)
+
+// Errors returned when the header of a message from a plugin is invalid.
+var (
+	errContentLength   = errors.New("bad Content-Length")
+	errContentType     = errors.New("unsupported Content-Type")
+	errDuplicateHeader = errors.New("duplicate header")
+	errInvalidHeader   = errors.New("invalid header")
+	errMessageTooLarge = errors.New("message too large")
+	errNoContentLength = errors.New("no Content-Length")
+	errUnknownHeader   = errors.New("unknown header")
+)
+
+// The categories of the errors that the plugins report in the data of their
+// error responses. The category tells the client whose problem the error is,
+// and the client uses it for choosing the exit code of the program.
+const (
+	// CategoryConfig is the category of the errors caused by invalid config.
+	CategoryConfig = "config"
+
+	// CategoryUser is the category of the errors caused by the input of
+	// the user, like the arguments of a command.
+	CategoryUser = "user"
+
+	// CategoryEnvironment is the category of the errors caused by
+	// the environment, like a missing program or a network that is down.
+	CategoryEnvironment = "environment"
+
+	// CategoryInternal is the category of the errors caused by a bug in
+	// the plugin.
+	CategoryInternal = "internal"
+)
+
+// An Error is an error response from a plugin. The category, the hint, and
+// whether the request may be retried are decoded from the data of the response
+// if the plugin set them.
+type Error struct {
+	// Err is the error in the response.
+	Err *api.Error
+
+	// Category is the category of the error. It is empty if the plugin did not
+	// set a known category.
+	Category string
+
+	// Hint is the suggestion for fixing the problem.
+	Hint string
+
+	// Detail is the additional information on the error that is shown after
+	// the message.
+	Detail string
+
+	// Retryable reports whether the request may succeed if it is sent again.
+	Retryable bool
+
+	// structured reports whether the data of the response was an object with
+	// the fields decoded into e.
+	structured bool
+}
+
+// A DecodeError is returned when the content of a message from a plugin is not
+// a valid message. The message has been read in full, so it can be skipped and
+// the next message can be read.
+type DecodeError struct {
+	Err error
+}
+
+// A FrameError is returned when a message from a plugin cannot be read as its
+// header is invalid or reading from the plugin fails. The start of the next
+// message is not known after it, so no more messages can be read.
+type FrameError struct {
+	Err error
+}
+
 // A PathError is returned when a plugin search path is not found.
 type PathError struct {
 	Path fspath.Path
@@ -43,6 +154,119 @@ type PathError struct {
 // search paths. It may only contain PathErrors.
 type PathErrors []error
 
+// A TaskValidationError is returned when a plugin reports problems in
+// the config of a task instance in the "validateTask" method.
+type TaskValidationError struct {
+	// ID is the ID of the task instance.
+	ID string
+
+	// TaskType is the type of the task instance.
+	TaskType string
+
+	// Problems contains the problems that the plugin reported.
+	Problems []string
+}
+
+// ValidationErrors is a slice of TaskValidationError that collects the problems
+// of all of the task instances that failed the validation. It may only contain
+// TaskValidationErrors.
+type ValidationErrors []error
+
+// newError returns the error for the error response res from a plugin. If
+// the plugin gave a hint in the data of the response, the hint is attached to
+// the returned error with [errhint].
+func newError(res *api.Error) error {
+	err := &Error{
+		Err:        res,
+		Category:   "",
+		Hint:       "",
+		Detail:     "",
+		Retryable:  false,
+		structured: false,
+	}
+
+	// The data is decoded from the response into generic values so it is
+	// encoded again for decoding the fields.
+	var data errorData
+
+	if raw, jsonErr := json.Marshal(res.Data); jsonErr == nil && json.Unmarshal(raw, &data) == nil {
+		switch data.Category {
+		case CategoryConfig, CategoryUser, CategoryEnvironment, CategoryInternal:
+			err.Category = data.Category
+		}
+
+		err.Hint = data.Hint
+		err.Detail = data.Detail
+		err.Retryable = data.Retryable
+		err.structured = true
+	}
+
+	if err.Hint != "" {
+		return errhint.Wrap(err, err.Hint)
+	}
+
+	return err
+}
+
+// ErrorCategory returns the category of the first error response from a plugin
+// in the tree of err. It returns an empty string if there is none or
+// the plugin did not set the category.
+func ErrorCategory(err error) string {
+	var pluginErr *Error
+	if errors.As(err, &pluginErr) {
+		return pluginErr.Category
+	}
+
+	return ""
+}
+
+// Retryable reports whether the first error response from a plugin in the tree
+// of err was marked as retryable by the plugin.
+func Retryable(err error) bool {
+	var pluginErr *Error
+
+	return errors.As(err, &pluginErr) && pluginErr.Retryable
+}
+
+// Error returns the value of e as a string. If the data of the response had
+// the fields of the error, the detail is shown instead of the raw data.
+func (e *Error) Error() string {
+	if !e.structured {
+		return "plugin returned an error: " + e.Err.Error()
+	}
+
+	if e.Detail == "" {
+		return "plugin returned an error: " + e.Err.Message
+	}
+
+	return "plugin returned an error: " + e.Err.Message + ": " + e.Detail
+}
+
+// Unwrap returns the error wrapped by e.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the value of e as a string.
+func (e *DecodeError) Error() string {
+	return "failed to decode message: " + e.Err.Error()
+}
+
+// Unwrap returns the error wrapped by e.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the value of e as a string.
+func (e *FrameError) Error() string {
+	return "failed to read message: " + e.Err.Error()
+}
+
+// Unwrap returns the error wrapped by e.
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
 // Error returns the value of e as a string.
 func (e *PathError) Error() string {
 	if e.Path == "" {
@@ -93,3 +317,34 @@ func (e PathErrors) Paths() []string {
 
 	return paths
 }
+
+// Error returns the value of e as a string.
+func (e *TaskValidationError) Error() string {
+	return fmt.Sprintf("invalid config for task %q (%s): %s", e.ID, e.TaskType, strings.Join(e.Problems, "; "))
+}
+
+// Error returns the value of e as a string. The problems are listed on their
+// own lines under the tasks they belong to.
+func (e ValidationErrors) Error() string {
+	s := "task validation failed"
+
+	for _, err := range e {
+		var taskErr *TaskValidationError
+		if !errors.As(err, &taskErr) {
+			panic("ValidationErrors contains an error that is not a TaskValidationError")
+		}
+
+		s += fmt.Sprintf("\n  - task %q (%s):", taskErr.ID, taskErr.TaskType)
+
+		for _, p := range taskErr.Problems {
+			s += "\n      " + p
+		}
+	}
+
+	return s
+}
+
+// Unwrap returns the wrapped errors from e.
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,170 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/panichandler"
+)
+
+// pingTimeout is the maximum time that the client waits for a response to
+// a "ping" call before it considers the plugin hung.
+const pingTimeout = 10 * time.Second
+
+// Keepalive starts pinging the started external plugins in the background. At
+// every interval, each plugin that has not sent a message during the interval
+// and has no calls in progress is sent a "ping" call. If a plugin does not
+// respond in time, it is reported as hung and using it fails. If restart is
+// true, the hung plugins are restarted instead when they are required again.
+// The pinging stops when ctx is done. Keepalive does not ping the plugins if
+// interval is not positive.
+func (s *Store) Keepalive(ctx context.Context, interval time.Duration, restart bool) {
+	s.restart = restart
+
+	if interval <= 0 {
+		return
+	}
+
+	handlePanic := panichandler.WithStackTrace()
+
+	go func() {
+		defer handlePanic()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, p := range s.plugins {
+				if e, ok := p.(*externalPlugin); ok {
+					ping(ctx, e, interval, restart)
+				}
+			}
+		}
+	}()
+}
+
+// restartHung restarts the plugin with the given name if it has not responded
+// to a ping. If the store is not set to restart the plugins, it returns an
+// error for a hung plugin instead.
+func (s *Store) restartHung(ctx context.Context, name string) error {
+	e, ok := s.PluginByName(name).(*externalPlugin)
+	if !ok || !e.hung.Load() {
+		return nil
+	}
+
+	if !s.restart {
+		return fmt.Errorf("%w: %s", errNotResponding, name)
+	}
+
+	slog.WarnContext(ctx, "restarting hung plugin", "plugin", name)
+
+	if err := s.reset(ctx, e); err != nil {
+		return err
+	}
+
+	return s.start(ctx, e)
+}
+
+// reset kills the process of the given plugin and resets the plugin to
+// the state before it was started so that it is started again when it is
+// required the next time.
+func (s *Store) reset(ctx context.Context, e *externalPlugin) error {
+	if err := e.kill(ctx); err != nil {
+		return err
+	}
+
+	// The process has been killed so waiting for it cannot block for long.
+	<-e.doneCh
+
+	e.cmd = nil
+	e.conn = nil
+	e.doneCh = make(chan error)
+
+	e.hung.Store(false)
+	delete(s.capabilities, e.manifest.Name)
+
+	return nil
+}
+
+// ping calls the "ping" method on the given plugin if it has been idle for at
+// least interval and marks the plugin as hung if it does not respond in time.
+// The plugins that have already been marked as hung are pinged again only if
+// they are not going to be restarted so that they are marked as responding if
+// they recover.
+func ping(ctx context.Context, e *externalPlugin, interval time.Duration, restart bool) {
+	if !e.alive.Load() || (restart && e.hung.Load()) {
+		return
+	}
+
+	if time.Since(time.Unix(0, e.lastActive.Load())) < interval || len(e.queue.pending()) > 0 {
+		return
+	}
+
+	pctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+
+	err := e.call(pctx, methodPing, nil, &result)
+	if ctx.Err() != nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		// A call that was started while the ping was sent may have kept
+		// the plugin busy.
+		if len(e.queue.pending()) > 0 || !e.alive.Load() {
+			return
+		}
+
+		if e.hung.Swap(true) {
+			return
+		}
+
+		slog.ErrorContext(ctx, "plugin did not respond to ping", "plugin", e.manifest.Name, "timeout", pingTimeout)
+
+		msg := fmt.Sprintf("Plugin %q is not responding", e.manifest.Name)
+		if restart {
+			msg += ", restarting it before it is used again"
+		}
+
+		diag.Report(ctx, diag.PluginUnresponsive, msg)
+
+		return
+	default:
+		// The plugin responded even if it was with an error, or the connection
+		// has been closed which is reported by the actual calls.
+		slog.DebugContext(ctx, "ping returned an error", "plugin", e.manifest.Name, "err", err)
+	}
+
+	if e.hung.Swap(false) {
+		slog.InfoContext(ctx, "plugin is responding again", "plugin", e.manifest.Name)
+	}
+}
@@ -17,12 +17,16 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/events"
 	"github.com/reginald-project/reginald/internal/logger"
+	"github.com/reginald-project/reginald/internal/system"
+	"github.com/reginald-project/reginald/internal/terminal"
 )
 
 // callExit sends the "exit" notification to the given plugin.
@@ -36,28 +40,39 @@ func callExit(ctx context.Context, plugin Plugin) error {
 	return nil
 }
 
-// callHandshake performs the "handshake" method call with the given plugin.
-func callHandshake(ctx context.Context, plugin Plugin) error {
-	params := api.DefaultHandshakeParams()
+// callHandshake performs the "handshake" method call with the given plugin. It
+// returns the capabilities the plugin reported.
+func callHandshake(ctx context.Context, plugin Plugin) (Capabilities, error) {
+	params := newHandshakeParams()
 
-	var result api.HandshakeResult
+	if e, ok := plugin.(*externalPlugin); ok {
+		params.Environment = e.env
+	}
+
+	var result handshakeResult
 
 	if err := plugin.call(ctx, api.MethodHandshake, params, &result); err != nil {
-		return err
+		return Capabilities{}, err
 	}
 
 	switch {
 	case params.Protocol != result.Protocol:
-		return fmt.Errorf("%w: wrong protocol, want %q, got %q", errHandshake, params.Protocol, result.Protocol)
-	case params.ProtocolVersion != result.ProtocolVersion:
-		return fmt.Errorf(
-			"%w: wrong protocol version, want %q, got %q",
+		return Capabilities{}, fmt.Errorf(
+			"%w: wrong protocol, want %q, got %q",
+			errHandshake,
+			params.Protocol,
+			result.Protocol,
+		)
+	case result.ProtocolVersion < params.MinProtocolVersion || result.ProtocolVersion > params.MaxProtocolVersion:
+		return Capabilities{}, fmt.Errorf(
+			"%w: unsupported protocol version, want %d to %d, got %d",
 			errHandshake,
-			params.ProtocolVersion,
+			params.MinProtocolVersion,
+			params.MaxProtocolVersion,
 			result.ProtocolVersion,
 		)
 	case plugin.Manifest().Name != result.Name:
-		return fmt.Errorf(
+		return Capabilities{}, fmt.Errorf(
 			"%w: mismatching plugin name, want %q, got %q",
 			errHandshake,
 			plugin.Manifest().Name,
@@ -75,20 +90,102 @@ func callHandshake(ctx context.Context, plugin Plugin) error {
 		result,
 	)
 
+	return result.Capabilities, nil
+}
+
+// newHandshakeParams returns the params for the "handshake" call with the range
+// of the protocol versions and the capabilities that the client supports.
+func newHandshakeParams() handshakeParams {
+	params := handshakeParams{
+		HandshakeParams:    api.DefaultHandshakeParams(),
+		MinProtocolVersion: minProtocolVersion,
+		MaxProtocolVersion: maxProtocolVersion,
+		Capabilities:       newClientCapabilities(),
+		Environment:        nil,
+	}
+	params.ProtocolVersion = maxProtocolVersion
+
+	return params
+}
+
+// newClientCapabilities returns the optional protocol features that the client
+// supports.
+func newClientCapabilities() clientCapabilities {
+	return clientCapabilities{
+		Progress:       true,
+		Prompt:         true,
+		Cancel:         true,
+		Download:       true,
+		PartialResults: true,
+	}
+}
+
+// callInitialize makes an "initialize" call to the given plugin with its
+// resolved config and the logging preferences. The plugin must support
+// the "initialize" capability.
+func callInitialize(ctx context.Context, plugin Plugin, cfg api.KeyValues) error {
+	params := initializeParams{
+		Config: cfg,
+		Logging: initializeLogging{
+			Level:    logLevelName(ctx, nil),
+			TraceRPC: false,
+		},
+		Capabilities: newClientCapabilities(),
+	}
+
+	if params.Config == nil {
+		params.Config = api.KeyValues{}
+	}
+
+	if e, ok := plugin.(*externalPlugin); ok {
+		params.Logging.Level = logLevelName(ctx, e.logLevel)
+		params.Logging.TraceRPC = e.traceDir != ""
+	}
+
+	var result json.RawMessage
+	if err := plugin.call(ctx, methodInitialize, params, &result); err != nil {
+		return err
+	}
+
+	slog.Log(ctx, slog.Level(logger.LevelTrace), "initialize successful", "plugin", plugin.Manifest().Name)
+
 	return nil
 }
 
-// callRunCommand makes a "runCommand" call to the given plugin.
-func callRunCommand(ctx context.Context, plugin Plugin, name string, cfg, pluginCfg api.KeyValues) error {
-	params := api.RunCommandParams{
-		Cmd:          name,
-		Config:       cfg,
-		PluginConfig: pluginCfg,
+// callRunCommand makes a "runCommand" call to the given plugin with the given
+// positional arguments and the global options of the run in cmdCtx. It returns
+// the result of the command.
+func callRunCommand(
+	ctx context.Context,
+	plugin Plugin,
+	name string,
+	args []string,
+	cfg, pluginCfg api.KeyValues,
+	cmdCtx *CommandContext,
+) (*RunCommandResult, error) {
+	params := RunCommandParams{
+		RunCommandParams: api.RunCommandParams{
+			Cmd:          name,
+			Config:       cfg,
+			PluginConfig: pluginCfg,
+		},
+		Context: cmdCtx,
+		Args:    args,
 	}
 
-	var result struct{}
+	var result RunCommandResult
 	if err := plugin.call(ctx, api.MethodRunCommand, params, &result); err != nil {
-		return err
+		return nil, err
+	}
+
+	if result.ExitCode < 0 || result.ExitCode > maxExitCode {
+		return nil, fmt.Errorf(
+			"%w: exit code %d from %q is not between 0 and %d",
+			errInvalidResponse,
+			result.ExitCode,
+			plugin.Manifest().Name,
+			maxExitCode,
+		)
 	}
 
 	slog.Log(
@@ -97,23 +194,28 @@ func callRunCommand(ctx context.Context, plugin Plugin, name string, cfg, plugin
 		"runCommand successful",
 		"plugin",
 		plugin.Manifest().Name,
-		"result",
-		result,
+		"exitCode",
+		result.ExitCode,
 	)
 
-	return nil
+	return &result, nil
 }
 
-// callRunTask makes a "runTask" call to the given plugin.
-func callRunTask(ctx context.Context, plugin Plugin, tt string, cfg *TaskConfig) error {
-	params := api.RunTaskParams{
-		TaskType: tt,
-		Config:   cfg.Config,
-	}
-
-	var result struct{}
+// callRunTask makes a "runTask" call to the given plugin with the given run
+// options. The dry-run option must only be set for plugins that support
+// the "diff" capability.
+func callRunTask(
+	ctx context.Context,
+	plugin Plugin,
+	tt string,
+	cfg *TaskConfig,
+	opts RunOptions,
+) (*RunTaskResult, error) {
+	params := newRunTaskParams(tt, cfg, opts)
+
+	var result RunTaskResult
 	if err := plugin.call(ctx, api.MethodRunTask, params, &result); err != nil {
-		return err
+		return nil, err
 	}
 
 	slog.Log(
@@ -126,7 +228,41 @@ func callRunTask(ctx context.Context, plugin Plugin, tt string, cfg *TaskConfig)
 		result,
 	)
 
-	return nil
+	return &result, nil
+}
+
+// callRunTasks makes a batch of "runTask" calls to the given plugin with one
+// message. The task types in tts correspond to the task configs in cfgs.
+// The results and the errors of the individual tasks are returned in the same
+// order as the tasks. The returned error is set only if the batch could not be
+// sent.
+func callRunTasks(
+	ctx context.Context,
+	plugin *externalPlugin,
+	tts []string,
+	cfgs []*TaskConfig,
+	opts RunOptions,
+) ([]*RunTaskResult, []error, error) {
+	results := make([]*RunTaskResult, len(cfgs))
+	calls := make([]batchCall, len(cfgs))
+
+	for i, cfg := range cfgs {
+		results[i] = &RunTaskResult{Changes: nil}
+		calls[i] = batchCall{
+			method: api.MethodRunTask,
+			params: newRunTaskParams(tts[i], cfg, opts),
+			result: results[i],
+		}
+	}
+
+	errs, err := plugin.callBatch(ctx, calls)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.Log(ctx, slog.Level(logger.LevelTrace), "runTask batch done", "plugin", plugin.manifest.Name, "n", len(calls))
+
+	return results, errs, nil
 }
 
 // callShutdown makes a "shutdown" call to the given plugin.
@@ -153,17 +289,71 @@ func callShutdown(ctx context.Context, plugin Plugin) error {
 	return nil
 }
 
-// handleLog handles running the "log" method request sent from a plugin.
-func handleLog(ctx context.Context, plugin Plugin, params *api.LogParams) error {
-	level := params.Level
+// callValidateTask makes a "validateTask" call to the given plugin for the task
+// config with the given task type without the domain. It returns the problems
+// the plugin found in the config. The plugin must support the "validate"
+// capability.
+func callValidateTask(ctx context.Context, plugin Plugin, tt string, cfg *TaskConfig) ([]string, error) {
+	params := validateTaskParams{
+		RunTaskParams: api.RunTaskParams{
+			TaskType: tt,
+			Config:   cfg.Config,
+		},
+		Facts: system.CurrentFacts(),
+	}
+
+	var result validateTaskResult
+	if err := plugin.call(ctx, methodValidateTask, params, &result); err != nil {
+		return nil, err
+	}
+
+	slog.Log(
+		ctx,
+		slog.Level(logger.LevelTrace),
+		"validateTask successful",
+		"plugin",
+		plugin.Manifest().Name,
+		"result",
+		result,
+	)
 
-	if !slog.Default().Enabled(ctx, level) {
+	return result.Errors, nil
+}
+
+// newRunTaskParams returns the params for the "runTask" call for the task
+// config with the given task type without the domain.
+func newRunTaskParams(tt string, cfg *TaskConfig, opts RunOptions) RunTaskParams {
+	return RunTaskParams{
+		RunTaskParams: api.RunTaskParams{
+			TaskType: tt,
+			Config:   cfg.Config,
+		},
+		DryRun:    opts.DryRun,
+		BackupDir: string(opts.BackupDir),
+		Elevate:   cfg.Elevate,
+		Facts:     system.CurrentFacts(),
+	}
+}
+
+// handleLog handles running the "log" method request sent from a plugin.
+// The record is tagged with the name of the plugin and the ID of the request
+// the plugin was handling so that the records from plugins running
+// concurrently can be told apart. If the plugin does not report the ID, it is
+// inferred when only one request to the plugin is in flight.
+func handleLog(ctx context.Context, plugin *externalPlugin, params *logParams) error {
+	level := slog.Level(params.Level)
+
+	if plugin.logLevel != nil {
+		if level < *plugin.logLevel {
+			return nil
+		}
+	} else if !slog.Default().Enabled(ctx, level) {
 		return nil
 	}
 
 	msg := params.Message
 	src := params.Source
-	attrs := make([]slog.Attr, 0, len(params.Attrs)+1)
+	attrs := make([]slog.Attr, 0, len(params.Attrs)+2) //nolint:mnd // plugin name and request ID
 
 	if src != nil {
 		attrs = append(attrs, slog.Any(slog.SourceKey, src))
@@ -178,12 +368,21 @@ func handleLog(ctx context.Context, plugin Plugin, params *api.LogParams) error
 		attrs = append(attrs, attr)
 	}
 
-	attrs = append(attrs, slog.String("plugin", plugin.Manifest().Name))
+	attrs = append(attrs, slog.String("plugin", plugin.manifest.Name))
+
+	if params.RequestID != nil && !params.RequestID.Null {
+		attrs = append(attrs, slog.String("request", string(encodeID(params.RequestID))))
+	} else if pending := plugin.queue.pending(); len(pending) == 1 {
+		attrs = append(attrs, slog.String("request", pending[0]))
+	}
+
 	t := params.Time
 	r := slog.NewRecord(t, level, msg, 0)
 
 	r.AddAttrs(attrs...)
 
+	// The handler does not check the level so the records are logged even if
+	// the level of the plugin is lower than the level of the default logger.
 	if err := slog.Default().Handler().Handle(ctx, r); err != nil {
 		panic(err)
 	}
@@ -191,6 +390,123 @@ func handleLog(ctx context.Context, plugin Plugin, params *api.LogParams) error
 	return nil
 }
 
+// handlePartialResult handles the "partialResult" notification sent from
+// a plugin by adding the part to the result of the request. The parts of
+// the results of the requests that the client has stopped waiting for are
+// ignored like the late responses.
+func handlePartialResult(ctx context.Context, plugin *externalPlugin, params *partialResultParams) error {
+	err := plugin.queue.addPartial(params.RequestID, params.Value)
+	if errors.Is(err, errLateResponse) {
+		slog.DebugContext(ctx, "ignoring partial result to closed request", "plugin", plugin.manifest.Name, "err", err)
+
+		return nil
+	}
+
+	return err
+}
+
+// handleProgress handles the "progress" notification sent from a plugin. While
+// the plugin is running a task, the progress is written to the output of
+// the task. Otherwise, it is printed as a progress message.
+func handleProgress(ctx context.Context, plugin *externalPlugin, params *progressParams) {
+	msg := params.Message
+	if params.Total > 0 {
+		msg = fmt.Sprintf("(%d/%d) %s", params.Current, params.Total, msg)
+	}
+
+	slog.DebugContext(ctx, "progress from plugin", "plugin", plugin.manifest.Name, "msg", msg)
+
+	events.Emit(events.Event{ //nolint:exhaustruct // only the fields of the event type are set
+		Type:    events.TypeProgress,
+		Plugin:  plugin.manifest.Name,
+		Message: params.Message,
+		Current: params.Current,
+		Total:   params.Total,
+	})
+
+	if out := plugin.output.Load(); out != nil {
+		out.WriteLine(msg)
+
+		return
+	}
+
+	terminal.Progressf("[%s] %s\n", plugin.manifest.Name, msg)
+}
+
+// handleConfirm handles the "confirm" method request sent from a plugin. If
+// the user cannot be prompted, the default answer from the params is returned.
+func handleConfirm(ctx context.Context, plugin Plugin, params *ConfirmParams) (ConfirmResult, error) {
+	slog.DebugContext(ctx, "plugin requested confirmation", "plugin", plugin.Manifest().Name, "prompt", params.Prompt)
+
+	ok, err := terminal.ConfirmE(ctx, params.Prompt, params.Default)
+	if errors.Is(err, terminal.ErrQuietPrompt) {
+		return ConfirmResult{Confirmed: params.Default}, nil
+	}
+
+	if err != nil {
+		return ConfirmResult{}, fmt.Errorf("failed to prompt for plugin %q: %w", plugin.Manifest().Name, err)
+	}
+
+	return ConfirmResult{Confirmed: ok}, nil
+}
+
+// handleDownload handles the "download" method request sent from a plugin by
+// downloading the file with the downloader of the plugin.
+func handleDownload(ctx context.Context, plugin *externalPlugin, params *DownloadParams) (DownloadResult, error) {
+	name := plugin.manifest.Name
+
+	slog.DebugContext(ctx, "plugin requested download", "plugin", name, "url", params.URL, "sha256", params.SHA256)
+
+	if plugin.downloader == nil {
+		return DownloadResult{}, fmt.Errorf("%w: plugin %q requested %s", errNoDownloader, name, params.URL)
+	}
+
+	path, sum, err := plugin.downloader.Download(ctx, params.URL, params.SHA256)
+	if err != nil {
+		return DownloadResult{}, fmt.Errorf("failed to download for plugin %q: %w", name, err)
+	}
+
+	return DownloadResult{Path: string(path), SHA256: sum}, nil
+}
+
+// handlePrompt handles the "prompt" method request sent from a plugin. If
+// the user cannot be prompted, the default answer from the params is returned
+// or, if there is no default, an error.
+func handlePrompt(ctx context.Context, plugin Plugin, params *PromptParams) (PromptResult, error) {
+	name := plugin.Manifest().Name
+
+	slog.DebugContext(ctx, "plugin requested input", "plugin", name, "prompt", params.Prompt, "secret", params.Secret)
+
+	if !terminal.Interactive() {
+		if params.Default == "" || params.Secret {
+			return PromptResult{}, fmt.Errorf("%w: plugin %q asked %q", errNotInteractive, name, params.Prompt)
+		}
+
+		return PromptResult{Answer: params.Default}, nil
+	}
+
+	var (
+		answer string
+		err    error
+	)
+
+	if params.Secret {
+		answer, err = terminal.AskSecret(ctx, params.Prompt)
+	} else {
+		answer, err = terminal.Ask(ctx, params.Prompt)
+	}
+
+	if err != nil {
+		return PromptResult{}, fmt.Errorf("failed to prompt for plugin %q: %w", name, err)
+	}
+
+	if answer == "" {
+		answer = params.Default
+	}
+
+	return PromptResult{Answer: answer}, nil
+}
+
 // unmarshalAttr unmarshals a log attribute that was received from a plugin and
 // returns it as [slog.Attr].
 func unmarshalAttr(attr api.LogAttr) (slog.Attr, error) {
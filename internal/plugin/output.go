@@ -0,0 +1,179 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Default limits of the standard error output of the plugins.
+const (
+	// DefaultMaxLineLength is the default maximum length of a line that
+	// a plugin writes to its standard error output.
+	DefaultMaxLineLength ByteSize = 4 << 10
+
+	// DefaultLinesPerSecond is the default maximum number of lines that
+	// a plugin may write to its standard error output per second.
+	DefaultLinesPerSecond = 100
+)
+
+// errOutputLimits is returned when the output limits of a plugin are invalid.
+var errOutputLimits = errors.New("invalid output limits")
+
+// OutputLimits contains the limits of the standard error output of an external
+// plugin. The output is printed to the terminal or as the output of the task
+// that the plugin is running, so a plugin that floods it would flood
+// the terminal. The lines that are longer than the maximum length are
+// truncated, and the lines that exceed the rate are suppressed. The zero values
+// of the fields use the defaults.
+type OutputLimits struct {
+	// MaxLineLength is the maximum length of a line. The rest of a longer line
+	// is discarded.
+	MaxLineLength ByteSize `mapstructure:"max-line-length"`
+
+	// LinesPerSecond is the maximum number of lines per second. The lines
+	// over the limit are discarded, and the number of the discarded lines is
+	// printed when the output continues.
+	LinesPerSecond int `mapstructure:"lines-per-second"`
+}
+
+// A lineLimiter limits the rate of the lines of a plugin output. The lines are
+// counted in windows of one second.
+type lineLimiter struct {
+	start      time.Time // start of the current window
+	rate       int       // maximum number of lines in a window
+	lines      int       // number of lines in the current window
+	suppressed int       // number of lines suppressed since the last allowed line
+}
+
+// IsZero reports whether o uses the default limits.
+func (o OutputLimits) IsZero() bool {
+	return o.MaxLineLength == 0 && o.LinesPerSecond == 0
+}
+
+// check reports an error if o has invalid limits.
+func (o OutputLimits) check() error {
+	if o.LinesPerSecond < 0 {
+		return fmt.Errorf("%w: lines per second must not be negative: %d", errOutputLimits, o.LinesPerSecond)
+	}
+
+	return nil
+}
+
+// maxLineLength returns the maximum line length in bytes.
+func (o OutputLimits) maxLineLength() int {
+	if o.MaxLineLength == 0 {
+		return int(DefaultMaxLineLength)
+	}
+
+	return int(min(o.MaxLineLength, ByteSize(math.MaxInt)))
+}
+
+// linesPerSecond returns the maximum number of lines per second.
+func (o OutputLimits) linesPerSecond() int {
+	if o.LinesPerSecond == 0 {
+		return DefaultLinesPerSecond
+	}
+
+	return o.LinesPerSecond
+}
+
+// allow reports whether a line that is written at now may be printed. It also
+// reports whether the line is the first one to exceed the rate in the current
+// window.
+func (l *lineLimiter) allow(now time.Time) (bool, bool) {
+	if now.Sub(l.start) >= time.Second {
+		l.start = now
+		l.lines = 0
+	}
+
+	l.lines++
+
+	if l.lines > l.rate {
+		l.suppressed++
+
+		return false, l.lines == l.rate+1
+	}
+
+	return true, false
+}
+
+// flush returns the number of lines that have been suppressed since the last
+// allowed line and resets it.
+func (l *lineLimiter) flush() int {
+	n := l.suppressed
+	l.suppressed = 0
+
+	return n
+}
+
+// readLine reads the next line from r and returns it without the line ending.
+// If the line is longer than limit bytes, the rest of the line is discarded so
+// that reading it does not use more memory, and readLine returns the number of
+// the discarded bytes. The line is cut at a character boundary. It returns
+// [io.EOF] when there are no lines left.
+func readLine(r *bufio.Reader, limit int) (string, int, error) {
+	var (
+		buf   []byte
+		total int
+		err   error
+	)
+
+	for {
+		var chunk []byte
+
+		chunk, err = r.ReadSlice('\n')
+		total += len(chunk)
+		buf = append(buf, chunk[:min(max(limit-len(buf), 0), len(chunk))]...)
+
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			break
+		}
+	}
+
+	switch {
+	case errors.Is(err, io.EOF) && total == 0:
+		return "", 0, io.EOF
+	case err != nil && !errors.Is(err, io.EOF):
+		return "", 0, fmt.Errorf("%w", err)
+	}
+
+	// The line ending is not counted in the length of the line.
+	if err == nil {
+		total--
+
+		if len(buf) > total {
+			buf = buf[:total]
+		}
+	}
+
+	// A character that was cut in the middle is discarded as a whole.
+	for i := 0; i < utf8.UTFMax-1 && len(buf) < total; i++ {
+		if r, size := utf8.DecodeLastRune(buf); r != utf8.RuneError || size != 1 {
+			break
+		}
+
+		buf = buf[:len(buf)-1]
+	}
+
+	return strings.TrimSuffix(string(buf), "\r"), total - len(buf), nil
+}
@@ -24,13 +24,19 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"mime"
+	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
 	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/logger"
 	"github.com/reginald-project/reginald/internal/panichandler"
@@ -59,8 +65,18 @@ type Plugin interface {
 
 // A Service is the service function a built-in plugin. The method calls that
 // would be done through JSON-RPC to external plugins are made using the service
-// function when the plugin in question is built in.
-type Service func(ctx context.Context, store *Store, method string, params any) error
+// function when the plugin in question is built in. The function returns
+// the result of the method call or nil if the method has no result.
+type Service func(ctx context.Context, store *Store, method string, params any) (any, error)
+
+// A Downloader downloads the files that the external plugins request with
+// the "download" method and verifies their checksums.
+type Downloader interface {
+	// Download downloads the file from rawURL and returns the path to
+	// the downloaded file and its hexadecimal SHA-256 checksum. If sum is not
+	// empty, the file must match it.
+	Download(ctx context.Context, rawURL, sum string) (fspath.Path, string, error)
+}
 
 // A builtinPlugin is a built-in plugin provided by Reginald. It is implemented
 // within the program and it must not use an external executable.
@@ -83,6 +99,7 @@ type connection struct {
 	stdin  io.WriteCloser // stdin of the process
 	stdout io.ReadCloser  // stdout of the process
 	stderr io.ReadCloser  // stderr of the process
+	trace  *rpcTrace      // trace of the messages, nil if not tracing
 	mu     sync.Mutex     // serializes writing
 }
 
@@ -109,8 +126,111 @@ type externalPlugin struct {
 	// the protocol supports both strings and ints as the ID, we just default to
 	// ints to make the client more reasonable.
 	lastID atomic.Int64
+
+	// output is the output stream of the task that the plugin is currently
+	// running. While it is set, the standard error output of the plugin is
+	// written to it as the output of the task.
+	output atomic.Pointer[terminal.Stream]
+
+	// traceDir is the directory for the trace file of the messages sent to and
+	// received from the plugin. If it is empty, the messages are not traced.
+	traceDir fspath.Path
+
+	// logLevel is the minimum level of the log messages from the plugin that
+	// are logged. If it is nil, the level of the default logger is used.
+	logLevel *slog.Level
+
+	// dir is the plugin directory that contains the manifest.
+	dir fspath.Path
+
+	// dataDir is the directory for the persistent data of the plugin. If it is
+	// empty, the plugin has no data directory.
+	dataDir fspath.Path
+
+	// env is the environment of the current plugin process. It is replaced
+	// every time the process is started.
+	env *environment
+
+	// exit holds the result of the current plugin process after it has
+	// exited. It is replaced every time the process is started.
+	exit atomic.Pointer[processExit]
+
+	// sandbox contains the restrictions for the plugin process.
+	sandbox Sandbox
+
+	// outputLimits contains the limits of the standard error output of
+	// the plugin process.
+	outputLimits OutputLimits
+
+	// protocolErrors is the number of times the plugin has violated
+	// the protocol or the limits of its output.
+	protocolErrors atomic.Int64
+
+	// maxProtocolErrors is the number of protocol errors after which
+	// the plugin is quarantined. If it is zero, the plugin is quarantined only
+	// for the errors after which its messages cannot be read anymore.
+	maxProtocolErrors int64
+
+	// maxMessageSize is the maximum length of the content of a message that
+	// the plugin sends in bytes.
+	maxMessageSize int
+
+	// quarantined is set when the plugin has been quarantined for its protocol
+	// errors. A quarantined plugin is killed and it cannot be used again
+	// during the run.
+	quarantined atomic.Bool
+
+	// downloader downloads the files that the plugin requests. If it is nil,
+	// the plugin cannot download files through the client.
+	downloader Downloader
+
+	// lastActive is the time in Unix nanoseconds when the last message was
+	// received from the plugin.
+	lastActive atomic.Int64
+
+	// group contains the current plugin process and the processes it starts
+	// so that they can be ended together. It is replaced every time
+	// the process is started.
+	group *processGroup
+
+	// shutdownTimeout is the time that the plugin has for shutting down
+	// gracefully before its process is terminated. If it is zero,
+	// [DefaultShutdownTimeout] is used.
+	shutdownTimeout time.Duration
+
+	// alive is set when the handshake with the plugin has succeeded and unset
+	// when the plugin is shut down or killed.
+	alive atomic.Bool
+
+	// hung is set when the plugin has not responded to a ping in time.
+	hung atomic.Bool
+
+	// caps contains the capabilities that the plugin reported in
+	// the handshake.
+	caps Capabilities
+
+	// options contains the entry options of the config entries in
+	// the manifest. It is nil if the manifest has no entry options. See
+	// [stripEntryOptions].
+	options *manifestOptions
 }
 
+// A batchCall is a single method call in a batch.
+type batchCall struct {
+	// params are the params of the call.
+	params any
+
+	// result is the value that the result of the call is unmarshaled into.
+	result any
+
+	// method is the method to call.
+	method string
+}
+
+// closedRequests is the number of the recently closed requests that
+// a responseQueue remembers for recognizing the late and duplicate responses.
+const closedRequests = 256
+
 // A responseQueue holds channels that transfer responses sent from the plugins
 // and read by the plugin's reading loop to the plugin's call function. While
 // not technically a queue, the name feels natural.
@@ -118,6 +238,24 @@ type responseQueue struct {
 	// q holds channels waiting for responses from the plugins.
 	q map[string]chan api.Response
 
+	// delivered contains the keys of the requests in q that have already
+	// received their response.
+	delivered map[string]struct{}
+
+	// partial contains the elements of the partial results of the requests in
+	// q that have not received their response yet.
+	partial map[string][]json.RawMessage
+
+	// closed contains the keys of the recently closed requests and whether
+	// their responses were delivered before they were closed. It is used for
+	// telling the late and duplicate responses apart from the responses with
+	// unknown IDs. At most [closedRequests] keys are kept.
+	closed map[string]bool
+
+	// closedOrder contains the keys in closed in the order they were added so
+	// that the oldest key can be removed.
+	closedOrder []string
+
 	// mu locks the queue.
 	mu sync.Mutex
 }
@@ -133,6 +271,19 @@ type rpcMessage struct {
 	Result  json.RawMessage `json:"result,omitempty"`
 }
 
+// An rpcResponse is a response that the client sends to a request from
+// a plugin. The ID is encoded by the client as [api.ID] does not quote string
+// identifiers when it is encoded.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *api.Error      `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+
+	// secret tells that the result contains secret input from the user.
+	secret bool
+}
+
 // External reports whether the plugin is not built-in.
 func (*builtinPlugin) External() bool {
 	return false
@@ -143,20 +294,21 @@ func (b *builtinPlugin) Manifest() *api.Manifest {
 	return b.manifest
 }
 
-// Close closes the standard streams attached to the connection.
+// Close closes the standard streams attached to the connection. The streams
+// that have already been closed when the process exited are skipped.
 func (c *connection) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if err := c.stderr.Close(); err != nil {
+	if err := c.stderr.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 		return fmt.Errorf("failed to close connection stderr: %w", err)
 	}
 
-	if err := c.stdin.Close(); err != nil {
+	if err := c.stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 		return fmt.Errorf("failed to close connection stdin: %w", err)
 	}
 
-	if err := c.stdout.Close(); err != nil {
+	if err := c.stdout.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 		return fmt.Errorf("failed to close connection stdout: %w", err)
 	}
 
@@ -206,30 +358,42 @@ func (b *builtinPlugin) call(ctx context.Context, method string, params, result
 
 	switch method {
 	case api.MethodHandshake:
-		handshakeResult, ok := result.(*api.HandshakeResult)
+		hr, ok := result.(*handshakeResult)
 		if !ok {
 			panic(fmt.Sprintf("invalid result type for method %q: %[2]T (%[2]v)", method, result))
 		}
 
-		*handshakeResult = api.HandshakeResult{
-			Name: b.manifest.Name,
-			Handshake: api.Handshake{
-				Protocol:        "reginald",
-				ProtocolVersion: 0,
+		*hr = handshakeResult{
+			HandshakeResult: api.HandshakeResult{
+				Name: b.manifest.Name,
+				Handshake: api.Handshake{
+					Protocol:        api.Protocol,
+					ProtocolVersion: maxProtocolVersion,
+				},
+			},
+			// The built-in plugins that cannot run their tasks elevated
+			// reject the elevated tasks when they are run.
+			Capabilities: Capabilities{
+				Diff:    true,
+				Elevate: true,
 			},
 		}
 	case api.MethodRunCommand, api.MethodRunTask:
-		err := b.service(ctx, b.store, method, params)
+		res, err := b.service(ctx, b.store, method, params)
 		if err != nil {
 			return fmt.Errorf("failed to run method %q from %q: %w", method, b.manifest.Name, err)
 		}
 
-		runResult, ok := result.(*struct{})
-		if !ok {
-			panic(fmt.Sprintf("invalid result type for method %q: %[2]T (%[2]v)", method, result))
+		// The result is passed through JSON so that the built-in plugins are
+		// handled the same way as the external ones.
+		data, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result from %q: %w", b.manifest.Name, err)
 		}
 
-		*runResult = struct{}{}
+		if err = json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to unmarshal result from %q: %w", b.manifest.Name, err)
+		}
 	default:
 		panic("invalid method call: " + method)
 	}
@@ -263,6 +427,10 @@ func (b *builtinPlugin) start(ctx context.Context) error {
 // the method call is successful. Otherwise, it returns any error that occurred
 // or was returned in response.
 func (e *externalPlugin) call(ctx context.Context, method string, params, result any) error {
+	if e.quarantined.Load() {
+		return e.quarantineError()
+	}
+
 	id := e.lastID.Add(1)
 
 	rpcID, err := api.NewID(id)
@@ -283,7 +451,7 @@ func (e *externalPlugin) call(ctx context.Context, method string, params, result
 	}
 
 	slog.Log(ctx, slog.Level(logger.LevelTrace), "calling method", "plugin", e.manifest.Name, "req", req)
-	e.queue.add(rpcID)
+	resCh := e.queue.add(rpcID)
 	defer e.queue.close(rpcID)
 
 	err = write(ctx, e.conn, req)
@@ -292,15 +460,15 @@ func (e *externalPlugin) call(ctx context.Context, method string, params, result
 	}
 
 	select {
-	case res, ok := <-e.queue.channel(rpcID):
+	case res, ok := <-resCh:
 		if !ok {
-			return fmt.Errorf("%w: plugin %q (method %q)", errNoResponse, e.manifest.Name, method)
+			return e.noResponse(method)
 		}
 
 		slog.Log(ctx, slog.Level(logger.LevelTrace), "response received", "plugin", e.manifest.Name, "res", res)
 
 		if res.Error != nil {
-			return fmt.Errorf("plugin returned an error: %w", res.Error)
+			return newError(res.Error)
 		}
 
 		if err := json.Unmarshal(res.Result, result); err != nil {
@@ -317,18 +485,117 @@ func (e *externalPlugin) call(ctx context.Context, method string, params, result
 			method,
 		)
 	case <-ctx.Done():
+		e.cancel(ctx, rpcID)
+
 		return fmt.Errorf("method call halted: %w", ctx.Err())
 	}
 
 	return nil
 }
 
-// kill kills the plugin process.
+// callBatch calls multiple methods in the plugin with a single batch message.
+// The plugin must support batches. The results of the successful calls are
+// unmarshaled into the results of the calls, and the errors of the calls are
+// returned in the same order as the calls. The returned error is set only if
+// the batch could not be sent or the call was halted.
+func (e *externalPlugin) callBatch(ctx context.Context, calls []batchCall) ([]error, error) {
+	if e.quarantined.Load() {
+		return nil, e.quarantineError()
+	}
+
+	reqs := make([]api.Request, len(calls))
+	chans := make([]chan api.Response, len(calls))
+
+	defer func() {
+		for _, req := range reqs {
+			if req.ID != nil {
+				e.queue.close(req.ID)
+			}
+		}
+	}()
+
+	for i, c := range calls {
+		rpcID, err := api.NewID(e.lastID.Add(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ID: %w", err)
+		}
+
+		rawParams, err := json.Marshal(c.params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal params: %w", err)
+		}
+
+		reqs[i] = api.Request{
+			JSONRPC: api.JSONRPCVersion,
+			ID:      rpcID,
+			Method:  c.method,
+			Params:  rawParams,
+		}
+
+		chans[i] = e.queue.add(rpcID)
+	}
+
+	slog.Log(ctx, slog.Level(logger.LevelTrace), "calling batch", "plugin", e.manifest.Name, "reqs", reqs)
+
+	if err := write(ctx, e.conn, reqs); err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(calls))
+
+	for i, req := range reqs {
+		select {
+		case res, ok := <-chans[i]:
+			switch {
+			case !ok:
+				errs[i] = e.noResponse(req.Method)
+			case res.Error != nil:
+				errs[i] = newError(res.Error)
+			default:
+				if err := json.Unmarshal(res.Result, calls[i].result); err != nil {
+					errs[i] = fmt.Errorf("failed to unmarshal result: %w", err)
+				}
+			}
+		case <-ctx.Done():
+			ids := make([]*api.ID, 0, len(reqs)-i)
+			for _, r := range reqs[i:] {
+				ids = append(ids, r.ID)
+			}
+
+			e.cancel(ctx, ids...)
+
+			return nil, fmt.Errorf("method call halted: %w", ctx.Err())
+		}
+	}
+
+	return errs, nil
+}
+
+// cancel sends the "cancel" notification for the requests with the given IDs
+// if the plugin supports it. The notifications are sent even if ctx is already
+// canceled as the client is no longer waiting for the responses.
+func (e *externalPlugin) cancel(ctx context.Context, ids ...*api.ID) {
+	if !e.caps.Cancel {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	for _, id := range ids {
+		if err := e.notify(ctx, methodCancel, cancelParams{ID: encodeID(id)}); err != nil {
+			slog.WarnContext(ctx, "failed to cancel request", "plugin", e.manifest.Name, "id", idToKey(id), "err", err)
+		}
+	}
+}
+
+// kill kills the plugin process and the processes it has started.
 func (e *externalPlugin) kill(ctx context.Context) error {
+	e.alive.Store(false)
+
 	if e.cmd.Process != nil {
 		slog.WarnContext(ctx, "killing process", "plugin", e.manifest.Name)
 
-		if err := e.cmd.Process.Kill(); err != nil {
+		if err := e.group.kill(e.cmd.Process); err != nil && !errors.Is(err, os.ErrProcessDone) {
 			return fmt.Errorf("failed to kill process for plugin %q: %w", e.manifest.Name, err)
 		}
 	}
@@ -342,23 +609,233 @@ func (e *externalPlugin) kill(ctx context.Context) error {
 	return nil
 }
 
+// terminate asks the plugin process and the processes it has started to exit by
+// sending them the termination signal. It reports whether the signal was sent.
+// The signal cannot be sent on Windows so the processes can only be killed
+// there.
+func (e *externalPlugin) terminate(ctx context.Context) bool {
+	if e.cmd.Process == nil {
+		return false
+	}
+
+	slog.WarnContext(ctx, "terminating process", "plugin", e.manifest.Name)
+
+	if err := e.group.terminate(e.cmd.Process); err != nil {
+		slog.DebugContext(ctx, "failed to terminate process", "plugin", e.manifest.Name, "err", err)
+
+		return false
+	}
+
+	return true
+}
+
+// protocolError records a violation of the protocol or the output limits by
+// the plugin and logs it with msg and args. The plugin is quarantined when it
+// reaches the maximum number of protocol errors.
+func (e *externalPlugin) protocolError(ctx context.Context, msg string, args ...any) {
+	n := e.protocolErrors.Add(1)
+
+	slog.WarnContext(ctx, msg, append([]any{"plugin", e.manifest.Name, "protocolErrors", n}, args...)...)
+
+	if e.maxProtocolErrors > 0 && n >= e.maxProtocolErrors {
+		e.quarantine(ctx)
+	}
+}
+
+// fatalProtocolError records a violation of the protocol after which
+// the messages from the plugin cannot be read anymore, logs it with msg and
+// args, and quarantines the plugin.
+func (e *externalPlugin) fatalProtocolError(ctx context.Context, msg string, args ...any) {
+	n := e.protocolErrors.Add(1)
+
+	slog.ErrorContext(ctx, msg, append([]any{"plugin", e.manifest.Name, "protocolErrors", n}, args...)...)
+	e.quarantine(ctx)
+}
+
+// quarantine kills the plugin for its protocol errors and marks it as failed
+// so that it is not used again during the run.
+func (e *externalPlugin) quarantine(ctx context.Context) {
+	if e.quarantined.Swap(true) {
+		return
+	}
+
+	n := e.protocolErrors.Load()
+
+	slog.ErrorContext(ctx, "quarantining plugin", "plugin", e.manifest.Name, "protocolErrors", n)
+	msg := fmt.Sprintf("Plugin %q was quarantined after %s", e.manifest.Name, protocolErrorCount(n))
+	diag.Report(ctx, diag.PluginQuarantined, msg)
+
+	if err := e.kill(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to kill quarantined plugin", "plugin", e.manifest.Name, "err", err)
+	}
+}
+
+// quarantineError returns the error for using the plugin after it has been
+// quarantined.
+func (e *externalPlugin) quarantineError() error {
+	n := e.protocolErrors.Load()
+
+	return fmt.Errorf("plugin %q %w after %s", e.manifest.Name, errQuarantined, protocolErrorCount(n))
+}
+
+// protocolErrorCount returns the given number of protocol errors as text.
+func protocolErrorCount(n int64) string {
+	if n == 1 {
+		return "1 protocol error"
+	}
+
+	return fmt.Sprintf("%d protocol errors", n)
+}
+
+// noResponse returns the error for a method call that got no response from
+// the plugin. If the plugin process exited because it violated its sandbox,
+// the violation is returned instead. The process exits a moment after its
+// connection closes, so its exit is waited for briefly.
+func (e *externalPlugin) noResponse(method string) error {
+	if e.quarantined.Load() {
+		return e.quarantineError()
+	}
+
+	err := fmt.Errorf("%w: plugin %q (method %q)", errNoResponse, e.manifest.Name, method)
+
+	exit := e.exit.Load()
+	if exit == nil || e.sandbox.IsZero() {
+		return err
+	}
+
+	select {
+	case <-exit.done:
+		if exit.err != nil {
+			return fmt.Errorf("plugin %q (method %q): %w", e.manifest.Name, method, exit.err)
+		}
+	case <-time.After(exitWait):
+	}
+
+	return err
+}
+
 // notification handles a notification request sent from the plugin.
 func (e *externalPlugin) notification(ctx context.Context, req api.Request) error {
 	switch req.Method {
 	case api.MethodLog:
-		var params api.LogParams
+		var params logParams
 		if err := json.Unmarshal(req.Params, &params); err != nil {
 			return fmt.Errorf("failed to unmarshal log params: %w", err)
 		}
 
 		return handleLog(ctx, e, &params)
+	case methodPartialResult:
+		var params partialResultParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal partial result params: %w", err)
+		}
+
+		return handlePartialResult(ctx, e, &params)
+	case methodProgress:
+		var params progressParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal progress params: %w", err)
+		}
+
+		handleProgress(ctx, e, &params)
+
+		return nil
 	default:
 		return fmt.Errorf("%w: %s", errUnknownMethod, req.Method)
 	}
 }
 
+// request handles a request sent by the plugin to the client and writes
+// the response back to the plugin.
+func (e *externalPlugin) request(ctx context.Context, req api.Request) {
+	var (
+		result any
+		err    error
+		secret bool
+		code   = api.CodeInternalError
+	)
+
+	switch req.Method {
+	case methodConfirm:
+		var params ConfirmParams
+		if err = json.Unmarshal(req.Params, &params); err != nil {
+			code = api.CodeInvalidParams
+			err = fmt.Errorf("failed to unmarshal confirm params: %w", err)
+
+			break
+		}
+
+		result, err = handleConfirm(ctx, e, &params)
+	case methodDownload:
+		var params DownloadParams
+		if err = json.Unmarshal(req.Params, &params); err != nil {
+			code = api.CodeInvalidParams
+			err = fmt.Errorf("failed to unmarshal download params: %w", err)
+
+			break
+		}
+
+		if params.URL == "" {
+			code = api.CodeInvalidParams
+			err = fmt.Errorf("%w in download params", errNoURL)
+
+			break
+		}
+
+		result, err = handleDownload(ctx, e, &params)
+	case methodPrompt:
+		var params PromptParams
+		if err = json.Unmarshal(req.Params, &params); err != nil {
+			code = api.CodeInvalidParams
+			err = fmt.Errorf("failed to unmarshal prompt params: %w", err)
+
+			break
+		}
+
+		secret = params.Secret
+		result, err = handlePrompt(ctx, e, &params)
+	default:
+		code = api.CodeMethodNotFound
+		err = fmt.Errorf("%w: %s", errUnknownMethod, req.Method)
+	}
+
+	res := rpcResponse{
+		JSONRPC: api.JSONRPCVersion,
+		ID:      encodeID(req.ID),
+		Error:   nil,
+		Result:  nil,
+		secret:  secret,
+	}
+
+	if err == nil {
+		res.Result, err = json.Marshal(result)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to handle request from plugin", "plugin", e.manifest.Name, "err", err)
+
+		res.Result = nil
+		res.Error = &api.Error{
+			Data:    nil,
+			Message: err.Error(),
+			Code:    code,
+		}
+	}
+
+	// The result is not logged as it may contain secret input.
+	slog.Log(ctx, slog.Level(logger.LevelTrace), "sending response", "plugin", e.manifest.Name, "method", req.Method)
+
+	if err := write(ctx, e.conn, res); err != nil {
+		slog.ErrorContext(ctx, "failed to write response", "plugin", e.manifest.Name, "err", err)
+	}
+}
+
 // notify sends a notification request to the plugin.
 func (e *externalPlugin) notify(ctx context.Context, method string, params any) error {
+	if e.quarantined.Load() {
+		return e.quarantineError()
+	}
+
 	rawParams, err := json.Marshal(params)
 	if err != nil {
 		return fmt.Errorf("failed to marshal params: %w", err)
@@ -383,93 +860,163 @@ func (e *externalPlugin) read(ctx context.Context, handlePanic func()) {
 	defer handlePanic()
 	defer e.queue.closeAll()
 
-	reader := bufio.NewReader(e.conn)
-	done := false
+	conn, ok := e.conn.(*connection)
+	if !ok {
+		panic(fmt.Sprintf("connection for plugin %q is not *connection", e.manifest.Name))
+	}
 
-	go func() {
-		<-ctx.Done()
+	reader := bufio.NewReader(conn)
 
-		done = true
-	}()
+	for ctx.Err() == nil {
+		msgs, data, err := read(reader, e.maxMessageSize)
+		if data != nil {
+			conn.trace.frame(traceRecv, data)
+		}
 
-	for !done {
-		msg, err := read(reader)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				return
 			}
 
-			slog.ErrorContext(ctx, "error reading from plugin", "plugin", e.manifest.Name, "err", err)
+			// Repeated invalid messages quarantine the plugin.
+			if skippable(err) {
+				e.protocolError(ctx, "skipping invalid message", "err", err)
 
-			return
-		}
+				continue
+			}
 
-		if msg.JSONRCP != api.JSONRPCVersion {
-			slog.ErrorContext(ctx, "invalid JSON-RPC version", "plugin", e.manifest.Name, "got", msg.JSONRCP)
+			// The connection is closed when the plugin is killed.
+			if !errors.Is(err, os.ErrClosed) {
+				e.fatalProtocolError(ctx, "error reading from plugin", "err", err)
+			}
 
 			return
 		}
 
-		if msg.ID == nil || msg.ID.Null {
-			switch {
-			case msg.Method == "":
-				slog.ErrorContext(ctx, "no method in notification", "plugin", e.manifest.Name, "rpcMsg", msg)
-
-				return
-			case msg.Error != nil:
-				slog.ErrorContext(ctx, "error in notification", "plugin", e.manifest.Name, "rpcMsg", msg)
+		for _, msg := range msgs {
+			e.handle(ctx, msg)
+		}
+	}
+}
 
-				return
-			case len(msg.Result) > 0:
-				slog.ErrorContext(ctx, "result in notification", "plugin", e.manifest.Name, "rpcMsg", msg)
+// handle handles a single message read from the plugin. The messages that
+// violate the protocol are recorded as protocol errors and skipped so that
+// the rest of the messages are still handled.
+func (e *externalPlugin) handle(ctx context.Context, msg *rpcMessage) {
+	e.lastActive.Store(time.Now().UnixNano())
 
-				return
-			}
+	if msg.JSONRCP != api.JSONRPCVersion {
+		e.invalidRequest(ctx, msg, "invalid JSON-RPC version", "got", msg.JSONRCP)
 
-			req := api.Request{
-				JSONRPC: msg.JSONRCP,
-				ID:      nil,
-				Method:  msg.Method,
-				Params:  msg.Params,
-			}
+		return
+	}
 
-			slog.Log(ctx, slog.Level(logger.LevelTrace), "notification received", "plugin", e.manifest.Name, "req", req)
+	if msg.ID == nil || msg.ID.Null {
+		switch {
+		case msg.Method == "":
+			e.protocolError(ctx, "no method in notification", "rpcMsg", msg)
 
-			if err := e.notification(ctx, req); err != nil {
-				slog.ErrorContext(ctx, "error handling notification", "plugin", e.manifest.Name, "err", err)
+			return
+		case msg.Error != nil:
+			e.protocolError(ctx, "error in notification", "rpcMsg", msg)
 
-				return
-			}
+			return
+		case len(msg.Result) > 0:
+			e.protocolError(ctx, "result in notification", "rpcMsg", msg)
 
-			continue
+			return
 		}
 
-		switch {
-		case msg.Method != "":
-			slog.ErrorContext(ctx, "method in response", "plugin", e.manifest.Name, "rpcMsg", msg)
+		req := api.Request{
+			JSONRPC: msg.JSONRCP,
+			ID:      nil,
+			Method:  msg.Method,
+			Params:  msg.Params,
+		}
 
-			return
-		case msg.Params != nil:
-			slog.ErrorContext(ctx, "params in response", "plugin", e.manifest.Name, "rpcMsg", msg)
+		slog.Log(ctx, slog.Level(logger.LevelTrace), "notification received", "plugin", e.manifest.Name, "req", req)
 
-			return
+		if err := e.notification(ctx, req); err != nil {
+			e.protocolError(ctx, "error handling notification", "err", err)
 		}
 
-		ch := e.queue.channel(msg.ID)
-		if ch == nil {
-			slog.ErrorContext(ctx, "response with no corresponding ID", "plugin", e.manifest.Name, "rpcMsg", msg)
+		return
+	}
+
+	if msg.Method != "" {
+		if msg.Error != nil || len(msg.Result) > 0 {
+			e.invalidRequest(ctx, msg, "result or error in request", "rpcMsg", msg)
 
 			return
 		}
 
-		res := api.Response{
+		req := api.Request{
 			JSONRPC: msg.JSONRCP,
-			ID:      *msg.ID,
-			Error:   msg.Error,
-			Result:  msg.Result,
+			ID:      msg.ID,
+			Method:  msg.Method,
+			Params:  msg.Params,
 		}
 
-		ch <- res
+		slog.Log(ctx, slog.Level(logger.LevelTrace), "request received", "plugin", e.manifest.Name, "req", req)
+
+		// Requests from the plugin may wait for user input so they are
+		// handled separately to keep the read loop running.
+		go e.request(ctx, req)
+
+		return
+	}
+
+	if msg.Params != nil {
+		e.protocolError(ctx, "params in response", "rpcMsg", msg)
+
+		return
+	}
+
+	res := api.Response{
+		JSONRPC: msg.JSONRCP,
+		ID:      *msg.ID,
+		Error:   msg.Error,
+		Result:  msg.Result,
+	}
+
+	err := e.queue.deliver(res)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errLateResponse):
+		// The plugin may respond to a request after the client has stopped
+		// waiting for it, and the response is ignored.
+		slog.DebugContext(ctx, "ignoring response to closed request", "plugin", e.manifest.Name, "err", err)
+	default:
+		e.protocolError(ctx, "invalid response", "err", err, "rpcMsg", msg)
+	}
+}
+
+// invalidRequest records the invalid message from the plugin as a protocol
+// error and logs it with msg and args. If the message is a request that has
+// an ID, the plugin waits for a response so an error response is written back
+// to it.
+func (e *externalPlugin) invalidRequest(ctx context.Context, rpcMsg *rpcMessage, msg string, args ...any) {
+	e.protocolError(ctx, msg, args...)
+
+	if rpcMsg.Method == "" || rpcMsg.ID == nil || rpcMsg.ID.Null || e.quarantined.Load() {
+		return
+	}
+
+	res := rpcResponse{
+		JSONRPC: api.JSONRPCVersion,
+		ID:      encodeID(rpcMsg.ID),
+		Error: &api.Error{
+			Data:    nil,
+			Message: msg,
+			Code:    api.CodeInvalidRequest,
+		},
+		Result: nil,
+		secret: false,
+	}
+
+	if err := write(ctx, e.conn, res); err != nil {
+		slog.ErrorContext(ctx, "failed to write response", "plugin", e.manifest.Name, "err", err)
 	}
 }
 
@@ -484,21 +1031,65 @@ func (e *externalPlugin) readStderr(ctx context.Context, handlePanic func()) {
 		panic(fmt.Sprintf("connection for plugin %q is not *connection", e.manifest.Name))
 	}
 
-	scanner := bufio.NewScanner(conn.stderr)
+	reader := bufio.NewReader(conn.stderr)
+	limiter := &lineLimiter{start: time.Time{}, rate: e.outputLimits.linesPerSecond(), lines: 0, suppressed: 0}
+
+	for {
+		line, dropped, err := readLine(reader, e.outputLimits.maxLineLength())
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.WarnContext(ctx, "error reading plugin stderr", "plugin", e.manifest.Name, "err", err)
+			}
+
+			break
+		}
+
+		if dropped > 0 {
+			e.protocolError(ctx, "plugin printed a line that is too long to stderr", "truncated", dropped)
+
+			line += fmt.Sprintf(" [truncated %d bytes]", dropped)
+		}
+
+		ok, exceeded := limiter.allow(time.Now())
+		if exceeded {
+			e.protocolError(ctx, "plugin exceeded the output rate", "rate", limiter.rate)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if n := limiter.flush(); n > 0 {
+			e.writeStderr(ctx, fmt.Sprintf("[%d lines suppressed]", n))
+		}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+		e.writeStderr(ctx, line)
+	}
 
-		slog.WarnContext(ctx, "plugin printed to stderr", "plugin", e.manifest.Name, "output", line)
-		terminal.Errorf("[%s] %s\n", e.manifest.Name, line)
+	if n := limiter.flush(); n > 0 {
+		e.writeStderr(ctx, fmt.Sprintf("[%d lines suppressed]", n))
 	}
+}
+
+// writeStderr writes a line that the plugin printed to its standard error
+// output. It is written as the output of the task that the plugin is running
+// or, if there is no such task, to the terminal.
+func (e *externalPlugin) writeStderr(ctx context.Context, line string) {
+	if out := e.output.Load(); out != nil {
+		slog.DebugContext(ctx, "task output from plugin", "plugin", e.manifest.Name, "output", line)
+		out.WriteLine(line)
 
-	if err := scanner.Err(); err != nil {
-		slog.WarnContext(ctx, "error reading plugin stderr", "plugin", e.manifest.Name, "err", err)
+		return
 	}
+
+	slog.WarnContext(ctx, "plugin printed to stderr", "plugin", e.manifest.Name, "output", line)
+	terminal.Errorf("[%s] %s\n", e.manifest.Name, line)
 }
 
-// start starts the execution of the plugin process.
+// start starts the execution of the plugin process. The process is run in
+// the plugin directory with the environment variables of Reginald removed and
+// the variables of the plugin environment added, unless the sandbox of
+// the plugin restricts them further.
 func (e *externalPlugin) start(ctx context.Context) error {
 	m := e.manifest
 
@@ -516,6 +1107,55 @@ func (e *externalPlugin) start(ctx context.Context) error {
 
 	// TODO: Add the mode for executing only trusted plugins.
 	c := exec.CommandContext(ctx, string(exe.Clean())) // #nosec G204 -- sanitized earlier
+	c.Dir = string(e.dir)
+
+	if err := e.sandbox.apply(c); err != nil {
+		return fmt.Errorf("failed to set up sandbox for %q: %w", m.Name, err)
+	}
+
+	// The process group is set up after the sandbox as the sandbox may replace
+	// the process attributes.
+	group, err := newProcessGroup(c)
+	if err != nil {
+		return fmt.Errorf("failed to set up process group for %q: %w", m.Name, err)
+	}
+
+	c.Cancel = func() error {
+		return group.kill(c.Process)
+	}
+
+	e.group = group
+
+	env, err := newEnvironment(ctx, e)
+	if err != nil {
+		_ = group.close()
+
+		return err
+	}
+
+	if c.Env == nil {
+		c.Env = os.Environ()
+	}
+
+	c.Env = env.environ(c.Env)
+
+	if err = e.startProcess(ctx, c, env); err != nil {
+		env.remove(ctx)
+
+		_ = group.close()
+
+		return err
+	}
+
+	return nil
+}
+
+// startProcess starts the plugin process for the command c that has
+// the environment env and starts the goroutines that read from and wait for
+// the process.
+func (e *externalPlugin) startProcess(ctx context.Context, c *exec.Cmd, env *environment) error {
+	m := e.manifest
+	exe := c.Path
 
 	stdin, err := c.StdinPipe()
 	if err != nil {
@@ -533,17 +1173,56 @@ func (e *externalPlugin) start(ctx context.Context) error {
 		return fmt.Errorf("failed to create stderr pipe for %s: %w", exe, err)
 	}
 
+	var trace *rpcTrace
+
+	if e.traceDir != "" {
+		// Tracing is only a debugging aid so the plugin is run even if
+		// the trace cannot be opened.
+		if trace, err = openTrace(e.traceDir, m.Name); err != nil {
+			slog.WarnContext(ctx, "failed to open RPC trace", "plugin", m.Name, "err", err)
+
+			trace = nil
+		}
+	}
+
 	conn := &connection{
 		mu:     sync.Mutex{},
 		stderr: stderr,
 		stdin:  stdin,
 		stdout: stdout,
+		trace:  trace,
 	}
 	e.conn = conn
 	e.cmd = c
+	e.env = env
 
 	if err = e.cmd.Start(); err != nil {
-		return fmt.Errorf("execution of %q (%s) failed: %w", m.Name, e.cmd.Path, err)
+		return fmt.Errorf("execution of %q (%s) failed: %w", m.Name, e.cmd.Path, e.sandbox.startError(err))
+	}
+
+	exit := &processExit{err: nil, done: make(chan struct{})}
+	e.exit.Store(exit)
+
+	// If the process cannot be added to the group, only the process itself is
+	// killed when the plugin is killed.
+	if err = e.group.attach(e.cmd.Process); err != nil {
+		slog.WarnContext(ctx, "failed to add plugin to process group", "plugin", m.Name, "err", err)
+	}
+
+	// The process runs for a moment before the limits are set as they can only
+	// be set for a running process.
+	if err = e.sandbox.limit(e.cmd.Process.Pid); err != nil {
+		if killErr := e.group.kill(e.cmd.Process); killErr != nil {
+			slog.WarnContext(ctx, "failed to kill plugin", "plugin", m.Name, "err", killErr)
+		}
+
+		_ = e.cmd.Wait() // the error is the one from killing the process
+		_ = trace.Close()
+
+		e.cmd = nil
+		e.conn = nil
+
+		return fmt.Errorf("failed to limit resources of %q: %w", m.Name, err)
 	}
 
 	handlePanic := panichandler.WithStackTrace()
@@ -551,16 +1230,65 @@ func (e *externalPlugin) start(ctx context.Context) error {
 	go e.read(ctx, handlePanic)
 	go e.readStderr(ctx, handlePanic)
 
+	// The channel and the command are captured as they are replaced when
+	// the plugin is restarted.
+	doneCh := e.doneCh
+	cmd := e.cmd
+	group := e.group
+
 	go func() {
 		defer handlePanic()
-		e.doneCh <- e.cmd.Wait()
-		close(e.doneCh)
+		err := cmd.Wait()
+
+		// The violation of the sandbox is reported instead of the exit status
+		// as it tells why the process exited.
+		if exit.err = e.sandbox.exitError(cmd.ProcessState); exit.err != nil {
+			slog.ErrorContext(ctx, "plugin violated its sandbox", "plugin", m.Name, "err", exit.err)
+
+			err = exit.err
+		}
+
+		// The processes that the plugin started are ended with it so that they
+		// do not outlive the plugin.
+		if killErr := group.kill(cmd.Process); killErr != nil && !errors.Is(killErr, os.ErrProcessDone) {
+			slog.WarnContext(ctx, "failed to end child processes of plugin", "plugin", m.Name, "err", killErr)
+		}
+
+		if closeErr := group.close(); closeErr != nil {
+			slog.WarnContext(ctx, "failed to close process group", "plugin", m.Name, "err", closeErr)
+		}
+
+		close(exit.done)
+		env.remove(ctx)
+
+		if closeErr := trace.Close(); closeErr != nil {
+			slog.WarnContext(ctx, "failed to close RPC trace", "plugin", m.Name, "err", closeErr)
+		}
+
+		doneCh <- err
+		close(doneCh)
 	}()
 
 	return nil
 }
 
-func (q *responseQueue) add(id *api.ID) {
+// newResponseQueue returns a new empty responseQueue.
+func newResponseQueue() *responseQueue {
+	return &responseQueue{
+		q:           make(map[string]chan api.Response),
+		delivered:   make(map[string]struct{}),
+		partial:     make(map[string][]json.RawMessage),
+		closed:      make(map[string]bool),
+		closedOrder: nil,
+		mu:          sync.Mutex{},
+	}
+}
+
+// add adds a request with the given ID to the queue and returns the channel
+// that receives its response. The channel is closed if the request is closed
+// or the plugin stops before the response arrives, so the caller should hold
+// on to the returned channel instead of looking it up again.
+func (q *responseQueue) add(id *api.ID) chan api.Response {
 	if q.q == nil {
 		panic("adding to nil responseQueue")
 	}
@@ -571,9 +1299,18 @@ func (q *responseQueue) add(id *api.ID) {
 	defer q.mu.Unlock()
 
 	q.q[idToKey(id)] = ch
+
+	return ch
 }
 
-func (q *responseQueue) channel(id *api.ID) chan api.Response {
+// close closes the channel matching the given ID and deletes the entry from
+// the queue. The ID is remembered for a while so that a response that arrives
+// after closing the request can be recognized.
+func (q *responseQueue) close(id *api.ID) {
+	if q.q == nil {
+		panic("closing in nil responseQueue")
+	}
+
 	key := idToKey(id)
 
 	q.mu.Lock()
@@ -581,31 +1318,172 @@ func (q *responseQueue) channel(id *api.ID) chan api.Response {
 
 	ch, ok := q.q[key]
 	if !ok {
-		return nil
+		return
 	}
 
-	return ch
+	close(ch)
+	delete(q.q, key)
+
+	_, delivered := q.delivered[key]
+	delete(q.delivered, key)
+	delete(q.partial, key)
+
+	if len(q.closedOrder) >= closedRequests {
+		delete(q.closed, q.closedOrder[0])
+		q.closedOrder = q.closedOrder[1:]
+	}
+
+	q.closed[key] = delivered
+	q.closedOrder = append(q.closedOrder, key)
 }
 
-// close closes the channel matching the given ID and deletes the entry from
-// the queue.
-func (q *responseQueue) close(id *api.ID) {
-	if q.q == nil {
-		panic("closing in nil responseQueue")
+// deliver passes the response res to the request with the same ID. It returns
+// an error if the response cannot be delivered: the ID is not an integer as
+// the client only uses integer IDs, the request has already received
+// a response, the request has been closed before it received a response, or
+// there is no request with the ID. Only one response is delivered to each
+// request so delivering never blocks. If the request has received partial
+// results, they are assembled with the result in res. If the assembly fails,
+// the request gets an error response and the error is returned.
+func (q *responseQueue) deliver(res api.Response) error {
+	key, err := responseKey(res.ID)
+	if err != nil {
+		return err
 	}
 
-	key := idToKey(id)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ch, err := q.find(key)
+	if err != nil {
+		return err
+	}
+
+	parts, ok := q.partial[key]
+	delete(q.partial, key)
+
+	if ok && res.Error == nil {
+		res.Result, err = assembleResult(parts, res.Result)
+		if err != nil {
+			// The request still gets a response so that it does not wait for
+			// one until it is canceled.
+			res.Result = nil
+			res.Error = &api.Error{
+				Data:    nil,
+				Message: err.Error(),
+				Code:    api.CodeInternalError,
+			}
+		}
+	}
+
+	q.delivered[key] = struct{}{}
+	ch <- res
+
+	return err
+}
+
+// addPartial adds the elements of the partial result value to the result of
+// the request with the given ID. It returns the same errors as deliver if
+// the request is not waiting for a response, and an error if value is not
+// a JSON array.
+func (q *responseQueue) addPartial(id api.ID, value json.RawMessage) error {
+	key, err := responseKey(id)
+	if err != nil {
+		return err
+	}
+
+	var elems []json.RawMessage
+	if err = json.Unmarshal(value, &elems); err != nil || elems == nil {
+		return fmt.Errorf("%w for request %s: value is not an array", errPartialResult, key)
+	}
 
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	if _, err = q.find(key); err != nil {
+		return err
+	}
+
+	q.partial[key] = append(q.partial[key], elems...)
+
+	return nil
+}
+
+// find returns the channel of the request with the given key if the request is
+// waiting for a response. Otherwise, it returns an error that tells why
+// the request cannot get a response. The queue must be locked.
+func (q *responseQueue) find(key string) (chan api.Response, error) {
 	ch, ok := q.q[key]
 	if !ok {
-		return
+		delivered, closed := q.closed[key]
+
+		switch {
+		case closed && delivered:
+			return nil, fmt.Errorf("%w: %s", errDuplicateResponse, key)
+		case closed:
+			return nil, fmt.Errorf("%w: %s", errLateResponse, key)
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnknownID, key)
+		}
 	}
 
-	close(ch)
-	delete(q.q, key)
+	if _, ok := q.delivered[key]; ok {
+		return nil, fmt.Errorf("%w: %s", errDuplicateResponse, key)
+	}
+
+	return ch, nil
+}
+
+// responseKey returns the key of the request that the response or the partial
+// result with the given ID belongs to. The ID is normalized so that, for
+// example, "1" and "1e0" match the same request. It returns an error if the ID
+// is not an integer as the client only uses integer IDs.
+func responseKey(id api.ID) (string, error) {
+	if id.Number == nil {
+		return "", fmt.Errorf("%w: %s", errResponseID, idToKey(&id))
+	}
+
+	n, err := id.Number.Int64()
+	if err != nil {
+		f, err := id.Number.Float64()
+		if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return "", fmt.Errorf("%w: %s", errResponseID, idToKey(&id))
+		}
+
+		n = int64(f)
+	}
+
+	normalized, err := api.NewID(n)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errResponseID, idToKey(&id))
+	}
+
+	return idToKey(normalized), nil
+}
+
+// assembleResult returns the result of a request that received partial
+// results. The result is a JSON array of the elements of the partial results in
+// parts followed by the elements of final, the result in the response. final
+// must be a JSON array or null.
+func assembleResult(parts []json.RawMessage, final json.RawMessage) (json.RawMessage, error) {
+	var elems []json.RawMessage
+
+	if len(final) > 0 {
+		if err := json.Unmarshal(final, &elems); err != nil {
+			return nil, fmt.Errorf("%w: result in the response is not an array", errPartialResult)
+		}
+	}
+
+	result := make([]json.RawMessage, 0, len(parts)+len(elems))
+	result = append(result, parts...)
+	result = append(result, elems...)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble result: %w", err)
+	}
+
+	return data, nil
 }
 
 // closeAll closes all of the channels from the queue.
@@ -621,6 +1499,23 @@ func (q *responseQueue) closeAll() {
 		close(ch)
 		delete(q.q, id)
 	}
+
+	clear(q.delivered)
+	clear(q.partial)
+}
+
+// pending returns the keys of the IDs of the requests that are waiting for
+// a response.
+func (q *responseQueue) pending() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys := make([]string, 0, len(q.q))
+	for k := range q.q {
+		keys = append(keys, k)
+	}
+
+	return keys
 }
 
 // idToKey is a helper function that converts id into a string that can be used
@@ -634,68 +1529,210 @@ func idToKey(id *api.ID) string {
 	return string(b)
 }
 
-// read reads a message from the plugin using the given reader.
-func read(r *bufio.Reader) (*rpcMessage, error) {
-	var l int
+// read reads a message from the plugin using the given reader. The message may
+// be a batch that contains multiple messages as a JSON array, so the messages
+// are returned as a slice. It also returns the raw content of the message.
+// The content may be at most maxSize bytes long. If the header of the message
+// is invalid or reading fails, the error is a [*FrameError]. If the content is
+// not a valid message, the error is a [*DecodeError].
+func read(r *bufio.Reader, maxSize int) ([]*rpcMessage, []byte, error) {
+	l, err := readHeader(r, maxSize)
+	if err != nil {
+		return nil, nil, &FrameError{Err: err}
+	}
+
+	if l == 0 {
+		return nil, nil, &DecodeError{Err: errZeroLength}
+	}
+
+	buf := make([]byte, l)
+	if n, err := io.ReadFull(r, buf); err != nil {
+		return nil, nil, &FrameError{Err: fmt.Errorf("failed to read RPC message: %w", err)}
+	} else if n != l {
+		return nil, nil, &FrameError{
+			Err: fmt.Errorf("failed to read RPC message: %w, want %d, got %d", errInvalidLength, l, n),
+		}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.DisallowUnknownFields()
+
+	if trimmed := bytes.TrimSpace(buf); len(trimmed) > 0 && trimmed[0] == '[' {
+		var msgs []*rpcMessage
+		if err := d.Decode(&msgs); err != nil {
+			return nil, buf, &DecodeError{Err: fmt.Errorf("failed to decode batch from JSON: %w", err)}
+		}
+
+		if len(msgs) == 0 || slices.Contains(msgs, nil) {
+			return nil, buf, &DecodeError{Err: fmt.Errorf("%w: empty message in batch", errInvalidBatch)}
+		}
+
+		return msgs, buf, nil
+	}
+
+	var msg *rpcMessage
+	if err := d.Decode(&msg); err != nil {
+		return nil, buf, &DecodeError{Err: fmt.Errorf("failed to decode message from JSON: %w", err)}
+	}
+
+	if msg == nil {
+		return nil, buf, &DecodeError{Err: fmt.Errorf("%w: null message", errInvalidMessage)}
+	}
+
+	return []*rpcMessage{msg}, buf, nil
+}
+
+// readHeader reads the header part of a message from r and returns the length
+// of the content part. The header must have exactly one Content-Length field
+// and at most one Content-Type field, and the length must not be greater than
+// maxSize. The lines of the header are read from the buffer of r so a line
+// that does not fit in the buffer is an error.
+func readHeader(r *bufio.Reader, maxSize int) (int, error) {
+	var (
+		l           = -1
+		contentType bool
+	)
 
 	for {
-		line, err := r.ReadString('\n')
+		b, err := r.ReadSlice('\n')
 		if err != nil {
-			return nil, fmt.Errorf("failed to read line: %w", err)
+			return 0, fmt.Errorf("failed to read line: %w", err)
 		}
 
-		line = strings.TrimRight(line, "\r\n")
+		line := strings.TrimRight(string(b), "\r\n")
 		if line == "" {
 			break
 		}
 
-		// TODO: Consider disallowing other headers.
-		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
-			v := strings.TrimSpace(line[strings.IndexByte(line, ':')+1:])
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", errInvalidHeader, line)
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			if l >= 0 {
+				return 0, fmt.Errorf("%w: %s", errDuplicateHeader, name)
+			}
 
-			if l, err = strconv.Atoi(v); err != nil {
-				return nil, fmt.Errorf("bad Content-Length %q: %w", v, err)
+			if l, err = strconv.Atoi(value); err != nil || l < 0 {
+				return 0, fmt.Errorf("%w: %q", errContentLength, value)
 			}
+
+			if l > maxSize {
+				return 0, fmt.Errorf("%w: %d bytes, maximum is %d", errMessageTooLarge, l, maxSize)
+			}
+		case "content-type":
+			if contentType {
+				return 0, fmt.Errorf("%w: %s", errDuplicateHeader, name)
+			}
+
+			contentType = true
+
+			if err = checkContentType(value); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("%w: %s", errUnknownHeader, name)
 		}
 	}
 
-	if l <= 0 {
-		return nil, fmt.Errorf("bad Content-Length %d: %w", l, errZeroLength)
+	if l < 0 {
+		return 0, errNoContentLength
 	}
 
-	buf := make([]byte, l)
-	if n, err := io.ReadFull(r, buf); err != nil {
-		return nil, fmt.Errorf("failed to read RPC message: %w", err)
-	} else if n != l {
-		return nil, fmt.Errorf("failed to read RPC message: %w, want %d, got %d", errInvalidLength, l, n)
+	return l, nil
+}
+
+// checkContentType reports an error if the given value of the Content-Type
+// header is not supported. The content must be JSON encoded in UTF-8, and
+// the media type of the Language Server Protocol is accepted as well.
+func checkContentType(value string) error {
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", errContentType, value, err)
 	}
 
-	d := json.NewDecoder(bytes.NewReader(buf))
-	d.DisallowUnknownFields()
+	if mediaType != "application/json" && mediaType != "application/vscode-jsonrpc" {
+		return fmt.Errorf("%w: %q", errContentType, value)
+	}
 
-	var msg *rpcMessage
-	if err := d.Decode(&msg); err != nil {
-		return nil, fmt.Errorf("failed to decode message from JSON: %w", err)
+	for k, v := range params {
+		if k != "charset" || (!strings.EqualFold(v, "utf-8") && !strings.EqualFold(v, "utf8")) {
+			return fmt.Errorf("%w: %q", errContentType, value)
+		}
 	}
 
-	return msg, nil
+	return nil
+}
+
+// skippable reports whether the error returned by read was caused by
+// the content of a message that was read in full. The message can then be
+// skipped and the next one read from the same reader. Otherwise, the start of
+// the next message is not known and the reader cannot be used anymore.
+func skippable(err error) bool {
+	var decodeErr *DecodeError
+
+	return errors.As(err, &decodeErr)
 }
 
-func write(ctx context.Context, w io.Writer, req api.Request) error {
-	data, err := json.Marshal(req)
+// encodeID returns the JSON encoding of id.
+func encodeID(id *api.ID) json.RawMessage {
+	switch {
+	case id == nil || id.Null:
+		return json.RawMessage("null")
+	case id.Number != nil:
+		return json.RawMessage(id.Number.String())
+	case id.String != nil:
+		data, err := json.Marshal(*id.String)
+		if err != nil {
+			panic(fmt.Sprintf("failed to encode string ID: %v", err))
+		}
+
+		return data
+	default:
+		return json.RawMessage("null")
+	}
+}
+
+// write writes msg to w as a protocol message with the Content-Length header.
+func write(ctx context.Context, w io.Writer, msg any) error {
+	data, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	slog.Log(ctx, slog.Level(logger.LevelTrace), "writing data", "data", string(data))
+	traced := data
 
-	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(data))
-	if _, err = w.Write([]byte(header)); err != nil {
-		return fmt.Errorf("failed to write request header: %w", err)
+	if res, ok := msg.(rpcResponse); ok {
+		// Responses may contain secret input from the user.
+		slog.Log(ctx, slog.Level(logger.LevelTrace), "writing response", "id", string(res.ID), "error", res.Error)
+
+		if res.secret {
+			res.Result = json.RawMessage(`"<redacted>"`)
+
+			if traced, err = json.Marshal(res); err != nil {
+				return fmt.Errorf("failed to marshal message: %w", err)
+			}
+		}
+	} else {
+		slog.Log(ctx, slog.Level(logger.LevelTrace), "writing data", "data", string(data))
 	}
 
-	if _, err = w.Write(data); err != nil {
-		return fmt.Errorf("failed to write request data: %w", err)
+	if c, ok := w.(*connection); ok {
+		c.trace.frame(traceSend, traced)
+	}
+
+	// The header and the content are written with a single call so that
+	// concurrent writes to the connection are not interleaved.
+	buf := make([]byte, 0, len(data)+32) //nolint:mnd // room for the header
+	buf = fmt.Appendf(buf, "Content-Length: %d\r\n\r\n", len(data))
+	buf = append(buf, data...)
+
+	if _, err = w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,601 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/errhint"
+)
+
+func TestResponseQueue_Deliver(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		pending []int64 // IDs of the requests that are waiting for responses
+		closed  []int64 // IDs of the requests that are closed before the frames are read
+		frames  []string
+		want    []error // results of delivering the responses in the frames
+	}{
+		{
+			name:    "In order",
+			pending: []int64{1, 2},
+			frames:  []string{response("1"), response("2")},
+			want:    []error{nil, nil},
+		},
+		{
+			name:    "Out of order",
+			pending: []int64{1, 2, 3},
+			frames:  []string{response("3"), response("1"), response("2")},
+			want:    []error{nil, nil, nil},
+		},
+		{
+			name:    "Batch out of order",
+			pending: []int64{1, 2},
+			frames:  []string{"[" + response("2") + "," + response("1") + "]"},
+			want:    []error{nil, nil},
+		},
+		{
+			name:    "Duplicate",
+			pending: []int64{1},
+			frames:  []string{response("1"), response("1")},
+			want:    []error{nil, errDuplicateResponse},
+		},
+		{
+			name:    "Duplicate in batch",
+			pending: []int64{1, 2},
+			frames:  []string{"[" + response("1") + "," + response("2") + "," + response("1") + "]"},
+			want:    []error{nil, nil, errDuplicateResponse},
+		},
+		{
+			name:    "Normalized duplicate",
+			pending: []int64{1},
+			frames:  []string{response("1"), response("1e0")},
+			want:    []error{nil, errDuplicateResponse},
+		},
+		{
+			name:    "Closed request",
+			pending: []int64{1},
+			closed:  []int64{1},
+			frames:  []string{response("1")},
+			want:    []error{errLateResponse},
+		},
+		{
+			name:    "Unknown ID",
+			pending: []int64{1},
+			frames:  []string{response("2"), response("1")},
+			want:    []error{errUnknownID, nil},
+		},
+		{
+			name:    "String ID",
+			pending: []int64{1},
+			frames:  []string{response(`"1"`)},
+			want:    []error{errResponseID},
+		},
+		{
+			name:    "Fractional ID",
+			pending: []int64{1},
+			frames:  []string{response("1.5")},
+			want:    []error{errResponseID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := newResponseQueue()
+			chans := make(map[int64]chan api.Response, len(tt.pending))
+
+			for _, n := range tt.pending {
+				chans[n] = q.add(newTestID(t, n))
+			}
+
+			for _, n := range tt.closed {
+				q.close(newTestID(t, n))
+			}
+
+			var sb strings.Builder
+
+			for _, f := range tt.frames {
+				fmt.Fprintf(&sb, "Content-Length: %d\r\n\r\n%s", len(f), f)
+			}
+
+			r := bufio.NewReader(strings.NewReader(sb.String()))
+
+			var got []error
+
+			for range tt.frames {
+				msgs, _, err := read(r, int(DefaultMaxMessageSize))
+				if err != nil {
+					t.Fatalf("read() error = %v", err)
+				}
+
+				for _, msg := range msgs {
+					got = append(got, q.deliver(api.Response{JSONRPC: msg.JSONRCP, ID: *msg.ID, Result: msg.Result}))
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("delivered %d responses, want %d", len(got), len(tt.want))
+			}
+
+			for i, err := range got {
+				if !errors.Is(err, tt.want[i]) || (err == nil) != (tt.want[i] == nil) {
+					t.Errorf("deliver() #%d error = %v, want %v", i, err, tt.want[i])
+				}
+			}
+
+			// Every pending request gets the response with its own ID.
+			for _, n := range tt.pending {
+				select {
+				case res, ok := <-chans[n]:
+					if !ok {
+						continue
+					}
+
+					if got, err := res.ID.Number.Int64(); err != nil || got != n {
+						t.Errorf("request %d got response with ID %s", n, idToKey(&res.ID))
+					}
+				default:
+				}
+			}
+		})
+	}
+}
+
+func TestResponseQueue_CloseAfterDelivery(t *testing.T) {
+	t.Parallel()
+
+	q := newResponseQueue()
+	id := newTestID(t, 1)
+
+	ch := q.add(id)
+
+	res := api.Response{JSONRPC: api.JSONRPCVersion, ID: *id, Result: []byte("true")}
+	if err := q.deliver(res); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	<-ch
+	q.close(id)
+
+	if err := q.deliver(res); !errors.Is(err, errDuplicateResponse) {
+		t.Errorf("deliver() after close error = %v, want %v", err, errDuplicateResponse)
+	}
+}
+
+func TestResponseQueue_ClosedLimit(t *testing.T) {
+	t.Parallel()
+
+	q := newResponseQueue()
+
+	for n := range int64(closedRequests + 1) {
+		id := newTestID(t, n)
+
+		q.add(id)
+		q.close(id)
+	}
+
+	// The oldest closed request is forgotten.
+	id := newTestID(t, 0)
+	if err := q.deliver(api.Response{JSONRPC: api.JSONRPCVersion, ID: *id}); !errors.Is(err, errUnknownID) {
+		t.Errorf("deliver() error = %v, want %v", err, errUnknownID)
+	}
+
+	id = newTestID(t, closedRequests)
+	if err := q.deliver(api.Response{JSONRPC: api.JSONRPCVersion, ID: *id}); !errors.Is(err, errLateResponse) {
+		t.Errorf("deliver() error = %v, want %v", err, errLateResponse)
+	}
+}
+
+func TestResponseQueue_PartialResults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		parts    []string
+		result   string
+		want     string
+		wantErr  error // error from delivering the response
+		partsErr error // error from adding the last part
+	}{
+		{name: "No parts", result: `[1]`, want: `[1]`},
+		{name: "Parts", parts: []string{`[1,2]`, `[]`, `[3]`}, result: `[4]`, want: `[1,2,3,4]`},
+		{name: "Null result", parts: []string{`[{"a":1}]`}, result: `null`, want: `[{"a":1}]`},
+		{name: "Empty parts", parts: []string{`[]`}, result: `[]`, want: `[]`},
+		{name: "Object result", parts: []string{`[1]`}, result: `{}`, wantErr: errPartialResult},
+		{name: "Object part", parts: []string{`{}`}, result: `[1]`, want: `[1]`, partsErr: errPartialResult},
+		{name: "Null part", parts: []string{`null`}, result: `[1]`, want: `[1]`, partsErr: errPartialResult},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := newResponseQueue()
+			id := newTestID(t, 1)
+
+			ch := q.add(id)
+
+			for i, part := range tt.parts {
+				err := q.addPartial(*id, json.RawMessage(part))
+
+				wantErr := error(nil)
+				if i == len(tt.parts)-1 {
+					wantErr = tt.partsErr
+				}
+
+				if !errors.Is(err, wantErr) || (err == nil) != (wantErr == nil) {
+					t.Fatalf("addPartial(%s) error = %v, want %v", part, err, wantErr)
+				}
+			}
+
+			err := q.deliver(api.Response{JSONRPC: api.JSONRPCVersion, ID: *id, Result: json.RawMessage(tt.result)})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("deliver() error = %v, want %v", err, tt.wantErr)
+			}
+
+			res := <-ch
+
+			if tt.wantErr != nil {
+				if res.Error == nil {
+					t.Errorf("response error = nil, want error")
+				}
+
+				return
+			}
+
+			if string(res.Result) != tt.want {
+				t.Errorf("result = %s, want %s", res.Result, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseQueue_PartialAfterResponse(t *testing.T) {
+	t.Parallel()
+
+	q := newResponseQueue()
+	id := newTestID(t, 1)
+
+	ch := q.add(id)
+
+	if err := q.deliver(api.Response{JSONRPC: api.JSONRPCVersion, ID: *id, Result: []byte("[]")}); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	if err := q.addPartial(*id, []byte("[1]")); !errors.Is(err, errDuplicateResponse) {
+		t.Errorf("addPartial() after response error = %v, want %v", err, errDuplicateResponse)
+	}
+
+	<-ch
+	q.close(id)
+
+	if err := q.addPartial(*newTestID(t, 2), []byte("[1]")); !errors.Is(err, errUnknownID) {
+		t.Errorf("addPartial() with unknown ID error = %v, want %v", err, errUnknownID)
+	}
+
+	q.add(newTestID(t, 3))
+	q.close(newTestID(t, 3))
+
+	if err := q.addPartial(*newTestID(t, 3), []byte("[1]")); !errors.Is(err, errLateResponse) {
+		t.Errorf("addPartial() after close error = %v, want %v", err, errLateResponse)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		data          string
+		wantCategory  string
+		wantHint      string
+		wantRetryable bool
+		wantMsg       string
+	}{
+		{name: "No data", data: "null", wantMsg: "task failed: plugin returned an error: failed"},
+		{
+			name:          "All fields",
+			data:          `{"category":"environment","retryable":true,"hint":"start the daemon","detail":"x"}`,
+			wantCategory:  CategoryEnvironment,
+			wantHint:      "start the daemon",
+			wantRetryable: true,
+			wantMsg:       "task failed: plugin returned an error: failed: x",
+		},
+		{
+			name:         "Category",
+			data:         `{"category":"config"}`,
+			wantCategory: CategoryConfig,
+			wantMsg:      "task failed: plugin returned an error: failed",
+		},
+		{
+			name:     "Unknown category",
+			data:     `{"category":"other","hint":"h"}`,
+			wantHint: "h",
+			wantMsg:  "task failed: plugin returned an error: failed",
+		},
+		{name: "String data", data: `"details"`, wantMsg: "task failed: plugin returned an error: failed (details)"},
+		{
+			name:    "Invalid fields",
+			data:    `{"category":1}`,
+			wantMsg: "task failed: plugin returned an error: failed (map[category:1])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var res api.Error
+			if err := json.Unmarshal([]byte(`{"code":-32603,"message":"failed","data":`+tt.data+`}`), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			err := fmt.Errorf("task failed: %w", newError(&res))
+
+			if got := ErrorCategory(err); got != tt.wantCategory {
+				t.Errorf("ErrorCategory() = %q, want %q", got, tt.wantCategory)
+			}
+
+			if got := errhint.Hint(err); got != tt.wantHint {
+				t.Errorf("Hint() = %q, want %q", got, tt.wantHint)
+			}
+
+			if got := Retryable(err); got != tt.wantRetryable {
+				t.Errorf("Retryable() = %v, want %v", got, tt.wantRetryable)
+			}
+
+			if got := err.Error(); got != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", got, tt.wantMsg)
+			}
+
+			var rpcErr *api.Error
+			if !errors.As(err, &rpcErr) || rpcErr.Message != "failed" {
+				t.Errorf("error %v does not wrap the response error", err)
+			}
+		})
+	}
+}
+
+func TestRead_SkipInvalid(t *testing.T) {
+	t.Parallel()
+
+	frames := []string{
+		`{"jsonrpc":"2.0","id":1,`,
+		`{"jsonrpc":"2.0","unknown":true}`,
+		`[]`,
+		`[null]`,
+		`null`,
+		``,
+		response("1"),
+	}
+
+	var sb strings.Builder
+
+	for _, f := range frames {
+		fmt.Fprintf(&sb, "Content-Length: %d\r\n\r\n%s", len(f), f)
+	}
+
+	r := bufio.NewReader(strings.NewReader(sb.String()))
+
+	for _, f := range frames[:len(frames)-1] {
+		msgs, _, err := read(r, int(DefaultMaxMessageSize))
+		if err == nil {
+			t.Fatalf("read() of %q returned %d messages, want error", f, len(msgs))
+		}
+
+		if !skippable(err) {
+			t.Fatalf("read() of %q error = %v, want skippable error", f, err)
+		}
+	}
+
+	msgs, _, err := read(r, int(DefaultMaxMessageSize))
+	if err != nil {
+		t.Fatalf("read() after invalid frames error = %v", err)
+	}
+
+	if len(msgs) != 1 || msgs[0].ID == nil || idToKey(msgs[0].ID) != "1" {
+		t.Errorf("read() after invalid frames = %v, want response with ID 1", msgs)
+	}
+}
+
+func TestRead_Frame(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error // nil if the message is read successfully
+	}{
+		{name: "Content-Length", input: "Content-Length: 2\r\n\r\n{}"},
+		{name: "Lowercase", input: "content-length: 2\r\n\r\n{}"},
+		{name: "Content-Type", input: "Content-Length: 2\r\nContent-Type: application/json\r\n\r\n{}"},
+		{
+			name:  "LSP Content-Type",
+			input: "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 2\r\n\r\n{}",
+		},
+		{name: "Maximum size", input: "Content-Length: 64\r\n\r\n{}" + strings.Repeat(" ", 62)},
+		{name: "Bad Content-Length", input: "Content-Length: abc\r\n\r\n{}", wantErr: errContentLength},
+		{name: "Negative Content-Length", input: "Content-Length: -2\r\n\r\n{}", wantErr: errContentLength},
+		{name: "No Content-Length", input: "\r\n{}", wantErr: errNoContentLength},
+		{
+			name:    "Duplicate Content-Length",
+			input:   "Content-Length: 2\r\nContent-Length: 2\r\n\r\n{}",
+			wantErr: errDuplicateHeader,
+		},
+		{
+			name:    "Duplicate Content-Type",
+			input:   "Content-Type: application/json\r\nContent-Type: application/json\r\nContent-Length: 2\r\n\r\n{}",
+			wantErr: errDuplicateHeader,
+		},
+		{
+			name:    "Unsupported Content-Type",
+			input:   "Content-Type: text/plain\r\nContent-Length: 2\r\n\r\n{}",
+			wantErr: errContentType,
+		},
+		{
+			name:    "Unsupported charset",
+			input:   "Content-Type: application/json; charset=latin1\r\nContent-Length: 2\r\n\r\n{}",
+			wantErr: errContentType,
+		},
+		{name: "Unknown header", input: "X-Test: 1\r\nContent-Length: 2\r\n\r\n{}", wantErr: errUnknownHeader},
+		{name: "No colon", input: "Content-Length 2\r\n\r\n{}", wantErr: errInvalidHeader},
+		{name: "Too large", input: "Content-Length: 65\r\n\r\n{}", wantErr: errMessageTooLarge},
+		{
+			name:    "Long line",
+			input:   "Content-Length: " + strings.Repeat("0", 4096) + "2\r\n\r\n{}",
+			wantErr: bufio.ErrBufferFull,
+		},
+		{name: "Short body", input: "Content-Length: 10\r\n\r\n{}", wantErr: io.ErrUnexpectedEOF},
+		{name: "Unterminated header", input: "Content-Length: 2", wantErr: io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msgs, _, err := read(bufio.NewReader(strings.NewReader(tt.input)), 64)
+			if tt.wantErr == nil {
+				if err != nil || len(msgs) != 1 {
+					t.Errorf("read() = %v, %v, want one message", msgs, err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("read() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var frameErr *FrameError
+			if !errors.As(err, &frameErr) {
+				t.Errorf("read() error = %v, want *FrameError", err)
+			}
+
+			if skippable(err) {
+				t.Errorf("read() error = %v is skippable, want not skippable", err)
+			}
+		})
+	}
+}
+
+func TestCallBatch_KilledMidBatch(t *testing.T) {
+	t.Parallel()
+
+	conn := &blockingConn{written: make(chan struct{}), release: make(chan struct{})}
+	e := newExternalPlugin(&api.Manifest{Name: "test"}) //nolint:exhaustruct // only the name is needed
+	e.conn = conn
+	e.cmd = &exec.Cmd{} //nolint:exhaustruct // the process is never started
+
+	type result struct {
+		err  error
+		errs []error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		var first, second bool
+
+		errs, err := e.callBatch(t.Context(), []batchCall{
+			{params: nil, result: &first, method: "first"},
+			{params: nil, result: &second, method: "second"},
+		})
+		done <- result{err: err, errs: errs}
+	}()
+
+	// The plugin answers the first call and is killed before the client starts
+	// waiting for the responses.
+	<-conn.written
+
+	res := api.Response{JSONRPC: api.JSONRPCVersion, ID: *newTestID(t, 1), Result: []byte("true")}
+	if err := e.queue.deliver(res); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	if err := e.kill(t.Context()); err != nil {
+		t.Fatalf("kill() error = %v", err)
+	}
+
+	close(conn.release)
+
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatalf("callBatch() error = %v", got.err)
+		}
+
+		if got.errs[0] != nil {
+			t.Errorf("callBatch() errs[0] = %v, want nil", got.errs[0])
+		}
+
+		if !errors.Is(got.errs[1], errNoResponse) {
+			t.Errorf("callBatch() errs[1] = %v, want %v", got.errs[1], errNoResponse)
+		}
+	case <-time.After(5 * time.Second): //nolint:mnd // generous timeout for a hung call
+		t.Fatal("callBatch() did not return after the plugin was killed")
+	}
+}
+
+// A blockingConn is a connection whose writes block until release is closed.
+// It signals on written when a write starts.
+type blockingConn struct {
+	written chan struct{}
+	release chan struct{}
+}
+
+func (*blockingConn) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *blockingConn) Write(p []byte) (int, error) {
+	close(c.written)
+	<-c.release
+
+	return len(p), nil
+}
+
+func (*blockingConn) Close() error {
+	return nil
+}
+
+// response returns a successful JSON-RPC response with the given raw ID.
+func response(id string) string {
+	return `{"jsonrpc":"2.0","id":` + id + `,"result":true}`
+}
+
+// newTestID returns the request ID for n.
+func newTestID(t *testing.T, n int64) *api.ID {
+	t.Helper()
+
+	id, err := api.NewID(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
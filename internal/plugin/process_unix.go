@@ -0,0 +1,79 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// A processGroup contains a plugin process and the processes that it starts so
+// that they can be ended together. On Unix, the plugin process is started as
+// the leader of a new process group, and the group is signaled as a whole.
+type processGroup struct{}
+
+// newProcessGroup sets up cmd to start the process in a new process group. It
+// must be called before cmd is started and after the other process attributes
+// have been set.
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{} //nolint:exhaustruct // only the process group is set
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+
+	return &processGroup{}, nil
+}
+
+// attach adds the started process p to g. The process is already the leader of
+// its group so there is nothing to do on Unix.
+func (g *processGroup) attach(_ *os.Process) error {
+	return nil
+}
+
+// terminate sends the termination signal to all of the processes in g.
+func (g *processGroup) terminate(p *os.Process) error {
+	return signalGroup(p, syscall.SIGTERM)
+}
+
+// kill kills all of the processes in g.
+func (g *processGroup) kill(p *os.Process) error {
+	return signalGroup(p, syscall.SIGKILL)
+}
+
+// close releases the resources of g. There are none on Unix.
+func (g *processGroup) close() error {
+	return nil
+}
+
+// signalGroup sends sig to the process group led by p. It returns
+// [os.ErrProcessDone] if there are no processes left in the group.
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	err := syscall.Kill(-p.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to send %s to process group %d: %w", sig, p.Pid, err)
+	}
+
+	return nil
+}
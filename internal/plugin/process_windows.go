@@ -0,0 +1,119 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// errNoTerminate is returned when a process group is asked to terminate on
+// Windows where the processes have no termination signal.
+var errNoTerminate = errors.New("processes cannot be terminated gracefully on Windows")
+
+// A processGroup contains a plugin process and the processes that it starts so
+// that they can be ended together. On Windows, the plugin process is assigned
+// to a job object that its child processes inherit, and the job is set to
+// kill its processes when it is closed.
+type processGroup struct {
+	job      windows.Handle
+	attached atomic.Bool
+}
+
+// newProcessGroup creates the job object for the process of cmd. It must be
+// called before cmd is started.
+func newProcessGroup(_ *exec.Cmd) (*processGroup, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job object: %w", err)
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{} //nolint:exhaustruct // only the limit flags are set
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+
+	if _, err = windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	); err != nil {
+		_ = windows.CloseHandle(job)
+
+		return nil, fmt.Errorf("failed to set up job object: %w", err)
+	}
+
+	return &processGroup{job: job, attached: atomic.Bool{}}, nil
+}
+
+// attach assigns the started process p to the job object of g. The processes
+// that p starts after this are also in the job.
+func (g *processGroup) attach(p *os.Process) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open process %d: %w", p.Pid, err)
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck // nothing to do if closing fails
+
+	if err = windows.AssignProcessToJobObject(g.job, h); err != nil {
+		return fmt.Errorf("failed to assign process %d to job object: %w", p.Pid, err)
+	}
+
+	g.attached.Store(true)
+
+	return nil
+}
+
+// terminate returns an error as the processes cannot be terminated gracefully
+// on Windows.
+func (g *processGroup) terminate(_ *os.Process) error {
+	return errNoTerminate
+}
+
+// kill kills all of the processes in g. If p has not been assigned to the job
+// object, only p is killed.
+func (g *processGroup) kill(p *os.Process) error {
+	if !g.attached.Load() {
+		if err := p.Kill(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	if err := windows.TerminateJobObject(g.job, 1); err != nil {
+		return fmt.Errorf("failed to terminate job object: %w", err)
+	}
+
+	return nil
+}
+
+// close releases the job object of g. Closing the job object kills
+// the processes that are still in it.
+func (g *processGroup) close() error {
+	if err := windows.CloseHandle(g.job); err != nil {
+		return fmt.Errorf("failed to close job object: %w", err)
+	}
+
+	return nil
+}
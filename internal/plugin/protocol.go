@@ -0,0 +1,1118 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/logger"
+	"github.com/reginald-project/reginald/internal/system"
+	"github.com/reginald-project/reginald/internal/typeconv"
+)
+
+// Capabilities contains the optional protocol features that a plugin reports
+// to support in its handshake result. The capabilities are sent as an object
+// of boolean flags. The flags that the client uses have their own fields, and
+// all of the flags, including the ones the client does not know, can be
+// checked with [Capabilities.Supports].
+type Capabilities struct {
+	// flags contains all of the boolean flags in the capabilities object.
+	flags map[string]bool
+
+	// Diff reports whether the plugin supports the "dryRun" parameter in
+	// "runTask" and reports the file changes in the result so that they can be
+	// shown as diffs.
+	Diff bool `json:"diff,omitempty"`
+
+	// Batch reports whether the plugin accepts batches of requests as JSON
+	// arrays. The client sends the tasks of the same plugin in a stage as
+	// a single batch to plugins that support it.
+	Batch bool `json:"batch,omitempty"`
+
+	// Cancel reports whether the plugin handles the "cancel" notification.
+	// The client sends it only to the plugins that support it.
+	Cancel bool `json:"cancel,omitempty"`
+
+	// Validate reports whether the plugin handles the "validateTask" method.
+	// The client validates the tasks of the plugins that support it before
+	// running any of the tasks.
+	Validate bool `json:"validate,omitempty"`
+
+	// Elevate reports whether the plugin supports the "elevate" parameter in
+	// "runTask" and runs the privileged operations of the elevated tasks with
+	// sudo. Only the tasks of the plugins that support it may be elevated.
+	Elevate bool `json:"elevate,omitempty"`
+
+	// Initialize reports whether the plugin handles the "initialize" method.
+	// The client calls it right after the handshake to send the config of
+	// the plugin before any other methods are called.
+	Initialize bool `json:"initialize,omitempty"`
+}
+
+// Supports reports whether the plugin reported to support the capability with
+// the given name.
+func (c Capabilities) Supports(name string) bool {
+	switch name {
+	case "diff":
+		return c.Diff
+	case "batch":
+		return c.Batch
+	case "cancel":
+		return c.Cancel
+	case "validate":
+		return c.Validate
+	case "elevate":
+		return c.Elevate
+	case "initialize":
+		return c.Initialize
+	default:
+		return c.flags[name]
+	}
+}
+
+// UnmarshalJSON decodes the capabilities object. The members that are not
+// boolean are ignored so that the later versions of the protocol may add
+// capabilities with other types of values.
+func (c *Capabilities) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid capabilities: %w", err)
+	}
+
+	flags := make(map[string]bool, len(raw))
+
+	for k, v := range raw {
+		var b bool
+		if err := json.Unmarshal(v, &b); err == nil {
+			flags[k] = b
+		}
+	}
+
+	*c = Capabilities{
+		flags:      flags,
+		Diff:       flags["diff"],
+		Batch:      flags["batch"],
+		Cancel:     flags["cancel"],
+		Validate:   flags["validate"],
+		Elevate:    flags["elevate"],
+		Initialize: flags["initialize"],
+	}
+
+	return nil
+}
+
+// The range of the protocol versions that the client supports. The client
+// advertises the range in the handshake and the plugin responds with
+// the version it chooses from the range.
+const (
+	minProtocolVersion = 0
+	maxProtocolVersion = api.ProtocolVersion
+)
+
+// clientCapabilities contains the optional protocol features that the client
+// supports. The client sends them in the handshake params so that the plugins
+// use the features only if the client supports them.
+type clientCapabilities struct {
+	// Progress reports whether the client handles the "progress"
+	// notifications.
+	Progress bool `json:"progress"`
+
+	// Prompt reports whether the client handles the "prompt" and "confirm"
+	// methods.
+	Prompt bool `json:"prompt"`
+
+	// Cancel reports whether the client may send the "cancel" notification.
+	Cancel bool `json:"cancel"`
+
+	// Download reports whether the client handles the "download" method.
+	Download bool `json:"download"`
+
+	// PartialResults reports whether the client handles the "partialResult"
+	// notifications.
+	PartialResults bool `json:"partialResults"`
+}
+
+// handshakeParams are the params of the "handshake" method with the range of
+// the supported protocol versions and the capabilities of the client.
+// The embedded protocol version is set to the highest supported version for
+// the plugins that do not negotiate the version.
+type handshakeParams struct {
+	api.HandshakeParams
+
+	// MinProtocolVersion is the lowest protocol version that the client
+	// supports.
+	MinProtocolVersion int `json:"minProtocolVersion"`
+
+	// MaxProtocolVersion is the highest protocol version that the client
+	// supports.
+	MaxProtocolVersion int `json:"maxProtocolVersion"`
+
+	// Capabilities contains the optional protocol features that the client
+	// supports.
+	Capabilities clientCapabilities `json:"capabilities"`
+
+	// Environment contains the environment that the client set up for
+	// the plugin process. It is the same as in the environment variables of
+	// the process.
+	Environment *environment `json:"environment,omitempty"`
+}
+
+// errorData is the data of an error response from a plugin with the fields
+// that tell the client how to handle the error. All of the fields are
+// optional, and the data may have other fields that the client ignores.
+type errorData struct {
+	// Category is the category of the error. See [CategoryConfig] and
+	// the other categories.
+	Category string `json:"category"`
+
+	// Hint is the suggestion for fixing the problem that is shown to the user.
+	Hint string `json:"hint"`
+
+	// Detail is the additional information on the error.
+	Detail string `json:"detail"`
+
+	// Retryable reports whether the request may succeed if it is sent again.
+	Retryable bool `json:"retryable"`
+}
+
+// methodCancel is the notification that the client sends to a plugin when it
+// stops waiting for the response to a request, for example, when the run is
+// interrupted. The plugin should stop handling the request.
+const methodCancel = "cancel"
+
+// cancelParams are the parameters for the "cancel" notification.
+type cancelParams struct {
+	// ID is the ID of the request to cancel.
+	ID json.RawMessage `json:"id"`
+}
+
+// A FileChange is a change to the contents of a file that a task made or, in
+// dry-run mode, would make.
+type FileChange struct {
+	// Path is the path to the changed file.
+	Path string `json:"path"`
+
+	// Old is the content of the file before the change. It is empty if
+	// the file is created.
+	Old string `json:"old"`
+
+	// New is the content of the file after the change. It is empty if the file
+	// is removed.
+	New string `json:"new"`
+}
+
+// Methods that the plugins can call on the client. The client handles them by
+// prompting the user through the terminal.
+const (
+	methodConfirm = "confirm"
+	methodPrompt  = "prompt"
+)
+
+// ConfirmParams are the parameters for the "confirm" method that a plugin
+// sends to ask the user a yes-or-no question.
+type ConfirmParams struct {
+	// Prompt is the question shown to the user.
+	Prompt string `json:"prompt"`
+
+	// Default is the answer used if the user gives no answer or the client is
+	// not interactive.
+	Default bool `json:"default,omitempty"`
+}
+
+// ConfirmResult is the result of the "confirm" method.
+type ConfirmResult struct {
+	// Confirmed reports whether the user answered yes.
+	Confirmed bool `json:"confirmed"`
+}
+
+// PromptParams are the parameters for the "prompt" method that a plugin sends
+// to ask the user for text input.
+type PromptParams struct {
+	// Prompt is the prompt shown to the user.
+	Prompt string `json:"prompt"`
+
+	// Default is the answer used if the user gives an empty answer or
+	// the client is not interactive. If it is empty, the method fails when
+	// the client cannot prompt the user.
+	Default string `json:"default,omitempty"`
+
+	// Secret tells the client to read the input without echoing it. Secret
+	// answers are never logged and no default is used for them when the client
+	// is not interactive.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// PromptResult is the result of the "prompt" method.
+type PromptResult struct {
+	// Answer is the answer the user gave.
+	Answer string `json:"answer"`
+}
+
+// methodDownload is the method that a plugin calls to have the client download
+// a file through the download cache and verify its checksum.
+const methodDownload = "download"
+
+// DownloadParams are the parameters for the "download" method.
+type DownloadParams struct {
+	// URL is the URL of the file to download.
+	URL string `json:"url"`
+
+	// SHA256 is the expected hexadecimal SHA-256 checksum of the file. If it
+	// is empty, the file must match the checksum recorded in the lock file
+	// when the file was first downloaded.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// DownloadResult is the result of the "download" method.
+type DownloadResult struct {
+	// Path is the path to the downloaded file in the download cache.
+	// The plugin must not modify the file.
+	Path string `json:"path"`
+
+	// SHA256 is the hexadecimal SHA-256 checksum of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// methodProgress is the notification that a plugin sends to report
+// the progress of the command or the task that it is running.
+const methodProgress = "progress"
+
+// progressParams are the parameters for the "progress" notification.
+type progressParams struct {
+	// Message describes the current step.
+	Message string `json:"message"`
+
+	// RequestID is the ID of the request from the client that the progress is
+	// reported for. It is optional.
+	RequestID *api.ID `json:"requestId,omitempty"`
+
+	// Current is the number of the current step. It is only used if Total is
+	// set.
+	Current int `json:"current,omitempty"`
+
+	// Total is the total number of the steps. It is zero if the number of
+	// the steps is not known.
+	Total int `json:"total,omitempty"`
+}
+
+// methodPartialResult is the notification that a plugin sends to deliver part
+// of the result of a request before the response. The parts are assembled
+// with the result in the response.
+const methodPartialResult = "partialResult"
+
+// partialResultParams are the parameters for the "partialResult"
+// notification.
+type partialResultParams struct {
+	// RequestID is the ID of the request from the client that the part of
+	// the result belongs to.
+	RequestID api.ID `json:"requestId"`
+
+	// Value is the part of the result. It must be a JSON array, and its
+	// elements are added to the result before the elements of the later parts
+	// and the result in the response.
+	Value json.RawMessage `json:"value"`
+}
+
+// A LogLevel is the level of a log message sent by a plugin. It is decoded
+// either from the numeric [slog.Level] or from the name of the level, and it
+// is mapped to the closest level that the client uses.
+type LogLevel slog.Level
+
+// RunCommandParams are the parameters for the "runCommand" method. In addition
+// to the parameters defined in the API, it contains the positional arguments
+// that were given to the command.
+type RunCommandParams struct {
+	api.RunCommandParams
+
+	// Context contains the global options of the run that are relevant to
+	// the command. It is nil if the command is not run from the command line.
+	Context *CommandContext `json:"context,omitempty"`
+
+	// Args are the positional arguments given to the command. They are
+	// validated against the arguments declared in the manifest before the call.
+	Args []string `json:"args,omitempty"`
+}
+
+// CommandContext contains the resolved global options of the run that are
+// passed to the plugin commands so that they can behave consistently with
+// the built-in commands.
+type CommandContext struct {
+	// Directory is the absolute path of the dotfiles directory.
+	Directory string `json:"directory"`
+
+	// Color tells whether the output of the command should be colored. It is
+	// resolved from the color mode so that the plugin does not need to check
+	// the terminal.
+	Color bool `json:"color"`
+
+	// DryRun tells the command to only report the changes it would make.
+	DryRun bool `json:"dryRun"`
+
+	// Verbose tells the command to print more output.
+	Verbose bool `json:"verbose"`
+
+	// Quiet tells the command to print only the errors.
+	Quiet bool `json:"quiet"`
+
+	// Interactive tells whether the command may ask for input from the user.
+	Interactive bool `json:"interactive"`
+}
+
+// RunTaskParams are the parameters for the "runTask" method. In addition to
+// the parameters defined in the API, it contains the parameters for
+// the optional protocol features.
+type RunTaskParams struct {
+	api.RunTaskParams
+
+	// DryRun tells the plugin to only report the changes the task would make.
+	// It is only sent to plugins that support the "diff" capability.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// BackupDir is the directory where the task should store the backups of
+	// the files it replaces or removes.
+	BackupDir string `json:"backupDir,omitempty"`
+
+	// Elevate tells the plugin to run the privileged operations of the task
+	// with "sudo -n". The client has acquired the credentials for sudo before
+	// running the task so that sudo does not ask for the password. It is only
+	// sent to plugins that support the "elevate" capability.
+	Elevate bool `json:"elevate,omitempty"`
+
+	// Facts contains the facts about the system that the client has collected
+	// so that the plugin does not need to detect them again.
+	Facts *system.Facts `json:"facts,omitempty"`
+}
+
+// maxExitCode is the largest exit code that a plugin command may return.
+const maxExitCode = 255
+
+// RunCommandResult is the result of the "runCommand" method. The plugins that
+// only report whether the command succeeded may respond with an empty object.
+type RunCommandResult struct {
+	// Data is the machine-readable result of the command. It is printed as
+	// the data of the JSON output when the command is run with "--json".
+	Data json.RawMessage `json:"data,omitempty"`
+
+	// Message is a message to the user. If ExitCode is zero, it is printed
+	// after the command has finished. Otherwise, it is the error message.
+	Message string `json:"message,omitempty"`
+
+	// ExitCode is the exit code of the program. Zero means that the command
+	// succeeded. The exit code must be between 0 and 255.
+	ExitCode int `json:"exitCode,omitempty"`
+}
+
+// Err returns the error for the exit code of the command. It returns nil if
+// the command succeeded.
+func (r *RunCommandResult) Err() error {
+	if r == nil || r.ExitCode == 0 {
+		return nil
+	}
+
+	if r.Message == "" {
+		return fmt.Errorf("%w with exit code %d", ErrCommandFailed, r.ExitCode)
+	}
+
+	return fmt.Errorf("%w: %s", ErrCommandFailed, r.Message)
+}
+
+// RunTaskResult is the result of the "runTask" method.
+type RunTaskResult struct {
+	// Changes contains the file changes the task made or would make.
+	Changes []FileChange `json:"changes,omitempty"`
+}
+
+// methodValidateTask is the method that the client calls on the plugins that
+// support the "validate" capability to check the config of a task instance
+// before any of the tasks are run.
+const methodValidateTask = "validateTask"
+
+// validateTaskParams are the parameters for the "validateTask" method. They
+// contain the same task type and config as the params of "runTask".
+type validateTaskParams struct {
+	api.RunTaskParams
+
+	// Facts contains the facts about the system that the client has collected
+	// so that the plugin does not need to detect them again.
+	Facts *system.Facts `json:"facts,omitempty"`
+}
+
+// validateTaskResult is the result of the "validateTask" method.
+type validateTaskResult struct {
+	// Errors contains the problems that the plugin found in the task config.
+	// The config is valid if it is empty.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// methodInitialize is the method that the client calls on the plugins that
+// support the "initialize" capability right after the handshake.
+const methodInitialize = "initialize"
+
+// initializeParams are the parameters for the "initialize" method. They contain
+// the resolved config of the plugin and the preferences that the plugin should
+// follow for the rest of its lifetime.
+type initializeParams struct {
+	// Config contains the resolved plugin-level config values. The configs of
+	// the commands are sent in the "runCommand" calls.
+	Config api.KeyValues `json:"config"`
+
+	// Logging contains the logging preferences for the plugin.
+	Logging initializeLogging `json:"logging"`
+
+	// Capabilities contains the optional protocol features that the client
+	// supports. They are the same as in the handshake params.
+	Capabilities clientCapabilities `json:"capabilities"`
+}
+
+// initializeLogging contains the logging preferences that are sent in
+// the "initialize" params.
+type initializeLogging struct {
+	// Level is the minimum level of the log messages from the plugin that
+	// the client logs. The plugin can skip sending the messages below it.
+	Level string `json:"level"`
+
+	// TraceRPC reports whether the client traces the messages sent to and
+	// received from the plugin.
+	TraceRPC bool `json:"traceRpc"`
+}
+
+// logParams are the parameters for the "log" notification. In addition to
+// the parameters defined in the API, they contain the ID of the request that
+// the plugin was handling when it logged the message.
+type logParams struct {
+	api.LogParams
+
+	// RequestID is the ID of the request from the client that the plugin was
+	// handling when it logged the message. It is optional.
+	RequestID *api.ID `json:"requestId,omitempty"`
+
+	// Level is the level of the message. It overrides the level in
+	// the embedded params so that the level names used by the client and
+	// the other SDKs are accepted.
+	Level LogLevel `json:"level"`
+}
+
+// UnmarshalJSON decodes the level either from a number or from a string. In
+// addition to the level names used by the client, the string may be "warning",
+// "fatal", or "critical" as used by the logging libraries in other languages.
+// The level is clamped between the trace and the error levels.
+func (l *LogLevel) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*l = clampLevel(logger.Level(n))
+
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid log level %s: %w", string(data), err)
+	}
+
+	switch strings.ToLower(s) {
+	case "warning":
+		s = "warn"
+	case "fatal", "critical":
+		s = "error"
+	}
+
+	var level logger.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+
+	*l = clampLevel(level)
+
+	return nil
+}
+
+// clampLevel returns level limited to the range of the levels the client uses.
+func clampLevel(level logger.Level) LogLevel {
+	return LogLevel(min(max(level, logger.LevelTrace), logger.LevelError))
+}
+
+// handshakeResult is the result of the "handshake" method with the optional
+// capabilities of the plugin.
+type handshakeResult struct {
+	api.HandshakeResult
+
+	// Capabilities contains the optional protocol features the plugin
+	// supports.
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// methodPing is the method that the client calls periodically on the idle
+// plugins to check that they still respond. The plugins should respond to it
+// with an empty object. An error response also tells that the plugin is alive.
+const methodPing = "ping"
+
+// The value types of the config entries that the client supports on top of
+// the value types of the SDK. The SDK does not know the types, so the plugins
+// read the values of the types as the basic JSON values that the client sends.
+const (
+	// DurationValue is a duration that is given as a string, such as "1m30s",
+	// or as a number of seconds. It is sent to the plugins as a string in
+	// the format of [time.Duration.String].
+	DurationValue api.ValueType = "duration"
+
+	// EnumValue is a string that must be one of the allowed values of the
+	// entry. The allowed values are given in the "enum" option of the entry.
+	EnumValue api.ValueType = "enum"
+
+	// FloatValue is a floating-point number.
+	FloatValue api.ValueType = "float"
+
+	// MapValue is a free-form table with string keys and values of any type.
+	// It is sent to the plugins as a JSON object.
+	MapValue api.ValueType = "map"
+
+	// StringMapValue is a free-form table with string keys and string values.
+	// It is sent to the plugins as a JSON object.
+	StringMapValue api.ValueType = "stringMap"
+)
+
+// Keys of the entry options in the config entries of the manifest. See
+// [EntryOptions].
+const (
+	deprecatedKey = "deprecated" // in the config entry
+	enumKey       = "enum"       // in the config entry
+	maxKey        = "max"        // in the config entry
+	minKey        = "min"        // in the config entry
+	negatableKey  = "negatable"  // in the flag object of the config entry
+	oneOfKey      = "oneOf"      // in the config entry
+	patternKey    = "pattern"    // in the config entry
+	replacedByKey = "replacedBy" // in the config entry
+	requiredKey   = "required"   // in the config entry
+)
+
+// persistentConfigKey is the key of the config entries in the manifest or in
+// a command object that are shared with the subcommands. See
+// [manifestOptions.PersistentConfig].
+const persistentConfigKey = "persistentConfig"
+
+// EntryOptions contains the options of a config entry that the client supports
+// on top of the config entries of the SDK. The plugins give the options in
+// the manifest next to the fields of the SDK types, and they are removed from
+// the manifest before it is decoded. See [stripEntryOptions].
+type EntryOptions struct {
+	// Min is the smallest allowed value of a numeric entry. For the lists of
+	// integers, it applies to each of the elements.
+	Min *float64 `json:"min,omitempty"`
+
+	// Max is the largest allowed value of a numeric entry. For the lists of
+	// integers, it applies to each of the elements.
+	Max *float64 `json:"max,omitempty"`
+
+	// Pattern is a regular expression that the values of a string entry must
+	// match. For the lists of strings, it applies to each of the elements.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Enum contains the allowed values of an entry of type [EnumValue].
+	Enum []string `json:"enum,omitempty"`
+
+	// OneOf contains the allowed values of a string or a numeric entry. For
+	// the lists, it applies to each of the elements. The values are either
+	// strings or float64 values depending on the type of the entry.
+	OneOf []any `json:"oneOf,omitempty"`
+
+	// Required tells whether the user must set the value of the entry in
+	// the config file, in an environment variable, or with a command-line
+	// flag.
+	Required bool `json:"required,omitempty"`
+
+	// Deprecated is the message that is shown to the user when they set
+	// the value of a deprecated entry. The entry is deprecated if either
+	// Deprecated or ReplacedBy is set.
+	Deprecated string `json:"deprecated,omitempty"`
+
+	// ReplacedBy is the key of the entry that replaces this deprecated entry.
+	// The replacement must be defined in the same config list and it must have
+	// the same type. If the user sets the value of this entry but not
+	// the value of the replacement, the value is also given to
+	// the replacement.
+	ReplacedBy string `json:"replacedBy,omitempty"`
+
+	// Negatable tells whether the boolean flag of the entry has an inverted
+	// "--no-<flag>" counterpart.
+	Negatable bool `json:"negatable,omitempty"`
+}
+
+// manifestOptions contains the entry options of the config entries in
+// a manifest. The options of each entry are stored by the key of the entry.
+type manifestOptions struct {
+	// Commands contains the entry options of the plugin and its commands by
+	// the paths of the commands. A path contains the names of the commands
+	// from the root of the plugin joined with spaces. The entries of
+	// the plugin itself have an empty path.
+	Commands map[string]map[string]EntryOptions `json:"commands,omitempty"`
+
+	// PersistentConfig contains the config entries that the plugin and its
+	// commands share with their subcommands by the paths of the commands.
+	// The values of the entries are resolved in the config of the command
+	// that declares them, and the flags of the entries can be given after
+	// any of the subcommands. The entry options of the entries are in
+	// Commands with the options of the other entries of the command.
+	PersistentConfig map[string][]api.ConfigEntry `json:"persistentConfig,omitempty"`
+
+	// Tasks contains the entry options of the task configs by the task types
+	// without the plugin domain.
+	Tasks map[string]map[string]EntryOptions `json:"tasks,omitempty"`
+}
+
+// IsMapType reports whether t is one of the free-form map types [MapValue] and
+// [StringMapValue].
+func IsMapType(t api.ValueType) bool {
+	return t == MapValue || t == StringMapValue
+}
+
+// IsDeprecated reports whether the entry is deprecated.
+func (o EntryOptions) IsDeprecated() bool {
+	return o.Deprecated != "" || o.ReplacedBy != ""
+}
+
+// empty reports whether o contains no options.
+func (o EntryOptions) empty() bool {
+	return o.Min == nil && o.Max == nil && o.Pattern == "" && len(o.Enum) == 0 && len(o.OneOf) == 0 &&
+		!o.Required && !o.Negatable && !o.IsDeprecated()
+}
+
+// stripEntryOptions removes the entry options that the client supports on top
+// of the SDK types from the manifest in data. It returns the manifest without
+// the options and the removed options. The returned options are nil if
+// the manifest has none.
+func stripEntryOptions(data []byte) ([]byte, *manifestOptions, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var manifest map[string]any
+	if err := d.Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w", err)
+	}
+
+	opts := &manifestOptions{
+		Commands:         make(map[string]map[string]EntryOptions),
+		PersistentConfig: make(map[string][]api.ConfigEntry),
+		Tasks:            make(map[string]map[string]EntryOptions),
+	}
+
+	changed, err := stripCommandOptions(manifest, "", opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tasks, _ := manifest["tasks"].([]any)
+	for _, t := range tasks {
+		task, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		taskType, _ := task["taskType"].(string)
+		options := make(map[string]EntryOptions)
+
+		ok, err := stripEntryList(task["config"], options)
+		if err != nil {
+			return nil, nil, fmt.Errorf("task %q: %w", taskType, err)
+		}
+
+		if len(options) > 0 {
+			opts.Tasks[taskType] = options
+		}
+
+		changed = changed || ok
+	}
+
+	if !changed {
+		return data, nil, nil
+	}
+
+	data, err = json.Marshal(manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode the manifest: %w", err)
+	}
+
+	return data, opts, nil
+}
+
+// stripCommandOptions removes the entry options from the config entries of
+// the manifest or command object obj and its subcommands, and the persistent
+// config entries from them. It records the options and the persistent entries
+// to opts by the command paths and reports whether anything was removed.
+func stripCommandOptions(obj map[string]any, path string, opts *manifestOptions) (bool, error) {
+	options := make(map[string]EntryOptions)
+
+	changed, err := stripEntryList(obj["config"], options)
+	if err != nil {
+		return false, err
+	}
+
+	persistent, err := stripPersistentConfig(obj, options)
+	if err != nil {
+		if path != "" {
+			return false, fmt.Errorf("command %q: %w", path, err)
+		}
+
+		return false, err
+	}
+
+	if len(options) > 0 {
+		opts.Commands[path] = options
+	}
+
+	if persistent != nil {
+		opts.PersistentConfig[path] = persistent
+		changed = true
+	}
+
+	commands, _ := obj["commands"].([]any)
+	for _, c := range commands {
+		cmd, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		name, _ := cmd["name"].(string)
+
+		ok, err := stripCommandOptions(cmd, strings.TrimSpace(path+" "+name), opts)
+		if err != nil {
+			return false, err
+		}
+
+		changed = changed || ok
+	}
+
+	return changed, nil
+}
+
+// stripPersistentConfig removes the persistent config entries from
+// the manifest or command object obj and returns them. The entry options of
+// the entries are recorded to options. The keys of the persistent entries may
+// not be the same as the keys of the other config entries of the object.
+func stripPersistentConfig(obj map[string]any, options map[string]EntryOptions) ([]api.ConfigEntry, error) {
+	raw, ok := obj[persistentConfigKey]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(obj, persistentConfigKey)
+
+	if _, err := stripEntryList(raw, options); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %q: %w", persistentConfigKey, err)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	var entries []api.ConfigEntry
+	if err = d.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%w: invalid %q: %w", errInvalidManifest, persistentConfigKey, err)
+	}
+
+	config, _ := obj["config"].([]any)
+	for _, e := range config {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		key, _ := entry["key"].(string)
+		if slices.ContainsFunc(entries, func(c api.ConfigEntry) bool { return c.Key == key }) {
+			return nil, fmt.Errorf("%w: duplicate config key %q in %q", errInvalidManifest, key, persistentConfigKey)
+		}
+	}
+
+	return slices.Clip(entries), nil
+}
+
+// stripConstraints removes the validation constraints from the config entry
+// object and records them to options. It reports whether any constraints were
+// removed. The constraints must fit the type of the entry.
+func stripConstraints(entry map[string]any, options *EntryOptions) (bool, error) {
+	key, _ := entry["key"].(string)
+	typ, _ := entry["type"].(string)
+	changed := false
+
+	for _, k := range []string{minKey, maxKey, patternKey, oneOfKey, requiredKey} {
+		v, ok := entry[k]
+		if !ok {
+			continue
+		}
+
+		delete(entry, k)
+
+		changed = true
+
+		if !constraintAllowed(k, api.ValueType(typ)) {
+			return false, fmt.Errorf("%w: option %q is not supported for %q of type %q", errInvalidManifest, k, key, typ)
+		}
+
+		var err error
+
+		switch k {
+		case minKey:
+			options.Min, err = manifestNumber(v)
+		case maxKey:
+			options.Max, err = manifestNumber(v)
+		case patternKey:
+			options.Pattern, ok = v.(string)
+			if !ok {
+				err = fmt.Errorf("%w: %v is not a string", typeconv.ErrConv, v)
+			} else if _, err = regexp.Compile(options.Pattern); err != nil {
+				err = fmt.Errorf("%w", err)
+			}
+		case oneOfKey:
+			options.OneOf, err = manifestOneOf(v, api.ValueType(typ))
+		case requiredKey:
+			if options.Required, ok = v.(bool); !ok {
+				err = fmt.Errorf("%w: %v is not a boolean", typeconv.ErrConv, v)
+			}
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("%w: invalid option %q of %q: %w", errInvalidManifest, k, key, err)
+		}
+	}
+
+	if options.Min != nil && options.Max != nil && *options.Min > *options.Max {
+		return false, fmt.Errorf("%w: option %q of %q is greater than option %q", errInvalidManifest, minKey, key, maxKey)
+	}
+
+	return changed, nil
+}
+
+// constraintAllowed reports whether the validation constraint with the given
+// key can be used with entries of type t.
+func constraintAllowed(key string, t api.ValueType) bool {
+	switch key {
+	case minKey, maxKey:
+		return t == api.IntValue || t == api.IntListValue || t == FloatValue
+	case patternKey:
+		return t == api.StringValue || t == api.StringListValue
+	case oneOfKey:
+		return t == api.StringValue || t == api.StringListValue || t == api.IntValue || t == api.IntListValue ||
+			t == FloatValue
+	default:
+		return true
+	}
+}
+
+// manifestNumber converts the number v that was decoded from a manifest to
+// a float64.
+func manifestNumber(v any) (*float64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v is not a number", typeconv.ErrConv, v)
+	}
+
+	x, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &x, nil
+}
+
+// manifestOneOf converts the list of allowed values v that was decoded from
+// a manifest to the values of [EntryOptions.OneOf] for an entry of type t.
+func manifestOneOf(v any, t api.ValueType) ([]any, error) {
+	a, ok := v.([]any)
+	if !ok || len(a) == 0 {
+		return nil, fmt.Errorf("%w: %v is not a list of values", typeconv.ErrConv, v)
+	}
+
+	result := make([]any, len(a))
+
+	for i, x := range a {
+		if t == api.StringValue || t == api.StringListValue {
+			if result[i], ok = x.(string); !ok {
+				return nil, fmt.Errorf("%w: %v is not a string", typeconv.ErrConv, x)
+			}
+
+			continue
+		}
+
+		n, err := manifestNumber(x)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = *n
+	}
+
+	return result, nil
+}
+
+// stripEntryList removes the entry options from the config entries in
+// the list raw. The alternatives of union values and the values of mapped
+// values are handled like the entries in the list. The function records
+// the options to opts by the keys of the entries and reports whether any
+// options were removed.
+func stripEntryList(raw any, opts map[string]EntryOptions) (bool, error) {
+	changed := false
+
+	// types contains the types of the plain entries in the list by their keys
+	// for checking the replacements of the deprecated entries.
+	types := make(map[string]any)
+
+	entries, _ := raw.([]any)
+	for _, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var err error
+
+		switch {
+		case entry["alternatives"] != nil:
+			ok, err = stripEntryList(entry["alternatives"], opts)
+		case entry["keyType"] != nil && entry["values"] != nil:
+			ok, err = stripEntryList(entry["values"], opts)
+		default:
+			ok, err = stripEntry(entry, opts)
+
+			if key, isString := entry["key"].(string); isString {
+				types[key] = entry["type"]
+			}
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		changed = changed || ok
+	}
+
+	for key, typ := range types {
+		replacement := opts[key].ReplacedBy
+		if replacement == "" {
+			continue
+		}
+
+		if t, ok := types[replacement]; !ok || replacement == key || t != typ {
+			return false, fmt.Errorf(
+				"%w: %q must be replaced by an entry of the same type in the same config, got %q",
+				errInvalidManifest,
+				key,
+				replacement,
+			)
+		}
+	}
+
+	return changed, nil
+}
+
+// stripEntry removes the entry options from the config entry object. It
+// records the options to opts by the key of the entry and reports whether any
+// options were removed.
+func stripEntry(entry map[string]any, opts map[string]EntryOptions) (bool, error) {
+	var options EntryOptions
+
+	key, _ := entry["key"].(string)
+	changed := false
+
+	if v, ok := entry[enumKey]; ok {
+		delete(entry, enumKey)
+
+		changed = true
+
+		a, ok := v.([]any)
+		if !ok {
+			return false, fmt.Errorf("%w: option %q of %q must be a list of strings", errInvalidManifest, enumKey, key)
+		}
+
+		for _, x := range a {
+			s, ok := x.(string)
+			if !ok {
+				return false, fmt.Errorf(
+					"%w: option %q of %q must be a list of strings",
+					errInvalidManifest,
+					enumKey,
+					key,
+				)
+			}
+
+			options.Enum = append(options.Enum, s)
+		}
+	}
+
+	if flag, ok := entry["flag"].(map[string]any); ok {
+		if v, ok := flag[negatableKey]; ok {
+			delete(flag, negatableKey)
+
+			changed = true
+
+			if options.Negatable, ok = v.(bool); !ok {
+				return false, fmt.Errorf(
+					"%w: option %q of the flag for %q must be a boolean",
+					errInvalidManifest,
+					negatableKey,
+					key,
+				)
+			}
+		}
+	}
+
+	for _, k := range []string{deprecatedKey, replacedByKey} {
+		v, ok := entry[k]
+		if !ok {
+			continue
+		}
+
+		delete(entry, k)
+
+		changed = true
+
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return false, fmt.Errorf("%w: option %q of %q must be a non-empty string", errInvalidManifest, k, key)
+		}
+
+		if k == deprecatedKey {
+			options.Deprecated = s
+		} else {
+			options.ReplacedBy = s
+		}
+	}
+
+	if entry["type"] == string(EnumValue) && len(options.Enum) == 0 {
+		return false, fmt.Errorf("%w: %q has type %q but no allowed values", errInvalidManifest, key, EnumValue)
+	}
+
+	ok, err := stripConstraints(entry, &options)
+	if err != nil {
+		return false, err
+	}
+
+	changed = changed || ok
+
+	if !options.empty() {
+		opts[key] = options
+	}
+
+	return changed, nil
+}
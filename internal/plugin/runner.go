@@ -0,0 +1,883 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/logger"
+	"github.com/reginald-project/reginald/internal/sudo"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/timing"
+)
+
+// Retrying the tasks that fail with an error that the plugin marked as
+// retryable.
+const (
+	// taskRetries is the maximum number of times a failed task is retried.
+	taskRetries = 2
+
+	// retryDelay is the delay before the first retry. The delay doubles
+	// before each later retry.
+	retryDelay = time.Second
+)
+
+// Statuses of the task events.
+const (
+	TaskStarted TaskStatus = iota
+	TaskSucceeded
+	TaskSkipped
+	TaskFailed
+	TaskUnknown
+)
+
+// Answers to the task confirmation prompt.
+const (
+	answerYes taskAnswer = iota
+	answerNo
+	answerAll
+	answerQuit
+)
+
+// Policies for handling the failed tasks.
+const (
+	// FailureStop stops the run when the task fails.
+	FailureStop FailurePolicy = "stop"
+
+	// FailureContinue continues the run with the rest of the tasks when
+	// the task fails, including the tasks that depend on it.
+	FailureContinue FailurePolicy = "continue"
+
+	// FailureSkipDependents continues the run when the task fails but skips
+	// the tasks that depend on it, directly or transitively.
+	FailureSkipDependents FailurePolicy = "skip-dependents"
+)
+
+// errFailurePolicy is returned when an invalid value is parsed into
+// [FailurePolicy].
+var errFailurePolicy = errors.New("invalid failure policy")
+
+// ErrTasksFailed is returned from the run when it was continued after one or
+// more of the tasks failed.
+var ErrTasksFailed = errors.New("tasks failed")
+
+// FailurePolicy tells what the runner does when a task fails. The empty value
+// means that the policy is not set and the default policy of the run is used.
+type FailurePolicy string
+
+// RunOptions are the options for running the tasks.
+type RunOptions struct {
+	// DryRun tells the plugins to only report the changes that the tasks
+	// would make instead of applying them.
+	DryRun bool
+
+	// Confirm tells the runner to ask the user to confirm each task before
+	// running it. The tasks are not confirmed if the standard input is not
+	// a terminal.
+	Confirm bool
+
+	// BackupDir is the directory where the tasks should store the backups of
+	// the files they replace or remove. It is passed to the plugins.
+	BackupDir fspath.Path
+
+	// OnEvent is called for every task event during the run if it is not nil.
+	OnEvent func(TaskEvent)
+
+	// Only contains the IDs of the task instances to run. If it is empty, all
+	// of the tasks are run. The tasks in Only are run even if they have already
+	// been run so that the same tasks can be run again, for example, when
+	// the files are watched for changes.
+	Only []string
+
+	// OnFailure is the policy for the failed tasks that do not set a policy of
+	// their own. If it is empty, the run stops on the first failed task.
+	OnFailure FailurePolicy
+}
+
+// A TaskEvent is an event in the life cycle of a task instance during a run.
+type TaskEvent struct {
+	// Err is the error that caused the task to fail. It is only set for
+	// the events with the status [TaskFailed].
+	Err error
+
+	// ID is the ID of the task instance.
+	ID string
+
+	// TaskType is the type of the task instance.
+	TaskType string
+
+	// Status is the status of the task at the time of the event.
+	Status TaskStatus
+
+	// Duration is the time it took to run the task. It is zero for the events
+	// with the status [TaskStarted].
+	Duration time.Duration
+
+	// Stage is the index of the execution stage of the task, starting from
+	// zero. The stages are run in order.
+	Stage int
+
+	// Changes is the number of changes that the task made, or would make in
+	// a dry run. It is only set for the events with the status
+	// [TaskSucceeded].
+	Changes int
+
+	// Elevated tells whether the task runs with elevated privileges.
+	Elevated bool
+}
+
+// TaskStatus is the status of a task in a [TaskEvent].
+type TaskStatus int
+
+// A taskBatch is a group of tasks in a stage that are run together.
+type taskBatch struct {
+	// plugin is the plugin that runs the tasks in a single batch. If it is
+	// nil, the batch contains only one task that is run the normal way.
+	plugin *externalPlugin
+
+	// cfgs are the configs of the tasks in the batch.
+	cfgs []*TaskConfig
+}
+
+// taskAnswer is the type of the answers to the task confirmation prompt.
+type taskAnswer int
+
+// runFailures keeps track of the failed tasks during a run.
+type runFailures struct {
+	// failed contains the IDs of the tasks that have failed during the run.
+	failed []string
+
+	// blocked maps the IDs of the tasks that must be skipped to the IDs of
+	// the failed or declined tasks they depend on.
+	blocked map[string]string
+}
+
+// ParseFailurePolicy parses the given string into a [FailurePolicy]. The empty
+// string is parsed as the unset policy.
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch p := FailurePolicy(strings.ToLower(s)); p {
+	case "", FailureStop, FailureContinue, FailureSkipDependents:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %q", errFailurePolicy, s)
+	}
+}
+
+// RunTasks runs the task instances of the current run in the execution order
+// that was resolved in Init. The stages are run one after another. When a task
+// fails, the failure policy of the task, or the policy in opts if the task does
+// not set one, decides whether the run stops or continues. If the run is
+// continued after failed tasks, the function returns [ErrTasksFailed] at
+// the end of the run.
+//
+// The tasks in a stage that belong to the same plugin are sent to the plugin
+// as a single batch if the plugin supports batches.
+//
+// Before any of the tasks are run, they are validated with [Store.ValidateTasks]
+// and the run fails if any of the tasks are invalid. If any of the tasks are
+// elevated, the credentials for sudo are acquired once before running
+// the tasks so that the user is asked for the password at most once during
+// the run. The credentials are not needed in a dry run.
+//
+// If the tasks should be confirmed, the user is asked whether to run each of
+// the tasks. If the user chooses to quit, the function returns [ErrQuit].
+func (s *Store) RunTasks(ctx context.Context, opts RunOptions) error {
+	s.runOpts = opts
+	confirm := opts.Confirm && terminal.Interactive()
+
+	failures := runFailures{failed: nil, blocked: make(map[string]string)}
+
+	for _, id := range opts.Only {
+		if cfg := s.taskConfig(id); cfg != nil {
+			cfg.run = false
+			cfg.skipped = false
+			cfg.unknown = false
+			cfg.changes = 0
+		}
+	}
+
+	// The plugins for the tasks are started before running any of the tasks so
+	// that a plugin that cannot be started does not leave the run half-done.
+	if err := s.ValidateTasks(ctx, opts); err != nil {
+		return err
+	}
+
+	if !opts.DryRun && s.hasElevated(opts) {
+		slog.InfoContext(ctx, "acquiring sudo credentials for elevated tasks")
+
+		if err := sudo.Authenticate(ctx); err != nil {
+			return fmt.Errorf("failed to acquire privileges for elevated tasks: %w", err)
+		}
+
+		defer sudo.Release()
+	}
+
+	for stage, nodes := range s.sortedTasks {
+		nodes = s.skipBlocked(ctx, opts, stage, opts.selected(nodes), failures)
+		if len(nodes) == 0 {
+			continue
+		}
+
+		slog.DebugContext(ctx, "running task stage", "n", stage+1, "tasks", len(nodes))
+
+		for _, batch := range s.taskBatches(nodes, confirm) {
+			if batch.plugin != nil {
+				if err := s.runBatch(ctx, opts, stage, batch, &failures); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			cfg := batch.cfgs[0]
+
+			if confirm {
+				answer, err := confirmTask(ctx, cfg)
+				if err != nil {
+					return err
+				}
+
+				switch answer {
+				case answerYes:
+				case answerNo:
+					slog.InfoContext(ctx, "task skipped by user", "task", cfg.ID)
+
+					// The tasks that depend on the declined task cannot be
+					// run without it.
+					cfg.skipped = true
+					s.blockDependents(cfg, &failures)
+
+					opts.emit(newTaskEvent(cfg, stage, TaskSkipped, nil, 0))
+
+					continue
+				case answerAll:
+					confirm = false
+				case answerQuit:
+					return ErrQuit
+				default:
+					panic(fmt.Sprintf("invalid task confirmation answer: %d", answer))
+				}
+			}
+
+			opts.emit(newTaskEvent(cfg, stage, TaskStarted, nil, 0))
+
+			start := time.Now()
+			stop := timing.Start("task " + cfg.ID)
+
+			err := retryTask(ctx, s, cfg, runTimed(ctx, s, cfg))
+
+			stop()
+
+			if err != nil {
+				opts.emit(newTaskEvent(cfg, stage, TaskFailed, err, time.Since(start)))
+
+				if err = s.taskFailed(ctx, opts, cfg, err, &failures); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			opts.emit(newTaskEvent(cfg, stage, resultStatus(cfg), nil, time.Since(start)))
+		}
+	}
+
+	if len(failures.failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrTasksFailed, strings.Join(failures.failed, ", "))
+	}
+
+	return nil
+}
+
+// ValidateTasks validates the task instances that are run with opts by calling
+// "validateTask" on the plugins of the tasks. The plugins are started if they
+// are not running. The tasks of the plugins that do not support the "validate"
+// capability are not validated. The problems of all of the tasks are collected
+// and returned as [ValidationErrors] so that the user sees all of them at once.
+// An error response from the plugin is reported as a problem of the task.
+func (s *Store) ValidateTasks(ctx context.Context, opts RunOptions) error {
+	// The plugins are started before the validation so that a plugin that
+	// cannot be started does not leave the run half-done.
+	if err := s.Require(ctx, s.taskPlugins(opts)...); err != nil {
+		return err
+	}
+
+	var errs ValidationErrors
+
+	// The tasks are validated in the order of the config so that the problems
+	// are reported in the same order every time.
+	for i := range s.taskConfigs {
+		cfg := &s.taskConfigs[i]
+
+		if s.taskNode(cfg.ID) == nil || len(opts.Only) > 0 && !slices.Contains(opts.Only, cfg.ID) {
+			continue
+		}
+
+		task := s.Task(cfg.TaskType)
+		if task == nil || task.Plugin == nil {
+			continue
+		}
+
+		if cfg.Elevate && !s.Capabilities(task.Plugin).Elevate {
+			errs = append(errs, &TaskValidationError{
+				ID:       cfg.ID,
+				TaskType: cfg.TaskType,
+				Problems: []string{
+					fmt.Sprintf("plugin %q does not support elevated tasks", task.Plugin.Manifest().Name),
+				},
+			})
+
+			continue
+		}
+
+		if !s.Capabilities(task.Plugin).Validate {
+			slog.Log(
+				ctx,
+				slog.Level(logger.LevelTrace),
+				"plugin does not support validation",
+				"task",
+				cfg.ID,
+				"plugin",
+				task.Plugin.Manifest().Name,
+			)
+
+			continue
+		}
+
+		tt := task.TaskType[strings.IndexByte(task.TaskType, '/')+1:]
+
+		problems, err := callValidateTask(ctx, task.Plugin, tt, cfg)
+
+		var rpcErr *api.Error
+		if errors.As(err, &rpcErr) {
+			problems, err = []string{rpcErr.Error()}, nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to validate task %q: %w", cfg.ID, err)
+		}
+
+		if len(problems) > 0 {
+			slog.DebugContext(ctx, "task config is invalid", "task", cfg.ID, "problems", problems)
+
+			errs = append(errs, &TaskValidationError{ID: cfg.ID, TaskType: cfg.TaskType, Problems: problems})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// runBatch runs the tasks in the batch with a single message to the plugin
+// and emits the events for them. The failed tasks are handled according to
+// their failure policies, and the function returns the error of the first
+// failed task that stops the run.
+func (s *Store) runBatch(ctx context.Context, opts RunOptions, stage int, batch taskBatch, failures *runFailures) error {
+	for _, cfg := range batch.cfgs {
+		opts.emit(newTaskEvent(cfg, stage, TaskStarted, nil, 0))
+	}
+
+	start := time.Now()
+	stop := timing.Start(fmt.Sprintf("task batch (%d tasks)", len(batch.cfgs)))
+
+	errs := runTaskBatch(ctx, s, batch.plugin, batch.cfgs)
+
+	stop()
+
+	// The tasks are run with one message so they share the duration.
+	d := time.Since(start)
+
+	var firstErr error
+
+	for i, cfg := range batch.cfgs {
+		if errs != nil && errs[i] != nil {
+			// The retries are run one task at a time.
+			errs[i] = retryTask(ctx, s, cfg, errs[i])
+		}
+
+		if errs != nil && errs[i] != nil {
+			opts.emit(newTaskEvent(cfg, stage, TaskFailed, errs[i], d))
+
+			if err := s.taskFailed(ctx, opts, cfg, errs[i], failures); err != nil && firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		opts.emit(newTaskEvent(cfg, stage, resultStatus(cfg), nil, d))
+	}
+
+	return firstErr
+}
+
+// resultStatus returns the status of the task instance that was run without
+// an error.
+func resultStatus(cfg *TaskConfig) TaskStatus {
+	switch {
+	case cfg.skipped:
+		return TaskSkipped
+	case cfg.unknown:
+		return TaskUnknown
+	default:
+		return TaskSucceeded
+	}
+}
+
+// taskFailed handles the failure of the given task according to its failure
+// policy. It returns the error for the failed task if the run should be
+// stopped. Otherwise, it records the failure, prints it, and, if the policy
+// says so, marks the tasks that depend on the failed task to be skipped.
+func (s *Store) taskFailed(
+	ctx context.Context,
+	opts RunOptions,
+	cfg *TaskConfig,
+	err error,
+	failures *runFailures,
+) error {
+	policy := cfg.OnFailure
+	if policy == "" {
+		policy = opts.OnFailure
+	}
+
+	if policy == "" || policy == FailureStop {
+		return fmt.Errorf("task %q failed: %w", cfg.ID, err)
+	}
+
+	slog.WarnContext(ctx, "task failed, continuing the run", "task", cfg.ID, "policy", policy, "err", err)
+	terminal.Errorf("Error: task %q failed: %v\n", cfg.ID, err)
+
+	failures.failed = append(failures.failed, cfg.ID)
+
+	if policy == FailureSkipDependents {
+		s.blockDependents(cfg, failures)
+	}
+
+	return nil
+}
+
+// blockDependents marks the tasks that depend on the given task, directly or
+// through other tasks, to be skipped as the given task has failed or it was
+// not run.
+func (s *Store) blockDependents(cfg *TaskConfig, failures *runFailures) {
+	node := s.taskNode(cfg.ID)
+	if node == nil {
+		panic("no task node found for task ID " + cfg.ID)
+	}
+
+	queue := slices.Clone(node.dependents)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+
+		if _, ok := failures.blocked[dep.id]; ok {
+			continue
+		}
+
+		failures.blocked[dep.id] = cfg.ID
+		queue = append(queue, dep.dependents...)
+	}
+}
+
+// skipBlocked emits the skipped events for the given tasks in the stage that
+// depend on a failed or declined task and returns the rest of the tasks.
+func (s *Store) skipBlocked(
+	ctx context.Context,
+	opts RunOptions,
+	stage int,
+	nodes []*taskNode,
+	failures runFailures,
+) []*taskNode {
+	if len(failures.blocked) == 0 {
+		return nodes
+	}
+
+	result := make([]*taskNode, 0, len(nodes))
+
+	for _, node := range nodes {
+		blocker, ok := failures.blocked[node.id]
+		if !ok {
+			result = append(result, node)
+
+			continue
+		}
+
+		cfg := s.taskConfig(node.id)
+		if cfg == nil {
+			panic("no task config found for task ID " + node.id)
+		}
+
+		if slices.Contains(failures.failed, blocker) {
+			slog.InfoContext(ctx, "task skipped due to failed dependency", "task", cfg.ID, "failed", blocker)
+			diag.Report(
+				ctx,
+				diag.DependencySkip,
+				fmt.Sprintf("Skipping task %q as it depends on failed task %q", cfg.ID, blocker),
+			)
+		} else {
+			slog.InfoContext(ctx, "task skipped due to declined dependency", "task", cfg.ID, "declined", blocker)
+			diag.Report(
+				ctx,
+				diag.DependencySkip,
+				fmt.Sprintf("Skipping task %q as it depends on skipped task %q", cfg.ID, blocker),
+			)
+		}
+
+		cfg.skipped = true
+
+		opts.emit(newTaskEvent(cfg, stage, TaskSkipped, nil, 0))
+	}
+
+	return result
+}
+
+// taskBatches groups the tasks in the stage into batches. The tasks of
+// the same external plugin that supports batches are grouped together into
+// a batch with the plugin set so that they can be sent to the plugin with
+// a single message. The other tasks are put into batches of their own without
+// the plugin. The batches are in the order of their first tasks in the stage.
+// If the tasks are confirmed, each task is put into a batch of its own.
+func (s *Store) taskBatches(stage []*taskNode, confirm bool) []taskBatch {
+	var batches []taskBatch
+
+	for _, node := range stage {
+		cfg := s.taskConfig(node.id)
+		if cfg == nil {
+			panic("no task config found for task ID " + node.id)
+		}
+
+		plugin := s.batchPlugin(cfg)
+		if confirm || plugin == nil {
+			batches = append(batches, taskBatch{plugin: nil, cfgs: []*TaskConfig{cfg}})
+
+			continue
+		}
+
+		i := slices.IndexFunc(batches, func(b taskBatch) bool { return b.plugin == plugin })
+		if i == -1 {
+			batches = append(batches, taskBatch{plugin: plugin, cfgs: []*TaskConfig{cfg}})
+
+			continue
+		}
+
+		batches[i].cfgs = append(batches[i].cfgs, cfg)
+	}
+
+	// A batch of only one task is run the normal way.
+	for i := range batches {
+		if len(batches[i].cfgs) == 1 {
+			batches[i].plugin = nil
+		}
+	}
+
+	return batches
+}
+
+// batchPlugin returns the plugin of the task if the task can be run in a batch,
+// or nil if it cannot.
+func (s *Store) batchPlugin(cfg *TaskConfig) *externalPlugin {
+	// The batches are sent with a single message so the timeouts of
+	// the individual tasks cannot be enforced in them.
+	if cfg.run || cfg.Timeout > 0 {
+		return nil
+	}
+
+	task := s.Task(cfg.TaskType)
+	if task == nil || task.Plugin == nil {
+		return nil
+	}
+
+	plugin, ok := task.Plugin.(*externalPlugin)
+	if !ok {
+		return nil
+	}
+
+	caps := s.Capabilities(plugin)
+	if !caps.Batch || (s.runOpts.DryRun && !caps.Diff) {
+		return nil
+	}
+
+	return plugin
+}
+
+// String returns the string representation of s. The values are stable and
+// they are used in the machine-readable output.
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskStarted:
+		return "started"
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskSkipped:
+		return "skipped"
+	case TaskFailed:
+		return "failed"
+	case TaskUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("TaskStatus(%d)", int(s))
+	}
+}
+
+// UnmarshalText assigns the value from the given textual representation to p.
+func (p *FailurePolicy) UnmarshalText(data []byte) error {
+	policy, err := ParseFailurePolicy(string(data))
+	if err != nil {
+		return err
+	}
+
+	*p = policy
+
+	return nil
+}
+
+// newTaskEvent returns a new event for the given task in the stage.
+func newTaskEvent(cfg *TaskConfig, stage int, status TaskStatus, err error, d time.Duration) TaskEvent {
+	changes := 0
+	if status == TaskSucceeded {
+		changes = cfg.changes
+	}
+
+	return TaskEvent{
+		Err:      err,
+		ID:       cfg.ID,
+		TaskType: cfg.TaskType,
+		Status:   status,
+		Duration: d,
+		Stage:    stage,
+		Changes:  changes,
+		Elevated: cfg.Elevate,
+	}
+}
+
+// emit calls the event handler of o with the given event if it is set.
+func (o RunOptions) emit(e TaskEvent) {
+	if o.OnEvent != nil {
+		o.OnEvent(e)
+	}
+}
+
+// selected returns the nodes of the given stage that should be run according to
+// o.
+func (o RunOptions) selected(stage []*taskNode) []*taskNode {
+	if len(o.Only) == 0 {
+		return stage
+	}
+
+	var nodes []*taskNode
+
+	for _, node := range stage {
+		if slices.Contains(o.Only, node.id) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes
+}
+
+// runTimed runs the given task with [RunTask]. If the task has a timeout and it
+// does not finish in time, the call to the plugin is canceled and the function
+// returns [ErrTaskTimeout]. As a plugin that does not handle the cancellations
+// keeps running the task, its process is killed and the plugin is started
+// again when it is needed the next time.
+func runTimed(ctx context.Context, store *Store, cfg *TaskConfig) error {
+	if cfg.Timeout <= 0 {
+		return RunTask(ctx, store, cfg)
+	}
+
+	task := store.Task(cfg.TaskType)
+	if task == nil || task.Plugin == nil {
+		panic("no plugin found for task type " + cfg.TaskType)
+	}
+
+	// The plugin is started before the timeout so that the process of
+	// the plugin does not depend on the context of the single task.
+	if err := store.Require(ctx, task.Plugin.Manifest().Name); err != nil {
+		return err
+	}
+
+	taskCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	err := RunTask(taskCtx, store, cfg)
+	if err == nil || ctx.Err() != nil || !errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	slog.WarnContext(ctx, "task timed out", "task", cfg.ID, "timeout", cfg.Timeout, "err", err)
+
+	if e, ok := task.Plugin.(*externalPlugin); ok && e.cmd != nil && !store.Capabilities(e).Cancel {
+		slog.WarnContext(ctx, "killing plugin that cannot cancel the timed out task", "plugin", e.manifest.Name)
+
+		if err = store.reset(ctx, e); err != nil {
+			return fmt.Errorf("%w after %s, and stopping the plugin failed: %w", ErrTaskTimeout, cfg.Timeout, err)
+		}
+	}
+
+	return fmt.Errorf("%w after %s", ErrTaskTimeout, cfg.Timeout)
+}
+
+// retryTask runs the given task again with [runTimed] if err, the error from
+// running it, was marked as retryable by the plugin. The task is retried at
+// most [taskRetries] times with a growing delay. It returns the error from
+// the last run, or nil if a retry succeeds.
+func retryTask(ctx context.Context, store *Store, cfg *TaskConfig, err error) error {
+	delay := retryDelay
+
+	for attempt := 1; attempt <= taskRetries && Retryable(err); attempt++ {
+		slog.WarnContext(ctx, "retrying task", "task", cfg.ID, "attempt", attempt, "delay", delay, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		err = runTimed(ctx, store, cfg)
+		delay *= 2
+	}
+
+	return err
+}
+
+// confirmTask asks the user whether the given task should be run.
+func confirmTask(ctx context.Context, cfg *TaskConfig) (taskAnswer, error) {
+	prompt := fmt.Sprintf("Apply task %q (%s)? [Y/n/a/q] ", cfg.ID, cfg.TaskType)
+	if cfg.Elevate {
+		prompt = fmt.Sprintf("Apply task %q (%s) as root? [Y/n/a/q] ", cfg.ID, cfg.TaskType)
+	}
+
+	for {
+		answer, err := terminal.Ask(ctx, prompt)
+		if err != nil {
+			return answerQuit, fmt.Errorf("failed to confirm task %q: %w", cfg.ID, err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "y", "yes":
+			return answerYes, nil
+		case "n", "no":
+			return answerNo, nil
+		case "a", "all":
+			return answerAll, nil
+		case "q", "quit":
+			return answerQuit, nil
+		default:
+			terminal.PrintErrf("Invalid input. Please enter \"y\" (yes), \"n\" (no), \"a\" (all), or \"q\" (quit).\n")
+		}
+	}
+}
+
+// Plan returns the configs of the task instances of the current run in
+// the stages in which they are run. The tasks in a stage depend only on
+// the tasks in the earlier stages.
+func (s *Store) Plan() [][]TaskConfig {
+	plan := make([][]TaskConfig, 0, len(s.sortedTasks))
+
+	for _, stage := range s.sortedTasks {
+		cfgs := make([]TaskConfig, 0, len(stage))
+
+		for _, node := range stage {
+			cfg := s.taskConfig(node.id)
+			if cfg == nil {
+				panic("no task config found for task ID " + node.id)
+			}
+
+			cfgs = append(cfgs, *cfg)
+		}
+
+		plan = append(plan, cfgs)
+	}
+
+	return plan
+}
+
+// taskPlugins returns the names of the plugins that provide the task types of
+// the task instances that are run with opts. Each plugin is listed only once.
+func (s *Store) taskPlugins(opts RunOptions) []string {
+	var names []string
+
+	for _, stage := range s.sortedTasks {
+		for _, node := range opts.selected(stage) {
+			cfg := s.taskConfig(node.id)
+			if cfg == nil {
+				panic("no task config found for task ID " + node.id)
+			}
+
+			task := s.Task(cfg.TaskType)
+			if task == nil || task.Plugin == nil {
+				continue
+			}
+
+			if name := task.Plugin.Manifest().Name; !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// hasElevated reports whether any of the task instances that are run with opts
+// are elevated.
+func (s *Store) hasElevated(opts RunOptions) bool {
+	for _, stage := range s.sortedTasks {
+		for _, node := range opts.selected(stage) {
+			if cfg := s.taskConfig(node.id); cfg != nil && cfg.Elevate {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// taskNode returns the node of the task with the given ID in the sorted tasks
+// of the current run. It returns nil if there is no such task.
+func (s *Store) taskNode(id string) *taskNode {
+	for _, stage := range s.sortedTasks {
+		for _, node := range stage {
+			if node.id == id {
+				return node
+			}
+		}
+	}
+
+	return nil
+}
+
+// taskConfig returns a pointer to the task config with the given ID in
+// the task configs of the current run. It returns nil if there is no such task.
+func (s *Store) taskConfig(id string) *TaskConfig {
+	for i := range s.taskConfigs {
+		if s.taskConfigs[i].ID == id {
+			return &s.taskConfigs[i]
+		}
+	}
+
+	return nil
+}
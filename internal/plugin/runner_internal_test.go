@@ -0,0 +1,157 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+//nolint:paralleltest // sets the default terminal
+func TestRunTasks_DeclinedDependency(t *testing.T) {
+	ctx := t.Context()
+
+	term := terminal.New(ctx)
+	term.Init(false, false, true, terminal.ColorNever, terminal.DefaultTheme())
+	term.SetAnswers(strings.NewReader("n\n"))
+
+	prev := terminal.Default()
+	terminal.Set(term)
+
+	collector := diag.NewCollector(diag.Config{}) //nolint:exhaustruct // default severities
+	prevCollector := diag.Default()
+	diag.Set(collector)
+
+	t.Cleanup(func() {
+		terminal.Set(prev)
+		diag.Set(prevCollector)
+
+		if err := term.Close(); err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	})
+
+	//nolint:exhaustruct // only the tasks are needed
+	manifest := &api.Manifest{
+		Name:   "test",
+		Domain: "test",
+		Tasks:  []api.Task{{TaskType: "run", Description: "Test task."}},
+	}
+
+	store, err := newStore(ctx, newBuiltinPlugins([]*api.Manifest{manifest}))
+	if err != nil {
+		t.Fatalf("newStore() = %v", err)
+	}
+
+	store.RegisterPluginRuntime(nil, store.PluginByName("test"))
+
+	var calls atomic.Int32
+
+	service := func(context.Context, *Store, string, any) (any, error) {
+		calls.Add(1)
+
+		return &RunTaskResult{Changes: nil}, nil
+	}
+
+	//nolint:exhaustruct // only the dependency chain matters
+	cfgs := []TaskConfig{
+		{TaskType: "test/run", ID: "first"},
+		{TaskType: "test/run", ID: "second", Requires: []string{"first"}},
+	}
+
+	if err = store.Init(ctx, func(string) Service { return service }, cfgs); err != nil {
+		t.Fatalf("Init() = %v", err)
+	}
+
+	got := make(map[string]TaskStatus)
+
+	//nolint:exhaustruct // the other options use their defaults
+	opts := RunOptions{
+		Confirm: true,
+		OnEvent: func(e TaskEvent) { got[e.ID] = e.Status },
+	}
+
+	// Only the first task may be asked as the answers run out after it.
+	if err = store.RunTasks(ctx, opts); err != nil {
+		t.Fatalf("RunTasks() = %v", err)
+	}
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("service called %d times, want 0", n)
+	}
+
+	for _, id := range []string{"first", "second"} {
+		if got[id] != TaskSkipped {
+			t.Errorf("status of %q = %v, want %v", id, got[id], TaskSkipped)
+		}
+	}
+
+	want := diag.Diagnostic{
+		Class:   diag.DependencySkip,
+		Message: `Skipping task "second" as it depends on skipped task "first"`,
+	}
+
+	if warns := collector.Warnings(); !slices.Contains(warns, want) {
+		t.Errorf("Warnings() = %v, want to contain %v", warns, want)
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct { //nolint:govet // don't care about this in tests
+		name string
+		cfg  TaskConfig
+		want TaskStatus
+	}{
+		{
+			name: "succeeded",
+			cfg:  TaskConfig{run: true}, //nolint:exhaustruct // only the result fields matter
+			want: TaskSucceeded,
+		},
+		{
+			name: "changes",
+			cfg:  TaskConfig{run: true, changes: 2}, //nolint:exhaustruct // only the result fields matter
+			want: TaskSucceeded,
+		},
+		{
+			name: "skipped",
+			cfg:  TaskConfig{skipped: true}, //nolint:exhaustruct // only the result fields matter
+			want: TaskSkipped,
+		},
+		{
+			name: "unknown",
+			cfg:  TaskConfig{run: true, unknown: true}, //nolint:exhaustruct // only the result fields matter
+			want: TaskUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := resultStatus(&tt.cfg); got != tt.want {
+				t.Errorf("resultStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtimes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/anttikivi/semver"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// versionTimeout is the timeout for running the runtime executable to get its
+// version.
+const versionTimeout = 5 * time.Second
+
+// Errors returned by Check.
+var (
+	ErrRuntimeNotFound = errors.New("runtime not found")
+	ErrRuntimeVersion  = errors.New("runtime version is too old")
+)
+
+// versionPattern matches the version number in the output of the runtime
+// executables, for example "Python 3.12.1" or "v22.3.0".
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`) //nolint:gochecknoglobals // used like a constant
+
+// A Status is the status of a runtime on the system. It is reported by
+// [Check].
+type Status struct {
+	// Version is the version of the runtime executable. It is nil if
+	// the version could not be resolved.
+	Version *semver.Version
+
+	// Required is the minimum version of the runtime. It is nil if any version
+	// is accepted.
+	Required *semver.Version
+
+	// Name is the normalized name of the runtime.
+	Name string
+
+	// Executable is the path to the runtime executable. It is empty if
+	// the runtime was not found.
+	Executable fspath.Path
+}
+
+// Check looks up the runtime for the given runtime specification from
+// the system and reports its status. It returns [ErrRuntimeNotFound] if no
+// executable of the runtime is found in the path and [ErrRuntimeVersion] if
+// the version of the found executable is lower than the required version.
+func Check(ctx context.Context, apiRuntime *api.Runtime) (Status, error) {
+	status := Status{
+		Version:    nil,
+		Required:   nil,
+		Name:       normalizeName(apiRuntime.Name),
+		Executable: "",
+	}
+
+	if apiRuntime.Version != "" {
+		v, err := semver.ParseLax(apiRuntime.Version)
+		if err != nil {
+			return status, fmt.Errorf("invalid version %q for runtime %q: %w", apiRuntime.Version, status.Name, err)
+		}
+
+		status.Required = v
+	}
+
+	for _, name := range executableNames(apiRuntime.Name) {
+		exe, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		status.Executable = fspath.Path(exe)
+
+		break
+	}
+
+	if status.Executable == "" {
+		return status, fmt.Errorf("%w: %s", ErrRuntimeNotFound, status.Name)
+	}
+
+	status.Version = executableVersion(ctx, status.Executable)
+
+	if status.Version != nil && status.Required != nil && status.Version.Compare(status.Required) < 0 {
+		return status, fmt.Errorf(
+			"%w: %s %s is installed but %s is required",
+			ErrRuntimeVersion,
+			status.Name,
+			status.Version,
+			status.Required,
+		)
+	}
+
+	return status, nil
+}
+
+// executableNames returns the names of the executables that can provide
+// the runtime with the given name in the order they should be looked up.
+func executableNames(name string) []string {
+	switch normalizeName(name) {
+	case "node":
+		return []string{"node"}
+	case "python":
+		return []string{"python3", "python"}
+	default:
+		return []string{name}
+	}
+}
+
+// executableVersion runs the given runtime executable with the "--version"
+// flag and parses the version from its output. It returns nil if the version
+// cannot be resolved.
+func executableVersion(ctx context.Context, exe fspath.Path) *semver.Version {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+
+	var out bytes.Buffer
+
+	// Some runtimes, like older Python versions, print the version to
+	// the standard error.
+	cmd := exec.CommandContext(ctx, string(exe), "--version")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil
+	}
+
+	v, err := semver.ParseLax(versionPattern.FindString(out.String()))
+	if err != nil {
+		return nil
+	}
+
+	return v
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
 	"os/exec"
 	"slices"
@@ -61,7 +62,7 @@ type runtime struct {
 //
 // Resolve modifies store and cfg.
 func Resolve(ctx context.Context, store *plugin.Store, cfg *config.Config) error {
-	for _, p := range store.Plugins {
+	for p := range store.VisitPlugins() {
 		apiRuntime := p.Manifest().Runtime
 		if apiRuntime == nil || apiRuntime.Name == "" {
 			slog.Log(ctx, slog.Level(logger.LevelTrace), "plugin has no runtime, skipping", "plugin", p.Manifest().Name)
@@ -158,14 +159,10 @@ func addProviderTask(ctx context.Context, taskType string, store *plugin.Store,
 }
 
 // findProviderTypes finds task types that can provide the given runtime.
-func findProviderTypes(tasks []*plugin.Task, rt *runtime) []*plugin.Task {
-	if len(tasks) == 0 {
-		panic("empty tasks")
-	}
-
+func findProviderTypes(tasks iter.Seq[*plugin.Task], rt *runtime) []*plugin.Task {
 	var ts []*plugin.Task
 
-	for _, t := range tasks {
+	for t := range tasks {
 		if rt.n == normalizeName(t.Provides) {
 			ts = append(ts, t)
 		}
@@ -252,7 +249,7 @@ func pluginProvider(ctx context.Context, rt *runtime, p plugin.Plugin, store *pl
 		return fmt.Errorf("%w: %s for %s", errNoProvider, rt.n, p.Manifest().Name)
 	}
 
-	ts := findProviderTypes(store.Tasks, rt)
+	ts := findProviderTypes(store.TaskTypes(), rt)
 	if len(ts) == 0 {
 		return fmt.Errorf("%w: %s", errNoProvider, rt.n)
 	}
@@ -0,0 +1,196 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// exitWait is the time that a method call that got no response waits for
+// the plugin process to exit so that a sandbox violation can be reported
+// instead of the missing response.
+const exitWait = time.Second
+
+// Errors returned by the sandbox.
+var (
+	// ErrSandbox is returned when the plugin process violates the limits of
+	// its sandbox or the sandbox cannot be set up.
+	ErrSandbox = errors.New("plugin sandbox")
+
+	errByteSize = errors.New("invalid byte size")
+)
+
+// A Sandbox contains the restrictions for the process of an external plugin.
+// The zero value does not restrict the process.
+type Sandbox struct {
+	// Env is the allow-list of the environment variables that are passed to
+	// the plugin process. An entry that ends with "*" allows all of
+	// the variables with the prefix before it. If Env is nil, the plugin
+	// inherits the whole environment of Reginald.
+	Env []string `mapstructure:"env"`
+
+	// Dir is the working directory of the plugin process. If it is empty,
+	// the plugin is run in its plugin directory.
+	Dir fspath.Path `mapstructure:"dir"`
+
+	// CPU is the limit of the CPU time of the plugin process in seconds. Zero
+	// means no limit. It is only supported on Linux.
+	CPU int `mapstructure:"cpu"`
+
+	// Memory is the limit of the address space of the plugin process. Zero
+	// means no limit. It is only supported on Linux.
+	Memory ByteSize `mapstructure:"memory"`
+
+	// Network tells whether the plugin process may access the network. If it
+	// is false, the process is run in a network namespace of its own that has
+	// no network interfaces. It is only supported on Linux.
+	Network *bool `mapstructure:"network"`
+}
+
+// ByteSize is a size in bytes. It can be given as a number of bytes or as
+// a string with a binary unit suffix, for example "512M" or "2GiB".
+type ByteSize uint64
+
+// processExit holds the result of a plugin process after it has exited.
+type processExit struct {
+	// err is the sandbox violation that ended the process, or nil if
+	// the process was not ended by its sandbox. It must only be read after
+	// done has been closed.
+	err error
+
+	// done is closed when the process has exited.
+	done chan struct{}
+}
+
+// IsZero reports whether s does not restrict the plugin process.
+func (s Sandbox) IsZero() bool {
+	return s.Env == nil && s.Dir == "" && s.CPU == 0 && s.Memory == 0 && s.Network == nil
+}
+
+// String returns the size in the largest binary unit that divides it evenly.
+func (b ByteSize) String() string {
+	units := []string{"", "K", "M", "G", "T"}
+	n := uint64(b)
+	i := 0
+
+	for n >= 1024 && n%1024 == 0 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+
+	return strconv.FormatUint(n, 10) + units[i]
+}
+
+// UnmarshalText assigns the value from the given textual representation to b.
+func (b *ByteSize) UnmarshalText(data []byte) error {
+	s := strings.ToUpper(strings.TrimSpace(string(data)))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	shift := 0
+
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			shift = 10 * (i + 1) //nolint:mnd // binary units
+			s = strings.TrimSpace(s[:len(s)-1])
+		}
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", errByteSize, string(data))
+	}
+
+	if n > (1<<64-1)>>shift {
+		return fmt.Errorf("%w: %q is too large", errByteSize, string(data))
+	}
+
+	*b = ByteSize(n << shift)
+
+	return nil
+}
+
+// apply sets up the restrictions of s that are set before starting the process
+// of cmd.
+func (s Sandbox) apply(cmd *exec.Cmd) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	if s.Dir != "" {
+		ok, err := s.Dir.IsDir()
+		if err != nil {
+			return fmt.Errorf("%w: failed to check working directory %q: %w", ErrSandbox, s.Dir, err)
+		}
+
+		if !ok {
+			return fmt.Errorf("%w: working directory %q is not a directory", ErrSandbox, s.Dir)
+		}
+
+		cmd.Dir = string(s.Dir)
+	}
+
+	if s.Env != nil {
+		cmd.Env = s.environ(os.Environ())
+	}
+
+	s.applyPlatform(cmd)
+
+	return nil
+}
+
+// startError returns the error for the failed start of a process in s.
+// Creating the namespaces for denying the network access fails on systems that
+// do not allow them for unprivileged users, so the error is reported as
+// a sandbox error.
+func (s Sandbox) startError(err error) error {
+	if s.denyNetwork() {
+		return fmt.Errorf("%w: failed to deny network access: %w", ErrSandbox, err)
+	}
+
+	return err
+}
+
+// denyNetwork reports whether s denies the network access.
+func (s Sandbox) denyNetwork() bool {
+	return s.Network != nil && !*s.Network
+}
+
+// environ returns the variables in env that are in the allow-list of s.
+func (s Sandbox) environ(env []string) []string {
+	allowed := make([]string, 0, len(s.Env))
+
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+
+		for _, pattern := range s.Env {
+			prefix, isPrefix := strings.CutSuffix(pattern, "*")
+			if name == pattern || isPrefix && strings.HasPrefix(name, prefix) {
+				allowed = append(allowed, kv)
+
+				break
+			}
+		}
+	}
+
+	return allowed
+}
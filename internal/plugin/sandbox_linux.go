@@ -0,0 +1,117 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cpuGrace is the time in seconds between the soft and the hard limit of
+// the CPU time. The process receives SIGXCPU when it reaches the soft limit and
+// it is killed when it reaches the hard limit.
+const cpuGrace = 5
+
+// check reports an error if s has options that are not supported on
+// the current platform.
+func (s Sandbox) check() error {
+	if s.CPU < 0 {
+		return fmt.Errorf("%w: CPU time limit must not be negative: %d", ErrSandbox, s.CPU)
+	}
+
+	return nil
+}
+
+// applyPlatform sets up the platform-specific restrictions of s for cmd before
+// it is started. Without network access, the process is run in a new network
+// namespace. If Reginald is not run as root, the network namespace is created
+// in a new user namespace that maps only the current user and group.
+func (s Sandbox) applyPlatform(cmd *exec.Cmd) {
+	if !s.denyNetwork() {
+		return
+	}
+
+	attr := &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+
+	if uid := os.Geteuid(); uid != 0 {
+		gid := os.Getegid()
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+
+	cmd.SysProcAttr = attr
+}
+
+// limit sets the resource limits of s for the process with the given PID. It
+// is called right after the process has been started.
+func (s Sandbox) limit(pid int) error {
+	if s.CPU > 0 {
+		lim := &unix.Rlimit{Cur: uint64(s.CPU), Max: uint64(s.CPU) + cpuGrace}
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, lim, nil); err != nil {
+			return fmt.Errorf("%w: failed to set CPU time limit: %w", ErrSandbox, err)
+		}
+	}
+
+	if s.Memory > 0 {
+		lim := &unix.Rlimit{Cur: uint64(s.Memory), Max: uint64(s.Memory)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, lim, nil); err != nil {
+			return fmt.Errorf("%w: failed to set memory limit: %w", ErrSandbox, err)
+		}
+	}
+
+	return nil
+}
+
+// exitError returns the sandbox violation that ended the process with
+// the given state, or nil if the process was not ended by the limits of s.
+// Exceeding the memory limit makes the allocations of the process fail, and
+// how the process handles that is up to the process, so any abnormal exit is
+// reported as a possible violation when the memory is limited.
+func (s Sandbox) exitError(state *os.ProcessState) error {
+	if state == nil || state.Success() {
+		return nil
+	}
+
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return nil
+	}
+
+	if s.CPU > 0 && status.Signaled() {
+		used := state.UserTime() + state.SystemTime()
+
+		if status.Signal() == syscall.SIGXCPU ||
+			status.Signal() == syscall.SIGKILL && used >= time.Duration(s.CPU)*time.Second {
+			return fmt.Errorf("%w: CPU time limit of %ds exceeded", ErrSandbox, s.CPU)
+		}
+	}
+
+	// SIGKILL is also used for killing the plugins that do not respond so it
+	// is not counted as a violation of the memory limit.
+	if s.Memory > 0 && (!status.Signaled() || status.Signal() != syscall.SIGKILL) {
+		return fmt.Errorf("%w: process ended with %s and may have exceeded the memory limit of %s", ErrSandbox, state, s.Memory)
+	}
+
+	return nil
+}
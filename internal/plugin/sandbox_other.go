@@ -0,0 +1,55 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// check reports an error if s has options that are not supported on
+// the current platform.
+func (s Sandbox) check() error {
+	switch {
+	case s.CPU != 0:
+		return fmt.Errorf("%w: CPU time limit is only supported on Linux", ErrSandbox)
+	case s.Memory != 0:
+		return fmt.Errorf("%w: memory limit is only supported on Linux", ErrSandbox)
+	case s.denyNetwork():
+		return fmt.Errorf("%w: denying network access is only supported on Linux", ErrSandbox)
+	default:
+		return nil
+	}
+}
+
+// applyPlatform sets up the platform-specific restrictions of s for cmd before
+// it is started. There are none on the current platform.
+func (s Sandbox) applyPlatform(_ *exec.Cmd) {}
+
+// limit sets the resource limits of s for the process with the given PID.
+// There are none on the current platform.
+func (s Sandbox) limit(_ int) error {
+	return nil
+}
+
+// exitError returns the sandbox violation that ended the process with
+// the given state. The process cannot violate the sandbox on the current
+// platform.
+func (s Sandbox) exitError(_ *os.ProcessState) error {
+	return nil
+}
@@ -20,21 +20,45 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"iter"
 	"log/slog"
+	"math"
 	"os"
 	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/errhint"
 	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/fsutil"
 	"github.com/reginald-project/reginald/internal/logger"
 	"github.com/reginald-project/reginald/internal/panichandler"
+	"github.com/reginald-project/reginald/internal/terminal"
+	"github.com/reginald-project/reginald/internal/timing"
 	"golang.org/x/sync/errgroup"
 )
 
+// DefaultShutdownTimeout is the default time that each plugin has for shutting
+// down gracefully before its process is terminated.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// DefaultMaxProtocolErrors is the default number of protocol errors after
+// which a plugin is quarantined.
+const DefaultMaxProtocolErrors = 10
+
+// DefaultMaxMessageSize is the default maximum length of the content of
+// a message that a plugin sends.
+const DefaultMaxMessageSize ByteSize = 16 << 20
+
+// killGracePeriod is the time that a plugin process has for exiting after it
+// has been sent the termination signal before it is killed.
+const killGracePeriod = 2 * time.Second
+
 // A Store stores the plugins, provides information on them, and has functions
 // for using the plugins within the program.
 type Store struct {
@@ -49,34 +73,112 @@ type Store struct {
 	// task IDs that provide those runtimes.
 	providers map[string]string
 
-	// Plugins is the list of plugins.
-	Plugins []Plugin
+	// plugins is the list of plugins. The built-in plugins are before
+	// the external plugins.
+	plugins []Plugin
+
+	// commands is the list of the top-level commands that are defined in
+	// the plugins.
+	commands []*Command
+
+	// tasks is the list of task types that are defined in the plugins.
+	tasks []*Task
 
-	// Commands is the list of commands that are defined in the plugins.
-	Commands []*Command
+	// taskConfigs contains the task configs for the current run.
+	taskConfigs []TaskConfig
 
-	// Tasks is the list of tasks that are defined in the plugins.
-	Tasks []*Task
+	// capabilities contains the capabilities that the started plugins reported
+	// in their handshake results. The keys of the map are the plugin names.
+	capabilities map[string]Capabilities
 
-	// TaskConfigs contains the task configs for the current run.
-	TaskConfigs []TaskConfig
+	// configs contains the resolved plugin-level configs that are sent to
+	// the plugins that support the "initialize" capability. The keys of the
+	// map are the plugin names.
+	configs map[string]api.KeyValues
 
 	// sortedTasks contains the tasks sorted into the correct execution order.
 	// Each member slice of the slice contains tasks that can be executed in
 	// parallel after the tasks in the slice before them are executed.
 	sortedTasks [][]*taskNode
+
+	// runOpts are the options for running the tasks in the current run.
+	runOpts RunOptions
+
+	// restart tells the store to restart the plugins that have stopped
+	// responding to the pings before they are used again.
+	restart bool
 }
 
 // NewStore finds the available built-in and external plugin manifests from
 // the given search paths, loads and decodes them, and returns a new Store with
 // the plugins created from them.
-func NewStore(ctx context.Context, builtin []*api.Manifest, wd fspath.Path, paths []fspath.Path) (*Store, error) {
+//
+// If cacheFile is not empty, the decoded manifests are cached in it. The cached
+// manifests of a search path are used instead of reading the manifests again
+// as long as the plugin directories and the manifest files in the search path
+// have not changed.
+func NewStore(
+	ctx context.Context,
+	builtin []*api.Manifest,
+	wd fspath.Path,
+	paths []fspath.Path,
+	cacheFile fspath.Path,
+) (*Store, error) {
 	// The built-in plugins should be added first as they are already included
 	// with the program. The external plugins are validated while they are being
 	// loaded so by loading the built-in plugins first, we can make sure that no
 	// external plugin collides with them.
-	manifests := slices.Clone(builtin)
-	plugins := make([]Plugin, 0, len(manifests))
+	plugins := newBuiltinPlugins(builtin)
+
+	var pathErrs PathErrors
+
+	cache := loadManifestCache(ctx, cacheFile)
+
+	external, err := readAllSearchPaths(ctx, wd, paths, cache)
+	if err != nil && !errors.As(err, &pathErrs) {
+		return nil, err
+	}
+
+	// The cache is only an optimization so the run can continue even if it
+	// cannot be written.
+	if err := cache.write(); err != nil {
+		slog.DebugContext(ctx, "failed to write plugin manifest cache", "err", err)
+	}
+
+	plugins = append(plugins, external...)
+
+	store, err := newStore(ctx, plugins)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pathErrs) > 0 {
+		return store, pathErrs
+	}
+
+	return store, nil
+}
+
+// NewDevStore returns a new Store with the built-in plugins and the external
+// plugin in the plugin directory dir. The plugin search paths and the manifest
+// cache are not used. The store is meant for running a plugin that is being
+// developed.
+func NewDevStore(ctx context.Context, builtin []*api.Manifest, dir fspath.Path) (*Store, error) {
+	plugins := newBuiltinPlugins(builtin)
+
+	p, err := readExternalPlugin(dir.Join(ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	plugins = append(plugins, p)
+
+	return newStore(ctx, plugins)
+}
+
+// newBuiltinPlugins returns the built-in plugins for the given manifests.
+func newBuiltinPlugins(manifests []*api.Manifest) []Plugin {
+	plugins := make([]Plugin, 0, len(manifests)+1)
 
 	for _, m := range manifests {
 		plugins = append(plugins, &builtinPlugin{
@@ -86,15 +188,12 @@ func NewStore(ctx context.Context, builtin []*api.Manifest, wd fspath.Path, path
 		})
 	}
 
-	var pathErrs PathErrors
-
-	external, err := readAllSearchPaths(ctx, wd, paths)
-	if err != nil && !errors.As(err, &pathErrs) {
-		return nil, err
-	}
-
-	plugins = append(plugins, external...)
+	return plugins
+}
 
+// newStore validates the given plugins and returns a new Store with them and
+// the commands and tasks defined in them.
+func newStore(ctx context.Context, plugins []Plugin) (*Store, error) {
 	if err := validate(plugins); err != nil {
 		return nil, err
 	}
@@ -119,21 +218,25 @@ func NewStore(ctx context.Context, builtin []*api.Manifest, wd fspath.Path, path
 	slog.Log(ctx, slog.Level(logger.LevelTrace), "created commands", "cmds", logCmds(commands))
 	slog.Log(ctx, slog.Level(logger.LevelTrace), "created tasks", "tasks", logTasks(tasks))
 
-	store := &Store{
-		Plugins:        plugins,
-		Commands:       commands,
-		Tasks:          tasks,
-		TaskConfigs:    nil,
+	return &Store{
+		plugins:        plugins,
+		commands:       commands,
+		tasks:          tasks,
+		taskConfigs:    nil,
+		capabilities:   make(map[string]Capabilities),
+		configs:        make(map[string]api.KeyValues),
 		pluginRuntimes: nil,
 		providers:      nil,
+		restart:        false,
 		sortedTasks:    nil,
-	}
-
-	if len(pathErrs) > 0 {
-		return store, pathErrs
-	}
+		runOpts:        RunOptions{DryRun: false, Confirm: false, BackupDir: "", OnEvent: nil, Only: nil, OnFailure: ""},
+	}, nil
+}
 
-	return store, nil
+// Capabilities returns the capabilities that the given plugin reported in its
+// handshake. The plugin must be started before calling this function.
+func (s *Store) Capabilities(plugin Plugin) Capabilities {
+	return s.capabilities[plugin.Manifest().Name]
 }
 
 // Command returns the command with the given name from the store. If prev is
@@ -143,7 +246,7 @@ func (s *Store) Command(prev *Command, name string) *Command {
 	var cmds []*Command
 
 	if prev == nil {
-		cmds = s.Commands
+		cmds = s.commands
 	} else {
 		cmds = prev.Commands
 	}
@@ -157,12 +260,24 @@ func (s *Store) Command(prev *Command, name string) *Command {
 	return nil
 }
 
-// Init loads the required plugins and performs a handshake with them. It uses
-// the command that should be run and the tasks to determine which plugins
-// should be loaded. It also resolves the execution order for the tasks, taking
-// the tasks that install the required runtimes into account.
+// CommandsFor returns an iterator over the subcommands of the given command in
+// the order they are defined in. If cmd is nil, the iterator is over
+// the top-level commands of the store.
+func (s *Store) CommandsFor(cmd *Command) iter.Seq[*Command] {
+	if cmd == nil {
+		return slices.Values(s.commands)
+	}
+
+	return slices.Values(cmd.Commands)
+}
+
+// Init prepares the store for the run. It sets up the built-in plugins and
+// resolves the execution order for the tasks, taking the tasks that install
+// the required runtimes into account. The plugins are not started here; they
+// are started with [Store.Require] when the command or the tasks that use them
+// are run.
 func (s *Store) Init(ctx context.Context, serviceResolver func(string) Service, tasks []TaskConfig) error {
-	for _, plugin := range s.Plugins {
+	for _, plugin := range s.plugins {
 		if plugin.External() {
 			continue
 		}
@@ -204,14 +319,14 @@ func (s *Store) Init(ctx context.Context, serviceResolver func(string) Service,
 		slog.Log(ctx, slog.Level(logger.LevelTrace), "task stage", "n", i+1, "id", ids)
 	}
 
-	s.TaskConfigs = tasks
+	s.taskConfigs = tasks
 
 	return nil
 }
 
 // Len returns the number of plugins in the store.
 func (s *Store) Len() int {
-	return len(s.Plugins)
+	return len(s.plugins)
 }
 
 // LogValue implements [slog.LogValuer] for Store. It returns a group value for
@@ -219,12 +334,12 @@ func (s *Store) Len() int {
 func (s *Store) LogValue() slog.Value {
 	var attrs []slog.Attr
 
-	names := make([]string, len(s.Plugins))
-	for i, p := range s.Plugins {
+	names := make([]string, len(s.plugins))
+	for i, p := range s.plugins {
 		names[i] = p.Manifest().Name
 	}
 
-	attrs = append(attrs, slog.Any("plugins", names), slog.Any("commands", logCmds(s.Commands)))
+	attrs = append(attrs, slog.Any("plugins", names), slog.Any("commands", logCmds(s.commands)))
 
 	return slog.GroupValue(attrs...)
 }
@@ -265,34 +380,291 @@ func (s *Store) RegisterProvider(taskID string, runtime runtime) {
 	s.providers[runtime.Name()] = taskID
 }
 
+// SetLogLevel sets the minimum level of the log messages that are logged from
+// the plugin with the given name or domain. The level overrides the level of
+// the default logger for the plugin. The built-in plugins use the default logger
+// directly so the level has no effect on them.
+func (s *Store) SetLogLevel(name string, level slog.Level) error {
+	p, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	if e, ok := p.(*externalPlugin); ok {
+		e.logLevel = &level
+	}
+
+	return nil
+}
+
+// SetOutputLimits sets the limits of the standard error output of the plugin
+// with the given name or domain. It must be called before the plugin is
+// started. The built-in plugins have no output of their own so the limits have
+// no effect on them.
+func (s *Store) SetOutputLimits(name string, limits OutputLimits) error {
+	p, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	if err := limits.check(); err != nil {
+		return err
+	}
+
+	if e, ok := p.(*externalPlugin); ok {
+		e.outputLimits = limits
+	}
+
+	return nil
+}
+
+// SetShutdownTimeout sets the time that the plugin with the given name or
+// domain has for shutting down gracefully in [Store.ShutdownAll]. The built-in
+// plugins have nothing to shut down so the timeout has no effect on them.
+func (s *Store) SetShutdownTimeout(name string, timeout time.Duration) error {
+	p, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	if e, ok := p.(*externalPlugin); ok {
+		e.shutdownTimeout = timeout
+	}
+
+	return nil
+}
+
+// SetSandbox sets the restrictions for the process of the plugin with the given
+// name or domain. It must be called before the plugin is started. The built-in
+// plugins are run within the Reginald process so they cannot be sandboxed.
+func (s *Store) SetSandbox(name string, sandbox Sandbox) error {
+	p, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	e, ok := p.(*externalPlugin)
+	if !ok {
+		return fmt.Errorf("%w: built-in plugin %q cannot be sandboxed", ErrSandbox, p.Manifest().Name)
+	}
+
+	if err := sandbox.check(); err != nil {
+		return err
+	}
+
+	e.sandbox = sandbox
+
+	return nil
+}
+
+// DataDir returns the data directory of the external plugin with the given name
+// or domain.
+func (s *Store) DataDir(name string) (fspath.Path, error) {
+	e, err := s.dataPlugin(name)
+	if err != nil {
+		return "", err
+	}
+
+	return e.dataDir, nil
+}
+
+// Dir returns the plugin directory of the external plugin with the given name.
+// It reports false if there is no external plugin with the name.
+func (s *Store) Dir(name string) (fspath.Path, bool) {
+	e, ok := s.PluginByName(name).(*externalPlugin)
+	if !ok {
+		return "", false
+	}
+
+	return e.dir, true
+}
+
+// CleanData removes the data directory of the external plugin with the given
+// name or domain and everything in it. The plugin must not be running. It
+// reports whether the directory existed. The directory is created again when
+// the plugin is started the next time.
+func (s *Store) CleanData(name string) (bool, error) {
+	e, err := s.dataPlugin(name)
+	if err != nil {
+		return false, err
+	}
+
+	if e.cmd != nil {
+		return false, fmt.Errorf("cannot remove the data of plugin %q while it is running", e.manifest.Name)
+	}
+
+	dir := e.dataDir
+
+	if _, err = os.Lstat(string(dir)); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check data directory %q: %w", dir, err)
+	}
+
+	if err = os.RemoveAll(string(dir)); err != nil {
+		return false, fmt.Errorf("failed to remove data directory %q: %w", dir, err)
+	}
+
+	return true, nil
+}
+
+// SetDataDir sets the data directories of the external plugins to
+// the directories named after the plugins within dir. The data directory of
+// a plugin is created when the plugin is started. It must be called before
+// the plugins are started.
+func (s *Store) SetDataDir(dir fspath.Path) {
+	for _, p := range s.plugins {
+		if e, ok := p.(*externalPlugin); ok {
+			e.dataDir = dir.Join(e.manifest.Name)
+		}
+	}
+}
+
+// SetDownloader sets the downloader that downloads the files the external
+// plugins request with the "download" method. It must be called before
+// the plugins are started.
+func (s *Store) SetDownloader(d Downloader) {
+	for _, p := range s.plugins {
+		if e, ok := p.(*externalPlugin); ok {
+			e.downloader = d
+		}
+	}
+}
+
+// SetConfigs sets the resolved plugin configs that are sent to the plugins in
+// the "initialize" call. The keys of cfgs are the plugin domains. It must be
+// called before the plugins are started.
+func (s *Store) SetConfigs(cfgs api.KeyValues) error {
+	for _, kv := range cfgs {
+		// The config parsing has already warned about unknown plugins.
+		p, err := s.lookup(kv.Key)
+		if err != nil {
+			continue
+		}
+
+		cfg, err := kv.Configs()
+		if err != nil {
+			return fmt.Errorf("failed to get config for %q: %w", p.Manifest().Name, err)
+		}
+
+		// The configs of the commands are sent when the commands are run.
+		s.configs[p.Manifest().Name] = slices.DeleteFunc(slices.Clone(cfg), func(kv api.KeyVal) bool {
+			return slices.ContainsFunc(p.Manifest().Commands, func(c *api.Command) bool { return c.Name == kv.Key })
+		})
+	}
+
+	return nil
+}
+
+// SetMaxProtocolErrors sets the number of protocol errors after which
+// the external plugins are quarantined. If n is zero, the plugins are
+// quarantined only for the errors after which their messages cannot be read
+// anymore. It must be called before the plugins are started.
+func (s *Store) SetMaxProtocolErrors(n int) {
+	for _, p := range s.plugins {
+		if e, ok := p.(*externalPlugin); ok {
+			e.maxProtocolErrors = int64(n)
+		}
+	}
+}
+
+// SetMaxMessageSize sets the maximum length of the content of a message that
+// the external plugins send. A plugin that sends a longer message is
+// quarantined. If size is zero, the default size is used. It must be called
+// before the plugins are started.
+func (s *Store) SetMaxMessageSize(size ByteSize) {
+	if size == 0 {
+		size = DefaultMaxMessageSize
+	}
+
+	for _, p := range s.plugins {
+		if e, ok := p.(*externalPlugin); ok {
+			e.maxMessageSize = int(min(size, ByteSize(math.MaxInt)))
+		}
+	}
+}
+
+// TraceRPC sets the external plugins to write the messages sent to and received
+// from them to trace files in dir. It must be called before the plugins are
+// started.
+func (s *Store) TraceRPC(dir fspath.Path) {
+	for _, p := range s.plugins {
+		if e, ok := p.(*externalPlugin); ok {
+			e.traceDir = dir
+		}
+	}
+}
+
 // ShutdownAll requests all of the started plugins to shut down and notfies them
-// to exit. It will ultimately kill the processes for the plugins that fail to
-// shut down gracefully.
+// to exit. Each plugin has its own shutdown timeout for exiting gracefully,
+// and the plugins that fail to do so are terminated and ultimately killed.
+// The timeouts are not cut short when ctx is canceled so that the plugins can
+// still clean up after an interrupt. The plugins that had to be killed are
+// reported as diagnostics.
 func (s *Store) ShutdownAll(ctx context.Context) error {
-	g, gctx := errgroup.WithContext(ctx)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		killed []string
+	)
 
-	for _, plugin := range s.Plugins {
+	for _, plugin := range s.plugins {
 		handlePanic := panichandler.WithStackTrace()
 
-		g.Go(func() error {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
 			defer handlePanic()
 
-			return shutdown(gctx, plugin)
-		})
+			forced, err := shutdown(ctx, plugin)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if forced {
+				killed = append(killed, plugin.Manifest().Name)
+			}
+
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
 	}
 
-	if err := g.Wait(); err != nil {
-		return fmt.Errorf("failed to shut down plugins: %w", err)
+	wg.Wait()
+
+	slices.Sort(killed)
+
+	for _, name := range killed {
+		diag.Report(ctx, diag.PluginKilled, fmt.Sprintf("Plugin %q did not shut down in time and was killed", name))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to shut down plugins: %w", errors.Join(errs...))
 	}
 
 	return nil
 }
 
+// TaskConfigs returns an iterator over the task configs of the current run.
+// The configs are set with [Store.Init].
+func (s *Store) TaskConfigs() iter.Seq[TaskConfig] {
+	return slices.Values(s.taskConfigs)
+}
+
+// TaskTypes returns an iterator over the task types that are defined in
+// the plugins.
+func (s *Store) TaskTypes() iter.Seq[*Task] {
+	return slices.Values(s.tasks)
+}
+
 // Task returns that task with the given task type from the store. The task type
 // must be the full-qualified task type meaning that it must be specified as
 // "<domain>/<task>".
 func (s *Store) Task(tt string) *Task {
-	for _, t := range s.Tasks {
+	for _, t := range s.tasks {
 		if t.TaskType == tt {
 			return t
 		}
@@ -301,9 +673,87 @@ func (s *Store) Task(tt string) *Task {
 	return nil
 }
 
+// Require starts the plugins with the given names and performs the handshakes
+// with them. The plugins that have already been started are skipped so that
+// only the plugins that are actually needed by the run are started, and each
+// of them only once.
+func (s *Store) Require(ctx context.Context, names ...string) error {
+	for _, name := range names {
+		if e, ok := s.PluginByName(name).(*externalPlugin); ok && e.quarantined.Load() {
+			return e.quarantineError()
+		}
+
+		if _, ok := s.capabilities[name]; ok {
+			if err := s.restartHung(ctx, name); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		plugin := s.PluginByName(name)
+		if plugin == nil {
+			return fmt.Errorf("%w: %s", errUnknownPlugin, name)
+		}
+
+		if err := s.start(ctx, plugin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PluginByName returns the plugin with the given name from the store. It
+// returns nil if there is no such plugin.
+func (s *Store) PluginByName(name string) Plugin {
+	for _, p := range s.plugins {
+		if p.Manifest().Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// VisitPlugins returns an iterator over the plugins in the store. The built-in
+// plugins are visited before the external plugins.
+func (s *Store) VisitPlugins() iter.Seq[Plugin] {
+	return slices.Values(s.plugins)
+}
+
+// dataPlugin returns the external plugin with the given name or domain that
+// has a data directory.
+func (s *Store) dataPlugin(name string) (*externalPlugin, error) {
+	p, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	e, ok := p.(*externalPlugin)
+	if !ok || e.dataDir == "" {
+		return nil, fmt.Errorf("%w: %s", errNoDataDir, p.Manifest().Name)
+	}
+
+	return e, nil
+}
+
+// lookup returns the plugin with the given name or domain.
+func (s *Store) lookup(name string) (Plugin, error) {
+	if p := s.PluginByName(name); p != nil {
+		return p, nil
+	}
+
+	if i := slices.IndexFunc(s.plugins, func(p Plugin) bool { return p.Manifest().Domain == name }); i >= 0 {
+		return s.plugins[i], nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errUnknownPlugin, name)
+}
+
 // resolveRuntime resolves a missing runtime by finding the providing task and
 // installing the runtime using it.
-func (s *Store) resolveRuntime(ctx context.Context, rt runtime, tasks []TaskConfig) error {
+func (s *Store) resolveRuntime(ctx context.Context, rt runtime) error {
 	if rt == nil {
 		return nil
 	}
@@ -317,7 +767,7 @@ func (s *Store) resolveRuntime(ctx context.Context, rt runtime, tasks []TaskConf
 
 	ok = false
 
-	for _, tcfg := range tasks {
+	for _, tcfg := range s.taskConfigs {
 		if tcfg.ID == tID {
 			cfg = tcfg
 			ok = true
@@ -335,12 +785,13 @@ func (s *Store) resolveRuntime(ctx context.Context, rt runtime, tasks []TaskConf
 		return fmt.Errorf("%w: %s with task ID %s", errNoProvider, rt.Name(), tID)
 	}
 
-	return RunTask(ctx, s, &cfg, tasks)
+	return RunTask(ctx, s, &cfg)
 }
 
 // start resolves the runtime for the given plugin, starts its process, and
-// performs the handshake with it.
-func (s *Store) start(ctx context.Context, plugin Plugin, tasks []TaskConfig) error {
+// performs the handshake with it. The plugins should be started through
+// [Store.Require].
+func (s *Store) start(ctx context.Context, plugin Plugin) error {
 	slog.InfoContext(ctx, "starting plugin", "plugin", plugin.Manifest().Name)
 
 	if e, ok := plugin.(*externalPlugin); ok && e.cmd != nil {
@@ -363,7 +814,7 @@ func (s *Store) start(ctx context.Context, plugin Plugin, tasks []TaskConfig) er
 	}
 
 	if rt != nil && !rt.Present() {
-		if err := s.resolveRuntime(ctx, rt, tasks); err != nil {
+		if err := s.resolveRuntime(ctx, rt); err != nil {
 			return err
 		}
 	}
@@ -377,26 +828,111 @@ func (s *Store) start(ctx context.Context, plugin Plugin, tasks []TaskConfig) er
 
 		slog.ErrorContext(ctx, "error when initializing the store, shutting down plugins")
 
-		if err = shutdown(ctx, plugin); err != nil {
+		if _, err = shutdown(ctx, plugin); err != nil {
 			fmt.Fprintf(os.Stderr, "Error when shutting down plugins: %v\n", err)
+
+			return
+		}
+
+		// The plugin is marked as not started so that it is not shut down
+		// again with the rest of the plugins.
+		if e, ok := plugin.(*externalPlugin); ok {
+			e.cmd = nil
+			e.conn = nil
+			e.doneCh = make(chan error)
 		}
 	}()
 
+	// The time to start the plugin process is included in the handshake as
+	// the plugin can respond only after it has started.
+	stop := timing.Start("handshake " + plugin.Manifest().Name)
+
 	if err = plugin.start(ctx); err != nil {
 		return fmt.Errorf("failed to start %q: %w", plugin.Manifest().Name, err)
 	}
 
-	if err = callHandshake(ctx, plugin); err != nil {
+	var caps Capabilities
+
+	caps, err = callHandshake(ctx, plugin)
+
+	stop()
+
+	if err != nil {
 		return fmt.Errorf("handshake with %q failed: %w", plugin.Manifest().Name, err)
 	}
 
+	if caps.Supports("initialize") {
+		stop = timing.Start("initialize " + plugin.Manifest().Name)
+		err = callInitialize(ctx, plugin, s.configs[plugin.Manifest().Name])
+
+		stop()
+
+		if err != nil {
+			return errhint.Wrapf(
+				fmt.Errorf("%w %q: %w", errInitialize, plugin.Manifest().Name, err),
+				"check the config of the plugin in \"plugins.%s\"",
+				plugin.Manifest().Domain,
+			)
+		}
+	}
+
+	s.capabilities[plugin.Manifest().Name] = caps
+
+	if e, ok := plugin.(*externalPlugin); ok {
+		e.caps = caps
+		e.lastActive.Store(time.Now().UnixNano())
+		e.alive.Store(true)
+	}
+
 	slog.InfoContext(ctx, "plugin started", "plugin", plugin.Manifest().Name)
 
 	return nil
 }
 
-// readAllSearchPaths loads plugins from all of the given search paths.
-func readAllSearchPaths(ctx context.Context, wd fspath.Path, paths []fspath.Path) ([]Plugin, error) {
+// ReadManifest reads the plugin manifest from path, decodes it, and validates
+// it the same way as the manifests that are loaded from the search paths.
+func ReadManifest(path fspath.Path) (*api.Manifest, error) {
+	plugin, err := readExternalPlugin(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin.manifest, nil
+}
+
+// ResolveSearchPath returns the absolute, cleaned path for the given plugin
+// search path. Relative paths are resolved from wd.
+func ResolveSearchPath(wd, path fspath.Path) (fspath.Path, error) {
+	if path.IsAbs() {
+		return path.Clean(), nil
+	}
+
+	var err error
+
+	// TODO: Is this sufficient?
+	if strings.HasPrefix(path.String(), "~") {
+		path, err = path.Abs()
+	} else {
+		path, err = fspath.NewAbs(string(wd), string(path))
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create absolute path from %q: %w", path, err)
+	}
+
+	return path.Clean(), nil
+}
+
+// readAllSearchPaths loads plugins from all of the given search paths. If cache
+// is not nil, the plugins are created from the cached manifests for the search
+// paths that have not changed, and the manifests from the other search paths
+// are stored in the cache.
+func readAllSearchPaths(
+	ctx context.Context,
+	wd fspath.Path,
+	paths []fspath.Path,
+	cache *manifestCache,
+) ([]Plugin, error) {
 	var (
 		mu       sync.Mutex
 		errMu    sync.Mutex
@@ -412,23 +948,11 @@ func readAllSearchPaths(ctx context.Context, wd fspath.Path, paths []fspath.Path
 		g.Go(func() error {
 			defer handlePanic()
 
-			var err error
-
-			if !path.IsAbs() {
-				// TODO: Is this sufficient?
-				if strings.HasPrefix(path.String(), "~") {
-					path, err = path.Abs()
-				} else {
-					path, err = fspath.NewAbs(string(wd), string(path))
-				}
-
-				if err != nil {
-					return fmt.Errorf("failed to create absolute path from %q: %w", path, err)
-				}
+			path, err := ResolveSearchPath(wd, path)
+			if err != nil {
+				return err
 			}
 
-			path = path.Clean()
-
 			slog.Log(ctx, slog.Level(logger.LevelTrace), "checking plugin search path", "path", path)
 
 			var ok bool
@@ -444,7 +968,7 @@ func readAllSearchPaths(ctx context.Context, wd fspath.Path, paths []fspath.Path
 				return nil
 			}
 
-			result, err := readSearchPath(ctx, path)
+			result, err := readCachedSearchPath(ctx, path, cache)
 			if err != nil {
 				return err
 			}
@@ -469,6 +993,36 @@ func readAllSearchPaths(ctx context.Context, wd fspath.Path, paths []fspath.Path
 	return plugins, nil
 }
 
+// readCachedSearchPath reads one search path using the cached manifests if
+// the search path has not changed since they were cached.
+func readCachedSearchPath(ctx context.Context, path fspath.Path, cache *manifestCache) ([]Plugin, error) {
+	if cache == nil {
+		return readSearchPath(ctx, path)
+	}
+
+	// The fingerprint is computed before reading the manifests so that
+	// the changes made during the reading invalidate the cache on the next run.
+	fingerprint, err := searchPathFingerprint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if plugins, ok := cache.lookup(path, fingerprint); ok {
+		slog.Log(ctx, slog.Level(logger.LevelTrace), "using cached plugin manifests", "path", path)
+
+		return plugins, nil
+	}
+
+	plugins, err := readSearchPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.store(path, fingerprint, plugins)
+
+	return plugins, nil
+}
+
 // readSearchPath reads one search path, checks all of the directories in it and
 // creates plugins for all of the found manifests.
 func readSearchPath(ctx context.Context, path fspath.Path) ([]Plugin, error) {
@@ -506,7 +1060,7 @@ func readSearchPath(ctx context.Context, path fspath.Path) ([]Plugin, error) {
 			}
 
 			// TODO: Possibly allow using other file formats.
-			manifestPath := path.Join(dirEntry.Name(), "manifest.json").Clean()
+			manifestPath := path.Join(dirEntry.Name(), ManifestFile).Clean()
 
 			plugin, err := readExternalPlugin(manifestPath)
 			if err != nil {
@@ -547,6 +1101,11 @@ func readExternalPlugin(path fspath.Path) (*externalPlugin, error) {
 		return nil, fmt.Errorf("failed to read %q: %w", path, err)
 	}
 
+	data, options, err := stripEntryOptions(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest at %q: %w", path, err)
+	}
+
 	d := json.NewDecoder(bytes.NewReader(data))
 	d.DisallowUnknownFields()
 
@@ -598,23 +1157,51 @@ func readExternalPlugin(path fspath.Path) (*externalPlugin, error) {
 
 	manifest.Commands = manifest.Commands[:i]
 
+	plugin := newExternalPlugin(manifest)
+	plugin.dir = path.Dir()
+	plugin.options = options
+
+	return plugin, nil
+}
+
+// newExternalPlugin returns a new external plugin for the given manifest that
+// has already been validated.
+func newExternalPlugin(manifest *api.Manifest) *externalPlugin {
 	return &externalPlugin{
-		conn:     nil,
-		cmd:      nil,
-		doneCh:   make(chan error),
-		lastID:   atomic.Int64{},
-		manifest: manifest,
-		queue: &responseQueue{
-			q:  make(map[string]chan api.Response),
-			mu: sync.Mutex{},
-		},
-	}, nil
+		options:           nil,
+		conn:              nil,
+		cmd:               nil,
+		doneCh:            make(chan error),
+		lastID:            atomic.Int64{},
+		caps:              Capabilities{},
+		lastActive:        atomic.Int64{},
+		alive:             atomic.Bool{},
+		hung:              atomic.Bool{},
+		logLevel:          nil,
+		dir:               "",
+		dataDir:           "",
+		env:               nil,
+		exit:              atomic.Pointer[processExit]{},
+		group:             nil,
+		sandbox:           Sandbox{}, //nolint:exhaustruct // no restrictions by default
+		outputLimits:      OutputLimits{MaxLineLength: 0, LinesPerSecond: 0},
+		protocolErrors:    atomic.Int64{},
+		maxProtocolErrors: DefaultMaxProtocolErrors,
+		maxMessageSize:    int(DefaultMaxMessageSize),
+		quarantined:       atomic.Bool{},
+		manifest:          manifest,
+		shutdownTimeout:   0,
+		traceDir:          "",
+		output:            atomic.Pointer[terminal.Stream]{},
+		queue:             newResponseQueue(),
+	}
 }
 
-// shutdown requests the given plugin to shut down and notifies it to exit. It
-// will ultimately kill the process if the plugin fails to shut down gracefully
-// and the context is canceled.
-func shutdown(ctx context.Context, plugin Plugin) error {
+// shutdown requests the given plugin to shut down and notifies it to exit
+// within the shutdown timeout of the plugin. If the plugin fails to exit in
+// time, its process is terminated and, after a grace period, killed. It reports
+// whether the process had to be terminated or killed.
+func shutdown(ctx context.Context, plugin Plugin) (bool, error) {
 	if !plugin.External() {
 		slog.Log(
 			ctx,
@@ -624,12 +1211,12 @@ func shutdown(ctx context.Context, plugin Plugin) error {
 			plugin.Manifest().Name,
 		)
 
-		return nil
+		return false, nil
 	}
 
 	external, ok := plugin.(*externalPlugin)
 	if !ok {
-		return fmt.Errorf(
+		return false, fmt.Errorf(
 			"%w: plugin %q cannot be converted to *externalPlugin",
 			ErrInvalidCast,
 			plugin.Manifest().Name,
@@ -639,9 +1226,65 @@ func shutdown(ctx context.Context, plugin Plugin) error {
 	if external.cmd == nil {
 		slog.DebugContext(ctx, "skipping plugin shutdown as it was never started", "plugin", external.manifest.Name)
 
-		return nil
+		return false, nil
+	}
+
+	external.alive.Store(false)
+
+	// The violation of the sandbox has already been reported by the method
+	// call that the process failed to respond to.
+	if exit := external.exit.Load(); exit != nil {
+		select {
+		case <-exit.done:
+			if exit.err != nil {
+				<-external.doneCh
+
+				return false, nil
+			}
+		default:
+		}
+	}
+
+	// The quarantined plugin has already been killed.
+	if external.quarantined.Load() {
+		<-external.doneCh
+
+		return false, nil
+	}
+
+	if external.hung.Load() {
+		slog.WarnContext(ctx, "killing plugin that is not responding", "plugin", external.manifest.Name)
+
+		if err := external.kill(ctx); err != nil {
+			return true, fmt.Errorf("failed to kill plugin %q: %w", external.manifest.Name, err)
+		}
+
+		<-external.doneCh
+
+		return true, nil
+	}
+
+	timeout := external.shutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
 	}
 
+	sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	err := shutdownGracefully(sctx, external)
+	if err == nil {
+		return false, nil
+	}
+
+	slog.WarnContext(ctx, "plugin failed to shut down gracefully", "plugin", external.manifest.Name, "err", err)
+
+	return forceExit(ctx, external, err)
+}
+
+// shutdownGracefully requests the given plugin to shut down, notifies it to
+// exit, and waits for its process to exit until ctx is done.
+func shutdownGracefully(ctx context.Context, external *externalPlugin) error {
 	if err := callShutdown(ctx, external); err != nil {
 		return err
 	}
@@ -655,15 +1298,46 @@ func shutdown(ctx context.Context, plugin Plugin) error {
 		if err != nil {
 			return fmt.Errorf("process for plugin %q returned error: %w", external.manifest.Name, err)
 		}
+
+		return nil
 	case <-ctx.Done():
-		if err := external.kill(ctx); err != nil {
-			return fmt.Errorf("failed to kill plugin %q: %w", external.manifest.Name, err)
+		return fmt.Errorf("shutting down plugin %q halted: %w", external.manifest.Name, ctx.Err())
+	}
+}
+
+// forceExit makes the process of the given plugin exit after it has failed to
+// shut down gracefully with err. The process is first sent the termination
+// signal and killed if it has not exited after [killGracePeriod]. It reports
+// whether the process had to be terminated or killed. If the process exited on
+// its own after all, the returned error is err.
+func forceExit(ctx context.Context, external *externalPlugin, err error) (bool, error) {
+	// The process may have exited on its own, for example when the plugin
+	// exited with an error.
+	if exit := external.exit.Load(); exit != nil {
+		select {
+		case <-exit.done:
+			<-external.doneCh
+
+			return false, err
+		default:
 		}
+	}
 
-		return fmt.Errorf("shutting down plugin %q halted: %w", external.manifest.Name, ctx.Err())
+	if external.terminate(ctx) {
+		select {
+		case <-external.doneCh:
+			return true, nil
+		case <-time.After(killGracePeriod):
+		}
 	}
 
-	return nil
+	if err := external.kill(ctx); err != nil {
+		return true, fmt.Errorf("failed to kill plugin %q: %w", external.manifest.Name, err)
+	}
+
+	<-external.doneCh
+
+	return true, nil
 }
 
 // validate checks the created plugins for conflicts. Specifically, the plugins
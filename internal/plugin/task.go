@@ -19,10 +19,17 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/diag"
+	"github.com/reginald-project/reginald/internal/diff"
+	"github.com/reginald-project/reginald/internal/fspath"
 	"github.com/reginald-project/reginald/internal/system"
+	"github.com/reginald-project/reginald/internal/terminal"
 )
 
 // Constants for the node visit statuses when traversing TaskGraph.
@@ -44,6 +51,10 @@ type Task struct {
 	// Plugin is the plugin that this task is defined in.
 	Plugin Plugin
 	api.Task
+
+	// Options contains the entry options of the config entries of this task
+	// by the keys of the entries.
+	Options map[string]EntryOptions
 }
 
 // A TaskConfig is the config for a task instance.
@@ -66,8 +77,33 @@ type TaskConfig struct {
 	// means that the task is run on every operating system.
 	Platforms system.OSes
 
+	// OnFailure is the policy for handling the failure of this task. If it is
+	// empty, the default policy of the run is used.
+	OnFailure FailurePolicy
+
+	// Timeout is the maximum time that running this task may take. If the task
+	// takes longer, it is canceled and it fails. Zero means no timeout.
+	Timeout time.Duration
+
+	// Elevate tells whether the task needs to run its operations with
+	// elevated privileges. The plugin of the task must support the "elevate"
+	// capability.
+	Elevate bool
+
 	// run tells whether this task instance is already run.
 	run bool
+
+	// skipped tells whether the task instance was skipped during the run
+	// instead of running it.
+	skipped bool
+
+	// unknown tells whether the outcome of the task instance is unknown as
+	// the plugin could not report it, for example, in a dry run.
+	unknown bool
+
+	// changes is the number of changes that the task instance reported during
+	// the run.
+	changes int
 }
 
 // TaskDefaults is the type for the default config values set for the tasks.
@@ -119,8 +155,16 @@ func (t *Task) LogValue() slog.Value {
 	return slog.GroupValue(slog.String("type", t.TaskType), slog.String("description", t.Description))
 }
 
+// Paths returns the paths in the config values of the task instance,
+// including the paths in the nested config tables. They are the files that
+// the task uses, and they can be used to find the tasks that are affected by
+// a changed file.
+func (c *TaskConfig) Paths() []fspath.Path {
+	return configPaths(c.Config)
+}
+
 // RunTask runs a task by calling the correct plugin.
-func RunTask(ctx context.Context, store *Store, cfg *TaskConfig, tasks []TaskConfig) error {
+func RunTask(ctx context.Context, store *Store, cfg *TaskConfig) error {
 	if store == nil {
 		panic("calling RunTask with nil store")
 	}
@@ -140,7 +184,7 @@ func RunTask(ctx context.Context, store *Store, cfg *TaskConfig, tasks []TaskCon
 		panic(fmt.Sprintf("task %q has nil plugin", task.TaskType))
 	}
 
-	if err := store.start(ctx, task.Plugin, tasks); err != nil {
+	if err := store.Require(ctx, task.Plugin.Manifest().Name); err != nil {
 		return err
 	}
 
@@ -150,16 +194,150 @@ func RunTask(ctx context.Context, store *Store, cfg *TaskConfig, tasks []TaskCon
 	}
 
 	tt := task.TaskType[i+1:]
+	name := task.Plugin.Manifest().Name
+	dryRun := store.runOpts.DryRun
+
+	if dryRun && !store.Capabilities(task.Plugin).Diff {
+		slog.WarnContext(ctx, "plugin does not support dry run, task status unknown", "task", cfg.ID, "plugin", name)
+		diag.Report(
+			ctx,
+			diag.UnknownStatus,
+			fmt.Sprintf("Status of task %q is unknown as plugin %q does not support dry run", cfg.ID, name),
+		)
+
+		cfg.run = true
+		cfg.unknown = true
+
+		return nil
+	}
+
+	// The output the plugin prints while running the task is shown with
+	// the task ID as the prefix and collapsed unless the task fails.
+	out := terminal.NewStream(terminal.Default(), cfg.ID)
+	external, isExternal := task.Plugin.(*externalPlugin)
+
+	if isExternal {
+		external.output.Store(out)
+	}
+
+	result, err := callRunTask(ctx, task.Plugin, tt, cfg, store.runOpts)
+
+	if isExternal {
+		external.output.Store(nil)
+	}
 
-	if err := callRunTask(ctx, task.Plugin, tt, cfg); err != nil {
+	out.Close(err != nil)
+
+	if err != nil {
 		return err
 	}
 
+	printChanges(ctx, cfg, result.Changes, dryRun)
+
 	cfg.run = true
+	cfg.changes = len(result.Changes)
 
 	return nil
 }
 
+// runTaskBatch runs the tasks of the same plugin by sending them to the plugin
+// in a single batch. The plugin must be an external plugin that supports
+// batches. The errors of the tasks are returned in the same order as
+// the tasks, and the returned slice is nil if all of the tasks succeeded.
+func runTaskBatch(ctx context.Context, store *Store, plugin *externalPlugin, cfgs []*TaskConfig) []error {
+	if err := store.Require(ctx, plugin.manifest.Name); err != nil {
+		return slices.Repeat([]error{err}, len(cfgs))
+	}
+
+	tts := make([]string, len(cfgs))
+	ids := make([]string, len(cfgs))
+
+	for i, cfg := range cfgs {
+		j := strings.IndexByte(cfg.TaskType, '/')
+		if j == -1 {
+			panic("invalid task type: " + cfg.TaskType)
+		}
+
+		tts[i] = cfg.TaskType[j+1:]
+		ids[i] = cfg.ID
+	}
+
+	slog.DebugContext(ctx, "running task batch", "plugin", plugin.manifest.Name, "tasks", ids)
+
+	// The output of the tasks cannot be told apart as the plugin runs them
+	// all in response to the same message.
+	out := terminal.NewStream(terminal.Default(), strings.Join(ids, ", "))
+	plugin.output.Store(out)
+
+	results, errs, err := callRunTasks(ctx, plugin, tts, cfgs, store.runOpts)
+
+	plugin.output.Store(nil)
+
+	if err != nil {
+		out.Close(true)
+
+		return slices.Repeat([]error{err}, len(cfgs))
+	}
+
+	failed := slices.ContainsFunc(errs, func(err error) bool { return err != nil })
+
+	out.Close(failed)
+
+	for i, cfg := range cfgs {
+		if errs[i] != nil {
+			continue
+		}
+
+		printChanges(ctx, cfg, results[i].Changes, store.runOpts.DryRun)
+
+		cfg.run = true
+		cfg.changes = len(results[i].Changes)
+	}
+
+	if !failed {
+		return nil
+	}
+
+	return errs
+}
+
+// configPaths returns the path values in kvs and in the config tables nested
+// in them.
+func configPaths(kvs api.KeyValues) []fspath.Path {
+	var paths []fspath.Path
+
+	for _, kv := range kvs {
+		switch kv.Type { //nolint:exhaustive // only the paths and the nested configs are needed
+		case api.PathValue:
+			switch v := kv.Val.(type) {
+			case fspath.Path:
+				if v != "" {
+					paths = append(paths, v)
+				}
+			case string:
+				if v != "" {
+					paths = append(paths, fspath.Path(v))
+				}
+			}
+		case api.PathListValue:
+			switch v := kv.Val.(type) {
+			case []fspath.Path:
+				paths = append(paths, v...)
+			case []string:
+				for _, s := range v {
+					paths = append(paths, fspath.Path(s))
+				}
+			}
+		case api.ConfigSliceValue:
+			if nested, err := kv.Configs(); err == nil {
+				paths = append(paths, configPaths(nested)...)
+			}
+		}
+	}
+
+	return paths
+}
+
 // newCycleError formats and returns an error for circular dependencies.
 func newCycleError(startNode *taskNode, stack []*taskNode) error {
 	path := ""
@@ -194,14 +372,23 @@ func newTasks(plugin Plugin) []*Task {
 		return nil
 	}
 
+	var opts map[string]map[string]EntryOptions
+
+	if ext, ok := plugin.(*externalPlugin); ok && ext.options != nil {
+		opts = ext.options.Tasks
+	}
+
 	tasks := make([]*Task, len(manifest.Tasks))
 
 	for i, t := range manifest.Tasks {
+		options := opts[t.TaskType]
+
 		// Normalize the task type here by adding the plugin domain.
 		t.TaskType = manifest.Domain + "/" + t.TaskType
 		tasks[i] = &Task{
-			Plugin: plugin,
-			Task:   t,
+			Plugin:  plugin,
+			Task:    t,
+			Options: options,
 		}
 	}
 
@@ -321,6 +508,36 @@ func (g taskGraph) sorted() ([][]*taskNode, error) {
 	return stages, nil
 }
 
+// printChanges shows the file changes reported by a task. In dry-run mode,
+// the changes are printed as unified diffs. Otherwise, they are only logged.
+func printChanges(ctx context.Context, cfg *TaskConfig, changes []FileChange, dryRun bool) {
+	for _, c := range changes {
+		if !dryRun {
+			slog.DebugContext(ctx, "task changed file", "task", cfg.ID, "path", c.Path)
+
+			continue
+		}
+
+		oldName, newName := c.Path, c.Path
+
+		if c.Old == "" {
+			oldName = os.DevNull
+		}
+
+		if c.New == "" {
+			newName = os.DevNull
+		}
+
+		if cfg.Elevate {
+			terminal.Printf("Task %q would change %s as root\n", cfg.ID, c.Path)
+		} else {
+			terminal.Printf("Task %q would change %s\n", cfg.ID, c.Path)
+		}
+
+		terminal.PrintDiff(diff.Unified(oldName, newName, c.Old, c.New, diff.DefaultContext))
+	}
+}
+
 func visit(node *taskNode, state map[string]visitState, stack *[]*taskNode) error {
 	state[node.id] = visiting
 
@@ -0,0 +1,116 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// Permissions for the trace files.
+const (
+	traceDirPerm  os.FileMode = 0o700
+	traceFilePerm os.FileMode = 0o600
+)
+
+// Directions of the traced messages.
+const (
+	traceRecv = "<--"
+	traceSend = "-->"
+)
+
+// An rpcTrace writes the JSON-RPC messages sent to and received from a plugin
+// to a trace file. The trace is used for debugging the protocol
+// implementations of the plugins. A nil rpcTrace discards the messages.
+type rpcTrace struct {
+	// f is the trace file. It is nil after the trace is closed.
+	f *os.File
+
+	// mu serializes writing to the trace file.
+	mu sync.Mutex
+}
+
+// openTrace opens the trace file for the plugin with the given name in dir.
+// The previous trace of the plugin is replaced.
+func openTrace(dir fspath.Path, name string) (*rpcTrace, error) {
+	if err := os.MkdirAll(string(dir), traceDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create trace directory %q: %w", dir, err)
+	}
+
+	path := dir.Join(name + ".trace")
+
+	f, err := os.OpenFile(string(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, traceFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file %q: %w", path, err)
+	}
+
+	return &rpcTrace{f: f, mu: sync.Mutex{}}, nil
+}
+
+// Close closes the trace file. The messages traced after closing the trace are
+// discarded.
+func (t *rpcTrace) Close() error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return nil
+	}
+
+	err := t.f.Close()
+	t.f = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to close trace file: %w", err)
+	}
+
+	return nil
+}
+
+// frame writes the content of a message to the trace with a timestamp and
+// the direction of the message. The errors are ignored as the trace is only
+// a debugging aid.
+func (t *rpcTrace) frame(direction string, data []byte) {
+	if t == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString(time.Now().Format(time.RFC3339Nano))
+	buf.WriteByte(' ')
+	buf.WriteString(direction)
+	buf.WriteByte(' ')
+	buf.Write(bytes.TrimSpace(data))
+	buf.WriteByte('\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return
+	}
+
+	_, _ = t.f.Write(buf.Bytes())
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// ErrDownloadChanged is returned when a downloaded file does not match
+// the checksum that was recorded in the lock file when it was first downloaded.
+var ErrDownloadChanged = errors.New("downloaded file has changed since its checksum was recorded")
+
+// A Downloader downloads the files that the tasks request through the download
+// cache and verifies them centrally. The checksums of the downloaded files are
+// recorded in the lock file, so a file that changes upstream is detected on
+// the later runs even if the task did not declare a checksum for it.
+// A Downloader is safe for concurrent use.
+type Downloader struct {
+	cache    *dlcache.Cache
+	lockFile fspath.Path
+	mu       sync.Mutex // guards the lock file
+}
+
+// NewDownloader returns a new Downloader that downloads the files to cache and
+// records their checksums in lockFile.
+func NewDownloader(cache *dlcache.Cache, lockFile fspath.Path) *Downloader {
+	return &Downloader{cache: cache, lockFile: lockFile} //nolint:exhaustruct // zero mutex is ready for use
+}
+
+// Download downloads the file from rawURL and returns the path to the cached
+// file and its hexadecimal SHA-256 checksum. If sum is not empty, the file must
+// match it. Otherwise, the file must match the checksum that is recorded in
+// the lock file for the URL, and if there is none, the checksum of
+// the downloaded file is recorded.
+func (d *Downloader) Download(ctx context.Context, rawURL, sum string) (fspath.Path, string, error) {
+	recorded, err := d.recorded(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	var path fspath.Path
+
+	switch {
+	case sum != "":
+		path, err = d.cache.Fetch(ctx, rawURL, sum)
+	case recorded != "":
+		sum = recorded
+
+		path, err = d.cache.Fetch(ctx, rawURL, sum)
+		if errors.Is(err, dlcache.ErrChecksum) {
+			err = fmt.Errorf("%w: %w", ErrDownloadChanged, err)
+		}
+	default:
+		path, sum, err = d.cache.Download(ctx, rawURL)
+	}
+
+	if err != nil {
+		return "", "", fmt.Errorf("%w", err)
+	}
+
+	sum = strings.ToLower(sum)
+
+	if sum != recorded {
+		if err = d.record(rawURL, sum); err != nil {
+			return "", "", err
+		}
+
+		slog.InfoContext(ctx, "recorded download checksum", "url", rawURL, "sha256", sum, "lock", d.lockFile)
+	}
+
+	return path, sum, nil
+}
+
+// recorded returns the checksum that is recorded in the lock file for rawURL.
+// It returns an empty string if there is none.
+func (d *Downloader) recorded(rawURL string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lock, err := ReadLock(d.lockFile)
+	if err != nil {
+		return "", err
+	}
+
+	return lock.Downloads[rawURL], nil
+}
+
+// record records the checksum sum for rawURL in the lock file.
+func (d *Downloader) record(rawURL, sum string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lock, err := ReadLock(d.lockFile)
+	if err != nil {
+		return err
+	}
+
+	lock.Downloads[rawURL] = sum
+
+	return lock.Write(d.lockFile)
+}
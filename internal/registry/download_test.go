@@ -0,0 +1,99 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/registry"
+)
+
+func TestDownloader(t *testing.T) {
+	t.Parallel()
+
+	var content atomic.Value
+
+	content.Store("v1")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, content.Load().(string)) //nolint:forcetypeassert // always a string
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	lockFile := fspath.Path(filepath.Join(dir, "reginald.lock"))
+	d := registry.NewDownloader(dlcache.New(fspath.Path(filepath.Join(dir, "cache"))), lockFile)
+	url := srv.URL + "/file"
+
+	sum := sha256.Sum256([]byte("v1"))
+	want := hex.EncodeToString(sum[:])
+
+	_, got, err := d.Download(t.Context(), url, "")
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if got != want {
+		t.Errorf("Download() checksum = %s, want %s", got, want)
+	}
+
+	lock, err := registry.ReadLock(lockFile)
+	if err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+
+	if lock.Downloads[url] != want {
+		t.Errorf("recorded checksum = %q, want %q", lock.Downloads[url], want)
+	}
+
+	if _, _, err = d.Download(t.Context(), url, "00"); !errors.Is(err, dlcache.ErrChecksum) {
+		t.Errorf("Download() with wrong checksum error = %v, want %v", err, dlcache.ErrChecksum)
+	}
+
+	content.Store("v2")
+
+	if _, err = dlcache.New(fspath.Path(filepath.Join(dir, "cache"))).Clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = d.Download(t.Context(), url, ""); !errors.Is(err, registry.ErrDownloadChanged) {
+		t.Errorf("Download() of changed file error = %v, want %v", err, registry.ErrDownloadChanged)
+	}
+
+	sum = sha256.Sum256([]byte("v2"))
+	want = hex.EncodeToString(sum[:])
+
+	if _, _, err = d.Download(t.Context(), url, want); err != nil {
+		t.Fatalf("Download() with new checksum error = %v", err)
+	}
+
+	if lock, err = registry.ReadLock(lockFile); err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+
+	if lock.Downloads[url] != want {
+		t.Errorf("recorded checksum after update = %q, want %q", lock.Downloads[url], want)
+	}
+}
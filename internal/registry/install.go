@@ -0,0 +1,421 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/reginald-project/reginald/internal/dlcache"
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/httpclient"
+	"github.com/reginald-project/reginald/internal/plugin"
+)
+
+// Permissions for the directories and files of the installed plugins.
+const (
+	installDirPerm  fs.FileMode = 0o755
+	installFilePerm fs.FileMode = 0o644
+)
+
+// Errors returned when installing plugins.
+var (
+	ErrExists        = errors.New("plugin is already installed")
+	errArchive       = errors.New("invalid plugin archive")
+	errChecksum      = errors.New("checksum mismatch")
+	errNoChecksum    = errors.New("artifact has no checksum")
+	errManifestMatch = errors.New("manifest does not match the index")
+)
+
+// Install downloads the artifact of the resolved plugin, verifies its checksum,
+// and extracts it to a directory named after the plugin in dir. It returns
+// the directory of the installed plugin. The plugin is not installed if
+// the directory already exists. The archive is taken from the download cache if
+// cache is not nil.
+func Install(ctx context.Context, cache *dlcache.Cache, r *Resolved, dir fspath.Path) (fspath.Path, error) {
+	dst := dir.Join(r.Name)
+
+	if _, err := os.Stat(string(dst)); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrExists, dst)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to check the plugin directory: %w", err)
+	}
+
+	if err := os.MkdirAll(string(dir), installDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create the plugin directory: %w", err)
+	}
+
+	tmp, err := tempDir(dir, r.Name)
+	if err != nil {
+		return "", err
+	}
+	defer removeTemp(ctx, tmp)
+
+	root, err := fetchPlugin(ctx, cache, r, tmp)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(root, string(dst)); err != nil {
+		return "", fmt.Errorf("failed to move the plugin to %s: %w", dst, err)
+	}
+
+	slog.InfoContext(ctx, "installed plugin", "name", r.Name, "version", r.Version, "dir", dst)
+
+	return dst, nil
+}
+
+// Update replaces the installed plugin in the plugin directory dir with
+// the resolved version. The previous version is restored if the new version
+// cannot be moved into place. The archive is taken from the download cache if
+// cache is not nil.
+func Update(ctx context.Context, cache *dlcache.Cache, r *Resolved, dir fspath.Path) error {
+	tmp, err := tempDir(dir.Dir(), r.Name)
+	if err != nil {
+		return err
+	}
+	defer removeTemp(ctx, tmp)
+
+	root, err := fetchPlugin(ctx, cache, r, tmp)
+	if err != nil {
+		return err
+	}
+
+	old := filepath.Join(tmp, "old")
+	if err = os.Rename(string(dir), old); err != nil {
+		return fmt.Errorf("failed to move the previous version of %s: %w", r.Name, err)
+	}
+
+	if err = os.Rename(root, string(dir)); err != nil {
+		if restoreErr := os.Rename(old, string(dir)); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+
+		return fmt.Errorf("failed to move the plugin to %s: %w", dir, err)
+	}
+
+	slog.InfoContext(ctx, "updated plugin", "name", r.Name, "version", r.Version, "dir", dir)
+
+	return nil
+}
+
+// tempDir creates a temporary directory for installing the named plugin in
+// dir. The archive is downloaded and extracted within the directory that
+// the plugin is installed to so that the extracted plugin can be renamed into
+// place.
+func tempDir(dir fspath.Path, name string) (string, error) {
+	tmp, err := os.MkdirTemp(string(dir), ".install-"+name+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+
+	return tmp, nil
+}
+
+// removeTemp removes the temporary directory tmp and everything in it.
+func removeTemp(ctx context.Context, tmp string) {
+	if err := os.RemoveAll(tmp); err != nil {
+		slog.WarnContext(ctx, "failed to remove temporary directory", "path", tmp, "err", err)
+	}
+}
+
+// fetchPlugin downloads and extracts the artifact of the resolved plugin to
+// tmp and checks the manifest in it. It returns the extracted plugin directory.
+func fetchPlugin(ctx context.Context, cache *dlcache.Cache, r *Resolved, tmp string) (string, error) {
+	archive, err := fetchArchive(ctx, cache, r.Artifact, tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s@%s: %w", r.Name, r.Version, err)
+	}
+
+	root := filepath.Join(tmp, "plugin")
+	if err = extract(archive, archiveName(r.Artifact.URL), root); err != nil {
+		return "", fmt.Errorf("failed to extract %s@%s: %w", r.Name, r.Version, err)
+	}
+
+	root, err = pluginRoot(root)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s@%s: %w", errArchive, r.Name, r.Version, err)
+	}
+
+	manifest, err := plugin.ReadManifest(fspath.Path(root).Join(plugin.ManifestFile))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s@%s: %w", errArchive, r.Name, r.Version, err)
+	}
+
+	if manifest.Name != r.Name {
+		return "", fmt.Errorf("%w: expected plugin %q, got %q", errManifestMatch, r.Name, manifest.Name)
+	}
+
+	return root, nil
+}
+
+// fetchArchive downloads the archive of the artifact and returns the path to it.
+// The archives from HTTP(S) URLs are stored in the download cache if cache is
+// not nil, and the other archives are downloaded to tmp.
+func fetchArchive(ctx context.Context, cache *dlcache.Cache, a Artifact, tmp string) (string, error) {
+	if cache != nil && !strings.HasPrefix(a.URL, "file://") {
+		archive, err := cache.Fetch(ctx, a.URL, a.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("%w", err)
+		}
+
+		return string(archive), nil
+	}
+
+	archive := filepath.Join(tmp, "archive")
+	if err := download(ctx, a, archive); err != nil {
+		return "", err
+	}
+
+	return archive, nil
+}
+
+// download downloads the artifact to the file name and verifies its checksum.
+func download(ctx context.Context, a Artifact, name string) error {
+	if a.SHA256 == "" {
+		return fmt.Errorf("%w: %s", errNoChecksum, a.URL)
+	}
+
+	src, err := open(ctx, a.URL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	f, err := os.Create(name) //nolint:gosec // the file is in the temporary directory
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", name, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(f, h), src); err != nil {
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(sum, a.SHA256) {
+		return fmt.Errorf("%w for %s: expected %s, got %s", errChecksum, a.URL, a.SHA256, sum)
+	}
+
+	return nil
+}
+
+// open opens the given HTTP(S) or file URL for reading. The caller must close
+// the returned reader.
+func open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+		}
+
+		name := filePath(u)
+
+		f, err := os.Open(name) //nolint:gosec // the file is given in the index
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %q: %w", name, err)
+		}
+
+		return f, nil
+	}
+
+	body, err := httpclient.Get(ctx, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return body, nil
+}
+
+// archiveName returns the file name of the archive in the given URL.
+func archiveName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return path.Base(u.Path)
+	}
+
+	return path.Base(rawURL)
+}
+
+// extract extracts the archive file to dir. The format of the archive is
+// determined from the given name.
+func extract(file, name, dir string) error {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return extractTarGz(file, dir)
+	case strings.HasSuffix(name, ".zip"):
+		return extractZip(file, dir)
+	default:
+		return fmt.Errorf("%w: unsupported format %q", errArchive, name)
+	}
+}
+
+// extractTarGz extracts the gzipped tarball file to dir.
+func extractTarGz(file, dir string) error {
+	f, err := os.Open(file) //nolint:gosec // the file is in the temporary directory
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", file, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errArchive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", errArchive, err)
+		}
+
+		target, err := archivePath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, installDirPerm); err != nil {
+				return fmt.Errorf("failed to create %q: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err = writeFile(target, tr, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+		default:
+			return fmt.Errorf("%w: unsupported entry %q", errArchive, hdr.Name)
+		}
+	}
+}
+
+// extractZip extracts the zip archive file to dir.
+func extractZip(file, dir string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errArchive, err)
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		target, err := archivePath(dir, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+
+		switch {
+		case mode.IsDir():
+			if err = os.MkdirAll(target, installDirPerm); err != nil {
+				return fmt.Errorf("failed to create %q: %w", target, err)
+			}
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("%w: %w", errArchive, err)
+			}
+
+			err = writeFile(target, rc, mode)
+			rc.Close()
+
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unsupported entry %q", errArchive, zf.Name)
+		}
+	}
+
+	return nil
+}
+
+// archivePath returns the path in dir for the given name of an archive entry.
+// It returns an error if the name would be outside of dir.
+func archivePath(dir, name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("%w: entry %q is outside of the archive", errArchive, name)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// writeFile writes the contents of r to the file name. Only the executable bits
+// of mode are kept.
+func writeFile(name string, r io.Reader, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), installDirPerm); err != nil {
+		return fmt.Errorf("failed to create %q: %w", filepath.Dir(name), err)
+	}
+
+	perm := installFilePerm | mode.Perm()&0o111 //nolint:mnd // executable bits
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm) //nolint:gosec // path is checked
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", name, err)
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, r); err != nil { //nolint:gosec // the plugins are trusted by checksum
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// pluginRoot returns the directory in the extracted archive dir that contains
+// the manifest. The manifest is either at the root of the archive or in
+// a single top-level directory.
+func pluginRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, plugin.ManifestFile)); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", dir, err)
+	}
+
+	if len(entries) == 1 && entries[0].IsDir() {
+		root := filepath.Join(dir, entries[0].Name())
+		if _, err = os.Stat(filepath.Join(root, plugin.ManifestFile)); err == nil {
+			return root, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %s in the archive", plugin.ManifestFile) //nolint:err113 // wrapped by the caller
+}
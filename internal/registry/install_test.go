@@ -0,0 +1,223 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/registry"
+)
+
+const testManifest = `{"name": "reginald-test", "domain": "test", "executable": "reginald-test"}`
+
+// writeTarGz writes a gzipped tarball with the given files to name and returns
+// the hexadecimal SHA-256 checksum of the archive.
+func writeTarGz(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for file, content := range files {
+		hdr := &tar.Header{Name: file, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return hex.EncodeToString(sum[:])
+}
+
+// resolved returns the plugin resolved from an index that lists the archive
+// with the given checksum.
+func resolved(t *testing.T, dir, archive, sum string) *registry.Resolved {
+	t.Helper()
+
+	index := filepath.Join(dir, "index.json")
+	data := `{"version": 1, "plugins": [{"name": "reginald-test", "releases": [{"version": "1.0.0", "artifacts": [` +
+		`{"os": "` + runtime.GOOS + `", "arch": "` + runtime.GOARCH + `", "url": "` + archive + `", "sha256": "` + sum + `"}` +
+		`]}]}]}`
+
+	if err := os.WriteFile(index, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := registry.Fetch(t.Context(), []string{index})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	r, err := registry.Resolve(indexes, "reginald-test", "")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	return r
+}
+
+func TestInstall(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sum := writeTarGz(t, filepath.Join(dir, "test.tar.gz"), map[string]string{
+		"reginald-test-1.0.0/manifest.json": testManifest,
+		"reginald-test-1.0.0/reginald-test": "#!/bin/sh\n",
+	})
+	r := resolved(t, dir, "test.tar.gz", sum)
+	plugins := fspath.Path(filepath.Join(dir, "plugins"))
+
+	got, err := registry.Install(t.Context(), nil, r, plugins)
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	if want := plugins.Join("reginald-test"); got != want {
+		t.Errorf("Install() = %q, want %q", got, want)
+	}
+
+	if _, err = os.Stat(string(got.Join("manifest.json"))); err != nil {
+		t.Errorf("manifest not installed: %v", err)
+	}
+
+	if _, err = registry.Install(t.Context(), nil, r, plugins); !errors.Is(err, registry.ErrExists) {
+		t.Errorf("Install() again error = %v, want %v", err, registry.ErrExists)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	plugins := fspath.Path(filepath.Join(dir, "plugins"))
+	pluginDir := plugins.Join("reginald-test")
+
+	if err := os.MkdirAll(string(pluginDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(string(pluginDir.Join("old")), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := writeTarGz(t, filepath.Join(dir, "test.tar.gz"), map[string]string{
+		"manifest.json": testManifest,
+		"reginald-test": "#!/bin/sh\n",
+	})
+	r := resolved(t, dir, "test.tar.gz", sum)
+
+	if err := registry.Update(t.Context(), nil, r, pluginDir); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if _, err := os.Stat(string(pluginDir.Join("manifest.json"))); err != nil {
+		t.Errorf("manifest not installed: %v", err)
+	}
+
+	if _, err := os.Stat(string(pluginDir.Join("old"))); err == nil {
+		t.Error("previous version not removed")
+	}
+
+	r.Artifact.SHA256 = "00"
+
+	if err := registry.Update(t.Context(), nil, r, pluginDir); err == nil {
+		t.Fatal("Update() with checksum mismatch error = nil, want error")
+	}
+
+	if _, err := os.Stat(string(pluginDir.Join("manifest.json"))); err != nil {
+		t.Errorf("installed version removed after failed update: %v", err)
+	}
+}
+
+func TestInstallInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		sum   string
+	}{
+		{"Checksum mismatch", map[string]string{"manifest.json": testManifest}, "00"},
+		{"Outside archive", map[string]string{"manifest.json": testManifest, "../escape": "x"}, ""},
+		{"No manifest", map[string]string{"reginald-test": "#!/bin/sh\n"}, ""},
+		{"Wrong name", map[string]string{"manifest.json": `{"name": "other", "executable": "other"}`}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			sum := writeTarGz(t, filepath.Join(dir, "test.tar.gz"), tt.files)
+			if tt.sum != "" {
+				sum = tt.sum
+			}
+
+			r := resolved(t, dir, "test.tar.gz", sum)
+			plugins := fspath.Path(filepath.Join(dir, "plugins"))
+
+			if _, err := registry.Install(t.Context(), nil, r, plugins); err == nil {
+				t.Fatal("Install() error = nil, want error")
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+				t.Error("file written outside of the archive")
+			}
+
+			if _, err := os.Stat(string(plugins.Join("reginald-test"))); err == nil {
+				t.Error("plugin installed despite the error")
+			}
+
+			entries, err := os.ReadDir(string(plugins))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != 0 {
+				t.Errorf("temporary files left in the plugin directory: %v", entries)
+			}
+		})
+	}
+}
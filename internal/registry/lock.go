@@ -0,0 +1,291 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// lockVersion is the version of the lock file format.
+const lockVersion = 1
+
+// Permissions for the lock file.
+const lockFilePerm fs.FileMode = 0o644
+
+// Errors returned for the lock file.
+var (
+	ErrLockMismatch = errors.New("installed plugin does not match the lock file")
+	ErrNotLocked    = errors.New("plugin is not in the lock file")
+	errInvalidLock  = errors.New("invalid lock file")
+)
+
+// A Lock is the lock file that pins the versions and the checksums of
+// the plugins installed from the plugin indexes so that the same plugins can be
+// installed on every machine. The plugins are changed only by the commands that
+// install and update the plugins. The lock file also records the checksums of
+// the files that the tasks download so that changed files are detected.
+type Lock struct {
+	// Plugins contains the locked plugins by their names.
+	Plugins map[string]LockedPlugin `json:"plugins"`
+
+	// Downloads contains the verified hexadecimal SHA-256 checksums of
+	// the files that the tasks have downloaded by their URLs.
+	Downloads map[string]string `json:"downloads,omitempty"`
+
+	// Version is the version of the lock file format.
+	Version int `json:"version"`
+}
+
+// A LockedPlugin is a plugin in the lock file. The version is the same on all
+// of the platforms, but the artifacts are recorded for each platform the plugin
+// has been installed on as the lock file is usually shared between machines.
+type LockedPlugin struct {
+	// Artifacts contains the installed artifacts by the platforms as
+	// "GOOS/GOARCH".
+	Artifacts map[string]LockedArtifact `json:"artifacts"`
+
+	// Version is the installed version of the plugin.
+	Version string `json:"version"`
+}
+
+// A LockedArtifact is the artifact of a locked plugin for a platform.
+type LockedArtifact struct {
+	// URL is the URL of the archive the plugin was installed from.
+	URL string `json:"url"`
+
+	// SHA256 is the hexadecimal SHA-256 checksum of the archive.
+	SHA256 string `json:"sha256"`
+
+	// Sum is the checksum of the installed plugin directory. See [DirSum].
+	Sum string `json:"sum"`
+}
+
+// ReadLock reads the lock file at path. If the file does not exist, it returns
+// an empty lock.
+func ReadLock(path fspath.Path) (*Lock, error) {
+	lock := &Lock{Plugins: make(map[string]LockedPlugin), Downloads: make(map[string]string), Version: lockVersion}
+
+	data, err := os.ReadFile(string(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return lock, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	if err = json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidLock, path, err)
+	}
+
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("%w %q: unsupported version %d", errInvalidLock, path, lock.Version)
+	}
+
+	if lock.Plugins == nil {
+		lock.Plugins = make(map[string]LockedPlugin)
+	}
+
+	if lock.Downloads == nil {
+		lock.Downloads = make(map[string]string)
+	}
+
+	for name, p := range lock.Plugins {
+		if p.Artifacts == nil {
+			p.Artifacts = make(map[string]LockedArtifact)
+			lock.Plugins[name] = p
+		}
+	}
+
+	return lock, nil
+}
+
+// Write writes the lock file to path. The plugins and the artifacts are
+// written in a deterministic order so that the file can be kept in version
+// control.
+func (l *Lock) Write(path fspath.Path) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the lock file: %w", err)
+	}
+
+	data = append(data, '\n')
+
+	if err = os.WriteFile(string(path), data, lockFilePerm); err != nil {
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// Set records the installed plugin in the lock. If the locked version of
+// the plugin changes, the artifacts for the other platforms are removed as they
+// are for the previous version.
+func (l *Lock) Set(r *Resolved, dir fspath.Path) error {
+	sum, err := DirSum(dir)
+	if err != nil {
+		return err
+	}
+
+	p, ok := l.Plugins[r.Name]
+	if !ok || p.Version != r.Version {
+		p = LockedPlugin{Artifacts: make(map[string]LockedArtifact), Version: r.Version}
+	}
+
+	p.Artifacts[platform()] = LockedArtifact{URL: r.Artifact.URL, SHA256: r.Artifact.SHA256, Sum: sum}
+	l.Plugins[r.Name] = p
+
+	return nil
+}
+
+// Resolved returns the locked artifact of the named plugin for the current
+// platform so that the plugin can be installed again without the indexes. It
+// reports false if the plugin has not been locked on the platform.
+func (l *Lock) Resolved(name string) (*Resolved, bool) {
+	p, ok := l.Plugins[name]
+	if !ok {
+		return nil, false
+	}
+
+	a, ok := p.Artifacts[platform()]
+	if !ok {
+		return nil, false
+	}
+
+	return &Resolved{
+		Name:     name,
+		Version:  p.Version,
+		Artifact: Artifact{OS: runtime.GOOS, Arch: runtime.GOARCH, URL: a.URL, SHA256: a.SHA256},
+	}, true
+}
+
+// Verify checks that the plugin directory of the named plugin matches the lock.
+func (l *Lock) Verify(name string, dir fspath.Path) error {
+	p, ok := l.Plugins[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotLocked, name)
+	}
+
+	a, ok := p.Artifacts[platform()]
+	if !ok {
+		return fmt.Errorf("%w: %s@%s on %s", ErrNotLocked, name, p.Version, platform())
+	}
+
+	sum, err := DirSum(dir)
+	if err != nil {
+		return err
+	}
+
+	if sum != a.Sum {
+		return fmt.Errorf("%w: %s@%s in %s has changed", ErrLockMismatch, name, p.Version, dir)
+	}
+
+	return nil
+}
+
+// DirSum returns the checksum of the files in the plugin directory dir. It is
+// the SHA-256 checksum of the sorted list of the relative paths, executable
+// bits, and checksums of the regular files. The "__pycache__" directories are
+// skipped as Python creates them in the plugin directory when the plugin is
+// run.
+func DirSum(dir fspath.Path) (string, error) {
+	var lines []string
+
+	err := filepath.WalkDir(string(dir), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if d.Name() == "__pycache__" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %q: %w", name, err)
+		}
+
+		sum, err := fileSum(name)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(string(dir), name)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path of %q: %w", name, err)
+		}
+
+		exec := "-"
+		if info.Mode().Perm()&0o111 != 0 {
+			exec = "x"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s  %s  %s\n", sum, exec, filepath.ToSlash(rel)))
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to compute the checksum of %q: %w", dir, err)
+	}
+
+	slices.Sort(lines)
+
+	h := sha256.New()
+
+	for _, line := range lines {
+		_, _ = io.WriteString(h, line)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileSum returns the hexadecimal SHA-256 checksum of the file name.
+func fileSum(name string) (string, error) {
+	f, err := os.Open(name) //nolint:gosec // the file is in the plugin directory
+	if err != nil {
+		return "", fmt.Errorf("failed to open %q: %w", name, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", name, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// platform returns the current platform as "GOOS/GOARCH".
+func platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
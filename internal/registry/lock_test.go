@@ -0,0 +1,136 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fspath"
+	"github.com/reginald-project/reginald/internal/registry"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	lockFile := fspath.Path(filepath.Join(dir, "reginald.lock"))
+	pluginDir := fspath.Path(filepath.Join(dir, "reginald-test"))
+
+	if err := os.MkdirAll(string(pluginDir.Join("__pycache__")), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(string(pluginDir.Join("manifest.json")), []byte(testManifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := registry.ReadLock(lockFile)
+	if err != nil {
+		t.Fatalf("ReadLock() of missing file error = %v", err)
+	}
+
+	if len(lock.Plugins) != 0 {
+		t.Fatalf("ReadLock() of missing file = %v, want empty lock", lock.Plugins)
+	}
+
+	r := &registry.Resolved{
+		Name:     "reginald-test",
+		Version:  "1.0.0",
+		Artifact: registry.Artifact{OS: "any", Arch: "any", URL: "https://example.com/test.tar.gz", SHA256: "aa"},
+	}
+
+	if err = lock.Set(r, pluginDir); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err = lock.Write(lockFile); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lock, err = registry.ReadLock(lockFile)
+	if err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+
+	got, ok := lock.Resolved("reginald-test")
+	if !ok || got.Version != r.Version || got.Artifact.URL != r.Artifact.URL || got.Artifact.SHA256 != "aa" {
+		t.Fatalf("Resolved() = %+v, %v, want %+v", got, ok, r)
+	}
+
+	if err = lock.Verify("reginald-test", pluginDir); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// The files written by Python are not part of the plugin.
+	if err = os.WriteFile(string(pluginDir.Join("__pycache__", "x.pyc")), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = lock.Verify("reginald-test", pluginDir); err != nil {
+		t.Errorf("Verify() after writing __pycache__ error = %v", err)
+	}
+
+	if err = os.WriteFile(string(pluginDir.Join("extra")), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = lock.Verify("reginald-test", pluginDir); !errors.Is(err, registry.ErrLockMismatch) {
+		t.Errorf("Verify() after change error = %v, want %v", err, registry.ErrLockMismatch)
+	}
+
+	if err = lock.Verify("reginald-other", pluginDir); !errors.Is(err, registry.ErrNotLocked) {
+		t.Errorf("Verify() of unknown plugin error = %v, want %v", err, registry.ErrNotLocked)
+	}
+
+	r.Version = "2.0.0"
+
+	if err = lock.Set(r, pluginDir); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err = lock.Verify("reginald-test", pluginDir); err != nil {
+		t.Errorf("Verify() after Set() error = %v", err)
+	}
+}
+
+func TestReadLockInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"Syntax", `{"version": 1`},
+		{"Version", `{"version": 2, "plugins": {}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "reginald.lock")
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := registry.ReadLock(fspath.Path(file)); err == nil {
+				t.Error("ReadLock() error = nil, want error")
+			}
+		})
+	}
+}
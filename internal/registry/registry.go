@@ -0,0 +1,430 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry implements the client for the plugin indexes. A plugin index
+// is a JSON document at a URL that lists the plugins that can be installed,
+// their versions, and the archives of the versions for each platform with
+// their checksums.
+package registry
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/anttikivi/semver"
+	"github.com/reginald-project/reginald/internal/httpclient"
+)
+
+// IndexVersion is the version of the index format that the client supports.
+const IndexVersion = 1
+
+// anyPlatform is the value of the operating system or the architecture of
+// an artifact that works on all of the platforms, like a plugin written in
+// a scripting language.
+const anyPlatform = "any"
+
+// Errors returned by the registry client.
+var (
+	ErrNotFound      = errors.New("plugin not found in the indexes")
+	ErrNoArtifact    = errors.New("no artifact for the platform")
+	ErrNoIndexes     = errors.New("no plugin indexes configured")
+	errInvalidIndex  = errors.New("invalid plugin index")
+	errVersionFormat = errors.New("invalid version")
+)
+
+// An Index is a plugin index.
+type Index struct {
+	// URL is the URL the index was fetched from. The relative URLs of
+	// the artifacts are resolved against it.
+	URL string `json:"-"`
+
+	// Plugins contains the plugins in the index.
+	Plugins []Entry `json:"plugins"`
+
+	// Version is the version of the index format.
+	Version int `json:"version"`
+}
+
+// An Entry is a plugin in an index.
+type Entry struct {
+	// Name is the name of the plugin. It must be the same as the name in
+	// the manifest of the plugin.
+	Name string `json:"name"`
+
+	// Description is a short description of the plugin.
+	Description string `json:"description,omitempty"`
+
+	// Homepage is the URL of the home page of the plugin.
+	Homepage string `json:"homepage,omitempty"`
+
+	// Releases contains the released versions of the plugin.
+	Releases []Release `json:"releases"`
+}
+
+// A Release is a released version of a plugin.
+type Release struct {
+	// Version is the version of the plugin. It must be a semantic version and
+	// the same as the version in the manifest of the plugin.
+	Version string `json:"version"`
+
+	// Artifacts contains the archives of the version for the platforms.
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// An Artifact is the archive of a version of a plugin for a platform. The
+// archive is either a gzipped tarball or a zip archive that contains
+// the plugin directory with the manifest either at its root or in a single
+// top-level directory.
+type Artifact struct {
+	// OS is the operating system as in GOOS, or "any".
+	OS string `json:"os"`
+
+	// Arch is the architecture as in GOARCH, or "any".
+	Arch string `json:"arch"`
+
+	// URL is the URL of the archive. It may be relative to the URL of
+	// the index.
+	URL string `json:"url"`
+
+	// SHA256 is the hexadecimal SHA-256 checksum of the archive.
+	SHA256 string `json:"sha256"`
+}
+
+// A Match is a plugin found in the indexes.
+type Match struct {
+	// Index is the URL of the index the plugin was found in.
+	Index string `json:"index"`
+
+	// Name is the name of the plugin.
+	Name string `json:"name"`
+
+	// Description is the description of the plugin.
+	Description string `json:"description,omitempty"`
+
+	// Latest is the latest version of the plugin that has an artifact for
+	// the current platform. It is empty if there is none.
+	Latest string `json:"latest,omitempty"`
+}
+
+// A Resolved is a version of a plugin resolved from the indexes with
+// the artifact for the current platform.
+type Resolved struct {
+	// Name is the name of the plugin.
+	Name string
+
+	// Version is the resolved version.
+	Version string
+
+	// Artifact is the artifact for the current platform.
+	Artifact Artifact
+}
+
+// Fetch fetches the indexes from the given URLs. The URLs are HTTP(S) URLs,
+// file URLs, or local paths.
+func Fetch(ctx context.Context, urls []string) ([]*Index, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoIndexes
+	}
+
+	indexes := make([]*Index, 0, len(urls))
+
+	for _, u := range urls {
+		data, err := read(ctx, u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch plugin index %q: %w", u, err)
+		}
+
+		var idx Index
+		if err = json.Unmarshal(data, &idx); err != nil {
+			return nil, fmt.Errorf("%w %q: %w", errInvalidIndex, u, err)
+		}
+
+		if idx.Version != IndexVersion {
+			return nil, fmt.Errorf("%w %q: unsupported version %d", errInvalidIndex, u, idx.Version)
+		}
+
+		idx.URL = u
+		indexes = append(indexes, &idx)
+	}
+
+	return indexes, nil
+}
+
+// ParseRef parses a plugin reference of the form "name" or "name@version".
+func ParseRef(ref string) (string, string, error) {
+	name, version, ok := strings.Cut(ref, "@")
+	if !ok {
+		return ref, "", nil
+	}
+
+	if _, err := semver.ParseLax(version); err != nil {
+		return "", "", fmt.Errorf("%w %q for %q: %w", errVersionFormat, version, name, err)
+	}
+
+	return name, version, nil
+}
+
+// SameVersion reports whether the versions a and b are the same semantic
+// version. The versions may be incomplete, like "1.2".
+func SameVersion(a, b string) bool {
+	va, err := semver.ParseLax(a)
+	if err != nil {
+		return false
+	}
+
+	vb, err := semver.ParseLax(b)
+	if err != nil {
+		return false
+	}
+
+	return va.Equal(vb)
+}
+
+// Search returns the plugins in the indexes whose name or description contains
+// term, ignoring case. All of the plugins are returned if term is empty. If
+// the same plugin is in multiple indexes, the first one is used.
+func Search(indexes []*Index, term string) []Match {
+	term = strings.ToLower(term)
+	seen := make(map[string]struct{})
+
+	var matches []Match
+
+	for _, idx := range indexes {
+		for _, e := range idx.Plugins {
+			if _, ok := seen[e.Name]; ok {
+				continue
+			}
+
+			if !strings.Contains(strings.ToLower(e.Name), term) &&
+				!strings.Contains(strings.ToLower(e.Description), term) {
+				continue
+			}
+
+			seen[e.Name] = struct{}{}
+
+			m := Match{Index: idx.URL, Name: e.Name, Description: e.Description, Latest: ""}
+			if r, _, err := e.release(""); err == nil {
+				m.Latest = r.Version
+			}
+
+			matches = append(matches, m)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b Match) int { return cmp.Compare(a.Name, b.Name) })
+
+	return matches
+}
+
+// Resolve finds the given version of the named plugin from the indexes. If
+// version is empty, the latest version that has an artifact for the current
+// platform is used. The indexes are searched in order.
+func Resolve(indexes []*Index, name, version string) (*Resolved, error) {
+	for _, idx := range indexes {
+		i := slices.IndexFunc(idx.Plugins, func(e Entry) bool { return e.Name == name })
+		if i == -1 {
+			continue
+		}
+
+		r, a, err := idx.Plugins[i].release(version)
+		if err != nil {
+			return nil, err
+		}
+
+		artifactURL, err := resolveURL(idx.URL, a.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: artifact of %s@%s: %w", errInvalidIndex, name, r.Version, err)
+		}
+
+		a.URL = artifactURL
+
+		return &Resolved{Name: name, Version: r.Version, Artifact: a}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// release returns the release with the given version and its artifact for
+// the current platform. If version is empty, the latest release with
+// an artifact for the platform is returned.
+func (e *Entry) release(version string) (Release, Artifact, error) {
+	var want *semver.Version
+
+	if version != "" {
+		v, err := semver.ParseLax(version)
+		if err != nil {
+			return Release{}, Artifact{}, fmt.Errorf("%w %q: %w", errVersionFormat, version, err)
+		}
+
+		want = v
+	}
+
+	var (
+		best     Release
+		artifact Artifact
+		bestV    *semver.Version
+	)
+
+	for _, r := range e.Releases {
+		v, err := semver.ParseLax(r.Version)
+		if err != nil {
+			continue
+		}
+
+		if want != nil && !v.Equal(want) {
+			continue
+		}
+
+		a, ok := r.artifact(runtime.GOOS, runtime.GOARCH)
+		if !ok {
+			if want != nil {
+				return Release{}, Artifact{}, fmt.Errorf(
+					"%w: %s@%s has no artifact for %s/%s",
+					ErrNoArtifact,
+					e.Name,
+					r.Version,
+					runtime.GOOS,
+					runtime.GOARCH,
+				)
+			}
+
+			continue
+		}
+
+		if bestV == nil || v.Compare(bestV) > 0 {
+			best, artifact, bestV = r, a, v
+		}
+	}
+
+	if bestV == nil {
+		if want != nil {
+			return Release{}, Artifact{}, fmt.Errorf("%w: %s@%s", ErrNotFound, e.Name, version)
+		}
+
+		return Release{}, Artifact{}, fmt.Errorf(
+			"%w: %s has no artifact for %s/%s",
+			ErrNoArtifact,
+			e.Name,
+			runtime.GOOS,
+			runtime.GOARCH,
+		)
+	}
+
+	return best, artifact, nil
+}
+
+// artifact returns the artifact of r for the given platform. The artifacts
+// for the exact platform are preferred over the ones for any platform.
+func (r *Release) artifact(goos, goarch string) (Artifact, bool) {
+	var (
+		found Artifact
+		ok    bool
+	)
+
+	for _, a := range r.Artifacts {
+		switch {
+		case a.OS == goos && a.Arch == goarch:
+			return a, true
+		case (a.OS == goos || a.OS == anyPlatform) && (a.Arch == goarch || a.Arch == anyPlatform) && !ok:
+			found, ok = a, true
+		}
+	}
+
+	return found, ok
+}
+
+// read reads the contents of the given HTTP(S) URL, file URL, or local path.
+func read(ctx context.Context, rawURL string) ([]byte, error) {
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		name := rawURL
+
+		if strings.HasPrefix(rawURL, "file://") {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+			}
+
+			name = filePath(u)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", name, err)
+		}
+
+		return data, nil
+	}
+
+	data, err := httpclient.ReadAll(ctx, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return data, nil
+}
+
+// resolveURL resolves ref against the URL of the index. The local paths of
+// the indexes are treated as file URLs.
+func resolveURL(base, ref string) (string, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", ref, err)
+	}
+
+	if r.IsAbs() {
+		return ref, nil
+	}
+
+	if !strings.Contains(base, "://") {
+		abs, err := filepath.Abs(base)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %q: %w", base, err)
+		}
+
+		p := filepath.ToSlash(abs)
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+
+		base = (&url.URL{Scheme: "file", Path: p}).String() //nolint:exhaustruct // only the path is needed
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", base, err)
+	}
+
+	return b.ResolveReference(r).String(), nil
+}
+
+// filePath returns the local path of the file URL u.
+func filePath(u *url.URL) string {
+	p := u.Path
+
+	// On Windows, the paths in the file URLs are like "/C:/dir".
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+
+	return filepath.FromSlash(p)
+}
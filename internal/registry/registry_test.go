@@ -0,0 +1,181 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/registry"
+)
+
+func testIndex() *registry.Index {
+	native := []registry.Artifact{
+		{OS: runtime.GOOS, Arch: runtime.GOARCH, URL: "native.tar.gz", SHA256: "aa"},
+		{OS: "any", Arch: "any", URL: "any.tar.gz", SHA256: "bb"},
+	}
+	other := []registry.Artifact{{OS: "plan9", Arch: "mips", URL: "other.tar.gz", SHA256: "cc"}}
+
+	return &registry.Index{
+		URL:     "https://example.com/plugins/index.json",
+		Version: registry.IndexVersion,
+		Plugins: []registry.Entry{
+			{
+				Name:        "reginald-brew",
+				Description: "Install packages with Homebrew.",
+				Homepage:    "",
+				Releases: []registry.Release{
+					{Version: "1.2.0", Artifacts: native},
+					{Version: "1.10.0", Artifacts: native},
+					{Version: "2.0.0", Artifacts: other},
+				},
+			},
+			{
+				Name:        "reginald-apt",
+				Description: "Install packages with APT.",
+				Homepage:    "",
+				Releases:    []registry.Release{{Version: "0.1.0", Artifacts: other}},
+			},
+			{
+				Name:        "reginald-links",
+				Description: "Create symbolic links.",
+				Homepage:    "",
+				Releases: []registry.Release{
+					{Version: "1.0.0", Artifacts: native[1:]},
+				},
+			},
+		},
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	indexes := []*registry.Index{testIndex()}
+
+	tests := []struct {
+		name string
+		term string
+		want []string
+	}{
+		{"All", "", []string{"reginald-apt@", "reginald-brew@1.10.0", "reginald-links@1.0.0"}},
+		{"Name", "BREW", []string{"reginald-brew@1.10.0"}},
+		{"Description", "packages", []string{"reginald-apt@", "reginald-brew@1.10.0"}},
+		{"None", "nothing", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+
+			for _, m := range registry.Search(indexes, tt.term) {
+				got = append(got, m.Name+"@"+m.Latest)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	indexes := []*registry.Index{testIndex()}
+
+	tests := []struct {
+		name        string
+		plugin      string
+		version     string
+		wantVersion string
+		wantURL     string
+		wantErr     error
+	}{
+		{"Latest", "reginald-brew", "", "1.10.0", "https://example.com/plugins/native.tar.gz", nil},
+		{"Pinned", "reginald-brew", "1.2", "1.2.0", "https://example.com/plugins/native.tar.gz", nil},
+		{"Any platform", "reginald-links", "", "1.0.0", "https://example.com/plugins/any.tar.gz", nil},
+		{"Pinned other platform", "reginald-brew", "2.0.0", "", "", registry.ErrNoArtifact},
+		{"No artifact", "reginald-apt", "", "", "", registry.ErrNoArtifact},
+		{"Missing version", "reginald-brew", "3.0.0", "", "", registry.ErrNotFound},
+		{"Missing plugin", "reginald-none", "", "", "", registry.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := registry.Resolve(indexes, tt.plugin, tt.version)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Resolve(%q, %q) error = %v, want %v", tt.plugin, tt.version, err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Resolve(%q, %q) error = %v", tt.plugin, tt.version, err)
+			}
+
+			if got.Version != tt.wantVersion || got.Artifact.URL != tt.wantURL {
+				t.Errorf(
+					"Resolve(%q, %q) = %s %s, want %s %s",
+					tt.plugin,
+					tt.version,
+					got.Version,
+					got.Artifact.URL,
+					tt.wantVersion,
+					tt.wantURL,
+				)
+			}
+		})
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ref         string
+		wantName    string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"reginald-brew", "reginald-brew", "", false},
+		{"reginald-brew@1.2.3", "reginald-brew", "1.2.3", false},
+		{"reginald-brew@v1", "reginald-brew", "v1", false},
+		{"reginald-brew@latest", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			t.Parallel()
+
+			name, version, err := registry.ParseRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRef(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+
+			if name != tt.wantName || version != tt.wantVersion {
+				t.Errorf("ParseRef(%q) = %q, %q, want %q, %q", tt.ref, name, version, tt.wantName, tt.wantVersion)
+			}
+		})
+	}
+}
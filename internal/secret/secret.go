@@ -0,0 +1,169 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret implements the encryption of the sensitive values in
+// the config file. The values are encrypted with AES-256-GCM using a key that
+// is stored in a separate key file outside of the dotfiles directory.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize is the size of the encryption keys in bytes.
+const KeySize = 32
+
+// prefix is the prefix of the encrypted values. It identifies the format and
+// the algorithm so that the format can be changed later.
+const prefix = "reginald:aes256gcm:"
+
+// Errors returned by the secret functions.
+var (
+	ErrInvalidKey   = errors.New("invalid encryption key")
+	ErrInvalidValue = errors.New("invalid encrypted value")
+)
+
+// Decrypt decrypts the value that was encrypted with [Encrypt] using
+// the given key.
+func Decrypt(key []byte, value string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(value), prefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q prefix", ErrInvalidValue, prefix)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: value is too short", ErrInvalidValue)
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decryption failed, the key might be wrong: %w", ErrInvalidValue, err)
+	}
+
+	return plaintext, nil
+}
+
+// Encrypt encrypts plaintext using the given key. The result is a printable
+// string that contains the format prefix and the nonce and the ciphertext
+// encoded in base64.
+func Encrypt(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data := gcm.Seal(nonce, nonce, plaintext, nil)
+
+	return prefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// GenerateKey generates a new random key and writes it to the key file at
+// path. The key is written encoded in hexadecimal. The file must not exist.
+func GenerateKey(path string) ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil { //nolint:mnd // private directory
+		return nil, fmt.Errorf("failed to create directory for key file %q: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) //nolint:mnd // private file
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file %q: %w", path, err)
+	}
+
+	if _, err = f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("failed to write key file %q: %w", path, err)
+	}
+
+	if err = f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close key file %q: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// IsEncrypted reports whether the value has the format of the values created
+// with [Encrypt].
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), prefix)
+}
+
+// ReadKey reads the hexadecimal-encoded key from the key file at path.
+func ReadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %q: %w", path, err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode key file %q: %w", ErrInvalidKey, path, err)
+	}
+
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: key in %q has %d bytes, want %d", ErrInvalidKey, path, len(key), KeySize)
+	}
+
+	return key, nil
+}
+
+// newGCM returns the AES-GCM cipher for the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: key has %d bytes, want %d", ErrInvalidKey, len(key), KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM cipher: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/secret"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "key")
+
+	key, err := secret.GenerateKey(path)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+
+	readKey, err := secret.ReadKey(path)
+	if err != nil {
+		t.Fatalf("ReadKey() failed: %v", err)
+	}
+
+	if string(readKey) != string(key) {
+		t.Fatalf("ReadKey() = %x, want %x", readKey, key)
+	}
+
+	if _, err = secret.GenerateKey(path); err == nil {
+		t.Error("GenerateKey() overwrote an existing key file")
+	}
+
+	otherKey, err := secret.GenerateKey(filepath.Join(t.TempDir(), "key"))
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Empty", ""},
+		{"Table", "[github]\ntoken = \"secret\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := secret.Encrypt(key, []byte(tt.plaintext))
+			if err != nil {
+				t.Fatalf("Encrypt() failed: %v", err)
+			}
+
+			if !secret.IsEncrypted(value) {
+				t.Errorf("IsEncrypted(%q) = false, want true", value)
+			}
+
+			got, err := secret.Decrypt(key, value)
+			if err != nil {
+				t.Fatalf("Decrypt() failed: %v", err)
+			}
+
+			if string(got) != tt.plaintext {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.plaintext)
+			}
+
+			if _, err = secret.Decrypt(otherKey, value); !errors.Is(err, secret.ErrInvalidValue) {
+				t.Errorf("Decrypt() with wrong key returned %v, want %v", err, secret.ErrInvalidValue)
+			}
+		})
+	}
+}
+
+func TestDecrypt_Invalid(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, secret.KeySize)
+
+	tests := []struct {
+		name  string
+		key   []byte
+		value string
+		want  error
+	}{
+		{"No prefix", key, "plain", secret.ErrInvalidValue},
+		{"Invalid base64", key, "reginald:aes256gcm:!!!", secret.ErrInvalidValue},
+		{"Too short", key, "reginald:aes256gcm:AAAA", secret.ErrInvalidValue},
+		{"Short key", key[:16], "reginald:aes256gcm:AAAA", secret.ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := secret.Decrypt(tt.key, tt.value); !errors.Is(err, tt.want) {
+				t.Errorf("Decrypt() returned %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
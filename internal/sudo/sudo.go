@@ -0,0 +1,260 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sudo implements running the elevated operations of the tasks with
+// sudo. Before the elevated tasks of a run are run, the credentials for sudo
+// are acquired once, asking the user for the password through the terminal if
+// needed, and they are kept valid until the run ends. The elevated operations
+// are then run with sudo in the non-interactive mode so that they never prompt
+// for the password themselves.
+package sudo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reginald-project/reginald/internal/terminal"
+)
+
+// program is the name of the sudo executable.
+const program = "sudo"
+
+// maxAttempts is the number of times the user is asked for the password before
+// giving up.
+const maxAttempts = 3
+
+// keepAliveInterval is the interval for refreshing the cached credentials so
+// that they do not time out during a long run. The default timeout of sudo is
+// five minutes.
+const keepAliveInterval = time.Minute
+
+// Errors returned by the sudo session.
+var (
+	errAuth           = errors.New("sudo authentication failed")
+	errNotInteractive = errors.New("sudo requires a password but it cannot be asked")
+	errUnsupported    = errors.New("elevated tasks are not supported on " + runtime.GOOS)
+)
+
+// defaultSession is the session that the package-level functions use.
+var defaultSession = New() //nolint:gochecknoglobals // global session for the run
+
+// A Session is a sudo session of a run. It holds the cached credentials of sudo
+// valid while it is active. It is safe for concurrent use.
+type Session struct {
+	// ask asks the user for the password.
+	ask func(ctx context.Context, prompt string) (string, error)
+
+	// stop stops refreshing the credentials. It is nil if the session is not
+	// active or if it does not need refreshing.
+	stop context.CancelFunc
+
+	// done is closed when the goroutine that refreshes the credentials exits.
+	done chan struct{}
+
+	mu sync.Mutex
+
+	// root tells whether the process is already run as root and sudo is not
+	// needed.
+	root bool
+
+	// active tells whether the session has been authenticated.
+	active bool
+}
+
+// New returns a new Session that asks the password through the default
+// terminal.
+func New() *Session {
+	return &Session{
+		ask:    askPassword,
+		stop:   nil,
+		done:   nil,
+		mu:     sync.Mutex{},
+		root:   os.Geteuid() == 0,
+		active: false,
+	}
+}
+
+// Authenticate acquires the credentials for the default session. See
+// [Session.Authenticate].
+func Authenticate(ctx context.Context) error {
+	return defaultSession.Authenticate(ctx)
+}
+
+// Command returns the command for running the given program elevated in
+// the default session. See [Session.Command].
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return defaultSession.Command(ctx, name, args...)
+}
+
+// Release ends the default session. See [Session.Release].
+func Release() {
+	defaultSession.Release()
+}
+
+// Authenticate acquires the credentials for sudo and starts refreshing them in
+// the background until the session is released. If sudo does not need
+// a password, the user is not asked for it. Otherwise the password is asked
+// through the terminal as a secret, and it is only passed to sudo and never
+// logged or stored. Calling Authenticate on an active session does nothing.
+func (s *Session) Authenticate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active {
+		return nil
+	}
+
+	if runtime.GOOS == "windows" {
+		return errUnsupported
+	}
+
+	if s.root {
+		slog.DebugContext(ctx, "running as root, sudo is not needed")
+
+		s.active = true
+
+		return nil
+	}
+
+	if _, err := exec.LookPath(program); err != nil {
+		return fmt.Errorf("%w: %w", errAuth, err)
+	}
+
+	if err := exec.CommandContext(ctx, program, "-n", "true").Run(); err != nil {
+		slog.DebugContext(ctx, "sudo requires a password", "err", err)
+
+		if err = s.authenticate(ctx); err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "sudo credentials acquired")
+
+	keepCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	s.stop = stop
+	s.done = make(chan struct{})
+	s.active = true
+
+	go s.keepAlive(keepCtx, s.done)
+
+	return nil
+}
+
+// Command returns the command for running the given program elevated. The
+// program is run with sudo in the non-interactive mode so it fails instead
+// of asking for the password if the session is not authenticated. If
+// the process is run as root, the program is run directly.
+func (s *Session) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if s.root {
+		return exec.CommandContext(ctx, name, args...)
+	}
+
+	return exec.CommandContext(ctx, program, append([]string{"-n", "--", name}, args...)...)
+}
+
+// Release ends the session and stops refreshing the credentials. The cached
+// credentials are not invalidated so that sudo behaves the same way after
+// the run as it would have without it.
+func (s *Session) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		s.stop()
+		<-s.done
+	}
+
+	s.stop = nil
+	s.done = nil
+	s.active = false
+}
+
+// authenticate asks the user for the password and validates it with sudo.
+// The user is asked again if the password is incorrect.
+func (s *Session) authenticate(ctx context.Context) error {
+	prompt := "Password for sudo: "
+
+	for attempt := 1; ; attempt++ {
+		password, err := s.ask(ctx, prompt)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errAuth, err)
+		}
+
+		var stderr bytes.Buffer
+
+		// The prompt of sudo is disabled as the password is read from
+		// the standard input.
+		cmd := exec.CommandContext(ctx, program, "-S", "-v", "-p", "")
+		cmd.Stdin = strings.NewReader(password + "\n")
+		cmd.Stderr = &stderr
+
+		err = cmd.Run()
+		if err == nil {
+			return nil
+		}
+
+		slog.DebugContext(ctx, "sudo authentication failed", "attempt", attempt, "stderr", stderr.String())
+
+		if ctx.Err() != nil || attempt == maxAttempts {
+			return fmt.Errorf("%w after %d attempts", errAuth, attempt)
+		}
+
+		prompt = "Sorry, try again. Password for sudo: "
+	}
+}
+
+// keepAlive refreshes the credentials of sudo periodically until ctx is
+// canceled. It closes done when it returns.
+func (s *Session) keepAlive(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := exec.CommandContext(ctx, program, "-n", "-v").Run(); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "failed to refresh sudo credentials", "err", err)
+			}
+		}
+	}
+}
+
+// askPassword asks the password for sudo through the default terminal. It does
+// not require the interactive mode as the password is needed whenever
+// the elevated tasks are run.
+func askPassword(ctx context.Context, prompt string) (string, error) {
+	if !terminal.CanAskSecret() {
+		return "", errNotInteractive
+	}
+
+	password, err := terminal.AskSecret(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return password, nil
+}
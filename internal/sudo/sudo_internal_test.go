@@ -0,0 +1,122 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sudo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSudo is a sudo that accepts the password "secret" and runs the commands
+// only after it has been given.
+const fakeSudo = `#!/bin/sh
+state="$(dirname "$0")/authenticated"
+case "$1" in
+-n)
+	[ -f "$state" ] || exit 1
+	if [ "$2" = "--" ]; then
+		shift 2
+		exec "$@"
+	fi
+	;;
+-S)
+	read -r password
+	[ "$password" = "secret" ] || exit 1
+	touch "$state"
+	;;
+esac
+`
+
+func TestSession(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sudo is not supported on Windows")
+	}
+
+	tests := []struct {
+		name      string
+		passwords []string
+		wantAsked int
+		wantErr   bool
+	}{
+		{"Correct", []string{"secret"}, 1, false},
+		{"Retry", []string{"wrong", "secret"}, 2, false},
+		{"Fail", []string{"wrong", "wrong", "wrong", "secret"}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := os.WriteFile(filepath.Join(dir, program), []byte(fakeSudo), 0o755); err != nil { //nolint:gosec // executable
+				t.Fatal(err)
+			}
+
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+			asked := 0
+			s := &Session{
+				ask: func(_ context.Context, _ string) (string, error) {
+					asked++
+
+					return tt.passwords[asked-1], nil
+				},
+				stop:   nil,
+				done:   nil,
+				mu:     sync.Mutex{},
+				root:   false,
+				active: false,
+			}
+
+			err := s.Authenticate(t.Context())
+			defer s.Release()
+
+			if asked != tt.wantAsked {
+				t.Errorf("asked for the password %d times, want %d", asked, tt.wantAsked)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, errAuth) {
+					t.Fatalf("Authenticate() = %v, want %v", err, errAuth)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authenticate() failed: %v", err)
+			}
+
+			// An active session does not ask for the password again.
+			if err = s.Authenticate(t.Context()); err != nil || asked != tt.wantAsked {
+				t.Errorf("second Authenticate() = %v after asking %d times", err, asked)
+			}
+
+			out, err := s.Command(t.Context(), "echo", "elevated").Output()
+			if err != nil {
+				t.Fatalf("Command() failed: %v", err)
+			}
+
+			if got := strings.TrimSpace(string(out)); got != "elevated" {
+				t.Errorf("Command() output = %q, want %q", got, "elevated")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// packageManagers contains the executables of the package managers that are
+// checked when the facts are collected.
+//
+//nolint:gochecknoglobals // used like a constant
+var packageManagers = []string{
+	"apk",
+	"apt",
+	"brew",
+	"choco",
+	"dnf",
+	"flatpak",
+	"nix",
+	"pacman",
+	"port",
+	"scoop",
+	"snap",
+	"winget",
+	"yum",
+	"zypper",
+}
+
+// currentFacts returns the facts about the current system. They are collected
+// only once.
+//
+//nolint:gochecknoglobals // facts are cached for the run
+var currentFacts = sync.OnceValue(collectFacts)
+
+// Facts contains the facts about the current system. They are collected once
+// and shared with the task conditions and the plugins so that the plugins do
+// not need to detect them again.
+type Facts struct {
+	// OS is the operating system as reported by the Go runtime, for example,
+	// "linux", "darwin", or "windows".
+	OS string `json:"os"`
+
+	// Distro is the ID of the Linux distribution from the os-release file. It
+	// is empty on the other operating systems.
+	Distro string `json:"distro,omitempty"`
+
+	// DistroLike contains the IDs of the distributions that the current Linux
+	// distribution is derived from.
+	DistroLike []string `json:"distroLike,omitempty"`
+
+	// Arch is the architecture as reported by the Go runtime, for example,
+	// "amd64" or "arm64".
+	Arch string `json:"arch"`
+
+	// Hostname is the host name of the system.
+	Hostname string `json:"hostname"`
+
+	// User is the name of the current user.
+	User string `json:"user"`
+
+	// Home is the home directory of the current user.
+	Home string `json:"home"`
+
+	// Shell is the login shell of the current user.
+	Shell string `json:"shell"`
+
+	// PackageManagers contains the package managers that are available in
+	// the executable search path.
+	PackageManagers []string `json:"packageManagers,omitempty"`
+
+	// CPUs is the number of logical CPUs.
+	CPUs int `json:"cpus"`
+
+	// WSL tells whether the program runs in the Windows Subsystem for Linux.
+	WSL bool `json:"wsl"`
+}
+
+// CurrentFacts returns the facts about the current system. The facts are
+// collected on the first call and the same facts are returned after that.
+func CurrentFacts() *Facts {
+	return currentFacts()
+}
+
+// Vars returns the facts as variables for the condition expressions. Each
+// checked package manager has a variable "pkg.<name>" that is either "true" or
+// "false".
+func (f *Facts) Vars() map[string]string {
+	vars := map[string]string{
+		"arch":     f.Arch,
+		"cpus":     strconv.Itoa(f.CPUs),
+		"distro":   f.Distro,
+		"home":     f.Home,
+		"hostname": f.Hostname,
+		"os":       f.OS,
+		"shell":    f.Shell,
+		"user":     f.User,
+		"wsl":      strconv.FormatBool(f.WSL),
+	}
+
+	for _, pm := range packageManagers {
+		vars["pkg."+pm] = "false"
+	}
+
+	for _, pm := range f.PackageManagers {
+		vars["pkg."+pm] = "true"
+	}
+
+	return vars
+}
+
+// collectFacts collects the facts about the current system. The facts that
+// cannot be detected are left empty.
+func collectFacts() *Facts {
+	facts := &Facts{
+		OS:              runtime.GOOS,
+		Distro:          "",
+		DistroLike:      nil,
+		Arch:            runtime.GOARCH,
+		Hostname:        "",
+		User:            "",
+		Home:            "",
+		Shell:           "",
+		PackageManagers: nil,
+		CPUs:            runtime.NumCPU(),
+		WSL:             false,
+	}
+
+	if runtime.GOOS == Linux {
+		if id, idLike, err := OSRelease(); err == nil {
+			facts.Distro = id
+			facts.DistroLike = idLike
+		}
+
+		facts.WSL = detectWSL()
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		facts.Hostname = hostname
+	}
+
+	if u, err := user.Current(); err == nil {
+		facts.User = u.Username
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		facts.Home = home
+	}
+
+	facts.Shell = os.Getenv("SHELL")
+	if facts.Shell == "" && runtime.GOOS == "windows" {
+		facts.Shell = os.Getenv("COMSPEC")
+	}
+
+	for _, pm := range packageManagers {
+		if _, err := exec.LookPath(pm); err == nil {
+			facts.PackageManagers = append(facts.PackageManagers, pm)
+		}
+	}
+
+	return facts
+}
+
+// detectWSL reports whether the program runs in the Windows Subsystem for
+// Linux.
+func detectWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+
+	if _, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop"); err == nil {
+		return true
+	}
+
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
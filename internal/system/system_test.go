@@ -177,3 +177,62 @@ func TestOSCurrent(t *testing.T) {
 		})
 	}
 }
+
+func TestFactsVars(t *testing.T) {
+	t.Parallel()
+
+	facts := &system.Facts{
+		OS:              "linux",
+		Distro:          "debian",
+		DistroLike:      nil,
+		Arch:            "arm64",
+		Hostname:        "work-1",
+		User:            "reginald",
+		Home:            "/home/reginald",
+		Shell:           "/bin/zsh",
+		PackageManagers: []string{"apt"},
+		CPUs:            8,
+		WSL:             true,
+	}
+
+	vars := facts.Vars()
+
+	want := map[string]string{
+		"arch":     "arm64",
+		"cpus":     "8",
+		"distro":   "debian",
+		"hostname": "work-1",
+		"os":       "linux",
+		"pkg.apt":  "true",
+		"pkg.brew": "false",
+		"wsl":      "true",
+	}
+
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("Vars()[%q] = %q, want %q", k, vars[k], v)
+		}
+	}
+}
+
+func TestCurrentFacts(t *testing.T) {
+	t.Parallel()
+
+	facts := system.CurrentFacts()
+
+	if facts.OS != runtime.GOOS {
+		t.Errorf("OS = %q, want %q", facts.OS, runtime.GOOS)
+	}
+
+	if facts.Arch != runtime.GOARCH {
+		t.Errorf("Arch = %q, want %q", facts.Arch, runtime.GOARCH)
+	}
+
+	if facts.CPUs < 1 {
+		t.Errorf("CPUs = %d, want at least 1", facts.CPUs)
+	}
+
+	if system.CurrentFacts() != facts {
+		t.Error("CurrentFacts() collected the facts again")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Possible values for [Assume].
+const (
+	AssumeNone Assume = iota
+	AssumeYes
+	AssumeDefaults
+)
+
+// errAssumedSecret is returned when secret input is requested while
+// the answers are assumed as there is no default value for secrets.
+var errAssumedSecret = errors.New("cannot assume an answer for secret input")
+
+// Assume tells which answers the [Terminal] assumes for the prompts instead of
+// asking the user.
+//
+// With AssumeYes, the confirmations are answered with yes. With AssumeDefaults,
+// they are answered with their default choices. In both modes, the text prompts
+// are answered with empty input, which selects the default value of the prompt,
+// the first option is selected in the selection prompts, and no options are
+// selected in the multiple selection prompts.
+type Assume int
+
+// SetAnswers sets s to read the answers to the prompts from r, one answer per
+// line, instead of reading them from the terminal. The prompts are still
+// written to the output and the answers are echoed after them, except for
+// the secret input. If r runs out of lines, the prompts return [io.EOF]. It is
+// meant for automated runs and tests, and it must be called before the first
+// prompt.
+func (s *Terminal) SetAnswers(r io.Reader) {
+	if r == nil {
+		s.answers = nil
+
+		return
+	}
+
+	s.answers = bufio.NewReader(r)
+}
+
+// SetAssume sets the answers that s assumes for the prompts instead of asking
+// the user. It must be called before the first prompt.
+func (s *Terminal) SetAssume(a Assume) {
+	s.assume = a
+}
+
+// SetPromptHook sets the function that s calls when it starts waiting for
+// the answer to a prompt. The function is called with the text of the prompt,
+// the options of a selection prompt, and whether the prompt asks for secret
+// input. It must be called before the first prompt.
+func (s *Terminal) SetPromptHook(f func(prompt string, options []string, secret bool)) {
+	s.onPrompt = f
+}
+
+// doAnswer reads the answer to a text prompt from the answers reader of s. It
+// must only be called from the IO goroutine.
+func (s *Terminal) doAnswer(p promptRequest) {
+	s.writeRaw(s.paint(s.theme.Prompt.sgr(s.colorDepth), p.prompt))
+
+	line, err := s.answers.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		s.writeRaw("\n")
+
+		if !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("failed to read answer: %w", err)
+		}
+
+		p.response <- promptResponse{response: "", err: err, selected: nil}
+		close(p.response)
+
+		return
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+
+	if p.secret {
+		s.writeRaw("\n")
+	} else {
+		s.writeRaw(line + "\n")
+	}
+
+	p.response <- promptResponse{response: line, err: nil, selected: nil}
+	close(p.response)
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSetAnswers(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	ctx := t.Context()
+	s := newTerminal(ctx, io.NopCloser(strings.NewReader("")), &out, io.Discard)
+	s.Init(false, false, true, ColorNever, DefaultTheme())
+	s.SetAnswers(strings.NewReader("y\nno\nhello\n2\nsecret\n"))
+
+	if ok, err := s.ConfirmE(ctx, "First?", false); err != nil || !ok {
+		t.Errorf("ConfirmE() = %v, %v, want true, nil", ok, err)
+	}
+
+	if ok, err := s.ConfirmE(ctx, "Second?", true); err != nil || ok {
+		t.Errorf("ConfirmE() = %v, %v, want false, nil", ok, err)
+	}
+
+	if answer, err := s.Ask(ctx, "Name: "); err != nil || answer != "hello" {
+		t.Errorf("Ask() = %q, %v, want \"hello\", nil", answer, err)
+	}
+
+	if i, err := s.Select(ctx, "Pick:", []string{"a", "b", "c"}); err != nil || i != 1 {
+		t.Errorf("Select() = %d, %v, want 1, nil", i, err)
+	}
+
+	if answer, err := s.AskSecret(ctx, "Token: "); err != nil || answer != "secret" {
+		t.Errorf("AskSecret() = %q, %v, want \"secret\", nil", answer, err)
+	}
+
+	if _, err := s.Ask(ctx, "More: "); !errors.Is(err, io.EOF) {
+		t.Errorf("Ask() error = %v, want %v", err, io.EOF)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	if got := out.String(); !strings.Contains(got, "Name: hello\n") || strings.Contains(got, "secret") {
+		t.Errorf("output = %q, want the answers echoed except for the secret", got)
+	}
+}
+
+func TestSetAssume(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		assume      Assume
+		def         bool
+		wantConfirm bool
+	}{
+		{AssumeYes, false, true},
+		{AssumeYes, true, true},
+		{AssumeDefaults, false, false},
+		{AssumeDefaults, true, true},
+	} {
+		s := newTerminal(ctx, io.NopCloser(strings.NewReader("")), io.Discard, io.Discard)
+		s.Init(false, false, true, ColorNever, DefaultTheme())
+		s.SetAssume(test.assume)
+
+		if ok, err := s.ConfirmE(ctx, "Continue?", test.def); err != nil || ok != test.wantConfirm {
+			t.Errorf("assume %d: ConfirmE(%v) = %v, %v, want %v, nil", test.assume, test.def, ok, err, test.wantConfirm)
+		}
+
+		if answer, err := s.Ask(ctx, "Name: "); err != nil || answer != "" {
+			t.Errorf("assume %d: Ask() = %q, %v, want \"\", nil", test.assume, answer, err)
+		}
+
+		if i, err := s.Select(ctx, "Pick:", []string{"a", "b"}); err != nil || i != 0 {
+			t.Errorf("assume %d: Select() = %d, %v, want 0, nil", test.assume, i, err)
+		}
+
+		if _, err := s.AskSecret(ctx, "Token: "); !errors.Is(err, errAssumedSecret) {
+			t.Errorf("assume %d: AskSecret() error = %v, want %v", test.assume, err, errAssumedSecret)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	}
+}
+
+func TestCanAskSecret(t *testing.T) {
+	t.Parallel()
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		quiet       bool
+		interactive bool
+		ci          bool
+		want        bool
+	}{
+		{false, false, false, true},
+		{false, true, false, true},
+		{true, false, false, false},
+		{false, false, true, false},
+	} {
+		s := newTerminal(t.Context(), io.NopCloser(strings.NewReader("")), io.Discard, io.Discard)
+		s.Init(test.quiet, false, test.interactive, ColorNever, DefaultTheme())
+		s.SetCI(test.ci)
+		s.SetAnswers(strings.NewReader("secret\n"))
+
+		if got := s.CanAskSecret(); got != test.want {
+			t.Errorf("CanAskSecret() with quiet=%v, interactive=%v, ci=%v = %v, want %v",
+				test.quiet, test.interactive, test.ci, got, test.want)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	}
+}
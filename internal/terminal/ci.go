@@ -0,0 +1,47 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// DetectCI reports whether the program is run in a continuous integration
+// environment or otherwise without a user at a terminal. It is detected from
+// the "CI" environment variable that most of the CI services set, or from
+// neither the standard input nor the standard output being a terminal, which
+// is the case in container builds, for example.
+func DetectCI() bool {
+	if ciEnv(os.Getenv("CI")) {
+		return true
+	}
+
+	return !term.IsTerminal(int(os.Stdin.Fd())) && !term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// ciEnv reports whether the value of the "CI" environment variable tells that
+// the program is run in a CI environment. Any value other than an empty value,
+// "0", or "false" is accepted as the services use different values.
+func ciEnv(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false":
+		return false
+	default:
+		return true
+	}
+}
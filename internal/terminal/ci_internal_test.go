@@ -0,0 +1,38 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import "testing"
+
+func TestCIEnv(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		in   string
+		want bool
+	}{
+		{"", false},
+		{"0", false},
+		{"false", false},
+		{" False ", false},
+		{"true", true},
+		{"1", true},
+		{"yes", true},
+	} {
+		if got := ciEnv(test.in); got != test.want {
+			t.Errorf("ciEnv(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
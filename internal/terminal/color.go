@@ -0,0 +1,351 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Color depths supported by the terminal.
+const (
+	depth16 colorDepth = iota
+	depth256
+	depthTrueColor
+)
+
+// errColor is returned when an invalid value is parsed into [Color].
+var errColor = errors.New("invalid color")
+
+// colorNames are the names of the basic colors in the order of their ANSI
+// codes.
+var colorNames = []string{ //nolint:gochecknoglobals // used as a constant
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+}
+
+// palette16 contains the RGB values of the 16 basic colors as they are in
+// the default palette of xterm. It is used to find the closest basic color for
+// the colors that the terminal cannot show.
+var palette16 = [16][3]int{ //nolint:gochecknoglobals // used as a constant
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// A Color is a color in the user interface. It is one of the basic color names
+// ("red", "bright-red", etc.), an index in the 256-color palette ("208"),
+// a hexadecimal RGB value ("#ff8700"), or "none" or empty for using
+// the default color of the terminal. The colors that the terminal does not
+// support are converted to the closest supported color.
+type Color string //nolint:recvcheck // needs different receiver types
+
+// A Theme contains the colors that are used in the user interface.
+type Theme struct {
+	// Warning is the color of the warnings.
+	Warning Color `mapstructure:"warning"`
+
+	// Error is the color of the error messages.
+	Error Color `mapstructure:"error"`
+
+	// Prompt is the color of the prompts that ask for input.
+	Prompt Color `mapstructure:"prompt"`
+
+	// Progress is the color of the messages that tell about the progress of
+	// the run.
+	Progress Color `mapstructure:"progress"`
+
+	// Hint is the color of the "hint:" prefix of the suggestions for fixing
+	// the errors.
+	Hint Color `mapstructure:"hint"`
+}
+
+// colorDepth is the number of colors that the terminal supports.
+type colorDepth int
+
+// parsedColor is the parsed form of a [Color]. Exactly one of the fields is
+// used, or none of them for the default color.
+type parsedColor struct {
+	basic   int // 1-16 for the basic colors, 0 if not set
+	index   int // 1-256 for the 256-color palette index plus one, 0 if not set
+	rgb     [3]int
+	trueRGB bool
+}
+
+// DefaultTheme returns the default color theme.
+func DefaultTheme() Theme {
+	return Theme{
+		Warning:  "yellow",
+		Error:    "red",
+		Prompt:   "",
+		Progress: "cyan",
+		Hint:     "cyan",
+	}
+}
+
+// ColorsEnabled reports whether the colors should be enabled with the given
+// color mode. In the automatic mode, the colors are disabled if "NO_COLOR" is
+// set or "CLICOLOR" is "0", and enabled if "CLICOLOR_FORCE" is set even if
+// the standard output is not a terminal. Otherwise, the colors are enabled if
+// the standard output is a terminal. The color modes set by the user always
+// take precedence over the environment variables.
+func ColorsEnabled(mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	case ColorAuto:
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("CLICOLOR") == "0" {
+			return false
+		}
+
+		if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+			return true
+		}
+
+		return term.IsTerminal(int(os.Stdout.Fd()))
+	default:
+		panic(fmt.Sprintf("invalid Terminal color mode: %v", mode))
+	}
+}
+
+// Set sets the value of c from the given string s.
+func (c *Color) Set(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if _, err := parseColor(s); err != nil {
+		return err
+	}
+
+	*c = Color(s)
+
+	return nil
+}
+
+// String returns the string representation of c.
+func (c Color) String() string {
+	return string(c)
+}
+
+// Type returns type of c as a string for command-line flags.
+func (*Color) Type() string {
+	return "Color"
+}
+
+// MarshalText encodes c in a textual form.
+func (c Color) MarshalText() ([]byte, error) { //nolint:unparam // implements interface
+	return []byte(c), nil
+}
+
+// UnmarshalText assigns the value from the given textual representation to c.
+func (c *Color) UnmarshalText(data []byte) error {
+	if err := c.Set(string(data)); err != nil {
+		return fmt.Errorf("failed to set Color: %w", err)
+	}
+
+	return nil
+}
+
+// sgr returns the parameters of the ANSI "Select Graphic Rendition" sequence
+// that sets c as the foreground color on a terminal with the given color
+// depth. It returns an empty string if c uses the default color.
+func (c Color) sgr(depth colorDepth) string {
+	rgb, err := parseColor(string(c))
+	if err != nil {
+		// The colors are validated when they are set so this only happens
+		// with invalid zero-values.
+		return ""
+	}
+
+	return rgb.sgr(depth)
+}
+
+// detectColorDepth returns the color depth that the terminal supports based on
+// the "COLORTERM" and "TERM" environment variables.
+func detectColorDepth() colorDepth {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return depthTrueColor
+	}
+
+	term := os.Getenv("TERM")
+
+	switch {
+	case strings.HasSuffix(term, "-direct"), os.Getenv("WT_SESSION") != "":
+		return depthTrueColor
+	case strings.Contains(term, "256color"):
+		return depth256
+	default:
+		return depth16
+	}
+}
+
+// parseColor parses the given color string.
+func parseColor(s string) (parsedColor, error) {
+	var c parsedColor
+
+	switch {
+	case s == "", s == "none", s == "default":
+		return c, nil
+	case strings.HasPrefix(s, "#"):
+		if len(s) != len("#rrggbb") {
+			return c, fmt.Errorf("%w: %q", errColor, s)
+		}
+
+		for i := range 3 {
+			v, err := strconv.ParseUint(s[1+2*i:3+2*i], 16, 8)
+			if err != nil {
+				return c, fmt.Errorf("%w: %q", errColor, s)
+			}
+
+			c.rgb[i] = int(v)
+		}
+
+		c.trueRGB = true
+
+		return c, nil
+	case s[0] >= '0' && s[0] <= '9':
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return c, fmt.Errorf("%w: %q", errColor, s)
+		}
+
+		c.index = int(v) + 1
+
+		return c, nil
+	}
+
+	name, bright := strings.CutPrefix(s, "bright-")
+
+	for i, n := range colorNames {
+		if n == name {
+			c.basic = i + 1
+
+			if bright {
+				c.basic += len(colorNames)
+			}
+
+			return c, nil
+		}
+	}
+
+	return c, fmt.Errorf("%w: %q", errColor, s)
+}
+
+// sgr returns the SGR parameters for c on a terminal with the given color
+// depth.
+func (c parsedColor) sgr(depth colorDepth) string {
+	switch {
+	case c.basic > 0:
+		return basicSGR(c.basic - 1)
+	case c.index > 0:
+		i := c.index - 1
+		if i < len(palette16) {
+			return basicSGR(i)
+		}
+
+		if depth == depth16 {
+			return basicSGR(closestBasic(indexRGB(i)))
+		}
+
+		return "38;5;" + strconv.Itoa(i)
+	case c.trueRGB:
+		switch depth {
+		case depthTrueColor:
+			return fmt.Sprintf("38;2;%d;%d;%d", c.rgb[0], c.rgb[1], c.rgb[2])
+		case depth256:
+			return "38;5;" + strconv.Itoa(closestIndex(c.rgb))
+		default:
+			return basicSGR(closestBasic(c.rgb))
+		}
+	default:
+		return ""
+	}
+}
+
+// basicSGR returns the SGR parameter for the basic color with the given index
+// from 0 to 15.
+func basicSGR(i int) string {
+	if i < len(colorNames) {
+		return strconv.Itoa(int(black) + i)
+	}
+
+	// The bright colors start from 90.
+	return strconv.Itoa(int(black) + 60 + i - len(colorNames)) //nolint:mnd // offset of the bright colors
+}
+
+// closestBasic returns the index of the basic color that is the closest to
+// the given RGB value.
+func closestBasic(rgb [3]int) int {
+	best, bestDist := 0, -1
+
+	for i, p := range palette16 {
+		if d := colorDistance(rgb, p); bestDist == -1 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+
+	return best
+}
+
+// closestIndex returns the index of the color in the 6×6×6 color cube or in
+// the grayscale ramp of the 256-color palette that is the closest to the given
+// RGB value.
+func closestIndex(rgb [3]int) int {
+	best, bestDist := 0, -1
+
+	for i := 16; i < 256; i++ {
+		if d := colorDistance(rgb, indexRGB(i)); bestDist == -1 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+
+	return best
+}
+
+// indexRGB returns the RGB value of the color with the given index in
+// the 256-color palette.
+func indexRGB(i int) [3]int {
+	switch {
+	case i < len(palette16):
+		return palette16[i]
+	case i < 232: //nolint:mnd // start of the grayscale ramp
+		i -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+
+		return [3]int{levels[i/36], levels[i/6%6], levels[i%6]}
+	default:
+		v := 8 + (i-232)*10 //nolint:mnd // grayscale ramp
+
+		return [3]int{v, v, v}
+	}
+}
+
+// colorDistance returns the squared distance between the two RGB values.
+func colorDistance(a, b [3]int) int {
+	d := 0
+
+	for i := range 3 {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+
+	return d
+}
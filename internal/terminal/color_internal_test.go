@@ -0,0 +1,77 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import "testing"
+
+func TestColorSGR(t *testing.T) {
+	t.Parallel()
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		in    Color
+		depth colorDepth
+		want  string
+	}{
+		{"", depthTrueColor, ""},
+		{"none", depth16, ""},
+		{"red", depth16, "31"},
+		{"bright-blue", depth16, "94"},
+		{"9", depth16, "91"},
+		{"208", depth256, "38;5;208"},
+		{"208", depth16, "33"},
+		{"#ff8700", depthTrueColor, "38;2;255;135;0"},
+		{"#ff8700", depth256, "38;5;208"},
+		{"#ff8700", depth16, "33"},
+		{"#000000", depth16, "30"},
+	} {
+		got := test.in.sgr(test.depth)
+		if got != test.want {
+			t.Errorf("Color(%q).sgr(%d) = %q, want %q", test.in, test.depth, got, test.want)
+		}
+	}
+}
+
+func TestColorSet(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		in      string
+		want    Color
+		wantErr bool
+	}{
+		{"Red", "red", false},
+		{" bright-cyan ", "bright-cyan", false},
+		{"255", "255", false},
+		{"#FF8700", "#ff8700", false},
+		{"", "", false},
+		{"orange", "", true},
+		{"256", "", true},
+		{"#ff87", "", true},
+		{"#gg8700", "", true},
+		{"bright-", "", true},
+	} {
+		var c Color
+
+		err := c.Set(test.in)
+		if (err != nil) != test.wantErr {
+			t.Fatalf("Set(%q) error = %v, wantErr %v", test.in, err, test.wantErr)
+		}
+
+		if err == nil && c != test.want {
+			t.Errorf("Set(%q) = %q, want %q", test.in, c, test.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kinds of the lines in a unified diff.
+const (
+	diffContext diffKind = iota // unchanged line or other text
+	diffHeader                  // "---" or "+++" line of a file header
+	diffHunk                    // hunk header
+	diffAdded                   // added line
+	diffRemoved                 // removed line
+)
+
+// A diffKind is the kind of a line in a unified diff.
+type diffKind int
+
+// PrintDiff writes the given unified diff to standard output buffer of s. If
+// colors are enabled, the added lines are printed in green, the removed lines
+// in red, and the hunk headers in cyan. It stores possible errors within s.
+func (s *Terminal) PrintDiff(d string) {
+	if s.quiet || d == "" {
+		return
+	}
+
+	var sb strings.Builder
+
+	lines := strings.Split(strings.TrimSuffix(d, "\n"), "\n")
+
+	for i, kind := range diffKinds(lines) {
+		switch kind {
+		case diffHeader:
+			sb.WriteString(s.colorln(white, lines[i]))
+		case diffHunk:
+			sb.WriteString(s.colorln(cyan, lines[i]))
+		case diffAdded:
+			sb.WriteString(s.colorln(green, lines[i]))
+		case diffRemoved:
+			sb.WriteString(s.colorln(red, lines[i]))
+		case diffContext:
+			sb.WriteString(lines[i] + "\n")
+		}
+	}
+
+	s.outCh <- message{
+		msg:  sb.String(),
+		mode: Buffered,
+	}
+}
+
+// diffKinds returns the kinds of the given lines of a unified diff. The "---"
+// and "+++" lines are file headers only outside of the hunks, so the removed
+// and added lines that start with "--" or "++" are not mistaken for them.
+// The end of a hunk is found from the line counts in its header.
+func diffKinds(lines []string) []diffKind {
+	kinds := make([]diffKind, len(lines))
+
+	// Number of the old and the new lines left in the current hunk.
+	var oldLeft, newLeft int
+
+	for i, line := range lines {
+		switch {
+		case oldLeft > 0 || newLeft > 0:
+			switch {
+			case strings.HasPrefix(line, "+"):
+				kinds[i] = diffAdded
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				kinds[i] = diffRemoved
+				oldLeft--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file" is not counted.
+				kinds[i] = diffContext
+			default:
+				kinds[i] = diffContext
+				oldLeft--
+				newLeft--
+			}
+		case strings.HasPrefix(line, "@@"):
+			kinds[i] = diffHunk
+			oldLeft, newLeft = hunkSizes(line)
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			kinds[i] = diffHeader
+		case strings.HasPrefix(line, "+"):
+			kinds[i] = diffAdded
+		case strings.HasPrefix(line, "-"):
+			kinds[i] = diffRemoved
+		default:
+			kinds[i] = diffContext
+		}
+	}
+
+	return kinds
+}
+
+// hunkSizes returns the numbers of the old and the new lines in the hunk with
+// the given header, for example "@@ -1,3 +1,4 @@". A missing number means one
+// line. If the header is invalid, hunkSizes returns zeros.
+func hunkSizes(header string) (int, int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0
+	}
+
+	return rangeSize(fields[1][1:]), rangeSize(fields[2][1:])
+}
+
+// rangeSize returns the number of lines in the range "start,count" of a hunk
+// header. The count is one if it is omitted.
+func rangeSize(r string) int {
+	_, count, ok := strings.Cut(r, ",")
+	if !ok {
+		return 1
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// PrintDiff writes the given unified diff to standard output buffer of
+// [Default]. If colors are enabled, the added lines are printed in green,
+// the removed lines in red, and the hunk headers in cyan. It stores possible
+// errors within [Default].
+func PrintDiff(d string) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	terminal.PrintDiff(d)
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffKinds(t *testing.T) {
+	t.Parallel()
+
+	const (
+		c = diffContext
+		h = diffHeader
+		k = diffHunk
+		a = diffAdded
+		r = diffRemoved
+	)
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		name string
+		diff string
+		want []diffKind
+	}{
+		{
+			"Simple",
+			"--- a/file\n+++ b/file\n@@ -1,2 +1,2 @@\n ctx\n-old\n+new\n",
+			[]diffKind{h, h, k, c, r, a},
+		},
+		{
+			"DashesInHunk",
+			"--- a/file\n+++ b/file\n@@ -1,2 +1,2 @@\n--- removed\n+++ added\n-- x\n++ y\n",
+			[]diffKind{h, h, k, r, a, r, a},
+		},
+		{
+			"MultipleFiles",
+			"--- a/one\n+++ b/one\n@@ -1 +1 @@\n---\n+++\n--- a/two\n+++ b/two\n@@ -0,0 +1 @@\n+new\n",
+			[]diffKind{h, h, k, r, a, h, h, k, a},
+		},
+		{
+			"NoNewlineMarker",
+			"--- a/file\n+++ b/file\n@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new\n",
+			[]diffKind{h, h, k, r, c, a},
+		},
+		{
+			"MultipleHunks",
+			"--- a/file\n+++ b/file\n@@ -1,1 +1,1 @@\n-a\n+b\n@@ -10,2 +10,1 @@\n ctx\n---\n",
+			[]diffKind{h, h, k, r, a, k, c, r},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := diffKinds(strings.Split(strings.TrimSuffix(test.diff, "\n"), "\n"))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("diffKinds(%q) = %v, want %v", test.diff, got, test.want)
+			}
+		})
+	}
+}
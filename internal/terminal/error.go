@@ -16,9 +16,29 @@ package terminal
 
 import (
 	"errors"
+	"strings"
 	"sync"
+
+	"github.com/reginald-project/reginald/internal/errhint"
+	"github.com/reginald-project/reginald/internal/text"
+)
+
+// Prefixes of the lines of the printed errors.
+const (
+	errorPrefix = "Error:"
+	hintPrefix  = "hint:"
 )
 
+// minErrorWidth is the minimum width that the error messages are wrapped to
+// regardless of the indentation.
+const minErrorWidth = 40
+
+// An errorLine is a line in the printed error chain before wrapping.
+type errorLine struct {
+	msg   string
+	depth int // number of the causes before this line
+}
+
 // An asyncError is the error type for [Terminal]. It stores the asynchronous
 // that happen during the Terminal's execution in a stack. asyncError is
 // thread-safe.
@@ -64,3 +84,117 @@ func (e *asyncError) joined() error {
 
 	return errors.Join(e.errs...)
 }
+
+// PrintError prints err to standard error output of s. The message of each
+// error in the chain of the causes of err is printed on its own line indented
+// under the error that wraps it, and the lines are wrapped to the width of
+// the terminal. If an error in the chain has a hint, it is printed after
+// the message. The errors are printed even in quiet mode.
+func (s *Terminal) PrintError(err error) {
+	s.outCh <- message{
+		msg:  s.formatError(err, Width()),
+		mode: Stderr,
+	}
+}
+
+// PrintError prints err to standard error output of [Default]. See
+// [Terminal.PrintError].
+func PrintError(err error) {
+	terminal.PrintError(err)
+}
+
+// formatError formats err for printing with the lines wrapped to width.
+func (s *Terminal) formatError(err error, width int) string {
+	var sb strings.Builder
+
+	for i, line := range errorLines(err, 0) {
+		if i == 0 {
+			writeWrapped(&sb, s.paint(s.theme.Error.sgr(s.colorDepth), errorPrefix)+" ", len(errorPrefix)+1, line.msg, width)
+
+			continue
+		}
+
+		indent := strings.Repeat("  ", max(line.depth, 1))
+		writeWrapped(&sb, indent, len(indent), line.msg, width)
+	}
+
+	if hint := errhint.Hint(err); hint != "" {
+		writeWrapped(&sb, s.paint(s.theme.Hint.sgr(s.colorDepth), hintPrefix)+" ", len(hintPrefix)+1, hint, width)
+	}
+
+	return sb.String()
+}
+
+// errorLines splits err into the lines of the printed error chain. The message
+// of a wrapping error is printed without the message of the error it wraps if
+// it ends with it, as the errors created with [fmt.Errorf] do. Otherwise,
+// the message is printed in full and its causes are not printed. The errors
+// joined with [errors.Join] are printed as separate lines at the same depth.
+func errorLines(err error, depth int) []errorLine {
+	var lines []errorLine
+
+	for err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint // only this error is checked
+			errs := joined.Unwrap()
+			msgs := make([]string, 0, len(errs))
+
+			for _, e := range errs {
+				msgs = append(msgs, e.Error())
+			}
+
+			if err.Error() != strings.Join(msgs, "\n") {
+				return append(lines, errorLine{msg: err.Error(), depth: depth})
+			}
+
+			for _, e := range errs {
+				lines = append(lines, errorLines(e, depth)...)
+			}
+
+			return lines
+		}
+
+		msg := err.Error()
+		next := errors.Unwrap(err)
+
+		if next != nil {
+			cause := next.Error()
+
+			switch trimmed, ok := strings.CutSuffix(msg, ": "+cause); {
+			case ok:
+				msg = trimmed
+			case msg == cause:
+				msg = ""
+			default:
+				return append(lines, errorLine{msg: msg, depth: depth})
+			}
+		}
+
+		if msg != "" {
+			lines = append(lines, errorLine{msg: msg, depth: depth})
+			depth++
+		}
+
+		err = next
+	}
+
+	return lines
+}
+
+// writeWrapped writes msg to sb wrapped to width. The first line is prefixed
+// with prefix that takes n columns, and the rest of the lines are indented to
+// the same column.
+func writeWrapped(sb *strings.Builder, prefix string, n int, msg string, width int) {
+	wrapped := strings.TrimSuffix(text.Wrap(msg, max(width-n, minErrorWidth)), "\n")
+	indent := strings.Repeat(" ", n)
+
+	for i, line := range strings.Split(wrapped, "\n") {
+		if i == 0 {
+			sb.WriteString(prefix)
+		} else if line != "" {
+			sb.WriteString(indent)
+		}
+
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/errhint"
+)
+
+var (
+	errBase  = errors.New("connection refused")
+	errOther = errors.New("plugin not found")
+)
+
+type sameMessageError struct {
+	err error
+}
+
+func (e *sameMessageError) Error() string { return e.err.Error() }
+func (e *sameMessageError) Unwrap() error { return e.err }
+
+func TestFormatError(t *testing.T) {
+	t.Parallel()
+
+	s := &Terminal{theme: DefaultTheme()}
+
+	for _, test := range []struct {
+		name  string
+		err   error
+		width int
+		want  string
+	}{
+		{
+			"Single",
+			errBase,
+			80,
+			"Error: connection refused\n",
+		},
+		{
+			"Chain",
+			fmt.Errorf("running command failed: %w", fmt.Errorf("failed to start: %w", errBase)),
+			80,
+			"Error: running command failed\n  failed to start\n    connection refused\n",
+		},
+		{
+			"Same message wrapper",
+			&sameMessageError{fmt.Errorf("failed to start: %w", errBase)},
+			80,
+			"Error: failed to start\n  connection refused\n",
+		},
+		{
+			"Sentinel prefix",
+			fmt.Errorf("failed to run: %w", fmt.Errorf("%w: example", errOther)),
+			80,
+			"Error: failed to run\n  plugin not found: example\n",
+		},
+		{
+			"Joined",
+			fmt.Errorf("failed to run: %w", errors.Join(errBase, errOther)),
+			80,
+			"Error: failed to run\n  connection refused\n  plugin not found\n",
+		},
+		{
+			"Hint",
+			fmt.Errorf("failed to run: %w", errhint.Wrap(errBase, "start the server")),
+			80,
+			"Error: failed to run\n  connection refused\nhint: start the server\n",
+		},
+		{
+			"Wrapped",
+			errors.New("the quick brown fox jumps over the lazy dog and keeps running through the forest"),
+			50,
+			"Error: the quick brown fox jumps over the lazy dog\n       and keeps running through the forest\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := s.formatError(test.err, test.width); got != test.want {
+				t.Errorf("formatError() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// defaultPager is the pager that is used if the "PAGER" environment variable
+// is not set.
+const defaultPager = "less"
+
+// Default options for the pagers that are set if the user has not set them.
+// The options for less make it exit if the output fits on one screen, pass
+// the color codes through, and not clear the screen on exit. The options for
+// lv make it pass the color codes through.
+const (
+	defaultLess = "FRX"
+	defaultLv   = "-c"
+)
+
+// A Pager pipes the standard output of a [Terminal] to a pager program, like
+// less, until it is closed. A nil Pager is valid and does nothing.
+type Pager struct {
+	s   *Terminal
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out io.Writer // the output of the terminal before paging
+}
+
+// pagerWriter writes to the standard input of the pager. The write errors are
+// ignored as they occur only when the user quits the pager before all of
+// the output is written, and the rest of the output can be discarded then.
+type pagerWriter struct {
+	w io.Writer
+}
+
+// swapRequest is the type for the requests to replace the standard output of
+// the Terminal. The previous output is sent to the channel.
+type swapRequest struct {
+	out  io.Writer
+	prev chan io.Writer
+}
+
+// SetPager sets whether s pages the long output, like the help messages, when
+// the standard output is a terminal. Paging is disabled by default.
+func (s *Terminal) SetPager(enabled bool) {
+	s.pager = enabled
+}
+
+// Page starts the pager program and directs the standard output of s to it
+// until the returned Pager is closed. The pager is read from the "PAGER"
+// environment variable and it defaults to less. Page returns nil and
+// the output is written directly if paging is disabled, the program is in
+// quiet mode, the standard output is not a terminal, or the pager cannot be
+// started.
+func (s *Terminal) Page(ctx context.Context) *Pager {
+	if !s.pager || s.quiet || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+
+	args := pagerCommand(os.LookupEnv)
+	if len(args) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // the pager is chosen by the user
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = pagerEnv(os.Environ())
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		slog.WarnContext(ctx, "failed to create pipe for pager", "pager", args[0], "err", err)
+
+		return nil
+	}
+
+	if err = cmd.Start(); err != nil {
+		slog.WarnContext(ctx, "failed to start pager", "pager", args[0], "err", err)
+
+		return nil
+	}
+
+	return &Pager{
+		s:   s,
+		cmd: cmd,
+		in:  in,
+		out: s.swapOut(&pagerWriter{w: in}),
+	}
+}
+
+// Page starts the pager for [Default]. See [Terminal.Page].
+func Page(ctx context.Context) *Pager {
+	return terminal.Page(ctx)
+}
+
+// Close writes the rest of the output to the pager, restores the standard
+// output of the terminal, and waits for the user to quit the pager.
+func (p *Pager) Close() error {
+	if p == nil {
+		return nil
+	}
+
+	p.s.swapOut(p.out)
+
+	if err := p.in.Close(); err != nil {
+		return fmt.Errorf("failed to close pager input: %w", err)
+	}
+
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("pager failed: %w", err)
+	}
+
+	return nil
+}
+
+// Write writes p to the pager. It implements [io.Writer].
+func (w *pagerWriter) Write(p []byte) (int, error) { //nolint:unparam // implements interface
+	_, _ = w.w.Write(p)
+
+	return len(p), nil
+}
+
+// swapOut flushes the buffered output and replaces the standard output of s
+// with out. It returns the previous output.
+func (s *Terminal) swapOut(out io.Writer) io.Writer {
+	prev := make(chan io.Writer)
+	s.swapCh <- swapRequest{out: out, prev: prev}
+
+	return <-prev
+}
+
+// pagerCommand returns the command and the arguments for running the pager
+// using the given function for looking up the environment variables. It
+// returns nil if paging is turned off by setting "PAGER" to an empty value or
+// to "cat".
+func pagerCommand(lookupEnv func(string) (string, bool)) []string {
+	pager, ok := lookupEnv("PAGER")
+	if !ok {
+		pager = defaultPager
+	}
+
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil
+	}
+
+	return args
+}
+
+// pagerEnv returns the environment for the pager process with the default
+// options for less and lv added unless environ already sets them.
+func pagerEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)+2) //nolint:mnd // number of the default options
+	env = append(env, environ...)
+
+	for _, kv := range []string{"LESS=" + defaultLess, "LV=" + defaultLv} {
+		name, _, _ := strings.Cut(kv, "=")
+		if !hasEnv(environ, name) {
+			env = append(env, kv)
+		}
+	}
+
+	return env
+}
+
+// hasEnv reports whether environ contains the variable with the given name.
+func hasEnv(environ []string, name string) bool {
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
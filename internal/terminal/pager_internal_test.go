@@ -0,0 +1,63 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPagerCommand(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{}, []string{"less"}},
+		{map[string]string{"PAGER": "more"}, []string{"more"}},
+		{map[string]string{"PAGER": "less -S"}, []string{"less", "-S"}},
+		{map[string]string{"PAGER": ""}, nil},
+		{map[string]string{"PAGER": "  "}, nil},
+		{map[string]string{"PAGER": "cat"}, nil},
+	} {
+		lookupEnv := func(key string) (string, bool) {
+			v, ok := test.env[key]
+
+			return v, ok
+		}
+
+		if got := pagerCommand(lookupEnv); !slices.Equal(got, test.want) {
+			t.Errorf("pagerCommand(%v) = %q, want %q", test.env, got, test.want)
+		}
+	}
+}
+
+func TestPagerEnv(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"HOME=/home/user"}, []string{"HOME=/home/user", "LESS=FRX", "LV=-c"}},
+		{[]string{"LESS=R"}, []string{"LESS=R", "LV=-c"}},
+		{[]string{"LESSOPEN=x", "LV="}, []string{"LESSOPEN=x", "LV=", "LESS=FRX"}},
+	} {
+		if got := pagerEnv(test.in); !slices.Equal(got, test.want) {
+			t.Errorf("pagerEnv(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
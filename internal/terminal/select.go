@@ -0,0 +1,392 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"golang.org/x/term"
+)
+
+// Keys that are recognized in the selection menus.
+const (
+	keyUp        = "\x1b[A"
+	keyUpApp     = "\x1bOA"
+	keyDown      = "\x1b[B"
+	keyDownApp   = "\x1bOB"
+	keyEnter     = "\r"
+	keyNewline   = "\n"
+	keySpace     = " "
+	keyInterrupt = "\x03"
+	keyEOF       = "\x04"
+)
+
+// errNoOptions is returned when a selection prompt is requested without any
+// options.
+var errNoOptions = errors.New("no options to select from")
+
+// A menu is the state of a selection menu that is navigated with the arrow
+// keys.
+type menu struct {
+	options []string
+	checked []bool
+	cursor  int
+	multi   bool
+}
+
+// MultiSelect asks the user to select any number of the given options. It
+// returns the indices of the selected options in ascending order.
+//
+// If the standard input and output are terminals and the answers are not
+// injected, the options are shown as a menu that is navigated with the arrow
+// keys and the options are toggled with space. Otherwise, the options are listed with numbers and the user enters
+// the numbers of the selected options.
+func (s *Terminal) MultiSelect(ctx context.Context, prompt string, options []string) ([]int, error) {
+	return s.selectOptions(ctx, prompt, options, true)
+}
+
+// Select asks the user to select one of the given options. It returns the index
+// of the selected option.
+//
+// If the standard input and output are terminals and the answers are not
+// injected, the options are shown as a menu that is navigated with the arrow
+// keys. Otherwise, the options are
+// listed with numbers and the user enters the number of the option.
+func (s *Terminal) Select(ctx context.Context, prompt string, options []string) (int, error) {
+	selected, err := s.selectOptions(ctx, prompt, options, false)
+	if err != nil {
+		return -1, err
+	}
+
+	return selected[0], nil
+}
+
+// MultiSelect asks the user to select any number of the given options using
+// [Default]. It returns the indices of the selected options in ascending order.
+func MultiSelect(ctx context.Context, prompt string, options []string) ([]int, error) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	return terminal.MultiSelect(ctx, prompt, options)
+}
+
+// Select asks the user to select one of the given options using [Default]. It
+// returns the index of the selected option.
+func Select(ctx context.Context, prompt string, options []string) (int, error) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	return terminal.Select(ctx, prompt, options)
+}
+
+// selectOptions runs a selection prompt.
+func (s *Terminal) selectOptions(ctx context.Context, prompt string, options []string, multi bool) ([]int, error) {
+	if len(options) == 0 {
+		return nil, errNoOptions
+	}
+
+	if s.assume != AssumeNone {
+		if multi {
+			return []int{}, nil
+		}
+
+		return []int{0}, nil
+	}
+
+	if s.quiet {
+		return nil, ErrQuietPrompt
+	}
+
+	if s.answers != nil || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return s.selectNumbered(ctx, prompt, options, multi)
+	}
+
+	responseCh := make(chan promptResponse, 1)
+
+	s.promptCh <- promptRequest{
+		prompt:   prompt,
+		response: responseCh,
+		options:  options,
+		multi:    multi,
+		secret:   false,
+	}
+
+	select {
+	case resp, ok := <-responseCh:
+		if !ok {
+			return nil, errNoResponse
+		}
+
+		if resp.err != nil {
+			return nil, resp.err
+		}
+
+		return resp.selected, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", errNoResponse, ctx.Err())
+	}
+}
+
+// selectNumbered runs a selection prompt by listing the options with numbers
+// and asking the user to enter the numbers. It is used when the input or
+// the output is not a terminal.
+func (s *Terminal) selectNumbered(ctx context.Context, prompt string, options []string, multi bool) ([]int, error) {
+	var sb strings.Builder
+
+	sb.WriteString(strings.TrimSpace(prompt) + "\n")
+
+	for i, o := range options {
+		fmt.Fprintf(&sb, "  %d) %s\n", i+1, o)
+	}
+
+	s.Print(sb.String())
+
+	question := fmt.Sprintf("Enter a number [1-%d]: ", len(options))
+	if multi {
+		question = "Enter the numbers separated by spaces or commas: "
+	}
+
+	for {
+		answer, err := s.Ask(ctx, question)
+		if err != nil {
+			return nil, err
+		}
+
+		if selected, ok := parseSelection(answer, options, multi); ok {
+			return selected, nil
+		}
+
+		if multi {
+			s.PrintErrf("Invalid input. Please enter numbers between 1 and %d.\n", len(options))
+		} else {
+			s.PrintErrf("Invalid input. Please enter a number between 1 and %d.\n", len(options))
+		}
+	}
+}
+
+// doSelect runs the selection menu in the IO goroutine and sends the selected
+// options as the response.
+func (s *Terminal) doSelect(p promptRequest) {
+	selected, err := s.runMenu(p)
+
+	p.response <- promptResponse{
+		response: "",
+		err:      err,
+		selected: selected,
+	}
+	close(p.response)
+}
+
+// runMenu shows the selection menu for the prompt request and reads the keys
+// from the input until the user confirms the selection. The terminal is put
+// into raw mode for the duration of the menu.
+func (s *Terminal) runMenu(p promptRequest) ([]int, error) {
+	fd := int(os.Stdin.Fd())
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+	}
+
+	defer func() {
+		if err := term.Restore(fd, state); err != nil {
+			s.appendErr(err)
+		}
+	}()
+
+	m := &menu{
+		options: p.options,
+		checked: make([]bool, len(p.options)),
+		cursor:  0,
+		multi:   p.multi,
+	}
+
+	hint := "(use arrow keys, enter to select)"
+	if m.multi {
+		hint = "(use arrow keys, space to toggle, enter to confirm)"
+	}
+
+	prompt := s.paint(s.theme.Prompt.sgr(s.colorDepth), strings.TrimSpace(p.prompt))
+
+	// Hide the cursor while the menu is shown.
+	s.writeRaw(fmt.Sprintf("%s %s\r\n\x1b[?25l", prompt, hint))
+	defer s.writeRaw("\x1b[?25h")
+
+	s.renderMenu(m, false)
+
+	buf := make([]byte, 16) //nolint:mnd // enough for the escape sequences
+
+	for {
+		n, err := s.in.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+
+		switch string(buf[:n]) {
+		case keyUp, keyUpApp, "k":
+			m.cursor = (m.cursor + len(m.options) - 1) % len(m.options)
+		case keyDown, keyDownApp, "j":
+			m.cursor = (m.cursor + 1) % len(m.options)
+		case keySpace:
+			if m.multi {
+				m.checked[m.cursor] = !m.checked[m.cursor]
+			}
+		case keyEnter, keyNewline:
+			selected := m.selected()
+			s.clearMenu(m)
+
+			names := make([]string, len(selected))
+			for i, j := range selected {
+				names[i] = m.options[j]
+			}
+
+			s.writeRaw(fmt.Sprintf("%s %s\r\n", prompt, strings.Join(names, ", ")))
+
+			return selected, nil
+		case keyInterrupt:
+			s.writeRaw("\r\n")
+
+			return nil, readline.ErrInterrupt
+		case keyEOF:
+			s.writeRaw("\r\n")
+
+			return nil, io.EOF
+		default:
+			continue
+		}
+
+		s.renderMenu(m, true)
+	}
+}
+
+// renderMenu writes the options of the menu. If redraw is true, the cursor is
+// first moved over the previously rendered options so that they are replaced.
+func (s *Terminal) renderMenu(m *menu, redraw bool) {
+	var sb strings.Builder
+
+	if redraw {
+		fmt.Fprintf(&sb, "\x1b[%dA", len(m.options))
+	}
+
+	for i, o := range m.options {
+		pointer := "  "
+		if i == m.cursor {
+			pointer = "> "
+		}
+
+		box := ""
+		if m.multi {
+			box = "[ ] "
+			if m.checked[i] {
+				box = "[x] "
+			}
+		}
+
+		line := pointer + box + o
+		if i == m.cursor {
+			line = s.paint(s.theme.Prompt.sgr(s.colorDepth), line)
+		}
+
+		sb.WriteString("\r\x1b[2K" + line + "\r\n")
+	}
+
+	s.writeRaw(sb.String())
+}
+
+// clearMenu removes the prompt line and the options of the menu from
+// the terminal and leaves the cursor at the start of the prompt line.
+func (s *Terminal) clearMenu(m *menu) {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "\x1b[%dA", len(m.options)+1)
+
+	for range len(m.options) + 1 {
+		sb.WriteString("\r\x1b[2K\n")
+	}
+
+	fmt.Fprintf(&sb, "\x1b[%dA", len(m.options)+1)
+
+	s.writeRaw(sb.String())
+}
+
+// writeRaw writes msg directly to the output of s. It must only be called from
+// the IO goroutine.
+func (s *Terminal) writeRaw(msg string) {
+	if _, err := io.WriteString(s.out, msg); err != nil {
+		s.appendErr(err)
+	}
+}
+
+// selected returns the indices of the selected options of m. In single
+// selection menus, it is the option under the cursor.
+func (m *menu) selected() []int {
+	if !m.multi {
+		return []int{m.cursor}
+	}
+
+	selected := []int{}
+
+	for i, c := range m.checked {
+		if c {
+			selected = append(selected, i)
+		}
+	}
+
+	return selected
+}
+
+// parseSelection parses the answer to a numbered selection prompt. The options
+// may be given by their numbers or their exact names. It reports whether
+// the answer was valid. In single selection, exactly one option must be given.
+func parseSelection(answer string, options []string, multi bool) ([]int, bool) {
+	fields := strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+
+	if !multi && len(fields) != 1 {
+		return nil, false
+	}
+
+	selected := []int{}
+
+	for _, f := range fields {
+		i := slices.Index(options, f)
+
+		if i == -1 {
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 1 || n > len(options) {
+				return nil, false
+			}
+
+			i = n - 1
+		}
+
+		if !slices.Contains(selected, i) {
+			selected = append(selected, i)
+		}
+	}
+
+	slices.Sort(selected)
+
+	return selected, true
+}
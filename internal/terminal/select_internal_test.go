@@ -0,0 +1,56 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	t.Parallel()
+
+	options := []string{"default", "work", "home"}
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		answer string
+		multi  bool
+		want   []int
+		wantOK bool
+	}{
+		{"2", false, []int{1}, true},
+		{" 3 ", false, []int{2}, true},
+		{"work", false, []int{1}, true},
+		{"", false, nil, false},
+		{"0", false, nil, false},
+		{"4", false, nil, false},
+		{"1 2", false, nil, false},
+		{"other", false, nil, false},
+		{"3, 1", true, []int{0, 2}, true},
+		{"1,1 home", true, []int{0, 2}, true},
+		{"", true, []int{}, true},
+		{"1 x", true, nil, false},
+	} {
+		got, ok := parseSelection(test.answer, options, test.multi)
+		if ok != test.wantOK {
+			t.Fatalf("parseSelection(%q, multi=%v) ok = %v, want %v", test.answer, test.multi, ok, test.wantOK)
+		}
+
+		if ok && !slices.Equal(got, test.want) {
+			t.Errorf("parseSelection(%q, multi=%v) = %v, want %v", test.answer, test.multi, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxStreamLines is the maximum number of lines a [Stream] keeps for printing
+// them if the task fails. The earlier lines are dropped.
+const maxStreamLines = 1000
+
+// A Stream multiplexes the output of one task, like the output of the external
+// commands that the task runs, to a [Terminal]. The output is written line by
+// line with the prefix of the stream so that the output of concurrently
+// running tasks is not mixed within a line.
+//
+// In verbose mode, the lines are printed as they are written. Otherwise, they
+// are collapsed: the stream keeps the last lines and prints them only if
+// the task fails.
+type Stream struct {
+	s       *Terminal
+	prefix  string
+	partial []byte   // incomplete last line
+	lines   []string // collapsed lines
+	dropped int      // number of collapsed lines dropped due to the limit
+	mu      sync.Mutex
+}
+
+// NewStream returns a new Stream that writes to s with the given prefix.
+func NewStream(s *Terminal, prefix string) *Stream {
+	if s == nil {
+		panic("attempt to create Stream with nil Terminal")
+	}
+
+	return &Stream{
+		s:       s,
+		prefix:  prefix,
+		partial: nil,
+		lines:   nil,
+		dropped: 0,
+		mu:      sync.Mutex{},
+	}
+}
+
+// Close ends the stream. If the task failed and its output was collapsed,
+// the collected lines are printed to standard error output so that the user
+// can see why the task failed. Otherwise, the collapsed lines are discarded.
+func (o *Stream) Close(failed bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.partial) > 0 {
+		o.writeLine(string(o.partial))
+		o.partial = nil
+	}
+
+	if failed && (o.dropped > 0 || len(o.lines) > 0) {
+		var sb strings.Builder
+
+		if o.dropped > 0 {
+			fmt.Fprintf(&sb, "[%s] ... %d earlier lines omitted\n", o.prefix, o.dropped)
+		}
+
+		for _, line := range o.lines {
+			fmt.Fprintf(&sb, "[%s] %s\n", o.prefix, line)
+		}
+
+		o.s.outCh <- message{msg: sb.String(), mode: Stderr}
+	}
+
+	o.lines = nil
+	o.dropped = 0
+}
+
+// Write writes the complete lines in p to the stream. An incomplete last line
+// is kept until the rest of it is written or the stream is closed. It
+// implements [io.Writer].
+func (o *Stream) Write(p []byte) (int, error) { //nolint:unparam // implements interface
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data := append(o.partial, p...) //nolint:gocritic // the partial line is replaced
+
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			break
+		}
+
+		o.writeLine(string(bytes.TrimSuffix(data[:i], []byte{'\r'})))
+		data = data[i+1:]
+	}
+
+	o.partial = bytes.Clone(data)
+
+	return len(p), nil
+}
+
+// WriteLine writes one line to the stream. The line must not contain
+// the trailing newline.
+func (o *Stream) WriteLine(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.writeLine(line)
+}
+
+// writeLine prints or collapses the given line. The caller must hold the lock.
+func (o *Stream) writeLine(line string) {
+	if o.s.verbose && !o.s.quiet {
+		o.s.outCh <- message{msg: fmt.Sprintf("[%s] %s\n", o.prefix, line), mode: Stdout}
+
+		return
+	}
+
+	if len(o.lines) == maxStreamLines {
+		o.lines = o.lines[1:]
+		o.dropped++
+	}
+
+	o.lines = append(o.lines, line)
+}
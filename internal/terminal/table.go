@@ -0,0 +1,204 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Styles of the table rows.
+const (
+	RowPlain RowStyle = iota
+	RowSuccess
+	RowWarning
+	RowError
+)
+
+// Layout of the tables.
+const (
+	// tableGap is the number of spaces between the table columns.
+	tableGap = 2
+
+	// minColumnWidth is the width that the columns are not shrunk below when
+	// the table does not fit in the terminal.
+	minColumnWidth = 6
+
+	// ellipsis is appended to the cells that are truncated.
+	ellipsis = "…"
+)
+
+// RowStyle is the style of a row in a table printed with [PrintTable].
+type RowStyle int
+
+// A TableColumn is a column in a table printed with [PrintTable].
+type TableColumn struct {
+	// Header is the header of the column.
+	Header string
+
+	// AlignRight tells whether the cells in the column are aligned to
+	// the right instead of the left.
+	AlignRight bool
+}
+
+// A TableRow is a row in a table printed with [PrintTable].
+type TableRow struct {
+	// Cells are the cells of the row in the order of the columns.
+	Cells []string
+
+	// Style is the style of the row. If colors are enabled, the rows are
+	// colored by their styles.
+	Style RowStyle
+}
+
+// PrintTable writes a table with the given columns and rows to standard output
+// buffer of s. The columns are aligned, and the table is fitted to the width of
+// the terminal by truncating the widest columns. If colors are enabled,
+// the rows are colored by their styles. It stores possible errors within s.
+func (s *Terminal) PrintTable(cols []TableColumn, rows []TableRow) {
+	if s.quiet || len(rows) == 0 {
+		return
+	}
+
+	lines := layoutTable(cols, rows, Width())
+
+	var sb strings.Builder
+
+	sb.WriteString(lines[0] + "\n")
+
+	for i, row := range rows {
+		line := lines[i+1]
+
+		switch row.Style {
+		case RowPlain:
+			sb.WriteString(line + "\n")
+		case RowSuccess:
+			sb.WriteString(s.colorln(green, line))
+		case RowWarning:
+			sb.WriteString(s.paintln(s.theme.Warning.sgr(s.colorDepth), line))
+		case RowError:
+			sb.WriteString(s.paintln(s.theme.Error.sgr(s.colorDepth), line))
+		default:
+			sb.WriteString(line + "\n")
+		}
+	}
+
+	s.outCh <- message{
+		msg:  sb.String(),
+		mode: Buffered,
+	}
+}
+
+// PrintTable writes a table with the given columns and rows to standard output
+// buffer of [Default]. The columns are aligned, and the table is fitted to
+// the width of the terminal by truncating the widest columns. If colors are
+// enabled, the rows are colored by their styles. It stores possible errors
+// within [Default].
+func PrintTable(cols []TableColumn, rows []TableRow) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	terminal.PrintTable(cols, rows)
+}
+
+// layoutTable formats the header and the rows of the table into aligned lines
+// that fit in the given width if possible. The first line is the header.
+func layoutTable(cols []TableColumn, rows []TableRow, width int) []string {
+	widths := make([]int, len(cols))
+
+	for i, col := range cols {
+		widths[i] = utf8.RuneCountInString(col.Header)
+
+		for _, row := range rows {
+			if i < len(row.Cells) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(row.Cells[i]))
+			}
+		}
+	}
+
+	total := tableGap * (len(cols) - 1)
+	for _, w := range widths {
+		total += w
+	}
+
+	// The widest column is shrunk until the table fits or all of the columns
+	// are at the minimum width.
+	for total > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+
+		widths[widest]--
+		total--
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	header := make([]string, len(cols))
+
+	for i, col := range cols {
+		header[i] = col.Header
+	}
+
+	lines = append(lines, formatRow(cols, widths, header))
+
+	for _, row := range rows {
+		lines = append(lines, formatRow(cols, widths, row.Cells))
+	}
+
+	return lines
+}
+
+// formatRow formats the cells of a single table row into a line using
+// the given column widths.
+func formatRow(cols []TableColumn, widths []int, cells []string) string {
+	var sb strings.Builder
+
+	for i, col := range cols {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+
+		if n := utf8.RuneCountInString(cell); n > widths[i] {
+			cell = string([]rune(cell)[:widths[i]-1]) + ellipsis
+		}
+
+		pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+
+		if i > 0 {
+			sb.WriteString(strings.Repeat(" ", tableGap))
+		}
+
+		switch {
+		case col.AlignRight:
+			sb.WriteString(pad + cell)
+		case i < len(cols)-1:
+			sb.WriteString(cell + pad)
+		default:
+			// No trailing spaces after the last column.
+			sb.WriteString(cell)
+		}
+	}
+
+	return sb.String()
+}
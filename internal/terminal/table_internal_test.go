@@ -0,0 +1,74 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLayoutTable(t *testing.T) {
+	t.Parallel()
+
+	cols := []TableColumn{
+		{Header: "ID", AlignRight: false},
+		{Header: "RESULT", AlignRight: false},
+		{Header: "TIME", AlignRight: true},
+	}
+	rows := []TableRow{
+		{Cells: []string{"link-create-0", "succeeded", "12ms"}, Style: RowSuccess},
+		{Cells: []string{"pkg", "failed", "1.5s"}, Style: RowError},
+		{Cells: []string{"short"}, Style: RowPlain},
+	}
+
+	//nolint:govet // don't care about this in tests
+	for _, test := range []struct {
+		width int
+		want  []string
+	}{
+		{
+			80,
+			[]string{
+				"ID             RESULT     TIME",
+				"link-create-0  succeeded  12ms",
+				"pkg            failed     1.5s",
+				"short                         ",
+			},
+		},
+		{
+			26,
+			[]string{
+				"ID         RESULT     TIME",
+				"link-cre…  succeeded  12ms",
+				"pkg        failed     1.5s",
+				"short                     ",
+			},
+		},
+		{
+			10,
+			[]string{
+				"ID      RESULT  TIME",
+				"link-…  succe…  12ms",
+				"pkg     failed  1.5s",
+				"short               ",
+			},
+		},
+	} {
+		got := layoutTable(cols, rows, test.width)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("layoutTable(width=%d) =\n%q\nwant\n%q", test.width, got, test.want)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -90,16 +91,25 @@ type OutputMode int
 // using this type return an error, it will be stored within the struct.
 type Terminal struct {
 	in            io.ReadCloser
+	answers       *bufio.Reader // injected answers to the prompts, nil if not set
 	out           io.Writer
 	errOut        io.Writer
 	promptCh      chan promptRequest
 	outCh         chan message
 	flushCh       chan chan struct{}
+	swapCh        chan swapRequest
 	err           *asyncError // stores the asynchronous errors
 	quiet         bool
-	verbose       bool //nolint:unused // TODO: Will be used soon.
+	verbose       bool
 	interactive   bool
+	ci            bool
+	hideProgress  bool
+	pager         bool
 	colorsEnabled bool
+	colorDepth    colorDepth
+	theme         Theme
+	assume        Assume
+	onPrompt      func(prompt string, options []string, secret bool)
 	wg            sync.WaitGroup
 }
 
@@ -117,23 +127,34 @@ type message struct {
 type promptRequest struct {
 	response chan promptResponse
 	prompt   string
+	options  []string // options for the selection prompts, nil for text input
+	multi    bool     // whether multiple options can be selected
+	secret   bool     // whether the input is read without echoing it
 }
 
 // A promptResponse is the type for the responses to prompts.
 type promptResponse struct {
 	err      error  // any error that occurred during the prompt
 	response string // the response to the prompt
+	selected []int  // indices of the selected options in the selection prompts
 }
 
 // New returns a new Terminal.
 func New(ctx context.Context) *Terminal {
+	return newTerminal(ctx, readline.NewCancelableStdin(os.Stdin), os.Stdout, os.Stderr)
+}
+
+// newTerminal returns a new Terminal that uses the given input and outputs.
+func newTerminal(ctx context.Context, in io.ReadCloser, out, errOut io.Writer) *Terminal {
 	s := &Terminal{
 		promptCh: make(chan promptRequest),
 		outCh:    make(chan message),
 		flushCh:  make(chan chan struct{}),
-		in:       readline.NewCancelableStdin(os.Stdin),
-		out:      os.Stdout,
-		errOut:   os.Stderr,
+		swapCh:   make(chan swapRequest),
+		in:       in,
+		answers:  nil,
+		out:      out,
+		errOut:   errOut,
 		wg:       sync.WaitGroup{},
 		err: &asyncError{
 			errs: make([]error, 0),
@@ -143,6 +164,9 @@ func New(ctx context.Context) *Terminal {
 		verbose:       false,
 		interactive:   false,
 		colorsEnabled: false,
+		colorDepth:    depth16,
+		theme:         DefaultTheme(),
+		assume:        AssumeNone,
 	}
 
 	s.wg.Add(1)
@@ -154,31 +178,16 @@ func New(ctx context.Context) *Terminal {
 // Ask asks the user for input. It returns the input that the user entered as
 // a string and any errors that occurred during the process.
 func (s *Terminal) Ask(ctx context.Context, prompt string) (string, error) {
-	if s.quiet {
-		return "", ErrQuietPrompt
-	}
-
-	responseCh := make(chan promptResponse, 1)
-
-	s.promptCh <- promptRequest{
-		prompt:   prompt,
-		response: responseCh,
-	}
-
-	select {
-	case resp, ok := <-responseCh:
-		if !ok {
-			return "", errNoResponse
-		}
-
-		if resp.err != nil {
-			return "", resp.err
-		}
+	return s.ask(ctx, prompt, false)
+}
 
-		return resp.response, nil
-	case <-ctx.Done():
-		return "", fmt.Errorf("%w: %w", errNoResponse, ctx.Err())
-	}
+// AskSecret asks the user for secret input, like a password or a token. The
+// input is read with echo disabled if the standard input is a terminal. It is
+// read through the same IO loop as the other prompts, and the terminal never
+// logs or stores it. It returns the input that the user entered as a string and
+// any errors that occurred during the process.
+func (s *Terminal) AskSecret(ctx context.Context, prompt string) (string, error) {
+	return s.ask(ctx, prompt, true)
 }
 
 // Close closes the Terminal. It waits for the output goroutine to finish and
@@ -216,7 +225,11 @@ func (s *Terminal) Confirm(ctx context.Context, prompt string, defaultChoice boo
 // the process. If the program is not interactive, the default value is
 // returned.
 func (s *Terminal) ConfirmE(ctx context.Context, prompt string, defaultChoice bool) (bool, error) {
-	if !s.interactive {
+	if s.assume == AssumeYes {
+		return true, nil
+	}
+
+	if !s.interactive || s.assume == AssumeDefaults {
 		return defaultChoice, nil
 	}
 
@@ -264,29 +277,57 @@ func (s *Terminal) Flush() {
 }
 
 // Init initializes s for by propagating the config values.
-func (s *Terminal) Init(quiet, verbose, interactive bool, colors ColorMode) {
+func (s *Terminal) Init(quiet, verbose, interactive bool, colors ColorMode, theme Theme) {
 	s.quiet = quiet
 	s.verbose = verbose
 	s.interactive = interactive
+	s.colorsEnabled = ColorsEnabled(colors)
+	s.colorDepth = detectColorDepth()
+	s.theme = theme
+}
 
-	switch colors {
-	case ColorAlways:
-		s.colorsEnabled = true
-	case ColorNever:
-		s.colorsEnabled = false
-	case ColorAuto:
-		s.colorsEnabled = term.IsTerminal(int(os.Stdout.Fd()))
-	default:
-		panic(fmt.Sprintf("invalid Terminal color mode: %v", colors))
+// SetProgress sets whether s prints the messages that tell about the progress
+// of the run. The progress messages are printed by default.
+func (s *Terminal) SetProgress(enabled bool) {
+	s.hideProgress = !enabled
+}
+
+// SetCI sets whether s runs in CI mode. Secret input is never asked in CI
+// mode.
+func (s *Terminal) SetCI(enabled bool) {
+	s.ci = enabled
+}
+
+// CanAskSecret reports whether s can ask the user for secret input, like
+// a password, when the run needs it. Unlike [Terminal.Interactive], it does not
+// depend on the interactive mode that is for confirming the steps of the run.
+// The standard input must be a terminal or the answers must be injected, and
+// the run must not be quiet or in CI mode.
+func (s *Terminal) CanAskSecret() bool {
+	if s.quiet || s.ci {
+		return false
 	}
+
+	return s.answers != nil || term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// Interactive reports whether s is in interactive mode and the standard input
+// is a terminal so that the user can actually be prompted. The prompts can also
+// be answered if the answers are injected or assumed.
+func (s *Terminal) Interactive() bool {
+	if !s.interactive || s.quiet {
+		return false
+	}
+
+	return s.answers != nil || s.assume != AssumeNone || term.IsTerminal(int(os.Stdin.Fd()))
 }
 
 // Errorf formats according to a format specifier and writes to standard error
-// output of s. If colors are enabled, the message is printed in red. It stores
-// possible errors within s.
+// output of s. If colors are enabled, the message is printed in the error color
+// of the theme. It stores possible errors within s.
 func (s *Terminal) Errorf(format string, a ...any) {
 	s.outCh <- message{
-		msg:  s.colorf(red, format, a...),
+		msg:  s.paint(s.theme.Error.sgr(s.colorDepth), fmt.Sprintf(format, a...)),
 		mode: Stderr,
 	}
 }
@@ -300,6 +341,16 @@ func (s *Terminal) PrintErrf(format string, a ...any) {
 	}
 }
 
+// PrintPorcelain writes the given fields as one tab-separated line to standard
+// output of s. As the line is meant to be machine-readable, it is written even
+// in quiet mode. It stores possible errors within s.
+func (s *Terminal) PrintPorcelain(fields ...string) {
+	s.outCh <- message{
+		msg:  strings.Join(fields, "\t") + "\n",
+		mode: Stdout,
+	}
+}
+
 // Print formats using the default formats for its operands and writes to
 // standard output buffer of s. Spaces are added between operands when neither
 // is a string. It stores possible errors within s.
@@ -344,18 +395,34 @@ func (s *Terminal) Println(a ...any) {
 // Warnln formats using the default formats for its operands and writes to
 // standard error output of s. Spaces are always added between operands and
 // a newline is appended. If colors are enabled, the message is printed in
-// yellow. It stores possible errors within s.
+// the warning color of the theme. It stores possible errors within s.
 func (s *Terminal) Warnln(a ...any) {
 	if s.quiet {
 		return
 	}
 
 	s.outCh <- message{
-		msg:  s.colorln(yellow, a...),
+		msg:  s.paintln(s.theme.Warning.sgr(s.colorDepth), a...),
 		mode: Stderr,
 	}
 }
 
+// Progressf formats according to a format specifier and writes to standard
+// output of s. It is used for the messages that tell about the progress of
+// the run, and the message is written immediately instead of buffering it. If
+// colors are enabled, the message is printed in the progress color of
+// the theme. It stores possible errors within s.
+func (s *Terminal) Progressf(format string, a ...any) {
+	if s.quiet || s.hideProgress {
+		return
+	}
+
+	s.outCh <- message{
+		msg:  s.paint(s.theme.Progress.sgr(s.colorDepth), fmt.Sprintf(format, a...)),
+		mode: Stdout,
+	}
+}
+
 // Ask asks the user for input. It returns the input that the user entered as
 // a string and any errors that occurred during the process.
 func Ask(ctx context.Context, prompt string) (string, error) {
@@ -366,6 +433,17 @@ func Ask(ctx context.Context, prompt string) (string, error) {
 	return Default().Ask(ctx, prompt)
 }
 
+// AskSecret asks the user for secret input, like a password or a token, using
+// [Default]. The input is read with echo disabled if the standard input is
+// a terminal.
+func AskSecret(ctx context.Context, prompt string) (string, error) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	return Default().AskSecret(ctx, prompt)
+}
+
 // Confirm asks the user for a boolean input. It returns the input that the user
 // entered as a boolean. If the function ecounters an error, it returns false.
 // Errors are stored within the default Terminal. If the program is not value is
@@ -415,6 +493,26 @@ func Flush() {
 	terminal.Flush()
 }
 
+// CanAskSecret reports whether [Default] can ask the user for secret input,
+// like a password, when the run needs it.
+func CanAskSecret() bool {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	return terminal.CanAskSecret()
+}
+
+// Interactive reports whether [Default] is in interactive mode and the standard
+// input is a terminal so that the user can actually be prompted.
+func Interactive() bool {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	return terminal.Interactive()
+}
+
 // PrintErrf formats according to a format specifier and writes to standard
 // error output of [Default]. It stores possible errors within [Default].
 func PrintErrf(format string, a ...any) {
@@ -425,6 +523,17 @@ func PrintErrf(format string, a ...any) {
 	terminal.PrintErrf(format, a...)
 }
 
+// PrintPorcelain writes the given fields as one tab-separated line to standard
+// output of [Default]. As the line is meant to be machine-readable, it is
+// written even in quiet mode. It stores possible errors within [Default].
+func PrintPorcelain(fields ...string) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	terminal.PrintPorcelain(fields...)
+}
+
 // Print formats using the default formats for its operands and writes to
 // standard output buffer of [Default]. Spaces are added between operands when
 // neither is a string. It stores possible errors within [Default].
@@ -457,6 +566,18 @@ func Println(a ...any) {
 	terminal.Println(a...)
 }
 
+// Progressf formats according to a format specifier and writes to standard
+// output of [Default]. It is used for the messages that tell about the progress
+// of the run. If colors are enabled, the message is printed in the progress
+// color of the theme. It stores possible errors within [Default].
+func Progressf(format string, a ...any) {
+	if terminal == nil {
+		panic("tried to call nil Terminal")
+	}
+
+	terminal.Progressf(format, a...)
+}
+
 // Set sets the default Terminal instance.
 func Set(s *Terminal) {
 	terminal = s
@@ -489,25 +610,70 @@ func (s *Terminal) appendErr(err error) {
 	s.err.append(err)
 }
 
-func (s *Terminal) colorf(c code, format string, a ...any) string {
-	msg := fmt.Sprintf(format, a...)
+// ask sends a text prompt to the IO loop and waits for the response.
+func (s *Terminal) ask(ctx context.Context, prompt string, secret bool) (string, error) {
+	if s.assume != AssumeNone {
+		if secret {
+			return "", errAssumedSecret
+		}
 
-	if !s.colorsEnabled {
-		return msg
+		return "", nil
 	}
 
-	return fmt.Sprintf("%c[%dm%s%c[%dm", escape, c, msg, escape, reset)
+	if s.quiet {
+		return "", ErrQuietPrompt
+	}
+
+	responseCh := make(chan promptResponse, 1)
+
+	s.promptCh <- promptRequest{
+		prompt:   prompt,
+		response: responseCh,
+		options:  nil,
+		multi:    false,
+		secret:   secret,
+	}
+
+	select {
+	case resp, ok := <-responseCh:
+		if !ok {
+			return "", errNoResponse
+		}
+
+		if resp.err != nil {
+			return "", resp.err
+		}
+
+		return resp.response, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("%w: %w", errNoResponse, ctx.Err())
+	}
 }
 
 func (s *Terminal) colorln(c code, a ...any) string {
-	if !s.colorsEnabled {
-		return fmt.Sprintln(a...)
+	return s.paintln(strconv.Itoa(int(c)), a...)
+}
+
+// paint wraps msg in the ANSI escape sequences for the given SGR parameters if
+// colors are enabled. A trailing newline is left outside of the color so that
+// the color does not leak to the next line.
+func (s *Terminal) paint(sgr, msg string) string {
+	if !s.colorsEnabled || sgr == "" {
+		return msg
+	}
+
+	msg, nl := strings.CutSuffix(msg, "\n")
+	msg = fmt.Sprintf("%c[%sm%s%c[%dm", escape, sgr, msg, escape, reset)
+
+	if nl {
+		msg += "\n"
 	}
 
-	msg := fmt.Sprintln(a...)
-	msg = strings.TrimSuffix(msg, "\n")
+	return msg
+}
 
-	return fmt.Sprintf("%c[%dm%s%c[%dm\n", escape, c, msg, escape, reset)
+func (s *Terminal) paintln(sgr string, a ...any) string {
+	return s.paint(sgr, fmt.Sprintln(a...))
 }
 
 // doIO is the main loop for the IO, run in its own goroutine.
@@ -563,13 +729,42 @@ func (s *Terminal) doIO(ctx context.Context) {
 		case ack := <-s.flushCh:
 			flush()
 			close(ack)
+		case req := <-s.swapCh:
+			flush()
+
+			req.prev <- s.out
+
+			s.out = req.out
+			buf.Reset(s.out)
 		}
 	}
 }
 
 func (s *Terminal) doPrompt(p promptRequest) {
+	if s.onPrompt != nil {
+		s.onPrompt(p.prompt, p.options, p.secret)
+	}
+
+	if p.options != nil {
+		s.doSelect(p)
+
+		return
+	}
+
+	if s.answers != nil {
+		s.doAnswer(p)
+
+		return
+	}
+
+	if p.secret && term.IsTerminal(int(os.Stdin.Fd())) {
+		s.doSecret(p)
+
+		return
+	}
+
 	rlCfg := &readline.Config{ //nolint:exhaustruct // use default values
-		Prompt:                 p.prompt,
+		Prompt:                 s.paint(s.theme.Prompt.sgr(s.colorDepth), p.prompt),
 		DisableAutoSaveHistory: true,
 		Stdin:                  s.in,
 		Stdout:                 s.out,
@@ -581,6 +776,7 @@ func (s *Terminal) doPrompt(p promptRequest) {
 		p.response <- promptResponse{
 			response: "",
 			err:      err,
+			selected: nil,
 		}
 		close(p.response)
 
@@ -599,6 +795,7 @@ func (s *Terminal) doPrompt(p promptRequest) {
 		p.response <- promptResponse{
 			response: "",
 			err:      err,
+			selected: nil,
 		}
 		close(p.response)
 
@@ -608,7 +805,34 @@ func (s *Terminal) doPrompt(p promptRequest) {
 	p.response <- promptResponse{
 		response: line,
 		err:      nil,
+		selected: nil,
+	}
+}
+
+// doSecret reads secret input from the terminal with echo disabled. The prompt
+// is written to the output and a newline is written after the input as
+// the newline typed by the user is not echoed.
+func (s *Terminal) doSecret(p promptRequest) {
+	if _, err := io.WriteString(s.out, s.paint(s.theme.Prompt.sgr(s.colorDepth), p.prompt)); err != nil {
+		s.appendErr(err)
+	}
+
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+	if _, werr := io.WriteString(s.out, "\n"); werr != nil {
+		s.appendErr(werr)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to read secret input: %w", err)
+	}
+
+	p.response <- promptResponse{
+		response: string(data),
+		err:      err,
+		selected: nil,
 	}
+	close(p.response)
 }
 
 func (s *Terminal) writeOut(msg message, buf *bufio.Writer, flush func()) {
@@ -33,7 +33,7 @@ func Wrap(s string, width int) string {
 				addForSpace = 1
 			}
 
-			if l+len(w)+addForSpace > width {
+			if l > 0 && l+len(w)+addForSpace > width {
 				sb.WriteByte('\n')
 
 				l = 0
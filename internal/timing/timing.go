@@ -0,0 +1,84 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timing collects the durations of the phases of a program run, like
+// parsing the config, discovering the plugins, the plugin handshakes, and
+// the tasks, so that they can be reported to the user.
+package timing
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// mu guards the recorded phases as the phases may end in multiple goroutines.
+var mu sync.Mutex //nolint:gochecknoglobals // used by multiple goroutines
+
+// phases contains the phases recorded during the run.
+var phases []Phase //nolint:gochecknoglobals // collected during the whole run
+
+// A Phase is a timed phase of the program run.
+type Phase struct {
+	// Start is the time when the phase started.
+	Start time.Time
+
+	// Name is the name of the phase.
+	Name string
+
+	// Duration is the time it took to run the phase.
+	Duration time.Duration
+}
+
+// LogValue implements [slog.LogValuer] for Phase.
+func (p Phase) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", p.Name), slog.Duration("duration", p.Duration))
+}
+
+// Phases returns the phases recorded so far in the order they were started.
+func Phases() []Phase {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := slices.Clone(phases)
+
+	slices.SortStableFunc(result, func(a, b Phase) int { return a.Start.Compare(b.Start) })
+
+	return result
+}
+
+// Reset removes all of the recorded phases.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	phases = nil
+}
+
+// Start starts timing the phase with the given name. It returns a function
+// that ends the phase and records it. The returned function must be called
+// only once.
+func Start(name string) func() {
+	start := time.Now()
+
+	return func() {
+		d := time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		phases = append(phases, Phase{Start: start, Name: name, Duration: d})
+	}
+}
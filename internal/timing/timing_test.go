@@ -0,0 +1,46 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing_test
+
+import (
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/timing"
+)
+
+//nolint:paralleltest // uses the global phases
+func TestStart(t *testing.T) {
+	timing.Reset()
+	t.Cleanup(timing.Reset)
+
+	stopOuter := timing.Start("outer")
+	stopInner := timing.Start("inner")
+
+	stopInner()
+	stopOuter()
+
+	phases := timing.Phases()
+	if len(phases) != 2 {
+		t.Fatalf("len(Phases()) = %d, want 2", len(phases))
+	}
+
+	if phases[0].Name != "outer" || phases[1].Name != "inner" {
+		t.Errorf("Phases() = %v, want the phases in the order they were started", phases)
+	}
+
+	if phases[0].Duration < phases[1].Duration {
+		t.Errorf("outer duration %v is less than inner duration %v", phases[0].Duration, phases[1].Duration)
+	}
+}
@@ -21,12 +21,25 @@ import (
 	"fmt"
 	"math"
 	"slices"
+	"strconv"
+	"time"
 
 	"github.com/reginald-project/reginald/internal/fspath"
 )
 
-// ErrConv is returned when a type conversion is invalid.
-var ErrConv = errors.New("cannot convert type")
+// Errors returned by the conversions.
+var (
+	// ErrConv is returned when a type conversion is invalid.
+	ErrConv = errors.New("cannot convert type")
+
+	// ErrRange is returned when a value is out of the range of the target
+	// type. It is always wrapped together with [ErrConv].
+	ErrRange = errors.New("value out of range")
+
+	// ErrNotIntegral is returned when a float value with a fractional part is
+	// converted to an integer. It is always wrapped together with [ErrConv].
+	ErrNotIntegral = errors.New("value is not an integer")
+)
 
 // AnyToBoolSlice converts a variable of type any to []bool if possible.
 func AnyToBoolSlice(a any) ([]bool, error) {
@@ -106,6 +119,75 @@ func ToBoolSlice(a []any) ([]bool, error) {
 	return out, nil
 }
 
+// ToDuration converts a duration string, such as "1m30s", or a number of
+// seconds to [time.Duration].
+func ToDuration(a any) (time.Duration, error) {
+	switch v := a.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrConv, err)
+		}
+
+		return d, nil
+	default:
+		n, err := ToInt(v)
+		if errors.Is(err, ErrRange) || errors.Is(err, ErrNotIntegral) {
+			return 0, err
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("%w: %[2]v (%[2]T) is neither a duration nor a number of seconds", ErrConv, v)
+		}
+
+		if int64(n) > math.MaxInt64/int64(time.Second) || int64(n) < math.MinInt64/int64(time.Second) {
+			return 0, fmt.Errorf("%w: %w: %d seconds", ErrConv, ErrRange, n)
+		}
+
+		return time.Duration(n) * time.Second, nil
+	}
+}
+
+// ToFloat converts any integer, unsigned integer, or float value to float64.
+//
+//nolint:cyclop // need to check all of the types
+func ToFloat(a any) (float64, error) {
+	if a == nil {
+		return 0, fmt.Errorf("%w: nil to float64", ErrConv)
+	}
+
+	switch v := a.(type) { //nolint:varnamelen
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("%w: invalid type %T", ErrConv, v)
+	}
+}
+
 // ToInt converts any integer, unsigned integer, or float value to int safely.
 //
 //nolint:cyclop // need to check all of the types
@@ -124,14 +206,10 @@ func ToInt(a any) (int, error) {
 	case int32:
 		return int(v), nil
 	case int64:
-		if math.MaxInt < v || v < math.MinInt {
-			return 0, fmt.Errorf("%w: %d out of range", ErrConv, v)
-		}
-
-		return int(v), nil
+		return Int64ToInt(v)
 	case uint:
 		if math.MaxInt < uint64(v) {
-			return 0, fmt.Errorf("%w: %d out of range", ErrConv, v)
+			return 0, fmt.Errorf("%w: %w: %d", ErrConv, ErrRange, v)
 		}
 
 		return int(v), nil // #nosec G115 -- bounds checked above
@@ -141,13 +219,13 @@ func ToInt(a any) (int, error) {
 		return int(v), nil
 	case uint32:
 		if math.MaxInt < uint64(v) {
-			return 0, fmt.Errorf("%w: %d out of range", ErrConv, v)
+			return 0, fmt.Errorf("%w: %w: %d", ErrConv, ErrRange, v)
 		}
 
 		return int(v), nil
 	case uint64:
 		if math.MaxInt < v {
-			return 0, fmt.Errorf("%w: %d out of range", ErrConv, v)
+			return 0, fmt.Errorf("%w: %w: %d", ErrConv, ErrRange, v)
 		}
 
 		return int(v), nil // #nosec G115 -- bounds checked above
@@ -160,28 +238,61 @@ func ToInt(a any) (int, error) {
 			return 0, fmt.Errorf("%w: Inf to int", ErrConv)
 		}
 
-		if math.MaxInt < v || v < math.MinInt {
-			return 0, fmt.Errorf("%w: %f out of range", ErrConv, v)
-		}
-
-		return int(v), nil
+		return floatToInt(float64(v))
 	case float64:
-		if math.IsNaN(v) {
-			return 0, fmt.Errorf("%w: NaN to int", ErrConv)
-		}
+		return floatToInt(v)
+	default:
+		return 0, fmt.Errorf("%w: invalid type %T", ErrConv, v)
+	}
+}
 
-		if math.IsInf(v, 0) {
-			return 0, fmt.Errorf("%w: Inf to int", ErrConv)
-		}
+// floatToInt converts the float value f to int. It returns an error if f is
+// not a finite integral value within the range of int.
+func floatToInt(f float64) (int, error) {
+	if math.IsNaN(f) {
+		return 0, fmt.Errorf("%w: NaN to int", ErrConv)
+	}
 
-		if math.MaxInt < v || v < math.MinInt {
-			return 0, fmt.Errorf("%w: %f out of range", ErrConv, v)
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%w: Inf to int", ErrConv)
+	}
+
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("%w: %w: %v", ErrConv, ErrNotIntegral, f)
+	}
+
+	// The float64 value of math.MaxInt is rounded up to 2^63 on 64-bit
+	// platforms, so the upper bound must be exclusive.
+	if f >= -float64(math.MinInt) || f < math.MinInt {
+		return 0, fmt.Errorf("%w: %w: %v", ErrConv, ErrRange, f)
+	}
+
+	return int(f), nil
+}
+
+// Int64ToInt converts the int64 value n to int. It returns an error if n is out
+// of the range of int on the current platform.
+func Int64ToInt(n int64) (int, error) {
+	if math.MaxInt < n || n < math.MinInt {
+		return 0, fmt.Errorf("%w: %w: %d", ErrConv, ErrRange, n)
+	}
+
+	return int(n), nil
+}
+
+// ParseInt parses the base-10 integer in s to int. It returns an error that
+// wraps [ErrRange] if the value is out of the range of int.
+func ParseInt(s string) (int, error) {
+	n, err := strconv.ParseInt(s, 10, strconv.IntSize)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("%w: %w: %s", ErrConv, ErrRange, s)
 		}
 
-		return int(v), nil
-	default:
-		return 0, fmt.Errorf("%w: invalid type %T", ErrConv, v)
+		return 0, fmt.Errorf("%w: %w", ErrConv, err)
 	}
+
+	return int(n), nil
 }
 
 // ToIntMap converts a map with elements of type any to map[string]int.
@@ -216,6 +327,30 @@ func ToIntSlice(a []any) ([]int, error) {
 	return s, nil
 }
 
+// ToStringMap converts a map with elements of type any to map[string]string.
+// It also accepts map[string]string as is.
+func ToStringMap(a any) (map[string]string, error) {
+	switch v := a.(type) {
+	case map[string]string:
+		return v, nil
+	case map[string]any:
+		m := make(map[string]string, len(v))
+
+		for k, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %[2]v (%[2]T) in %[3]q to %[4]T", ErrConv, x, k, s)
+			}
+
+			m[k] = s
+		}
+
+		return m, nil
+	default:
+		return nil, fmt.Errorf("%w: %[2]v (%[2]T) to map[string]string", ErrConv, a)
+	}
+}
+
 // ToPathSlice converts a slice with elements of type any to []fspath.Path.
 func ToPathSlice(a []any) ([]fspath.Path, error) {
 	out := make([]fspath.Path, len(a))
@@ -18,7 +18,9 @@ import (
 	"errors"
 	"maps"
 	"math"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/reginald-project/reginald/internal/typeconv"
 )
@@ -124,10 +126,16 @@ func TestToInt(t *testing.T) {
 		},
 		{
 			name:    "float32",
-			input:   float32(3.7),
+			input:   float32(3),
 			want:    3,
 			wantErr: false,
 		},
+		{
+			name:    "float32 not integral",
+			input:   float32(3.7),
+			want:    0,
+			wantErr: true,
+		},
 		{
 			name:    "float32 NaN",
 			input:   float32(math.NaN()),
@@ -148,16 +156,34 @@ func TestToInt(t *testing.T) {
 		},
 		{
 			name:    "float64",
-			input:   6.9,
+			input:   6.0,
 			want:    6,
 			wantErr: false,
 		},
 		{
 			name:    "float64 negative",
-			input:   -2.9,
+			input:   -2.0,
 			want:    -2,
 			wantErr: false,
 		},
+		{
+			name:    "float64 not integral",
+			input:   6.9,
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "float64 negative not integral",
+			input:   -2.9,
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "float64 max int",
+			input:   float64(math.MaxInt),
+			want:    0,
+			wantErr: true,
+		},
 		{
 			name:    "float64 NaN",
 			input:   math.NaN(),
@@ -252,3 +278,168 @@ func TestToIntMap(t *testing.T) {
 		})
 	}
 }
+
+func TestInt64ToInt(t *testing.T) {
+	t.Parallel()
+
+	if got, err := typeconv.Int64ToInt(-42); err != nil || got != -42 {
+		t.Errorf("Int64ToInt(-42) = %d, %v, want -42, nil", got, err)
+	}
+
+	if strconv.IntSize == 64 {
+		return
+	}
+
+	if _, err := typeconv.Int64ToInt(math.MaxInt64); !errors.Is(err, typeconv.ErrRange) {
+		t.Errorf("Int64ToInt(MaxInt64) error = %v, want ErrRange", err)
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		want    error
+		name    string
+		input   string
+		wantInt int
+	}{
+		{name: "int", input: "-12", wantInt: -12, want: nil},
+		{name: "out of range", input: "99999999999999999999", wantInt: 0, want: typeconv.ErrRange},
+		{name: "not a number", input: "1.5", wantInt: 0, want: typeconv.ErrConv},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := typeconv.ParseInt(tt.input)
+			if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Fatalf("ParseInt(%q) error = %v, want %v", tt.input, err, tt.want)
+			}
+
+			if got != tt.wantInt {
+				t.Errorf("ParseInt(%q) = %d, want %d", tt.input, got, tt.wantInt)
+			}
+		})
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   any
+		name    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "string", input: "1m30s", want: 90 * time.Second, wantErr: false},
+		{name: "int seconds", input: 45, want: 45 * time.Second, wantErr: false},
+		{name: "int64 seconds", input: int64(2), want: 2 * time.Second, wantErr: false},
+		{name: "duration", input: time.Minute, want: time.Minute, wantErr: false},
+		{name: "invalid string", input: "soon", want: 0, wantErr: true},
+		{name: "invalid type", input: true, want: 0, wantErr: true},
+		{name: "nil", input: nil, want: 0, wantErr: true},
+		{name: "float seconds", input: 1.5, want: 0, wantErr: true},
+		{name: "overflow", input: int64(math.MaxInt64 / 1000), want: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := typeconv.ToDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToDuration(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, typeconv.ErrConv) {
+				t.Errorf("ToDuration(%v) error = %v, want ErrConv", tt.input, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ToDuration(%v) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToFloat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   any
+		name    string
+		want    float64
+		wantErr bool
+	}{
+		{name: "float64", input: 1.5, want: 1.5, wantErr: false},
+		{name: "float32", input: float32(0.25), want: 0.25, wantErr: false},
+		{name: "int", input: 3, want: 3, wantErr: false},
+		{name: "uint64", input: uint64(7), want: 7, wantErr: false},
+		{name: "string", input: "1.5", want: 0, wantErr: true},
+		{name: "nil", input: nil, want: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := typeconv.ToFloat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToFloat(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ToFloat(%v) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToStringMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   any
+		want    map[string]string
+		name    string
+		wantErr bool
+	}{
+		{
+			name:    "string map",
+			input:   map[string]string{"A": "a"},
+			want:    map[string]string{"A": "a"},
+			wantErr: false,
+		},
+		{
+			name:    "any map",
+			input:   map[string]any{"A": "a", "B": "b"},
+			want:    map[string]string{"A": "a", "B": "b"},
+			wantErr: false,
+		},
+		{name: "empty", input: map[string]any{}, want: map[string]string{}, wantErr: false},
+		{name: "int value", input: map[string]any{"A": 1}, want: nil, wantErr: true},
+		{name: "not a map", input: "A=a", want: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := typeconv.ToStringMap(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToStringMap(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+
+			if err == nil && !maps.Equal(got, tt.want) {
+				t.Errorf("ToStringMap(%v) = %v, want %v", tt.input, got, tt.want)
+			}
+
+			if err != nil && !errors.Is(err, typeconv.ErrConv) {
+				t.Errorf("ToStringMap(%v) error = %v, want %v", tt.input, err, typeconv.ErrConv)
+			}
+		})
+	}
+}
@@ -105,7 +105,7 @@ func run() int {
 	if err := cli.Execute(ctx); err != nil {
 		var successErr *cli.SuccessError
 		if !errors.As(err, &successErr) {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			terminal.PrintError(err)
 
 			var exitErr *cli.ExitError
 			if errors.As(err, &exitErr) {
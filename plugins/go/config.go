@@ -0,0 +1,378 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// errConfigValue is returned when a config value cannot be read as the type
+// that the plugin requests.
+var errConfigValue = errors.New("invalid config value")
+
+// The value types that the client supports on top of the value types of
+// the SDK.
+const (
+	durationValue  api.ValueType = "duration"
+	enumValue      api.ValueType = "enum"
+	floatValue     api.ValueType = "float"
+	mapValue       api.ValueType = "map"
+	stringMapValue api.ValueType = "stringMap"
+)
+
+// A Config provides typed access to the config values that the client sends
+// to the plugin. The accessor functions return the given default value if
+// the value is not set. If the value has the wrong type or cannot be parsed,
+// the accessor records the error and returns the default value. The recorded
+// errors are returned by Err so the plugin can read all of its values before
+// checking for the errors:
+//
+//	cfg := NewConfig(values)
+//	file := cfg.Path("file", "~/.gitconfig")
+//	force := cfg.Bool("force", false)
+//
+//	if err := cfg.Err(); err != nil {
+//		return err
+//	}
+type Config struct {
+	errs   *[]error
+	values api.KeyValues
+}
+
+// NewConfig returns a new Config for reading the given values.
+func NewConfig(values api.KeyValues) *Config {
+	return &Config{errs: &[]error{}, values: values}
+}
+
+// Bool returns the value of the bool config value key.
+func (c *Config) Bool(key string, def bool) bool {
+	return get(c, key, def, (*api.Value).Bool)
+}
+
+// BoolSlice returns the value of the bool list config value key.
+func (c *Config) BoolSlice(key string, def []bool) []bool {
+	return get(c, key, def, (*api.Value).BoolSlice)
+}
+
+// Duration returns the value of the duration config value key.
+func (c *Config) Duration(key string, def time.Duration) time.Duration {
+	return get(c, key, def, func(v *api.Value) (time.Duration, error) {
+		s, ok := v.Val.(string)
+		if v.Type != durationValue || !ok {
+			return 0, fmt.Errorf("%w: cannot convert %v to time.Duration", api.ErrValueRead, v.Val)
+		}
+
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+
+		return d, nil
+	})
+}
+
+// Float returns the value of the float config value key.
+func (c *Config) Float(key string, def float64) float64 {
+	return get(c, key, def, func(v *api.Value) (float64, error) {
+		x, ok := v.Val.(float64)
+		if v.Type != floatValue || !ok {
+			return 0, fmt.Errorf("%w: cannot convert %v to float64", api.ErrValueRead, v.Val)
+		}
+
+		return x, nil
+	})
+}
+
+// Int returns the value of the int config value key.
+func (c *Config) Int(key string, def int) int {
+	return get(c, key, def, (*api.Value).Int)
+}
+
+// IntSlice returns the value of the int list config value key.
+func (c *Config) IntSlice(key string, def []int) []int {
+	return get(c, key, def, (*api.Value).IntSlice)
+}
+
+// Map returns the value of the map config value key.
+func (c *Config) Map(key string, def map[string]any) map[string]any {
+	return get(c, key, def, func(v *api.Value) (map[string]any, error) {
+		m, ok := v.Val.(map[string]any)
+		if v.Type != mapValue || !ok {
+			return nil, fmt.Errorf("%w: cannot convert %v to map[string]any", api.ErrValueRead, v.Val)
+		}
+
+		return m, nil
+	})
+}
+
+// StringMap returns the value of the string map config value key.
+func (c *Config) StringMap(key string, def map[string]string) map[string]string {
+	return get(c, key, def, func(v *api.Value) (map[string]string, error) {
+		a, ok := v.Val.(map[string]any)
+		if v.Type != stringMapValue || !ok {
+			return nil, fmt.Errorf("%w: cannot convert %v to map[string]string", api.ErrValueRead, v.Val)
+		}
+
+		m := make(map[string]string, len(a))
+
+		for k, x := range a {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: cannot convert %v in %q to string", api.ErrValueRead, x, k)
+			}
+
+			m[k] = s
+		}
+
+		return m, nil
+	})
+}
+
+// String returns the value of the string config value key. Enum values are
+// also accepted as strings.
+func (c *Config) String(key, def string) string {
+	return get(c, key, def, func(v *api.Value) (string, error) {
+		if v.Type == enumValue {
+			v = &api.Value{Val: v.Val, Type: api.StringValue}
+		}
+
+		return v.String()
+	})
+}
+
+// StringSlice returns the value of the string list config value key.
+func (c *Config) StringSlice(key string, def []string) []string {
+	return get(c, key, def, (*api.Value).StringSlice)
+}
+
+// Path returns the value of the path config value key. The environment
+// variables and the user home directory in the path are expanded. Strings are
+// also accepted as paths.
+func (c *Config) Path(key string, def fspath.Path) fspath.Path {
+	s := get(c, key, string(def), func(v *api.Value) (string, error) {
+		if v.Type == api.PathValue {
+			v = &api.Value{Val: v.Val, Type: api.StringValue}
+		}
+
+		return v.String()
+	})
+
+	path, err := fspath.Path(s).Expand()
+	if err != nil {
+		c.record(key, err)
+
+		return def
+	}
+
+	return path
+}
+
+// PathSlice returns the value of the path list config value key.
+// The environment variables and the user home directories in the paths are
+// expanded. String lists are also accepted as path lists.
+func (c *Config) PathSlice(key string, def []fspath.Path) []fspath.Path {
+	ss := get(c, key, nil, func(v *api.Value) ([]string, error) {
+		if v.Type == api.PathListValue {
+			v = &api.Value{Val: v.Val, Type: api.StringListValue}
+		}
+
+		return v.StringSlice()
+	})
+	if ss == nil {
+		return def
+	}
+
+	paths := make([]fspath.Path, 0, len(ss))
+
+	for _, s := range ss {
+		path, err := fspath.Path(s).Expand()
+		if err != nil {
+			c.record(key, err)
+
+			return def
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// Sub returns a Config for the nested config values in key. The returned
+// Config shares the recorded errors with c. If the value is not set,
+// the returned Config has no values.
+func (c *Config) Sub(key string) *Config {
+	return &Config{errs: c.errs, values: get(c, key, nil, configs)}
+}
+
+// Decode decodes the config values into v that must be a pointer to a struct.
+// The fields of the struct are matched to the config keys using the
+// "mapstructure" struct tags and the nested config values are decoded into
+// nested structs. The paths are expanded like in Path. The errors recorded by
+// the accessors are not affected.
+func (c *Config) Decode(v any) error {
+	m, err := toMap(c.values)
+	if err != nil {
+		return err
+	}
+
+	decoderConfig := &mapstructure.DecoderConfig{ //nolint:exhaustruct // use default values
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			expandPathHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+		ErrorUnused:      true,
+		Result:           v,
+		WeaklyTypedInput: false,
+	}
+
+	d, err := mapstructure.NewDecoder(decoderConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create mapstructure decoder: %w", err)
+	}
+
+	if err = d.Decode(m); err != nil {
+		return fmt.Errorf("%w: %w", errConfigValue, err)
+	}
+
+	return nil
+}
+
+// Err returns the errors recorded by the accessors joined together, or nil if
+// all of the values were read successfully.
+func (c *Config) Err() error {
+	return errors.Join(*c.errs...)
+}
+
+// configs returns the nested config values in v. The nested values are
+// decoded from JSON as generic maps by the server so they are decoded again as
+// KeyValues if needed.
+func configs(v *api.Value) (api.KeyValues, error) {
+	if a, ok := v.Val.([]any); ok && v.Type == api.ConfigSliceValue {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal nested config values: %w", err)
+		}
+
+		var values api.KeyValues
+		if err = json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal nested config values: %w", err)
+		}
+
+		return values, nil
+	}
+
+	values, err := v.Configs()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return values, nil
+}
+
+// expandPathHookFunc returns a decode hook that expands the strings that are
+// decoded into paths.
+func expandPathHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeFor[fspath.Path]() {
+			return data, nil
+		}
+
+		s, ok := data.(string)
+		if !ok {
+			return data, nil
+		}
+
+		path, err := fspath.Path(s).Expand()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return path, nil
+	}
+}
+
+// get looks up the config value key from c and converts it using fn. If the
+// value is not set, get returns def. If the conversion fails, the error is
+// recorded to c and def is returned.
+func get[T any](c *Config, key string, def T, fn func(*api.Value) (T, error)) T {
+	kv, ok := c.values.Get(key)
+	if !ok {
+		return def
+	}
+
+	x, err := fn(&kv.Value)
+	if err != nil {
+		c.record(key, err)
+
+		return def
+	}
+
+	return x
+}
+
+// record records the error that occurred when reading the config value key.
+func (c *Config) record(key string, err error) {
+	*c.errs = append(*c.errs, fmt.Errorf("%w: %q: %w", errConfigValue, key, err))
+}
+
+// toMap converts the config values to a map that can be decoded using
+// mapstructure. The nested config values are converted to nested maps.
+func toMap(values api.KeyValues) (map[string]any, error) {
+	m := make(map[string]any, len(values))
+
+	for _, kv := range values {
+		var (
+			x   any
+			err error
+		)
+
+		switch kv.Type { //nolint:exhaustive // other values are used as is
+		case api.ConfigSliceValue:
+			var sub api.KeyValues
+			if sub, err = configs(&kv.Value); err != nil {
+				break
+			}
+
+			if x, err = toMap(sub); err != nil {
+				return nil, err
+			}
+		case api.IntValue:
+			// The integers are decoded from JSON as floats.
+			x, err = kv.Int()
+		case api.IntListValue:
+			x, err = kv.IntSlice()
+		default:
+			x = kv.Val
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errConfigValue, kv.Key, err)
+		}
+
+		m[kv.Key] = x
+	}
+
+	return m, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+	"github.com/reginald-project/reginald/internal/fspath"
+)
+
+// testValues are the config values as they are decoded from the client
+// request.
+const testValues = `[
+	{"key": "force", "type": "bool", "value": true},
+	{"key": "delay", "type": "duration", "value": "1m30s"},
+	{"key": "ratio", "type": "float", "value": 0.5},
+	{"key": "mode", "type": "enum", "value": "safe"},
+	{"key": "jobs", "type": "int", "value": 4},
+	{"key": "name", "type": "string", "value": "reginald"},
+	{"key": "dirs", "type": "pathList", "value": ["/etc", "/tmp/$REGINALD_TEST_DIR"]},
+	{"key": "file", "type": "path", "value": "/tmp/${REGINALD_TEST_DIR}/file"},
+	{"key": "tags", "type": "stringList", "value": ["a", "b"]},
+	{"key": "env", "type": "stringMap", "value": {"EDITOR": "vi"}},
+	{"key": "settings", "type": "map", "value": {"depth": 2, "fast": true}},
+	{"key": "link", "type": "configs", "value": [
+		{"key": "src", "type": "string", "value": "a"},
+		{"key": "depth", "type": "int", "value": 2}
+	]}
+]`
+
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	var values api.KeyValues
+	if err := json.Unmarshal([]byte(testValues), &values); err != nil {
+		t.Fatalf("failed to unmarshal test values: %v", err)
+	}
+
+	return NewConfig(values)
+}
+
+func TestConfig(t *testing.T) {
+	t.Setenv("REGINALD_TEST_DIR", "test")
+
+	tests := []struct {
+		name    string
+		get     func(c *Config) any
+		want    any
+		wantErr bool
+	}{
+		{
+			"bool",
+			func(c *Config) any { return c.Bool("force", false) },
+			true,
+			false,
+		},
+		{
+			"int",
+			func(c *Config) any { return c.Int("jobs", 1) },
+			4,
+			false,
+		},
+		{
+			"string",
+			func(c *Config) any { return c.String("name", "") },
+			"reginald",
+			false,
+		},
+		{
+			"duration",
+			func(c *Config) any { return c.Duration("delay", 0) },
+			90 * time.Second,
+			false,
+		},
+		{
+			"float",
+			func(c *Config) any { return c.Float("ratio", 1) },
+			0.5,
+			false,
+		},
+		{
+			"enum as string",
+			func(c *Config) any { return c.String("mode", "") },
+			"safe",
+			false,
+		},
+		{
+			"string slice",
+			func(c *Config) any { return c.StringSlice("tags", nil) },
+			[]string{"a", "b"},
+			false,
+		},
+		{
+			"map",
+			func(c *Config) any { return c.Map("settings", nil) },
+			map[string]any{"depth": 2.0, "fast": true},
+			false,
+		},
+		{
+			"string map",
+			func(c *Config) any { return c.StringMap("env", nil) },
+			map[string]string{"EDITOR": "vi"},
+			false,
+		},
+		{
+			"path",
+			func(c *Config) any { return c.Path("file", "") },
+			fspath.Path("/tmp/test/file"),
+			false,
+		},
+		{
+			"path slice",
+			func(c *Config) any { return c.PathSlice("dirs", nil) },
+			[]fspath.Path{"/etc", "/tmp/test"},
+			false,
+		},
+		{
+			"string as path",
+			func(c *Config) any { return c.Path("name", "") },
+			fspath.Path("reginald"),
+			false,
+		},
+		{
+			"default",
+			func(c *Config) any { return c.String("missing", "default") },
+			"default",
+			false,
+		},
+		{
+			"wrong type",
+			func(c *Config) any { return c.Int("name", 1) },
+			1,
+			true,
+		},
+		{
+			"sub",
+			func(c *Config) any { return c.Sub("link").Int("depth", 0) },
+			2,
+			false,
+		},
+		{
+			"sub error",
+			func(c *Config) any { return c.Sub("link").Bool("src", false) },
+			false,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig(t)
+
+			got := tt.get(c)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+
+			if err := c.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigDecode(t *testing.T) {
+	t.Setenv("REGINALD_TEST_DIR", "test")
+
+	type link struct {
+		Src   string `mapstructure:"src"`
+		Depth int    `mapstructure:"depth"`
+	}
+
+	type options struct {
+		Env      map[string]string `mapstructure:"env"`
+		Settings map[string]any    `mapstructure:"settings"`
+		Dirs     []fspath.Path     `mapstructure:"dirs"`
+		File     fspath.Path       `mapstructure:"file"`
+		Mode     string            `mapstructure:"mode"`
+		Name     string            `mapstructure:"name"`
+		Tags     []string          `mapstructure:"tags"`
+		Link     link              `mapstructure:"link"`
+		Delay    time.Duration     `mapstructure:"delay"`
+		Ratio    float64           `mapstructure:"ratio"`
+		Jobs     int               `mapstructure:"jobs"`
+		Force    bool              `mapstructure:"force"`
+	}
+
+	var got options
+	if err := newTestConfig(t).Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	want := options{
+		Env:      map[string]string{"EDITOR": "vi"},
+		Settings: map[string]any{"depth": 2.0, "fast": true},
+		Dirs:     []fspath.Path{"/etc", "/tmp/test"},
+		File:     "/tmp/test/file",
+		Mode:     "safe",
+		Name:     "reginald",
+		Tags:     []string{"a", "b"},
+		Link:     link{Src: "a", Depth: 2},
+		Delay:    90 * time.Second,
+		Ratio:    0.5,
+		Jobs:     4,
+		Force:    true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+
+	var unknown struct {
+		Name string `mapstructure:"name"`
+	}
+	if err := newTestConfig(t).Decode(&unknown); err == nil {
+		t.Error("Decode() with unused values should fail")
+	}
+}
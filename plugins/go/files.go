@@ -0,0 +1,73 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/reginald-project/reginald/internal/fsutil"
+)
+
+// backupDirKey is the context key for the snapshot directory of the run that
+// the client sends with the "runTask" method.
+type backupDirKey struct{}
+
+// BackupDir returns the snapshot directory of the run that the task run with
+// ctx should store the backups of the files it replaces or removes in. It
+// returns an empty string if the client did not set the directory.
+func BackupDir(ctx context.Context) string {
+	dir, _ := ctx.Value(backupDirKey{}).(string)
+
+	return dir
+}
+
+// Backup saves a copy of the file, directory, or symbolic link at name to
+// the snapshot directory of the run and records it in the journal of
+// the snapshot so that the user can put it back with "reginald restore".
+// A file is saved only once per run so that the snapshot keeps the original
+// file. Backup returns the path to the copy. If there is no file at name or
+// the client did not set the snapshot directory, Backup does nothing and
+// returns an empty string.
+func Backup(ctx context.Context, name string) (string, error) {
+	dir := BackupDir(ctx)
+	if dir == "" {
+		return "", nil
+	}
+
+	path, err := fsutil.Snapshot(name, dir)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return path, nil
+}
+
+// WriteFile writes data to the named file atomically so that an interrupted
+// write never leaves a partially-written file behind. If the file exists, it
+// is saved with [Backup] before it is replaced and its permission bits are
+// preserved. Otherwise, the file is created with permissions perm.
+func WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error {
+	if _, err := Backup(ctx, name); err != nil {
+		return err
+	}
+
+	if _, err := fsutil.WriteFile(name, data, perm, ""); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
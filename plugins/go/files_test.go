@@ -0,0 +1,89 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reginald-project/reginald/internal/fsutil"
+)
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	backupDir := t.TempDir()
+	ctx := context.WithValue(t.Context(), backupDirKey{}, backupDir)
+	name := filepath.Join(t.TempDir(), "file")
+
+	if err := WriteFile(ctx, name, []byte("first"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if _, err := fsutil.ReadJournal(backupDir); err == nil {
+		t.Error("WriteFile() saved a file that did not exist to the snapshot")
+	}
+
+	if err := WriteFile(ctx, name, []byte("second"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := WriteFile(ctx, name, []byte("third"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "third" {
+		t.Errorf("WriteFile() content = %q, want %q", data, "third")
+	}
+
+	entries, err := fsutil.ReadJournal(backupDir)
+	if err != nil {
+		t.Fatalf("ReadJournal() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("ReadJournal() = %d entries, want 1", len(entries))
+	}
+
+	saved, err := os.ReadFile(filepath.Join(backupDir, entries[0].Backup))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(saved) != "first" {
+		t.Errorf("saved content = %q, want %q", saved, "first")
+	}
+}
+
+func TestBackup_NoBackupDir(t *testing.T) {
+	t.Parallel()
+
+	name := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(name, []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := Backup(t.Context(), name)
+	if err != nil || path != "" {
+		t.Errorf("Backup() = %q, %v, want \"\", nil", path, err)
+	}
+}
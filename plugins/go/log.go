@@ -35,6 +35,14 @@ type RPCHandler struct {
 	opts   RPCHandlerOptions
 }
 
+// logParams are the parameters for the "log" notification. In addition to
+// the parameters defined in the API, they contain the ID of the request that
+// the server was handling when the message was logged.
+type logParams struct {
+	api.LogParams
+	RequestID *api.ID `json:"requestId,omitempty"`
+}
+
 // RPCHandlerOptions are the options for the RPCHandler.
 type RPCHandlerOptions struct {
 	// AddSource causes the handler to compute the source code position
@@ -66,8 +74,10 @@ func (*RPCHandler) Enabled(_ context.Context, _ slog.Level) bool {
 // a "log" notification to the client.
 //
 //nolint:gocritic // implements interface
-func (h *RPCHandler) Handle(_ context.Context, r slog.Record) error {
-	var params api.LogParams
+func (h *RPCHandler) Handle(ctx context.Context, r slog.Record) error {
+	var params logParams
+
+	params.RequestID = requestID(ctx)
 
 	params.Time = r.Time
 	params.Level = r.Level
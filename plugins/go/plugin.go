@@ -22,13 +22,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/reginald-project/reginald-sdk-go/api"
 )
 
 func main() {
-	fmt.Fprintln(os.Stderr, "Hello world")
-
 	opts := &ServerOpts{
 		Name:        "reginald-go",
 		Version:     "0.1.0",
@@ -51,23 +51,29 @@ func main() {
 		},
 	}
 
+	var server *Server
+
 	versionsCmd := &Command{ //nolint:exhaustruct // omit default values
 		Name:  "versions",
 		Usage: "versions [options]",
 		Args:  nil,
-		Run: func(_ api.KeyValues) error {
-			fmt.Fprintln(os.Stderr, "running versions")
-			slog.InfoContext(context.TODO(), "running command", "cmd", "versions")
+		Runner: CommandFunc(func(ctx context.Context, _ api.KeyValues) error {
+			slog.InfoContext(ctx, "running command", "cmd", "versions")
 
-			return nil
-		},
+			return server.Progress(ctx, 0).Report("running versions")
+		}),
 	}
 
-	server := NewServer(opts, versionsCmd)
+	server = NewServer(opts, versionsCmd)
+
+	slog.SetDefault(server.Logger())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := server.RunContext(ctx)
 
-	slog.SetDefault(slog.New(NewRPCHandler(server, nil)))
+	stop()
 
-	if err := server.Run(); err != nil {
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s is going to exit with an error: %v", opts.Name, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,133 @@
+// Copyright 2025 The Reginald Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/reginald-project/reginald-sdk-go/api"
+)
+
+// methodProgress is the notification for reporting the progress of a command
+// or a task to the client.
+const methodProgress = "progress"
+
+// requestIDKey is the context key for the ID of the request that the server is
+// handling.
+type requestIDKey struct{}
+
+// A Progress reports the progress of a command or a task to the client using
+// the "progress" notification. The client shows the messages as the output of
+// the task or as progress messages instead of treating them as warnings like
+// the standard error output. A Progress is safe for concurrent use.
+type Progress struct {
+	ctx     context.Context //nolint:containedctx // the progress belongs to a single request
+	server  *Server
+	mu      sync.Mutex
+	current int
+	total   int
+}
+
+// progressParams are the parameters for the "progress" notification.
+type progressParams struct {
+	Message   string  `json:"message"`
+	RequestID *api.ID `json:"requestId,omitempty"`
+	Current   int     `json:"current,omitempty"`
+	Total     int     `json:"total,omitempty"`
+}
+
+// Logger returns a logger that sends the log records to the client as "log"
+// notifications. The records logged with the context passed to a command or
+// a task are tagged with the ID of the request.
+func (s *Server) Logger() *slog.Logger {
+	return slog.New(NewRPCHandler(s, nil))
+}
+
+// Progress returns a Progress for reporting the progress of the command or
+// the task that is run with ctx. If total is greater than zero, it is
+// the number of the steps that are reported with Step.
+func (s *Server) Progress(ctx context.Context, total int) *Progress {
+	return &Progress{
+		ctx:     ctx,
+		server:  s,
+		mu:      sync.Mutex{},
+		current: 0,
+		total:   total,
+	}
+}
+
+// Report sends a progress message to the client without advancing the step.
+// The arguments are handled in the manner of [fmt.Sprintf].
+func (p *Progress) Report(format string, a ...any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.send(fmt.Sprintf(format, a...))
+}
+
+// Step advances the progress by one step and sends the message for the step to
+// the client. The arguments are handled in the manner of [fmt.Sprintf].
+func (p *Progress) Step(format string, a ...any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current++
+
+	return p.send(fmt.Sprintf(format, a...))
+}
+
+// send sends the progress notification. The caller must hold the lock. Nothing
+// is sent if the client does not support progress notifications.
+func (p *Progress) send(msg string) error {
+	if !p.server.client.Progress {
+		return nil
+	}
+
+	params := progressParams{
+		Message:   msg,
+		RequestID: requestID(p.ctx),
+		Current:   0,
+		Total:     0,
+	}
+
+	if p.total > 0 {
+		params.Current = min(p.current, p.total)
+		params.Total = p.total
+	}
+
+	if err := p.server.notify(methodProgress, params); err != nil {
+		return fmt.Errorf("failed to send progress: %w", err)
+	}
+
+	return nil
+}
+
+// requestID returns the ID of the request that the server is handling with ctx,
+// or nil if ctx does not belong to a request.
+func requestID(ctx context.Context) *api.ID {
+	if ctx == nil {
+		return nil
+	}
+
+	id, ok := ctx.Value(requestIDKey{}).(*api.ID)
+	if !ok {
+		return nil
+	}
+
+	return id
+}
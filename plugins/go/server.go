@@ -17,10 +17,12 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"os"
 	"slices"
 	"strconv"
@@ -30,27 +32,132 @@ import (
 	"github.com/reginald-project/reginald-sdk-go/api"
 )
 
+// Methods that are not defined in the API package.
+const (
+	// methodCancel is the notification that the client sends when it stops
+	// waiting for the response to a request.
+	methodCancel = "cancel"
+
+	// methodPing is the method that the client calls to check that the plugin
+	// still responds.
+	methodPing = "ping"
+
+	// methodValidateTask is the method that the client calls to check
+	// the config of a task before any of the tasks are run.
+	methodValidateTask = "validateTask"
+)
+
+// maxMessageSize is the maximum length of the content of a message that
+// the server reads.
+const maxMessageSize = 16 << 20
+
 // Errors returned by the server functions.
 var (
-	errInvalidLength  = errors.New("number of bytes read does not match")
-	errInvalidParams  = errors.New("invalid params")
-	errUnknownCommand = errors.New("unknown command")
-	errUnknownMethod  = errors.New("unknown method")
-	errZeroLength     = errors.New("Content-Length is zero")
+	errContentLength   = errors.New("bad Content-Length")
+	errContentType     = errors.New("unsupported Content-Type")
+	errDuplicateHeader = errors.New("duplicate header")
+	errInvalidBatch    = errors.New("invalid batch")
+	errInvalidHeader   = errors.New("invalid header")
+	errInvalidLength   = errors.New("number of bytes read does not match")
+	errInvalidParams   = errors.New("invalid params")
+	errMessageTooLarge = errors.New("message too large")
+	errNoContentLength = errors.New("no Content-Length")
+	errUnknownCommand  = errors.New("unknown command")
+	errUnknownHeader   = errors.New("unknown header")
+	errUnknownMethod   = errors.New("unknown method")
+	errUnknownTask     = errors.New("unknown task")
+	errZeroLength      = errors.New("Content-Length is zero")
 )
 
 // A Server is a Reginald plugin server implementation.
 type Server struct {
+	running        map[string]context.CancelFunc
 	jsonRPCVersion string
 	protocol       string
 	ServerOpts
 	commands        []*Command
+	tasks           []*Task
 	mu              sync.Mutex
+	runningMu       sync.Mutex
 	protocolVersion int
+	client          clientCapabilities
 	exit            bool
 	shutdown        bool
 }
 
+// handshakeResult is the result of the "handshake" method with the optional
+// protocol features that the server supports.
+type handshakeResult struct {
+	api.HandshakeResult
+	Capabilities capabilities `json:"capabilities"`
+}
+
+// capabilities are the optional protocol features that the server supports.
+type capabilities struct {
+	// Batch reports that the server accepts batches of requests.
+	Batch bool `json:"batch"`
+
+	// Cancel reports that the server handles the "cancel" notification.
+	Cancel bool `json:"cancel"`
+
+	// Validate reports that the server handles the "validateTask" method.
+	Validate bool `json:"validate"`
+}
+
+// handshakeParams are the params of the "handshake" method with the range of
+// the protocol versions and the optional protocol features that the client
+// supports. If the client does not send the range, only the embedded protocol
+// version is accepted.
+type handshakeParams struct {
+	api.HandshakeParams
+	MinProtocolVersion *int               `json:"minProtocolVersion"`
+	MaxProtocolVersion *int               `json:"maxProtocolVersion"`
+	Capabilities       clientCapabilities `json:"capabilities"`
+}
+
+// clientCapabilities are the optional protocol features that the client
+// supports. The server uses the features only if the client supports them.
+type clientCapabilities struct {
+	Progress bool `json:"progress"`
+	Prompt   bool `json:"prompt"`
+	Cancel   bool `json:"cancel"`
+}
+
+// cancelParams are the parameters of the "cancel" notification.
+type cancelParams struct {
+	ID api.ID `json:"id"`
+}
+
+// runTaskParams are the parameters of the "runTask" method. In addition to
+// the parameters defined in the API, the client sends the options of the run
+// and the facts about the system.
+type runTaskParams struct {
+	api.RunTaskParams
+
+	Facts     map[string]any `json:"facts,omitempty"`
+	BackupDir string         `json:"backupDir,omitempty"`
+	DryRun    bool           `json:"dryRun,omitempty"`
+}
+
+// validateTaskParams are the parameters of the "validateTask" method.
+type validateTaskParams struct {
+	api.RunTaskParams
+
+	Facts map[string]any `json:"facts,omitempty"`
+}
+
+// validateTaskResult is the result of the "validateTask" method.
+type validateTaskResult struct {
+	Errors []string `json:"errors,omitempty"`
+}
+
+// A message is a message read from the client. It contains either a single
+// request or a batch of requests.
+type message struct {
+	reqs  []api.Request
+	batch bool
+}
+
 // ServerOpts are the options for creating a plugin server.
 type ServerOpts struct {
 	Name        string
@@ -62,6 +169,42 @@ type ServerOpts struct {
 	Config      []api.ConfigEntry
 }
 
+// Categories of the errors that the server reports to the client in the data
+// of the error responses. The client uses the category for choosing its exit
+// code.
+const (
+	CategoryConfig      = "config"
+	CategoryUser        = "user"
+	CategoryEnvironment = "environment"
+	CategoryInternal    = "internal"
+)
+
+// An Error is an error that a command or a task returns with the information
+// that the client uses for handling it. The fields are sent to the client in
+// the data of the error response.
+type Error struct {
+	// Err is the underlying error.
+	Err error
+
+	// Category is the category of the error.
+	Category string
+
+	// Hint is the suggestion for fixing the problem that the client shows to
+	// the user.
+	Hint string
+
+	// Retryable tells the client that running the task again may succeed.
+	Retryable bool
+}
+
+// errorData is the data of the error responses of the server.
+type errorData struct {
+	Category  string `json:"category,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+	Detail    string `json:"detail"`
+}
+
 // A Command is a command that can be run by the plugin.
 type Command struct {
 	Name        string
@@ -69,16 +212,114 @@ type Command struct {
 	Description string
 	Help        string
 	Args        *api.Arguments
-	Run         func(api.KeyValues) error
-	Aliases     []string
-	Config      []api.ConfigEntry
-	Commands    []*Command
+
+	// Runner runs the command. The context passed to the runner is canceled
+	// when the client closes the connection or the plugin is interrupted.
+	Runner CommandRunner
+
+	// Run runs the command without a context. It is used only if Runner is
+	// nil.
+	//
+	// Deprecated: Use Runner, for example, with a CommandFunc.
+	Run func(api.KeyValues) error
+
+	Aliases  []string
+	Config   []api.ConfigEntry
+	Commands []*Command
+}
+
+// A Task is a task type that can be run by the plugin.
+type Task struct {
+	// Runner runs the task. The context passed to the runner is canceled when
+	// the client closes the connection or the plugin is interrupted.
+	Runner TaskRunner
+
+	// Validator checks the config of the task before any of the tasks are
+	// run. It is optional.
+	Validator TaskValidator
+
+	Type        string
+	Description string
+	Provides    string
+	Config      []api.ConfigType
+}
+
+// A CommandRunner runs a plugin command with the config values given by
+// the client.
+type CommandRunner interface {
+	RunCommand(ctx context.Context, cfg api.KeyValues) error
+}
+
+// A TaskRunner runs a task with the config values given by the client.
+type TaskRunner interface {
+	RunTask(ctx context.Context, cfg api.KeyValues) error
+}
+
+// A TaskValidator checks the config values of a task given by the client. It
+// returns nil if the config is valid. If the returned error wraps multiple
+// errors, for example, one created with [errors.Join], each of them is
+// reported to the client as a separate problem.
+type TaskValidator interface {
+	ValidateTask(ctx context.Context, cfg api.KeyValues) error
+}
+
+// The CommandFunc type is an adapter to allow the use of ordinary functions as
+// command runners.
+type CommandFunc func(ctx context.Context, cfg api.KeyValues) error
+
+// The TaskFunc type is an adapter to allow the use of ordinary functions as
+// task runners.
+type TaskFunc func(ctx context.Context, cfg api.KeyValues) error
+
+// The ValidateFunc type is an adapter to allow the use of ordinary functions as
+// task validators.
+type ValidateFunc func(ctx context.Context, cfg api.KeyValues) error
+
+// RunCommand calls f(ctx, cfg).
+func (f CommandFunc) RunCommand(ctx context.Context, cfg api.KeyValues) error {
+	return f(ctx, cfg)
+}
+
+// RunTask calls f(ctx, cfg).
+func (f TaskFunc) RunTask(ctx context.Context, cfg api.KeyValues) error {
+	return f(ctx, cfg)
+}
+
+// ValidateTask calls f(ctx, cfg).
+func (f ValidateFunc) ValidateTask(ctx context.Context, cfg api.KeyValues) error {
+	return f(ctx, cfg)
+}
+
+// WithoutContext returns a CommandRunner that runs a command function that
+// does not take a context. It can be used to adapt the old command functions
+// to the Runner field of Command.
+func WithoutContext(run func(api.KeyValues) error) CommandRunner {
+	return CommandFunc(func(_ context.Context, cfg api.KeyValues) error {
+		return run(cfg)
+	})
+}
+
+// runner returns the runner for the command, or nil if the command is not
+// runnable.
+func (c *Command) runner() CommandRunner {
+	if c.Runner != nil {
+		return c.Runner
+	}
+
+	if c.Run != nil {
+		return WithoutContext(c.Run)
+	}
+
+	return nil
 }
 
 // NewServer creates a new plugin server. A plugin manifest can be generated
 // automatically for plugins that use NewServer to create their plugn server.
 func NewServer(opts *ServerOpts, impls ...any) *Server {
-	var cmds []*Command
+	var (
+		cmds  []*Command
+		tasks []*Task
+	)
 
 	for _, impl := range impls {
 		switch v := impl.(type) {
@@ -86,6 +327,10 @@ func NewServer(opts *ServerOpts, impls ...any) *Server {
 			cmds = append(cmds, v)
 		case Command:
 			cmds = append(cmds, &v)
+		case *Task:
+			tasks = append(tasks, v)
+		case Task:
+			tasks = append(tasks, &v)
 		default:
 			panic(fmt.Sprintf("invalid plugin functionality type: %[1]T (%[1]v)", impl))
 		}
@@ -101,52 +346,105 @@ func NewServer(opts *ServerOpts, impls ...any) *Server {
 
 	return &Server{
 		ServerOpts:      *opts,
+		client:          clientCapabilities{Progress: false, Prompt: false, Cancel: false},
 		commands:        cmds,
+		tasks:           tasks,
 		exit:            false,
 		jsonRPCVersion:  api.JSONRPCVersion,
 		mu:              sync.Mutex{},
 		protocol:        api.Protocol,
 		protocolVersion: api.ProtocolVersion,
+		running:         make(map[string]context.CancelFunc),
+		runningMu:       sync.Mutex{},
 		shutdown:        false,
 	}
 }
 
-// Run starts the plugin server and waits for requests from the client. It reads
-// the requests from the standard input and sends the responses to the standard
-// output. Run exits when the server is shut down first by calling the method
-// "shutdown" and then sending the exit notification. The server reports normal
-// errors by sending them as resposes to the client. If the server encounters
-// some other error, it stops the run and returns the error.
+// Run starts the plugin server and waits for requests from the client. It is
+// equivalent to calling RunContext with [context.Background].
 func (s *Server) Run() error {
-	reader := bufio.NewReader(os.Stdin)
+	return s.RunContext(context.Background())
+}
+
+// RunContext starts the plugin server and waits for requests from the client.
+// It reads the requests from the standard input and sends the responses to
+// the standard output. RunContext exits when the server is shut down first by
+// calling the method "shutdown" and then sending the exit notification.
+// The server reports normal errors by sending them as resposes to the client.
+// If the server encounters some other error, it stops the run and returns
+// the error.
+//
+// Each command and task is run with a context that is derived from ctx and
+// canceled when the method returns. The context is also canceled if ctx is
+// canceled, the client closes the connection, or the client cancels
+// the request while the method is running.
+func (s *Server) RunContext(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgCh := make(chan message)
+	errCh := make(chan error, 1)
+
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+
+		for {
+			msg, err := read(reader)
+			if err != nil {
+				// The client is gone so the running method is canceled.
+				cancel()
+
+				errCh <- err
+
+				return
+			}
+
+			// The cancellations are handled while reading as the requests
+			// they cancel block the handling of the other messages.
+			if !msg.batch && msg.reqs[0].Method == methodCancel && (msg.reqs[0].ID == nil || msg.reqs[0].ID.Null) {
+				_ = s.notification(msg.reqs[0])
+
+				continue
+			}
+
+			select {
+			case msgCh <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	for !s.exit {
-		req, err := read(reader)
-		if err != nil {
+		var msg message
+
+		select {
+		case msg = <-msgCh:
+		case err := <-errCh:
 			return err
+		case <-ctx.Done():
+			return fmt.Errorf("%w", context.Cause(ctx))
 		}
 
-		if s.shutdown && req.Method != api.MethodExit {
-			err = s.respondError(*req.ID, &api.Error{
-				Code:    api.CodeMethodNotFound,
-				Message: "method not found",
-				Data:    fmt.Sprintf("method %q not available when shutting down", req.Method),
-			})
-			if err != nil {
-				return fmt.Errorf("failed to send error response: %w", err)
+		if msg.batch {
+			if err := s.batch(ctx, msg.reqs); err != nil {
+				return err
 			}
+
+			continue
 		}
 
+		req := msg.reqs[0]
+
 		if req.ID != nil && !req.ID.Null {
-			err = s.method(req)
-			if err != nil {
+			if err := s.method(ctx, req); err != nil {
 				return err
 			}
 
 			continue
 		}
 
-		if err = s.notification(req); err != nil {
+		if err := s.notification(req); err != nil {
 			// TODO: Should we send a log notification here?
 			continue
 		}
@@ -155,50 +453,144 @@ func (s *Server) Run() error {
 	return nil
 }
 
+// batch runs the requests in a batch one after another and sends
+// the responses to them as a single batch. The notifications in the batch
+// have no responses, and if the batch contains only notifications, nothing is
+// sent.
+func (s *Server) batch(ctx context.Context, reqs []api.Request) error {
+	responses := make([]api.Response, 0, len(reqs))
+
+	for _, req := range reqs {
+		if req.ID == nil || req.ID.Null {
+			_ = s.notification(req)
+
+			continue
+		}
+
+		res, err := s.response(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		responses = append(responses, res)
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return write(responses)
+}
+
 // method runs the method in the request and sends a response to it. If method
 // returns an error, it means that the function itself has failed and that
 // the server should notify the client about that. The server should not,
 // however, send a response outside of method.
-func (s *Server) method(req api.Request) error {
+func (s *Server) method(ctx context.Context, req api.Request) error {
+	res, err := s.response(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return write(res)
+}
+
+// response runs the method in the request and returns the response to it. If
+// response returns an error, the method itself has failed and no response
+// should be sent.
+func (s *Server) response(ctx context.Context, req api.Request) (api.Response, error) {
 	if req.ID == nil || req.ID.Null {
 		panic(fmt.Sprintf("method runner received request with a nil ID: %+v", req))
 	}
 
-	var methodFunc func(params json.RawMessage) (any, error)
+	if s.shutdown {
+		return s.errorResponse(*req.ID, &api.Error{
+			Code:    api.CodeMethodNotFound,
+			Message: "method not found",
+			Data:    fmt.Sprintf("method %q not available when shutting down", req.Method),
+		}), nil
+	}
+
+	var methodFunc func(ctx context.Context, params json.RawMessage) (any, error)
 
 	switch req.Method {
 	case api.MethodHandshake:
 		methodFunc = s.methodHandshake
 	case api.MethodRunCommand:
 		methodFunc = s.methodRunCommand
+	case api.MethodRunTask:
+		methodFunc = s.methodRunTask
+	case methodPing:
+		methodFunc = s.methodPing
 	case api.MethodShutdown:
 		methodFunc = s.methodShutdown
+	case methodValidateTask:
+		methodFunc = s.methodValidateTask
 	default:
-		err := s.respondError(*req.ID, &api.Error{
+		return s.errorResponse(*req.ID, &api.Error{
 			Code:    api.CodeMethodNotFound,
 			Message: "method not found",
 			Data:    req.Method,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to send error response: %w", err)
-		}
-
-		return nil
+		}), nil
 	}
 
-	result, err := methodFunc(req.Params)
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, requestIDKey{}, req.ID))
+	defer cancel()
+
+	key := idKey(*req.ID)
+
+	s.runningMu.Lock()
+	s.running[key] = cancel
+	s.runningMu.Unlock()
+
+	defer func() {
+		s.runningMu.Lock()
+		delete(s.running, key)
+		s.runningMu.Unlock()
+	}()
+
+	result, err := methodFunc(ctx, req.Params)
 	if err != nil {
 		var rpcErr *api.Error
 		if errors.As(err, &rpcErr) {
-			err = s.respondError(*req.ID, rpcErr)
+			return s.errorResponse(*req.ID, rpcErr), nil
 		}
 
-		return err
+		return api.Response{}, err
 	}
 
-	err = s.respond(*req.ID, result)
+	rawResult, err := json.Marshal(result)
 	if err != nil {
-		return err
+		return api.Response{}, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return api.Response{
+		JSONRPC: s.jsonRPCVersion,
+		ID:      *req.ID,
+		Error:   nil,
+		Result:  rawResult,
+	}, nil
+}
+
+// methodCancel runs the "cancel" notification by canceling the context of
+// the request with the given ID if the request is still running.
+func (s *Server) methodCancel(params json.RawMessage) error {
+	var cancelParams cancelParams
+	if err := json.Unmarshal(params, &cancelParams); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal cancel params: %w", errInvalidParams, err)
+	}
+
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+
+	if cancel, ok := s.running[idKey(cancelParams.ID)]; ok {
+		cancel()
 	}
 
 	return nil
@@ -216,11 +608,11 @@ func (s *Server) methodExit(params json.RawMessage) error {
 }
 
 // methodHandshake runs the "handshake" method.
-func (s *Server) methodHandshake(params json.RawMessage) (any, error) {
+func (s *Server) methodHandshake(_ context.Context, params json.RawMessage) (any, error) {
 	d := json.NewDecoder(bytes.NewReader(params))
 	d.DisallowUnknownFields()
 
-	var handshakeParams api.HandshakeParams
+	var handshakeParams handshakeParams
 	if err := d.Decode(&handshakeParams); err != nil {
 		return nil, &api.Error{
 			Code:    api.CodeInvalidParams,
@@ -237,29 +629,51 @@ func (s *Server) methodHandshake(params json.RawMessage) (any, error) {
 		}
 	}
 
-	if handshakeParams.ProtocolVersion != s.protocolVersion {
+	low, high := handshakeParams.ProtocolVersion, handshakeParams.ProtocolVersion
+
+	if handshakeParams.MinProtocolVersion != nil {
+		low = *handshakeParams.MinProtocolVersion
+	}
+
+	if handshakeParams.MaxProtocolVersion != nil {
+		high = *handshakeParams.MaxProtocolVersion
+	}
+
+	if s.protocolVersion < low || s.protocolVersion > high {
 		return nil, &api.Error{
 			Code:    api.CodeHandshakeError,
 			Message: "handshake error",
 			Data: fmt.Sprintf(
-				"expected protocol to be %d, got %d",
+				"expected protocol version range to include %d, got %d to %d",
 				s.protocolVersion,
-				handshakeParams.ProtocolVersion,
+				low,
+				high,
 			),
 		}
 	}
 
-	return api.HandshakeResult{
-		Name: s.Name,
-		Handshake: api.Handshake{
-			Protocol:        s.protocol,
-			ProtocolVersion: s.protocolVersion,
+	s.client = handshakeParams.Capabilities
+
+	return handshakeResult{
+		HandshakeResult: api.HandshakeResult{
+			Name: s.Name,
+			Handshake: api.Handshake{
+				Protocol:        s.protocol,
+				ProtocolVersion: s.protocolVersion,
+			},
+		},
+		Capabilities: capabilities{
+			Batch:  true,
+			Cancel: true,
+			Validate: slices.ContainsFunc(s.tasks, func(t *Task) bool {
+				return t.Validator != nil
+			}),
 		},
 	}, nil
 }
 
 // methodRunCommand runs the "runCommand" method.
-func (s *Server) methodRunCommand(params json.RawMessage) (any, error) {
+func (s *Server) methodRunCommand(ctx context.Context, params json.RawMessage) (any, error) {
 	d := json.NewDecoder(bytes.NewReader(params))
 	d.DisallowUnknownFields()
 
@@ -306,7 +720,7 @@ func (s *Server) methodRunCommand(params json.RawMessage) (any, error) {
 		return nil, &api.Error{
 			Code:    api.CodeInvalidCommand,
 			Message: "invalid command",
-			Data:    err,
+			Data:    newErrorData(err, CategoryUser),
 		}
 	}
 
@@ -318,7 +732,8 @@ func (s *Server) methodRunCommand(params json.RawMessage) (any, error) {
 		}
 	}
 
-	if cmd.Run == nil {
+	runner := cmd.runner()
+	if runner == nil {
 		return nil, &api.Error{
 			Code:    api.CodeCommandNotRunnable,
 			Message: "command not runnable",
@@ -326,19 +741,63 @@ func (s *Server) methodRunCommand(params json.RawMessage) (any, error) {
 		}
 	}
 
-	if err = cmd.Run(runParams.Config); err != nil {
+	if err = runner.RunCommand(ctx, runParams.Config); err != nil {
 		return nil, &api.Error{
 			Code:    api.CodeCommandError,
 			Message: "command error",
-			Data:    err,
+			Data:    newErrorData(err, ""),
+		}
+	}
+
+	return struct{}{}, nil
+}
+
+// methodRunTask runs the "runTask" method.
+func (s *Server) methodRunTask(ctx context.Context, params json.RawMessage) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(params))
+	d.DisallowUnknownFields()
+
+	var runParams runTaskParams
+	if err := d.Decode(&runParams); err != nil {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidParams,
+			Message: "invalid params",
+			Data:    fmt.Errorf("failed to unmarshal runTask params: %w", err),
+		}
+	}
+
+	i := slices.IndexFunc(s.tasks, func(t *Task) bool {
+		return t.Type == runParams.TaskType
+	})
+	if i == -1 || s.tasks[i].Runner == nil {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidParams,
+			Message: "invalid params",
+			Data:    fmt.Errorf("%w: %s", errUnknownTask, runParams.TaskType),
+		}
+	}
+
+	ctx = context.WithValue(ctx, backupDirKey{}, runParams.BackupDir)
+
+	if err := s.tasks[i].Runner.RunTask(ctx, runParams.Config); err != nil {
+		return nil, &api.Error{
+			Code:    api.CodeCommandError,
+			Message: "task error",
+			Data:    newErrorData(err, ""),
 		}
 	}
 
 	return struct{}{}, nil
 }
 
+// methodPing runs the "ping" method. The client calls it to check that
+// the plugin still responds.
+func (*Server) methodPing(_ context.Context, _ json.RawMessage) (any, error) {
+	return struct{}{}, nil
+}
+
 // methodShutdown runs the "shutdown" method.
-func (s *Server) methodShutdown(params json.RawMessage) (any, error) {
+func (s *Server) methodShutdown(_ context.Context, params json.RawMessage) (any, error) {
 	if !isNull(params) {
 		return nil, &api.Error{
 			Code:    api.CodeInvalidParams,
@@ -352,6 +811,55 @@ func (s *Server) methodShutdown(params json.RawMessage) (any, error) {
 	return true, nil
 }
 
+// methodValidateTask runs the "validateTask" method. The problems that
+// the validator of the task finds are returned in the result. The tasks
+// without a validator are always valid.
+func (s *Server) methodValidateTask(ctx context.Context, params json.RawMessage) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(params))
+	d.DisallowUnknownFields()
+
+	var validateParams validateTaskParams
+	if err := d.Decode(&validateParams); err != nil {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidParams,
+			Message: "invalid params",
+			Data:    fmt.Errorf("failed to unmarshal validateTask params: %w", err),
+		}
+	}
+
+	i := slices.IndexFunc(s.tasks, func(t *Task) bool {
+		return t.Type == validateParams.TaskType
+	})
+	if i == -1 {
+		return nil, &api.Error{
+			Code:    api.CodeInvalidParams,
+			Message: "invalid params",
+			Data:    fmt.Errorf("%w: %s", errUnknownTask, validateParams.TaskType),
+		}
+	}
+
+	if s.tasks[i].Validator == nil {
+		return validateTaskResult{Errors: nil}, nil
+	}
+
+	err := s.tasks[i].Validator.ValidateTask(ctx, validateParams.Config)
+	if err == nil {
+		return validateTaskResult{Errors: nil}, nil
+	}
+
+	var problems []string
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint // only the top-level error is split
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, e.Error())
+		}
+	} else {
+		problems = []string{err.Error()}
+	}
+
+	return validateTaskResult{Errors: problems}, nil
+}
+
 // notification runs the method in the notification request. No response is sent
 // to the client.
 func (s *Server) notification(req api.Request) error {
@@ -360,6 +868,8 @@ func (s *Server) notification(req api.Request) error {
 	switch req.Method {
 	case api.MethodExit:
 		methodFunc = s.methodExit
+	case methodCancel:
+		methodFunc = s.methodCancel
 	default:
 		return fmt.Errorf("%w: notification %q", errUnknownMethod, req.Method)
 	}
@@ -372,29 +882,6 @@ func (s *Server) notification(req api.Request) error {
 	return nil
 }
 
-// respond sends successful response to the client.
-func (s *Server) respond(id api.ID, result any) error {
-	rawResult, err := json.Marshal(result)
-	if err != nil {
-		return fmt.Errorf("failed to marshal result: %w", err)
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	err = write(api.Response{
-		JSONRPC: s.jsonRPCVersion,
-		ID:      id,
-		Error:   nil,
-		Result:  rawResult,
-	})
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // notify sends a notification to the client.
 func (s *Server) notify(method string, params any) error {
 	rawParams, err := json.Marshal(params)
@@ -429,22 +916,28 @@ func (s *Server) notify(method string, params any) error {
 	return nil
 }
 
-// respondError sends an error response to the client.
-func (s *Server) respondError(id api.ID, rpcErr *api.Error) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	err := write(api.Response{
+// errorResponse returns an error response to the request with the given ID.
+func (s *Server) errorResponse(id api.ID, rpcErr *api.Error) api.Response {
+	return api.Response{
 		JSONRPC: s.jsonRPCVersion,
 		ID:      id,
 		Error:   rpcErr,
 		Result:  nil,
-	})
-	if err != nil {
-		return err
 	}
+}
 
-	return nil
+// idKey returns a string for the given request ID that can be used as a map
+// key. The numbers and the strings are prefixed differently so that a number
+// and a string with the same content are different keys.
+func idKey(id api.ID) string {
+	switch {
+	case id.Number != nil:
+		return "n" + id.Number.String()
+	case id.String != nil:
+		return "s" + *id.String
+	default:
+		return ""
+	}
 }
 
 // isNull is a helper function for telling if the given raw JSON message is
@@ -458,56 +951,168 @@ func isNull(p json.RawMessage) bool {
 	return bytes.Equal(bytes.TrimSpace(p), []byte("null"))
 }
 
-// read reads a request sent to the plugin using the given reader.
-func read(r *bufio.Reader) (api.Request, error) {
-	var l int
+// Error returns the value of e as a string.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
 
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil {
-			return api.Request{}, fmt.Errorf("failed to read line: %w", err)
-		}
+// Unwrap returns the error wrapped by e.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
 
-		line = strings.TrimRight(line, "\r\n")
-		if line == "" {
-			break
+// newErrorData returns the data of the error response for err. If err has no
+// [Error] with a category in its tree, the given category is used.
+func newErrorData(err error, category string) errorData {
+	data := errorData{
+		Category:  category,
+		Hint:      "",
+		Retryable: false,
+		Detail:    err.Error(),
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Category != "" {
+			data.Category = e.Category
 		}
 
-		// TODO: Consider disallowing other headers.
-		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
-			v := strings.TrimSpace(line[strings.IndexByte(line, ':')+1:])
+		data.Hint = e.Hint
+		data.Retryable = e.Retryable
+	}
 
-			if l, err = strconv.Atoi(v); err != nil {
-				return api.Request{}, fmt.Errorf("bad Content-Length %q: %w", v, err)
-			}
-		}
+	return data
+}
+
+// read reads a message sent to the plugin using the given reader. The message
+// is either a single request or a batch of requests.
+func read(r *bufio.Reader) (message, error) {
+	l, err := readHeader(r)
+	if err != nil {
+		return message{}, err
 	}
 
-	if l <= 0 {
-		return api.Request{}, fmt.Errorf("bad Content-Length %d: %w", l, errZeroLength)
+	if l == 0 {
+		return message{}, errZeroLength
 	}
 
 	buf := make([]byte, l)
 	if n, err := io.ReadFull(r, buf); err != nil {
-		return api.Request{}, fmt.Errorf("failed to read RPC message: %w", err)
+		return message{}, fmt.Errorf("failed to read RPC message: %w", err)
 	} else if n != l {
-		return api.Request{}, fmt.Errorf("failed to read RPC message: %w, want %d, got %d", errInvalidLength, l, n)
+		return message{}, fmt.Errorf("failed to read RPC message: %w, want %d, got %d", errInvalidLength, l, n)
 	}
 
 	d := json.NewDecoder(bytes.NewReader(buf))
 	d.DisallowUnknownFields()
 
+	if trimmed := bytes.TrimSpace(buf); len(trimmed) > 0 && trimmed[0] == '[' {
+		var reqs []api.Request
+		if err := d.Decode(&reqs); err != nil {
+			return message{}, fmt.Errorf("failed to decode batch from JSON: %w", err)
+		}
+
+		if len(reqs) == 0 {
+			return message{}, fmt.Errorf("%w: empty batch", errInvalidBatch)
+		}
+
+		return message{reqs: reqs, batch: true}, nil
+	}
+
 	var req api.Request
 	if err := d.Decode(&req); err != nil {
-		return api.Request{}, fmt.Errorf("failed to decode message from JSON: %w", err)
+		return message{}, fmt.Errorf("failed to decode message from JSON: %w", err)
 	}
 
-	return req, nil
+	return message{reqs: []api.Request{req}, batch: false}, nil
 }
 
-// write writes a response to the standard output that will be sent to
-// the client.
-func write(res api.Response) error {
+// readHeader reads the header part of a message from r and returns the length
+// of the content part. The header must have exactly one Content-Length field
+// and at most one Content-Type field.
+func readHeader(r *bufio.Reader) (int, error) {
+	var (
+		l           = -1
+		contentType bool
+	)
+
+	for {
+		b, err := r.ReadSlice('\n')
+		if err != nil {
+			return 0, fmt.Errorf("failed to read line: %w", err)
+		}
+
+		line := strings.TrimRight(string(b), "\r\n")
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", errInvalidHeader, line)
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			if l >= 0 {
+				return 0, fmt.Errorf("%w: %s", errDuplicateHeader, name)
+			}
+
+			if l, err = strconv.Atoi(value); err != nil || l < 0 {
+				return 0, fmt.Errorf("%w: %q", errContentLength, value)
+			}
+
+			if l > maxMessageSize {
+				return 0, fmt.Errorf("%w: %d bytes, maximum is %d", errMessageTooLarge, l, maxMessageSize)
+			}
+		case "content-type":
+			if contentType {
+				return 0, fmt.Errorf("%w: %s", errDuplicateHeader, name)
+			}
+
+			contentType = true
+
+			if err = checkContentType(value); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("%w: %s", errUnknownHeader, name)
+		}
+	}
+
+	if l < 0 {
+		return 0, errNoContentLength
+	}
+
+	return l, nil
+}
+
+// checkContentType reports an error if the given value of the Content-Type
+// header is not supported. The content must be JSON encoded in UTF-8.
+func checkContentType(value string) error {
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", errContentType, value, err)
+	}
+
+	if mediaType != "application/json" && mediaType != "application/vscode-jsonrpc" {
+		return fmt.Errorf("%w: %q", errContentType, value)
+	}
+
+	for k, v := range params {
+		if k != "charset" || (!strings.EqualFold(v, "utf-8") && !strings.EqualFold(v, "utf8")) {
+			return fmt.Errorf("%w: %q", errContentType, value)
+		}
+	}
+
+	return nil
+}
+
+// write writes a response or a batch of responses to the standard output that
+// will be sent to the client.
+func write(res any) error {
 	data, err := json.Marshal(res)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response: %w", err)
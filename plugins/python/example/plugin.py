@@ -0,0 +1,66 @@
+#!/usr/bin/env python3
+# Copyright 2025 The Reginald Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""An example Reginald plugin that uses the Python SDK."""
+
+import os
+import sys
+
+# The SDK is a single module so it can be copied next to the plugin. Here it is
+# loaded from the parent directory.
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), ".."))
+
+from reginald_plugin import Server  # noqa: E402
+
+server = Server("reginald-python-example")
+
+
+@server.command("hello")
+def hello(ctx):
+    """Print a greeting."""
+    name = ctx.args[0] if ctx.args else "world"
+    ctx.progress(f"Hello, {name}!")
+
+
+@server.task("line")
+def line(ctx):
+    """Ensure that each of the files contains the line."""
+    text = ctx.config.str("line")
+    if not text:
+        raise ValueError("no line given")
+    files = ctx.config.paths("files")
+    for path in files:
+        ctx.progress(f"Checking {path}", step=True, total=len(files))
+        lines = []
+        if os.path.exists(path):
+            with open(path, encoding="utf-8") as f:
+                lines = f.read().splitlines()
+        if text in lines:
+            continue
+        ctx.log.info("adding line", extra={"request_id": ctx.request_id})
+        with open(path, "a", encoding="utf-8") as f:
+            f.write(text + "\n")
+
+
+@server.validator("line")
+def validate_line(ctx):
+    """Check that the line is given before any of the tasks are run."""
+    if not ctx.config.str("line"):
+        return ["no line given"]
+    return None
+
+
+if __name__ == "__main__":
+    server.main()
@@ -0,0 +1,606 @@
+# Copyright 2025 The Reginald Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Reginald plugin SDK for Python.
+
+The module implements the Reginald plugin protocol over the standard streams so
+that a plugin only has to register its commands and tasks:
+
+    server = Server("reginald-example")
+
+    @server.command("hello")
+    def hello(ctx):
+        ctx.progress(f"Hello, {ctx.config.str('name', 'world')}!")
+
+    @server.task("line")
+    def line(ctx):
+        path = ctx.config.path("file")
+        ...
+
+    if __name__ == "__main__":
+        server.main()
+
+The standard output is reserved for the protocol messages. The output for
+the user should be sent with the logging handler from the server, with
+Context.progress, or, while running a task, written to the standard error
+output as the output of the task.
+
+The module has no dependencies outside of the standard library and supports
+Python 3.8 and later.
+"""
+
+import json
+import logging
+import os
+import re
+import sys
+import traceback
+from datetime import datetime, timedelta, timezone
+
+PROTOCOL = "reginald"
+PROTOCOL_VERSION = 0
+JSONRPC_VERSION = "2.0"
+
+# Error codes used by the protocol.
+PARSE_ERROR = -32700
+INVALID_REQUEST = -32600
+METHOD_NOT_FOUND = -32601
+INVALID_PARAMS = -32602
+INTERNAL_ERROR = -32603
+HANDSHAKE_ERROR = -32000
+INVALID_COMMAND = -32001
+COMMAND_NOT_RUNNABLE = -32002
+COMMAND_ERROR = -32003
+
+# Log levels of the protocol. They match the levels of log/slog in Go.
+_LEVELS = (
+    (logging.ERROR, 8),
+    (logging.WARNING, 4),
+    (logging.INFO, 0),
+    (logging.DEBUG, -4),
+)
+_TRACE_LEVEL = -8
+
+
+class RPCError(Exception):
+    """An error that is sent to the client as the response to a request."""
+
+    def __init__(self, code, message, data=None):
+        super().__init__(message)
+        self.code = code
+        self.message = message
+        self.data = data
+
+    def to_json(self):
+        """Return the error object of the response."""
+        err = {"code": self.code, "message": self.message}
+        if self.data is not None:
+            err["data"] = self.data
+        return err
+
+
+class ConfigError(RPCError):
+    """An error returned when a config value has the wrong type."""
+
+    def __init__(self, key, message):
+        super().__init__(INVALID_PARAMS, f"invalid config value {key!r}: {message}")
+        self.key = key
+
+
+class Config:
+    """Typed access to the config values sent by the client.
+
+    The accessors return the given default if the value is not set and raise
+    ConfigError if the value has the wrong type. The error is reported to
+    the client as an invalid params error.
+    """
+
+    def __init__(self, values=None):
+        self._values = {}
+        for kv in values or []:
+            self._values[kv.get("key")] = kv
+
+    def __contains__(self, key):
+        return key in self._values
+
+    def __iter__(self):
+        return iter(self._values)
+
+    def raw(self, key, default=None):
+        """Return the value of key without checking its type."""
+        kv = self._values.get(key)
+        if kv is None:
+            return default
+        return kv.get("value", default)
+
+    def str(self, key, default=""):
+        """Return the value of key as a string."""
+        return self._get(key, default, str, ("string", "path", "enum"))
+
+    def bool(self, key, default=False):
+        """Return the value of key as a bool."""
+        return self._get(key, default, bool, ("bool",))
+
+    def int(self, key, default=0):
+        """Return the value of key as an int."""
+        value = self._get(key, default, (int, float), ("int",))
+        if isinstance(value, float):
+            if not value.is_integer():
+                raise ConfigError(key, f"{value} is not an integer")
+            value = int(value)
+        return value
+
+    def float(self, key, default=0.0):
+        """Return the value of key as a float."""
+        return float(self._get(key, default, (int, float), ("float",)))
+
+    def duration(self, key, default=None):
+        """Return the value of key as a timedelta. The client sends
+        the durations as strings like "1m30s"."""
+        value = self._get(key, None, str, ("duration",))
+        if value is None:
+            return default if default is not None else timedelta()
+        try:
+            return _parse_duration(value)
+        except ValueError as e:
+            raise ConfigError(key, str(e)) from e
+
+    def path(self, key, default=""):
+        """Return the value of key as a path with the user home directory and
+        the environment variables expanded."""
+        value = self.str(key, default)
+        if not value:
+            return value
+        return os.path.expanduser(os.path.expandvars(value))
+
+    def list(self, key, default=None):
+        """Return the value of key as a list of strings."""
+        value = self._get(key, default, list, ("stringList", "pathList"))
+        if value is None:
+            return []
+        for item in value:
+            if not isinstance(item, str):
+                raise ConfigError(key, f"list contains {item!r}")
+        return list(value)
+
+    def paths(self, key, default=None):
+        """Return the value of key as a list of expanded paths."""
+        return [os.path.expanduser(os.path.expandvars(p)) for p in self.list(key, default)]
+
+    def dict(self, key, default=None):
+        """Return the value of key as a dictionary. Both "map" and "stringMap"
+        values are accepted."""
+        value = self._get(key, default, dict, ("map", "stringMap"))
+        if value is None:
+            return {}
+        return dict(value)
+
+    def sub(self, key):
+        """Return the nested config values in key as a Config."""
+        value = self._get(key, [], list, ("configs",))
+        return Config(value)
+
+    def to_dict(self):
+        """Return the config values as a dictionary with nested values as
+        nested dictionaries."""
+        out = {}
+        for key, kv in self._values.items():
+            if kv.get("type") == "configs":
+                out[key] = Config(kv.get("value")).to_dict()
+            else:
+                out[key] = kv.get("value")
+        return out
+
+    def _get(self, key, default, types, value_types):
+        kv = self._values.get(key)
+        if kv is None:
+            return default
+        if kv.get("type") is not None and kv.get("type") not in value_types:
+            raise ConfigError(key, f"expected {' or '.join(value_types)}, got {kv.get('type')}")
+        value = kv.get("value")
+        # bool is a subclass of int so it is checked separately.
+        if not isinstance(value, types) or (isinstance(value, bool) and bool not in _tuple(types)):
+            raise ConfigError(key, f"{value!r} has the wrong type")
+        return value
+
+
+class Context:
+    """The context of a command or a task run.
+
+    It contains the config values and the arguments of the run, the facts about
+    the system that the client sends with the tasks, and the helpers for
+    communicating with the client.
+    """
+
+    def __init__(self, server, request_id, config, plugin_config=None, args=None, facts=None):
+        self.server = server
+        self.request_id = request_id
+        self.config = config
+        self.plugin_config = plugin_config if plugin_config is not None else Config()
+        self.args = list(args or [])
+        self.facts = dict(facts or {})
+        self.log = logging.LoggerAdapter(server.logger, {"request_id": request_id})
+        self._step = 0
+
+    def progress(self, message, step=False, total=0):
+        """Report the progress of the run to the client.
+
+        If step is true, the current step is advanced. If total is given,
+        the client shows the current and the total number of the steps.
+        """
+        if step:
+            self._step += 1
+        if not self.server.client_capabilities.get("progress"):
+            return
+        params = {"message": message, "requestId": self.request_id}
+        if total > 0:
+            params["current"] = min(self._step, total)
+            params["total"] = total
+        self.server.notify("progress", params)
+
+    def prompt(self, prompt, default=None, secret=False):
+        """Ask the user for text input and return the answer."""
+        self._require_prompt()
+        params = {"prompt": prompt, "secret": secret}
+        if default is not None:
+            params["default"] = default
+        return self.server.call("prompt", params)["answer"]
+
+    def confirm(self, prompt, default=False):
+        """Ask the user a yes-or-no question and return the answer."""
+        self._require_prompt()
+        return self.server.call("confirm", {"prompt": prompt, "default": default})["confirmed"]
+
+    def _require_prompt(self):
+        if not self.server.client_capabilities.get("prompt"):
+            raise RuntimeError("the client does not support prompting the user")
+
+
+class LogHandler(logging.Handler):
+    """A logging handler that sends the records to the client as "log"
+    notifications."""
+
+    def __init__(self, server, level=logging.NOTSET):
+        super().__init__(level)
+        self.server = server
+
+    def emit(self, record):
+        try:
+            params = {
+                "time": datetime.fromtimestamp(record.created, timezone.utc).isoformat(),
+                "level": _protocol_level(record.levelno),
+                "msg": record.getMessage(),
+                "source": {"function": record.funcName, "file": record.pathname, "line": record.lineno},
+                "attrs": [],
+            }
+            request_id = getattr(record, "request_id", None)
+            if request_id is not None:
+                params["requestId"] = request_id
+            if record.exc_info:
+                params["attrs"].append({"key": "exception", "value": self.formatException(record.exc_info)})
+            self.server.notify("log", params)
+        except Exception:  # noqa: BLE001
+            self.handleError(record)
+
+
+class Server:
+    """A Reginald plugin server.
+
+    The server reads the requests from the client from the standard input and
+    writes the responses to the standard output. The commands and the tasks
+    are registered with the command and task decorators.
+    """
+
+    def __init__(self, name, stdin=None, stdout=None):
+        self.name = name
+        self.stdin = stdin if stdin is not None else sys.stdin.buffer
+        self.stdout = stdout if stdout is not None else sys.stdout.buffer
+        self.commands = {}
+        self.tasks = {}
+        self.validators = {}
+        self.logger = logging.getLogger(name)
+        self.logger.addHandler(LogHandler(self))
+        self.logger.setLevel(logging.DEBUG)
+        self.logger.propagate = False
+        self._shutdown = False
+        # The optional features that the client supports. They are set in
+        # the handshake.
+        self.client_capabilities = {}
+        self._last_id = 0
+        self._pending = []
+
+    def command(self, name):
+        """Register the decorated function as the command with the given name.
+
+        Subcommands are registered with their parent commands separated by
+        dots. The function is called with a Context.
+        """
+
+        def decorator(fn):
+            self.commands[name] = fn
+            return fn
+
+        return decorator
+
+    def task(self, task_type):
+        """Register the decorated function as the task type with the given
+        name. The function is called with a Context."""
+
+        def decorator(fn):
+            self.tasks[task_type] = fn
+            return fn
+
+        return decorator
+
+    def validator(self, task_type):
+        """Register the decorated function as the validator of the task type
+        with the given name. The function is called with a Context before any
+        of the tasks are run, and it returns a list of the problems found in
+        the config of the task, or None if the config is valid."""
+
+        def decorator(fn):
+            self.validators[task_type] = fn
+            return fn
+
+        return decorator
+
+    def main(self):
+        """Serve the requests and exit the process with the status code."""
+        sys.exit(0 if self.serve() else 1)
+
+    def serve(self):
+        """Handle the requests until the client sends the "exit" notification.
+
+        It returns True if the server was shut down before the exit, or False
+        if the client exited without the shutdown or closed the connection.
+        """
+        while True:
+            if self._pending:
+                msg = self._pending.pop(0)
+            else:
+                msg = read_message(self.stdin)
+            if msg is None:
+                return False
+            if isinstance(msg, list):
+                if self._batch(msg):
+                    return self._shutdown
+                continue
+            method = msg.get("method")
+            if method == "exit":
+                return self._shutdown
+            if "id" not in msg or msg.get("id") is None:
+                continue
+            self._respond(msg["id"], method, msg.get("params"))
+
+    def notify(self, method, params):
+        """Send a notification to the client."""
+        write_message(self.stdout, {"jsonrpc": JSONRPC_VERSION, "method": method, "params": params})
+
+    def call(self, method, params):
+        """Send a request to the client and return the result.
+
+        The requests from the client that arrive before the response are
+        handled after the current request. An RPCError is raised if the client
+        responds with an error.
+        """
+        self._last_id += 1
+        req_id = f"{self.name}-{self._last_id}"
+        write_message(self.stdout, {"jsonrpc": JSONRPC_VERSION, "id": req_id, "method": method, "params": params})
+        while True:
+            msg = read_message(self.stdin)
+            if msg is None:
+                raise RPCError(INTERNAL_ERROR, "connection closed while waiting for response")
+            if isinstance(msg, list) or "method" in msg:
+                self._pending.append(msg)
+                continue
+            if msg.get("id") != req_id:
+                continue
+            if msg.get("error"):
+                err = msg["error"]
+                raise RPCError(err.get("code", INTERNAL_ERROR), err.get("message", ""), err.get("data"))
+            return msg.get("result")
+
+    def _batch(self, msgs):
+        """Handle a batch of requests and send the responses as a batch. It
+        returns True if the batch contained the "exit" notification."""
+        responses = []
+        for msg in msgs:
+            method = msg.get("method")
+            if method == "exit":
+                return True
+            if msg.get("id") is None:
+                continue
+            responses.append(self._response(msg["id"], method, msg.get("params")))
+        if responses:
+            write_message(self.stdout, responses)
+        return False
+
+    def _respond(self, req_id, method, params):
+        write_message(self.stdout, self._response(req_id, method, params))
+
+    def _response(self, req_id, method, params):
+        res = {"jsonrpc": JSONRPC_VERSION, "id": req_id}
+        try:
+            res["result"] = self._handle(req_id, method, params)
+        except RPCError as e:
+            res["error"] = e.to_json()
+        except Exception as e:  # noqa: BLE001
+            res["error"] = RPCError(INTERNAL_ERROR, str(e), traceback.format_exc()).to_json()
+        return res
+
+    def _handle(self, req_id, method, params):
+        if params is None:
+            params = {}
+        if self._shutdown:
+            raise RPCError(METHOD_NOT_FOUND, f"method {method!r} not available when shutting down")
+        if method == "shutdown":
+            self._shutdown = True
+            return True
+        if method == "ping":
+            return {}
+        if not isinstance(params, dict):
+            raise RPCError(INVALID_PARAMS, "params must be an object")
+        if method == "handshake":
+            return self._handshake(params)
+        if method == "runCommand":
+            return self._run_command(req_id, params)
+        if method == "runTask":
+            return self._run_task(req_id, params)
+        if method == "validateTask":
+            return self._validate_task(req_id, params)
+        raise RPCError(METHOD_NOT_FOUND, f"method not found: {method}")
+
+    def _handshake(self, params):
+        # Clients that do not send the range of the supported versions support
+        # only the version they send.
+        low = params.get("minProtocolVersion", params.get("protocolVersion"))
+        high = params.get("maxProtocolVersion", params.get("protocolVersion"))
+        if (
+            params.get("protocol") != PROTOCOL
+            or not isinstance(low, int)
+            or not isinstance(high, int)
+            or not low <= PROTOCOL_VERSION <= high
+        ):
+            raise RPCError(
+                HANDSHAKE_ERROR,
+                "handshake error",
+                f"expected protocol {PROTOCOL} with version {PROTOCOL_VERSION}, "
+                f"got {params.get('protocol')} with versions {low} to {high}",
+            )
+        self.client_capabilities = params.get("capabilities") or {}
+        return {
+            "name": self.name,
+            "protocol": PROTOCOL,
+            "protocolVersion": PROTOCOL_VERSION,
+            "capabilities": {"batch": True, "validate": bool(self.validators)},
+        }
+
+    def _run_command(self, req_id, params):
+        cmd = params.get("cmd")
+        fn = self.commands.get(cmd)
+        if fn is None:
+            raise RPCError(INVALID_COMMAND, "invalid command", f"unknown command: {cmd}")
+        ctx = Context(
+            self,
+            req_id,
+            Config(params.get("config")),
+            Config(params.get("pluginConfig")),
+            params.get("args"),
+        )
+        _run(fn, ctx, "command error")
+        return {}
+
+    def _run_task(self, req_id, params):
+        task_type = params.get("taskType")
+        fn = self.tasks.get(task_type)
+        if fn is None:
+            raise RPCError(INVALID_PARAMS, "invalid params", f"unknown task: {task_type}")
+        ctx = Context(self, req_id, Config(params.get("config")), facts=params.get("facts"))
+        _run(fn, ctx, "task error")
+        return {}
+
+    def _validate_task(self, req_id, params):
+        task_type = params.get("taskType")
+        if task_type not in self.tasks:
+            raise RPCError(INVALID_PARAMS, "invalid params", f"unknown task: {task_type}")
+        fn = self.validators.get(task_type)
+        if fn is None:
+            return {}
+        ctx = Context(self, req_id, Config(params.get("config")), facts=params.get("facts"))
+        problems = fn(ctx)
+        if not problems:
+            return {}
+        return {"errors": [str(p) for p in problems]}
+
+
+def read_message(stream):
+    """Read a Content-Length framed message from the stream. It returns None
+    when the stream is closed."""
+    length = 0
+    while True:
+        line = stream.readline()
+        if not line:
+            return None
+        line = line.strip()
+        if not line:
+            break
+        key, _, value = line.decode("ascii").partition(":")
+        if key.strip().lower() == "content-length":
+            length = int(value.strip())
+    if length <= 0:
+        raise RPCError(INVALID_REQUEST, "missing Content-Length")
+    return json.loads(stream.read(length))
+
+
+def write_message(stream, msg):
+    """Write a Content-Length framed message to the stream."""
+    data = json.dumps(msg).encode("utf-8")
+    stream.write(b"Content-Length: %d\r\n\r\n" % len(data) + data)
+    stream.flush()
+
+
+def _run(fn, ctx, message):
+    try:
+        fn(ctx)
+    except RPCError:
+        raise
+    except Exception as e:  # noqa: BLE001
+        raise RPCError(COMMAND_ERROR, message, str(e)) from e
+
+
+_DURATION_UNITS = {
+    "ns": timedelta(microseconds=0.001),
+    "us": timedelta(microseconds=1),
+    "\u00b5s": timedelta(microseconds=1),
+    "\u03bcs": timedelta(microseconds=1),
+    "ms": timedelta(milliseconds=1),
+    "s": timedelta(seconds=1),
+    "m": timedelta(minutes=1),
+    "h": timedelta(hours=1),
+}
+
+_DURATION_PART = re.compile(r"(\d+(?:\.\d*)?|\.\d+)(ns|us|\u00b5s|\u03bcs|ms|s|m|h)")
+
+
+def _parse_duration(s):
+    """Parse a duration string in the format of Go's time.Duration."""
+    sign = 1
+    rest = s
+    if rest[:1] in ("-", "+"):
+        sign = -1 if rest[0] == "-" else 1
+        rest = rest[1:]
+    if rest == "0":
+        return timedelta()
+    if not rest:
+        raise ValueError(f"invalid duration {s!r}")
+    total = timedelta()
+    pos = 0
+    while pos < len(rest):
+        m = _DURATION_PART.match(rest, pos)
+        if m is None:
+            raise ValueError(f"invalid duration {s!r}")
+        total += float(m.group(1)) * _DURATION_UNITS[m.group(2)]
+        pos = m.end()
+    return sign * total
+
+
+def _protocol_level(levelno):
+    for level, value in _LEVELS:
+        if levelno >= level:
+            return value
+    return _TRACE_LEVEL
+
+
+def _tuple(types):
+    return types if isinstance(types, tuple) else (types,)
@@ -0,0 +1,275 @@
+# Copyright 2025 The Reginald Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Tests for the Python plugin SDK. Run them with "python3 -m unittest"."""
+
+import io
+import json
+import unittest
+from datetime import timedelta
+
+import reginald_plugin as rp
+
+
+def frame(msg):
+    data = json.dumps(msg).encode("utf-8")
+    return b"Content-Length: %d\r\n\r\n" % len(data) + data
+
+
+def request(req_id, method, params=None):
+    return frame({"jsonrpc": "2.0", "id": req_id, "method": method, "params": params})
+
+
+def handshake(req_id=0):
+    return request(req_id, "handshake", {
+        "protocol": "reginald",
+        "protocolVersion": 0,
+        "minProtocolVersion": 0,
+        "maxProtocolVersion": 0,
+        "capabilities": {"progress": True, "prompt": True, "cancel": True},
+    })
+
+
+def exit_notification():
+    return frame({"jsonrpc": "2.0", "method": "exit"})
+
+
+def run(server_setup, *messages):
+    stdin = io.BytesIO(b"".join(messages))
+    stdout = io.BytesIO()
+    server = rp.Server("reginald-test", stdin, stdout)
+    server_setup(server)
+    ok = server.serve()
+    stdout.seek(0)
+    out = []
+    while True:
+        msg = rp.read_message(stdout)
+        if msg is None:
+            return ok, out
+        out.append(msg)
+
+
+class TestConfig(unittest.TestCase):
+    values = [
+        {"key": "force", "type": "bool", "value": True},
+        {"key": "jobs", "type": "int", "value": 4.0},
+        {"key": "name", "type": "string", "value": "reginald"},
+        {"key": "delay", "type": "duration", "value": "1m30.5s"},
+        {"key": "ratio", "type": "float", "value": 0.5},
+        {"key": "mode", "type": "enum", "value": "safe"},
+        {"key": "dirs", "type": "pathList", "value": ["~/a", "/b"]},
+        {"key": "env", "type": "stringMap", "value": {"EDITOR": "vi"}},
+        {"key": "link", "type": "configs", "value": [{"key": "src", "type": "string", "value": "x"}]},
+    ]
+
+    def test_accessors(self):
+        cfg = rp.Config(self.values)
+        self.assertTrue(cfg.bool("force"))
+        self.assertEqual(cfg.int("jobs"), 4)
+        self.assertEqual(cfg.str("name"), "reginald")
+        self.assertEqual(cfg.str("missing", "default"), "default")
+        self.assertEqual(cfg.duration("delay"), timedelta(seconds=90.5))
+        self.assertEqual(cfg.float("ratio"), 0.5)
+        self.assertEqual(cfg.str("mode"), "safe")
+        self.assertEqual(cfg.paths("dirs")[1], "/b")
+        self.assertFalse(cfg.paths("dirs")[0].startswith("~"))
+        self.assertEqual(cfg.dict("env"), {"EDITOR": "vi"})
+        self.assertEqual(cfg.dict("missing"), {})
+        self.assertEqual(cfg.sub("link").str("src"), "x")
+        self.assertEqual(cfg.to_dict()["link"], {"src": "x"})
+
+    def test_wrong_type(self):
+        cfg = rp.Config(self.values)
+        with self.assertRaises(rp.ConfigError):
+            cfg.int("name")
+        with self.assertRaises(rp.ConfigError):
+            cfg.int("force")
+        with self.assertRaises(rp.ConfigError):
+            cfg.duration("name")
+        with self.assertRaises(rp.ConfigError):
+            cfg.dict("name")
+
+
+class TestServer(unittest.TestCase):
+    def test_handshake(self):
+        ok, out = run(
+            lambda s: None,
+            request(1, "handshake", {"protocol": "reginald", "protocolVersion": 0}),
+            request(2, "shutdown"),
+            exit_notification(),
+        )
+        self.assertTrue(ok)
+        self.assertEqual(out[0]["id"], 1)
+        self.assertEqual(out[0]["result"]["name"], "reginald-test")
+        self.assertIs(out[1]["result"], True)
+
+    def test_handshake_version_range(self):
+        _, out = run(
+            lambda s: None,
+            request(1, "handshake", {
+                "protocol": "reginald",
+                "protocolVersion": 2,
+                "minProtocolVersion": 0,
+                "maxProtocolVersion": 2,
+            }),
+            request(2, "handshake", {
+                "protocol": "reginald",
+                "protocolVersion": 2,
+                "minProtocolVersion": 1,
+                "maxProtocolVersion": 2,
+            }),
+            exit_notification(),
+        )
+        self.assertEqual(out[0]["result"]["protocolVersion"], rp.PROTOCOL_VERSION)
+        self.assertEqual(out[1]["error"]["code"], rp.HANDSHAKE_ERROR)
+
+    def test_errors(self):
+        _, out = run(
+            lambda s: None,
+            request(1, "unknown"),
+            request(2, "runCommand", "invalid"),
+            request(3, "runCommand", {"cmd": "missing", "config": [], "pluginConfig": []}),
+            exit_notification(),
+        )
+        self.assertEqual(
+            [m["error"]["code"] for m in out],
+            [rp.METHOD_NOT_FOUND, rp.INVALID_PARAMS, rp.INVALID_COMMAND],
+        )
+
+    def test_command(self):
+        got = {}
+
+        def setup(server):
+            @server.command("greet.say")
+            def say(ctx):
+                got["args"] = ctx.args
+                got["name"] = ctx.config.str("name")
+                ctx.progress("saying")
+
+        ok, out = run(
+            setup,
+            handshake(),
+            request(1, "runCommand", {
+                "cmd": "greet.say",
+                "args": ["a"],
+                "config": [{"key": "name", "type": "string", "value": "x"}],
+                "pluginConfig": [],
+            }),
+            exit_notification(),
+        )
+        self.assertFalse(ok)
+        self.assertEqual(got, {"args": ["a"], "name": "x"})
+        self.assertEqual(out[1]["method"], "progress")
+        self.assertEqual(out[1]["params"]["requestId"], 1)
+        self.assertEqual(out[2]["result"], {})
+
+    def test_task_error(self):
+        def setup(server):
+            @server.task("fail")
+            def fail(ctx):
+                raise ValueError("broken")
+
+        _, out = run(setup, request(1, "runTask", {"taskType": "fail", "config": []}), exit_notification())
+        self.assertEqual(out[0]["error"]["code"], rp.COMMAND_ERROR)
+        self.assertEqual(out[0]["error"]["data"], "broken")
+
+    def test_task_facts(self):
+        got = {}
+
+        def setup(server):
+            @server.task("t")
+            def t(ctx):
+                got.update(ctx.facts)
+
+        facts = {"os": "linux", "arch": "arm64", "wsl": False}
+        run(setup, request(1, "runTask", {"taskType": "t", "config": [], "facts": facts}), exit_notification())
+        self.assertEqual(got, facts)
+
+    def test_validate_task(self):
+        def setup(server):
+            @server.task("t")
+            def t(ctx):
+                pass
+
+            @server.task("u")
+            def u(ctx):
+                pass
+
+            @server.validator("t")
+            def validate(ctx):
+                if not ctx.config.str("name"):
+                    return ["name must be set"]
+                return None
+
+        def params(task_type, name):
+            return {"taskType": task_type, "config": [{"key": "name", "type": "string", "value": name}]}
+
+        _, out = run(
+            setup,
+            handshake(),
+            request(1, "validateTask", params("t", "")),
+            request(2, "validateTask", params("t", "x")),
+            request(3, "validateTask", params("u", "")),
+            request(4, "validateTask", params("missing", "")),
+            exit_notification(),
+        )
+        self.assertIs(out[0]["result"]["capabilities"]["validate"], True)
+        self.assertEqual(out[1]["result"], {"errors": ["name must be set"]})
+        self.assertEqual(out[2]["result"], {})
+        self.assertEqual(out[3]["result"], {})
+        self.assertEqual(out[4]["error"]["code"], rp.INVALID_PARAMS)
+
+    def test_batch(self):
+        ran = []
+
+        def setup(server):
+            @server.task("t")
+            def t(ctx):
+                ran.append(ctx.config.str("n"))
+
+        def task_request(req_id, n):
+            return {
+                "jsonrpc": "2.0",
+                "id": req_id,
+                "method": "runTask",
+                "params": {"taskType": "t", "config": [{"key": "n", "type": "string", "value": n}]},
+            }
+
+        _, out = run(setup, frame([task_request(1, "a"), task_request(2, "b")]), exit_notification())
+        self.assertEqual(ran, ["a", "b"])
+        self.assertEqual(len(out), 1)
+        self.assertEqual([r["id"] for r in out[0]], [1, 2])
+
+    def test_call(self):
+        answers = {}
+
+        def setup(server):
+            @server.command("ask")
+            def ask(ctx):
+                answers["ok"] = ctx.confirm("Continue?")
+
+        ok, out = run(
+            setup,
+            handshake(),
+            request(1, "runCommand", {"cmd": "ask", "config": [], "pluginConfig": []}),
+            frame({"jsonrpc": "2.0", "id": "reginald-test-1", "result": {"confirmed": True}}),
+            exit_notification(),
+        )
+        self.assertEqual(answers, {"ok": True})
+        self.assertEqual(out[1]["method"], "confirm")
+        self.assertEqual(out[2]["result"], {})
+
+
+if __name__ == "__main__":
+    unittest.main()